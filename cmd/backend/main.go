@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
 	"os/signal"
 	"syscall"
@@ -12,9 +13,15 @@ import (
 )
 
 func main() {
+	demo := flag.Bool("demo", false, "заполнять новых студентов одинаковыми демо-данными, как DEMO_ENABLED=true")
+	flag.Parse()
+
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 
 	app := application.New()
+	if *demo {
+		app.EnableDemo()
+	}
 
 	err := app.Start(ctx)
 	if err != nil {
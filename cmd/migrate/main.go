@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"log"
+
+	"go.uber.org/zap"
+
+	"eats-backend/internal/migrations"
+)
+
+// Обновляет формат файлов данных в каталоге, например в восстановленном бэкапе:
+//
+//	go run ./cmd/migrate -dir data/backups/2024-05-01
+func main() {
+	dir := flag.String("dir", "data", "каталог с файлами данных")
+	check := flag.Bool("check", false, "только проверить версию, ничего не меняя")
+	flag.Parse()
+
+	if *check {
+		if err := migrations.Check(*dir); err != nil {
+			log.Fatalln(err)
+		}
+
+		log.Printf("Data in %s is up to date (version %d)", *dir, migrations.CurrentVersion())
+
+		return
+	}
+
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		log.Fatalf("can't create logger: %s", err)
+	}
+
+	if err := migrations.Migrate(*dir, logger.Sugar()); err != nil {
+		log.Fatalln(err)
+	}
+
+	log.Printf("Data in %s migrated to version %d", *dir, migrations.CurrentVersion())
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"eats-backend/internal/api"
+)
+
+// Записывает спецификацию OpenAPI, построенную по маршрутам сервера, например для проверки в CI:
+//
+//	go run ./cmd/spec -out openapi.json
+func main() {
+	out := flag.String("out", "openapi.json", "куда записать спецификацию, - для stdout")
+	flag.Parse()
+
+	spec, err := api.OpenAPISpec()
+	if err != nil {
+		log.Fatalf("can't build spec: %v", err)
+	}
+
+	spec = append(spec, '\n')
+
+	if *out == "-" {
+		if _, err := os.Stdout.Write(spec); err != nil {
+			log.Fatalf("can't write spec: %v", err)
+		}
+
+		return
+	}
+
+	if err := os.WriteFile(*out, spec, 0644); err != nil {
+		log.Fatalf("can't write spec: %v", err)
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"eats-backend/internal/datacheck"
+)
+
+// Проверяет файлы данных до запуска сервера и печатает все найденные проблемы с путями внутри файлов:
+//
+//	go run ./cmd/validate-data -dir data
+//
+// Код выхода 1, если есть ошибки. Предупреждения на код выхода не влияют.
+func main() {
+	dir := flag.String("dir", "data", "каталог с файлами данных")
+	flag.Parse()
+
+	report := datacheck.Validate(*dir)
+	fmt.Println(report)
+
+	if report.HasErrors() {
+		os.Exit(1)
+	}
+}
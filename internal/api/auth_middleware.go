@@ -3,11 +3,14 @@ package api
 import (
 	"context"
 	"crypto/rsa"
+	"crypto/subtle"
 	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"strings"
+	"sync"
 
 	"github.com/golang-jwt/jwt/v5"
 	"go.uber.org/zap"
@@ -22,43 +25,123 @@ var (
 	errInvalidSigningMethod = errors.New("invalid signing method")
 )
 
+// RevocationList список отозванных токенов по их идентификатору (jti).
+type RevocationList interface {
+	IsRevoked(ctx context.Context, tokenID string) (bool, error)
+	Add(ctx context.Context, tokenIDs ...string) error
+}
+
+// UserSuspensions заблокированные преподавателями пользователи.
+type UserSuspensions interface {
+	GetSuspension(ctx context.Context, userID string) (models.UserSuspension, bool)
+}
+
+// suspendedError токен действителен, но его владелец заблокирован
+type suspendedError struct {
+	suspension models.UserSuspension
+}
+
+func (e *suspendedError) Error() string {
+	return fmt.Sprintf("user %s is suspended by %s: %s", e.suspension.UserID, e.suspension.BlockedBy, e.suspension.Reason)
+}
+
+func (e *suspendedError) Unwrap() error {
+	return errForbidden
+}
+
+// RevocationSet список отозванных токенов в памяти процесса.
+type RevocationSet struct {
+	tokenIDs map[string]struct{}
+
+	mux sync.RWMutex
+}
+
+func NewRevocationSet(tokenIDs []string) *RevocationSet {
+	set := &RevocationSet{tokenIDs: make(map[string]struct{}, len(tokenIDs))}
+	for _, id := range tokenIDs {
+		set.tokenIDs[id] = struct{}{}
+	}
+
+	return set
+}
+
+func (s *RevocationSet) IsRevoked(_ context.Context, tokenID string) (bool, error) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	_, has := s.tokenIDs[tokenID]
+
+	return has, nil
+}
+
+// Add добавляет токены в список отозванных.
+func (s *RevocationSet) Add(_ context.Context, tokenIDs ...string) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	for _, id := range tokenIDs {
+		s.tokenIDs[id] = struct{}{}
+	}
+
+	return nil
+}
+
+// BootstrapTokenID идентификатор, под которым в журнале выданных токенов записывается вход по паролю администратора.
+const BootstrapTokenID = "bootstrap"
+
+// BootstrapCredentials логин и пароль администратора для выдачи токенов без JWT.
+// Пустой пароль отключает вход по паролю.
+type BootstrapCredentials struct {
+	User     string
+	Password string
+}
+
 type AuthMiddleware struct {
 	publicKey *rsa.PublicKey
+	bootstrap BootstrapCredentials
 
 	logger        *zap.SugaredLogger
-	revokedTokens map[string]struct{}
+	revokedTokens RevocationList
+	suspensions   UserSuspensions
 }
 
 func NewAuthMiddleware(
 	publicKey *rsa.PublicKey,
+	bootstrap BootstrapCredentials,
 	logger *zap.SugaredLogger,
-	revokedTokensList []string,
+	revokedTokens RevocationList,
+	suspensions UserSuspensions,
 ) *AuthMiddleware {
-	revokedTokens := make(map[string]struct{}, len(revokedTokensList))
-	for _, token := range revokedTokensList {
-		revokedTokens[token] = struct{}{}
-	}
-
 	return &AuthMiddleware{
 		publicKey:     publicKey,
+		bootstrap:     bootstrap,
 		logger:        logger,
 		revokedTokens: revokedTokens,
+		suspensions:   suspensions,
 	}
 }
 
 func (m *AuthMiddleware) JWTAuth(next http.HandlerFunc) http.HandlerFunc {
 	return func(response http.ResponseWriter, request *http.Request) {
-		claims, err := m.Check(request.Header.Get("Authorization"), request.URL.Path)
+		claims, err := m.Check(request.Context(), request.Header.Get("Authorization"), request.URL.Path)
 		if err != nil {
 			response.Header().Set("Content-Type", "application/json")
 
 			m.logger.Errorf("can't check JWT: %s, payload: %s", err, m.payload(request))
 
-			var errRes error
-			if errors.Is(err, errForbidden) {
+			var (
+				errRes    error
+				suspended *suspendedError
+			)
+
+			switch {
+			case errors.As(err, &suspended):
+				response.WriteHeader(http.StatusForbidden)
+				_, errRes = response.Write(suspendedResponse(suspended.suspension))
+			case errors.Is(err, errForbidden):
 				response.WriteHeader(http.StatusForbidden)
 				_, errRes = response.Write([]byte(`{"error": "forbidden"}`))
-			} else {
+			default:
 				response.WriteHeader(http.StatusUnauthorized)
 				_, errRes = response.Write([]byte(`{"error": "unauthorized"}`))
 			}
@@ -70,10 +153,119 @@ func (m *AuthMiddleware) JWTAuth(next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
+		setAccessLogUser(request.Context(), claims)
+
+		next.ServeHTTP(response, request.WithContext(ContextWithClaims(request.Context(), claims)))
+	}
+}
+
+// suspendedResponse тело ответа заблокированному пользователю: причина нужна, чтобы он понял, к кому обращаться
+func suspendedResponse(suspension models.UserSuspension) []byte {
+	buf, err := json.Marshal(map[string]any{
+		"error":     "forbidden",
+		"code":      "user_suspended",
+		"reason":    suspension.Reason,
+		"blockedAt": suspension.BlockedAt,
+	})
+	if err != nil {
+		return []byte(`{"error": "forbidden", "code": "user_suspended"}`)
+	}
+
+	return buf
+}
+
+// TeacherOnly пропускает только запросы с токеном преподавателя. Ставится после JWTAuth.
+func (m *AuthMiddleware) TeacherOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(response http.ResponseWriter, request *http.Request) {
+		claims := models.ClaimsFromContext(request.Context())
+		if claims == nil || !claims.IsTeacher {
+			m.logger.Errorf("access to %s denied: not a teacher, payload: %s", request.URL.Path, m.payload(request))
+
+			response.Header().Set("Content-Type", "application/json")
+			response.WriteHeader(http.StatusForbidden)
+
+			if _, err := response.Write([]byte(`{"error": "forbidden"}`)); err != nil {
+				m.logger.Errorf("can't write response: %s, payload: %s", err, m.payload(request))
+			}
+
+			return
+		}
+
+		next.ServeHTTP(response, request)
+	}
+}
+
+// CourierOnly пропускает только токены курьеров
+func (m *AuthMiddleware) CourierOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(response http.ResponseWriter, request *http.Request) {
+		claims := models.ClaimsFromContext(request.Context())
+		if !claims.IsCourier() {
+			m.logger.Errorf("access to %s denied: not a courier, payload: %s", request.URL.Path, m.payload(request))
+
+			response.Header().Set("Content-Type", "application/json")
+			response.WriteHeader(http.StatusForbidden)
+
+			if _, err := response.Write([]byte(`{"error": "forbidden"}`)); err != nil {
+				m.logger.Errorf("can't write response: %s, payload: %s", err, m.payload(request))
+			}
+
+			return
+		}
+
+		next.ServeHTTP(response, request)
+	}
+}
+
+// TokenIssuer пропускает к выдаче токенов администратора по Basic-авторизации из конфигурации
+// или преподавателя по JWT. Так первый токен преподавателя можно получить без уже выданного.
+func (m *AuthMiddleware) TokenIssuer(next http.HandlerFunc) http.HandlerFunc {
+	teacherOnly := m.JWTAuth(m.TeacherOnly(next))
+
+	return func(response http.ResponseWriter, request *http.Request) {
+		user, password, ok := request.BasicAuth()
+		if !ok {
+			teacherOnly(response, request)
+
+			return
+		}
+
+		if !m.checkBootstrap(user, password) {
+			m.logger.Errorf("bootstrap auth failed for user %q from %s", user, request.RemoteAddr)
+
+			response.Header().Set("Content-Type", "application/json")
+			response.Header().Set("WWW-Authenticate", `Basic realm="eats-backend"`)
+			response.WriteHeader(http.StatusUnauthorized)
+
+			if _, err := response.Write([]byte(`{"error": "unauthorized"}`)); err != nil {
+				m.logger.Errorf("can't write response: %s", err)
+			}
+
+			return
+		}
+
+		claims := &models.AuthTokenClaims{
+			RegisteredClaims: &jwt.RegisteredClaims{ID: BootstrapTokenID},
+			Nickname:         user,
+			IsTeacher:        true,
+		}
+
+		setAccessLogUser(request.Context(), claims)
+
 		next.ServeHTTP(response, request.WithContext(ContextWithClaims(request.Context(), claims)))
 	}
 }
 
+func (m *AuthMiddleware) checkBootstrap(user, password string) bool {
+	if m.bootstrap.Password == "" {
+		return false
+	}
+
+	userOK := subtle.ConstantTimeCompare([]byte(user), []byte(m.bootstrap.User))
+	passwordOK := subtle.ConstantTimeCompare([]byte(password), []byte(m.bootstrap.Password))
+
+	return userOK&passwordOK == 1
+}
+
 func (m *AuthMiddleware) payload(request *http.Request) string {
 	aHdr := request.Header.Get("Authorization")
 	aHdrParts := strings.Split(aHdr, ".")
@@ -94,7 +286,7 @@ func ContextWithClaims(ctx context.Context, claims *models.AuthTokenClaims) cont
 	return context.WithValue(ctx, models.ContextClaimsKey{}, claims)
 }
 
-func (m *AuthMiddleware) Check(serviceJWT, requestedMethod string) (*models.AuthTokenClaims, error) {
+func (m *AuthMiddleware) Check(ctx context.Context, serviceJWT, requestedMethod string) (*models.AuthTokenClaims, error) {
 	jwtAuthPrefix := "Bearer "
 
 	if !strings.HasPrefix(serviceJWT, jwtAuthPrefix) {
@@ -108,7 +300,12 @@ func (m *AuthMiddleware) Check(serviceJWT, requestedMethod string) (*models.Auth
 		return nil, fmt.Errorf("can't parse JWT: %w", err)
 	}
 
-	if m.isRevoked(claims.ID) {
+	revoked, err := m.revokedTokens.IsRevoked(ctx, claims.ID)
+	if err != nil {
+		return nil, fmt.Errorf("can't check revocation: %w", err)
+	}
+
+	if revoked {
 		return nil, fmt.Errorf(
 			"%w: revoked token with nickname %s and id %s",
 			errForbidden,
@@ -117,6 +314,10 @@ func (m *AuthMiddleware) Check(serviceJWT, requestedMethod string) (*models.Auth
 		)
 	}
 
+	if suspension, ok := m.suspensions.GetSuspension(ctx, claims.ID); ok {
+		return nil, &suspendedError{suspension: suspension}
+	}
+
 	if requestedMethod == "/api/generate-token" {
 		if !claims.IsTeacher {
 			return nil, fmt.Errorf(
@@ -131,12 +332,6 @@ func (m *AuthMiddleware) Check(serviceJWT, requestedMethod string) (*models.Auth
 	return claims, nil
 }
 
-func (m *AuthMiddleware) isRevoked(id string) bool {
-	_, has := m.revokedTokens[id]
-
-	return has
-}
-
 func (m *AuthMiddleware) parse(token string) (*models.AuthTokenClaims, error) {
 	parser := jwt.NewParser()
 
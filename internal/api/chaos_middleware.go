@@ -0,0 +1,110 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"eats-backend/internal/models"
+)
+
+type ChaosRules interface {
+	PickFault(userID, route string) (models.ChaosRule, bool)
+}
+
+// ChaosMiddleware внедряет сбои по правилам, заданным преподавателем. Ставится после JWTAuth.
+type ChaosMiddleware struct {
+	rules  ChaosRules
+	logger *zap.SugaredLogger
+}
+
+func NewChaosMiddleware(rules ChaosRules, logger *zap.SugaredLogger) *ChaosMiddleware {
+	return &ChaosMiddleware{
+		rules:  rules,
+		logger: logger,
+	}
+}
+
+func (m *ChaosMiddleware) Middleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(response http.ResponseWriter, request *http.Request) {
+		// административные маршруты не ломаем, иначе правило нельзя будет снять
+		if strings.Contains(request.Pattern, " /admin/") {
+			next.ServeHTTP(response, request)
+
+			return
+		}
+
+		claims := models.ClaimsFromContext(request.Context())
+		if claims == nil {
+			next.ServeHTTP(response, request)
+
+			return
+		}
+
+		rule, ok := m.rules.PickFault(claims.ID, request.Pattern)
+		if !ok {
+			next.ServeHTTP(response, request)
+
+			return
+		}
+
+		m.logger.With("module", "chaos").Infof(
+			"Injecting %s into %s for %s by rule %s",
+			rule.Fault,
+			request.Pattern,
+			claims.Nickname,
+			rule.ID,
+		)
+
+		switch rule.Fault {
+		case models.ChaosFaultDelay:
+			select {
+			case <-time.After(time.Duration(rule.DelayMs) * time.Millisecond):
+			case <-request.Context().Done():
+				return
+			}
+
+			next.ServeHTTP(response, request)
+		case models.ChaosFaultError:
+			response.Header().Set("Content-Type", "application/json")
+			response.WriteHeader(http.StatusInternalServerError)
+
+			if _, err := response.Write([]byte(`{"error": "internal server error"}`)); err != nil {
+				m.logger.Errorf("can't write response: %s", err)
+			}
+		case models.ChaosFaultMalformedJSON:
+			response.Header().Set("Content-Type", "application/json")
+			response.WriteHeader(http.StatusOK)
+
+			if _, err := response.Write([]byte(`{"id": "`)); err != nil {
+				m.logger.Errorf("can't write response: %s", err)
+			}
+		case models.ChaosFaultReset:
+			m.resetConnection(response)
+		default:
+			next.ServeHTTP(response, request)
+		}
+	}
+}
+
+// resetConnection обрывает соединение с RST, не отправляя ответ.
+func (m *ChaosMiddleware) resetConnection(response http.ResponseWriter) {
+	conn, _, err := http.NewResponseController(response).Hijack()
+	if err != nil {
+		// соединение не поддерживает hijack (например, HTTP/2), обрываем средствами сервера
+		panic(http.ErrAbortHandler)
+	}
+
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		if err := tcpConn.SetLinger(0); err != nil {
+			m.logger.Errorf("can't set linger: %s", err)
+		}
+	}
+
+	if err := conn.Close(); err != nil {
+		m.logger.Errorf("can't close connection: %s", err)
+	}
+}
@@ -0,0 +1,241 @@
+package api
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// CompressionMiddleware сжимает ответы gzip или deflate, если клиент их принимает. Сжимаются только
+// ответы из списка типов не меньше minSize байт, поэтому уже сжатые файлы (картинки, zip) отдаются как есть.
+type CompressionMiddleware struct {
+	minSize      int
+	contentTypes []string
+}
+
+func NewCompressionMiddleware(minSize int, contentTypes []string) *CompressionMiddleware {
+	return &CompressionMiddleware{
+		minSize:      minSize,
+		contentTypes: contentTypes,
+	}
+}
+
+func (m *CompressionMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		response.Header().Add("Vary", "Accept-Encoding")
+
+		encoding := negotiateEncoding(request.Header.Get("Accept-Encoding"))
+		if encoding == "" || request.Method == http.MethodHead || request.Header.Get("Range") != "" {
+			next.ServeHTTP(response, request)
+
+			return
+		}
+
+		writer := &compressWriter{ResponseWriter: response, middleware: m, encoding: encoding}
+		defer writer.Close()
+
+		next.ServeHTTP(writer, request)
+	})
+}
+
+// allowed проверяет тип ответа по списку. "text/*" в списке разрешает все текстовые типы.
+func (m *CompressionMiddleware) allowed(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	for _, allowed := range m.contentTypes {
+		if prefix, ok := strings.CutSuffix(allowed, "/*"); ok {
+			if strings.HasPrefix(mediaType, prefix+"/") {
+				return true
+			}
+
+			continue
+		}
+
+		if mediaType == allowed {
+			return true
+		}
+	}
+
+	return false
+}
+
+// negotiateEncoding выбирает gzip или deflate из Accept-Encoding, gzip предпочтительнее при равном весе.
+func negotiateEncoding(header string) string {
+	best, bestWeight := "", 0.0
+
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		weight := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+
+			weight = parsed
+		}
+
+		if name == "*" {
+			name = "gzip"
+		}
+
+		if (name != "gzip" && name != "deflate") || weight <= 0 {
+			continue
+		}
+
+		if weight > bestWeight || (weight == bestWeight && name == "gzip") {
+			best, bestWeight = name, weight
+		}
+	}
+
+	return best
+}
+
+// compressWriter копит начало ответа, пока не станет ясно, стоит ли его сжимать: по типу ответа,
+// Content-Encoding обработчика и размеру. Статус тоже откладывается, чтобы успеть поменять заголовки.
+type compressWriter struct {
+	http.ResponseWriter
+	middleware *CompressionMiddleware
+	encoding   string
+
+	statusCode int
+	buf        []byte
+	decided    bool
+	compressor io.WriteCloser
+}
+
+func (w *compressWriter) WriteHeader(statusCode int) {
+	if w.decided || w.statusCode != 0 {
+		return
+	}
+
+	// Информационные ответы не откладываются, за ними идет настоящий статус
+	if statusCode < http.StatusOK {
+		w.ResponseWriter.WriteHeader(statusCode)
+
+		return
+	}
+
+	w.statusCode = statusCode
+}
+
+func (w *compressWriter) Write(body []byte) (int, error) {
+	if w.statusCode == 0 {
+		w.statusCode = http.StatusOK
+	}
+
+	if !w.decided {
+		w.buf = append(w.buf, body...)
+		if len(w.buf) < w.middleware.minSize {
+			return len(body), nil
+		}
+
+		if err := w.decide(); err != nil {
+			return 0, err
+		}
+
+		return len(body), nil
+	}
+
+	if w.compressor != nil {
+		return w.compressor.Write(body)
+	}
+
+	return w.ResponseWriter.Write(body)
+}
+
+// Flush отправляет накопленное, чтобы потоковые ответы не ждали заполнения буфера.
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		if w.statusCode == 0 {
+			w.statusCode = http.StatusOK
+		}
+
+		if err := w.decide(); err != nil {
+			return
+		}
+	}
+
+	if flusher, ok := w.compressor.(interface{ Flush() error }); ok {
+		_ = flusher.Flush()
+	}
+
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Close дописывает ответ, который оказался меньше minSize, и завершает сжатый поток.
+func (w *compressWriter) Close() {
+	if !w.decided {
+		if w.statusCode == 0 && len(w.buf) == 0 {
+			return
+		}
+
+		if w.statusCode == 0 {
+			w.statusCode = http.StatusOK
+		}
+
+		if err := w.decide(); err != nil {
+			return
+		}
+	}
+
+	if w.compressor != nil {
+		_ = w.compressor.Close()
+	}
+}
+
+func (w *compressWriter) decide() error {
+	w.decided = true
+
+	header := w.Header()
+	if header.Get("Content-Type") == "" && len(w.buf) > 0 {
+		header.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+
+	compress := len(w.buf) >= w.middleware.minSize &&
+		w.statusCode != http.StatusNoContent && w.statusCode != http.StatusNotModified &&
+		header.Get("Content-Encoding") == "" &&
+		w.middleware.allowed(header.Get("Content-Type"))
+
+	if compress {
+		header.Del("Content-Length")
+		header.Set("Content-Encoding", w.encoding)
+
+		if w.encoding == "gzip" {
+			w.compressor = gzip.NewWriter(w.ResponseWriter)
+		} else {
+			w.compressor, _ = flate.NewWriter(w.ResponseWriter, flate.DefaultCompression)
+		}
+	}
+
+	w.ResponseWriter.WriteHeader(w.statusCode)
+
+	buf := w.buf
+	w.buf = nil
+
+	if len(buf) == 0 {
+		return nil
+	}
+
+	var err error
+	if w.compressor != nil {
+		_, err = w.compressor.Write(buf)
+	} else {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+
+	return err
+}
@@ -0,0 +1,31 @@
+package api
+
+import (
+	"net/http"
+
+	"eats-backend/internal/models"
+)
+
+type DemoSeeder interface {
+	EnsureUser(userID string)
+}
+
+// DemoMiddleware в демо-режиме заполняет данные студента при первом запросе. Ставится после JWTAuth.
+type DemoMiddleware struct {
+	seeder DemoSeeder
+}
+
+func NewDemoMiddleware(seeder DemoSeeder) *DemoMiddleware {
+	return &DemoMiddleware{seeder: seeder}
+}
+
+func (m *DemoMiddleware) Middleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(response http.ResponseWriter, request *http.Request) {
+		// Преподавателям и курьерам демо-данные не нужны
+		if claims := models.ClaimsFromContext(request.Context()); claims != nil && claims.GetRole() == models.TokenRoleStudent {
+			m.seeder.EnsureUser(claims.ID)
+		}
+
+		next.ServeHTTP(response, request)
+	}
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"eats-backend/internal/models"
+)
+
+type ExperimentAssigner interface {
+	GetAssignments(ctx context.Context) []models.ExperimentAssignment
+}
+
+// ExperimentsMiddleware добавляет в ответ заголовок X-Experiments с вариантами пользователя,
+// чтобы клиент мог отправлять их в аналитику вместе с событиями. Ставится после JWTAuth.
+type ExperimentsMiddleware struct {
+	assigner ExperimentAssigner
+}
+
+func NewExperimentsMiddleware(assigner ExperimentAssigner) *ExperimentsMiddleware {
+	return &ExperimentsMiddleware{assigner: assigner}
+}
+
+func (m *ExperimentsMiddleware) Middleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(response http.ResponseWriter, request *http.Request) {
+		if models.ClaimsFromContext(request.Context()) != nil {
+			assignments := m.assigner.GetAssignments(request.Context())
+			if len(assignments) > 0 {
+				values := make([]string, len(assignments))
+				for i, assignment := range assignments {
+					values[i] = assignment.Experiment + "=" + assignment.Variant
+				}
+
+				response.Header().Set(models.ExperimentsHeader, strings.Join(values, ", "))
+			}
+		}
+
+		next.ServeHTTP(response, request)
+	}
+}
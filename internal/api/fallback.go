@@ -0,0 +1,55 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"eats-backend/internal/models"
+)
+
+// Методы, которые проверяются для заголовка Allow. HEAD обслуживают маршруты GET.
+var routeMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// notFound отвечает на запросы без маршрута. ServeMux сам отвечает на них текстом, а клиентам нужен тот же
+// JSON, что и у остальных ошибок: 405 с заголовком Allow, если путь есть с другим методом, иначе 404.
+func (r *Router) notFound(writer http.ResponseWriter, request *http.Request) {
+	allowed := r.allowedMethods(request)
+	if len(allowed) == 0 {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: no route for %s %s",
+			models.ErrNotFound, request.Method, request.URL.Path))
+
+		return
+	}
+
+	writer.Header().Set("Allow", strings.Join(allowed, ", "))
+	r.sendErrorResponse(writer, request, fmt.Errorf("%w: %s is not allowed for %s, use %s",
+		models.ErrMethodNotAllowed, request.Method, request.URL.Path, strings.Join(allowed, ", ")))
+}
+
+// allowedMethods методы, с которыми путь запроса попадает в зарегистрированный маршрут
+func (r *Router) allowedMethods(request *http.Request) []string {
+	allowed := make([]string, 0, len(routeMethods))
+
+	for _, method := range routeMethods {
+		probe := request.WithContext(request.Context())
+		probe.Method = method
+
+		if _, pattern := r.router.Handler(probe); pattern != "" && pattern != fallbackPattern {
+			allowed = append(allowed, method)
+
+			if method == http.MethodGet {
+				allowed = append(allowed, http.MethodHead)
+			}
+		}
+	}
+
+	return allowed
+}
+
+// fallbackPattern ловит запросы любым методом, для которых нет более точного маршрута
+const fallbackPattern = "/"
+
+func (r *Router) serveDocs(writer http.ResponseWriter, request *http.Request) {
+	http.ServeFile(writer, request, r.docsPage)
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type LeaderChecker interface {
+	IsLeader() bool
+}
+
+// FollowerMiddleware не дает менять данные на экземпляре, который не лидер. Состояние сохраняет только
+// лидер, поэтому запись на ведомом потерялась бы. Такие запросы получают 503 с Retry-After: к повтору
+// балансировщик отправит запрос лидеру или этот экземпляр сам станет лидером. Чтение доступно везде.
+type FollowerMiddleware struct {
+	leader     LeaderChecker
+	retryAfter string
+}
+
+func NewFollowerMiddleware(leader LeaderChecker, retryAfter time.Duration) *FollowerMiddleware {
+	return &FollowerMiddleware{
+		leader:     leader,
+		retryAfter: strconv.Itoa(max(1, int(math.Ceil(retryAfter.Seconds())))),
+	}
+}
+
+func (m *FollowerMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		switch request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			if !m.leader.IsLeader() {
+				response.Header().Set("Content-Type", "application/json")
+				response.Header().Set("Retry-After", m.retryAfter)
+				response.WriteHeader(http.StatusServiceUnavailable)
+				_, _ = response.Write([]byte(`{"error":"this instance is not the leader, retry later","code":"not_leader"}`))
+
+				return
+			}
+		}
+
+		next.ServeHTTP(response, request)
+	})
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"cmp"
+	"context"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"eats-backend/internal/models"
+)
+
+// LanguageMiddleware выбирает язык ответа по заголовку Accept-Language и кладет его в контекст.
+type LanguageMiddleware struct {
+	defaultLanguage string
+	supported       []string
+}
+
+func NewLanguageMiddleware(defaultLanguage string, supported []string) *LanguageMiddleware {
+	return &LanguageMiddleware{
+		defaultLanguage: defaultLanguage,
+		supported:       supported,
+	}
+}
+
+func (m *LanguageMiddleware) Middleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(response http.ResponseWriter, request *http.Request) {
+		lang := m.resolve(request.Header.Get("Accept-Language"))
+
+		response.Header().Set("Content-Language", lang)
+		response.Header().Add("Vary", "Accept-Language")
+
+		ctx := context.WithValue(request.Context(), models.ContextLanguageKey{}, lang)
+		next.ServeHTTP(response, request.WithContext(ctx))
+	}
+}
+
+type languageWeight struct {
+	lang    string
+	quality float64
+}
+
+// resolve возвращает первый поддерживаемый язык в порядке убывания веса q.
+// Регион не учитывается: en-US и en-GB считаются en.
+func (m *LanguageMiddleware) resolve(header string) string {
+	weights := make([]languageWeight, 0)
+
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" {
+			continue
+		}
+
+		quality := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+
+			quality = parsed
+		}
+
+		if quality <= 0 {
+			continue
+		}
+
+		primary, _, _ := strings.Cut(tag, "-")
+		weights = append(weights, languageWeight{lang: strings.ToLower(primary), quality: quality})
+	}
+
+	slices.SortStableFunc(weights, func(a, b languageWeight) int {
+		return cmp.Compare(b.quality, a.quality)
+	})
+
+	for _, weight := range weights {
+		if weight.lang == "*" {
+			return m.defaultLanguage
+		}
+
+		if slices.Contains(m.supported, weight.lang) {
+			return weight.lang
+		}
+	}
+
+	return m.defaultLanguage
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"eats-backend/internal/config"
+	"eats-backend/internal/metrics"
+)
+
+// RouteClass группа маршрутов с общим лимитом одновременных запросов
+type RouteClass string
+
+const (
+	RouteClassRead  RouteClass = "read"
+	RouteClassWrite RouteClass = "write"
+	// Маршруты преподавателя не отнимают места у студентов и наоборот
+	RouteClassAdmin RouteClass = "admin"
+	// Выгрузки и потоки держат место долго, поэтому ограничиваются отдельно
+	RouteClassLongRunning RouteClass = "long_running"
+)
+
+// routeClass класс маршрута для ограничения одновременных запросов
+func routeClass(method string, access routeAccess, doc routeDoc) RouteClass {
+	switch {
+	case doc.LongRunning:
+		return RouteClassLongRunning
+	case access == accessTeacher:
+		return RouteClassAdmin
+	case method == http.MethodGet || method == http.MethodHead:
+		return RouteClassRead
+	default:
+		return RouteClassWrite
+	}
+}
+
+type classLimiter struct {
+	slots chan struct{}
+	// Сколько запросов сейчас ждет свободного места
+	waiting atomic.Int64
+}
+
+// LoadSheddingMiddleware ограничивает число одновременно обрабатываемых запросов каждого класса маршрутов.
+// Когда вся группа разом нагружает сервер, лишние запросы недолго ждут в очереди, а при полной очереди
+// сразу получают 503 с Retry-After и не встают в очередь к общим блокировкам сервисов.
+type LoadSheddingMiddleware struct {
+	classes      map[RouteClass]*classLimiter
+	queueSize    int64
+	queueTimeout time.Duration
+	retryAfter   string
+}
+
+func NewLoadSheddingMiddleware(cfg config.LoadSheddingConfig) *LoadSheddingMiddleware {
+	classes := make(map[RouteClass]*classLimiter, len(cfg.MaxInFlight))
+	for class, limit := range cfg.MaxInFlight {
+		if limit > 0 {
+			classes[RouteClass(class)] = &classLimiter{slots: make(chan struct{}, limit)}
+		}
+	}
+
+	return &LoadSheddingMiddleware{
+		classes:      classes,
+		queueSize:    int64(cfg.QueueSize),
+		queueTimeout: cfg.QueueTimeout,
+		retryAfter:   strconv.Itoa(max(1, int(math.Ceil(cfg.RetryAfter.Seconds())))),
+	}
+}
+
+func (m *LoadSheddingMiddleware) Middleware(class RouteClass, next http.HandlerFunc) http.HandlerFunc {
+	limiter, ok := m.classes[class]
+	if !ok {
+		return next
+	}
+
+	return func(response http.ResponseWriter, request *http.Request) {
+		if !m.acquire(request.Context(), limiter) {
+			metrics.RequestsShed.Inc(string(class))
+
+			response.Header().Set("Content-Type", "application/json")
+			response.Header().Set("Retry-After", m.retryAfter)
+			response.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = response.Write([]byte(`{"error":"server is overloaded, retry later","code":"overloaded"}`))
+
+			return
+		}
+
+		defer func() { <-limiter.slots }()
+
+		next.ServeHTTP(response, request)
+	}
+}
+
+// acquire занимает место для запроса. Если мест нет, ждет в очереди не дольше queueTimeout,
+// при полной очереди возвращает false сразу.
+func (m *LoadSheddingMiddleware) acquire(ctx context.Context, limiter *classLimiter) bool {
+	select {
+	case limiter.slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	if m.queueSize <= 0 || m.queueTimeout <= 0 {
+		return false
+	}
+
+	if limiter.waiting.Add(1) > m.queueSize {
+		limiter.waiting.Add(-1)
+
+		return false
+	}
+
+	defer limiter.waiting.Add(-1)
+
+	timer := time.NewTimer(m.queueTimeout)
+	defer timer.Stop()
+
+	select {
+	case limiter.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -1,21 +1,35 @@
 package api
 
 import (
-	"eats-backend/internal/models"
-	"fmt"
+	"context"
+	"io"
 	"net/http"
+	"sync/atomic"
 	"time"
 
+	"github.com/google/uuid"
 	"go.uber.org/zap"
+
+	"eats-backend/internal/models"
 )
 
+const requestIDHeader = "X-Request-ID"
+
 type responseCapture struct {
 	writer     http.ResponseWriter
 	statusCode int
+	size       int
 }
 
 func (resp *responseCapture) Write(body []byte) (int, error) {
-	return resp.writer.Write(body)
+	if resp.statusCode == 0 {
+		resp.statusCode = http.StatusOK
+	}
+
+	n, err := resp.writer.Write(body)
+	resp.size += n
+
+	return n, err
 }
 
 func (resp *responseCapture) WriteHeader(statusCode int) {
@@ -29,52 +43,194 @@ func (resp *responseCapture) Header() http.Header {
 	return resp.writer.Header()
 }
 
+// Unwrap нужен http.ResponseController, чтобы добраться до Hijack и Flush исходного writer.
+func (resp *responseCapture) Unwrap() http.ResponseWriter {
+	return resp.writer
+}
+
+// countingReader считает размер тела запроса по мере чтения обработчиком.
+type countingReader struct {
+	io.ReadCloser
+	size int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.size += n
+
+	return n, err
+}
+
+// accessLogUser заполняется в JWTAuth, чтобы внешний middleware узнал пользователя запроса.
+type accessLogUser struct {
+	id       string
+	nickname string
+}
+
+type accessLogUserKey struct{}
+
+type requestIDKey struct{}
+
+func setAccessLogUser(ctx context.Context, claims *models.AuthTokenClaims) {
+	user, ok := ctx.Value(accessLogUserKey{}).(*accessLogUser)
+	if !ok || claims == nil {
+		return
+	}
+
+	user.nickname = claims.Nickname
+	if claims.RegisteredClaims != nil {
+		user.id = claims.ID
+	}
+}
+
+// RequestIDFromContext возвращает идентификатор текущего запроса.
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+
+	return requestID
+}
+
+// SlowRequests учитывает запросы дольше порога.
+type SlowRequests interface {
+	Threshold() time.Duration
+	Record(request models.SlowRequest)
+}
+
+// UsageRecorder учитывает запросы пользователей по маршрутам.
+type UsageRecorder interface {
+	RecordRequest(userID, nickname, route string, statusCode int, at time.Time)
+}
+
 type Middleware struct {
 	logger *zap.SugaredLogger
+	// Для частых маршрутов успешные запросы логируются выборочно: route -> каждый N-й.
+	sampling map[string]int
+	counters map[string]*atomic.Uint64
+	slow     SlowRequests
+	usage    UsageRecorder
 }
 
-func NewLoggerMiddleware(logger *zap.SugaredLogger) *Middleware {
+func NewLoggerMiddleware(
+	logger *zap.SugaredLogger,
+	sampling map[string]int,
+	slow SlowRequests,
+	usage UsageRecorder,
+) *Middleware {
+	counters := make(map[string]*atomic.Uint64, len(sampling))
+	for route := range sampling {
+		counters[route] = &atomic.Uint64{}
+	}
+
 	return &Middleware{
-		logger: logger,
+		logger:   logger,
+		sampling: sampling,
+		counters: counters,
+		slow:     slow,
+		usage:    usage,
 	}
 }
 
-func (lm *Middleware) Middleware(next http.HandlerFunc) http.HandlerFunc {
-	return func(response http.ResponseWriter, req *http.Request) {
-		// Create a custom response writer
-		responseWriter := &responseCapture{writer: response}
-
+// Middleware пишет одну запись access-лога на каждый запрос.
+func (lm *Middleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, req *http.Request) {
 		startTime := time.Now()
 
-		// Process request
+		requestID := req.Header.Get(requestIDHeader)
+		if requestID == "" {
+			requestID = uuid.NewString()
+		}
+
+		response.Header().Set(requestIDHeader, requestID)
+
+		user := &accessLogUser{}
+		ctx := context.WithValue(req.Context(), requestIDKey{}, requestID)
+		ctx = context.WithValue(ctx, accessLogUserKey{}, user)
+		ctx, timings := models.ContextWithTimings(ctx)
+
+		body := &countingReader{ReadCloser: req.Body}
+		req = req.WithContext(ctx)
+		req.Body = body
+
+		responseWriter := &responseCapture{writer: response}
+
 		next.ServeHTTP(responseWriter, req)
 
-		// Get response details
 		statusCode := responseWriter.statusCode
 		if statusCode == 0 {
 			statusCode = http.StatusOK
 		}
 
-		method := req.Method
-		path := req.URL.Path
-		userAgent := req.UserAgent()
-		host := req.Host
-
-		// Write the sanitized response body to the response writer
-		responseWriter.WriteHeader(statusCode)
-
-		// Calculate latency in milliseconds
-		latency := time.Since(startTime).Seconds() * 1000
-
-		// Log details in JSON format
-		lm.logger.With(
-			"method", method,
-			"status_code", statusCode,
-			"path", path,
-			"user_agent", userAgent,
-			"host", host,
-			"latency_ms", fmt.Sprintf("%.4fms", latency),
-			"username", models.ClaimsFromContext(req.Context()).Nickname,
-		).Infof("Request handeled")
+		// Pattern выставляет ServeMux после выбора обработчика
+		route := req.Pattern
+		duration := time.Since(startTime)
+
+		lm.usage.RecordRequest(user.id, user.nickname, route, statusCode, startTime)
+
+		if threshold := lm.slow.Threshold(); threshold > 0 && duration >= threshold {
+			lm.recordSlow(models.SlowRequest{
+				RequestID:  requestID,
+				Method:     req.Method,
+				Route:      route,
+				Path:       req.URL.Path,
+				UserID:     user.id,
+				Nickname:   user.nickname,
+				StatusCode: statusCode,
+				DurationMs: float64(duration.Microseconds()) / 1000,
+				At:         startTime,
+			}, timings)
+		}
+
+		if !lm.shouldLog(route, statusCode) {
+			return
+		}
+
+		lm.logger.Desugar().Info("Request handled",
+			zap.String("request_id", requestID),
+			zap.String("method", req.Method),
+			zap.String("path", req.URL.Path),
+			zap.String("route", route),
+			zap.Int("status_code", statusCode),
+			zap.Float64("latency_ms", float64(duration.Microseconds())/1000),
+			zap.Int("request_size", body.size),
+			zap.Int("response_size", responseWriter.size),
+			zap.String("user_id", user.id),
+			zap.String("username", user.nickname),
+			zap.String("user_agent", req.UserAgent()),
+			zap.String("remote_addr", req.RemoteAddr),
+		)
+	})
+}
+
+// recordSlow пишет медленный запрос в лог всегда, без выборки, вместе с временем участков сервисов
+func (lm *Middleware) recordSlow(request models.SlowRequest, timings *models.RequestTimings) {
+	durations := timings.Durations()
+	if len(durations) > 0 {
+		request.Timings = make(map[string]float64, len(durations))
+		for name, duration := range durations {
+			request.Timings[name] = float64(duration.Microseconds()) / 1000
+		}
+	}
+
+	lm.slow.Record(request)
+
+	lm.logger.Desugar().Warn("Slow request",
+		zap.String("request_id", request.RequestID),
+		zap.String("method", request.Method),
+		zap.String("route", request.Route),
+		zap.Int("status_code", request.StatusCode),
+		zap.Float64("latency_ms", request.DurationMs),
+		zap.String("user_id", request.UserID),
+		zap.String("username", request.Nickname),
+		zap.Any("timings_ms", request.Timings),
+	)
+}
+
+// shouldLog решает, писать ли запрос в лог. Ошибки логируются всегда.
+func (lm *Middleware) shouldLog(route string, statusCode int) bool {
+	rate, sampled := lm.sampling[route]
+	if !sampled || rate <= 1 || statusCode >= http.StatusBadRequest {
+		return true
 	}
+
+	return lm.counters[route].Add(1)%uint64(rate) == 1
 }
@@ -0,0 +1,27 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+
+	"eats-backend/internal/metrics"
+	"eats-backend/internal/models"
+)
+
+// getMetrics отдает бизнес-метрики в текстовом формате Prometheus для панелей преподавателя
+func (r *Router) getMetrics(writer http.ResponseWriter, request *http.Request) {
+	var buf bytes.Buffer
+	if err := metrics.Default.WriteText(&buf); err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	writer.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	writer.WriteHeader(http.StatusOK)
+
+	if _, err := writer.Write(buf.Bytes()); err != nil {
+		r.logger.Errorf("can't send metrics: %v", err)
+	}
+}
@@ -1,5 +1,11 @@
 package api
 
+import (
+	"time"
+
+	"eats-backend/internal/models"
+)
+
 type PaginatedResponse[T any] struct {
 	Page       int `json:"currentPage"`
 	TotalPages int `json:"totalPages"`
@@ -9,3 +15,38 @@ type PaginatedResponse[T any] struct {
 type TokenResponse struct {
 	Token string `json:"token"`
 }
+
+// WhoAmIResponse данные токена, с которым пришел запрос.
+type WhoAmIResponse struct {
+	TokenID   string           `json:"tokenId"`
+	Nickname  string           `json:"nickname"`
+	IsTeacher bool             `json:"isTeacher"`
+	Role      models.TokenRole `json:"role"`
+	Issuer    string           `json:"issuer,omitempty"`
+	IssuedAt  *time.Time       `json:"issuedAt,omitempty"`
+	ExpiresAt *time.Time       `json:"expiresAt,omitempty"`
+}
+
+type FileResponse struct {
+	File string `json:"file"`
+}
+
+// CartQuantityResponse количество товара в корзине после изменения.
+type CartQuantityResponse struct {
+	Total int `json:"total"`
+}
+
+type HealthResponse struct {
+	Status string `json:"status"`
+}
+
+const (
+	readinessOK       = "ok"
+	readinessDegraded = "degraded"
+)
+
+// ReadinessResponse готовность сервера: degraded, если бэкап не удался после всех повторов.
+type ReadinessResponse struct {
+	Status string              `json:"status"`
+	Backup models.BackupHealth `json:"backup"`
+}
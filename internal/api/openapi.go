@@ -0,0 +1,414 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"eats-backend/internal/models"
+)
+
+// routeAccess кто может вызывать маршрут.
+type routeAccess int
+
+const (
+	accessPublic routeAccess = iota
+	accessUser
+	accessTeacher
+	// Преподаватель по JWT или администратор по паролю из конфигурации
+	accessTokenIssuer
+	accessCourier
+)
+
+// queryParam параметр строки запроса. Type - тип схемы OpenAPI: string, integer, number, array (строки через запятую).
+type queryParam struct {
+	Name     string
+	Type     string
+	Required bool
+}
+
+var paginationQuery = []queryParam{{Name: "page", Type: "integer"}, {Name: "pageSize", Type: "integer"}}
+
+// rawBody тело запроса или ответа, которое не описывается JSON-схемой, например файл.
+type rawBody struct {
+	ContentType string
+}
+
+// routeDoc описание маршрута для спецификации. Request и Response - значения типов тела,
+// по ним через reflection строятся схемы. Пустой Response - ответ 200 без тела.
+type routeDoc struct {
+	Tag      string
+	Summary  string
+	Query    []queryParam
+	Request  any
+	Response any
+	// LongRunning отключает крайний срок запроса для маршрутов, которые отдают ответ потоком.
+	LongRunning bool
+	// Unlimited не ограничивает число одновременных запросов, например у проверок работоспособности.
+	Unlimited bool
+}
+
+type route struct {
+	method string
+	path   string
+	access routeAccess
+	doc    routeDoc
+}
+
+// routeRegistry регистрирует обработчики в ServeMux и запоминает их описание,
+// поэтому спецификация строится из тех же вызовов, что и маршрутизация.
+type routeRegistry struct {
+	mux     *http.ServeMux
+	auth    func(next http.HandlerFunc) http.HandlerFunc
+	teacher func(next http.HandlerFunc) http.HandlerFunc
+	courier func(next http.HandlerFunc) http.HandlerFunc
+	issuer  func(next http.HandlerFunc) http.HandlerFunc
+	// Оборачивает маршрут целиком, включая авторизацию. Применяется внутри ServeMux,
+	// чтобы access-лог видел Pattern исходного запроса.
+	timeout func(next http.HandlerFunc) http.HandlerFunc
+	// Ограничивает одновременные запросы класса маршрута. Снаружи крайнего срока: ожидание места
+	// в очереди не тратит время обработки.
+	limit  func(class RouteClass, next http.HandlerFunc) http.HandlerFunc
+	routes []route
+}
+
+func newRouteRegistry(
+	mux *http.ServeMux,
+	authMiddleware func(next http.HandlerFunc) http.HandlerFunc,
+	teacherMiddleware func(next http.HandlerFunc) http.HandlerFunc,
+	courierMiddleware func(next http.HandlerFunc) http.HandlerFunc,
+	tokenIssuerMiddleware func(next http.HandlerFunc) http.HandlerFunc,
+	timeoutMiddleware func(next http.HandlerFunc) http.HandlerFunc,
+	loadShedding func(class RouteClass, next http.HandlerFunc) http.HandlerFunc,
+) *routeRegistry {
+	return &routeRegistry{
+		mux:     mux,
+		auth:    authMiddleware,
+		teacher: teacherMiddleware,
+		courier: courierMiddleware,
+		issuer:  tokenIssuerMiddleware,
+		timeout: timeoutMiddleware,
+		limit:   loadShedding,
+	}
+}
+
+func (rr *routeRegistry) public(pattern string, handler http.HandlerFunc, doc routeDoc) {
+	rr.add(pattern, accessPublic, handler, doc)
+}
+
+func (rr *routeRegistry) user(pattern string, handler http.HandlerFunc, doc routeDoc) {
+	rr.add(pattern, accessUser, rr.auth(handler), doc)
+}
+
+func (rr *routeRegistry) teacherOnly(pattern string, handler http.HandlerFunc, doc routeDoc) {
+	rr.add(pattern, accessTeacher, rr.auth(rr.teacher(handler)), doc)
+}
+
+func (rr *routeRegistry) courierOnly(pattern string, handler http.HandlerFunc, doc routeDoc) {
+	rr.add(pattern, accessCourier, rr.auth(rr.courier(handler)), doc)
+}
+
+// tokenIssuer маршрут выдачи токенов, авторизация целиком на tokenIssuerMiddleware
+func (rr *routeRegistry) tokenIssuer(pattern string, handler http.HandlerFunc, doc routeDoc) {
+	rr.add(pattern, accessTokenIssuer, rr.issuer(handler), doc)
+}
+
+func (rr *routeRegistry) add(pattern string, access routeAccess, handler http.HandlerFunc, doc routeDoc) {
+	method, path, _ := strings.Cut(pattern, " ")
+
+	if !doc.LongRunning {
+		handler = rr.timeout(handler)
+	}
+
+	if !doc.Unlimited {
+		handler = rr.limit(routeClass(method, access, doc), handler)
+	}
+
+	rr.mux.HandleFunc(pattern, handler)
+
+	rr.routes = append(rr.routes, route{method: method, path: path, access: access, doc: doc})
+}
+
+var pathParamPattern = regexp.MustCompile(`\{([^}.]+)(\.\.\.)?\}`)
+
+// buildOpenAPI собирает документ OpenAPI 3 по зарегистрированным маршрутам.
+func buildOpenAPI(routes []route) ([]byte, error) {
+	schemas := newSchemaBuilder()
+	paths := make(map[string]map[string]any)
+
+	for _, rt := range routes {
+		path := pathParamPattern.ReplaceAllString(rt.path, "{$1}")
+		if paths[path] == nil {
+			paths[path] = make(map[string]any)
+		}
+
+		paths[path][strings.ToLower(rt.method)] = operation(rt, schemas)
+	}
+
+	spec := map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "Eats backend",
+			"version": "1.0.0",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": schemas.components,
+			"securitySchemes": map[string]any{
+				"bearerAuth": map[string]any{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+				"basicAuth":  map[string]any{"type": "http", "scheme": "basic"},
+			},
+		},
+	}
+
+	return json.MarshalIndent(spec, "", "  ")
+}
+
+func operation(rt route, schemas *schemaBuilder) map[string]any {
+	op := map[string]any{
+		"summary": rt.doc.Summary,
+	}
+
+	if rt.doc.Tag != "" {
+		op["tags"] = []string{rt.doc.Tag}
+	}
+
+	parameters := make([]any, 0)
+
+	for _, match := range pathParamPattern.FindAllStringSubmatch(rt.path, -1) {
+		parameters = append(parameters, map[string]any{
+			"in": "path", "name": match[1], "required": true, "schema": map[string]any{"type": "string"},
+		})
+	}
+
+	for _, param := range rt.doc.Query {
+		schema := map[string]any{"type": param.Type}
+		if param.Type == "array" {
+			schema["items"] = map[string]any{"type": "string"}
+		}
+
+		parameters = append(parameters, map[string]any{
+			"in": "query", "name": param.Name, "required": param.Required, "schema": schema,
+			"style": "form", "explode": false,
+		})
+	}
+
+	if len(parameters) > 0 {
+		op["parameters"] = parameters
+	}
+
+	if rt.doc.Request != nil {
+		op["requestBody"] = map[string]any{
+			"required": true,
+			"content":  content(rt.doc.Request, schemas),
+		}
+	}
+
+	success := map[string]any{"description": "OK"}
+	if rt.doc.Response != nil {
+		success["content"] = content(rt.doc.Response, schemas)
+	}
+
+	errorResponse := func(description string) map[string]any {
+		return map[string]any{
+			"description": description,
+			"content": map[string]any{
+				"application/json": map[string]any{"schema": map[string]any{"$ref": "#/components/schemas/ErrorResponse"}},
+			},
+		}
+	}
+
+	responses := map[string]any{
+		"200":     success,
+		"default": errorResponse("Ошибка"),
+	}
+
+	if rt.access != accessPublic {
+		op["security"] = []any{map[string]any{"bearerAuth": []string{}}}
+		responses["401"] = errorResponse("Токен недействителен или не указан")
+	}
+
+	if rt.access == accessTokenIssuer {
+		op["security"] = []any{map[string]any{"bearerAuth": []string{}}, map[string]any{"basicAuth": []string{}}}
+	}
+
+	if rt.access == accessTeacher || rt.access == accessTokenIssuer {
+		responses["403"] = errorResponse("Доступно только преподавателям")
+	}
+
+	if rt.access == accessCourier {
+		responses["403"] = errorResponse("Доступно только курьерам")
+	}
+
+	op["responses"] = responses
+
+	return op
+}
+
+func content(body any, schemas *schemaBuilder) map[string]any {
+	if raw, ok := body.(rawBody); ok {
+		return map[string]any{
+			raw.ContentType: map[string]any{"schema": map[string]any{"type": "string", "format": "binary"}},
+		}
+	}
+
+	return map[string]any{
+		"application/json": map[string]any{"schema": schemas.schema(reflect.TypeOf(body))},
+	}
+}
+
+var (
+	timeType       = reflect.TypeFor[time.Time]()
+	rawMessageType = reflect.TypeFor[json.RawMessage]()
+	moneyType      = reflect.TypeFor[models.Money]()
+)
+
+// schemaBuilder строит JSON-схемы по типам Go по тем же правилам, что и encoding/json.
+// Именованные структуры выносятся в components/schemas.
+type schemaBuilder struct {
+	components map[string]any
+}
+
+func newSchemaBuilder() *schemaBuilder {
+	return &schemaBuilder{
+		components: map[string]any{
+			"ErrorResponse": map[string]any{
+				"type":       "object",
+				"required":   []string{"error"},
+				"properties": map[string]any{"error": map[string]any{"type": "string"}},
+			},
+		},
+	}
+}
+
+func (b *schemaBuilder) schema(t reflect.Type) map[string]any {
+	switch t {
+	case timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case rawMessageType:
+		return map[string]any{}
+	case moneyType:
+		// Сумма в рублях с копейками, а не целое число копеек
+		return map[string]any{"type": "number", "multipleOf": 0.01}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return b.schema(t.Elem())
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+
+		return map[string]any{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.structSchema(t)
+		}
+
+		name := componentName(t)
+		if _, ok := b.components[name]; !ok {
+			// Заглушка до построения схемы, чтобы рекурсивные типы не зациклились
+			b.components[name] = map[string]any{}
+			b.components[name] = b.structSchema(t)
+		}
+
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	default:
+		return map[string]any{}
+	}
+}
+
+func (b *schemaBuilder) structSchema(t reflect.Type) map[string]any {
+	properties := make(map[string]any)
+	required := make([]string, 0)
+
+	b.addFields(t, properties, &required)
+
+	schema := map[string]any{
+		"type":       "object",
+		"properties": properties,
+	}
+
+	if len(required) > 0 {
+		slices.Sort(required)
+		schema["required"] = required
+	}
+
+	return schema
+}
+
+func (b *schemaBuilder) addFields(t reflect.Type, properties map[string]any, required *[]string) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, options, _ := strings.Cut(tag, ",")
+
+		// Встроенные структуры без имени в теге encoding/json раскрывает в поля родителя
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+
+			if embedded.Kind() == reflect.Struct {
+				b.addFields(embedded, properties, required)
+
+				continue
+			}
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = b.schema(field.Type)
+
+		if !slices.Contains(strings.Split(options, ","), "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// componentName имя схемы для типа. У обобщенных типов в имени есть параметры вида Page[pkg.T],
+// они заменяются на допустимые в OpenAPI символы.
+func componentName(t reflect.Type) string {
+	name := t.Name()
+	if !strings.Contains(name, "[") {
+		return name
+	}
+
+	base, params, _ := strings.Cut(strings.TrimSuffix(name, "]"), "[")
+
+	parts := strings.Split(params, ",")
+	for i, part := range parts {
+		parts[i] = part[strings.LastIndex(part, ".")+1:]
+	}
+
+	return fmt.Sprintf("%s_%s", base, strings.Join(parts, "_"))
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+
+	"eats-backend/internal/models"
+)
+
+// sandboxPaymentPage страница оплаты песочницы. Кнопки отправляют результат оплаты на тот же адрес.
+var sandboxPaymentPage = template.Must(template.New("sandbox").Parse(`<!DOCTYPE html>
+<html lang="ru">
+<head>
+  <meta charset="utf-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <title>Оплата (песочница)</title>
+</head>
+<body>
+  <h1>Тестовая оплата</h1>
+  <p>Платеж {{.}}. Деньги не списываются.</p>
+  <form method="post" action="?status=succeeded"><button type="submit">Оплатить</button></form>
+  <form method="post" action="?status=failed"><button type="submit">Отказаться</button></form>
+</body>
+</html>
+`))
+
+func (r *Router) getSandboxPaymentPage(writer http.ResponseWriter, request *http.Request) {
+	id := request.PathValue("id")
+	if id == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
+		return
+	}
+
+	writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+	writer.WriteHeader(http.StatusOK)
+
+	if err := sandboxPaymentPage.Execute(writer, id); err != nil {
+		r.logger.Errorf("can't render sandbox payment page: %v", err)
+	}
+}
@@ -0,0 +1,211 @@
+package api
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"eats-backend/internal/models"
+)
+
+const redacted = "***"
+
+// Заголовки, значения которых не попадают в запись
+var recordingSecretHeaders = map[string]bool{
+	"Authorization":       true,
+	"Cookie":              true,
+	"X-Payment-Signature": true,
+	"X-Wallet-Pin":        true,
+}
+
+// Поля JSON и параметры запроса с секретами: пароли, токены, данные карт
+var (
+	recordingSecretField = regexp.MustCompile(
+		`(?i)"(password|[a-z]*pin|secret|cardNumber|cvv|cvc|[a-z]*token)"(\s*:\s*)("(?:[^"\\]|\\.)*"|[^,}\]\s]+)`)
+	recordingSecretParam = regexp.MustCompile(`(?i)^(password|[a-z]*pin|secret|[a-z]*token)$`)
+)
+
+type Recorder interface {
+	Record(userID, nickname string, exchange models.RecordedExchange)
+}
+
+// RecordingMiddleware записывает запросы студентов и ответы на них для проверки преподавателем.
+// Ставится после JWTAuth, запросы преподавателей, курьеров и административные маршруты не пишутся.
+type RecordingMiddleware struct {
+	recorder    Recorder
+	maxBodySize int
+}
+
+func NewRecordingMiddleware(recorder Recorder, maxBodySize int) *RecordingMiddleware {
+	return &RecordingMiddleware{
+		recorder:    recorder,
+		maxBodySize: maxBodySize,
+	}
+}
+
+func (m *RecordingMiddleware) Middleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(response http.ResponseWriter, request *http.Request) {
+		claims := models.ClaimsFromContext(request.Context())
+		if claims == nil || claims.GetRole() != models.TokenRoleStudent || strings.Contains(request.Pattern, " /admin/") {
+			next.ServeHTTP(response, request)
+
+			return
+		}
+
+		start := time.Now()
+
+		requestBody := &bodyCapture{limit: m.maxBodySize}
+		if request.Body != nil {
+			request.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.TeeReader(request.Body, requestBody), request.Body}
+		}
+
+		capture := &recordingWriter{ResponseWriter: response, body: &bodyCapture{limit: m.maxBodySize}}
+
+		next.ServeHTTP(capture, request)
+
+		status := capture.statusCode
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		m.recorder.Record(claims.ID, claims.Nickname, models.RecordedExchange{
+			RequestID:      RequestIDFromContext(request.Context()),
+			At:             start,
+			Method:         request.Method,
+			Route:          request.Pattern,
+			Path:           request.URL.Path,
+			Query:          sanitizeQuery(request.URL.RawQuery),
+			RequestHeaders: sanitizeHeaders(request.Header),
+			RequestBody:    requestBody.sanitized(request.Header.Get("Content-Type")),
+			Status:         status,
+			ResponseBody:   capture.body.sanitized(response.Header().Get("Content-Type")),
+			DurationMs:     time.Since(start).Milliseconds(),
+		})
+	}
+}
+
+// bodyCapture запоминает первые limit байт тела и считает полный размер
+type bodyCapture struct {
+	buf   bytes.Buffer
+	limit int
+	size  int
+}
+
+func (c *bodyCapture) Write(p []byte) (int, error) {
+	c.size += len(p)
+
+	if rest := c.limit - c.buf.Len(); rest > 0 {
+		c.buf.Write(p[:min(rest, len(p))])
+	}
+
+	return len(p), nil
+}
+
+// sanitized возвращает тело для записи: текст без секретов, у бинарных данных только тип и размер
+func (c *bodyCapture) sanitized(contentType string) string {
+	if c.size == 0 {
+		return ""
+	}
+
+	// Клиенты часто шлют JSON без Content-Type или с типом формы, поэтому текстом считается все,
+	// кроме файлов и данных, которые не являются UTF-8
+	text := c.buf.Bytes()
+	// Обрезка могла разрезать последний символ
+	for cut := 0; c.size > len(text) && cut < utf8.UTFMax && !utf8.Valid(text); cut++ {
+		text = text[:len(text)-1]
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if isBinaryMediaType(mediaType) || !utf8.Valid(text) {
+		return fmt.Sprintf("<%s, %d bytes>", cmp.Or(mediaType, "binary"), c.size)
+	}
+
+	body := recordingSecretField.ReplaceAllString(string(text), `"$1"$2"`+redacted+`"`)
+	if c.size > c.buf.Len() {
+		body += fmt.Sprintf("... <truncated, %d bytes total>", c.size)
+	}
+
+	return body
+}
+
+// Тела этих типов записываются только типом и размером
+var recordingBinaryTypes = []string{
+	"multipart/", "image/", "audio/", "video/", "application/octet-stream", "application/zip", "application/pdf",
+}
+
+func isBinaryMediaType(mediaType string) bool {
+	for _, prefix := range recordingBinaryTypes {
+		if strings.HasPrefix(mediaType, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func sanitizeHeaders(header http.Header) map[string]string {
+	result := make(map[string]string, len(header))
+	for name, values := range header {
+		if recordingSecretHeaders[name] {
+			result[name] = redacted
+
+			continue
+		}
+
+		result[name] = strings.Join(values, ", ")
+	}
+
+	return result
+}
+
+func sanitizeQuery(rawQuery string) string {
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return rawQuery
+	}
+
+	for name := range query {
+		if recordingSecretParam.MatchString(name) {
+			query.Set(name, redacted)
+		}
+	}
+
+	return query.Encode()
+}
+
+type recordingWriter struct {
+	http.ResponseWriter
+	statusCode int
+	body       *bodyCapture
+}
+
+func (w *recordingWriter) WriteHeader(statusCode int) {
+	if w.statusCode == 0 {
+		w.statusCode = statusCode
+	}
+
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	_, _ = w.body.Write(p[:n])
+
+	return n, err
+}
+
+// Unwrap нужен http.ResponseController, чтобы добраться до Hijack и Flush исходного writer.
+func (w *recordingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -5,8 +5,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"math"
 	"net/http"
+	"net/http/pprof"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/rs/cors"
@@ -23,45 +27,125 @@ var (
 	errJsonDecode                 = fmt.Errorf("%w: json body invalid", models.ErrBadRequest)
 )
 
+// retryAfterSeconds через сколько секунд клиенту стоит повторить запрос, отклоненный из-за перегрузки
+const retryAfterSeconds = "1"
+
 type FileSaver interface {
 	SaveFile(w http.ResponseWriter, r *http.Request) (string, error)
+	PresignUpload(ctx context.Context) (*models.PresignedUpload, error)
+	SaveSignedFile(w http.ResponseWriter, r *http.Request, name, expires, signature string) error
+	ServeUpload(w http.ResponseWriter, r *http.Request, name string) error
+	SignDownload(ctx context.Context, name string) (*models.SignedDownload, error)
+}
+
+// ImageProxy отдает картинки каталога с внешних хостов из локального кеша
+type ImageProxy interface {
+	ServeImage(w http.ResponseWriter, r *http.Request, hash string) error
 }
 
 type UserData interface {
-	GetProfile(ctx context.Context) (*models.UserProfile, error)
+	GetProfile(ctx context.Context) (models.UserProfile, error)
 	UpdateProfile(ctx context.Context, data models.UpdateUserRequest) error
 	DeleteProfile(ctx context.Context) error
+	SetEmail(ctx context.Context, email string) error
+	VerifyEmail(ctx context.Context, code string) error
 }
 
 type AddressService interface {
-	GetAddresses(ctx context.Context) []*models.Address
+	GetAddresses(ctx context.Context, label models.AddressLabel) ([]*models.Address, error)
 	AddAddress(ctx context.Context, address *models.Address) error
 	RemoveAddress(ctx context.Context, addressID string) error
 	UpdateAddress(ctx context.Context, newAddress *models.Address) error
+	PatchAddress(ctx context.Context, addressID string, patch models.AddressPatch) (models.Address, error)
+	SetCurrentAddress(ctx context.Context, addressID string) (models.Address, error)
+	ClearCurrentAddress(ctx context.Context)
 }
 
 type ProductsService interface {
-	GetProductsList(ctx context.Context, page, pageSize int, category string) (models.ProductsList, error)
-	GetProductByID(ctx context.Context, id string) (models.Product, error)
-	GetCategories() []models.Category
+	GetProductsList(ctx context.Context, page, pageSize int, filter models.ProductsFilter) (models.ProductsList, error)
+	ViewProduct(ctx context.Context, id string) (models.Product, error)
+	GetRecentlyViewed(ctx context.Context) []models.ProductPreview
+	GetPopular(ctx context.Context, limit int) ([]models.ProductPreview, error)
+	GetLocalizedCategories(ctx context.Context, includeEmpty bool) []models.Category
+	GetTags() []models.Tag
+	GetFacets(ctx context.Context, category string) (models.ProductFacets, error)
 	AddReview(ctx context.Context, review models.PostReviewRequest, productID string) error
+	GetReviewSummary(ctx context.Context, productID string) (models.ReviewSummary, error)
 	AddFavourite(ctx context.Context, id string) error
 	RemoveFavourite(ctx context.Context, id string) error
+	GetFavourites(ctx context.Context, page, pageSize int) models.ProductsList
+	BatchFavourites(ctx context.Context, req models.FavouritesBatchRequest) (models.FavouritesBatchResponse, error)
+	NotifyWhenAvailable(ctx context.Context, id string) error
+	SetAvailability(ctx context.Context, id string, available bool) (models.Product, error)
+	ListCatalogVersions(ctx context.Context) []models.CatalogVersion
+	RollbackCatalog(ctx context.Context, version int) (models.CatalogVersion, error)
+	GetCatalogChanges(ctx context.Context, since time.Time) models.CatalogChanges
+}
+
+type ComboService interface {
+	ListCombos(ctx context.Context) ([]models.Combo, error)
+	GetCombo(ctx context.Context, id string) (models.Combo, error)
+}
+
+type SearchService interface {
+	Search(ctx context.Context, req models.SearchRequest) (models.SearchResults, error)
+}
+
+type NotificationService interface {
+	GetNotifications(ctx context.Context) []models.Notification
 }
 
 type CartService interface {
-	GetCart(ctx context.Context) (models.CartResponse, error)
+	GetCartForAddress(ctx context.Context, addressID string, tip models.Money) (models.CartResponse, error)
 	AddItem(ctx context.Context, productID string) (int, error)
 	RemoveItem(ctx context.Context, productID string) (int, error)
+	SetQuantity(ctx context.Context, productID string, quantity int) (int, error)
+	AddCombo(ctx context.Context, comboID string) (int, error)
+	RemoveCombo(ctx context.Context, comboID string) (int, error)
+	SetItemComment(ctx context.Context, productID, comment string) (models.CartResponseItem, error)
+	Cleanup(ctx context.Context) (models.CartCleanupResult, error)
+	GetDeliveryInfo() models.DeliveryInfo
+}
+
+type ShoppingListService interface {
+	GetLists(ctx context.Context) []models.ShoppingList
+	GetList(ctx context.Context, id string) (models.ShoppingList, error)
+	CreateList(ctx context.Context, req models.ShoppingListRequest) (models.ShoppingList, error)
+	UpdateList(ctx context.Context, id string, req models.ShoppingListRequest) (models.ShoppingList, error)
+	DeleteList(ctx context.Context, id string) error
+	AddToCart(ctx context.Context, id string) (models.ListToCartResult, error)
 }
 
 type OrderService interface {
-	GetOrders(ctx context.Context) ([]*models.Order, error)
+	GetOrders(ctx context.Context) ([]models.Order, error)
 	MakeNewOrder(ctx context.Context, orderRequest *models.OrderRequest) error
+	DelayOrder(ctx context.Context, orderID string, req models.OrderDelayRequest) (models.Order, error)
+	GetOrderTimeline(ctx context.Context, orderID string) (models.OrderTimeline, error)
+}
+
+type RefundService interface {
+	RefundOrder(ctx context.Context, orderID string, req models.RefundRequest) (models.Order, error)
+}
+
+type SubscriptionService interface {
+	GetSubscriptions(ctx context.Context) []models.Subscription
+	Create(ctx context.Context, req models.SubscriptionRequest) (models.Subscription, error)
+	Pause(ctx context.Context, id string) (models.Subscription, error)
+	Resume(ctx context.Context, id string) (models.Subscription, error)
+	Cancel(ctx context.Context, id string) (models.Subscription, error)
+}
+
+type CourierService interface {
+	GetOrders(ctx context.Context) ([]models.CourierOrder, error)
+	AcceptOrder(ctx context.Context, orderID string) (models.CourierOrder, error)
+	DeclineOrder(ctx context.Context, orderID string) error
+	UpdateStatus(ctx context.Context, orderID string, status models.DeliveryStatus) (models.CourierOrder, error)
+	SetPhoto(ctx context.Context, orderID, photo string) (models.CourierOrder, error)
 }
 
 type TokenService interface {
-	GenerateToken(ctx context.Context, username string, isTeacher bool) (string, error)
+	GenerateToken(ctx context.Context, username string, role models.TokenRole) (string, error)
+	ListTokens(ctx context.Context) ([]models.IssuedToken, error)
 }
 
 type WalletService interface {
@@ -69,108 +153,924 @@ type WalletService interface {
 	GetTransactions(ctx context.Context, page, pageSize int) (*models.TransactionsResponse, error)
 	TopupAccount(ctx context.Context, req models.TopupRequest) (*models.TopupResponse, error)
 	TransferMoney(ctx context.Context, req models.TransferRequest) (*models.TransferResponse, error)
+	GetAnalytics(ctx context.Context, period models.AnalyticsPeriod) (*models.WalletAnalytics, error)
+	OpenAccount(ctx context.Context, req models.OpenAccountRequest) (*models.Account, error)
+	UpdateAccount(ctx context.Context, accountID string, patch models.AccountPatch) (*models.Account, error)
+	GetRate(ctx context.Context, from, to models.Currency) (models.ExchangeRate, error)
+	GetContacts(ctx context.Context) []models.WalletContact
+	SaveContact(ctx context.Context, req models.SaveWalletContactRequest) (models.WalletContact, error)
+	DeleteContact(ctx context.Context, contactID string) error
+}
+
+type WalletPINService interface {
+	GetStatus(ctx context.Context) models.WalletPINStatus
+	SetPIN(ctx context.Context, req models.WalletPINRequest) (models.WalletPINStatus, error)
+	RequestReset(ctx context.Context) (models.WalletPINResetResponse, error)
+	ConfirmReset(ctx context.Context, req models.WalletPINResetConfirmRequest) (models.WalletPINStatus, error)
+}
+
+type ScheduledTopupService interface {
+	GetTopups(ctx context.Context) []models.ScheduledTopup
+	Create(ctx context.Context, req models.ScheduledTopupRequest) (models.ScheduledTopup, error)
+	Cancel(ctx context.Context, id string) (models.ScheduledTopup, error)
+}
+
+type PaymentService interface {
+	CreateTopup(ctx context.Context, req models.TopupRequest) (models.Payment, error)
+	GetPayment(ctx context.Context, id string) (models.Payment, error)
+	HandleCallback(ctx context.Context, body []byte, signature string) (models.Payment, error)
+	CompleteSandboxPayment(ctx context.Context, id string, status models.PaymentStatus) (models.Payment, error)
+}
+
+type FraudReview interface {
+	GetBlocked(ctx context.Context, status models.BlockedOperationStatus) []models.BlockedOperation
+	Approve(ctx context.Context, id string) (models.BlockedOperation, error)
+}
+
+type TransactionIcons interface {
+	GetIcons(ctx context.Context) map[models.IconKind]string
+	SetIcon(ctx context.Context, kind models.IconKind, icon string) (map[models.IconKind]string, error)
+}
+
+type ExportService interface {
+	Validate(req *models.ExportRequest) error
+	WriteArchive(ctx context.Context, w io.Writer, req models.ExportRequest) error
+	ValidateOrders(req *models.OrderExportRequest) error
+	WriteOrders(ctx context.Context, w io.Writer, req models.OrderExportRequest) error
+}
+
+type AccountExportService interface {
+	ExportAccount(ctx context.Context) (models.AccountExport, error)
+	WriteArchive(ctx context.Context, w io.Writer, export models.AccountExport) error
+}
+
+type ResetService interface {
+	ResetUser(ctx context.Context, userID string) error
+}
+
+type OrderExtrasCatalog interface {
+	ListExtras() []models.OrderExtra
+}
+
+type CheckoutService interface {
+	Preview(ctx context.Context, req models.CheckoutPreviewRequest) (*models.CheckoutPreview, error)
+}
+
+type ChaosService interface {
+	GetRules(ctx context.Context) []models.ChaosRule
+	AddRule(ctx context.Context, rule models.ChaosRule) (models.ChaosRule, error)
+	RemoveRule(ctx context.Context, id string) error
+	ClearRules(ctx context.Context)
+}
+
+type DiagnosticsService interface {
+	GetRuntime(ctx context.Context) models.RuntimeDiagnostics
+}
+
+type UsageService interface {
+	GetUserUsage(ctx context.Context, userID string) (models.UserUsage, error)
+}
+
+type SlowRequestLog interface {
+	GetReport(ctx context.Context) models.SlowRequestsReport
+	Clear(ctx context.Context)
+}
+
+type BackupManager interface {
+	GetStatus(ctx context.Context) []models.BackupStatus
+	BackupNow(ctx context.Context) (models.BackupSnapshot, error)
+	ListSnapshots(ctx context.Context) ([]models.BackupSnapshot, error)
+	Restore(ctx context.Context, snapshot string) (models.RestoreResult, error)
+	Health(ctx context.Context) models.BackupHealth
+}
+
+type WebhookService interface {
+	CreateWebhook(ctx context.Context, request models.WebhookRequest) (models.Webhook, error)
+	ListWebhooks(ctx context.Context) []models.Webhook
+	DeleteWebhook(ctx context.Context, id string) error
+	GetDeliveries(ctx context.Context, webhookID string) ([]models.WebhookDelivery, error)
+}
+
+type RecordingService interface {
+	ListSessions(ctx context.Context) []models.RecordingSummary
+	GetSession(ctx context.Context, userID string) (models.RecordingSession, error)
+	ClearSession(ctx context.Context, userID string) error
+}
+
+type SuspensionService interface {
+	BlockUser(ctx context.Context, userID string, req models.BlockUserRequest) (models.UserSuspension, error)
+	UnblockUser(ctx context.Context, userID string) error
+	ListSuspensions(ctx context.Context) []models.UserSuspension
+}
+
+type ExperimentService interface {
+	ListExperiments(ctx context.Context) []models.Experiment
+	PutExperiment(ctx context.Context, key string, req models.ExperimentRequest) (models.Experiment, error)
+	DeleteExperiment(ctx context.Context, key string) error
+	GetAssignments(ctx context.Context) []models.ExperimentAssignment
+	Variant(ctx context.Context, key string) (string, bool)
+}
+
+type ZoneService interface {
+	ListZones(ctx context.Context) []models.DeliveryZone
+	SetAssortment(ctx context.Context, zoneID string, req models.ZoneAssortmentRequest) (models.DeliveryZone, error)
+	UserZoneFilter(ctx context.Context) *models.ZoneFilter
+}
+
+type CollectionService interface {
+	ListCollections(ctx context.Context) []models.Collection
+	SetCollection(ctx context.Context, id string, req models.CollectionRequest) (models.Collection, error)
+	DeleteCollection(ctx context.Context, id string) error
+	CollectionFilter(ctx context.Context, id string) (*models.CollectionFilter, error)
+	GetRails(ctx context.Context, limit int, zone *models.ZoneFilter) ([]models.CollectionRail, error)
+}
+
+// ClockService время сервера, которое в демо-режиме можно перемотать вперед
+type ClockService interface {
+	GetClock(ctx context.Context) models.ClockState
+	AdvanceClock(ctx context.Context, req models.AdvanceClockRequest) (models.ClockState, error)
+}
+
+type SessionService interface {
+	ListSessions(ctx context.Context) []models.Session
+	RevokeSession(ctx context.Context, tokenID string) error
+}
+
+type StatsService interface {
+	GetStats(ctx context.Context, days int) (models.Stats, error)
+}
+
+type LogLevels interface {
+	GetLevels() models.LogLevels
+	SetLevels(req models.LogLevelRequest) (models.LogLevels, error)
 }
 
 type Router struct {
 	*http.Server
-	router *http.ServeMux
+	router     *http.ServeMux
+	pagination config.PaginationConfig
+	// Страница документации для GET /
+	docsPage string
 
 	productsService ProductsService
+	combos          ComboService
+	search          SearchService
+	notifications   NotificationService
 	userData        UserData
 	addressService  AddressService
 	cartService     CartService
+	shoppingLists   ShoppingListService
 	orderService    OrderService
+	refunds         RefundService
+	couriers        CourierService
+	subscriptions   SubscriptionService
+	scheduledTopups ScheduledTopupService
+	checkoutService CheckoutService
+	orderExtras     OrderExtrasCatalog
 	tokenService    TokenService
 	walletService   WalletService
+	walletPINs      WalletPINService
+	payments        PaymentService
+	fraudReview     FraudReview
+	icons           TransactionIcons
+	exportService   ExportService
+	accountExport   AccountExportService
+	resetService    ResetService
+	chaosService    ChaosService
+	logLevels       LogLevels
+	diagnostics     DiagnosticsService
+	slowRequests    SlowRequestLog
+	usage           UsageService
+	stats           StatsService
+	backups         BackupManager
+	webhooks        WebhookService
+	recordings      RecordingService
+	suspensions     SuspensionService
+	experiments     ExperimentService
+	zones           ZoneService
+	collections     CollectionService
+	clock           ClockService
+	sessions        SessionService
 	fileSaver       FileSaver
+	imageProxy      ImageProxy
+
+	// Маршруты с описанием для /openapi.json
+	routes []route
 
 	logger *zap.SugaredLogger
 }
 
 func NewRouter(
 	cfg config.ServerOpts,
+	pagination config.PaginationConfig,
 	productsService ProductsService,
+	combos ComboService,
+	search SearchService,
+	notifications NotificationService,
 	userData UserData,
 	addressService AddressService,
 	cartService CartService,
+	shoppingLists ShoppingListService,
 	orderService OrderService,
+	refunds RefundService,
+	couriers CourierService,
+	subscriptions SubscriptionService,
+	scheduledTopups ScheduledTopupService,
+	checkoutService CheckoutService,
+	orderExtras OrderExtrasCatalog,
 	tokenService TokenService,
 	walletService WalletService,
+	walletPINs WalletPINService,
+	payments PaymentService,
+	fraudReview FraudReview,
+	icons TransactionIcons,
+	exportService ExportService,
+	accountExport AccountExportService,
+	resetService ResetService,
+	chaosService ChaosService,
+	logLevels LogLevels,
+	diagnostics DiagnosticsService,
+	slowRequests SlowRequestLog,
+	usage UsageService,
+	stats StatsService,
+	backups BackupManager,
+	webhooks WebhookService,
+	recordings RecordingService,
+	suspensions SuspensionService,
+	experiments ExperimentService,
+	zones ZoneService,
+	collections CollectionService,
+	clock ClockService,
+	sessions SessionService,
 	fileSaver FileSaver,
+	imageProxy ImageProxy,
 	authMiddleware func(next http.HandlerFunc) http.HandlerFunc,
-	loggingMiddleware func(next http.HandlerFunc) http.HandlerFunc,
+	teacherMiddleware func(next http.HandlerFunc) http.HandlerFunc,
+	courierMiddleware func(next http.HandlerFunc) http.HandlerFunc,
+	tokenIssuerMiddleware func(next http.HandlerFunc) http.HandlerFunc,
+	loggingMiddleware func(next http.Handler) http.Handler,
+	compressionMiddleware func(next http.Handler) http.Handler,
+	followerMiddleware func(next http.Handler) http.Handler,
+	timeoutMiddleware func(next http.HandlerFunc) http.HandlerFunc,
+	loadShedding func(class RouteClass, next http.HandlerFunc) http.HandlerFunc,
 	logger *zap.SugaredLogger,
 ) *Router {
 	innerRouter := http.NewServeMux()
 
 	appRouter := &Router{
 		Server: &http.Server{
-			Handler:      cors.AllowAll().Handler(innerRouter),
+			Handler:      loggingMiddleware(compressionMiddleware(followerMiddleware(cors.AllowAll().Handler(innerRouter)))),
 			ReadTimeout:  time.Duration(cfg.ReadTimeout) * time.Second,
 			WriteTimeout: time.Duration(cfg.WriteTimeout) * time.Second,
 			IdleTimeout:  time.Duration(cfg.IdleTimeout) * time.Second,
 		},
 		router:          innerRouter,
+		pagination:      pagination,
+		docsPage:        cfg.DocsPage,
 		productsService: productsService,
+		combos:          combos,
+		search:          search,
+		notifications:   notifications,
 		userData:        userData,
 		addressService:  addressService,
 		cartService:     cartService,
+		shoppingLists:   shoppingLists,
 		orderService:    orderService,
+		refunds:         refunds,
+		couriers:        couriers,
+		subscriptions:   subscriptions,
+		scheduledTopups: scheduledTopups,
+		checkoutService: checkoutService,
+		orderExtras:     orderExtras,
 		tokenService:    tokenService,
 		walletService:   walletService,
+		walletPINs:      walletPINs,
+		payments:        payments,
+		fraudReview:     fraudReview,
+		icons:           icons,
+		exportService:   exportService,
+		accountExport:   accountExport,
+		resetService:    resetService,
+		chaosService:    chaosService,
+		logLevels:       logLevels,
+		diagnostics:     diagnostics,
+		slowRequests:    slowRequests,
+		usage:           usage,
+		stats:           stats,
+		backups:         backups,
+		webhooks:        webhooks,
+		recordings:      recordings,
+		suspensions:     suspensions,
+		experiments:     experiments,
+		zones:           zones,
+		collections:     collections,
+		clock:           clock,
+		sessions:        sessions,
 		logger:          logger,
 		fileSaver:       fileSaver,
+		imageProxy:      imageProxy,
 	}
 
-	innerRouter.HandleFunc("GET /users/me", authMiddleware(loggingMiddleware(appRouter.getUser)))
-	innerRouter.HandleFunc("PUT /users/me", authMiddleware(loggingMiddleware(appRouter.updateProfile)))
-	innerRouter.HandleFunc("DELETE /users/me", authMiddleware(loggingMiddleware(appRouter.deleteUser)))
+	routes := newRouteRegistry(
+		innerRouter,
+		authMiddleware,
+		teacherMiddleware,
+		courierMiddleware,
+		tokenIssuerMiddleware,
+		timeoutMiddleware,
+		loadShedding,
+	)
+	appRouter.registerRoutes(routes)
+	appRouter.routes = routes.routes
+
+	return appRouter
+}
+
+// registerRoutes регистрирует маршруты вместе с их описанием для /openapi.json.
+func (r *Router) registerRoutes(routes *routeRegistry) {
+	routes.user("GET /users/me", r.getUser, routeDoc{
+		Tag: "О пользователе", Summary: "Профиль пользователя", Response: models.UserProfile{},
+	})
+	routes.user("PUT /users/me", r.updateProfile, routeDoc{
+		Tag: "О пользователе", Summary: "Изменить профиль", Request: models.UpdateUserRequest{},
+	})
+	routes.user("DELETE /users/me", r.deleteUser, routeDoc{Tag: "О пользователе", Summary: "Удалить профиль"})
+	routes.user("GET /users/me/export", r.exportAccount, routeDoc{
+		Tag: "О пользователе", Summary: "Выгрузить все данные аккаунта", Response: models.AccountExport{},
+		Query:       []queryParam{{Name: "format", Type: "string"}},
+		LongRunning: true,
+	})
+	routes.user("POST /users/me/email", r.setEmail, routeDoc{
+		Tag: "О пользователе", Summary: "Указать email и отправить код подтверждения", Request: models.SetEmailRequest{},
+	})
+	routes.user("POST /users/me/email/verify", r.verifyEmail, routeDoc{
+		Tag: "О пользователе", Summary: "Подтвердить email", Request: models.VerifyEmailRequest{},
+	})
+
+	routes.user("GET /users/me/sessions", r.getSessions, routeDoc{
+		Tag: "О пользователе", Summary: "Устройства, с которых заходили по токенам этого ника", Response: []models.Session{},
+	})
+	routes.user("DELETE /users/me/sessions/{jti}", r.revokeSession, routeDoc{
+		Tag: "О пользователе", Summary: "Завершить сессию и отозвать ее токен",
+	})
+	routes.user("GET /experiments", r.getExperimentAssignments, routeDoc{
+		Tag: "О пользователе", Summary: "Варианты A/B экспериментов", Response: []models.ExperimentAssignment{},
+	})
+
+	routes.user("POST /logout", r.logout, routeDoc{Tag: "О пользователе", Summary: "Отозвать текущий токен"})
+
+	routes.user("GET /products", r.getProductsList, routeDoc{
+		Tag: "Товары", Summary: "Список товаров", Response: models.ProductsList{},
+		Query: append([]queryParam{
+			{Name: "category", Type: "string"},
+			{Name: "tags", Type: "array"},
+			{Name: "excludeAllergens", Type: "array"},
+			{Name: "priceMin", Type: "number"},
+			{Name: "priceMax", Type: "number"},
+			{Name: "weightMin", Type: "integer"},
+			{Name: "weightMax", Type: "integer"},
+			{Name: "hasDiscount", Type: "boolean"},
+			{Name: "collection", Type: "string"},
+			{Name: "sort", Type: "string"},
+		}, paginationQuery...),
+	})
+	routes.user("GET /products/facets", r.getProductFacets, routeDoc{
+		Tag: "Товары", Summary: "Значения фильтров каталога", Response: models.ProductFacets{},
+		Query: []queryParam{{Name: "category", Type: "string"}},
+	})
+	routes.user("GET /products/recent", r.getRecentlyViewed, routeDoc{
+		Tag: "Товары", Summary: "Недавно просмотренные товары", Response: []models.ProductPreview{},
+	})
+	routes.user("GET /products/popular", r.getPopular, routeDoc{
+		Tag: "Товары", Summary: "Популярные товары", Response: []models.ProductPreview{},
+		Query: []queryParam{{Name: "limit", Type: "integer"}},
+	})
+	routes.user("GET /collections", r.getCollectionRails, routeDoc{
+		Tag: "Товары", Summary: "Подборки товаров для главного экрана", Response: []models.CollectionRail{},
+		Query: []queryParam{{Name: "limit", Type: "integer"}},
+	})
+	routes.user("GET /products/changes", r.getCatalogChanges, routeDoc{
+		Tag: "Товары", Summary: "Изменения каталога", Response: models.CatalogChanges{},
+		Query: []queryParam{{Name: "since", Type: "string"}},
+	})
+	routes.user("GET /search", r.searchAll, routeDoc{
+		Tag: "Товары", Summary: "Поиск по товарам, категориям и заказам", Response: models.SearchResults{},
+		Query: []queryParam{
+			{Name: "q", Type: "string", Required: true}, {Name: "type", Type: "array"}, {Name: "limit", Type: "integer"},
+		},
+	})
+	routes.user("GET /products/{id}", r.getProductByID, routeDoc{
+		Tag: "Товары", Summary: "Товар", Response: models.Product{},
+	})
+
+	routes.user("GET /combos", r.getCombos, routeDoc{
+		Tag: "Товары", Summary: "Наборы товаров", Response: []models.Combo{},
+	})
+	routes.user("GET /combos/{id}", r.getComboByID, routeDoc{
+		Tag: "Товары", Summary: "Набор товаров", Response: models.Combo{},
+	})
+
+	routes.user("GET /favourites", r.getFavourites, routeDoc{
+		Tag: "Товары", Summary: "Избранные товары", Query: paginationQuery, Response: models.ProductsList{},
+	})
+	routes.user("POST /favourites/batch", r.batchFavourites, routeDoc{
+		Tag: "Товары", Summary: "Добавить и убрать несколько товаров из избранного",
+		Request: models.FavouritesBatchRequest{}, Response: models.FavouritesBatchResponse{},
+	})
+	routes.user("POST /products/{id}/favourite", r.addFavourite, routeDoc{Tag: "Товары", Summary: "Добавить в избранное"})
+	routes.user("DELETE /products/{id}/favourite", r.deleteFavourite, routeDoc{Tag: "Товары", Summary: "Убрать из избранного"})
 
-	innerRouter.HandleFunc("POST /logout", authMiddleware(loggingMiddleware(appRouter.logout)))
+	routes.user("POST /products/{id}/notify", r.notifyWhenAvailable, routeDoc{
+		Tag: "Товары", Summary: "Сообщить, когда товар появится в наличии",
+	})
+	routes.user("GET /notifications", r.getNotifications, routeDoc{
+		Tag: "О пользователе", Summary: "Уведомления", Response: []models.Notification{},
+	})
 
-	innerRouter.HandleFunc("GET /products", authMiddleware(loggingMiddleware(appRouter.getProductsList)))
-	innerRouter.HandleFunc("GET /products/{id}", authMiddleware(loggingMiddleware(appRouter.getProductByID)))
+	routes.user("POST /products/{id}/reviews", r.addReview, routeDoc{
+		Tag: "Товары", Summary: "Оставить отзыв", Request: models.PostReviewRequest{},
+	})
+	routes.user("GET /products/{id}/reviews/summary", r.getReviewSummary, routeDoc{
+		Tag: "Товары", Summary: "Сводка отзывов", Response: models.ReviewSummary{},
+	})
 
-	innerRouter.HandleFunc("POST /products/{id}/favourite", authMiddleware(loggingMiddleware(appRouter.addFavourite)))
-	innerRouter.HandleFunc("DELETE /products/{id}/favourite", authMiddleware(loggingMiddleware(appRouter.deleteFavourite)))
+	routes.user("GET /categories", r.getCategories, routeDoc{
+		Tag: "Товары", Summary: "Категории", Response: []models.Category{},
+		Query: []queryParam{{Name: "includeEmpty", Type: "boolean"}},
+	})
+	routes.user("GET /tags", r.getTags, routeDoc{Tag: "Товары", Summary: "Теги", Response: []models.Tag{}})
 
-	innerRouter.HandleFunc("POST /products/{id}/reviews", authMiddleware(loggingMiddleware(appRouter.addReview)))
+	routes.user("GET /cart", r.getCart, routeDoc{
+		Tag: "Корзина", Summary: "Корзина", Response: models.CartResponse{},
+		Query: []queryParam{{Name: "addressId", Type: "string"}, {Name: "tip", Type: "number"}},
+	})
+	routes.user("POST /cart/items", r.addToCart, routeDoc{
+		Tag: "Корзина", Summary: "Добавить товар", Response: CartQuantityResponse{},
+		Query: []queryParam{{Name: "id", Type: "string", Required: true}},
+	})
+	routes.user("DELETE /cart/items/{id}", r.removeFromCart, routeDoc{
+		Tag: "Корзина", Summary: "Уменьшить количество товара", Response: CartQuantityResponse{},
+	})
+	routes.user("PUT /cart/items/{id}", r.setCartItemQuantity, routeDoc{
+		Tag: "Корзина", Summary: "Задать количество товара", Request: models.CartQuantityRequest{},
+		Response: CartQuantityResponse{},
+	})
+	routes.user("POST /cart/combos/{id}", r.addComboToCart, routeDoc{
+		Tag: "Корзина", Summary: "Добавить набор", Response: CartQuantityResponse{},
+	})
+	routes.user("DELETE /cart/combos/{id}", r.removeComboFromCart, routeDoc{
+		Tag: "Корзина", Summary: "Уменьшить количество наборов", Response: CartQuantityResponse{},
+	})
+	routes.user("PUT /cart/items/{id}/comment", r.setCartItemComment, routeDoc{
+		Tag: "Корзина", Summary: "Комментарий к товару", Request: models.CartCommentRequest{},
+		Response: models.CartResponseItem{},
+	})
+	routes.user("POST /cart/cleanup", r.cleanupCart, routeDoc{
+		Tag: "Корзина", Summary: "Убрать недоступные товары", Response: models.CartCleanupResult{},
+	})
+	routes.user("GET /delivery-info", r.getDeliveryInfo, routeDoc{
+		Tag: "Корзина", Summary: "Условия доставки", Response: models.DeliveryInfo{},
+	})
 
-	innerRouter.HandleFunc("GET /categories", authMiddleware(loggingMiddleware(appRouter.getCategories)))
+	routes.user("GET /lists", r.getShoppingLists, routeDoc{
+		Tag: "Списки покупок", Summary: "Списки покупок", Response: []models.ShoppingList{},
+	})
+	routes.user("POST /lists", r.createShoppingList, routeDoc{
+		Tag: "Списки покупок", Summary: "Создать список",
+		Request: models.ShoppingListRequest{}, Response: models.ShoppingList{},
+	})
+	routes.user("GET /lists/{id}", r.getShoppingList, routeDoc{
+		Tag: "Списки покупок", Summary: "Список покупок", Response: models.ShoppingList{},
+	})
+	routes.user("PUT /lists/{id}", r.updateShoppingList, routeDoc{
+		Tag: "Списки покупок", Summary: "Изменить список",
+		Request: models.ShoppingListRequest{}, Response: models.ShoppingList{},
+	})
+	routes.user("DELETE /lists/{id}", r.deleteShoppingList, routeDoc{Tag: "Списки покупок", Summary: "Удалить список"})
+	routes.user("POST /lists/{id}/to-cart", r.shoppingListToCart, routeDoc{
+		Tag: "Списки покупок", Summary: "Добавить товары списка в корзину", Response: models.ListToCartResult{},
+	})
 
-	innerRouter.HandleFunc("GET /cart", authMiddleware(loggingMiddleware(appRouter.getCart)))
-	innerRouter.HandleFunc("POST /cart/items", authMiddleware(loggingMiddleware(appRouter.addToCart)))
-	innerRouter.HandleFunc("DELETE /cart/items/{id}", authMiddleware(loggingMiddleware(appRouter.removeFromCart)))
+	routes.user("GET /orders", r.getOrders, routeDoc{Tag: "Заказы", Summary: "Заказы", Response: []models.Order{}})
+	routes.user("POST /orders", r.makeOrder, routeDoc{
+		Tag: "Заказы", Summary: "Оформить заказ", Request: models.OrderRequest{},
+	})
+	routes.user("GET /orders/{id}/timeline", r.getOrderTimeline, routeDoc{
+		Tag: "Заказы", Summary: "Ход заказа с событиями для экрана доставки", Response: models.OrderTimeline{},
+	})
+	routes.user("GET /subscriptions", r.getSubscriptions, routeDoc{
+		Tag: "Заказы", Summary: "Подписки на повторяющиеся заказы", Response: []models.Subscription{},
+	})
+	routes.user("POST /subscriptions", r.createSubscription, routeDoc{
+		Tag: "Заказы", Summary: "Оформить подписку на заказ",
+		Request: models.SubscriptionRequest{}, Response: models.Subscription{},
+	})
+	routes.user("POST /subscriptions/{id}/pause", r.pauseSubscription, routeDoc{
+		Tag: "Заказы", Summary: "Приостановить подписку", Response: models.Subscription{},
+	})
+	routes.user("POST /subscriptions/{id}/resume", r.resumeSubscription, routeDoc{
+		Tag: "Заказы", Summary: "Возобновить подписку", Response: models.Subscription{},
+	})
+	routes.user("DELETE /subscriptions/{id}", r.cancelSubscription, routeDoc{
+		Tag: "Заказы", Summary: "Отменить подписку", Response: models.Subscription{},
+	})
+	routes.user("GET /order-extras", r.getOrderExtras, routeDoc{
+		Tag: "Заказы", Summary: "Опции заказа и доплаты за них", Response: []models.OrderExtra{},
+	})
+	routes.user("POST /checkout/preview", r.previewCheckout, routeDoc{
+		Tag: "Заказы", Summary: "Предварительный расчет заказа",
+		Request: models.CheckoutPreviewRequest{}, Response: models.CheckoutPreview{},
+	})
 
-	innerRouter.HandleFunc("GET /orders", authMiddleware(loggingMiddleware(appRouter.getOrders)))
-	innerRouter.HandleFunc("POST /orders", authMiddleware(loggingMiddleware(appRouter.makeOrder)))
+	routes.courierOnly("GET /courier/orders", r.getCourierOrders, routeDoc{
+		Tag: "Курьер", Summary: "Заказы курьера и новое предложение", Response: []models.CourierOrder{},
+	})
+	routes.courierOnly("POST /courier/orders/{id}/accept", r.acceptCourierOrder, routeDoc{
+		Tag: "Курьер", Summary: "Принять предложенный заказ", Response: models.CourierOrder{},
+	})
+	routes.courierOnly("POST /courier/orders/{id}/decline", r.declineCourierOrder, routeDoc{
+		Tag: "Курьер", Summary: "Отказаться от заказа",
+	})
+	routes.courierOnly("PUT /courier/orders/{id}/status", r.updateDeliveryStatus, routeDoc{
+		Tag: "Курьер", Summary: "Заказ забран или вручен",
+		Request: models.DeliveryStatusRequest{}, Response: models.CourierOrder{},
+	})
+	routes.courierOnly("POST /courier/orders/{id}/photo", r.setDeliveryPhoto, routeDoc{
+		Tag: "Курьер", Summary: "Фото доставки", Request: models.DeliveryPhotoRequest{}, Response: models.CourierOrder{},
+	})
 
-	innerRouter.HandleFunc("GET /addresses", authMiddleware(loggingMiddleware(appRouter.getAddresses)))
-	innerRouter.HandleFunc("POST /addresses", authMiddleware(loggingMiddleware(appRouter.addAddress)))
-	innerRouter.HandleFunc("PUT /addresses/{id}", authMiddleware(loggingMiddleware(appRouter.updateAddress)))
-	innerRouter.HandleFunc("DELETE /addresses/{id}", authMiddleware(loggingMiddleware(appRouter.deleteAddress)))
+	routes.user("GET /addresses", r.getAddresses, routeDoc{
+		Tag: "О пользователе", Summary: "Адреса", Response: []models.Address{},
+		Query: []queryParam{{Name: "label", Type: "string"}},
+	})
+	routes.user("POST /addresses", r.addAddress, routeDoc{Tag: "О пользователе", Summary: "Добавить адрес", Request: models.Address{}})
+	routes.user("PUT /addresses/{id}", r.updateAddress, routeDoc{
+		Tag: "О пользователе", Summary: "Изменить адрес", Request: models.Address{},
+	})
+	routes.user("PATCH /addresses/{id}", r.patchAddress, routeDoc{
+		Tag: "О пользователе", Summary: "Изменить отдельные поля адреса",
+		Request: models.AddressPatch{}, Response: models.Address{},
+	})
+	routes.user("DELETE /addresses/{id}", r.deleteAddress, routeDoc{Tag: "О пользователе", Summary: "Удалить адрес"})
+	routes.user("PUT /users/me/current-address", r.setCurrentAddress, routeDoc{
+		Tag: "О пользователе", Summary: "Выбрать адрес доставки",
+		Request: models.CurrentAddressRequest{}, Response: models.Address{},
+	})
+	routes.user("DELETE /users/me/current-address", r.clearCurrentAddress, routeDoc{
+		Tag: "О пользователе", Summary: "Сбросить выбранный адрес доставки",
+	})
 
-	innerRouter.HandleFunc("POST /createToken", authMiddleware(loggingMiddleware(appRouter.createToken)))
-	innerRouter.HandleFunc("POST /createTeacherToken", authMiddleware(loggingMiddleware(appRouter.createTeacherToken)))
+	tokenQuery := []queryParam{{Name: "name", Type: "string", Required: true}}
+	routes.tokenIssuer("POST /createToken", r.createToken, routeDoc{
+		Tag: "О пользователе", Summary: "Создать токен студента", Query: tokenQuery, Response: TokenResponse{},
+	})
+	routes.tokenIssuer("POST /createTeacherToken", r.createTeacherToken, routeDoc{
+		Tag: "О пользователе", Summary: "Создать токен преподавателя", Query: tokenQuery, Response: TokenResponse{},
+	})
+	routes.tokenIssuer("POST /createCourierToken", r.createCourierToken, routeDoc{
+		Tag: "О пользователе", Summary: "Создать токен курьера", Query: tokenQuery, Response: TokenResponse{},
+	})
+	routes.user("GET /auth/whoami", r.whoAmI, routeDoc{
+		Tag: "О пользователе", Summary: "Данные текущего токена", Response: WhoAmIResponse{},
+	})
 
-	uploadsDir := http.Dir("data/uploads")
-	innerRouter.Handle("GET /uploads/", http.StripPrefix("/uploads/", http.FileServer(uploadsDir)))
-	innerRouter.HandleFunc("POST /uploads", authMiddleware(loggingMiddleware(appRouter.saveFile)))
+	// Без авторизации: картинки каталога загружаются тегами img
+	routes.public("GET /img/{hash}", r.serveImage, routeDoc{
+		Tag: "Файлы", Summary: "Картинка каталога с внешнего хоста", Response: rawBody{ContentType: "image/*"},
+		LongRunning: true,
+	})
+	// Без авторизации: файлы открыты, а при UPLOADS_PRIVATE доступ дает подпись из /uploads/{name}/link
+	routes.public("GET /uploads/{name}", r.serveUpload, routeDoc{
+		Tag: "Файлы", Summary: "Скачать файл",
+		Query: []queryParam{
+			{Name: "expires", Type: "integer"},
+			{Name: "signature", Type: "string"},
+		},
+		Response:    rawBody{ContentType: "image/jxl"},
+		LongRunning: true,
+	})
+	routes.user("GET /uploads/{name}/link", r.signDownload, routeDoc{
+		Tag: "Файлы", Summary: "Получить подписанную ссылку на скачивание", Response: models.SignedDownload{},
+	})
+	routes.user("POST /uploads", r.saveFile, routeDoc{
+		Tag: "Файлы", Summary: "Загрузить изображение JPEG XL",
+		Request: rawBody{ContentType: "multipart/form-data"}, Response: FileResponse{},
+	})
+	routes.user("POST /uploads/presign", r.presignUpload, routeDoc{
+		Tag: "Файлы", Summary: "Получить подписанную ссылку для загрузки", Response: models.PresignedUpload{},
+	})
+	// Без авторизации: доступ дает подпись в ссылке, выданной /uploads/presign
+	routes.public("PUT /uploads/{name}", r.saveSignedFile, routeDoc{
+		Tag: "Файлы", Summary: "Загрузить файл по подписанной ссылке",
+		Query: []queryParam{
+			{Name: "expires", Type: "integer", Required: true},
+			{Name: "signature", Type: "string", Required: true},
+		},
+		Request: rawBody{ContentType: "application/octet-stream"}, Response: FileResponse{},
+	})
 
 	// Wallet routes
-	innerRouter.HandleFunc("GET /wallet", authMiddleware(loggingMiddleware(appRouter.getWallet)))
-	innerRouter.HandleFunc("GET /wallet/transactions", authMiddleware(loggingMiddleware(appRouter.getTransactions)))
-	innerRouter.HandleFunc("POST /wallet/topup", authMiddleware(loggingMiddleware(appRouter.topupAccount)))
-	innerRouter.HandleFunc("POST /wallet/transfers", authMiddleware(loggingMiddleware(appRouter.transferMoney)))
+	routes.user("GET /wallet", r.getWallet, routeDoc{Tag: "Кошелек", Summary: "Счета", Response: models.Wallet{}})
+	routes.user("POST /wallet/accounts", r.openAccount, routeDoc{
+		Tag: "Кошелек", Summary: "Открыть счет", Request: models.OpenAccountRequest{}, Response: models.Account{},
+	})
+	routes.user("PATCH /wallet/accounts/{id}", r.updateAccount, routeDoc{
+		Tag: "Кошелек", Summary: "Изменить название, цвет и иконку счета",
+		Request: models.AccountPatch{}, Response: models.Account{},
+	})
+	routes.user("GET /wallet/rates", r.getExchangeRate, routeDoc{
+		Tag: "Кошелек", Summary: "Курс обмена между валютами счетов", Response: models.ExchangeRate{},
+		Query: []queryParam{{Name: "from", Type: "string", Required: true}, {Name: "to", Type: "string", Required: true}},
+	})
+	routes.user("GET /wallet/transactions", r.getTransactions, routeDoc{
+		Tag: "Кошелек", Summary: "История операций", Query: paginationQuery, Response: models.TransactionsResponse{},
+	})
+	routes.user("GET /wallet/analytics", r.getWalletAnalytics, routeDoc{
+		Tag: "Кошелек", Summary: "Аналитика трат", Response: models.WalletAnalytics{},
+		Query: []queryParam{{Name: "period", Type: "string"}},
+	})
+	routes.user("POST /wallet/topup", r.topupAccount, routeDoc{
+		Tag: "Кошелек", Summary: "Пополнить счет", Request: models.TopupRequest{}, Response: models.TopupResponse{},
+	})
+	routes.user("GET /wallet/topup/schedule", r.getScheduledTopups, routeDoc{
+		Tag: "Кошелек", Summary: "Регулярные пополнения", Response: []models.ScheduledTopup{},
+	})
+	routes.user("POST /wallet/topup/schedule", r.createScheduledTopup, routeDoc{
+		Tag: "Кошелек", Summary: "Создать регулярное пополнение",
+		Request: models.ScheduledTopupRequest{}, Response: models.ScheduledTopup{},
+	})
+	routes.user("DELETE /wallet/topup/schedule/{id}", r.cancelScheduledTopup, routeDoc{
+		Tag: "Кошелек", Summary: "Отменить регулярное пополнение", Response: models.ScheduledTopup{},
+	})
+	routes.user("POST /wallet/topup/external", r.createExternalTopup, routeDoc{
+		Tag: "Кошелек", Summary: "Пополнить счет через платежного провайдера",
+		Request: models.TopupRequest{}, Response: models.Payment{},
+	})
+	routes.user("GET /payments/{id}", r.getPayment, routeDoc{
+		Tag: "Кошелек", Summary: "Статус пополнения через провайдера", Response: models.Payment{},
+	})
+	routes.public("POST /payments/callback", r.paymentCallback, routeDoc{
+		Tag: "Кошелек", Summary: "Уведомление платежного провайдера об оплате",
+		Request: models.PaymentCallback{}, Response: models.Payment{},
+	})
+	routes.public("GET /payments/sandbox/{id}", r.getSandboxPaymentPage, routeDoc{
+		Tag: "Кошелек", Summary: "Страница оплаты песочницы", Response: rawBody{ContentType: "text/html"},
+	})
+	routes.public("POST /payments/sandbox/{id}", r.completeSandboxPayment, routeDoc{
+		Tag: "Кошелек", Summary: "Завершить платеж песочницы",
+		Query: []queryParam{{Name: "status", Type: "string", Required: true}}, Response: models.Payment{},
+	})
+	routes.user("POST /wallet/transfers", r.transferMoney, routeDoc{
+		Tag: "Кошелек", Summary: "Перевод по номеру телефона",
+		Request: models.TransferRequest{}, Response: models.TransferResponse{},
+	})
+	routes.user("GET /wallet/contacts", r.getWalletContacts, routeDoc{
+		Tag: "Кошелек", Summary: "Сохраненные и недавние получатели переводов", Response: []models.WalletContact{},
+	})
+	routes.user("POST /wallet/contacts", r.saveWalletContact, routeDoc{
+		Tag: "Кошелек", Summary: "Сохранить получателя под именем",
+		Request: models.SaveWalletContactRequest{}, Response: models.WalletContact{},
+	})
+	routes.user("DELETE /wallet/contacts/{id}", r.deleteWalletContact, routeDoc{
+		Tag: "Кошелек", Summary: "Удалить сохраненного получателя",
+	})
+	routes.user("GET /wallet/pin", r.getWalletPIN, routeDoc{
+		Tag: "Кошелек", Summary: "Установлен ли PIN кошелька", Response: models.WalletPINStatus{},
+	})
+	routes.user("POST /wallet/pin", r.setWalletPIN, routeDoc{
+		Tag: "Кошелек", Summary: "Установить или сменить PIN кошелька",
+		Request: models.WalletPINRequest{}, Response: models.WalletPINStatus{},
+	})
+	routes.user("POST /wallet/pin/reset", r.requestWalletPINReset, routeDoc{
+		Tag: "Кошелек", Summary: "Отправить код для сброса PIN", Response: models.WalletPINResetResponse{},
+	})
+	routes.user("POST /wallet/pin/reset/confirm", r.confirmWalletPINReset, routeDoc{
+		Tag: "Кошелек", Summary: "Новый PIN по коду сброса",
+		Request: models.WalletPINResetConfirmRequest{}, Response: models.WalletPINStatus{},
+	})
+
+	// Admin routes
+	routes.teacherOnly("GET /admin/export", r.exportData, routeDoc{
+		Tag: "Администрирование", Summary: "Выгрузка данных", Response: rawBody{ContentType: "application/zip"},
+		Query:       []queryParam{{Name: "entities", Type: "array"}, {Name: "format", Type: "string"}},
+		LongRunning: true,
+	})
+	routes.teacherOnly("GET /admin/orders/export", r.exportOrders, routeDoc{
+		Tag: "Администрирование", Summary: "Выгрузка заказов за период", Response: rawBody{ContentType: "text/csv"},
+		Query: []queryParam{
+			{Name: "from", Type: "string"}, {Name: "to", Type: "string"}, {Name: "format", Type: "string"},
+		},
+		LongRunning: true,
+	})
+	routes.teacherOnly("PUT /admin/products/{id}/availability", r.setAvailability, routeDoc{
+		Tag: "Администрирование", Summary: "Изменить наличие товара",
+		Request: models.AvailabilityRequest{}, Response: models.Product{},
+	})
+	routes.teacherOnly("GET /admin/products/versions", r.getCatalogVersions, routeDoc{
+		Tag: "Администрирование", Summary: "Версии каталога", Response: []models.CatalogVersion{},
+	})
+	routes.teacherOnly("POST /admin/products/versions/{version}/rollback", r.rollbackCatalog, routeDoc{
+		Tag: "Администрирование", Summary: "Откатить каталог к версии", Response: models.CatalogVersion{},
+	})
+	routes.teacherOnly("POST /admin/orders/{id}/refund", r.refundOrder, routeDoc{
+		Tag: "Администрирование", Summary: "Вернуть деньги за заказ или его позиции",
+		Request: models.RefundRequest{}, Response: models.Order{},
+	})
+	routes.teacherOnly("POST /admin/orders/{id}/delay", r.delayOrder, routeDoc{
+		Tag: "Администрирование", Summary: "Задержать доставку заказа",
+		Request: models.OrderDelayRequest{}, Response: models.Order{},
+	})
+	routes.teacherOnly("GET /admin/tokens", r.listTokens, routeDoc{
+		Tag: "Администрирование", Summary: "Выданные токены", Response: []models.IssuedToken{},
+	})
+	routes.teacherOnly("GET /admin/wallet/blocked", r.getBlockedOperations, routeDoc{
+		Tag: "Администрирование", Summary: "Операции кошелька, заблокированные антифродом",
+		Query: []queryParam{{Name: "status", Type: "string"}}, Response: []models.BlockedOperation{},
+	})
+	routes.teacherOnly("POST /admin/wallet/blocked/{id}/approve", r.approveBlockedOperation, routeDoc{
+		Tag: "Администрирование", Summary: "Разрешить заблокированную операцию", Response: models.BlockedOperation{},
+	})
+	routes.teacherOnly("GET /admin/icons", r.getIcons, routeDoc{
+		Tag: "Администрирование", Summary: "Иконки транзакций", Response: map[models.IconKind]string{},
+	})
+	routes.teacherOnly("PUT /admin/icons/{kind}", r.setIcon, routeDoc{
+		Tag: "Администрирование", Summary: "Изменить иконку транзакций",
+		Request: models.IconRequest{}, Response: map[models.IconKind]string{},
+	})
+	routes.teacherOnly("GET /admin/stats", r.getStats, routeDoc{
+		Tag: "Администрирование", Summary: "Статистика заказов, корзин и кошелька",
+		Query: []queryParam{{Name: "days", Type: "integer"}}, Response: models.Stats{},
+	})
+	routes.teacherOnly("POST /admin/users/{id}/reset", r.resetUser, routeDoc{
+		Tag: "Администрирование", Summary: "Сбросить данные студента",
+	})
+	routes.teacherOnly("GET /admin/users/{id}/usage", r.getUserUsage, routeDoc{
+		Tag: "Администрирование", Summary: "Вызовы API студентом", Response: models.UserUsage{},
+	})
+	routes.teacherOnly("GET /admin/users/blocked", r.listBlockedUsers, routeDoc{
+		Tag: "Администрирование", Summary: "Заблокированные пользователи", Response: []models.UserSuspension{},
+	})
+	routes.teacherOnly("POST /admin/users/{id}/block", r.blockUser, routeDoc{
+		Tag: "Администрирование", Summary: "Заблокировать пользователя",
+		Request: models.BlockUserRequest{}, Response: models.UserSuspension{},
+	})
+	routes.teacherOnly("POST /admin/users/{id}/unblock", r.unblockUser, routeDoc{
+		Tag: "Администрирование", Summary: "Разблокировать пользователя",
+	})
+	routes.teacherOnly("GET /admin/zones", r.listZones, routeDoc{
+		Tag: "Администрирование", Summary: "Зоны доставки и их ассортимент", Response: []models.DeliveryZone{},
+	})
+	routes.teacherOnly("PUT /admin/zones/{id}/assortment", r.setZoneAssortment, routeDoc{
+		Tag: "Администрирование", Summary: "Привязать товары и категории к зоне доставки",
+		Request: models.ZoneAssortmentRequest{}, Response: models.DeliveryZone{},
+	})
+	routes.teacherOnly("GET /admin/collections", r.listCollections, routeDoc{
+		Tag: "Администрирование", Summary: "Подборки товаров", Response: []models.Collection{},
+	})
+	routes.teacherOnly("PUT /admin/collections/{id}", r.setCollection, routeDoc{
+		Tag: "Администрирование", Summary: "Создать или заменить подборку товаров",
+		Request: models.CollectionRequest{}, Response: models.Collection{},
+	})
+	routes.teacherOnly("DELETE /admin/collections/{id}", r.deleteCollection, routeDoc{
+		Tag: "Администрирование", Summary: "Удалить подборку товаров",
+	})
+	routes.teacherOnly("GET /admin/clock", r.getClock, routeDoc{
+		Tag: "Администрирование", Summary: "Время сервера", Response: models.ClockState{},
+	})
+	routes.teacherOnly("POST /admin/clock/advance", r.advanceClock, routeDoc{
+		Tag: "Администрирование", Summary: "Перемотать время вперед в демо-режиме",
+		Request: models.AdvanceClockRequest{}, Response: models.ClockState{},
+	})
+	routes.teacherOnly("GET /admin/experiments", r.listExperiments, routeDoc{
+		Tag: "Администрирование", Summary: "A/B эксперименты", Response: []models.Experiment{},
+	})
+	routes.teacherOnly("PUT /admin/experiments/{key}", r.putExperiment, routeDoc{
+		Tag: "Администрирование", Summary: "Создать или изменить эксперимент",
+		Request: models.ExperimentRequest{}, Response: models.Experiment{},
+	})
+	routes.teacherOnly("DELETE /admin/experiments/{key}", r.deleteExperiment, routeDoc{
+		Tag: "Администрирование", Summary: "Удалить эксперимент",
+	})
+	routes.teacherOnly("GET /admin/chaos", r.getChaosRules, routeDoc{
+		Tag: "Администрирование", Summary: "Правила внедрения сбоев", Response: []models.ChaosRule{},
+	})
+	routes.teacherOnly("POST /admin/chaos", r.addChaosRule, routeDoc{
+		Tag: "Администрирование", Summary: "Добавить правило внедрения сбоев",
+		Request: models.ChaosRule{}, Response: models.ChaosRule{},
+	})
+	routes.teacherOnly("DELETE /admin/chaos", r.clearChaosRules, routeDoc{
+		Tag: "Администрирование", Summary: "Удалить все правила внедрения сбоев",
+	})
+	routes.teacherOnly("DELETE /admin/chaos/{id}", r.removeChaosRule, routeDoc{
+		Tag: "Администрирование", Summary: "Удалить правило внедрения сбоев",
+	})
+	routes.teacherOnly("GET /admin/log-level", r.getLogLevels, routeDoc{
+		Tag: "Администрирование", Summary: "Уровни логирования", Response: models.LogLevels{},
+	})
+	routes.teacherOnly("PUT /admin/log-level", r.setLogLevels, routeDoc{
+		Tag: "Администрирование", Summary: "Изменить уровни логирования",
+		Request: models.LogLevelRequest{}, Response: models.LogLevels{},
+	})
+	routes.teacherOnly("GET /admin/backup/status", r.getBackupStatus, routeDoc{
+		Tag: "Администрирование", Summary: "Результат последнего бэкапа по сервисам", Response: []models.BackupStatus{},
+	})
+	routes.teacherOnly("POST /admin/backup", r.backupNow, routeDoc{
+		Tag: "Администрирование", Summary: "Сделать бэкап сейчас", Response: models.BackupSnapshot{},
+	})
+	routes.teacherOnly("GET /admin/backups", r.listBackups, routeDoc{
+		Tag: "Администрирование", Summary: "Доступные снимки бэкапа", Response: []models.BackupSnapshot{},
+	})
+	routes.teacherOnly("POST /admin/restore", r.restoreBackup, routeDoc{
+		Tag: "Администрирование", Summary: "Восстановить данные из снимка бэкапа",
+		Query:    []queryParam{{Name: "snapshot", Type: "string", Required: true}},
+		Response: models.RestoreResult{},
+	})
+	routes.teacherOnly("GET /admin/debug/runtime", r.getRuntimeDiagnostics, routeDoc{
+		Tag: "Администрирование", Summary: "Диагностика процесса", Response: models.RuntimeDiagnostics{},
+	})
+	routes.teacherOnly("GET /admin/metrics", r.getMetrics, routeDoc{
+		Tag: "Администрирование", Summary: "Метрики бизнес-событий в формате Prometheus",
+		Response: rawBody{ContentType: "text/plain"},
+	})
+	routes.teacherOnly("GET /admin/debug/slow", r.getSlowRequests, routeDoc{
+		Tag: "Администрирование", Summary: "Самые долгие запросы", Response: models.SlowRequestsReport{},
+	})
+	routes.teacherOnly("DELETE /admin/debug/slow", r.clearSlowRequests, routeDoc{
+		Tag: "Администрирование", Summary: "Сбросить счетчики медленных запросов",
+	})
+	routes.teacherOnly("GET /admin/recordings", r.listRecordings, routeDoc{
+		Tag: "Администрирование", Summary: "Студенты с записанными запросами", Response: []models.RecordingSummary{},
+	})
+	routes.teacherOnly("GET /admin/recordings/{id}", r.downloadRecording, routeDoc{
+		Tag: "Администрирование", Summary: "Скачать запись запросов студента", Response: models.RecordingSession{},
+	})
+	routes.teacherOnly("DELETE /admin/recordings/{id}", r.clearRecording, routeDoc{
+		Tag: "Администрирование", Summary: "Удалить запись запросов студента",
+	})
+	routes.teacherOnly("GET /admin/webhooks", r.listWebhooks, routeDoc{
+		Tag: "Администрирование", Summary: "Вебхуки интеграторов", Response: []models.Webhook{},
+	})
+	routes.teacherOnly("POST /admin/webhooks", r.createWebhook, routeDoc{
+		Tag: "Администрирование", Summary: "Зарегистрировать вебхук",
+		Request: models.WebhookRequest{}, Response: models.Webhook{},
+	})
+	routes.teacherOnly("DELETE /admin/webhooks/{id}", r.deleteWebhook, routeDoc{
+		Tag: "Администрирование", Summary: "Удалить вебхук",
+	})
+	routes.teacherOnly("GET /admin/webhooks/{id}/deliveries", r.getWebhookDeliveries, routeDoc{
+		Tag: "Администрирование", Summary: "Журнал отправок вебхука", Response: []models.WebhookDelivery{},
+	})
+
+	// pprof разбирает путь относительно /debug/pprof/, поэтому префикс /admin отрезается.
+	// В спецификацию профили не попадают, их описывает документация net/http/pprof.
+	pprofHandler := func(handler http.HandlerFunc) http.HandlerFunc {
+		return routes.auth(routes.teacher(http.StripPrefix("/admin", handler).ServeHTTP))
+	}
+	routes.mux.HandleFunc("GET /admin/debug/pprof/", pprofHandler(pprof.Index))
+	routes.mux.HandleFunc("GET /admin/debug/pprof/cmdline", pprofHandler(pprof.Cmdline))
+	routes.mux.HandleFunc("GET /admin/debug/pprof/profile", pprofHandler(pprof.Profile))
+	routes.mux.HandleFunc("GET /admin/debug/pprof/symbol", pprofHandler(pprof.Symbol))
+	routes.mux.HandleFunc("POST /admin/debug/pprof/symbol", pprofHandler(pprof.Symbol))
+	routes.mux.HandleFunc("GET /admin/debug/pprof/trace", pprofHandler(pprof.Trace))
 
 	// Health check endpoint
-	innerRouter.HandleFunc("GET /health", appRouter.healthCheck)
+	routes.public("GET /health", r.healthCheck, routeDoc{
+		Summary: "Проверка работоспособности", Response: HealthResponse{}, Unlimited: true,
+	})
+	routes.public("GET /readyz", r.readinessCheck, routeDoc{
+		Summary: "Проверка готовности и состояния бэкапов", Response: ReadinessResponse{}, Unlimited: true,
+	})
 
-	innerRouter.HandleFunc("GET /", func(writer http.ResponseWriter, request *http.Request) {
-		http.ServeFile(writer, request, "redoc-static.html")
+	routes.public("GET /openapi.json", r.getOpenAPI, routeDoc{
+		Summary: "Спецификация OpenAPI, построенная по маршрутам", Response: rawBody{ContentType: "application/json"},
 	})
 
-	return appRouter
+	routes.mux.HandleFunc("GET /docs", r.serveDocs)
+	routes.mux.Handle("GET /{$}", http.RedirectHandler("/docs", http.StatusFound))
+	routes.mux.HandleFunc(fallbackPattern, r.notFound)
+}
+
+// OpenAPISpec строит спецификацию без запуска сервера: обработчики только регистрируются, сервисы не нужны.
+func OpenAPISpec() ([]byte, error) {
+	passthrough := func(next http.HandlerFunc) http.HandlerFunc { return next }
+
+	unlimited := func(_ RouteClass, next http.HandlerFunc) http.HandlerFunc { return next }
+
+	routes := newRouteRegistry(http.NewServeMux(), passthrough, passthrough, passthrough, passthrough, passthrough, unlimited)
+	(&Router{}).registerRoutes(routes)
+
+	return buildOpenAPI(routes.routes)
+}
+
+func (r *Router) getOpenAPI(writer http.ResponseWriter, request *http.Request) {
+	buf, err := buildOpenAPI(r.routes)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
 }
 
 func (r *Router) sendResponse(response http.ResponseWriter, request *http.Request, code int, buf []byte) {
@@ -186,6 +1086,8 @@ func (r *Router) sendResponse(response http.ResponseWriter, request *http.Reques
 }
 
 func (r *Router) sendErrorResponse(response http.ResponseWriter, request *http.Request, err error) {
+	response.Header().Set("Content-Type", "application/json")
+
 	switch {
 	case errors.Is(err, models.ErrBadRequest):
 		response.WriteHeader(http.StatusBadRequest)
@@ -216,8 +1118,8 @@ func (r *Router) sendErrorResponse(response http.ResponseWriter, request *http.R
 		r.writeError(response, request, err)
 
 		return
-	case errors.Is(err, models.ErrUnauthorized):
-		response.WriteHeader(http.StatusUnauthorized)
+	case errors.Is(err, models.ErrTooManyRequests):
+		response.WriteHeader(http.StatusTooManyRequests)
 		r.logger.With(
 			"module", "api",
 			"request_url", request.Method+": "+request.URL.Path,
@@ -226,19 +1128,92 @@ func (r *Router) sendErrorResponse(response http.ResponseWriter, request *http.R
 		r.writeError(response, request, err)
 
 		return
-	}
+	case errors.Is(err, models.ErrPriceChanged), errors.Is(err, models.ErrConflict):
+		response.WriteHeader(http.StatusConflict)
+		r.logger.With(
+			"module", "api",
+			"request_url", request.Method+": "+request.URL.Path,
+		).Warn(err)
 
-	response.WriteHeader(http.StatusInternalServerError)
-	r.logger.With(
-		"module", "api",
-		"request_url", request.Method+": "+request.URL.Path,
-	).Error(err)
+		r.writeError(response, request, err)
 
-	r.writeError(response, request, err)
-}
+		return
+	case errors.Is(err, models.ErrServiceUnavailable):
+		response.Header().Set("Retry-After", retryAfterSeconds)
+		response.WriteHeader(http.StatusServiceUnavailable)
+		r.logger.With(
+			"module", "api",
+			"request_url", request.Method+": "+request.URL.Path,
+		).Warn(err)
+
+		r.writeError(response, request, err)
+
+		return
+	case errors.Is(err, models.ErrBadGateway):
+		response.WriteHeader(http.StatusBadGateway)
+		r.logger.With(
+			"module", "api",
+			"request_url", request.Method+": "+request.URL.Path,
+		).Warn(err)
+
+		r.writeError(response, request, err)
+
+		return
+	case errors.Is(err, context.DeadlineExceeded):
+		response.WriteHeader(http.StatusServiceUnavailable)
+		r.logger.With(
+			"module", "api",
+			"request_url", request.Method+": "+request.URL.Path,
+		).Warn(err)
+
+		r.writeError(response, request, err)
+
+		return
+	case errors.Is(err, models.ErrMethodNotAllowed):
+		response.WriteHeader(http.StatusMethodNotAllowed)
+		r.logger.With(
+			"module", "api",
+			"request_url", request.Method+": "+request.URL.Path,
+		).Warn(err)
+
+		r.writeError(response, request, err)
+
+		return
+	case errors.Is(err, models.ErrUnauthorized):
+		response.WriteHeader(http.StatusUnauthorized)
+		r.logger.With(
+			"module", "api",
+			"request_url", request.Method+": "+request.URL.Path,
+		).Warn(err)
+
+		r.writeError(response, request, err)
+
+		return
+	}
+
+	response.WriteHeader(http.StatusInternalServerError)
+	r.logger.With(
+		"module", "api",
+		"request_url", request.Method+": "+request.URL.Path,
+	).Error(err)
+
+	r.writeError(response, request, err)
+}
+
+// detailedError ошибка с дополнительными полями в теле ответа
+type detailedError interface {
+	Details() map[string]any
+}
 
 func (r *Router) writeError(response http.ResponseWriter, request *http.Request, err error) {
-	body := map[string]string{"error": err.Error()}
+	body := map[string]any{"error": err.Error()}
+
+	var detailed detailedError
+	if errors.As(err, &detailed) {
+		for key, value := range detailed.Details() {
+			body[key] = value
+		}
+	}
 
 	result, err := json.Marshal(body)
 	if err != nil {
@@ -263,7 +1238,7 @@ func (r *Router) saveFile(writer http.ResponseWriter, request *http.Request) {
 		return
 	}
 
-	responseBody := map[string]string{"file": filename}
+	responseBody := FileResponse{File: filename}
 
 	buf, err := json.Marshal(responseBody)
 	if err != nil {
@@ -275,24 +1250,153 @@ func (r *Router) saveFile(writer http.ResponseWriter, request *http.Request) {
 	r.sendResponse(writer, request, http.StatusOK, buf)
 }
 
+func (r *Router) presignUpload(writer http.ResponseWriter, request *http.Request) {
+	upload, err := r.fileSaver.PresignUpload(request.Context())
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("PresignUpload: %w", err))
+
+		return
+	}
+
+	buf, err := json.Marshal(upload)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) saveSignedFile(writer http.ResponseWriter, request *http.Request) {
+	name := request.PathValue("name")
+	query := request.URL.Query()
+
+	err := r.fileSaver.SaveSignedFile(writer, request, name, query.Get("expires"), query.Get("signature"))
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("SaveSignedFile: %w", err))
+
+		return
+	}
+
+	buf, err := json.Marshal(FileResponse{File: name})
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) serveUpload(writer http.ResponseWriter, request *http.Request) {
+	err := r.fileSaver.ServeUpload(writer, request, request.PathValue("name"))
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("ServeUpload: %w", err))
+	}
+}
+
+func (r *Router) serveImage(writer http.ResponseWriter, request *http.Request) {
+	err := r.imageProxy.ServeImage(writer, request, request.PathValue("hash"))
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("ServeImage: %w", err))
+	}
+}
+
+func (r *Router) signDownload(writer http.ResponseWriter, request *http.Request) {
+	link, err := r.fileSaver.SignDownload(request.Context(), request.PathValue("name"))
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("SignDownload: %w", err))
+
+		return
+	}
+
+	buf, err := json.Marshal(link)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
 func (r *Router) getProductsList(writer http.ResponseWriter, request *http.Request) {
-	page, err := getPaginationParameter(request, "page", 1)
+	page, pageSize, err := r.getPagination(request)
 	if err != nil {
-		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, err))
+		r.sendErrorResponse(writer, request, err)
 
 		return
 	}
 
-	pageSize, err := getPaginationParameter(request, "pageSize", models.DefaultPageSize)
+	filter := models.ProductsFilter{
+		Category:         request.URL.Query().Get("category"),
+		Tags:             getListParameter(request, "tags"),
+		ExcludeAllergens: getListParameter(request, "excludeAllergens"),
+		Sort:             models.ProductSort(request.URL.Query().Get("sort")),
+		Zone:             r.zones.UserZoneFilter(request.Context()),
+	}
+
+	if collection := request.URL.Query().Get("collection"); collection != "" {
+		filter.Collection, err = r.collections.CollectionFilter(request.Context(), collection)
+		if err != nil {
+			r.sendErrorResponse(writer, request, fmt.Errorf("CollectionFilter: %w", err))
+
+			return
+		}
+	}
+
+	// Явно выбранный порядок важнее эксперимента, а у подборки свой порядок
+	if filter.Sort == "" && filter.Collection == nil {
+		variant, ok := r.experiments.Variant(request.Context(), models.ExperimentCatalogSort)
+		if ok && variant != models.ExperimentControl {
+			filter.Sort = models.ProductSort(variant)
+		}
+	}
+
+	for _, bound := range []struct {
+		name   string
+		target **models.Money
+	}{
+		{"priceMin", &filter.PriceMin},
+		{"priceMax", &filter.PriceMax},
+	} {
+		*bound.target, err = getOptionalMoneyParameter(request, bound.name)
+		if err != nil {
+			r.sendErrorResponse(writer, request, err)
+
+			return
+		}
+	}
+
+	for _, bound := range []struct {
+		name   string
+		target **int
+	}{
+		{"weightMin", &filter.WeightMin},
+		{"weightMax", &filter.WeightMax},
+	} {
+		*bound.target, err = getOptionalIntParameter(request, bound.name)
+		if err != nil {
+			r.sendErrorResponse(writer, request, err)
+
+			return
+		}
+	}
+
+	filter.HasDiscount, err = getOptionalBoolParameter(request, "hasDiscount")
 	if err != nil {
-		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, err))
+		r.sendErrorResponse(writer, request, err)
 
 		return
 	}
 
-	category := request.URL.Query().Get("category")
+	if filter.Category == models.FavouriteCategory {
+		writer.Header().Set("Deprecation", "true")
+		writer.Header().Set("Link", `</favourites>; rel="successor-version"`)
+	}
 
-	result, err := r.productsService.GetProductsList(request.Context(), page, pageSize, category)
+	result, err := r.productsService.GetProductsList(request.Context(), page, pageSize, filter)
 	if err != nil {
 		r.sendErrorResponse(writer, request, err)
 
@@ -309,6 +1413,24 @@ func (r *Router) getProductsList(writer http.ResponseWriter, request *http.Reque
 	r.sendResponse(writer, request, http.StatusOK, buf)
 }
 
+func (r *Router) getFavourites(writer http.ResponseWriter, request *http.Request) {
+	page, pageSize, err := r.getPagination(request)
+	if err != nil {
+		r.sendErrorResponse(writer, request, err)
+
+		return
+	}
+
+	buf, err := json.Marshal(r.productsService.GetFavourites(request.Context(), page, pageSize))
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
 func (r *Router) getProductByID(writer http.ResponseWriter, request *http.Request) {
 	id := request.PathValue("id")
 	if id == "" {
@@ -317,7 +1439,7 @@ func (r *Router) getProductByID(writer http.ResponseWriter, request *http.Reques
 		return
 	}
 
-	product, err := r.productsService.GetProductByID(request.Context(), id)
+	product, err := r.productsService.ViewProduct(request.Context(), id)
 	if err != nil {
 		r.sendErrorResponse(writer, request, fmt.Errorf("GetProductByID: %w", err))
 
@@ -334,51 +1456,2725 @@ func (r *Router) getProductByID(writer http.ResponseWriter, request *http.Reques
 	r.sendResponse(writer, request, http.StatusOK, buf)
 }
 
-func (r *Router) addReview(writer http.ResponseWriter, request *http.Request) {
+func (r *Router) getCombos(writer http.ResponseWriter, request *http.Request) {
+	combos, err := r.combos.ListCombos(request.Context())
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("ListCombos: %w", err))
+
+		return
+	}
+
+	buf, err := json.Marshal(combos)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) getComboByID(writer http.ResponseWriter, request *http.Request) {
 	id := request.PathValue("id")
 	if id == "" {
 		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
 
 		return
 	}
-	var requestBody models.PostReviewRequest
 
-	err := json.NewDecoder(request.Body).Decode(&requestBody)
+	combo, err := r.combos.GetCombo(request.Context(), id)
 	if err != nil {
-		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", errJsonDecode, err))
+		r.sendErrorResponse(writer, request, fmt.Errorf("GetCombo: %w", err))
 
 		return
 	}
 
-	err = r.productsService.AddReview(request.Context(), requestBody, id)
+	buf, err := json.Marshal(combo)
 	if err != nil {
-		r.sendErrorResponse(writer, request, fmt.Errorf("AddReview: %w", err))
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
 
 		return
 	}
 
-	writer.WriteHeader(http.StatusOK)
+	r.sendResponse(writer, request, http.StatusOK, buf)
 }
 
-func (r *Router) addFavourite(writer http.ResponseWriter, request *http.Request) {
-	id := request.PathValue("id")
-	if id == "" {
-		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
+func (r *Router) getRecentlyViewed(writer http.ResponseWriter, request *http.Request) {
+	result := r.productsService.GetRecentlyViewed(request.Context())
+
+	buf, err := json.Marshal(result)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
 
 		return
 	}
 
-	err := r.productsService.AddFavourite(request.Context(), id)
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) searchAll(writer http.ResponseWriter, request *http.Request) {
+	limit, err := getOptionalIntParameter(request, "limit")
 	if err != nil {
-		r.sendErrorResponse(writer, request, fmt.Errorf("AddFavourite: %w", err))
+		r.sendErrorResponse(writer, request, err)
+
+		return
+	}
+
+	searchRequest := models.SearchRequest{Query: request.URL.Query().Get("q")}
+	if limit != nil {
+		// 0 означает лимит по умолчанию, поэтому явный 0 отклоняется здесь
+		if *limit <= 0 {
+			r.sendErrorResponse(writer, request, fmt.Errorf("%w: limit must be positive", models.ErrBadRequest))
+
+			return
+		}
+
+		searchRequest.Limit = *limit
+	}
+
+	for _, searchType := range getListParameter(request, "type") {
+		searchRequest.Types = append(searchRequest.Types, models.SearchType(searchType))
+	}
+
+	result, err := r.search.Search(request.Context(), searchRequest)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("Search: %w", err))
+
+		return
+	}
+
+	buf, err := json.Marshal(result)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) getCollectionRails(writer http.ResponseWriter, request *http.Request) {
+	limit := models.DefaultCollectionRailLimit
+
+	if value := request.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			r.sendErrorResponse(writer, request, fmt.Errorf("%w: invalid limit: %w", models.ErrBadRequest, err))
+
+			return
+		}
+
+		limit = parsed
+	}
+
+	result, err := r.collections.GetRails(request.Context(), limit, r.zones.UserZoneFilter(request.Context()))
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("GetRails: %w", err))
+
+		return
+	}
+
+	buf, err := json.Marshal(result)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) getPopular(writer http.ResponseWriter, request *http.Request) {
+	limit := models.DefaultPopularLimit
+
+	if value := request.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			r.sendErrorResponse(writer, request, fmt.Errorf("%w: invalid limit: %w", models.ErrBadRequest, err))
+
+			return
+		}
+
+		limit = parsed
+	}
+
+	result, err := r.productsService.GetPopular(request.Context(), limit)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("GetPopular: %w", err))
+
+		return
+	}
+
+	buf, err := json.Marshal(result)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) getCatalogChanges(writer http.ResponseWriter, request *http.Request) {
+	var since time.Time
+
+	if value := request.URL.Query().Get("since"); value != "" {
+		parsed, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			r.sendErrorResponse(writer, request, fmt.Errorf("%w: invalid since: %w", models.ErrBadRequest, err))
+
+			return
+		}
+
+		since = parsed
+	}
+
+	result := r.productsService.GetCatalogChanges(request.Context(), since)
+
+	buf, err := json.Marshal(result)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) notifyWhenAvailable(writer http.ResponseWriter, request *http.Request) {
+	id := request.PathValue("id")
+	if id == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
+
+		return
+	}
+
+	err := r.productsService.NotifyWhenAvailable(request.Context(), id)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("NotifyWhenAvailable: %w", err))
+
+		return
+	}
+
+	writer.WriteHeader(http.StatusOK)
+}
+
+func (r *Router) getNotifications(writer http.ResponseWriter, request *http.Request) {
+	result := r.notifications.GetNotifications(request.Context())
+
+	buf, err := json.Marshal(result)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) addReview(writer http.ResponseWriter, request *http.Request) {
+	id := request.PathValue("id")
+	if id == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
+
+		return
+	}
+	var requestBody models.PostReviewRequest
+
+	err := json.NewDecoder(request.Body).Decode(&requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", errJsonDecode, err))
+
+		return
+	}
+
+	err = r.productsService.AddReview(request.Context(), requestBody, id)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("AddReview: %w", err))
+
+		return
+	}
+
+	writer.WriteHeader(http.StatusOK)
+}
+
+func (r *Router) getReviewSummary(writer http.ResponseWriter, request *http.Request) {
+	id := request.PathValue("id")
+	if id == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
+
+		return
+	}
+
+	summary, err := r.productsService.GetReviewSummary(request.Context(), id)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("GetReviewSummary: %w", err))
+
+		return
+	}
+
+	buf, err := json.Marshal(summary)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) batchFavourites(writer http.ResponseWriter, request *http.Request) {
+	var requestBody models.FavouritesBatchRequest
+
+	err := json.NewDecoder(request.Body).Decode(&requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", errJsonDecode, err))
+
+		return
+	}
+
+	result, err := r.productsService.BatchFavourites(request.Context(), requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("BatchFavourites: %w", err))
+
+		return
+	}
+
+	buf, err := json.Marshal(result)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) addFavourite(writer http.ResponseWriter, request *http.Request) {
+	id := request.PathValue("id")
+	if id == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
+
+		return
+	}
+
+	err := r.productsService.AddFavourite(request.Context(), id)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("AddFavourite: %w", err))
+
+		return
+	}
+
+	writer.WriteHeader(http.StatusOK)
+}
+
+func (r *Router) deleteFavourite(writer http.ResponseWriter, request *http.Request) {
+	id := request.PathValue("id")
+	if id == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
+
+		return
+	}
+
+	err := r.productsService.RemoveFavourite(request.Context(), id)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("RemoveFavourite: %w", err))
+
+		return
+	}
+
+	writer.WriteHeader(http.StatusOK)
+}
+
+func (r *Router) getUser(writer http.ResponseWriter, request *http.Request) {
+	result, err := r.userData.GetProfile(request.Context())
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("GetProfile: %w", err))
+
+		return
+	}
+
+	buf, err := json.Marshal(result)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) deleteUser(writer http.ResponseWriter, request *http.Request) {
+	err := r.userData.DeleteProfile(request.Context())
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("DeleteProfile: %w", err))
+
+		return
+	}
+
+	writer.WriteHeader(http.StatusOK)
+}
+
+func (r *Router) updateProfile(writer http.ResponseWriter, request *http.Request) {
+	var requestBody models.UpdateUserRequest
+
+	err := json.NewDecoder(request.Body).Decode(&requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", errJsonDecode, err))
+
+		return
+	}
+
+	err = r.userData.UpdateProfile(request.Context(), requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("UpdateProfile: %w", err))
+
+		return
+	}
+
+	writer.WriteHeader(http.StatusOK)
+}
+
+func (r *Router) setEmail(writer http.ResponseWriter, request *http.Request) {
+	var requestBody models.SetEmailRequest
+
+	err := json.NewDecoder(request.Body).Decode(&requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", errJsonDecode, err))
+
+		return
+	}
+
+	err = r.userData.SetEmail(request.Context(), requestBody.Email)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("SetEmail: %w", err))
+
+		return
+	}
+
+	writer.WriteHeader(http.StatusOK)
+}
+
+func (r *Router) verifyEmail(writer http.ResponseWriter, request *http.Request) {
+	var requestBody models.VerifyEmailRequest
+
+	err := json.NewDecoder(request.Body).Decode(&requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", errJsonDecode, err))
+
+		return
+	}
+
+	err = r.userData.VerifyEmail(request.Context(), requestBody.Code)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("VerifyEmail: %w", err))
+
+		return
+	}
+
+	writer.WriteHeader(http.StatusOK)
+}
+
+func (r *Router) logout(writer http.ResponseWriter, _ *http.Request) {
+	writer.WriteHeader(http.StatusOK)
+}
+
+func (r *Router) getSessions(writer http.ResponseWriter, request *http.Request) {
+	buf, err := json.Marshal(r.sessions.ListSessions(request.Context()))
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) revokeSession(writer http.ResponseWriter, request *http.Request) {
+	jti := request.PathValue("jti")
+	if jti == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
+
+		return
+	}
+
+	err := r.sessions.RevokeSession(request.Context(), jti)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("RevokeSession: %w", err))
+
+		return
+	}
+
+	writer.WriteHeader(http.StatusOK)
+}
+
+func (r *Router) getAddresses(writer http.ResponseWriter, request *http.Request) {
+	label := models.AddressLabel(request.URL.Query().Get("label"))
+
+	addresses, err := r.addressService.GetAddresses(request.Context(), label)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("GetAddresses: %w", err))
+
+		return
+	}
+
+	buf, err := json.Marshal(addresses)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) getSubscriptions(writer http.ResponseWriter, request *http.Request) {
+	result := r.subscriptions.GetSubscriptions(request.Context())
+
+	buf, err := json.Marshal(result)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) createSubscription(writer http.ResponseWriter, request *http.Request) {
+	var requestBody models.SubscriptionRequest
+
+	err := json.NewDecoder(request.Body).Decode(&requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", errJsonDecode, err))
+
+		return
+	}
+
+	result, err := r.subscriptions.Create(request.Context(), requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("Create: %w", err))
+
+		return
+	}
+
+	r.sendSubscription(writer, request, result)
+}
+
+func (r *Router) pauseSubscription(writer http.ResponseWriter, request *http.Request) {
+	id := request.PathValue("id")
+	if id == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
+
+		return
+	}
+
+	result, err := r.subscriptions.Pause(request.Context(), id)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("Pause: %w", err))
+
+		return
+	}
+
+	r.sendSubscription(writer, request, result)
+}
+
+func (r *Router) resumeSubscription(writer http.ResponseWriter, request *http.Request) {
+	id := request.PathValue("id")
+	if id == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
+
+		return
+	}
+
+	result, err := r.subscriptions.Resume(request.Context(), id)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("Resume: %w", err))
+
+		return
+	}
+
+	r.sendSubscription(writer, request, result)
+}
+
+func (r *Router) cancelSubscription(writer http.ResponseWriter, request *http.Request) {
+	id := request.PathValue("id")
+	if id == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
+
+		return
+	}
+
+	result, err := r.subscriptions.Cancel(request.Context(), id)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("Cancel: %w", err))
+
+		return
+	}
+
+	r.sendSubscription(writer, request, result)
+}
+
+func (r *Router) sendSubscription(writer http.ResponseWriter, request *http.Request, subscription models.Subscription) {
+	buf, err := json.Marshal(subscription)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) getShoppingLists(writer http.ResponseWriter, request *http.Request) {
+	result := r.shoppingLists.GetLists(request.Context())
+
+	buf, err := json.Marshal(result)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) getShoppingList(writer http.ResponseWriter, request *http.Request) {
+	id := request.PathValue("id")
+	if id == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
+
+		return
+	}
+
+	result, err := r.shoppingLists.GetList(request.Context(), id)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("GetList: %w", err))
+
+		return
+	}
+
+	buf, err := json.Marshal(result)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) createShoppingList(writer http.ResponseWriter, request *http.Request) {
+	var requestBody models.ShoppingListRequest
+
+	err := json.NewDecoder(request.Body).Decode(&requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", errJsonDecode, err))
+
+		return
+	}
+
+	result, err := r.shoppingLists.CreateList(request.Context(), requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("CreateList: %w", err))
+
+		return
+	}
+
+	buf, err := json.Marshal(result)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) updateShoppingList(writer http.ResponseWriter, request *http.Request) {
+	id := request.PathValue("id")
+	if id == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
+
+		return
+	}
+
+	var requestBody models.ShoppingListRequest
+
+	err := json.NewDecoder(request.Body).Decode(&requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", errJsonDecode, err))
+
+		return
+	}
+
+	result, err := r.shoppingLists.UpdateList(request.Context(), id, requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("UpdateList: %w", err))
+
+		return
+	}
+
+	buf, err := json.Marshal(result)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) deleteShoppingList(writer http.ResponseWriter, request *http.Request) {
+	id := request.PathValue("id")
+	if id == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
+
+		return
+	}
+
+	err := r.shoppingLists.DeleteList(request.Context(), id)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("DeleteList: %w", err))
+
+		return
+	}
+
+	writer.WriteHeader(http.StatusOK)
+}
+
+func (r *Router) shoppingListToCart(writer http.ResponseWriter, request *http.Request) {
+	id := request.PathValue("id")
+	if id == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
+
+		return
+	}
+
+	result, err := r.shoppingLists.AddToCart(request.Context(), id)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("AddToCart: %w", err))
+
+		return
+	}
+
+	buf, err := json.Marshal(result)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) addAddress(writer http.ResponseWriter, request *http.Request) {
+	var requestBody models.Address
+
+	err := json.NewDecoder(request.Body).Decode(&requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", errJsonDecode, err))
+
+		return
+	}
+
+	err = r.addressService.AddAddress(request.Context(), &requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("AddAddress: %w", err))
+
+		return
+	}
+
+	writer.WriteHeader(http.StatusOK)
+}
+
+func (r *Router) updateAddress(writer http.ResponseWriter, request *http.Request) {
+	id := request.PathValue("id")
+	if id == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
+
+		return
+	}
+
+	var requestBody models.Address
+
+	err := json.NewDecoder(request.Body).Decode(&requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", errJsonDecode, err))
+
+		return
+	}
+
+	requestBody.ID = id
+
+	err = r.addressService.UpdateAddress(request.Context(), &requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("UpdateAddress: %w", err))
+
+		return
+	}
+
+	writer.WriteHeader(http.StatusOK)
+}
+
+func (r *Router) patchAddress(writer http.ResponseWriter, request *http.Request) {
+	id := request.PathValue("id")
+	if id == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
+
+		return
+	}
+
+	var requestBody models.AddressPatch
+
+	err := json.NewDecoder(request.Body).Decode(&requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", errJsonDecode, err))
+
+		return
+	}
+
+	result, err := r.addressService.PatchAddress(request.Context(), id, requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("PatchAddress: %w", err))
+
+		return
+	}
+
+	buf, err := json.Marshal(result)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) deleteAddress(writer http.ResponseWriter, request *http.Request) {
+	id := request.PathValue("id")
+	if id == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
+
+		return
+	}
+
+	err := r.addressService.RemoveAddress(request.Context(), id)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("RemoveAddress: %w", err))
+
+		return
+	}
+
+	writer.WriteHeader(http.StatusOK)
+}
+
+func (r *Router) setCurrentAddress(writer http.ResponseWriter, request *http.Request) {
+	var requestBody models.CurrentAddressRequest
+
+	err := json.NewDecoder(request.Body).Decode(&requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", errJsonDecode, err))
+
+		return
+	}
+
+	if requestBody.AddressID == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
+
+		return
+	}
+
+	address, err := r.addressService.SetCurrentAddress(request.Context(), requestBody.AddressID)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("SetCurrentAddress: %w", err))
+
+		return
+	}
+
+	buf, err := json.Marshal(address)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) clearCurrentAddress(writer http.ResponseWriter, request *http.Request) {
+	r.addressService.ClearCurrentAddress(request.Context())
+
+	writer.WriteHeader(http.StatusOK)
+}
+
+func (r *Router) getCategories(writer http.ResponseWriter, request *http.Request) {
+	includeEmpty := true
+
+	if value := request.URL.Query().Get("includeEmpty"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			r.sendErrorResponse(writer, request, fmt.Errorf("%w: invalid includeEmpty: %w", models.ErrBadRequest, err))
+
+			return
+		}
+
+		includeEmpty = parsed
+	}
+
+	result := r.productsService.GetLocalizedCategories(request.Context(), includeEmpty)
+
+	buf, err := json.Marshal(result)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) getProductFacets(writer http.ResponseWriter, request *http.Request) {
+	facets, err := r.productsService.GetFacets(request.Context(), request.URL.Query().Get("category"))
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("GetFacets: %w", err))
+
+		return
+	}
+
+	buf, err := json.Marshal(facets)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) getTags(writer http.ResponseWriter, request *http.Request) {
+	result := r.productsService.GetTags()
+
+	buf, err := json.Marshal(result)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) getCart(writer http.ResponseWriter, request *http.Request) {
+	var tip models.Money
+
+	parsed, err := getOptionalMoneyParameter(request, "tip")
+	if err != nil {
+		r.sendErrorResponse(writer, request, err)
+
+		return
+	}
+
+	if parsed != nil {
+		tip = *parsed
+	}
+
+	cart, err := r.cartService.GetCartForAddress(request.Context(), request.URL.Query().Get("addressId"), tip)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("GetCart: %w", err))
+
+		return
+	}
+
+	buf, err := json.Marshal(cart)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) cleanupCart(writer http.ResponseWriter, request *http.Request) {
+	result, err := r.cartService.Cleanup(request.Context())
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("Cleanup: %w", err))
+
+		return
+	}
+
+	buf, err := json.Marshal(result)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) addToCart(writer http.ResponseWriter, request *http.Request) {
+	id := request.URL.Query().Get("id")
+	if id == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
+
+		return
+	}
+
+	amount, err := r.cartService.AddItem(request.Context(), id)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("AddToCart: %w", err))
+
+		return
+	}
+
+	response := CartQuantityResponse{Total: amount}
+
+	buf, err := json.Marshal(response)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) setCartItemQuantity(writer http.ResponseWriter, request *http.Request) {
+	id := request.PathValue("id")
+	if id == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
+
+		return
+	}
+
+	var requestBody models.CartQuantityRequest
+
+	err := json.NewDecoder(request.Body).Decode(&requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", errJsonDecode, err))
+
+		return
+	}
+
+	amount, err := r.cartService.SetQuantity(request.Context(), id, requestBody.Quantity)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("SetQuantity: %w", err))
+
+		return
+	}
+
+	buf, err := json.Marshal(CartQuantityResponse{Total: amount})
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) setCartItemComment(writer http.ResponseWriter, request *http.Request) {
+	id := request.PathValue("id")
+	if id == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
+
+		return
+	}
+
+	var requestBody models.CartCommentRequest
+
+	err := json.NewDecoder(request.Body).Decode(&requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", errJsonDecode, err))
+
+		return
+	}
+
+	result, err := r.cartService.SetItemComment(request.Context(), id, requestBody.Comment)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("SetItemComment: %w", err))
+
+		return
+	}
+
+	buf, err := json.Marshal(result)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) removeFromCart(writer http.ResponseWriter, request *http.Request) {
+	id := request.PathValue("id")
+	if id == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
+
+		return
+	}
+
+	amount, err := r.cartService.RemoveItem(request.Context(), id)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("RemoveItem: %w", err))
+
+		return
+	}
+
+	response := CartQuantityResponse{Total: amount}
+
+	buf, err := json.Marshal(response)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) addComboToCart(writer http.ResponseWriter, request *http.Request) {
+	id := request.PathValue("id")
+	if id == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
+
+		return
+	}
+
+	amount, err := r.cartService.AddCombo(request.Context(), id)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("AddCombo: %w", err))
+
+		return
+	}
+
+	buf, err := json.Marshal(CartQuantityResponse{Total: amount})
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) removeComboFromCart(writer http.ResponseWriter, request *http.Request) {
+	id := request.PathValue("id")
+	if id == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
+
+		return
+	}
+
+	amount, err := r.cartService.RemoveCombo(request.Context(), id)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("RemoveCombo: %w", err))
+
+		return
+	}
+
+	buf, err := json.Marshal(CartQuantityResponse{Total: amount})
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) getDeliveryInfo(writer http.ResponseWriter, request *http.Request) {
+	buf, err := json.Marshal(r.cartService.GetDeliveryInfo())
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) getOrderExtras(writer http.ResponseWriter, request *http.Request) {
+	buf, err := json.Marshal(r.orderExtras.ListExtras())
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) getOrders(writer http.ResponseWriter, request *http.Request) {
+	orders, err := r.orderService.GetOrders(request.Context())
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("GetOrders: %w", err))
+
+		return
+	}
+
+	buf, err := json.Marshal(orders)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) makeOrder(writer http.ResponseWriter, request *http.Request) {
+	var requestBody models.OrderRequest
+
+	err := json.NewDecoder(request.Body).Decode(&requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", errJsonDecode, err))
+
+		return
+	}
+
+	if requestBody.PIN == "" {
+		requestBody.PIN = request.Header.Get(models.WalletPINHeader)
+	}
+
+	err = r.orderService.MakeNewOrder(request.Context(), &requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("MakeNewOrder: %w", err))
+
+		return
+	}
+
+	writer.WriteHeader(http.StatusOK)
+}
+
+func (r *Router) previewCheckout(writer http.ResponseWriter, request *http.Request) {
+	var requestBody models.CheckoutPreviewRequest
+
+	err := json.NewDecoder(request.Body).Decode(&requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", errJsonDecode, err))
+
+		return
+	}
+
+	preview, err := r.checkoutService.Preview(request.Context(), requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("Preview: %w", err))
+
+		return
+	}
+
+	buf, err := json.Marshal(preview)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) createToken(writer http.ResponseWriter, request *http.Request) {
+	name := request.URL.Query().Get("name")
+	if name == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyName))
+
+		return
+	}
+
+	token, err := r.tokenService.GenerateToken(request.Context(), name, models.TokenRoleStudent)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("CreateToken: %w", err))
+
+		return
+	}
+
+	responseBody := TokenResponse{
+		Token: token,
+	}
+
+	buf, err := json.Marshal(responseBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) createTeacherToken(writer http.ResponseWriter, request *http.Request) {
+	name := request.URL.Query().Get("name")
+	if name == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyName))
+
+		return
+	}
+
+	token, err := r.tokenService.GenerateToken(request.Context(), name, models.TokenRoleTeacher)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("CreateToken: %w", err))
+
+		return
+	}
+
+	responseBody := TokenResponse{
+		Token: token,
+	}
+
+	buf, err := json.Marshal(responseBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) createCourierToken(writer http.ResponseWriter, request *http.Request) {
+	name := request.URL.Query().Get("name")
+	if name == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyName))
+
+		return
+	}
+
+	token, err := r.tokenService.GenerateToken(request.Context(), name, models.TokenRoleCourier)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("CreateToken: %w", err))
+
+		return
+	}
+
+	responseBody := TokenResponse{
+		Token: token,
+	}
+
+	buf, err := json.Marshal(responseBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) listTokens(writer http.ResponseWriter, request *http.Request) {
+	tokens, err := r.tokenService.ListTokens(request.Context())
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("ListTokens: %w", err))
+
+		return
+	}
+
+	buf, err := json.Marshal(tokens)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) whoAmI(writer http.ResponseWriter, request *http.Request) {
+	claims := models.ClaimsFromContext(request.Context())
+
+	response := WhoAmIResponse{
+		TokenID:   claims.ID,
+		Nickname:  claims.Nickname,
+		IsTeacher: claims.IsTeacher,
+		Role:      claims.GetRole(),
+		Issuer:    claims.Issuer,
+	}
+
+	if claims.IssuedAt != nil {
+		response.IssuedAt = &claims.IssuedAt.Time
+	}
+
+	if claims.ExpiresAt != nil {
+		response.ExpiresAt = &claims.ExpiresAt.Time
+	}
+
+	buf, err := json.Marshal(response)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+// getPagination разбирает page и pageSize одинаково для всех списков: pageSize больше максимума
+// уменьшается до него или отклоняется ошибкой с maxPageSize, в зависимости от настройки.
+func (r *Router) getPagination(request *http.Request) (int, int, error) {
+	page, err := getPaginationParameter(request, "page", 1)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: %w", models.ErrBadRequest, err)
+	}
+
+	pageSize, err := getPaginationParameter(request, "pageSize", models.DefaultPageSize)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: %w", models.ErrBadRequest, err)
+	}
+
+	if pageSize > r.pagination.MaxPageSize {
+		if r.pagination.Oversize == "reject" {
+			return 0, 0, &models.PageSizeError{PageSize: pageSize, MaxPageSize: r.pagination.MaxPageSize}
+		}
+
+		pageSize = r.pagination.MaxPageSize
+	}
+
+	// Смещение (page-1)*pageSize не должно переполниться
+	if page-1 > math.MaxInt32/pageSize {
+		return 0, 0, fmt.Errorf("%w: %w page: %d", models.ErrBadRequest, errInvalidPaginationParameter, page)
+	}
+
+	return page, pageSize, nil
+}
+
+func getPaginationParameter(request *http.Request, parameterName string, defaultValue int) (int, error) {
+	parameter := request.URL.Query().Get(parameterName)
+
+	if parameter == "" {
+		return defaultValue, nil
+	}
+
+	value, err := strconv.Atoi(parameter)
+	if err != nil {
+		return 0, fmt.Errorf("%w %s: %w", errInvalidPaginationParameter, parameterName, err)
+	}
+
+	if value <= 0 {
+		return 0, fmt.Errorf("%w %s: %d", errInvalidPaginationParameter, parameterName, value)
+	}
+
+	return value, nil
+}
+
+// getOptionalIntParameter разбирает необязательный числовой query-параметр, nil - параметра нет.
+func getOptionalIntParameter(request *http.Request, parameterName string) (*int, error) {
+	parameter := request.URL.Query().Get(parameterName)
+	if parameter == "" {
+		return nil, nil
+	}
+
+	value, err := strconv.Atoi(parameter)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid %s: %w", models.ErrBadRequest, parameterName, err)
+	}
+
+	return &value, nil
+}
+
+func getOptionalMoneyParameter(request *http.Request, parameterName string) (*models.Money, error) {
+	parameter := request.URL.Query().Get(parameterName)
+	if parameter == "" {
+		return nil, nil
+	}
+
+	value, err := models.ParseMoney(parameter)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid %s: %w", models.ErrBadRequest, parameterName, err)
+	}
+
+	return &value, nil
+}
+
+func getOptionalBoolParameter(request *http.Request, parameterName string) (*bool, error) {
+	parameter := request.URL.Query().Get(parameterName)
+	if parameter == "" {
+		return nil, nil
+	}
+
+	value, err := strconv.ParseBool(parameter)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid %s: %w", models.ErrBadRequest, parameterName, err)
+	}
+
+	return &value, nil
+}
+
+// getListParameter разбирает query-параметр со значениями через запятую.
+func getListParameter(request *http.Request, parameterName string) []string {
+	parameter := request.URL.Query().Get(parameterName)
+	if parameter == "" {
+		return nil
+	}
+
+	result := make([]string, 0)
+	for _, value := range strings.Split(parameter, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			result = append(result, value)
+		}
+	}
+
+	return result
+}
+
+// Wallet handlers
+func (r *Router) getWallet(writer http.ResponseWriter, request *http.Request) {
+	wallet, err := r.walletService.GetWallet(request.Context())
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("GetWallet: %w", err))
+		return
+	}
+
+	buf, err := json.Marshal(wallet)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) getTransactions(writer http.ResponseWriter, request *http.Request) {
+	page, pageSize, err := r.getPagination(request)
+	if err != nil {
+		r.sendErrorResponse(writer, request, err)
+		return
+	}
+
+	transactions, err := r.walletService.GetTransactions(request.Context(), page, pageSize)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("GetTransactions: %w", err))
+		return
+	}
+
+	buf, err := json.Marshal(transactions)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) getWalletAnalytics(writer http.ResponseWriter, request *http.Request) {
+	period := models.AnalyticsPeriod(request.URL.Query().Get("period"))
+
+	analytics, err := r.walletService.GetAnalytics(request.Context(), period)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("GetAnalytics: %w", err))
+		return
+	}
+
+	buf, err := json.Marshal(analytics)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) openAccount(writer http.ResponseWriter, request *http.Request) {
+	var requestBody models.OpenAccountRequest
+
+	err := json.NewDecoder(request.Body).Decode(&requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", errJsonDecode, err))
+		return
+	}
+
+	account, err := r.walletService.OpenAccount(request.Context(), requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("OpenAccount: %w", err))
+		return
+	}
+
+	buf, err := json.Marshal(account)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) updateAccount(writer http.ResponseWriter, request *http.Request) {
+	id := request.PathValue("id")
+	if id == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
+		return
+	}
+
+	var requestBody models.AccountPatch
+
+	err := json.NewDecoder(request.Body).Decode(&requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", errJsonDecode, err))
+		return
+	}
+
+	account, err := r.walletService.UpdateAccount(request.Context(), id, requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("UpdateAccount: %w", err))
+		return
+	}
+
+	buf, err := json.Marshal(account)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) getExchangeRate(writer http.ResponseWriter, request *http.Request) {
+	from := models.Currency(request.URL.Query().Get("from"))
+	to := models.Currency(request.URL.Query().Get("to"))
+
+	rate, err := r.walletService.GetRate(request.Context(), from, to)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("GetRate: %w", err))
+		return
+	}
+
+	buf, err := json.Marshal(rate)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) topupAccount(writer http.ResponseWriter, request *http.Request) {
+	var requestBody models.TopupRequest
+
+	err := json.NewDecoder(request.Body).Decode(&requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", errJsonDecode, err))
+		return
+	}
+
+	response, err := r.walletService.TopupAccount(request.Context(), requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("TopupAccount: %w", err))
+		return
+	}
+
+	buf, err := json.Marshal(response)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) getScheduledTopups(writer http.ResponseWriter, request *http.Request) {
+	result := r.scheduledTopups.GetTopups(request.Context())
+
+	buf, err := json.Marshal(result)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) createScheduledTopup(writer http.ResponseWriter, request *http.Request) {
+	var requestBody models.ScheduledTopupRequest
+
+	err := json.NewDecoder(request.Body).Decode(&requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", errJsonDecode, err))
+
+		return
+	}
+
+	result, err := r.scheduledTopups.Create(request.Context(), requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("Create: %w", err))
+
+		return
+	}
+
+	r.sendScheduledTopup(writer, request, result)
+}
+
+func (r *Router) cancelScheduledTopup(writer http.ResponseWriter, request *http.Request) {
+	id := request.PathValue("id")
+	if id == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
+
+		return
+	}
+
+	result, err := r.scheduledTopups.Cancel(request.Context(), id)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("Cancel: %w", err))
+
+		return
+	}
+
+	r.sendScheduledTopup(writer, request, result)
+}
+
+func (r *Router) sendScheduledTopup(writer http.ResponseWriter, request *http.Request, topup models.ScheduledTopup) {
+	buf, err := json.Marshal(topup)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) createExternalTopup(writer http.ResponseWriter, request *http.Request) {
+	var requestBody models.TopupRequest
+
+	err := json.NewDecoder(request.Body).Decode(&requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", errJsonDecode, err))
+		return
+	}
+
+	response, err := r.payments.CreateTopup(request.Context(), requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("CreateTopup: %w", err))
+		return
+	}
+
+	buf, err := json.Marshal(response)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) getPayment(writer http.ResponseWriter, request *http.Request) {
+	id := request.PathValue("id")
+	if id == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
+		return
+	}
+
+	response, err := r.payments.GetPayment(request.Context(), id)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("GetPayment: %w", err))
+		return
+	}
+
+	buf, err := json.Marshal(response)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+// paymentCallback принимает уведомление провайдера. Подпись считается от тела как есть,
+// поэтому тело читается целиком, а не декодируется.
+func (r *Router) paymentCallback(writer http.ResponseWriter, request *http.Request) {
+	body, err := io.ReadAll(request.Body)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: can't read body: %w", models.ErrBadRequest, err))
+		return
+	}
+
+	response, err := r.payments.HandleCallback(request.Context(), body, request.Header.Get("X-Payment-Signature"))
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("HandleCallback: %w", err))
+		return
+	}
+
+	buf, err := json.Marshal(response)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) completeSandboxPayment(writer http.ResponseWriter, request *http.Request) {
+	id := request.PathValue("id")
+	if id == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
+		return
+	}
+
+	status := models.PaymentStatus(request.URL.Query().Get("status"))
+
+	response, err := r.payments.CompleteSandboxPayment(request.Context(), id, status)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("CompleteSandboxPayment: %w", err))
+		return
+	}
+
+	buf, err := json.Marshal(response)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) transferMoney(writer http.ResponseWriter, request *http.Request) {
+	var requestBody models.TransferRequest
+
+	err := json.NewDecoder(request.Body).Decode(&requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", errJsonDecode, err))
+		return
+	}
+
+	if requestBody.PIN == "" {
+		requestBody.PIN = request.Header.Get(models.WalletPINHeader)
+	}
+
+	response, err := r.walletService.TransferMoney(request.Context(), requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("TransferMoney: %w", err))
+		return
+	}
+
+	buf, err := json.Marshal(response)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) getWalletContacts(writer http.ResponseWriter, request *http.Request) {
+	buf, err := json.Marshal(r.walletService.GetContacts(request.Context()))
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) saveWalletContact(writer http.ResponseWriter, request *http.Request) {
+	var requestBody models.SaveWalletContactRequest
+
+	err := json.NewDecoder(request.Body).Decode(&requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", errJsonDecode, err))
+		return
+	}
+
+	contact, err := r.walletService.SaveContact(request.Context(), requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("SaveContact: %w", err))
+		return
+	}
+
+	buf, err := json.Marshal(contact)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) deleteWalletContact(writer http.ResponseWriter, request *http.Request) {
+	id := request.PathValue("id")
+	if id == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
+		return
+	}
+
+	err := r.walletService.DeleteContact(request.Context(), id)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("DeleteContact: %w", err))
+		return
+	}
+
+	writer.WriteHeader(http.StatusOK)
+}
+
+func (r *Router) getWalletPIN(writer http.ResponseWriter, request *http.Request) {
+	buf, err := json.Marshal(r.walletPINs.GetStatus(request.Context()))
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) setWalletPIN(writer http.ResponseWriter, request *http.Request) {
+	var requestBody models.WalletPINRequest
+
+	err := json.NewDecoder(request.Body).Decode(&requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", errJsonDecode, err))
+		return
+	}
+
+	status, err := r.walletPINs.SetPIN(request.Context(), requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("SetPIN: %w", err))
+		return
+	}
+
+	buf, err := json.Marshal(status)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) requestWalletPINReset(writer http.ResponseWriter, request *http.Request) {
+	response, err := r.walletPINs.RequestReset(request.Context())
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("RequestReset: %w", err))
+		return
+	}
+
+	buf, err := json.Marshal(response)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) confirmWalletPINReset(writer http.ResponseWriter, request *http.Request) {
+	var requestBody models.WalletPINResetConfirmRequest
+
+	err := json.NewDecoder(request.Body).Decode(&requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", errJsonDecode, err))
+		return
+	}
+
+	status, err := r.walletPINs.ConfirmReset(request.Context(), requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("ConfirmReset: %w", err))
+		return
+	}
+
+	buf, err := json.Marshal(status)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) exportData(writer http.ResponseWriter, request *http.Request) {
+	exportRequest := models.ExportRequest{
+		Entities: getListParameter(request, "entities"),
+		Format:   models.ExportFormat(request.URL.Query().Get("format")),
+	}
+
+	if err := r.exportService.Validate(&exportRequest); err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("Export: %w", err))
+
+		return
+	}
+
+	fileName := fmt.Sprintf("export-%s.zip", time.Now().Format("2006-01-02_15-04-05"))
+
+	writer.Header().Set("Content-Type", "application/zip")
+	writer.Header().Set("Content-Disposition", `attachment; filename="`+fileName+`"`)
+	writer.WriteHeader(http.StatusOK)
+
+	// Заголовки уже отправлены, поэтому ошибку можно только залогировать
+	if err := r.exportService.WriteArchive(request.Context(), writer, exportRequest); err != nil {
+		r.logger.With(
+			"module", "api",
+			"request_url", request.Method+": "+request.URL.Path,
+		).Errorf("Error writing export archive: %v", err)
+	}
+}
+
+func (r *Router) exportOrders(writer http.ResponseWriter, request *http.Request) {
+	query := request.URL.Query()
+
+	from, err := parseExportTime(query.Get("from"), false)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: invalid from: %w", models.ErrBadRequest, err))
+
+		return
+	}
+
+	to, err := parseExportTime(query.Get("to"), true)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: invalid to: %w", models.ErrBadRequest, err))
+
+		return
+	}
+
+	exportRequest := models.OrderExportRequest{
+		From:   from,
+		To:     to,
+		Format: models.ExportFormat(query.Get("format")),
+	}
+
+	if err := r.exportService.ValidateOrders(&exportRequest); err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("ExportOrders: %w", err))
+
+		return
+	}
+
+	contentType := "text/csv; charset=utf-8"
+	if exportRequest.Format == models.ExportFormatJSON {
+		contentType = "application/json"
+	}
+
+	// Конец периода не входит в выгрузку, поэтому в имени файла последний выгруженный день
+	first := "start"
+	if !exportRequest.From.IsZero() {
+		first = exportRequest.From.Format(time.DateOnly)
+	}
+
+	fileName := fmt.Sprintf(
+		"orders-%s_%s.%s",
+		first,
+		exportRequest.To.Add(-time.Nanosecond).Format(time.DateOnly),
+		exportRequest.Format,
+	)
+
+	writer.Header().Set("Content-Type", contentType)
+	writer.Header().Set("Content-Disposition", `attachment; filename="`+fileName+`"`)
+	writer.WriteHeader(http.StatusOK)
+
+	// Заголовки уже отправлены, поэтому ошибку можно только залогировать
+	if err := r.exportService.WriteOrders(request.Context(), writer, exportRequest); err != nil {
+		r.logger.With(
+			"module", "api",
+			"request_url", request.Method+": "+request.URL.Path,
+		).Errorf("Error writing orders export: %v", err)
+	}
+}
+
+// parseExportTime разбирает границу периода: время RFC 3339 или дату. Дата в конце периода
+// включает весь день.
+func parseExportTime(value string, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if parsed, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return parsed, nil
+	}
+
+	parsed, err := time.ParseInLocation(time.DateOnly, value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected RFC 3339 time or date YYYY-MM-DD: %q", value)
+	}
+
+	if endOfDay {
+		parsed = parsed.AddDate(0, 0, 1)
+	}
+
+	return parsed, nil
+}
+
+func (r *Router) exportAccount(writer http.ResponseWriter, request *http.Request) {
+	format := request.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+
+	if format != "json" && format != "zip" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: unknown format %q, use json or zip", models.ErrBadRequest, format))
+
+		return
+	}
+
+	export, err := r.accountExport.ExportAccount(request.Context())
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("ExportAccount: %w", err))
+
+		return
+	}
+
+	fileName := fmt.Sprintf("account-%s.%s", export.ExportedAt.Format("2006-01-02_15-04-05"), format)
+
+	if format == "json" {
+		buf, err := json.Marshal(export)
+		if err != nil {
+			r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+			return
+		}
+
+		writer.Header().Set("Content-Disposition", `attachment; filename="`+fileName+`"`)
+		r.sendResponse(writer, request, http.StatusOK, buf)
+
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/zip")
+	writer.Header().Set("Content-Disposition", `attachment; filename="`+fileName+`"`)
+	writer.WriteHeader(http.StatusOK)
+
+	// Данные уже собраны, поэтому здесь может сломаться только запись в соединение
+	if err := r.accountExport.WriteArchive(request.Context(), writer, export); err != nil {
+		r.logger.With(
+			"module", "api",
+			"request_url", request.Method+": "+request.URL.Path,
+		).Errorf("Error writing account archive: %v", err)
+	}
+}
+
+func (r *Router) setAvailability(writer http.ResponseWriter, request *http.Request) {
+	id := request.PathValue("id")
+	if id == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
+
+		return
+	}
+
+	var requestBody models.AvailabilityRequest
+
+	err := json.NewDecoder(request.Body).Decode(&requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", errJsonDecode, err))
+
+		return
+	}
+
+	result, err := r.productsService.SetAvailability(request.Context(), id, requestBody.Available)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("SetAvailability: %w", err))
+
+		return
+	}
+
+	buf, err := json.Marshal(result)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) getCatalogVersions(writer http.ResponseWriter, request *http.Request) {
+	buf, err := json.Marshal(r.productsService.ListCatalogVersions(request.Context()))
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) rollbackCatalog(writer http.ResponseWriter, request *http.Request) {
+	version, err := strconv.Atoi(request.PathValue("version"))
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: version must be a number", models.ErrBadRequest))
+
+		return
+	}
+
+	result, err := r.productsService.RollbackCatalog(request.Context(), version)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("RollbackCatalog: %w", err))
+
+		return
+	}
+
+	buf, err := json.Marshal(result)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) getBlockedOperations(writer http.ResponseWriter, request *http.Request) {
+	status := models.BlockedOperationStatus(request.URL.Query().Get("status"))
+
+	switch status {
+	case "", models.BlockedOperationPending, models.BlockedOperationApproved, models.BlockedOperationCompleted:
+	default:
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: unknown status %s", models.ErrBadRequest, status))
+
+		return
+	}
+
+	result := r.fraudReview.GetBlocked(request.Context(), status)
+
+	buf, err := json.Marshal(result)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) getIcons(writer http.ResponseWriter, request *http.Request) {
+	result := r.icons.GetIcons(request.Context())
+
+	buf, err := json.Marshal(result)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) refundOrder(writer http.ResponseWriter, request *http.Request) {
+	id := request.PathValue("id")
+	if id == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
+
+		return
+	}
+
+	var requestBody models.RefundRequest
+
+	err := json.NewDecoder(request.Body).Decode(&requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", errJsonDecode, err))
+
+		return
+	}
+
+	result, err := r.refunds.RefundOrder(request.Context(), id, requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("RefundOrder: %w", err))
+
+		return
+	}
+
+	buf, err := json.Marshal(result)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) getOrderTimeline(writer http.ResponseWriter, request *http.Request) {
+	id := request.PathValue("id")
+	if id == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
+
+		return
+	}
+
+	result, err := r.orderService.GetOrderTimeline(request.Context(), id)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("GetOrderTimeline: %w", err))
+
+		return
+	}
+
+	buf, err := json.Marshal(result)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) delayOrder(writer http.ResponseWriter, request *http.Request) {
+	id := request.PathValue("id")
+	if id == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
+
+		return
+	}
+
+	var requestBody models.OrderDelayRequest
+
+	err := json.NewDecoder(request.Body).Decode(&requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", errJsonDecode, err))
+
+		return
+	}
+
+	result, err := r.orderService.DelayOrder(request.Context(), id, requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("DelayOrder: %w", err))
+
+		return
+	}
+
+	buf, err := json.Marshal(result)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) getCourierOrders(writer http.ResponseWriter, request *http.Request) {
+	orders, err := r.couriers.GetOrders(request.Context())
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("GetCourierOrders: %w", err))
+
+		return
+	}
+
+	buf, err := json.Marshal(orders)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) acceptCourierOrder(writer http.ResponseWriter, request *http.Request) {
+	id := request.PathValue("id")
+	if id == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
+
+		return
+	}
+
+	order, err := r.couriers.AcceptOrder(request.Context(), id)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("AcceptOrder: %w", err))
+
+		return
+	}
+
+	buf, err := json.Marshal(order)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) declineCourierOrder(writer http.ResponseWriter, request *http.Request) {
+	id := request.PathValue("id")
+	if id == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
+
+		return
+	}
+
+	if err := r.couriers.DeclineOrder(request.Context(), id); err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("DeclineOrder: %w", err))
+
+		return
+	}
+
+	writer.WriteHeader(http.StatusOK)
+}
+
+func (r *Router) updateDeliveryStatus(writer http.ResponseWriter, request *http.Request) {
+	id := request.PathValue("id")
+	if id == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
+
+		return
+	}
+
+	var requestBody models.DeliveryStatusRequest
+
+	err := json.NewDecoder(request.Body).Decode(&requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", errJsonDecode, err))
+
+		return
+	}
+
+	order, err := r.couriers.UpdateStatus(request.Context(), id, requestBody.Status)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("UpdateDeliveryStatus: %w", err))
+
+		return
+	}
+
+	buf, err := json.Marshal(order)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) setDeliveryPhoto(writer http.ResponseWriter, request *http.Request) {
+	id := request.PathValue("id")
+	if id == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
+
+		return
+	}
+
+	var requestBody models.DeliveryPhotoRequest
+
+	err := json.NewDecoder(request.Body).Decode(&requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", errJsonDecode, err))
+
+		return
+	}
+
+	order, err := r.couriers.SetPhoto(request.Context(), id, requestBody.Photo)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("SetDeliveryPhoto: %w", err))
+
+		return
+	}
+
+	buf, err := json.Marshal(order)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) setIcon(writer http.ResponseWriter, request *http.Request) {
+	kind := request.PathValue("kind")
+	if kind == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
+
+		return
+	}
+
+	var requestBody models.IconRequest
+
+	err := json.NewDecoder(request.Body).Decode(&requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", errJsonDecode, err))
+
+		return
+	}
+
+	result, err := r.icons.SetIcon(request.Context(), models.IconKind(kind), requestBody.Icon)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("SetIcon: %w", err))
+
+		return
+	}
+
+	buf, err := json.Marshal(result)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) getBackupStatus(writer http.ResponseWriter, request *http.Request) {
+	result := r.backups.GetStatus(request.Context())
+
+	buf, err := json.Marshal(result)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) backupNow(writer http.ResponseWriter, request *http.Request) {
+	result, err := r.backups.BackupNow(request.Context())
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("BackupNow: %w", err))
+
+		return
+	}
+
+	buf, err := json.Marshal(result)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) listBackups(writer http.ResponseWriter, request *http.Request) {
+	result, err := r.backups.ListSnapshots(request.Context())
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("ListSnapshots: %w", err))
+
+		return
+	}
+
+	buf, err := json.Marshal(result)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) restoreBackup(writer http.ResponseWriter, request *http.Request) {
+	snapshot := request.URL.Query().Get("snapshot")
+	if snapshot == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: snapshot is required", models.ErrBadRequest))
+
+		return
+	}
+
+	result, err := r.backups.Restore(request.Context(), snapshot)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("Restore: %w", err))
+
+		return
+	}
+
+	buf, err := json.Marshal(result)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) getStats(writer http.ResponseWriter, request *http.Request) {
+	days := models.DefaultStatsDays
+
+	if value := request.URL.Query().Get("days"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			r.sendErrorResponse(writer, request, fmt.Errorf("%w: invalid days: %w", models.ErrBadRequest, err))
+
+			return
+		}
+
+		days = parsed
+	}
+
+	result, err := r.stats.GetStats(request.Context(), days)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("GetStats: %w", err))
+
+		return
+	}
+
+	buf, err := json.Marshal(result)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) approveBlockedOperation(writer http.ResponseWriter, request *http.Request) {
+	id := request.PathValue("id")
+	if id == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
+
+		return
+	}
+
+	result, err := r.fraudReview.Approve(request.Context(), id)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("Approve: %w", err))
+
+		return
+	}
+
+	buf, err := json.Marshal(result)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) resetUser(writer http.ResponseWriter, request *http.Request) {
+	id := request.PathValue("id")
+	if id == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
+
+		return
+	}
+
+	err := r.resetService.ResetUser(request.Context(), id)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("ResetUser: %w", err))
+
+		return
+	}
+
+	writer.WriteHeader(http.StatusOK)
+}
+
+func (r *Router) getChaosRules(writer http.ResponseWriter, request *http.Request) {
+	result := r.chaosService.GetRules(request.Context())
+
+	buf, err := json.Marshal(result)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) addChaosRule(writer http.ResponseWriter, request *http.Request) {
+	var requestBody models.ChaosRule
+
+	err := json.NewDecoder(request.Body).Decode(&requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", errJsonDecode, err))
+
+		return
+	}
+
+	rule, err := r.chaosService.AddRule(request.Context(), requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("AddRule: %w", err))
+
+		return
+	}
+
+	buf, err := json.Marshal(rule)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) removeChaosRule(writer http.ResponseWriter, request *http.Request) {
+	id := request.PathValue("id")
+	if id == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
+
+		return
+	}
+
+	err := r.chaosService.RemoveRule(request.Context(), id)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("RemoveRule: %w", err))
+
+		return
+	}
+
+	writer.WriteHeader(http.StatusOK)
+}
+
+func (r *Router) listRecordings(writer http.ResponseWriter, request *http.Request) {
+	buf, err := json.Marshal(r.recordings.ListSessions(request.Context()))
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) downloadRecording(writer http.ResponseWriter, request *http.Request) {
+	id := request.PathValue("id")
+	if id == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
+
+		return
+	}
+
+	session, err := r.recordings.GetSession(request.Context(), id)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("GetSession: %w", err))
+
+		return
+	}
+
+	buf, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	fileName := fmt.Sprintf("recording-%s-%s.json", id, time.Now().Format("2006-01-02_15-04-05"))
+
+	writer.Header().Set("Content-Disposition", `attachment; filename="`+fileName+`"`)
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) clearRecording(writer http.ResponseWriter, request *http.Request) {
+	id := request.PathValue("id")
+	if id == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
+
+		return
+	}
+
+	err := r.recordings.ClearSession(request.Context(), id)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("ClearSession: %w", err))
+
+		return
+	}
+
+	writer.WriteHeader(http.StatusOK)
+}
+
+func (r *Router) listBlockedUsers(writer http.ResponseWriter, request *http.Request) {
+	buf, err := json.Marshal(r.suspensions.ListSuspensions(request.Context()))
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
 
 		return
 	}
 
-	writer.WriteHeader(http.StatusOK)
+	r.sendResponse(writer, request, http.StatusOK, buf)
 }
 
-func (r *Router) deleteFavourite(writer http.ResponseWriter, request *http.Request) {
+func (r *Router) getUserUsage(writer http.ResponseWriter, request *http.Request) {
 	id := request.PathValue("id")
 	if id == "" {
 		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
@@ -386,25 +4182,14 @@ func (r *Router) deleteFavourite(writer http.ResponseWriter, request *http.Reque
 		return
 	}
 
-	err := r.productsService.RemoveFavourite(request.Context(), id)
-	if err != nil {
-		r.sendErrorResponse(writer, request, fmt.Errorf("RemoveFavourite: %w", err))
-
-		return
-	}
-
-	writer.WriteHeader(http.StatusOK)
-}
-
-func (r *Router) getUser(writer http.ResponseWriter, request *http.Request) {
-	result, err := r.userData.GetProfile(request.Context())
+	usage, err := r.usage.GetUserUsage(request.Context(), id)
 	if err != nil {
-		r.sendErrorResponse(writer, request, fmt.Errorf("GetProfile: %w", err))
+		r.sendErrorResponse(writer, request, fmt.Errorf("GetUserUsage: %w", err))
 
 		return
 	}
 
-	buf, err := json.Marshal(result)
+	buf, err := json.Marshal(usage)
 	if err != nil {
 		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
 
@@ -414,19 +4199,15 @@ func (r *Router) getUser(writer http.ResponseWriter, request *http.Request) {
 	r.sendResponse(writer, request, http.StatusOK, buf)
 }
 
-func (r *Router) deleteUser(writer http.ResponseWriter, request *http.Request) {
-	err := r.userData.DeleteProfile(request.Context())
-	if err != nil {
-		r.sendErrorResponse(writer, request, fmt.Errorf("DeleteProfile: %w", err))
+func (r *Router) blockUser(writer http.ResponseWriter, request *http.Request) {
+	id := request.PathValue("id")
+	if id == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
 
 		return
 	}
 
-	writer.WriteHeader(http.StatusOK)
-}
-
-func (r *Router) updateProfile(writer http.ResponseWriter, request *http.Request) {
-	var requestBody models.UpdateUserRequest
+	var requestBody models.BlockUserRequest
 
 	err := json.NewDecoder(request.Body).Decode(&requestBody)
 	if err != nil {
@@ -435,24 +4216,14 @@ func (r *Router) updateProfile(writer http.ResponseWriter, request *http.Request
 		return
 	}
 
-	err = r.userData.UpdateProfile(request.Context(), requestBody)
+	suspension, err := r.suspensions.BlockUser(request.Context(), id, requestBody)
 	if err != nil {
-		r.sendErrorResponse(writer, request, fmt.Errorf("UpdateProfile: %w", err))
+		r.sendErrorResponse(writer, request, fmt.Errorf("BlockUser: %w", err))
 
 		return
 	}
 
-	writer.WriteHeader(http.StatusOK)
-}
-
-func (r *Router) logout(writer http.ResponseWriter, _ *http.Request) {
-	writer.WriteHeader(http.StatusOK)
-}
-
-func (r *Router) getAddresses(writer http.ResponseWriter, request *http.Request) {
-	addresses := r.addressService.GetAddresses(request.Context())
-
-	buf, err := json.Marshal(addresses)
+	buf, err := json.Marshal(suspension)
 	if err != nil {
 		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
 
@@ -462,27 +4233,36 @@ func (r *Router) getAddresses(writer http.ResponseWriter, request *http.Request)
 	r.sendResponse(writer, request, http.StatusOK, buf)
 }
 
-func (r *Router) addAddress(writer http.ResponseWriter, request *http.Request) {
-	var requestBody models.Address
+func (r *Router) unblockUser(writer http.ResponseWriter, request *http.Request) {
+	id := request.PathValue("id")
+	if id == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
 
-	err := json.NewDecoder(request.Body).Decode(&requestBody)
+		return
+	}
+
+	err := r.suspensions.UnblockUser(request.Context(), id)
 	if err != nil {
-		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", errJsonDecode, err))
+		r.sendErrorResponse(writer, request, fmt.Errorf("UnblockUser: %w", err))
 
 		return
 	}
 
-	err = r.addressService.AddAddress(request.Context(), &requestBody)
+	writer.WriteHeader(http.StatusOK)
+}
+
+func (r *Router) listZones(writer http.ResponseWriter, request *http.Request) {
+	buf, err := json.Marshal(r.zones.ListZones(request.Context()))
 	if err != nil {
-		r.sendErrorResponse(writer, request, fmt.Errorf("AddAddress: %w", err))
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
 
 		return
 	}
 
-	writer.WriteHeader(http.StatusOK)
+	r.sendResponse(writer, request, http.StatusOK, buf)
 }
 
-func (r *Router) updateAddress(writer http.ResponseWriter, request *http.Request) {
+func (r *Router) setZoneAssortment(writer http.ResponseWriter, request *http.Request) {
 	id := request.PathValue("id")
 	if id == "" {
 		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
@@ -490,7 +4270,7 @@ func (r *Router) updateAddress(writer http.ResponseWriter, request *http.Request
 		return
 	}
 
-	var requestBody models.Address
+	var requestBody models.ZoneAssortmentRequest
 
 	err := json.NewDecoder(request.Body).Decode(&requestBody)
 	if err != nil {
@@ -499,19 +4279,35 @@ func (r *Router) updateAddress(writer http.ResponseWriter, request *http.Request
 		return
 	}
 
-	requestBody.ID = id
+	zone, err := r.zones.SetAssortment(request.Context(), id, requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("SetAssortment: %w", err))
 
-	err = r.addressService.UpdateAddress(request.Context(), &requestBody)
+		return
+	}
+
+	buf, err := json.Marshal(zone)
 	if err != nil {
-		r.sendErrorResponse(writer, request, fmt.Errorf("UpdateAddress: %w", err))
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
 
 		return
 	}
 
-	writer.WriteHeader(http.StatusOK)
+	r.sendResponse(writer, request, http.StatusOK, buf)
 }
 
-func (r *Router) deleteAddress(writer http.ResponseWriter, request *http.Request) {
+func (r *Router) listCollections(writer http.ResponseWriter, request *http.Request) {
+	buf, err := json.Marshal(r.collections.ListCollections(request.Context()))
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) setCollection(writer http.ResponseWriter, request *http.Request) {
 	id := request.PathValue("id")
 	if id == "" {
 		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
@@ -519,20 +4315,23 @@ func (r *Router) deleteAddress(writer http.ResponseWriter, request *http.Request
 		return
 	}
 
-	err := r.addressService.RemoveAddress(request.Context(), id)
+	var requestBody models.CollectionRequest
+
+	err := json.NewDecoder(request.Body).Decode(&requestBody)
 	if err != nil {
-		r.sendErrorResponse(writer, request, fmt.Errorf("RemoveAddress: %w", err))
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", errJsonDecode, err))
 
 		return
 	}
 
-	writer.WriteHeader(http.StatusOK)
-}
+	collection, err := r.collections.SetCollection(request.Context(), id, requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("SetCollection: %w", err))
 
-func (r *Router) getCategories(writer http.ResponseWriter, request *http.Request) {
-	result := r.productsService.GetCategories()
+		return
+	}
 
-	buf, err := json.Marshal(result)
+	buf, err := json.Marshal(collection)
 	if err != nil {
 		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
 
@@ -542,15 +4341,8 @@ func (r *Router) getCategories(writer http.ResponseWriter, request *http.Request
 	r.sendResponse(writer, request, http.StatusOK, buf)
 }
 
-func (r *Router) getCart(writer http.ResponseWriter, request *http.Request) {
-	cart, err := r.cartService.GetCart(request.Context())
-	if err != nil {
-		r.sendErrorResponse(writer, request, fmt.Errorf("GetCart: %w", err))
-
-		return
-	}
-
-	buf, err := json.Marshal(cart)
+func (r *Router) getClock(writer http.ResponseWriter, request *http.Request) {
+	buf, err := json.Marshal(r.clock.GetClock(request.Context()))
 	if err != nil {
 		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
 
@@ -560,26 +4352,24 @@ func (r *Router) getCart(writer http.ResponseWriter, request *http.Request) {
 	r.sendResponse(writer, request, http.StatusOK, buf)
 }
 
-func (r *Router) addToCart(writer http.ResponseWriter, request *http.Request) {
-	id := request.URL.Query().Get("id")
-	if id == "" {
-		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
+func (r *Router) advanceClock(writer http.ResponseWriter, request *http.Request) {
+	var requestBody models.AdvanceClockRequest
+
+	err := json.NewDecoder(request.Body).Decode(&requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", errJsonDecode, err))
 
 		return
 	}
 
-	amount, err := r.cartService.AddItem(request.Context(), id)
+	state, err := r.clock.AdvanceClock(request.Context(), requestBody)
 	if err != nil {
-		r.sendErrorResponse(writer, request, fmt.Errorf("AddToCart: %w", err))
+		r.sendErrorResponse(writer, request, fmt.Errorf("AdvanceClock: %w", err))
 
 		return
 	}
 
-	response := map[string]any{
-		"total": amount,
-	}
-
-	buf, err := json.Marshal(response)
+	buf, err := json.Marshal(state)
 	if err != nil {
 		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
 
@@ -589,7 +4379,7 @@ func (r *Router) addToCart(writer http.ResponseWriter, request *http.Request) {
 	r.sendResponse(writer, request, http.StatusOK, buf)
 }
 
-func (r *Router) removeFromCart(writer http.ResponseWriter, request *http.Request) {
+func (r *Router) deleteCollection(writer http.ResponseWriter, request *http.Request) {
 	id := request.PathValue("id")
 	if id == "" {
 		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
@@ -597,18 +4387,18 @@ func (r *Router) removeFromCart(writer http.ResponseWriter, request *http.Reques
 		return
 	}
 
-	amount, err := r.cartService.RemoveItem(request.Context(), id)
+	err := r.collections.DeleteCollection(request.Context(), id)
 	if err != nil {
-		r.sendErrorResponse(writer, request, fmt.Errorf("RemoveItem: %w", err))
+		r.sendErrorResponse(writer, request, fmt.Errorf("DeleteCollection: %w", err))
 
 		return
 	}
 
-	response := map[string]any{
-		"total": amount,
-	}
+	writer.WriteHeader(http.StatusOK)
+}
 
-	buf, err := json.Marshal(response)
+func (r *Router) getExperimentAssignments(writer http.ResponseWriter, request *http.Request) {
+	buf, err := json.Marshal(r.experiments.GetAssignments(request.Context()))
 	if err != nil {
 		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
 
@@ -618,15 +4408,8 @@ func (r *Router) removeFromCart(writer http.ResponseWriter, request *http.Reques
 	r.sendResponse(writer, request, http.StatusOK, buf)
 }
 
-func (r *Router) getOrders(writer http.ResponseWriter, request *http.Request) {
-	orders, err := r.orderService.GetOrders(request.Context())
-	if err != nil {
-		r.sendErrorResponse(writer, request, fmt.Errorf("GetOrders: %w", err))
-
-		return
-	}
-
-	buf, err := json.Marshal(orders)
+func (r *Router) listExperiments(writer http.ResponseWriter, request *http.Request) {
+	buf, err := json.Marshal(r.experiments.ListExperiments(request.Context()))
 	if err != nil {
 		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
 
@@ -636,8 +4419,15 @@ func (r *Router) getOrders(writer http.ResponseWriter, request *http.Request) {
 	r.sendResponse(writer, request, http.StatusOK, buf)
 }
 
-func (r *Router) makeOrder(writer http.ResponseWriter, request *http.Request) {
-	var requestBody models.OrderRequest
+func (r *Router) putExperiment(writer http.ResponseWriter, request *http.Request) {
+	key := request.PathValue("key")
+	if key == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
+
+		return
+	}
+
+	var requestBody models.ExperimentRequest
 
 	err := json.NewDecoder(request.Body).Decode(&requestBody)
 	if err != nil {
@@ -646,36 +4436,43 @@ func (r *Router) makeOrder(writer http.ResponseWriter, request *http.Request) {
 		return
 	}
 
-	err = r.orderService.MakeNewOrder(request.Context(), &requestBody)
+	experiment, err := r.experiments.PutExperiment(request.Context(), key, requestBody)
 	if err != nil {
-		r.sendErrorResponse(writer, request, fmt.Errorf("MakeNewOrder: %w", err))
+		r.sendErrorResponse(writer, request, fmt.Errorf("PutExperiment: %w", err))
 
 		return
 	}
 
-	writer.WriteHeader(http.StatusOK)
+	buf, err := json.Marshal(experiment)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
+		return
+	}
+
+	r.sendResponse(writer, request, http.StatusOK, buf)
 }
 
-func (r *Router) createToken(writer http.ResponseWriter, request *http.Request) {
-	name := request.URL.Query().Get("name")
-	if name == "" {
-		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyName))
+func (r *Router) deleteExperiment(writer http.ResponseWriter, request *http.Request) {
+	key := request.PathValue("key")
+	if key == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
 
 		return
 	}
 
-	token, err := r.tokenService.GenerateToken(request.Context(), name, false)
+	err := r.experiments.DeleteExperiment(request.Context(), key)
 	if err != nil {
-		r.sendErrorResponse(writer, request, fmt.Errorf("CreateToken: %w", err))
+		r.sendErrorResponse(writer, request, fmt.Errorf("DeleteExperiment: %w", err))
 
 		return
 	}
 
-	responseBody := TokenResponse{
-		Token: token,
-	}
+	writer.WriteHeader(http.StatusOK)
+}
 
-	buf, err := json.Marshal(responseBody)
+func (r *Router) listWebhooks(writer http.ResponseWriter, request *http.Request) {
+	buf, err := json.Marshal(r.webhooks.ListWebhooks(request.Context()))
 	if err != nil {
 		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
 
@@ -685,26 +4482,24 @@ func (r *Router) createToken(writer http.ResponseWriter, request *http.Request)
 	r.sendResponse(writer, request, http.StatusOK, buf)
 }
 
-func (r *Router) createTeacherToken(writer http.ResponseWriter, request *http.Request) {
-	name := request.URL.Query().Get("name")
-	if name == "" {
-		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyName))
+func (r *Router) createWebhook(writer http.ResponseWriter, request *http.Request) {
+	var requestBody models.WebhookRequest
+
+	err := json.NewDecoder(request.Body).Decode(&requestBody)
+	if err != nil {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", errJsonDecode, err))
 
 		return
 	}
 
-	token, err := r.tokenService.GenerateToken(request.Context(), name, true)
+	webhook, err := r.webhooks.CreateWebhook(request.Context(), requestBody)
 	if err != nil {
-		r.sendErrorResponse(writer, request, fmt.Errorf("CreateToken: %w", err))
+		r.sendErrorResponse(writer, request, fmt.Errorf("CreateWebhook: %w", err))
 
 		return
 	}
 
-	responseBody := TokenResponse{
-		Token: token,
-	}
-
-	buf, err := json.Marshal(responseBody)
+	buf, err := json.Marshal(webhook)
 	if err != nil {
 		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
 
@@ -714,112 +4509,135 @@ func (r *Router) createTeacherToken(writer http.ResponseWriter, request *http.Re
 	r.sendResponse(writer, request, http.StatusOK, buf)
 }
 
-func getPaginationParameter(request *http.Request, parameterName string, defaultValue int) (int, error) {
-	parameter := request.URL.Query().Get(parameterName)
+func (r *Router) deleteWebhook(writer http.ResponseWriter, request *http.Request) {
+	id := request.PathValue("id")
+	if id == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
 
-	if parameter == "" {
-		return defaultValue, nil
+		return
 	}
 
-	value, err := strconv.Atoi(parameter)
+	err := r.webhooks.DeleteWebhook(request.Context(), id)
 	if err != nil {
-		return 0, fmt.Errorf("%w %s: %w", errInvalidPaginationParameter, parameterName, err)
-	}
+		r.sendErrorResponse(writer, request, fmt.Errorf("DeleteWebhook: %w", err))
 
-	if value <= 0 {
-		return 0, fmt.Errorf("%w %s: %d", errInvalidPaginationParameter, parameterName, value)
+		return
 	}
 
-	return value, nil
+	writer.WriteHeader(http.StatusOK)
 }
 
-// Wallet handlers
-func (r *Router) getWallet(writer http.ResponseWriter, request *http.Request) {
-	wallet, err := r.walletService.GetWallet(request.Context())
+func (r *Router) getWebhookDeliveries(writer http.ResponseWriter, request *http.Request) {
+	id := request.PathValue("id")
+	if id == "" {
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, errEmptyID))
+
+		return
+	}
+
+	deliveries, err := r.webhooks.GetDeliveries(request.Context(), id)
 	if err != nil {
-		r.sendErrorResponse(writer, request, fmt.Errorf("GetWallet: %w", err))
+		r.sendErrorResponse(writer, request, fmt.Errorf("GetDeliveries: %w", err))
+
 		return
 	}
 
-	buf, err := json.Marshal(wallet)
+	buf, err := json.Marshal(deliveries)
 	if err != nil {
 		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
 		return
 	}
 
 	r.sendResponse(writer, request, http.StatusOK, buf)
 }
 
-func (r *Router) getTransactions(writer http.ResponseWriter, request *http.Request) {
-	page, err := getPaginationParameter(request, "page", 1)
-	if err != nil {
-		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, err))
-		return
-	}
-
-	pageSize, err := getPaginationParameter(request, "pageSize", models.DefaultPageSize)
-	if err != nil {
-		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrBadRequest, err))
-		return
-	}
+func (r *Router) clearChaosRules(writer http.ResponseWriter, request *http.Request) {
+	r.chaosService.ClearRules(request.Context())
 
-	transactions, err := r.walletService.GetTransactions(request.Context(), page, pageSize)
-	if err != nil {
-		r.sendErrorResponse(writer, request, fmt.Errorf("GetTransactions: %w", err))
-		return
-	}
+	writer.WriteHeader(http.StatusOK)
+}
 
-	buf, err := json.Marshal(transactions)
+func (r *Router) getLogLevels(writer http.ResponseWriter, request *http.Request) {
+	buf, err := json.Marshal(r.logLevels.GetLevels())
 	if err != nil {
 		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
 		return
 	}
 
 	r.sendResponse(writer, request, http.StatusOK, buf)
 }
 
-func (r *Router) topupAccount(writer http.ResponseWriter, request *http.Request) {
-	var requestBody models.TopupRequest
+func (r *Router) setLogLevels(writer http.ResponseWriter, request *http.Request) {
+	var requestBody models.LogLevelRequest
 
 	err := json.NewDecoder(request.Body).Decode(&requestBody)
 	if err != nil {
 		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", errJsonDecode, err))
+
 		return
 	}
 
-	response, err := r.walletService.TopupAccount(request.Context(), requestBody)
+	levels, err := r.logLevels.SetLevels(requestBody)
 	if err != nil {
-		r.sendErrorResponse(writer, request, fmt.Errorf("TopupAccount: %w", err))
+		r.sendErrorResponse(writer, request, fmt.Errorf("SetLevels: %w", err))
+
 		return
 	}
 
-	buf, err := json.Marshal(response)
+	r.logger.Infof("Log levels changed: %v", levels)
+
+	buf, err := json.Marshal(levels)
 	if err != nil {
 		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
 		return
 	}
 
 	r.sendResponse(writer, request, http.StatusOK, buf)
 }
 
-func (r *Router) transferMoney(writer http.ResponseWriter, request *http.Request) {
-	var requestBody models.TransferRequest
-
-	err := json.NewDecoder(request.Body).Decode(&requestBody)
+func (r *Router) getRuntimeDiagnostics(writer http.ResponseWriter, request *http.Request) {
+	buf, err := json.Marshal(r.diagnostics.GetRuntime(request.Context()))
 	if err != nil {
-		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", errJsonDecode, err))
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
 		return
 	}
 
-	response, err := r.walletService.TransferMoney(request.Context(), requestBody)
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) getSlowRequests(writer http.ResponseWriter, request *http.Request) {
+	buf, err := json.Marshal(r.slowRequests.GetReport(request.Context()))
 	if err != nil {
-		r.sendErrorResponse(writer, request, fmt.Errorf("TransferMoney: %w", err))
+		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
 		return
 	}
 
+	r.sendResponse(writer, request, http.StatusOK, buf)
+}
+
+func (r *Router) clearSlowRequests(writer http.ResponseWriter, request *http.Request) {
+	r.slowRequests.Clear(request.Context())
+
+	writer.WriteHeader(http.StatusOK)
+}
+
+// readinessCheck отвечает 200 и при деградации бэкапов: сервер продолжает обслуживать запросы,
+// а мониторинг смотрит на status
+func (r *Router) readinessCheck(writer http.ResponseWriter, request *http.Request) {
+	response := ReadinessResponse{Status: readinessOK, Backup: r.backups.Health(request.Context())}
+	if response.Backup.Degraded {
+		response.Status = readinessDegraded
+	}
+
 	buf, err := json.Marshal(response)
 	if err != nil {
 		r.sendErrorResponse(writer, request, fmt.Errorf("%w: %w", models.ErrInternalServer, err))
+
 		return
 	}
 
@@ -827,9 +4645,7 @@ func (r *Router) transferMoney(writer http.ResponseWriter, request *http.Request
 }
 
 func (r *Router) healthCheck(writer http.ResponseWriter, _ *http.Request) {
-	response := map[string]string{
-		"status": "ok",
-	}
+	response := HealthResponse{Status: "ok"}
 
 	writer.Header().Set("Content-Type", "application/json")
 	writer.WriteHeader(http.StatusOK)
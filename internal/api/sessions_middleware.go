@@ -0,0 +1,31 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"eats-backend/internal/models"
+)
+
+type SessionTracker interface {
+	Touch(claims *models.AuthTokenClaims, userAgent string, now time.Time)
+}
+
+// SessionsMiddleware отмечает каждый запрос в сессии его токена для GET /users/me/sessions. Ставится после JWTAuth.
+type SessionsMiddleware struct {
+	tracker SessionTracker
+}
+
+func NewSessionsMiddleware(tracker SessionTracker) *SessionsMiddleware {
+	return &SessionsMiddleware{tracker: tracker}
+}
+
+func (m *SessionsMiddleware) Middleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(response http.ResponseWriter, request *http.Request) {
+		if claims := models.ClaimsFromContext(request.Context()); claims != nil {
+			m.tracker.Touch(claims, request.UserAgent(), time.Now())
+		}
+
+		next.ServeHTTP(response, request)
+	}
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// TimeoutMiddleware задает крайний срок контексту запроса. Сервисы проверяют контекст перед долгими
+// операциями и изменением данных, а истекший срок превращается в ответ 503.
+type TimeoutMiddleware struct {
+	timeout time.Duration
+}
+
+func NewTimeoutMiddleware(timeout time.Duration) *TimeoutMiddleware {
+	return &TimeoutMiddleware{timeout: timeout}
+}
+
+func (m *TimeoutMiddleware) Middleware(next http.HandlerFunc) http.HandlerFunc {
+	if m.timeout <= 0 {
+		return next
+	}
+
+	return func(response http.ResponseWriter, request *http.Request) {
+		ctx, cancel := context.WithTimeout(request.Context(), m.timeout)
+		defer cancel()
+
+		writer := &headerTracker{ResponseWriter: response}
+		next.ServeHTTP(writer, request.WithContext(ctx))
+
+		// Обработчик мог выйти по отмене контекста, ничего не ответив, например во время задержки chaos
+		if !writer.wroteHeader && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			response.Header().Set("Content-Type", "application/json")
+			response.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = response.Write([]byte(`{"error":"request timeout exceeded"}`))
+		}
+	}
+}
+
+type headerTracker struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *headerTracker) WriteHeader(statusCode int) {
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *headerTracker) Write(body []byte) (int, error) {
+	w.wroteHeader = true
+
+	return w.ResponseWriter.Write(body)
+}
+
+func (w *headerTracker) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -2,14 +2,22 @@ package application
 
 import (
 	"context"
+	"crypto/rand"
+	"errors"
 	"fmt"
+	"net/http"
+	"os"
 	"sync"
-	"time"
 
+	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 
 	"eats-backend/internal/api"
 	"eats-backend/internal/config"
+	"eats-backend/internal/events"
+	"eats-backend/internal/logging"
+	"eats-backend/internal/mailer"
+	"eats-backend/internal/models"
 	"eats-backend/internal/service"
 	"eats-backend/internal/storage"
 	"eats-backend/pkg/runner"
@@ -22,14 +30,57 @@ type Application struct {
 	cartService       *service.Cart
 	favouritesService *service.Favourites
 	orderService      *service.OrderService
+	refunds           *service.RefundService
+	couriers          *service.CourierService
+	checkoutService   *service.CheckoutService
+	orderExtras       *service.OrderExtrasCatalog
 	productService    *service.ProductsService
+	combos            *service.ComboService
+	notifications     *service.NotificationService
+	shoppingLists     *service.ShoppingListService
+	subscriptions     *service.SubscriptionService
+	scheduledTopups   *service.ScheduledTopupService
+	stats             *service.StatsService
+	icons             *service.IconCatalog
 	tokenService      *service.TokenService
+	revokedTokens     api.RevocationList
 	userData          *service.UserData
 	walletService     *service.WalletService
+	payments          *service.PaymentService
+	fraudGuard        *service.FraudGuard
+	walletPINs        *service.WalletPINService
 	fileSaver         *storage.Storage
+	imageProxy        *storage.ImageProxy
 	backupService     *service.BackupService
+	exportService     *service.ExportService
+	accountExport     *service.AccountExportService
+	resetService      *service.ResetService
+	chaosService      *service.ChaosService
+	diagnostics       *service.DiagnosticsService
+	slowRequests      *service.SlowRequestLog
+	usage             *service.UsageService
+	stateStore        *storage.SQLiteStore
+	walletRepo        *storage.WalletRepository
+	orderRepo         *storage.OrderRepository
+	redis             *redis.Client
+	persistence       *service.PersistenceService
+	webhooks          *service.WebhookService
+	recordings        *service.RecordingService
+	suspensions       *service.SuspensionService
+	experiments       *service.ExperimentService
+	zones             *service.ZoneService
+	collections       *service.CollectionService
+	sessions          *service.SessionService
+	leader            *service.LeaderElection
+	demo              *service.DemoService
+	clock             *service.SimulatedClock
+	events            *events.Bus
+	logLevels         *logging.Levels
 	logger            *zap.SugaredLogger
 
+	// Демо-режим включен флагом запуска, а не через DEMO_ENABLED
+	demoFlag bool
+
 	errChan chan error
 	wg      sync.WaitGroup
 	ready   bool
@@ -41,11 +92,26 @@ func New() *Application {
 	}
 }
 
+// EnableDemo включает демо-режим независимо от DEMO_ENABLED, для флага --demo
+func (a *Application) EnableDemo() {
+	a.demoFlag = true
+}
+
 func (a *Application) Start(ctx context.Context) error {
 	if err := a.initConfigAndLogger(); err != nil {
 		return err
 	}
 
+	if err := a.initStorage(ctx); err != nil {
+		return err
+	}
+
+	if err := a.initRedis(ctx); err != nil {
+		return err
+	}
+
+	a.initLeader()
+
 	if err := a.initServices(); err != nil {
 		return err
 	}
@@ -54,11 +120,28 @@ func (a *Application) Start(ctx context.Context) error {
 		return err
 	}
 
-	// Запускаем сервис бэкапа в отдельной горутине
+	// Бэкапы, сохранение в SQLite и задачи по расписанию выполняет только лидер, иначе экземпляры
+	// на общем хранилище затирают состояние друг друга и дважды создают заказы по подпискам
+	jobs := []func(ctx context.Context){a.backupService.Start, a.subscriptions.Start, a.scheduledTopups.Start}
+	if a.persistence != nil {
+		jobs = append(jobs, a.persistence.Start)
+	}
+
+	// Пока экземпляр был ведомым, состояние в SQLite менял прежний лидер
+	if a.persistence != nil {
+		a.leader.OnElected(a.reloadState)
+	}
+
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		a.leader.Run(ctx, jobs...)
+	}()
+
 	a.wg.Add(1)
 	go func() {
 		defer a.wg.Done()
-		a.backupService.Start(ctx)
+		a.webhooks.Start(ctx)
 	}()
 
 	return nil
@@ -91,11 +174,38 @@ func (a *Application) HandleGracefulShutdown(ctx context.Context, cancel context
 	a.wg.Wait()
 
 	// Выполняем финальный бекап перед завершением работы
-	a.logger.Info("Creating final backup before shutdown...")
-	if err := a.backupService.PerformBackup(); err != nil {
-		a.logger.Errorf("Failed to create final backup: %v", err)
+	if a.leader.IsLeader() {
+		a.logger.Info("Creating final backup before shutdown...")
+		if err := a.backupService.PerformBackup(); err != nil {
+			a.logger.Errorf("Failed to create final backup: %v", err)
+		} else {
+			a.logger.Info("Final backup completed successfully")
+		}
+
+		// Состояние сохраняется до Release, пока другой экземпляр не может стать лидером и записать свое
+		if a.persistence != nil {
+			if err := a.persistence.Persist(context.WithoutCancel(ctx)); err != nil {
+				a.logger.Errorf("Failed to persist state: %v", err)
+			}
+		}
 	} else {
-		a.logger.Info("Final backup completed successfully")
+		a.logger.Info("Skipping final backup, another instance is the leader")
+	}
+
+	if err := a.leader.Release(context.WithoutCancel(ctx)); err != nil {
+		a.logger.Errorf("Failed to release leader lock: %v", err)
+	}
+
+	if a.persistence != nil {
+		if err := a.stateStore.Close(); err != nil {
+			a.logger.Errorf("Failed to close sqlite: %v", err)
+		}
+	}
+
+	if a.redis != nil {
+		if err := a.redis.Close(); err != nil {
+			a.logger.Errorf("Failed to close redis: %v", err)
+		}
 	}
 
 	close(a.errChan)
@@ -125,73 +235,684 @@ func (a *Application) initConfig() error {
 		return fmt.Errorf("can't parse config: %w", err)
 	}
 
+	_, err = a.logLevels.SetLevels(models.LogLevelRequest{Level: a.cfg.LogLevel, Modules: a.cfg.LogModuleLevels})
+	if err != nil {
+		return fmt.Errorf("can't set log levels: %w", err)
+	}
+
+	return nil
+}
+
+// initStorage подключает SQLite, если он выбран. При первом запуске база заполняется из файлов data/,
+// дальше начальные данные сервисов читаются из нее.
+func (a *Application) initStorage(ctx context.Context) error {
+	switch a.cfg.StorageType {
+	case "json":
+		return nil
+	case "sqlite":
+	default:
+		return fmt.Errorf("unknown storage type %s, should be json or sqlite", a.cfg.StorageType)
+	}
+
+	store, err := storage.NewSQLiteStore(ctx, a.cfg.SQLite.Path)
+	if err != nil {
+		return fmt.Errorf("can't init sqlite: %w", err)
+	}
+
+	a.stateStore = store
+
+	err = errors.Join(
+		loadOrSeed(ctx, store, "products", &a.cfg.InitialProductsData),
+		loadOrSeed(ctx, store, "user_profiles", &a.cfg.InitialUserProfiles),
+		loadOrSeed(ctx, store, "cart_items", &a.cfg.InitialCartItems),
+		loadOrSeed(ctx, store, "user_favourites", &a.cfg.InitialFavourites),
+		loadSnapshot(ctx, store, "orders", &a.cfg.InitialOrders),
+		loadSnapshot(ctx, store, "wallet_data", &a.cfg.InitialWalletData),
+		loadOrSeed(ctx, store, "notifications", &a.cfg.InitialNotifications),
+		loadOrSeed(ctx, store, "shopping_lists", &a.cfg.InitialShoppingLists),
+		loadOrSeed(ctx, store, "subscriptions", &a.cfg.InitialSubscriptions),
+		loadOrSeed(ctx, store, "scheduled_topups", &a.cfg.InitialScheduledTopups),
+		loadOrSeed(ctx, store, "transaction_icons", &a.cfg.InitialTransactionIcons),
+		loadOrSeed(ctx, store, "payments", &a.cfg.InitialPayments),
+		loadOrSeed(ctx, store, "uploads", &a.cfg.InitialUploads),
+		loadOrSeed(ctx, store, "webhooks", &a.cfg.InitialWebhooks),
+		loadOrSeed(ctx, store, "user_suspensions", &a.cfg.InitialSuspensions),
+		loadOrSeed(ctx, store, "experiments", &a.cfg.InitialExperiments),
+		loadOrSeed(ctx, store, "delivery_zones", &a.cfg.InitialDeliveryZones),
+		loadOrSeed(ctx, store, "collections", &a.cfg.InitialCollections),
+	)
+	if err != nil {
+		return fmt.Errorf("can't load state from sqlite: %w", err)
+	}
+
+	// Кошельки и заказы хранятся в своих таблицах и записываются при каждом изменении. Снимки
+	// из прежних версий переносятся в таблицы при первом запуске.
+	a.walletRepo = storage.NewWalletRepository(store)
+	a.orderRepo = storage.NewOrderRepository(store)
+
+	err = errors.Join(
+		a.walletRepo.LoadOrSeed(ctx, &a.cfg.InitialWalletData),
+		a.orderRepo.LoadOrSeed(ctx, &a.cfg.InitialOrders),
+	)
+	if err != nil {
+		return fmt.Errorf("can't load wallets and orders from sqlite: %w", err)
+	}
+
+	a.logger.Infof("Using sqlite storage %s", a.cfg.SQLite.Path)
+
+	return nil
+}
+
+// initRedis подключает Redis, если задан адрес, и переносит в него корзины и отозванные токены из файлов data/.
+func (a *Application) initRedis(ctx context.Context) error {
+	if a.cfg.Redis.Addr == "" {
+		return nil
+	}
+
+	client := redis.NewClient(&redis.Options{
+		Addr:     a.cfg.Redis.Addr,
+		Password: a.cfg.Redis.Password,
+		DB:       a.cfg.Redis.DB,
+	})
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		return fmt.Errorf("can't connect to redis %s: %w", a.cfg.Redis.Addr, errors.Join(err, client.Close()))
+	}
+
+	a.redis = client
+
+	err := errors.Join(
+		storage.NewRedisCartStore(client, a.cfg.Redis.KeyPrefix).Seed(ctx, a.cfg.InitialCartItems),
+		storage.NewRedisRevocationList(client, a.cfg.Redis.KeyPrefix).Add(ctx, a.cfg.RevokedTokens...),
+	)
+	if err != nil {
+		return fmt.Errorf("can't seed redis: %w", err)
+	}
+
+	a.logger.Infof("Using redis %s for carts and revoked tokens", a.cfg.Redis.Addr)
+
+	return nil
+}
+
+// initLeader выбирает блокировку лидера. Без нее экземпляр считается единственным.
+func (a *Application) initLeader() {
+	var lock service.LeaderLock
+
+	switch a.cfg.Leader.Lock {
+	case "file":
+		lock = storage.NewFileLock(a.cfg.Leader.LockFile)
+	case "redis":
+		hostname, _ := os.Hostname()
+		lock = storage.NewRedisLock(a.redis, a.cfg.Redis.KeyPrefix, hostname+"-"+rand.Text())
+	}
+
+	a.leader = service.NewLeaderElection(lock, a.cfg.Leader.TTL, a.logLevels.Module(logging.ModuleStorage))
+}
+
+func loadOrSeed[T any](ctx context.Context, store *storage.SQLiteStore, name string, target *T) error {
+	var loaded T
+
+	found, err := store.Load(ctx, name, &loaded)
+	if err != nil {
+		return err
+	}
+
+	if found {
+		*target = loaded
+
+		return nil
+	}
+
+	return store.Save(ctx, name, *target)
+}
+
+// loadSnapshot читает снимок сущности, если он сохранялся
+func loadSnapshot[T any](ctx context.Context, store *storage.SQLiteStore, name string, target *T) error {
+	var loaded T
+
+	found, err := store.Load(ctx, name, &loaded)
+	if err != nil {
+		return err
+	}
+
+	if found {
+		*target = loaded
+	}
+
+	return nil
+}
+
+// reloadState перечитывает состояние из SQLite перед тем, как экземпляр станет лидером: кошельки и заказы
+// из их таблиц, остальное из снимков
+func (a *Application) reloadState(ctx context.Context) error {
+	wallets, err := a.walletRepo.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	orders, err := a.orderRepo.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := a.persistence.Reload(ctx); err != nil {
+		return err
+	}
+
+	a.walletService.ReplaceData(wallets)
+	a.orderService.ReplaceOrders(orders)
+
 	return nil
 }
 
 func (a *Application) initLogger() error {
-	zapLog, err := zap.NewProduction()
+	// Уровень из конфига применяется после его загрузки, до этого пишем info
+	levels, err := logging.New("info")
 	if err != nil {
 		return fmt.Errorf("can't create logger: %w", err)
 	}
 
-	a.logger = zapLog.Sugar()
+	a.logLevels = levels
+	a.logger = levels.Logger()
 
 	return nil
 }
 
 func (a *Application) initServices() error {
-	a.addressService = service.NewAddressService()
+	a.events = events.NewBus(a.logger)
+	// Перематывать время можно только в демо-режиме, иначе это системное время
+	a.clock = service.NewSimulatedClock(a.demoFlag || a.cfg.Demo.Enabled)
+	a.addressService = service.NewAddressService(service.AddressLimits{
+		MaxPerUser:            a.cfg.Addresses.MaxPerUser,
+		DuplicateRadiusMeters: a.cfg.Addresses.DuplicateRadius,
+	})
 
 	// Инициализируем сервисы с данными из конфига
 	a.favouritesService = service.NewFavouritesService(a.cfg.InitialFavourites)
-	a.userData = service.NewUserData(a.cfg.InitialUserProfiles)
+	var emailSender service.Mailer = mailer.NewLogMailer(a.logger)
+	if a.cfg.MailerType == "smtp" {
+		emailSender = mailer.NewSMTPMailer(a.cfg.SMTP)
+	}
+
+	emailRenderer, err := mailer.NewRenderer()
+	if err != nil {
+		return fmt.Errorf("can't init email templates: %w", err)
+	}
+
+	a.userData = service.NewUserData(a.cfg.InitialUserProfiles, emailSender, a.clock)
+	emailNotifier := service.NewEmailNotifier(a.userData, emailSender, emailRenderer, a.logger)
+
+	signingKey := []byte(a.cfg.Uploads.SigningKey)
+	if len(signingKey) == 0 {
+		a.logger.Warn("UPLOADS_SIGNING_KEY is not set, presigned upload urls will be invalid after restart")
+
+		signingKey = make([]byte, 32)
+		if _, err := rand.Read(signingKey); err != nil {
+			return fmt.Errorf("can't generate upload signing key: %w", err)
+		}
+	}
+
+	storageLogger := a.logLevels.Module(logging.ModuleStorage)
+
+	a.fileSaver = storage.NewStorage(storageLogger, a.cfg.Uploads.Dir, a.cfg.Host, signingKey, a.cfg.Uploads.PresignTTL, a.cfg.Uploads.MaxConcurrent, a.cfg.Uploads.Private, a.cfg.InitialUploads)
+
+	images := a.cfg.Images
+
+	a.imageProxy, err = storage.NewImageProxy(storageLogger, images.Dir, images.BaseURL, images.AllowedHosts, images.MaxSize, images.Timeout)
+	if err != nil {
+		return fmt.Errorf("image proxy: %w", err)
+	}
+
+	// Каталог получает ссылки на прокси до того, как попадет в сервис товаров, бэкапы и выгрузки
+	if err := a.imageProxy.RewriteProducts(a.cfg.InitialProductsData); err != nil {
+		return fmt.Errorf("can't rewrite product images: %w", err)
+	}
+
+	recentlyViewed := service.NewRecentlyViewed(service.DefaultRecentlyViewedLimit)
+	popularity := service.NewProductPopularity(a.cfg.InitialProductsData, a.cfg.InitialOrders)
+	a.notifications = service.NewNotificationService(emailNotifier, a.cfg.InitialNotifications)
+
+	hours := a.cfg.WorkingHours
+
+	workingHours, err := service.NewWorkingHours(
+		hours.Timezone, hours.Schedule, hours.Holidays, hours.OutsideHours == "schedule")
+	if err != nil {
+		return fmt.Errorf("working hours: %w", err)
+	}
+
+	reviewModerator := service.NewReviewModerator(service.ReviewRules{
+		MaxImages:   a.cfg.Reviews.MaxImages,
+		MinLength:   a.cfg.Reviews.MinLength,
+		MaxLength:   a.cfg.Reviews.MaxLength,
+		MaxPerDay:   a.cfg.Reviews.MaxPerDay,
+		BannedWords: a.cfg.Reviews.BannedWords,
+	})
 
-	a.fileSaver = storage.NewStorage(a.logger, "data/uploads")
 	a.productService = service.NewProductsService(
 		a.favouritesService,
+		recentlyViewed,
+		a.notifications,
+		popularity,
+		a.fileSaver,
+		reviewModerator,
+		a.userData,
+		workingHours,
+		a.events,
 		a.cfg.InitialProductsData,
 		a.cfg.InitialProductCategories,
 		a.cfg.InitialCategories,
 	)
 
-	a.cartService = service.NewCart(a.productService, a.logger, a.cfg.InitialCartItems)
-	a.orderService = service.NewOrderService(a.addressService, a.cartService, a.cfg.InitialOrders)
-	a.tokenService = service.NewTokenService(a.cfg.PrivateKey, a.cfg.CreatedTokensPath)
-	a.walletService = service.NewWalletService(a.userData, a.cfg.InitialWalletData)
+	var cartStore service.CartStore = service.NewMemoryCartStore(a.cfg.InitialCartItems)
+	if a.redis != nil {
+		cartStore = storage.NewRedisCartStore(a.redis, a.cfg.Redis.KeyPrefix)
+	}
+
+	checkout := a.cfg.Checkout
+	delivery := service.NewDeliveryCalculator(
+		checkout.StoreCoordinates,
+		checkout.DeliveryBasePrice,
+		checkout.DeliveryPricePerKm,
+		checkout.MinOrderAmount,
+		checkout.FreeDeliveryThreshold,
+		workingHours,
+		a.clock,
+	)
+
+	a.stats = service.NewStatsService(a.cfg.InitialOrders, a.cfg.InitialCartItems)
+	a.combos = service.NewComboService(a.productService, a.cfg.InitialCombos)
+	a.zones = service.NewZoneService(a.productService, a.addressService, a.cfg.InitialDeliveryZones)
+	a.collections = service.NewCollectionService(a.productService, a.cfg.NewProductsPeriod, a.cfg.InitialCollections)
+	a.cartService = service.NewCart(
+		a.productService, cartStore, delivery, a.addressService, a.stats, a.combos, checkout.MaxItemQuantity,
+		a.logger, a.cfg.InitialCartItems,
+	)
+	a.shoppingLists = service.NewShoppingListService(a.productService, a.cartService, a.cfg.InitialShoppingLists)
+	walletLogger := a.logLevels.Module(logging.ModuleWallet)
+	a.icons = service.NewIconCatalog(a.cfg.Host, a.cfg.InitialTransactionIcons)
+	a.fraudGuard = service.NewFraudGuard(service.FraudRules{
+		MaxTransfersPerHour:            a.cfg.Fraud.MaxTransfersPerHour,
+		MaxTopupsPerHour:               a.cfg.Fraud.MaxTopupsPerHour,
+		MaxAmountPerCounterpartyPerDay: a.cfg.Fraud.MaxAmountPerCounterpartyPerDay,
+		NewRecipientCooldown:           a.cfg.Fraud.NewRecipientCooldown,
+	}, a.clock, walletLogger)
+	a.walletPINs = service.NewWalletPINService(service.WalletPINSettings{
+		Threshold:    a.cfg.Wallet.PINThreshold,
+		MaxAttempts:  a.cfg.Wallet.PINMaxAttempts,
+		Lockout:      a.cfg.Wallet.PINLockout,
+		ResetCodeTTL: a.cfg.Wallet.PINResetCodeTTL,
+	}, a.notifications, a.clock, walletLogger)
+	a.walletService = service.NewWalletService(
+		a.userData,
+		a.events,
+		a.fraudGuard,
+		a.walletPINs,
+		a.stats,
+		a.icons,
+		service.NewStaticRates(a.cfg.Wallet.Rates),
+		a.cfg.Wallet.Currencies,
+		a.clock,
+		walletLogger,
+		a.cfg.InitialWalletData,
+	)
+
+	paymentSecret := []byte(a.cfg.Payments.SandboxSecret)
+	if len(paymentSecret) == 0 {
+		a.logger.Warn("PAYMENTS_SANDBOX_SECRET is not set, payment callbacks can be signed only by this server")
+
+		paymentSecret = make([]byte, 32)
+		if _, err := rand.Read(paymentSecret); err != nil {
+			return fmt.Errorf("can't generate payment sandbox secret: %w", err)
+		}
+	}
+
+	a.payments = service.NewPaymentService(
+		service.NewSandboxPaymentProvider(a.cfg.Payments.BaseURL, paymentSecret),
+		a.walletService,
+		a.cfg.Payments.MaxAmount,
+		walletLogger,
+		a.cfg.InitialPayments,
+	)
+	priceLocks := service.NewPriceLocks(checkout.PriceLockTTL, a.clock)
+	a.orderExtras = service.NewOrderExtrasCatalog(a.cfg.InitialOrderExtras)
+	a.checkoutService = service.NewCheckoutService(
+		a.addressService,
+		a.cartService,
+		a.walletService,
+		delivery,
+		priceLocks,
+		a.orderExtras,
+		checkout.PromoCodes,
+		checkout.LoyaltyPercent,
+	)
+
+	a.orderService = service.NewOrderService(
+		a.addressService,
+		a.cartService,
+		a.walletService,
+		priceLocks,
+		delivery,
+		a.checkoutService,
+		a.orderExtras,
+		a.events,
+		a.clock,
+		a.logger,
+		a.cfg.InitialOrders,
+	)
+
+	if a.walletRepo != nil {
+		a.walletService.UseRepository(a.walletRepo)
+		a.orderService.UseRepository(a.orderRepo)
+	}
+
+	a.refunds = service.NewRefundService(a.orderService, a.walletService, a.notifications, a.clock, a.logger)
+	a.couriers = service.NewCourierService(a.orderService, a.fileSaver, a.clock, a.logger)
+	a.subscriptions = service.NewSubscriptionService(
+		a.orderService,
+		a.productService,
+		delivery,
+		a.notifications,
+		a.clock,
+		a.logger,
+		a.cfg.SubscriptionsCheckInterval,
+		a.cfg.InitialSubscriptions,
+	)
+	a.scheduledTopups = service.NewScheduledTopupService(
+		a.walletService,
+		a.notifications,
+		a.clock,
+		walletLogger,
+		a.cfg.ScheduledTopupsCheckInterval,
+		a.cfg.InitialScheduledTopups,
+	)
+	a.revokedTokens = api.NewRevocationSet(a.cfg.RevokedTokens)
+	if a.redis != nil {
+		a.revokedTokens = storage.NewRedisRevocationList(a.redis, a.cfg.Redis.KeyPrefix)
+	}
+
+	a.sessions = service.NewSessionService(a.revokedTokens)
+	a.tokenService = service.NewTokenService(a.cfg.PrivateKey, a.cfg.CreatedTokensPath, a.revokedTokens, a.logger)
 
-	// Инициализируем сервис бэкапа (каждые 24 часа)
-	a.backupService = service.NewBackupService(a.logger, "data", 24*time.Hour)
+	a.exportService = service.NewExportService(a.productService, a.orderService)
+
+	a.recordings = service.NewRecordingService(a.cfg.Recording.MaxEntries)
+	a.suspensions = service.NewSuspensionService(a.cfg.InitialSuspensions)
+	a.experiments = service.NewExperimentService(a.cfg.InitialExperiments)
+
+	a.webhooks = service.NewWebhookService(
+		&http.Client{Timeout: a.cfg.Webhooks.Timeout},
+		a.cfg.Webhooks.MaxAttempts,
+		a.cfg.Webhooks.Backoff,
+		a.logger,
+		a.cfg.InitialWebhooks,
+	)
+
+	// Подписчики доменных событий, порядок подписки - порядок вызова
+	events.Subscribe(a.events, "email", func(ctx context.Context, event events.OrderCreated) {
+		emailNotifier.OrderCreated(ctx, event.UserID, event.Order)
+	})
+	events.Subscribe(a.events, "email", func(ctx context.Context, event events.OrderStatusChanged) {
+		emailNotifier.OrderStatusChanged(ctx, event.UserID, event.Order)
+	})
+	events.Subscribe(a.events, "email", emailNotifier.TransferCompleted)
+	events.Subscribe(a.events, "stats", func(_ context.Context, event events.OrderCreated) {
+		a.stats.OrderPlaced(event.UserID, event.Order)
+	})
+	events.Subscribe(a.events, "popularity", func(_ context.Context, event events.OrderCreated) {
+		popularity.ProductsOrdered(event.Order.Items)
+	})
+	events.Subscribe(a.events, "waitlist", a.notifications.ProductUpdated)
+	events.Subscribe(a.events, "notifications", a.notifications.OrderDelayed)
+	events.Subscribe(a.events, "webhooks", func(ctx context.Context, event events.OrderCreated) {
+		a.webhooks.Enqueue(ctx, event)
+	})
+	events.Subscribe(a.events, "webhooks", func(ctx context.Context, event events.OrderStatusChanged) {
+		a.webhooks.Enqueue(ctx, event)
+	})
+	events.Subscribe(a.events, "webhooks", func(ctx context.Context, event events.OrderDelayed) {
+		a.webhooks.Enqueue(ctx, event)
+	})
+	events.Subscribe(a.events, "webhooks", func(ctx context.Context, event events.TransferCompleted) {
+		a.webhooks.Enqueue(ctx, event)
+	})
+
+	// Регистрируем все сервисы с данными пользователя для выгрузки аккаунта
+	a.accountExport = service.NewAccountExportService(a.logger)
+	a.accountExport.RegisterExporter(a.userData)
+	a.accountExport.RegisterExporter(a.addressService)
+	a.accountExport.RegisterExporter(a.orderService)
+	a.accountExport.RegisterExporter(a.cartService)
+	a.accountExport.RegisterExporter(a.favouritesService)
+	a.accountExport.RegisterExporter(a.walletService)
+	a.accountExport.RegisterExporter(a.payments)
+	a.accountExport.RegisterExporter(a.shoppingLists)
+	a.accountExport.RegisterExporter(a.subscriptions)
+	a.accountExport.RegisterExporter(a.scheduledTopups)
+	a.accountExport.RegisterExporter(a.notifications)
+	a.accountExport.RegisterExporter(a.fileSaver)
+
+	// Регистрируем все сервисы с данными пользователя для сброса
+	a.resetService = service.NewResetService(a.logger)
+	a.resetService.RegisterResettable(a.userData)
+	a.resetService.RegisterResettable(a.addressService)
+	a.resetService.RegisterResettable(a.cartService)
+	a.resetService.RegisterResettable(a.favouritesService)
+	a.resetService.RegisterResettable(a.orderService)
+	a.resetService.RegisterResettable(a.walletService)
+	a.resetService.RegisterResettable(a.payments)
+	a.resetService.RegisterResettable(recentlyViewed)
+	a.resetService.RegisterResettable(a.notifications)
+	a.resetService.RegisterResettable(a.shoppingLists)
+	a.resetService.RegisterResettable(a.subscriptions)
+	a.resetService.RegisterResettable(a.scheduledTopups)
+	a.resetService.RegisterResettable(a.fraudGuard)
+	a.resetService.RegisterResettable(a.walletPINs)
+	a.resetService.RegisterResettable(a.fileSaver)
+
+	if a.demoFlag || a.cfg.Demo.Enabled {
+		a.demo = service.NewDemoService(
+			a.cfg.Demo.Seed, a.productService, a.userData, a.walletService, a.orderService, a.favouritesService)
+		// После сброса студент снова получит демо-данные при следующем запросе
+		a.resetService.RegisterResettable(a.demo)
+
+		a.logger.Infof("Demo mode enabled with seed %d", a.cfg.Demo.Seed)
+	}
+
+	// Файлы из отзывов и фото доставки не удаляются вместе с загрузками пользователя
+	a.fileSaver.RegisterReferrer(a.productService)
+	a.fileSaver.RegisterReferrer(a.orderService)
+
+	a.chaosService = service.NewChaosService()
+
+	a.diagnostics = service.NewDiagnosticsService()
+	a.slowRequests = service.NewSlowRequestLog(a.cfg.SlowRequests.Threshold, a.cfg.SlowRequests.Keep)
+	a.usage = service.NewUsageService()
+	a.resetService.RegisterResettable(a.usage)
+	a.diagnostics.RegisterSizer(a.cartService)
+	a.diagnostics.RegisterSizer(a.orderService)
+	a.diagnostics.RegisterSizer(a.walletService)
+	a.diagnostics.RegisterSizer(a.payments)
+	a.diagnostics.RegisterSizer(a.notifications)
+	a.diagnostics.RegisterSizer(a.shoppingLists)
+	a.diagnostics.RegisterSizer(a.subscriptions)
+	a.diagnostics.RegisterSizer(a.scheduledTopups)
+	a.diagnostics.RegisterSizer(a.fraudGuard)
+	a.diagnostics.RegisterSizer(a.walletPINs)
+	a.diagnostics.RegisterSizer(a.webhooks)
+	a.diagnostics.RegisterSizer(a.recordings)
+	a.diagnostics.RegisterSizer(a.suspensions)
+	a.diagnostics.RegisterSizer(a.sessions)
+	a.diagnostics.RegisterSizer(a.usage)
+
+	// Инициализируем сервис бэкапа (по умолчанию каждые 24 часа)
+	a.backupService = service.NewBackupService(
+		storageLogger, a.clock, a.cfg.DataDir, a.cfg.Backup.Interval, a.cfg.Backup.RetryAttempts, a.cfg.Backup.RetryBackoff,
+	)
+
+	if a.cfg.Backup.AlertEmail != "" {
+		a.backupService.RegisterAlerter(service.NewBackupAlertMailer(emailSender, a.cfg.Backup.AlertEmail, storageLogger))
+	}
 
 	// Регистрируем все сервисы для бэкапа
+	a.backupService.RegisterBackupable(a.productService)
 	a.backupService.RegisterBackupable(a.userData)
 	a.backupService.RegisterBackupable(a.cartService)
 	a.backupService.RegisterBackupable(a.favouritesService)
 	a.backupService.RegisterBackupable(a.orderService)
 	a.backupService.RegisterBackupable(a.walletService)
+	a.backupService.RegisterBackupable(a.notifications)
+	a.backupService.RegisterBackupable(a.shoppingLists)
+	a.backupService.RegisterBackupable(a.subscriptions)
+	a.backupService.RegisterBackupable(a.scheduledTopups)
+	a.backupService.RegisterBackupable(a.icons)
+	a.backupService.RegisterBackupable(a.payments)
+	a.backupService.RegisterBackupable(a.fileSaver)
+	a.backupService.RegisterBackupable(a.webhooks)
+	a.backupService.RegisterBackupable(a.suspensions)
+	a.backupService.RegisterBackupable(a.experiments)
+	a.backupService.RegisterBackupable(a.zones)
+	a.backupService.RegisterBackupable(a.collections)
+	a.backupService.RegisterBackupable(a.sessions)
+	a.backupService.RegisterBackupable(a.walletPINs)
+	a.backupService.RegisterBackupable(a.usage)
+
+	if a.stateStore != nil {
+		a.persistence = service.NewPersistenceService(storageLogger, a.stateStore, a.cfg.SQLite.SaveInterval)
+		a.persistence.RegisterBackupable(a.productService)
+		a.persistence.RegisterBackupable(a.userData)
+		// Корзины в Redis общие для всех экземпляров и сами переживают перезапуск, старый снимок из SQLite
+		// при смене лидера затер бы их
+		if a.redis == nil {
+			a.persistence.RegisterBackupable(a.cartService)
+		}
+		a.persistence.RegisterBackupable(a.favouritesService)
+		// Кошельки и заказы сами записывают каждое изменение в свои таблицы
+		a.persistence.RegisterBackupable(a.notifications)
+		a.persistence.RegisterBackupable(a.shoppingLists)
+		a.persistence.RegisterBackupable(a.subscriptions)
+		a.persistence.RegisterBackupable(a.scheduledTopups)
+		a.persistence.RegisterBackupable(a.icons)
+		a.persistence.RegisterBackupable(a.payments)
+		a.persistence.RegisterBackupable(a.fileSaver)
+		a.persistence.RegisterBackupable(a.webhooks)
+		a.persistence.RegisterBackupable(a.suspensions)
+		a.persistence.RegisterBackupable(a.experiments)
+		a.persistence.RegisterBackupable(a.zones)
+		a.persistence.RegisterBackupable(a.collections)
+		a.persistence.RegisterBackupable(a.sessions)
+		a.persistence.RegisterBackupable(a.walletPINs)
+		a.persistence.RegisterBackupable(a.usage)
+	}
 
 	return nil
 }
 
 func (a *Application) initRouter(ctx context.Context) error {
-	authMiddleware := api.NewAuthMiddleware(a.cfg.PublicKey, a.logger, a.cfg.RevokedTokens).JWTAuth
-	loggingMiddleware := api.NewLoggerMiddleware(a.logger).Middleware
+	apiLogger := a.logLevels.Module(logging.ModuleAPI)
+
+	bootstrap := api.BootstrapCredentials{User: a.cfg.Bootstrap.User, Password: a.cfg.Bootstrap.Password}
+	auth := api.NewAuthMiddleware(a.cfg.PublicKey, bootstrap, apiLogger, a.revokedTokens, a.suspensions)
+	loggingMiddleware := api.NewLoggerMiddleware(apiLogger, a.cfg.AccessLogSampling, a.slowRequests, a.usage).Middleware
+	timeoutMiddleware := api.NewTimeoutMiddleware(a.cfg.RequestTimeout).Middleware
+	loadShedding := func(_ api.RouteClass, next http.HandlerFunc) http.HandlerFunc { return next }
+	if a.cfg.LoadShedding.Enabled {
+		loadShedding = api.NewLoadSheddingMiddleware(a.cfg.LoadShedding).Middleware
+	}
+	compressionMiddleware := func(next http.Handler) http.Handler { return next }
+	if a.cfg.Compression.Enabled {
+		compressionMiddleware = api.NewCompressionMiddleware(a.cfg.Compression.MinSize, a.cfg.Compression.ContentTypes).Middleware
+	}
+	chaos := api.NewChaosMiddleware(a.chaosService, apiLogger)
+	language := api.NewLanguageMiddleware(a.cfg.DefaultLanguage, a.cfg.Languages)
+	recordingMiddleware := func(next http.HandlerFunc) http.HandlerFunc { return next }
+	if a.cfg.Recording.Enabled {
+		recordingMiddleware = api.NewRecordingMiddleware(a.recordings, a.cfg.Recording.MaxBodySize).Middleware
+	}
+
+	// Сбои внедряются после авторизации, когда известен пользователь. Запись снаружи сбоев,
+	// чтобы в ней был ответ, который на самом деле получил студент.
+	demoMiddleware := func(next http.HandlerFunc) http.HandlerFunc { return next }
+	if a.demo != nil {
+		demoMiddleware = api.NewDemoMiddleware(a.demo).Middleware
+	}
+
+	experiments := api.NewExperimentsMiddleware(a.experiments)
+	sessions := api.NewSessionsMiddleware(a.sessions)
+
+	authMiddleware := func(next http.HandlerFunc) http.HandlerFunc {
+		return auth.JWTAuth(sessions.Middleware(demoMiddleware(recordingMiddleware(
+			experiments.Middleware(language.Middleware(chaos.Middleware(next))),
+		))))
+	}
 
 	router := api.NewRouter(
 		a.cfg.ServerOpts,
+		a.cfg.Pagination,
 		a.productService,
+		a.combos,
+		service.NewSearchService(a.productService, a.orderService),
+		a.notifications,
 		a.userData,
 		a.addressService,
 		a.cartService,
+		a.shoppingLists,
 		a.orderService,
+		a.refunds,
+		a.couriers,
+		a.subscriptions,
+		a.scheduledTopups,
+		a.checkoutService,
+		a.orderExtras,
 		a.tokenService,
 		a.walletService,
+		a.walletPINs,
+		a.payments,
+		a.fraudGuard,
+		a.icons,
+		a.exportService,
+		a.accountExport,
+		a.resetService,
+		a.chaosService,
+		a.logLevels,
+		a.diagnostics,
+		a.slowRequests,
+		a.usage,
+		a.stats,
+		a.backupService,
+		a.webhooks,
+		a.recordings,
+		a.suspensions,
+		a.experiments,
+		a.zones,
+		a.collections,
+		a.clock,
+		a.sessions,
 		a.fileSaver,
+		a.imageProxy,
 		authMiddleware,
+		auth.TeacherOnly,
+		auth.CourierOnly,
+		auth.TokenIssuer,
 		loggingMiddleware,
-		a.logger,
+		compressionMiddleware,
+		api.NewFollowerMiddleware(a.leader, a.cfg.Leader.TTL/3).Middleware,
+		timeoutMiddleware,
+		loadShedding,
+		apiLogger,
 	)
 
-	if err := runner.RunServer(ctx, router, a.cfg.ListenPort, a.errChan, &a.wg); err != nil {
+	serverOpts := runner.Options{
+		Addr: a.cfg.ListenAddr,
+		TLS: runner.TLSOptions{
+			CertFile:         a.cfg.TLS.CertFile,
+			KeyFile:          a.cfg.TLS.KeyFile,
+			AutocertHosts:    a.cfg.TLS.AutocertHosts,
+			AutocertCacheDir: a.cfg.TLS.AutocertCacheDir,
+			RedirectAddr:     a.cfg.TLS.RedirectAddr,
+		},
+	}
+
+	if err := runner.RunServer(ctx, router, serverOpts, a.errChan, &a.wg); err != nil {
 		return fmt.Errorf("can't run public router: %w", err)
 	}
 
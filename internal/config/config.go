@@ -9,14 +9,20 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"net/url"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/caarlos0/env/v11"
 	"github.com/golang-jwt/jwt/v5"
 	"go.uber.org/zap"
 
+	"eats-backend/internal/datacheck"
+	"eats-backend/internal/migrations"
 	"eats-backend/internal/models"
 )
 
@@ -26,16 +32,31 @@ var (
 )
 
 type Config struct {
-	ListenPort string
+	// Адрес сервера: ":8080", "127.0.0.1:8080" или "[::]:8080".
+	ListenAddr string `env:"LISTEN_ADDR" envDefault:":8080"`
+	// HTTPS по файлам сертификата или через ACME. Без них сервер работает по HTTP.
+	TLS TLSConfig `envPrefix:"TLS_"`
 
 	PublicKey  *rsa.PublicKey  `env:"PUBLIC_KEY,notEmpty"`
 	PrivateKey *rsa.PrivateKey `env:"PRIVATE_KEY,notEmpty"`
 
 	RevokedTokens []string
 
+	// Логин и пароль для выдачи токенов через Basic-авторизацию, когда токена преподавателя еще нет.
+	// Без пароля токены выдают только преподаватели.
+	Bootstrap BootstrapConfig `envPrefix:"BOOTSTRAP_"`
+
 	InitialProductsData      []*models.Product
 	InitialCategories        map[string]models.Category
 	InitialProductCategories map[string][]string
+	// Наборы товаров по специальной цене
+	InitialCombos []models.Combo
+	// Опции заказа, которые можно выбрать при оформлении
+	InitialOrderExtras []models.OrderExtra
+	// Зоны доставки вместе с привязанным к ним ассортиментом
+	InitialDeliveryZones []*models.DeliveryZone
+	// Подборки товаров для главного экрана, например "Летнее меню"
+	InitialCollections []*models.Collection
 
 	// User data
 	InitialUserProfiles map[string]*models.UserProfile
@@ -43,42 +64,300 @@ type Config struct {
 	InitialFavourites   map[string][]string
 	InitialOrders       map[string][]*models.Order
 	InitialWalletData   models.WalletData
+	// Лист ожидания товаров и уведомления внутри приложения
+	InitialNotifications models.NotificationsData
+	InitialShoppingLists map[string][]*models.ShoppingList
+	InitialSubscriptions map[string][]*models.Subscription
+	// Регулярные пополнения кошелька
+	InitialScheduledTopups map[string][]*models.ScheduledTopup
+	// Иконки транзакций, заданные преподавателем, поверх иконок по умолчанию
+	InitialTransactionIcons map[models.IconKind]string
+	// Пополнения через платежного провайдера, в том числе ожидающие оплаты
+	InitialPayments map[string][]*models.Payment
+	// Какие файлы загрузил каждый пользователь, для выгрузки аккаунта
+	InitialUploads map[string][]models.UploadedFile
+	// Вебхуки интеграторов вместе с секретами
+	InitialWebhooks []*models.Webhook
+	// Пользователи, заблокированные преподавателями
+	InitialSuspensions []*models.UserSuspension
+	// A/B эксперименты модуля аналитики
+	InitialExperiments []*models.Experiment
+
+	ServerOpts    ServerOpts
+	FeedbacksPath string
+
+	// Каталог с файлами данных, бэкапами и миграциями. Остальные пути по умолчанию строятся от него,
+	// поэтому сервер можно запускать из любого рабочего каталога.
+	DataDir string `env:"DATA_DIR" envDefault:"data"`
+	// Журнал выданных токенов, по умолчанию DATA_DIR/created_tokens.csv.
+	CreatedTokensPath string `env:"CREATED_TOKENS_PATH"`
+	// Адрес, по которому доступны загруженные файлы, с / на конце.
+	Host string `env:"UPLOADS_HOST" envDefault:"http://eats-pages.ddns.net/uploads/"`
+
+	// Начальные уровни логирования, меняются на ходу через PUT /admin/log-level.
+	// LOG_MODULE_LEVELS переопределяет уровень модулей api, wallet, storage: "wallet:debug,storage:warn".
+	LogLevel        string            `env:"LOG_LEVEL" envDefault:"info"`
+	LogModuleLevels map[string]string `env:"LOG_MODULE_LEVELS"`
+
+	// Крайний срок обработки запроса, после него сервер отвечает 503. 0 - без ограничения.
+	RequestTimeout time.Duration `env:"REQUEST_TIMEOUT" envDefault:"10s"`
+
+	// Ограничение одновременных запросов по классам маршрутов, лишние получают 503 с Retry-After.
+	LoadShedding LoadSheddingConfig `envPrefix:"LOAD_SHEDDING_"`
+
+	// Ограничения размера страницы для всех списков с пагинацией.
+	Pagination PaginationConfig `envPrefix:"PAGINATION_"`
+
+	// Сжатие ответов gzip/deflate по Accept-Encoding.
+	Compression CompressionConfig `envPrefix:"COMPRESSION_"`
+
+	// Порог медленного запроса и сколько худших запросов хранить для /admin/debug/slow.
+	SlowRequests SlowRequestsConfig `envPrefix:"SLOW_REQUESTS_"`
+
+	// Выборочное логирование частых маршрутов: "GET /products:10" пишет в лог каждый 10-й успешный запрос.
+	AccessLogSampling map[string]int `env:"ACCESS_LOG_SAMPLING" envDefault:"GET /health:100"`
+
+	// Способ отправки писем: log (только в лог) или smtp.
+	MailerType string     `env:"MAILER_TYPE" envDefault:"log"`
+	SMTP       SMTPConfig `envPrefix:"SMTP_"`
+
+	Checkout CheckoutConfig `envPrefix:"CHECKOUT_"`
+
+	// Часы работы магазина и праздники. По умолчанию магазин работает круглосуточно.
+	WorkingHours WorkingHoursConfig `envPrefix:"WORKING_HOURS_"`
+
+	// Как часто проверять подписки на повторяющиеся заказы, срок которых наступил.
+	SubscriptionsCheckInterval time.Duration `env:"SUBSCRIPTIONS_CHECK_INTERVAL" envDefault:"1m"`
+	// Как часто проверять регулярные пополнения кошелька, срок которых наступил.
+	ScheduledTopupsCheckInterval time.Duration `env:"SCHEDULED_TOPUPS_CHECK_INTERVAL" envDefault:"1m"`
+
+	// Правила антифрода для пополнений и переводов, 0 отключает правило.
+	Fraud FraudConfig `envPrefix:"FRAUD_"`
+
+	// Валюты счетов кошелька и курсы для переводов между ними.
+	Wallet WalletConfig `envPrefix:"WALLET_"`
+
+	// Где хранить состояние: json (файлы data/ и бэкапы) или sqlite.
+	StorageType string       `env:"STORAGE_TYPE" envDefault:"json"`
+	SQLite      SQLiteConfig `envPrefix:"SQLITE_"`
+
+	// Redis для корзин и списка отозванных токенов. Без адреса они хранятся в памяти.
+	Redis RedisConfig `envPrefix:"REDIS_"`
+
+	Uploads UploadsConfig `envPrefix:"UPLOADS_"`
+
+	// Прокси картинок каталога с внешних хостов.
+	Images ImagesConfig `envPrefix:"IMAGES_"`
+
+	// Сколько товар после createdAt считается новинкой: подборка new и GET /products?collection=new.
+	NewProductsPeriod time.Duration `env:"NEW_PRODUCTS_PERIOD" envDefault:"720h"`
+
+	Payments PaymentsConfig `envPrefix:"PAYMENTS_"`
 
-	ServerOpts        ServerOpts
-	FeedbacksPath     string
-	CreatedTokensPath string
-	Host              string
+	// Ограничения отзывов: картинки, длина текста, запрещенные слова и частота.
+	Reviews ReviewsConfig `envPrefix:"REVIEWS_"`
+
+	// Запись запросов студентов для проверки преподавателем, по умолчанию выключена.
+	Recording RecordingConfig `envPrefix:"RECORDING_"`
+
+	// Повторы и таймаут отправки вебхуков интеграторам.
+	Webhooks WebhooksConfig `envPrefix:"WEBHOOKS_"`
+
+	// Периодичность бэкапов, повторы при ошибке и адрес для писем о провале.
+	Backup BackupConfig `envPrefix:"BACKUP_"`
+
+	// Какой из экземпляров на общем хранилище делает бэкапы и выполняет подписки и пополнения по расписанию.
+	Leader LeaderConfig `envPrefix:"LEADER_"`
+
+	// Сколько адресов может сохранить пользователь и какие адреса считаются дублями.
+	Addresses AddressesConfig `envPrefix:"ADDRESSES_"`
+
+	// Язык каталога, если в Accept-Language нет поддерживаемого. Основные поля товаров и категорий
+	// на русском, остальные языки берутся из переводов.
+	DefaultLanguage string   `env:"DEFAULT_LANGUAGE" envDefault:"ru"`
+	Languages       []string `env:"LANGUAGES" envDefault:"ru,en"`
+
+	// Демо-режим: каждый новый студент получает одинаковые при каждом запуске профиль, кошелек,
+	// заказы и избранное. Включается также флагом --demo.
+	Demo DemoConfig `envPrefix:"DEMO_"`
+
+	// Обновлять файлы data/ старого формата при старте. Если выключено, сервер с такими данными не стартует.
+	DataAutoMigrate bool `env:"DATA_AUTO_MIGRATE" envDefault:"true"`
+	// Не стартовать, если в файлах data/ есть ошибки, например цена ниже нуля или неизвестный товар в категории.
+	// Если выключено, найденные проблемы только пишутся в лог.
+	DataStrict bool `env:"DATA_STRICT" envDefault:"false"`
 }
 
 func GetConfig(logger *zap.SugaredLogger) (*Config, error) {
 	cfg := &Config{
-		ListenPort: ":8080",
 		ServerOpts: ServerOpts{
 			ReadTimeout:          60,
 			WriteTimeout:         60,
 			IdleTimeout:          60,
 			MaxRequestBodySizeMb: 1,
 		},
-		CreatedTokensPath: "data/created_tokens.csv",
-		Host:              "http://eats-pages.ddns.net/uploads/",
+	}
+
+	opts := env.Options{
+		FuncMap: map[reflect.Type]env.ParserFunc{
+			reflect.TypeOf(rsa.PublicKey{}):  ParsePubKey,
+			reflect.TypeOf(rsa.PrivateKey{}): ParsePrivateKey,
+		},
+	}
+
+	err := env.ParseWithOptions(cfg, opts)
+	if err != nil {
+		return nil, fmt.Errorf("env.ParseWithOptions: %w", err)
+	}
+
+	cfg.resolvePaths()
+
+	if cfg.Pagination.Oversize != "clamp" && cfg.Pagination.Oversize != "reject" {
+		return nil, fmt.Errorf("unknown PAGINATION_OVERSIZE %q, should be clamp or reject", cfg.Pagination.Oversize)
+	}
+
+	if cfg.Pagination.MaxPageSize <= 0 {
+		return nil, fmt.Errorf("PAGINATION_MAX_PAGE_SIZE should be positive, got %d", cfg.Pagination.MaxPageSize)
+	}
+
+	if cfg.WorkingHours.OutsideHours != "reject" && cfg.WorkingHours.OutsideHours != "schedule" {
+		return nil, fmt.Errorf(
+			"unknown WORKING_HOURS_OUTSIDE_HOURS %q, should be reject or schedule", cfg.WorkingHours.OutsideHours)
+	}
+
+	if cfg.Checkout.MaxItemQuantity < 0 {
+		return nil, fmt.Errorf("CHECKOUT_MAX_ITEM_QUANTITY can't be negative, got %d", cfg.Checkout.MaxItemQuantity)
+	}
+
+	if cfg.Recording.MaxEntries <= 0 {
+		return nil, fmt.Errorf("RECORDING_MAX_ENTRIES should be positive, got %d", cfg.Recording.MaxEntries)
+	}
+
+	if cfg.SlowRequests.Keep <= 0 {
+		return nil, fmt.Errorf("SLOW_REQUESTS_KEEP should be positive, got %d", cfg.SlowRequests.Keep)
+	}
+
+	if cfg.Webhooks.MaxAttempts <= 0 {
+		return nil, fmt.Errorf("WEBHOOKS_MAX_ATTEMPTS should be positive, got %d", cfg.Webhooks.MaxAttempts)
+	}
+
+	if cfg.Backup.Interval <= 0 || cfg.Backup.RetryAttempts <= 0 {
+		return nil, fmt.Errorf(
+			"BACKUP_INTERVAL and BACKUP_RETRY_ATTEMPTS should be positive, got %s and %d",
+			cfg.Backup.Interval, cfg.Backup.RetryAttempts,
+		)
+	}
+
+	switch cfg.Leader.Lock {
+	case "none", "file":
+	case "redis":
+		if cfg.Redis.Addr == "" {
+			return nil, errors.New("LEADER_LOCK=redis requires REDIS_ADDR")
+		}
+	default:
+		return nil, fmt.Errorf("unknown LEADER_LOCK %q, should be none, file or redis", cfg.Leader.Lock)
+	}
+
+	if cfg.Leader.TTL <= 0 {
+		return nil, fmt.Errorf("LEADER_TTL should be positive, got %s", cfg.Leader.TTL)
+	}
+
+	for class, limit := range cfg.LoadShedding.MaxInFlight {
+		switch class {
+		case "read", "write", "admin", "long_running":
+		default:
+			return nil, fmt.Errorf("unknown class %q in LOAD_SHEDDING_MAX_IN_FLIGHT, should be read, write, admin or long_running", class)
+		}
+
+		if limit < 0 {
+			return nil, fmt.Errorf("LOAD_SHEDDING_MAX_IN_FLIGHT for %s can't be negative, got %d", class, limit)
+		}
+	}
+
+	if cfg.LoadShedding.QueueSize < 0 || cfg.LoadShedding.QueueTimeout < 0 || cfg.LoadShedding.RetryAfter < 0 {
+		return nil, errors.New("LOAD_SHEDDING_QUEUE_SIZE, LOAD_SHEDDING_QUEUE_TIMEOUT and LOAD_SHEDDING_RETRY_AFTER can't be negative")
+	}
+
+	if cfg.Uploads.MaxConcurrent <= 0 {
+		return nil, fmt.Errorf("UPLOADS_MAX_CONCURRENT should be positive, got %d", cfg.Uploads.MaxConcurrent)
+	}
+
+	if cfg.Images.MaxSize <= 0 || cfg.Images.Timeout <= 0 {
+		return nil, errors.New("IMAGES_MAX_SIZE and IMAGES_TIMEOUT should be positive")
+	}
+
+	if cfg.NewProductsPeriod <= 0 {
+		return nil, errors.New("NEW_PRODUCTS_PERIOD should be positive")
+	}
+
+	if cfg.Addresses.MaxPerUser < 0 || cfg.Addresses.DuplicateRadius < 0 {
+		return nil, errors.New("ADDRESSES_MAX_PER_USER and ADDRESSES_DUPLICATE_RADIUS can't be negative")
+	}
+
+	if cfg.Wallet.PINThreshold < 0 {
+		return nil, fmt.Errorf("WALLET_PIN_THRESHOLD can't be negative, got %s", cfg.Wallet.PINThreshold)
+	}
+
+	if cfg.Wallet.PINMaxAttempts <= 0 || cfg.Wallet.PINLockout <= 0 || cfg.Wallet.PINResetCodeTTL <= 0 {
+		return nil, errors.New("WALLET_PIN_MAX_ATTEMPTS, WALLET_PIN_LOCKOUT and WALLET_PIN_RESET_CODE_TTL should be positive")
+	}
+
+	// Без курса в валюте нельзя ни пополнить счет, ни перевести с него
+	for _, currency := range cfg.Wallet.Currencies {
+		if rate, ok := cfg.Wallet.Rates[currency]; currency != models.CurrencyRUB && (!ok || rate <= 0) {
+			return nil, fmt.Errorf("WALLET_RATES has no positive rate for %s from WALLET_CURRENCIES", currency)
+		}
+	}
+
+	// Данные старого формата обновляем до загрузки, с данными новее сервера не стартуем
+	if cfg.DataAutoMigrate {
+		if err := migrations.Migrate(cfg.DataDir, logger); err != nil {
+			return nil, fmt.Errorf("migrations.Migrate: %w", err)
+		}
+	}
+
+	if err := migrations.Check(cfg.DataDir); err != nil {
+		return nil, fmt.Errorf("migrations.Check: %w", err)
+	}
+
+	report := datacheck.Validate(cfg.DataDir)
+	if cfg.DataStrict && report.HasErrors() {
+		return nil, fmt.Errorf("data validation failed (DATA_STRICT=true):\n%s", report)
+	}
+
+	for _, issue := range report.Issues {
+		logger.Warnf("Data check: %s", issue)
 	}
 
 	// Загружаем товары и преобразуем в указатели
-	products, err := getInitData[models.Product]("data/products.json", logger)
+	products, err := getInitData[models.Product](cfg.dataFile("products.json"), logger)
 	if err != nil {
+		// Отсутствующий файл не ошибка, а испорченный - ошибка: иначе сервер молча стартует без данных
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("can't load products: %w", err)
+		}
+
 		logger.Warnf("Can't load products from file: %v", err)
 		cfg.InitialProductsData = []*models.Product{}
 	} else {
 		cfg.InitialProductsData = make([]*models.Product, len(products))
 		for i := range products {
-			products[i].Image = cfg.Host + products[i].Image
+			// В бэкапах каталога ссылки на картинки уже полные
+			if !strings.Contains(products[i].Image, "://") {
+				products[i].Image = cfg.Host + products[i].Image
+			}
+
 			cfg.InitialProductsData[i] = &products[i]
 		}
 	}
 
 	// Загружаем категории и преобразуем в map
-	categories, err := getInitData[models.Category]("data/categories.json", logger)
+	categories, err := getInitData[models.Category](cfg.dataFile("categories.json"), logger)
 	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("can't load categories: %w", err)
+		}
+
 		logger.Warnf("Can't load categories from file: %v", err)
 		cfg.InitialCategories = map[string]models.Category{}
 	} else {
@@ -89,17 +368,95 @@ func GetConfig(logger *zap.SugaredLogger) (*Config, error) {
 	}
 
 	// Загружаем связки товаров и категорий
-	productCategories, err := getProductCategories("data/product_categories.json", logger)
+	productCategories, err := getProductCategories(cfg.dataFile("product_categories.json"), logger)
 	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("can't load product categories: %w", err)
+		}
+
 		logger.Warnf("Can't load product categories from file: %v", err)
 		cfg.InitialProductCategories = map[string][]string{}
 	} else {
 		cfg.InitialProductCategories = productCategories
 	}
 
+	combos, err := getInitData[models.Combo](cfg.dataFile("combos.json"), logger)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("can't load combos: %w", err)
+		}
+
+		logger.Warnf("Can't load combos from file: %v", err)
+		cfg.InitialCombos = []models.Combo{}
+	} else {
+		if err := validateCombos(combos); err != nil {
+			return nil, err
+		}
+
+		for i := range combos {
+			if combos[i].Image != "" && !strings.Contains(combos[i].Image, "://") {
+				combos[i].Image = cfg.Host + combos[i].Image
+			}
+		}
+
+		cfg.InitialCombos = combos
+	}
+
+	orderExtras, err := getInitData[models.OrderExtra](cfg.dataFile("order_extras.json"), logger)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("can't load order extras: %w", err)
+		}
+
+		logger.Warnf("Can't load order extras from file: %v", err)
+		cfg.InitialOrderExtras = []models.OrderExtra{}
+	} else {
+		if err := validateOrderExtras(orderExtras); err != nil {
+			return nil, err
+		}
+
+		cfg.InitialOrderExtras = orderExtras
+	}
+
+	zones, err := getDeliveryZones(cfg.dataFile("delivery_zones.json"), logger)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("can't load delivery zones: %w", err)
+		}
+
+		logger.Warnf("Can't load delivery zones from file: %v", err)
+		cfg.InitialDeliveryZones = []*models.DeliveryZone{}
+	} else {
+		if err := validateDeliveryZones(zones); err != nil {
+			return nil, err
+		}
+
+		cfg.InitialDeliveryZones = zones
+	}
+
+	collections, err := getCollections(cfg.dataFile("collections.json"), logger)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("can't load collections: %w", err)
+		}
+
+		logger.Warnf("Can't load collections from file: %v", err)
+		cfg.InitialCollections = []*models.Collection{}
+	} else {
+		if err := validateCollections(collections); err != nil {
+			return nil, err
+		}
+
+		cfg.InitialCollections = collections
+	}
+
 	// Загружаем заблокированные токены
-	bannedTokens, err := getInitData[string]("data/blocked_tokens.json", logger)
+	bannedTokens, err := getInitData[string](cfg.dataFile("blocked_tokens.json"), logger)
 	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("can't load banned tokens: %w", err)
+		}
+
 		logger.Warnf("Can't load banned tokens from file: %v", err)
 		cfg.RevokedTokens = []string{}
 	} else {
@@ -107,8 +464,12 @@ func GetConfig(logger *zap.SugaredLogger) (*Config, error) {
 	}
 
 	// Загружаем профили пользователей
-	userProfiles, err := getUserProfiles("data/user_profiles.json", logger)
+	userProfiles, err := getUserProfiles(cfg.dataFile("user_profiles.json"), logger)
 	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("can't load user profiles: %w", err)
+		}
+
 		logger.Warnf("Can't load user profiles from file: %v", err)
 		cfg.InitialUserProfiles = make(map[string]*models.UserProfile)
 	} else {
@@ -116,8 +477,12 @@ func GetConfig(logger *zap.SugaredLogger) (*Config, error) {
 	}
 
 	// Загружаем корзины пользователей
-	cartItems, err := getCartItems("data/cart_items.json", logger)
+	cartItems, err := getCartItems(cfg.dataFile("cart_items.json"), logger)
 	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("can't load cart items: %w", err)
+		}
+
 		logger.Warnf("Can't load cart items from file: %v", err)
 		cfg.InitialCartItems = make(map[string]map[string]*models.CartItem)
 	} else {
@@ -125,8 +490,12 @@ func GetConfig(logger *zap.SugaredLogger) (*Config, error) {
 	}
 
 	// Загружаем избранное пользователей
-	favourites, err := getFavourites("data/user_favourites.json", logger)
+	favourites, err := getFavourites(cfg.dataFile("user_favourites.json"), logger)
 	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("can't load favourites: %w", err)
+		}
+
 		logger.Warnf("Can't load favourites from file: %v", err)
 		cfg.InitialFavourites = make(map[string][]string)
 	} else {
@@ -134,8 +503,12 @@ func GetConfig(logger *zap.SugaredLogger) (*Config, error) {
 	}
 
 	// Загружаем заказы пользователей
-	orders, err := getOrders("data/orders.json", logger)
+	orders, err := getOrders(cfg.dataFile("orders.json"), logger)
 	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("can't load orders: %w", err)
+		}
+
 		logger.Warnf("Can't load orders from file: %v", err)
 		cfg.InitialOrders = make(map[string][]*models.Order)
 	} else {
@@ -143,30 +516,141 @@ func GetConfig(logger *zap.SugaredLogger) (*Config, error) {
 	}
 
 	// Загружаем данные кошелька
-	walletData, err := getWalletData("data/wallet_data.json", logger)
+	walletData, err := getWalletData(cfg.dataFile("wallet_data.json"), logger)
 	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("can't load wallet data: %w", err)
+		}
+
 		logger.Warnf("Can't load wallet data from file: %v", err)
 		// Инициализируем пустые данные кошелька
 		cfg.InitialWalletData = models.WalletData{
 			Accounts:     make(map[string]map[string]*models.Account),
 			Transactions: make(map[string][]models.Transaction),
-			DailyTopups:  make(map[string]map[string]int),
+			DailyTopups:  make(map[string]map[string]models.Money),
 			UserPhones:   make(map[string]string),
 		}
 	} else {
 		cfg.InitialWalletData = walletData
 	}
 
-	opts := env.Options{
-		FuncMap: map[reflect.Type]env.ParserFunc{
-			reflect.TypeOf(rsa.PublicKey{}):  ParsePubKey,
-			reflect.TypeOf(rsa.PrivateKey{}): ParsePrivateKey,
-		},
+	notifications, err := getNotifications(cfg.dataFile("notifications.json"), logger)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("can't load notifications: %w", err)
+		}
+
+		logger.Warnf("Can't load notifications from file: %v", err)
+	} else {
+		cfg.InitialNotifications = notifications
 	}
 
-	err = env.ParseWithOptions(cfg, opts)
+	shoppingLists, err := getShoppingLists(cfg.dataFile("shopping_lists.json"), logger)
 	if err != nil {
-		return nil, fmt.Errorf("env.ParseWithOptions: %w", err)
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("can't load shopping lists: %w", err)
+		}
+
+		logger.Warnf("Can't load shopping lists from file: %v", err)
+		cfg.InitialShoppingLists = make(map[string][]*models.ShoppingList)
+	} else {
+		cfg.InitialShoppingLists = shoppingLists
+	}
+
+	subscriptions, err := getSubscriptions(cfg.dataFile("subscriptions.json"), logger)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("can't load subscriptions: %w", err)
+		}
+
+		logger.Warnf("Can't load subscriptions from file: %v", err)
+		cfg.InitialSubscriptions = make(map[string][]*models.Subscription)
+	} else {
+		cfg.InitialSubscriptions = subscriptions
+	}
+
+	scheduledTopups, err := getScheduledTopups(cfg.dataFile("scheduled_topups.json"), logger)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("can't load scheduled topups: %w", err)
+		}
+
+		logger.Warnf("Can't load scheduled topups from file: %v", err)
+		cfg.InitialScheduledTopups = make(map[string][]*models.ScheduledTopup)
+	} else {
+		cfg.InitialScheduledTopups = scheduledTopups
+	}
+
+	icons, err := getTransactionIcons(cfg.dataFile("transaction_icons.json"), logger)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("can't load transaction icons: %w", err)
+		}
+
+		logger.Warnf("Can't load transaction icons from file: %v", err)
+		cfg.InitialTransactionIcons = make(map[models.IconKind]string)
+	} else {
+		cfg.InitialTransactionIcons = icons
+	}
+
+	payments, err := getPayments(cfg.dataFile("payments.json"), logger)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("can't load payments: %w", err)
+		}
+
+		logger.Warnf("Can't load payments from file: %v", err)
+		cfg.InitialPayments = make(map[string][]*models.Payment)
+	} else {
+		cfg.InitialPayments = payments
+	}
+
+	uploads, err := getUploads(cfg.dataFile("uploads.json"), logger)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("can't load uploads: %w", err)
+		}
+
+		logger.Warnf("Can't load uploads from file: %v", err)
+		cfg.InitialUploads = make(map[string][]models.UploadedFile)
+	} else {
+		cfg.InitialUploads = uploads
+	}
+
+	webhooks, err := getWebhooks(cfg.dataFile("webhooks.json"), logger)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("can't load webhooks: %w", err)
+		}
+
+		logger.Warnf("Can't load webhooks from file: %v", err)
+		cfg.InitialWebhooks = []*models.Webhook{}
+	} else {
+		cfg.InitialWebhooks = webhooks
+	}
+
+	suspensions, err := getSuspensions(cfg.dataFile("user_suspensions.json"), logger)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("can't load user suspensions: %w", err)
+		}
+
+		logger.Warnf("Can't load user suspensions from file: %v", err)
+		cfg.InitialSuspensions = []*models.UserSuspension{}
+	} else {
+		cfg.InitialSuspensions = suspensions
+	}
+
+	experiments, err := getExperiments(cfg.dataFile("experiments.json"), logger)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("can't load experiments: %w", err)
+		}
+
+		logger.Warnf("Can't load experiments from file: %v", err)
+		cfg.InitialExperiments = []*models.Experiment{}
+	} else {
+		cfg.InitialExperiments = experiments
 	}
 
 	return cfg, nil
@@ -177,6 +661,223 @@ type ServerOpts struct {
 	WriteTimeout         int `json:"write_timeout"`
 	IdleTimeout          int `json:"idle_timeout"`
 	MaxRequestBodySizeMb int `json:"max_request_body_size_mb"`
+	// Страница документации, которую сервер отдает на GET /docs.
+	DocsPage string `json:"docs_page" env:"DOCS_PAGE" envDefault:"redoc-static.html"`
+}
+
+type FraudConfig struct {
+	MaxTransfersPerHour            int           `env:"MAX_TRANSFERS_PER_HOUR" envDefault:"10"`
+	MaxTopupsPerHour               int           `env:"MAX_TOPUPS_PER_HOUR" envDefault:"10"`
+	MaxAmountPerCounterpartyPerDay models.Money  `env:"MAX_AMOUNT_PER_COUNTERPARTY_PER_DAY" envDefault:"10000"`
+	NewRecipientCooldown           time.Duration `env:"NEW_RECIPIENT_COOLDOWN" envDefault:"0"`
+}
+
+type DemoConfig struct {
+	Enabled bool `env:"ENABLED" envDefault:"false"`
+	// Seed генератора демо-данных: при одном seed у пользователя всегда одни и те же данные.
+	Seed uint64 `env:"SEED" envDefault:"1"`
+}
+
+type WalletConfig struct {
+	// В каких валютах можно открыть счет.
+	Currencies []models.Currency `env:"CURRENCIES" envDefault:"RUB,USD,EUR"`
+	// Статическая таблица курсов: сколько рублей стоит единица валюты, "USD:90,EUR:100".
+	Rates map[models.Currency]float64 `env:"RATES" envDefault:"USD:90,EUR:100"`
+	// Переводы и оплаты кошельком от этой суммы в рублях требуют PIN, если пользователь его установил.
+	PINThreshold models.Money `env:"PIN_THRESHOLD" envDefault:"1000"`
+	// После стольких ошибок подряд подтверждение PIN блокируется на PIN_LOCKOUT.
+	PINMaxAttempts int           `env:"PIN_MAX_ATTEMPTS" envDefault:"5"`
+	PINLockout     time.Duration `env:"PIN_LOCKOUT" envDefault:"15m"`
+	// Сколько действует код для сброса забытого PIN.
+	PINResetCodeTTL time.Duration `env:"PIN_RESET_CODE_TTL" envDefault:"10m"`
+}
+
+type SMTPConfig struct {
+	Host     string `env:"HOST"`
+	Port     int    `env:"PORT" envDefault:"587"`
+	Username string `env:"USERNAME"`
+	Password string `env:"PASSWORD"`
+	From     string `env:"FROM"`
+}
+
+type CheckoutConfig struct {
+	// Координаты магазина [долгота, широта], от них считается расстояние доставки.
+	StoreCoordinates   []float64    `env:"STORE_COORDINATES" envDefault:"37.6176,55.7558"`
+	DeliveryBasePrice  models.Money `env:"DELIVERY_BASE_PRICE" envDefault:"150"`
+	DeliveryPricePerKm models.Money `env:"DELIVERY_PRICE_PER_KM" envDefault:"20"`
+	// Промокоды и скидка на товары в процентах: "WELCOME10:10,STUDENT:15".
+	PromoCodes map[string]int `env:"PROMO_CODES" envDefault:"WELCOME10:10"`
+	// Процент от стоимости товаров, который начисляется баллами.
+	LoyaltyPercent int `env:"LOYALTY_PERCENT" envDefault:"5"`
+	// Сколько действует фиксация цен из предварительного расчета.
+	PriceLockTTL time.Duration `env:"PRICE_LOCK_TTL" envDefault:"10m"`
+	// Минимальная сумма заказа и стоимость товаров, с которой доставка бесплатна. 0 - ограничения нет.
+	MinOrderAmount        models.Money `env:"MIN_ORDER_AMOUNT" envDefault:"0"`
+	FreeDeliveryThreshold models.Money `env:"FREE_DELIVERY_THRESHOLD" envDefault:"0"`
+	// Сколько единиц одного товара можно положить в корзину и заказать. 0 - ограничения нет.
+	// Ограничение отдельного товара задается полем maxQuantity в products.json.
+	MaxItemQuantity int `env:"MAX_ITEM_QUANTITY" envDefault:"0"`
+}
+
+type WorkingHoursConfig struct {
+	Timezone string `env:"TIMEZONE" envDefault:"Europe/Moscow"`
+	// Часы по дням недели: "daily:09:00-23:00,sat:10:00-22:00,sun:closed". Пусто - круглосуточно.
+	Schedule map[string]string `env:"SCHEDULE"`
+	// Даты, когда магазин закрыт весь день: "2026-12-31,2027-01-01".
+	Holidays []string `env:"HOLIDAYS"`
+	// Заказы в нерабочее время: reject - отклонять, schedule - принимать к открытию.
+	OutsideHours string `env:"OUTSIDE_HOURS" envDefault:"reject"`
+}
+
+type ReviewsConfig struct {
+	MaxImages int `env:"MAX_IMAGES" envDefault:"5"`
+	MinLength int `env:"MIN_LENGTH" envDefault:"0"`
+	MaxLength int `env:"MAX_LENGTH" envDefault:"2000"`
+	MaxPerDay int `env:"MAX_PER_DAY" envDefault:"10"`
+	// Запрещенные слова через запятую: "дурак,редиска".
+	BannedWords []string `env:"BANNED_WORDS"`
+}
+
+type RecordingConfig struct {
+	Enabled bool `env:"ENABLED" envDefault:"false"`
+	// Сколько последних запросов хранить на каждого студента.
+	MaxEntries int `env:"MAX_ENTRIES" envDefault:"500"`
+	// Тела запросов и ответов длиннее этого размера в байтах обрезаются.
+	MaxBodySize int `env:"MAX_BODY_SIZE" envDefault:"4096"`
+}
+
+type SlowRequestsConfig struct {
+	// Запросы дольше порога пишутся в лог с временем участков сервисов. 0 отключает отслеживание.
+	Threshold time.Duration `env:"THRESHOLD" envDefault:"500ms"`
+	// Сколько самых долгих запросов хранить.
+	Keep int `env:"KEEP" envDefault:"50"`
+}
+
+type WebhooksConfig struct {
+	// Сколько раз пытаться доставить событие, прежде чем отметить отправку проваленной.
+	MaxAttempts int `env:"MAX_ATTEMPTS" envDefault:"5"`
+	// Задержка перед первым повтором, дальше она удваивается.
+	Backoff time.Duration `env:"BACKOFF" envDefault:"5s"`
+	Timeout time.Duration `env:"TIMEOUT" envDefault:"5s"`
+}
+
+type BackupConfig struct {
+	Interval time.Duration `env:"INTERVAL" envDefault:"24h"`
+	// Сколько раз пытаться сделать бэкап по таймеру, прежде чем считать его проваленным.
+	RetryAttempts int `env:"RETRY_ATTEMPTS" envDefault:"3"`
+	// Задержка перед первым повтором, дальше она удваивается.
+	RetryBackoff time.Duration `env:"RETRY_BACKOFF" envDefault:"30s"`
+	// Кому писать, когда бэкап не удался после всех повторов. Без адреса провал только пишется в лог.
+	AlertEmail string `env:"ALERT_EMAIL"`
+}
+
+type LeaderConfig struct {
+	// none - экземпляр один и всегда выполняет задачи, file - блокировка файла, redis - ключ в Redis из REDIS_ADDR.
+	Lock string `env:"LOCK" envDefault:"none"`
+	// Файл блокировки для file, по умолчанию DATA_DIR/leader.lock.
+	LockFile string `env:"LOCK_FILE"`
+	// Время жизни блокировки в Redis: за столько упавший лидер сменится другим экземпляром.
+	TTL time.Duration `env:"TTL" envDefault:"15s"`
+}
+
+type AddressesConfig struct {
+	// 0 - без ограничения.
+	MaxPerUser int `env:"MAX_PER_USER" envDefault:"20"`
+	// Радиус в метрах, в котором новый адрес считается дублем сохраненного, 0 - сравнивается только строка адреса.
+	DuplicateRadius int `env:"DUPLICATE_RADIUS" envDefault:"30"`
+}
+
+type SQLiteConfig struct {
+	// По умолчанию DATA_DIR/eats.db.
+	Path string `env:"PATH"`
+	// Как часто сохранять состояние сервисов в базу.
+	SaveInterval time.Duration `env:"SAVE_INTERVAL" envDefault:"30s"`
+}
+
+type PaginationConfig struct {
+	MaxPageSize int `env:"MAX_PAGE_SIZE" envDefault:"100"`
+	// Что делать с pageSize больше максимума: clamp - уменьшить до максимума, reject - ответить 400.
+	Oversize string `env:"OVERSIZE" envDefault:"clamp"`
+}
+
+type LoadSheddingConfig struct {
+	Enabled bool `env:"ENABLED" envDefault:"true"`
+	// Сколько запросов класса обрабатывается одновременно, 0 - без ограничения. Классы: read (GET),
+	// write (остальные методы), admin (маршруты преподавателя), long_running (выгрузки и потоки).
+	MaxInFlight map[string]int `env:"MAX_IN_FLIGHT" envDefault:"read:200,write:100,admin:20,long_running:4"`
+	// Сколько запросов каждого класса может ждать места и сколько ждать, дальше - 503.
+	QueueSize    int           `env:"QUEUE_SIZE" envDefault:"50"`
+	QueueTimeout time.Duration `env:"QUEUE_TIMEOUT" envDefault:"500ms"`
+	// Через сколько клиенту повторить запрос, округляется вверх до секунд.
+	RetryAfter time.Duration `env:"RETRY_AFTER" envDefault:"1s"`
+}
+
+type CompressionConfig struct {
+	Enabled bool `env:"ENABLED" envDefault:"true"`
+	// Ответы меньше этого размера в байтах отдаются без сжатия.
+	MinSize int `env:"MIN_SIZE" envDefault:"1024"`
+	// Какие типы ответов сжимать, "text/*" подходит для всех текстовых. Картинки и архивы уже сжаты.
+	ContentTypes []string `env:"CONTENT_TYPES" envDefault:"application/json,text/*,application/javascript,application/xml,image/svg+xml"`
+}
+
+type UploadsConfig struct {
+	// Ключ подписи ссылок на загрузку. Если не задан, генерируется при запуске,
+	// и выданные ссылки перестают работать после перезапуска.
+	SigningKey string `env:"SIGNING_KEY"`
+	// Время жизни подписанной ссылки.
+	PresignTTL time.Duration `env:"PRESIGN_TTL" envDefault:"15m"`
+	// Сколько загрузок может обрабатываться одновременно, остальные сразу получают 503.
+	MaxConcurrent int `env:"MAX_CONCURRENT" envDefault:"4"`
+	// Отдавать файлы только по подписанным ссылкам из GET /uploads/{name}/link.
+	Private bool `env:"PRIVATE" envDefault:"false"`
+	// Каталог загруженных файлов, по умолчанию DATA_DIR/uploads.
+	Dir string `env:"DIR"`
+}
+
+type ImagesConfig struct {
+	// Хосты, картинки с которых отдаются через /img/{hash}, вместе с поддоменами. Пусто - прокси выключен.
+	AllowedHosts []string `env:"ALLOWED_HOSTS" envDefault:"wbbasket.ru"`
+	// Картинки больше этого размера в байтах не скачиваются.
+	MaxSize int64 `env:"MAX_SIZE" envDefault:"5242880"`
+	// Сколько ждать ответа исходного хоста.
+	Timeout time.Duration `env:"TIMEOUT" envDefault:"10s"`
+	// Адрес, от которого строятся ссылки на картинки, по умолчанию /img/ на хосте из UPLOADS_HOST.
+	BaseURL string `env:"BASE_URL"`
+	// Каталог скачанных картинок, по умолчанию DATA_DIR/images.
+	Dir string `env:"DIR"`
+}
+
+type TLSConfig struct {
+	CertFile string `env:"CERT_FILE"`
+	KeyFile  string `env:"KEY_FILE"`
+	// Хосты для сертификата Let's Encrypt, например eats-pages.ddns.net.
+	AutocertHosts []string `env:"AUTOCERT_HOSTS"`
+	// По умолчанию DATA_DIR/autocert.
+	AutocertCacheDir string `env:"AUTOCERT_CACHE_DIR"`
+	// Адрес HTTP-сервера, который перенаправляет на HTTPS, обычно ":80".
+	RedirectAddr string `env:"REDIRECT_ADDR"`
+}
+
+type BootstrapConfig struct {
+	User     string `env:"USER" envDefault:"admin"`
+	Password string `env:"PASSWORD"`
+}
+
+type PaymentsConfig struct {
+	// Адрес сервера, от него строятся ссылки на страницу оплаты песочницы.
+	BaseURL string `env:"BASE_URL" envDefault:"http://eats-pages.ddns.net/"`
+	// Ключ подписи уведомлений песочницы. Если не задан, генерируется при запуске.
+	SandboxSecret string `env:"SANDBOX_SECRET"`
+	// Максимальная сумма одного пополнения через провайдера.
+	MaxAmount models.Money `env:"MAX_AMOUNT" envDefault:"15000"`
+}
+
+type RedisConfig struct {
+	Addr     string `env:"ADDR"`
+	Password string `env:"PASSWORD"`
+	DB       int    `env:"DB"`
+	// Префикс ключей, чтобы несколько стендов могли делить один Redis.
+	KeyPrefix string `env:"KEY_PREFIX" envDefault:"eats:"`
 }
 
 // ParsePubKey public keys loader for github.com/caarlos0/env/v11 lib.
@@ -259,8 +960,106 @@ func loadJSONFile[T any](filePath string, logger *zap.SugaredLogger) (T, error)
 	return result, nil
 }
 
+// validateCombos проверяет, что у каждого набора есть товары и положительная цена
+func validateCombos(combos []models.Combo) error {
+	seen := make(map[string]struct{}, len(combos))
+
+	for _, combo := range combos {
+		if combo.ID == "" || len(combo.Items) == 0 || combo.Price <= 0 {
+			return fmt.Errorf("combo %q must have id, items and positive price", combo.ID)
+		}
+
+		if _, ok := seen[combo.ID]; ok {
+			return fmt.Errorf("duplicate combo %q", combo.ID)
+		}
+
+		seen[combo.ID] = struct{}{}
+
+		for _, item := range combo.Items {
+			if item.ProductID == "" || item.Quantity <= 0 {
+				return fmt.Errorf("combo %q has item without id or with non-positive quantity", combo.ID)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateOrderExtras проверяет, что у опций заказа есть уникальный ID, название и неотрицательная доплата
+func validateOrderExtras(extras []models.OrderExtra) error {
+	seen := make(map[string]struct{}, len(extras))
+
+	for _, extra := range extras {
+		if extra.ID == "" || extra.Name == "" || extra.Surcharge < 0 {
+			return fmt.Errorf("order extra %q must have id, name and non-negative surcharge", extra.ID)
+		}
+
+		if _, ok := seen[extra.ID]; ok {
+			return fmt.Errorf("duplicate order extra %q", extra.ID)
+		}
+
+		seen[extra.ID] = struct{}{}
+	}
+
+	return nil
+}
+
+func validateCollections(collections []*models.Collection) error {
+	seen := make(map[string]struct{}, len(collections))
+
+	for _, collection := range collections {
+		if collection == nil || collection.ID == "" || collection.Name == "" {
+			return errors.New("collection must have id and name")
+		}
+
+		if collection.ID == models.CollectionNew {
+			return fmt.Errorf("collection %q is built in and can't be defined in collections.json", collection.ID)
+		}
+
+		if collection.ActiveFrom != nil && collection.ActiveTo != nil && !collection.ActiveFrom.Before(*collection.ActiveTo) {
+			return fmt.Errorf("collection %q activeFrom should be before activeTo", collection.ID)
+		}
+
+		if _, ok := seen[collection.ID]; ok {
+			return fmt.Errorf("duplicate collection %q", collection.ID)
+		}
+
+		seen[collection.ID] = struct{}{}
+	}
+
+	return nil
+}
+
+func validateDeliveryZones(zones []*models.DeliveryZone) error {
+	seen := make(map[string]struct{}, len(zones))
+
+	for _, zone := range zones {
+		if zone.ID == "" || zone.Name == "" {
+			return fmt.Errorf("delivery zone %q must have id and name", zone.ID)
+		}
+
+		if len(zone.Polygon) < 3 {
+			return fmt.Errorf("delivery zone %q polygon must have at least 3 points", zone.ID)
+		}
+
+		for _, point := range zone.Polygon {
+			if len(point) != 2 {
+				return fmt.Errorf("delivery zone %q polygon points must be [longitude, latitude]", zone.ID)
+			}
+		}
+
+		if _, ok := seen[zone.ID]; ok {
+			return fmt.Errorf("duplicate delivery zone %q", zone.ID)
+		}
+
+		seen[zone.ID] = struct{}{}
+	}
+
+	return nil
+}
+
 type loadable interface {
-	string | models.Product | models.Category
+	string | models.Product | models.Category | models.Combo | models.OrderExtra
 }
 
 func getInitData[T loadable](filePath string, logger *zap.SugaredLogger) ([]T, error) {
@@ -291,6 +1090,112 @@ func getOrders(filePath string, logger *zap.SugaredLogger) (map[string][]*models
 	return loadJSONFile[map[string][]*models.Order](filePath, logger)
 }
 
+// getNotifications загружает лист ожидания товаров и уведомления из файла
+func getNotifications(filePath string, logger *zap.SugaredLogger) (models.NotificationsData, error) {
+	return loadJSONFile[models.NotificationsData](filePath, logger)
+}
+
+// getShoppingLists загружает списки покупок пользователей из файла
+func getShoppingLists(filePath string, logger *zap.SugaredLogger) (map[string][]*models.ShoppingList, error) {
+	return loadJSONFile[map[string][]*models.ShoppingList](filePath, logger)
+}
+
+// getTransactionIcons загружает иконки транзакций из файла
+func getTransactionIcons(filePath string, logger *zap.SugaredLogger) (map[models.IconKind]string, error) {
+	return loadJSONFile[map[models.IconKind]string](filePath, logger)
+}
+
+// getPayments загружает пополнения через платежного провайдера из файла
+func getPayments(filePath string, logger *zap.SugaredLogger) (map[string][]*models.Payment, error) {
+	return loadJSONFile[map[string][]*models.Payment](filePath, logger)
+}
+
+// resolvePaths заполняет незаданные пути от каталога данных
+func (c *Config) resolvePaths() {
+	if c.CreatedTokensPath == "" {
+		c.CreatedTokensPath = c.dataFile("created_tokens.csv")
+	}
+
+	if c.Uploads.Dir == "" {
+		c.Uploads.Dir = c.dataFile("uploads")
+	}
+
+	if c.Images.Dir == "" {
+		c.Images.Dir = c.dataFile("images")
+	}
+
+	if c.Leader.LockFile == "" {
+		c.Leader.LockFile = c.dataFile("leader.lock")
+	}
+
+	if c.SQLite.Path == "" {
+		c.SQLite.Path = c.dataFile("eats.db")
+	}
+
+	if c.TLS.AutocertCacheDir == "" {
+		c.TLS.AutocertCacheDir = c.dataFile("autocert")
+	}
+
+	if !strings.HasSuffix(c.Host, "/") {
+		c.Host += "/"
+	}
+
+	// Картинки отдает тот же сервер, что и загрузки
+	if c.Images.BaseURL == "" {
+		if host, err := url.Parse(c.Host); err == nil {
+			c.Images.BaseURL = host.ResolveReference(&url.URL{Path: "/img/"}).String()
+		}
+	}
+
+	if !strings.HasSuffix(c.Images.BaseURL, "/") {
+		c.Images.BaseURL += "/"
+	}
+}
+
+// dataFile возвращает путь к файлу в каталоге данных
+func (c *Config) dataFile(name string) string {
+	return filepath.Join(c.DataDir, name)
+}
+
+// getUploads загружает список загруженных пользователями файлов
+func getUploads(filePath string, logger *zap.SugaredLogger) (map[string][]models.UploadedFile, error) {
+	return loadJSONFile[map[string][]models.UploadedFile](filePath, logger)
+}
+
+// getWebhooks загружает вебхуки интеграторов из файла
+func getWebhooks(filePath string, logger *zap.SugaredLogger) ([]*models.Webhook, error) {
+	return loadJSONFile[[]*models.Webhook](filePath, logger)
+}
+
+// getSuspensions загружает заблокированных пользователей из файла
+func getSuspensions(filePath string, logger *zap.SugaredLogger) ([]*models.UserSuspension, error) {
+	return loadJSONFile[[]*models.UserSuspension](filePath, logger)
+}
+
+// getDeliveryZones загружает зоны доставки из файла
+func getDeliveryZones(filePath string, logger *zap.SugaredLogger) ([]*models.DeliveryZone, error) {
+	return loadJSONFile[[]*models.DeliveryZone](filePath, logger)
+}
+
+// getCollections загружает подборки товаров из файла
+func getCollections(filePath string, logger *zap.SugaredLogger) ([]*models.Collection, error) {
+	return loadJSONFile[[]*models.Collection](filePath, logger)
+}
+
+// getExperiments загружает A/B эксперименты из файла
+func getExperiments(filePath string, logger *zap.SugaredLogger) ([]*models.Experiment, error) {
+	return loadJSONFile[[]*models.Experiment](filePath, logger)
+}
+
+// getSubscriptions загружает подписки на повторяющиеся заказы из файла
+func getSubscriptions(filePath string, logger *zap.SugaredLogger) (map[string][]*models.Subscription, error) {
+	return loadJSONFile[map[string][]*models.Subscription](filePath, logger)
+}
+
+func getScheduledTopups(filePath string, logger *zap.SugaredLogger) (map[string][]*models.ScheduledTopup, error) {
+	return loadJSONFile[map[string][]*models.ScheduledTopup](filePath, logger)
+}
+
 // getWalletData загружает данные кошелька из файла
 func getWalletData(filePath string, logger *zap.SugaredLogger) (models.WalletData, error) {
 	return loadJSONFile[models.WalletData](filePath, logger)
@@ -0,0 +1,543 @@
+package datacheck
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"eats-backend/internal/models"
+)
+
+// Validate проверяет все известные файлы каталога dir. Файлы, которых нет, сервер заменяет пустыми данными,
+// поэтому ошибкой считается только отсутствие каталога товаров и категорий.
+func Validate(dir string) Report {
+	c := &checker{
+		dir:        dir,
+		report:     Report{Dir: dir, Checked: []string{}, Missing: []string{}, Issues: []Issue{}},
+		products:   map[string]struct{}{},
+		categories: map[string]struct{}{},
+		combos:     map[string]struct{}{},
+	}
+
+	// Каталог проверяем первым: по нему проверяются ссылки в остальных файлах
+	c.checkProducts()
+	c.checkCategories()
+	c.checkProductCategories()
+	c.checkCombos()
+	c.checkOrderExtras()
+	c.checkDeliveryZones()
+	c.checkCollections()
+	c.checkFavourites()
+	c.checkCartItems()
+	c.checkOrders()
+	c.checkWallet()
+	c.checkUserProfiles()
+
+	// Для остальных файлов достаточно, что они разбираются в модели
+	c.load("blocked_tokens.json", false, &[]string{})
+	c.load("notifications.json", false, &models.NotificationsData{})
+	c.load("shopping_lists.json", false, &map[string][]*models.ShoppingList{})
+	c.load("subscriptions.json", false, &map[string][]*models.Subscription{})
+	c.load("scheduled_topups.json", false, &map[string][]*models.ScheduledTopup{})
+	c.load("transaction_icons.json", false, &map[models.IconKind]string{})
+	c.load("payments.json", false, &map[string][]*models.Payment{})
+	c.load("uploads.json", false, &map[string][]models.UploadedFile{})
+	c.load("webhooks.json", false, &[]*models.Webhook{})
+	c.load("user_suspensions.json", false, &[]*models.UserSuspension{})
+	c.load("experiments.json", false, &[]*models.Experiment{})
+
+	return c.report
+}
+
+func (c *checker) checkProducts() {
+	const file = "products.json"
+
+	var products []models.Product
+	if !c.load(file, true, &products) {
+		return
+	}
+
+	for i, product := range products {
+		path := fmt.Sprintf("[%d]", i)
+
+		if product.ID == "" || product.Name == "" {
+			c.errorf(file, path, "product must have id and name")
+		}
+
+		if _, ok := c.products[product.ID]; ok && product.ID != "" {
+			c.errorf(file, path+".id", "duplicate product %q", product.ID)
+		}
+
+		c.products[product.ID] = struct{}{}
+
+		if product.Price < 0 {
+			c.errorf(file, path+".price", "negative price %s", product.Price)
+		}
+
+		if product.Discount < 0 || product.Discount >= 100 {
+			c.errorf(file, path+".discount", "discount %d should be from 0 to 99", product.Discount)
+		}
+
+		if product.Rating < 0 || product.Rating > 10 {
+			c.errorf(file, path+".rating", "rating %v should be from 0 to 10", product.Rating)
+		}
+
+		if product.Weight < 0 {
+			c.errorf(file, path+".weight", "negative weight %d", product.Weight)
+		}
+
+		if product.MaxQuantity < 0 {
+			c.errorf(file, path+".maxQuantity", "negative max quantity %d", product.MaxQuantity)
+		}
+
+		if product.AvailableHours != "" && !validTimeRange(product.AvailableHours) {
+			c.errorf(file, path+".availableHours", "malformed hours %q, should be HH:MM-HH:MM", product.AvailableHours)
+		}
+
+		if product.CreatedAt != nil && product.CreatedAt.After(time.Now()) {
+			c.warnf(file, path+".createdAt", "createdAt %s is in the future", product.CreatedAt.Format(time.RFC3339))
+		}
+
+		for j, review := range product.Reviews {
+			if review.Rating < 1 || review.Rating > 5 {
+				c.errorf(file, fmt.Sprintf("%s.reviews[%d].rating", path, j), "rating %d should be from 1 to 5", review.Rating)
+			}
+		}
+	}
+}
+
+func (c *checker) checkCategories() {
+	const file = "categories.json"
+
+	var categories []models.Category
+	if !c.load(file, true, &categories) {
+		return
+	}
+
+	for i, category := range categories {
+		path := fmt.Sprintf("[%d]", i)
+
+		if category.ID == "" || category.Name == "" {
+			c.errorf(file, path, "category must have id and name")
+		}
+
+		if _, ok := c.categories[category.ID]; ok && category.ID != "" {
+			c.errorf(file, path+".id", "duplicate category %q", category.ID)
+		}
+
+		c.categories[category.ID] = struct{}{}
+	}
+}
+
+func (c *checker) checkProductCategories() {
+	const file = "product_categories.json"
+
+	var productCategories map[string][]string
+	if !c.load(file, true, &productCategories) {
+		return
+	}
+
+	for _, categoryID := range sortedKeys(productCategories) {
+		if _, ok := c.categories[categoryID]; !ok {
+			c.errorf(file, categoryID, "unknown category %q", categoryID)
+		}
+
+		for i, productID := range productCategories[categoryID] {
+			if _, ok := c.products[productID]; !ok {
+				c.errorf(file, fmt.Sprintf("%s[%d]", categoryID, i), "unknown product %q", productID)
+			}
+		}
+	}
+}
+
+func (c *checker) checkCombos() {
+	const file = "combos.json"
+
+	var combos []models.Combo
+	if !c.load(file, false, &combos) {
+		return
+	}
+
+	for i, combo := range combos {
+		path := fmt.Sprintf("[%d]", i)
+
+		if combo.ID == "" || len(combo.Items) == 0 {
+			c.errorf(file, path, "combo must have id and items")
+		}
+
+		if _, ok := c.combos[combo.ID]; ok && combo.ID != "" {
+			c.errorf(file, path+".id", "duplicate combo %q", combo.ID)
+		}
+
+		c.combos[combo.ID] = struct{}{}
+
+		if combo.Price <= 0 {
+			c.errorf(file, path+".price", "price %s should be positive", combo.Price)
+		}
+
+		for j, item := range combo.Items {
+			itemPath := fmt.Sprintf("%s.items[%d]", path, j)
+
+			if _, ok := c.products[item.ProductID]; !ok {
+				c.errorf(file, itemPath+".id", "unknown product %q", item.ProductID)
+			}
+
+			if item.Quantity <= 0 {
+				c.errorf(file, itemPath+".quantity", "quantity %d should be positive", item.Quantity)
+			}
+		}
+	}
+}
+
+func (c *checker) checkOrderExtras() {
+	const file = "order_extras.json"
+
+	var extras []models.OrderExtra
+	if !c.load(file, false, &extras) {
+		return
+	}
+
+	seen := make(map[string]struct{}, len(extras))
+
+	for i, extra := range extras {
+		path := fmt.Sprintf("[%d]", i)
+
+		if extra.ID == "" || extra.Name == "" {
+			c.errorf(file, path, "order extra must have id and name")
+		}
+
+		if _, ok := seen[extra.ID]; ok && extra.ID != "" {
+			c.errorf(file, path+".id", "duplicate order extra %q", extra.ID)
+		}
+
+		seen[extra.ID] = struct{}{}
+
+		if extra.Surcharge < 0 {
+			c.errorf(file, path+".surcharge", "negative surcharge %s", extra.Surcharge)
+		}
+	}
+}
+
+func (c *checker) checkDeliveryZones() {
+	const file = "delivery_zones.json"
+
+	var zones []*models.DeliveryZone
+	if !c.load(file, false, &zones) {
+		return
+	}
+
+	seen := make(map[string]struct{}, len(zones))
+
+	for i, zone := range zones {
+		path := fmt.Sprintf("[%d]", i)
+
+		if zone == nil {
+			c.errorf(file, path, "zone is null")
+
+			continue
+		}
+
+		if zone.ID == "" || zone.Name == "" {
+			c.errorf(file, path, "delivery zone must have id and name")
+		}
+
+		if _, ok := seen[zone.ID]; ok && zone.ID != "" {
+			c.errorf(file, path+".id", "duplicate delivery zone %q", zone.ID)
+		}
+
+		seen[zone.ID] = struct{}{}
+
+		if len(zone.Polygon) < 3 {
+			c.errorf(file, path+".polygon", "polygon must have at least 3 points, got %d", len(zone.Polygon))
+		}
+
+		for j, point := range zone.Polygon {
+			if len(point) != 2 {
+				c.errorf(file, fmt.Sprintf("%s.polygon[%d]", path, j), "point must be [longitude, latitude]")
+			}
+		}
+
+		// Товары ассортимента зоны, которых нет в каталоге, просто не покажутся
+		for j, productID := range zone.Products {
+			if _, ok := c.products[productID]; !ok {
+				c.warnf(file, fmt.Sprintf("%s.products[%d]", path, j), "unknown product %q", productID)
+			}
+		}
+
+		for j, categoryID := range zone.Categories {
+			if _, ok := c.categories[categoryID]; !ok {
+				c.warnf(file, fmt.Sprintf("%s.categories[%d]", path, j), "unknown category %q", categoryID)
+			}
+		}
+	}
+}
+
+func (c *checker) checkCollections() {
+	const file = "collections.json"
+
+	var collections []*models.Collection
+	if !c.load(file, false, &collections) {
+		return
+	}
+
+	seen := make(map[string]struct{}, len(collections))
+
+	for i, collection := range collections {
+		path := fmt.Sprintf("[%d]", i)
+
+		if collection == nil {
+			c.errorf(file, path, "collection is null")
+
+			continue
+		}
+
+		if collection.ID == "" || collection.Name == "" {
+			c.errorf(file, path, "collection must have id and name")
+		}
+
+		if collection.ID == models.CollectionNew {
+			c.errorf(file, path+".id", "collection %q is built in", collection.ID)
+		}
+
+		if _, ok := seen[collection.ID]; ok && collection.ID != "" {
+			c.errorf(file, path+".id", "duplicate collection %q", collection.ID)
+		}
+
+		seen[collection.ID] = struct{}{}
+
+		if collection.ActiveFrom != nil && collection.ActiveTo != nil && !collection.ActiveFrom.Before(*collection.ActiveTo) {
+			c.errorf(file, path+".activeTo", "activeTo should be after activeFrom")
+		}
+
+		// Товары, которых нет в каталоге, просто не покажутся
+		for j, productID := range collection.ProductIDs {
+			if _, ok := c.products[productID]; !ok {
+				c.warnf(file, fmt.Sprintf("%s.productIds[%d]", path, j), "unknown product %q", productID)
+			}
+		}
+	}
+}
+
+// checkFavourites в избранном и корзине могут остаться снятые с продажи товары, поэтому это предупреждения
+func (c *checker) checkFavourites() {
+	const file = "user_favourites.json"
+
+	var favourites map[string][]string
+	if !c.load(file, false, &favourites) {
+		return
+	}
+
+	for _, userID := range sortedKeys(favourites) {
+		for i, productID := range favourites[userID] {
+			if _, ok := c.products[productID]; !ok {
+				c.warnf(file, fmt.Sprintf("%s[%d]", userID, i), "unknown product %q", productID)
+			}
+		}
+	}
+}
+
+func (c *checker) checkCartItems() {
+	const file = "cart_items.json"
+
+	var carts map[string]map[string]*models.CartItem
+	if !c.load(file, false, &carts) {
+		return
+	}
+
+	for _, userID := range sortedKeys(carts) {
+		cart := carts[userID]
+
+		for _, productID := range sortedKeys(cart) {
+			path := joinPath(userID, productID)
+			item := cart[productID]
+
+			if item == nil {
+				c.errorf(file, path, "cart item is null")
+
+				continue
+			}
+
+			if _, ok := c.products[productID]; !ok {
+				c.warnf(file, path, "unknown product %q", productID)
+			}
+
+			if item.ProductID != productID {
+				c.errorf(file, path+".id", "id %q doesn't match key %q", item.ProductID, productID)
+			}
+
+			if item.Quantity <= 0 {
+				c.errorf(file, path+".quantity", "quantity %d should be positive", item.Quantity)
+			}
+		}
+	}
+}
+
+func (c *checker) checkOrders() {
+	const file = "orders.json"
+
+	var orders map[string][]*models.Order
+	if !c.load(file, false, &orders) {
+		return
+	}
+
+	for _, userID := range sortedKeys(orders) {
+		for i, order := range orders[userID] {
+			path := fmt.Sprintf("%s[%d]", userID, i)
+
+			if order == nil {
+				c.errorf(file, path, "order is null")
+
+				continue
+			}
+
+			if order.ID == "" {
+				c.errorf(file, path+".id", "order must have id")
+			}
+
+			amounts := []struct {
+				name  string
+				value models.Money
+			}{
+				{"orderPrice", order.OrderPrice},
+				{"deliveryPrice", order.DeliveryPrice},
+				{"tip", order.Tip},
+				{"totalPrice", order.TotalPrice},
+			}
+
+			for _, amount := range amounts {
+				if amount.value < 0 {
+					c.errorf(file, joinPath(path, amount.name), "negative amount %s", amount.value)
+				}
+			}
+
+			for j, item := range order.Items {
+				itemPath := fmt.Sprintf("%s.items[%d]", path, j)
+
+				if item.Quantity <= 0 {
+					c.errorf(file, itemPath+".quantity", "quantity %d should be positive", item.Quantity)
+				}
+
+				if item.Price < 0 {
+					c.errorf(file, itemPath+".price", "negative price %s", item.Price)
+				}
+			}
+		}
+	}
+}
+
+func (c *checker) checkWallet() {
+	const file = "wallet_data.json"
+
+	var wallet models.WalletData
+	if !c.load(file, false, &wallet) {
+		return
+	}
+
+	for _, userID := range sortedKeys(wallet.Accounts) {
+		accounts := wallet.Accounts[userID]
+
+		for _, accountID := range sortedKeys(accounts) {
+			path := joinPath("accounts", joinPath(userID, accountID))
+			account := accounts[accountID]
+
+			if account == nil {
+				c.errorf(file, path, "account is null")
+
+				continue
+			}
+
+			if account.ID != accountID {
+				c.errorf(file, path+".id", "id %q doesn't match key %q", account.ID, accountID)
+			}
+
+			if account.Type != models.AccountTypeCard && account.Type != models.AccountTypeSavings {
+				c.errorf(file, path+".type", "unknown account type %q, should be card or savings", account.Type)
+			}
+
+			if account.Balance < 0 {
+				c.errorf(file, path+".balance", "negative balance %s", account.Balance)
+			}
+		}
+	}
+
+	for _, userID := range sortedKeys(wallet.Transactions) {
+		for i, transaction := range wallet.Transactions[userID] {
+			if transaction.Time.IsZero() {
+				c.warnf(file, fmt.Sprintf("transactions.%s[%d].time", userID, i), "transaction has no time")
+			}
+		}
+	}
+
+	for _, userID := range sortedKeys(wallet.DailyTopups) {
+		topups := wallet.DailyTopups[userID]
+
+		for _, date := range sortedKeys(topups) {
+			path := joinPath("daily_topups", joinPath(userID, date))
+
+			if _, err := time.Parse("2006-01-02", date); err != nil {
+				c.errorf(file, path, "malformed date %q, should be 2006-01-02", date)
+			}
+
+			if topups[date] < 0 {
+				c.errorf(file, path, "negative amount %s", topups[date])
+			}
+		}
+	}
+
+	for _, userID := range sortedKeys(wallet.Contacts) {
+		contacts := wallet.Contacts[userID]
+
+		for _, contactID := range sortedKeys(contacts) {
+			path := joinPath("contacts", joinPath(userID, contactID))
+
+			if contactID == userID {
+				c.errorf(file, path, "user can't be their own contact")
+			}
+
+			if strings.TrimSpace(contacts[contactID].Name) == "" {
+				c.errorf(file, path+".name", "contact name is empty")
+			}
+		}
+	}
+}
+
+func (c *checker) checkUserProfiles() {
+	const file = "user_profiles.json"
+
+	var profiles map[string]*models.UserProfile
+	if !c.load(file, false, &profiles) {
+		return
+	}
+
+	for _, userID := range sortedKeys(profiles) {
+		profile := profiles[userID]
+		if profile == nil || profile.Birthday == "" {
+			continue
+		}
+
+		if _, err := time.Parse("02.01.2006", profile.Birthday); err != nil {
+			c.errorf(file, userID+".birthday", "malformed date %q, should be 02.01.2006", profile.Birthday)
+		}
+	}
+}
+
+// validTimeRange проверяет часы продажи в формате "08:00-11:00", как их разбирает каталог
+func validTimeRange(value string) bool {
+	from, to, ok := strings.Cut(strings.TrimSpace(value), "-")
+	if !ok {
+		return false
+	}
+
+	return validClock(from) && validClock(to)
+}
+
+func validClock(value string) bool {
+	value = strings.TrimSpace(value)
+	if value == "24:00" {
+		return true
+	}
+
+	_, err := time.Parse("15:04", value)
+
+	return err == nil
+}
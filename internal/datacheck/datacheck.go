@@ -0,0 +1,255 @@
+// Package datacheck проверяет файлы data/ до запуска сервера: формат JSON, соответствие моделям
+// и связи между файлами, например товары в product_categories.json.
+package datacheck
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+type Severity string
+
+const (
+	// SeverityError данные сломаны: сервер с ними не стартует или работает неправильно.
+	SeverityError Severity = "error"
+	// SeverityWarning данные странные, но сервер с ними справится, например лишнее поле или удаленный товар.
+	SeverityWarning Severity = "warning"
+)
+
+// Issue найденная проблема. Path - путь внутри файла: "[3].price" или "accounts.user-1.card".
+type Issue struct {
+	File     string   `json:"file"`
+	Path     string   `json:"path,omitempty"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+func (i Issue) String() string {
+	location := i.File
+	if i.Path != "" {
+		location += " " + i.Path
+	}
+
+	return fmt.Sprintf("%s: %s: %s", i.Severity, location, i.Message)
+}
+
+// Report результат проверки каталога данных.
+type Report struct {
+	Dir string `json:"dir"`
+	// Проверенные файлы и файлы, которых нет: сервер начнет с пустыми данными.
+	Checked []string `json:"checked"`
+	Missing []string `json:"missing"`
+	Issues  []Issue  `json:"issues"`
+}
+
+// HasErrors есть ли проблемы, с которыми сервер не должен стартовать в строгом режиме.
+func (r Report) HasErrors() bool {
+	for _, issue := range r.Issues {
+		if issue.Severity == SeverityError {
+			return true
+		}
+	}
+
+	return false
+}
+
+// String отчет для человека: по строке на проблему и итог.
+func (r Report) String() string {
+	var builder strings.Builder
+
+	errorsCount := 0
+
+	for _, issue := range r.Issues {
+		builder.WriteString(issue.String())
+		builder.WriteByte('\n')
+
+		if issue.Severity == SeverityError {
+			errorsCount++
+		}
+	}
+
+	fmt.Fprintf(&builder, "%s: checked %d files, %d errors, %d warnings",
+		r.Dir, len(r.Checked), errorsCount, len(r.Issues)-errorsCount)
+
+	if len(r.Missing) > 0 {
+		fmt.Fprintf(&builder, ", missing (will start empty): %s", strings.Join(r.Missing, ", "))
+	}
+
+	return builder.String()
+}
+
+// checker собирает проблемы и то, что нужно для проверки связей между файлами
+type checker struct {
+	dir    string
+	report Report
+
+	products   map[string]struct{}
+	categories map[string]struct{}
+	combos     map[string]struct{}
+}
+
+func (c *checker) errorf(file, path, format string, args ...any) {
+	c.add(file, path, SeverityError, format, args...)
+}
+
+func (c *checker) warnf(file, path, format string, args ...any) {
+	c.add(file, path, SeverityWarning, format, args...)
+}
+
+func (c *checker) add(file, path string, severity Severity, format string, args ...any) {
+	c.report.Issues = append(c.report.Issues, Issue{
+		File:     file,
+		Path:     path,
+		Severity: severity,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// load читает файл в target. false - файла нет или он не разбирается, проблема уже записана.
+// Без обязательного файла сервер стартует с пустым каталогом, поэтому это ошибка.
+func (c *checker) load(file string, required bool, target any) bool {
+	data, err := os.ReadFile(filepath.Join(c.dir, file))
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			c.errorf(file, "", "can't read: %v", err)
+
+			return false
+		}
+
+		c.report.Missing = append(c.report.Missing, file)
+		if required {
+			c.errorf(file, "", "file is missing, server would start without it")
+		}
+
+		return false
+	}
+
+	c.report.Checked = append(c.report.Checked, file)
+
+	if err := json.Unmarshal(data, target); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			line, column := position(data, syntaxErr.Offset)
+			c.errorf(file, fmt.Sprintf("line %d, column %d", line, column), "invalid JSON: %v", err)
+
+			return false
+		}
+
+		path, locatedErr := locate(data, reflect.TypeOf(target).Elem(), "", false)
+		if locatedErr == nil {
+			locatedErr = err
+		}
+
+		c.errorf(file, path, "%v", locatedErr)
+
+		return false
+	}
+
+	// Поле с опечаткой молча пропускается при загрузке, поэтому о нем стоит знать
+	if path, err := locate(data, reflect.TypeOf(target).Elem(), "", true); err != nil {
+		c.warnf(file, path, "%v", err)
+	}
+
+	return true
+}
+
+// locate ищет самый глубокий элемент, который не разбирается в тип t, и возвращает путь к нему.
+// strict дополнительно запрещает поля, которых нет в модели.
+func locate(data []byte, t reflect.Type, path string, strict bool) (string, error) {
+	err := decode(data, reflect.New(t).Interface(), strict)
+	if err == nil {
+		return "", nil
+	}
+
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Slice:
+		var items []json.RawMessage
+		if json.Unmarshal(data, &items) == nil {
+			for i, item := range items {
+				if itemPath, itemErr := locate(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), strict); itemErr != nil {
+					return itemPath, itemErr
+				}
+			}
+		}
+	case reflect.Map:
+		var items map[string]json.RawMessage
+		if json.Unmarshal(data, &items) == nil {
+			for _, key := range sortedKeys(items) {
+				if itemPath, itemErr := locate(items[key], t.Elem(), joinPath(path, key), strict); itemErr != nil {
+					return itemPath, itemErr
+				}
+			}
+		}
+	case reflect.Struct:
+		var fields map[string]json.RawMessage
+		if json.Unmarshal(data, &fields) == nil {
+			for i := range t.NumField() {
+				field := t.Field(i)
+
+				name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+				if name == "" || name == "-" || !field.IsExported() {
+					continue
+				}
+
+				if raw, ok := fields[name]; ok {
+					if fieldPath, fieldErr := locate(raw, field.Type, joinPath(path, name), strict); fieldErr != nil {
+						return fieldPath, fieldErr
+					}
+				}
+			}
+		}
+	}
+
+	return path, err
+}
+
+func decode(data []byte, target any, strict bool) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if strict {
+		decoder.DisallowUnknownFields()
+	}
+
+	return decoder.Decode(target)
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+
+	return path + "." + key
+}
+
+// position переводит смещение в байтах в номер строки и столбца, считая с 1
+func position(data []byte, offset int64) (int, int) {
+	offset = min(offset, int64(len(data)))
+	before := data[:offset]
+
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+
+	return line, column
+}
+
+func sortedKeys[V any](items map[string]V) []string {
+	keys := make([]string, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,141 @@
+package datacheck_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"eats-backend/internal/datacheck"
+)
+
+func writeData(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	catalog := map[string]string{
+		"products.json":           `[{"id": "p1", "name": "Soup", "price": 100}]`,
+		"categories.json":         `[{"id": "c1", "name": "Soups"}]`,
+		"product_categories.json": `{"c1": ["p1"]}`,
+	}
+
+	for name, content := range catalog {
+		if _, ok := files[name]; !ok {
+			files[name] = content
+		}
+	}
+
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600))
+	}
+
+	return dir
+}
+
+func findIssue(report datacheck.Report, file, path string) *datacheck.Issue {
+	for i := range report.Issues {
+		if report.Issues[i].File == file && report.Issues[i].Path == path {
+			return &report.Issues[i]
+		}
+	}
+
+	return nil
+}
+
+func TestValidateCleanData(t *testing.T) {
+	dir := writeData(t, map[string]string{})
+
+	report := datacheck.Validate(dir)
+
+	require.Empty(t, report.Issues)
+	require.False(t, report.HasErrors())
+	require.Contains(t, report.Missing, "wallet_data.json")
+}
+
+func TestValidateMissingCatalog(t *testing.T) {
+	report := datacheck.Validate(t.TempDir())
+
+	require.True(t, report.HasErrors())
+	require.NotNil(t, findIssue(report, "products.json", ""))
+}
+
+func TestValidateUnknownProductInCategory(t *testing.T) {
+	dir := writeData(t, map[string]string{
+		"product_categories.json": `{"c1": ["p1", "p404"], "c404": []}`,
+	})
+
+	report := datacheck.Validate(dir)
+
+	issue := findIssue(report, "product_categories.json", "c1[1]")
+	require.NotNil(t, issue)
+	require.Equal(t, datacheck.SeverityError, issue.Severity)
+	require.Contains(t, issue.Message, "p404")
+	require.NotNil(t, findIssue(report, "product_categories.json", "c404"))
+}
+
+func TestValidateWallet(t *testing.T) {
+	dir := writeData(t, map[string]string{
+		"wallet_data.json": `{
+			"accounts": {"user": {"card": {"id": "card", "type": "card", "balance": -10}}},
+			"daily_topups": {"user": {"17.10.2026": 100}},
+			"contacts": {"user": {"user": {"name": "Я"}, "friend": {"name": " "}}}
+		}`,
+	})
+
+	report := datacheck.Validate(dir)
+
+	require.True(t, report.HasErrors())
+	require.NotNil(t, findIssue(report, "wallet_data.json", "accounts.user.card.balance"))
+	require.NotNil(t, findIssue(report, "wallet_data.json", "daily_topups.user.17.10.2026"))
+	require.NotNil(t, findIssue(report, "wallet_data.json", "contacts.user.user"))
+	require.NotNil(t, findIssue(report, "wallet_data.json", "contacts.user.friend.name"))
+}
+
+func TestValidateLocatesDecodeErrors(t *testing.T) {
+	dir := writeData(t, map[string]string{
+		"products.json": `[{"id": "p1", "name": "Soup"}, {"id": "p2", "name": "Tea", "weight": "heavy"}]`,
+		"orders.json":   "{\n  \"user\": [\n    {\"id\": \"o1\",}\n  ]\n}",
+	})
+
+	report := datacheck.Validate(dir)
+
+	require.NotNil(t, findIssue(report, "products.json", "[1].weight"))
+	require.NotNil(t, findIssue(report, "orders.json", "line 3, column 18"))
+}
+
+func TestValidateUnknownFieldIsWarning(t *testing.T) {
+	dir := writeData(t, map[string]string{
+		"products.json":      `[{"id": "p1", "name": "Soup", "prise": 100}]`,
+		"user_profiles.json": `{"user": {"name": "Ann", "birthday": "2000-01-01"}}`,
+	})
+
+	report := datacheck.Validate(dir)
+
+	issue := findIssue(report, "products.json", "[0]")
+	require.NotNil(t, issue)
+	require.Equal(t, datacheck.SeverityWarning, issue.Severity)
+	require.Contains(t, issue.Message, "prise")
+
+	require.NotNil(t, findIssue(report, "user_profiles.json", "user.birthday"))
+}
+
+func TestValidateCollections(t *testing.T) {
+	dir := writeData(t, map[string]string{
+		"collections.json": `[
+			{"id": "summer", "name": "Summer", "productIds": ["p1", "p404"]},
+			{"id": "new", "name": "New", "productIds": []},
+			{"id": "winter", "name": "Winter", "activeFrom": "2026-03-01T00:00:00Z", "activeTo": "2025-12-01T00:00:00Z"}
+		]`,
+	})
+
+	report := datacheck.Validate(dir)
+
+	issue := findIssue(report, "collections.json", "[0].productIds[1]")
+	require.NotNil(t, issue)
+	require.Equal(t, datacheck.SeverityWarning, issue.Severity)
+	require.NotNil(t, findIssue(report, "collections.json", "[1].id"))
+	require.NotNil(t, findIssue(report, "collections.json", "[2].activeTo"))
+	require.True(t, report.HasErrors())
+}
@@ -0,0 +1,67 @@
+package events
+
+import (
+	"context"
+	"reflect"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+type handler struct {
+	subscriber string
+	handle     func(ctx context.Context, event Event)
+}
+
+// Bus синхронная шина событий внутри процесса. Обработчики вызываются по порядку подписки в горутине
+// того, кто опубликовал событие, поэтому долгую работу (письма, запросы наружу) они уносят в фон сами.
+type Bus struct {
+	logger   *zap.SugaredLogger
+	handlers map[reflect.Type][]handler
+
+	mu sync.RWMutex
+}
+
+func NewBus(logger *zap.SugaredLogger) *Bus {
+	return &Bus{
+		logger:   logger,
+		handlers: make(map[reflect.Type][]handler),
+	}
+}
+
+// Subscribe подписывает обработчик на события типа E. subscriber - имя подписчика для логов.
+func Subscribe[E Event](bus *Bus, subscriber string, handle func(ctx context.Context, event E)) {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+
+	eventType := reflect.TypeFor[E]()
+	bus.handlers[eventType] = append(bus.handlers[eventType], handler{
+		subscriber: subscriber,
+		handle: func(ctx context.Context, event Event) {
+			handle(ctx, event.(E))
+		},
+	})
+}
+
+// Publish передает событие всем подписчикам. Паника подписчика пишется в лог и не мешает остальным
+// подписчикам и тому, кто опубликовал событие.
+func (b *Bus) Publish(ctx context.Context, event Event) {
+	b.mu.RLock()
+	handlers := b.handlers[reflect.TypeOf(event)]
+	b.mu.RUnlock()
+
+	for _, h := range handlers {
+		b.deliver(ctx, h, event)
+	}
+}
+
+func (b *Bus) deliver(ctx context.Context, h handler, event Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			b.logger.Errorw("Event handler panicked",
+				"event", event.EventName(), "subscriber", h.subscriber, "panic", r)
+		}
+	}()
+
+	h.handle(ctx, event)
+}
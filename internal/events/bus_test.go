@@ -0,0 +1,38 @@
+package events_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"eats-backend/internal/events"
+	"eats-backend/internal/models"
+)
+
+func TestBus_Publish(t *testing.T) {
+	bus := events.NewBus(zap.NewNop().Sugar())
+
+	var received []string
+
+	events.Subscribe(bus, "broken", func(context.Context, events.OrderCreated) {
+		panic("boom")
+	})
+	events.Subscribe(bus, "first", func(_ context.Context, event events.OrderCreated) {
+		received = append(received, "first:"+event.Order.ID)
+	})
+	events.Subscribe(bus, "second", func(_ context.Context, event events.OrderCreated) {
+		received = append(received, "second:"+event.Order.ID)
+	})
+	events.Subscribe(bus, "transfers", func(context.Context, events.TransferCompleted) {
+		received = append(received, "transfer")
+	})
+
+	// Паника одного подписчика не мешает остальным, события других типов им не приходят
+	bus.Publish(t.Context(), events.OrderCreated{UserID: "user-1", Order: models.Order{ID: "order-1"}})
+	require.Equal(t, []string{"first:order-1", "second:order-1"}, received)
+
+	bus.Publish(t.Context(), events.ProductUpdated{})
+	require.Len(t, received, 2)
+}
@@ -0,0 +1,63 @@
+// Package events доменные события и шина, через которую сервисы сообщают о них друг другу.
+package events
+
+import "eats-backend/internal/models"
+
+// Event доменное событие. Имя используется в логах и как тип события в вебхуках, поля событий
+// уходят интеграторам как есть.
+type Event interface {
+	EventName() string
+}
+
+// OrderCreated пользователь оформил заказ.
+type OrderCreated struct {
+	UserID string       `json:"userId"`
+	Order  models.Order `json:"order"`
+}
+
+func (OrderCreated) EventName() string { return "order.created" }
+
+// OrderStatusChanged заказ сменил статус: завершен по времени или курьером.
+type OrderStatusChanged struct {
+	UserID string       `json:"userId"`
+	Order  models.Order `json:"order"`
+}
+
+func (OrderStatusChanged) EventName() string { return "order.status_changed" }
+
+// OrderDelayed доставка заказа задерживается, у заказа новое ожидаемое время.
+type OrderDelayed struct {
+	UserID string            `json:"userId"`
+	Order  models.Order      `json:"order"`
+	Delay  models.OrderDelay `json:"delay"`
+}
+
+func (OrderDelayed) EventName() string { return "order.delayed" }
+
+// TransferCompleted выполнен перевод между пользователями.
+type TransferCompleted struct {
+	FromUserID string       `json:"fromUserId"`
+	ToUserID   string       `json:"toUserId"`
+	FromPhone  string       `json:"fromPhone"`
+	ToPhone    string       `json:"toPhone"`
+	Amount     models.Money `json:"amount"`
+	// Валюта счета отправителя, в ней указаны Amount и SenderBalance.
+	Currency models.Currency `json:"currency"`
+	// Баланс отправителя после перевода.
+	SenderBalance models.Money `json:"senderBalance"`
+	// Сколько зачислено получателю в валюте его счета.
+	CreditedAmount   models.Money    `json:"creditedAmount"`
+	CreditedCurrency models.Currency `json:"creditedCurrency"`
+}
+
+func (TransferCompleted) EventName() string { return "wallet.transfer_completed" }
+
+// ProductUpdated товар в каталоге изменился: наличие или отзывы.
+type ProductUpdated struct {
+	Product models.Product       `json:"product"`
+	Change  models.CatalogChange `json:"change"`
+	// Был ли товар в наличии до изменения.
+	WasAvailable bool `json:"wasAvailable"`
+}
+
+func (ProductUpdated) EventName() string { return "product.updated" }
@@ -0,0 +1,199 @@
+// Package logging создает логгеры, уровень которых можно менять во время работы сервера:
+// общий уровень и переопределения для отдельных модулей.
+package logging
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"eats-backend/internal/models"
+)
+
+// Модули, для которых можно задать свой уровень.
+const (
+	ModuleAPI     = "api"
+	ModuleWallet  = "wallet"
+	ModuleStorage = "storage"
+)
+
+var modules = []string{ModuleAPI, ModuleWallet, ModuleStorage}
+
+// Levels хранит уровни логирования и выдает логгеры модулей.
+type Levels struct {
+	base   *zap.Logger
+	global zap.AtomicLevel
+	// Уровень каждого модуля: совпадает с общим, пока для модуля нет переопределения.
+	modules   map[string]zap.AtomicLevel
+	overrides map[string]zapcore.Level
+
+	mux sync.RWMutex
+}
+
+// New создает production-логгер с уровнем level. Сам логгер пишет все записи,
+// фильтрация по уровню происходит в обертках общего логгера и логгеров модулей.
+func New(level string) (*Levels, error) {
+	parsed, err := zapcore.ParseLevel(level)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log level %q: %w", level, err)
+	}
+
+	cfg := zap.NewProductionConfig()
+	cfg.Level = zap.NewAtomicLevelAt(zapcore.DebugLevel)
+
+	base, err := cfg.Build()
+	if err != nil {
+		return nil, fmt.Errorf("can't build logger: %w", err)
+	}
+
+	l := &Levels{
+		base:      base,
+		global:    zap.NewAtomicLevelAt(parsed),
+		modules:   make(map[string]zap.AtomicLevel, len(modules)),
+		overrides: make(map[string]zapcore.Level),
+	}
+
+	for _, module := range modules {
+		l.modules[module] = zap.NewAtomicLevelAt(parsed)
+	}
+
+	return l, nil
+}
+
+// Logger общий логгер сервера.
+func (l *Levels) Logger() *zap.SugaredLogger {
+	return withLevel(l.base, l.global).Sugar()
+}
+
+// Module логгер модуля, записи помечаются полем module.
+func (l *Levels) Module(module string) *zap.SugaredLogger {
+	level, ok := l.modules[module]
+	if !ok {
+		level = l.global
+	}
+
+	return withLevel(l.base, level).Sugar().With("module", module)
+}
+
+func (l *Levels) GetLevels() models.LogLevels {
+	l.mux.RLock()
+	defer l.mux.RUnlock()
+
+	result := models.LogLevels{
+		Level:     l.global.Level().String(),
+		Modules:   make(map[string]string, len(l.modules)),
+		Overrides: make(map[string]string, len(l.overrides)),
+	}
+
+	for module, level := range l.modules {
+		result.Modules[module] = level.Level().String()
+	}
+
+	for module, level := range l.overrides {
+		result.Overrides[module] = level.String()
+	}
+
+	return result
+}
+
+// SetLevels меняет уровни. Запрос проверяется целиком до применения, чтобы ошибка в одном модуле
+// не оставила уровни измененными наполовину.
+func (l *Levels) SetLevels(req models.LogLevelRequest) (models.LogLevels, error) {
+	var global *zapcore.Level
+
+	if req.Level != "" {
+		parsed, err := zapcore.ParseLevel(req.Level)
+		if err != nil {
+			return models.LogLevels{}, fmt.Errorf("%w: invalid level %q", models.ErrBadRequest, req.Level)
+		}
+
+		global = &parsed
+	}
+
+	overrides := make(map[string]*zapcore.Level, len(req.Modules))
+
+	for module, level := range req.Modules {
+		if !slices.Contains(modules, module) {
+			return models.LogLevels{}, fmt.Errorf(
+				"%w: unknown module %q, should be one of %v", models.ErrBadRequest, module, modules,
+			)
+		}
+
+		if level == "" {
+			overrides[module] = nil
+
+			continue
+		}
+
+		parsed, err := zapcore.ParseLevel(level)
+		if err != nil {
+			return models.LogLevels{}, fmt.Errorf("%w: invalid level %q for module %s", models.ErrBadRequest, level, module)
+		}
+
+		overrides[module] = &parsed
+	}
+
+	l.mux.Lock()
+
+	if global != nil {
+		l.global.SetLevel(*global)
+	}
+
+	for module, level := range overrides {
+		if level == nil {
+			delete(l.overrides, module)
+		} else {
+			l.overrides[module] = *level
+		}
+	}
+
+	for module, moduleLevel := range l.modules {
+		level, ok := l.overrides[module]
+		if !ok {
+			level = l.global.Level()
+		}
+
+		moduleLevel.SetLevel(level)
+	}
+
+	l.mux.Unlock()
+
+	return l.GetLevels(), nil
+}
+
+// Sync сбрасывает буферы логгера.
+func (l *Levels) Sync() error {
+	return l.base.Sync()
+}
+
+func withLevel(logger *zap.Logger, level zapcore.LevelEnabler) *zap.Logger {
+	return logger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &levelCore{Core: core, level: level}
+	}))
+}
+
+// levelCore отбрасывает записи ниже level. В отличие от zapcore.NewIncreaseLevelCore позволяет
+// опустить уровень ниже, чем у исходного core.
+type levelCore struct {
+	zapcore.Core
+	level zapcore.LevelEnabler
+}
+
+func (c *levelCore) Enabled(level zapcore.Level) bool {
+	return c.level.Enabled(level)
+}
+
+func (c *levelCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.level.Enabled(entry.Level) {
+		return checked
+	}
+
+	return c.Core.Check(entry, checked)
+}
+
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{Core: c.Core.With(fields), level: c.level}
+}
@@ -0,0 +1,28 @@
+package mailer
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// LogMailer не отправляет письма, а пишет их в лог. Используется для локальной разработки.
+type LogMailer struct {
+	logger *zap.SugaredLogger
+}
+
+func NewLogMailer(logger *zap.SugaredLogger) *LogMailer {
+	return &LogMailer{
+		logger: logger,
+	}
+}
+
+func (m *LogMailer) Send(_ context.Context, to, subject, body string) error {
+	m.logger.With(
+		"module", "mailer",
+		"to", to,
+		"subject", subject,
+	).Infof("Email sent: %s", body)
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"strings"
+)
+
+//go:embed templates/*.html
+var templatesFS embed.FS
+
+const layoutTemplate = "templates/layout.html"
+
+// Renderer собирает письма из шаблонов. Каждый шаблон определяет блоки "subject" и "content",
+// которые подставляются в общий layout.
+type Renderer struct {
+	templates map[string]*template.Template
+}
+
+func NewRenderer() (*Renderer, error) {
+	files, err := templatesFS.ReadDir("templates")
+	if err != nil {
+		return nil, fmt.Errorf("can't read templates dir: %w", err)
+	}
+
+	renderer := &Renderer{templates: make(map[string]*template.Template)}
+
+	for _, file := range files {
+		path := "templates/" + file.Name()
+		if path == layoutTemplate {
+			continue
+		}
+
+		tmpl, err := template.ParseFS(templatesFS, layoutTemplate, path)
+		if err != nil {
+			return nil, fmt.Errorf("can't parse template %s: %w", file.Name(), err)
+		}
+
+		renderer.templates[strings.TrimSuffix(file.Name(), ".html")] = tmpl
+	}
+
+	return renderer, nil
+}
+
+// Render возвращает тему и html-тело письма.
+func (r *Renderer) Render(name string, data any) (string, string, error) {
+	tmpl, ok := r.templates[name]
+	if !ok {
+		return "", "", fmt.Errorf("template %s not found", name)
+	}
+
+	var subject bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return "", "", fmt.Errorf("can't render subject of %s: %w", name, err)
+	}
+
+	var body bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&body, "layout", data); err != nil {
+		return "", "", fmt.Errorf("can't render body of %s: %w", name, err)
+	}
+
+	return strings.TrimSpace(subject.String()), body.String(), nil
+}
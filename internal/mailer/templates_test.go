@@ -0,0 +1,30 @@
+package mailer_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"eats-backend/internal/mailer"
+	"eats-backend/internal/models"
+)
+
+func TestRenderer_Render(t *testing.T) {
+	renderer, err := mailer.NewRenderer()
+	require.NoError(t, err)
+
+	subject, body, err := renderer.Render("order_created", map[string]any{
+		"Order": models.Order{
+			Address:    models.Address{AddressLine: "ул. Пушкина, 1"},
+			TotalPrice: models.Rubles(300),
+			Items:      []models.OrderItem{{Name: "Хлеб <свежий>", Quantity: 2, Price: models.Rubles(65)}},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "Заказ принят", subject)
+	require.Contains(t, body, "ул. Пушкина, 1")
+	require.Contains(t, body, "Хлеб &lt;свежий&gt;")
+
+	_, _, err = renderer.Render("unknown", nil)
+	require.Error(t, err)
+}
@@ -0,0 +1,41 @@
+package metrics
+
+// Бизнес-метрики для панелей преподавателя. Значения меток - короткие фиксированные строки,
+// а не id пользователей и заказов, чтобы число рядов не росло с числом студентов.
+var (
+	OrdersCreated = Default.NewCounter("eats_orders_created_total",
+		"Orders placed, by payment method and source (cart, subscription).",
+		"payment_method", "source")
+	OrdersCancelled = Default.NewCounter("eats_orders_cancelled_total",
+		"Orders fully refunded after placement.")
+	PaymentFailures = Default.NewCounter("eats_payment_failures_total",
+		"Failed payments, by source (order, subscription, external_topup) and reason.",
+		"source", "reason")
+	TopupRejections = Default.NewCounter("eats_topup_rejections_total",
+		"Rejected wallet topups, by reason (daily_limit, max_amount, fraud).",
+		"reason")
+	UploadRejections = Default.NewCounter("eats_upload_rejections_total",
+		"Rejected file uploads, by reason.",
+		"reason")
+	RequestsShed = Default.NewCounter("eats_requests_shed_total",
+		"Requests rejected with 503 because too many requests of the route class were in flight.",
+		"class")
+
+	Backups = Default.NewCounter("eats_backups_total",
+		"Backups performed, by result (success, failure).",
+		"result")
+	BackupDuration = Default.NewGauge("eats_backup_duration_seconds",
+		"Duration of the last backup.")
+	BackupSize = Default.NewGauge("eats_backup_size_bytes",
+		"Total size of the last backup.")
+	BackupLastSuccess = Default.NewGauge("eats_backup_last_success_timestamp_seconds",
+		"Unix time of the last successful backup.")
+)
+
+// Значения метки reason у PaymentFailures
+const (
+	PaymentReasonInsufficientFunds = "insufficient_funds"
+	PaymentReasonPIN               = "pin"
+	PaymentReasonDeclined          = "declined"
+	PaymentReasonError             = "error"
+)
@@ -0,0 +1,230 @@
+// Package metrics счетчики и показатели бизнес-событий в текстовом формате Prometheus. Метрики
+// объявляются переменными пакета на реестре Default, как в клиенте Prometheus, и отдаются
+// преподавателю через GET /admin/metrics.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Default реестр, на котором объявлены все метрики сервера.
+var Default = NewRegistry()
+
+type metricType string
+
+const (
+	typeCounter metricType = "counter"
+	typeGauge   metricType = "gauge"
+)
+
+// Registry набор метрик, которые выводятся вместе.
+type Registry struct {
+	mux     sync.RWMutex
+	metrics []*vec
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// vec метрика с набором меток: значение на каждое сочетание значений меток.
+type vec struct {
+	name   string
+	help   string
+	kind   metricType
+	labels []string
+
+	mux    sync.Mutex
+	values map[string]float64 // значения меток через \xff -> значение
+}
+
+func (r *Registry) register(name, help string, kind metricType, labels []string) *vec {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	for _, metric := range r.metrics {
+		if metric.name == name {
+			panic(fmt.Sprintf("metric %s is already registered", name))
+		}
+	}
+
+	metric := &vec{name: name, help: help, kind: kind, labels: labels, values: make(map[string]float64)}
+	r.metrics = append(r.metrics, metric)
+
+	return metric
+}
+
+func (v *vec) add(delta float64, labelValues []string) {
+	key := v.key(labelValues)
+
+	v.mux.Lock()
+	v.values[key] += delta
+	v.mux.Unlock()
+}
+
+func (v *vec) set(value float64, labelValues []string) {
+	key := v.key(labelValues)
+
+	v.mux.Lock()
+	v.values[key] = value
+	v.mux.Unlock()
+}
+
+func (v *vec) get(labelValues []string) float64 {
+	key := v.key(labelValues)
+
+	v.mux.Lock()
+	defer v.mux.Unlock()
+
+	return v.values[key]
+}
+
+func (v *vec) key(labelValues []string) string {
+	// Неверное число меток - ошибка в коде, а не в данных, поэтому паника, как в клиенте Prometheus
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metric %s: expected %d label values, got %d", v.name, len(v.labels), len(labelValues)))
+	}
+
+	return strings.Join(labelValues, "\xff")
+}
+
+// Counter только растущий счетчик событий.
+type Counter struct{ vec *vec }
+
+// NewCounter объявляет счетчик. Имя по соглашению Prometheus заканчивается на _total.
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{vec: r.register(name, help, typeCounter, labels)}
+}
+
+// Inc увеличивает счетчик на 1 для значений меток в порядке объявления.
+func (c *Counter) Inc(labelValues ...string) {
+	c.vec.add(1, labelValues)
+}
+
+// Add увеличивает счетчик на delta, отрицательные значения игнорируются.
+func (c *Counter) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		return
+	}
+
+	c.vec.add(delta, labelValues)
+}
+
+// Value текущее значение, для тестов.
+func (c *Counter) Value(labelValues ...string) float64 {
+	return c.vec.get(labelValues)
+}
+
+// Gauge значение, которое может как расти, так и уменьшаться: длительность последнего бэкапа, размер.
+type Gauge struct{ vec *vec }
+
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{vec: r.register(name, help, typeGauge, labels)}
+}
+
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	g.vec.set(value, labelValues)
+}
+
+// Value текущее значение, для тестов.
+func (g *Gauge) Value(labelValues ...string) float64 {
+	return g.vec.get(labelValues)
+}
+
+// WriteText выводит метрики в текстовом формате Prometheus 0.0.4. Метрики идут по имени, значения
+// внутри метрики - по меткам, чтобы вывод не зависел от порядка map.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mux.RLock()
+	metrics := slices.Clone(r.metrics)
+	r.mux.RUnlock()
+
+	slices.SortFunc(metrics, func(a, b *vec) int { return strings.Compare(a.name, b.name) })
+
+	var builder strings.Builder
+
+	for _, metric := range metrics {
+		metric.writeText(&builder)
+	}
+
+	_, err := io.WriteString(w, builder.String())
+
+	return err
+}
+
+func (v *vec) writeText(builder *strings.Builder) {
+	fmt.Fprintf(builder, "# HELP %s %s\n", v.name, escapeHelp(v.help))
+	fmt.Fprintf(builder, "# TYPE %s %s\n", v.name, v.kind)
+
+	v.mux.Lock()
+	keys := make([]string, 0, len(v.values))
+	for key := range v.values {
+		keys = append(keys, key)
+	}
+
+	slices.Sort(keys)
+
+	values := make([]float64, len(keys))
+	for i, key := range keys {
+		values[i] = v.values[key]
+	}
+	v.mux.Unlock()
+
+	// Метрика без меток видна сразу с нулем, а не только после первого события
+	if len(v.labels) == 0 && len(keys) == 0 {
+		keys, values = []string{""}, []float64{0}
+	}
+
+	for i, key := range keys {
+		builder.WriteString(v.name)
+
+		if len(v.labels) > 0 {
+			builder.WriteByte('{')
+
+			for j, value := range strings.Split(key, "\xff") {
+				if j > 0 {
+					builder.WriteByte(',')
+				}
+
+				fmt.Fprintf(builder, "%s=\"%s\"", v.labels[j], escapeLabel(value))
+			}
+
+			builder.WriteByte('}')
+		}
+
+		builder.WriteByte(' ')
+		builder.WriteString(formatValue(values[i]))
+		builder.WriteByte('\n')
+	}
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
@@ -0,0 +1,50 @@
+package metrics_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"eats-backend/internal/metrics"
+)
+
+func TestRegistry_WriteText(t *testing.T) {
+	registry := metrics.NewRegistry()
+
+	uploads := registry.NewCounter("uploads_rejected_total", "Rejected uploads.", "reason")
+	duration := registry.NewGauge("backup_duration_seconds", "Duration of the last backup.")
+	orders := registry.NewCounter("orders_total", "Orders\nplaced.", "method", "source")
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Go(func() { uploads.Inc("too_large") })
+	}
+	wg.Wait()
+
+	uploads.Inc(`bad"quote`)
+	uploads.Add(-5, "too_large")
+	duration.Set(1.5)
+	orders.Add(2, "card", "cart")
+
+	require.Equal(t, float64(10), uploads.Value("too_large"))
+	require.Panics(t, func() { uploads.Inc() })
+	require.Panics(t, func() { registry.NewGauge("orders_total", "") })
+
+	var out strings.Builder
+	require.NoError(t, registry.WriteText(&out))
+
+	// Метрики идут по имени, значения - по меткам, кавычки и переводы строк экранируются
+	require.Equal(t, `# HELP backup_duration_seconds Duration of the last backup.
+# TYPE backup_duration_seconds gauge
+backup_duration_seconds 1.5
+# HELP orders_total Orders\nplaced.
+# TYPE orders_total counter
+orders_total{method="card",source="cart"} 2
+# HELP uploads_rejected_total Rejected uploads.
+# TYPE uploads_rejected_total counter
+uploads_rejected_total{reason="bad\"quote"} 1
+uploads_rejected_total{reason="too_large"} 10
+`, out.String())
+}
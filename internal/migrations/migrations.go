@@ -0,0 +1,245 @@
+// Package migrations обновляет файлы данных (data/ и бэкапы) между версиями формата.
+//
+// Версия формата хранится в файле data_version.json рядом с данными. Каталог без этого файла
+// считается версией 0 - так выглядят данные, созданные до появления версий.
+package migrations
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"go.uber.org/zap"
+)
+
+const VersionFileName = "data_version.json"
+
+var ErrIncompatibleVersion = errors.New("incompatible data version")
+
+// Migration шаг обновления одного файла данных до версии Version.
+type Migration struct {
+	Version     int
+	Description string
+	// File имя файла данных без расширения, например wallet_data. Мигрируются и бэкапы этого файла.
+	File string
+	// Apply получает содержимое файла, разобранное в any, и возвращает обновленное.
+	// Шаги работают с JSON напрямую, а не с моделями, чтобы не зависеть от их будущих изменений.
+	Apply func(data any) (any, error)
+}
+
+type versionFile struct {
+	Version int `json:"version"`
+}
+
+// CurrentVersion версия формата, с которой работает сервер.
+func CurrentVersion() int {
+	version := 0
+	for _, migration := range steps {
+		version = max(version, migration.Version)
+	}
+
+	return version
+}
+
+// ReadVersion возвращает версию данных в каталоге.
+func ReadVersion(dir string) (int, error) {
+	raw, err := os.ReadFile(filepath.Join(dir, VersionFileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("can't read data version: %w", err)
+	}
+
+	var version versionFile
+	if err := json.Unmarshal(raw, &version); err != nil {
+		return 0, fmt.Errorf("can't parse data version: %w", err)
+	}
+
+	return version.Version, nil
+}
+
+// WriteVersion записывает версию данных в каталог.
+func WriteVersion(dir string, version int) error {
+	raw, err := json.Marshal(versionFile{Version: version})
+	if err != nil {
+		return fmt.Errorf("can't marshal data version: %w", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, VersionFileName), append(raw, '\n'), 0644); err != nil {
+		return fmt.Errorf("can't write data version: %w", err)
+	}
+
+	return nil
+}
+
+// Check проверяет, что сервер может работать с данными каталога без миграций.
+func Check(dir string) error {
+	version, err := ReadVersion(dir)
+	if err != nil {
+		return err
+	}
+
+	current := CurrentVersion()
+
+	switch {
+	case version > current:
+		return fmt.Errorf(
+			"%w: data in %s has version %d, server supports up to %d",
+			ErrIncompatibleVersion, dir, version, current,
+		)
+	case version < current:
+		return fmt.Errorf(
+			"%w: data in %s has version %d and should be migrated to %d",
+			ErrIncompatibleVersion, dir, version, current,
+		)
+	}
+
+	return nil
+}
+
+// Migrate применяет к каталогу недостающие шаги по порядку версий и после каждого обновляет версию,
+// чтобы прерванную миграцию можно было продолжить. Данные из более новой версии не трогает.
+func Migrate(dir string, logger *zap.SugaredLogger) error {
+	version, err := ReadVersion(dir)
+	if err != nil {
+		return err
+	}
+
+	current := CurrentVersion()
+	if version > current {
+		return Check(dir)
+	}
+
+	if version == current {
+		return nil
+	}
+
+	if err := preserveOriginals(dir, version); err != nil {
+		return err
+	}
+
+	pending := slices.Clone(steps)
+	slices.SortStableFunc(pending, func(a, b Migration) int { return a.Version - b.Version })
+
+	for _, migration := range pending {
+		if migration.Version <= version {
+			continue
+		}
+
+		files, err := migrationFiles(dir, migration.File)
+		if err != nil {
+			return err
+		}
+
+		for _, file := range files {
+			if err := applyToFile(file, migration); err != nil {
+				return fmt.Errorf("migration %d (%s) failed on %s: %w", migration.Version, migration.Description, file, err)
+			}
+		}
+
+		logger.Infof("Data in %s migrated to version %d: %s", dir, migration.Version, migration.Description)
+
+		if err := WriteVersion(dir, migration.Version); err != nil {
+			return err
+		}
+	}
+
+	return WriteVersion(dir, current)
+}
+
+// preserveOriginals копирует файлы данных до миграции в подкаталог pre_migration_v<версия>,
+// чтобы к ним можно было вернуться, если миграция испортила данные.
+func preserveOriginals(dir string, version int) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return fmt.Errorf("can't list data files: %w", err)
+	}
+
+	target := filepath.Join(dir, fmt.Sprintf("pre_migration_v%d", version))
+	if err := os.MkdirAll(target, 0755); err != nil {
+		return fmt.Errorf("can't create %s: %w", target, err)
+	}
+
+	for _, file := range files {
+		if filepath.Base(file) == VersionFileName {
+			continue
+		}
+
+		raw, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("can't read %s: %w", file, err)
+		}
+
+		if err := os.WriteFile(filepath.Join(target, filepath.Base(file)), raw, 0644); err != nil {
+			return fmt.Errorf("can't copy %s: %w", file, err)
+		}
+	}
+
+	return nil
+}
+
+// migrationFiles возвращает сам файл данных и его бэкапы в каталоге.
+func migrationFiles(dir, name string) ([]string, error) {
+	backups, err := filepath.Glob(filepath.Join(dir, name+"_backup_*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("can't list backups of %s: %w", name, err)
+	}
+
+	files := make([]string, 0, len(backups)+1)
+
+	dataFile := filepath.Join(dir, name+".json")
+	if _, err := os.Stat(dataFile); err == nil {
+		files = append(files, dataFile)
+	}
+
+	return append(files, backups...), nil
+}
+
+func applyToFile(file string, migration Migration) error {
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("can't read: %w", err)
+	}
+
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return nil
+	}
+
+	// UseNumber сохраняет числа как есть, без перевода в float64
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+
+	var data any
+	if err := decoder.Decode(&data); err != nil {
+		return fmt.Errorf("can't parse: %w", err)
+	}
+
+	data, err = migration.Apply(data)
+	if err != nil {
+		return err
+	}
+
+	migrated, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("can't marshal: %w", err)
+	}
+
+	// Пишем во временный файл и переименовываем, чтобы не оставить файл наполовину записанным
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, append(migrated, '\n'), 0644); err != nil {
+		return fmt.Errorf("can't write: %w", err)
+	}
+
+	if err := os.Rename(tmp, file); err != nil {
+		return fmt.Errorf("can't replace: %w", err)
+	}
+
+	return nil
+}
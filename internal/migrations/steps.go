@@ -0,0 +1,95 @@
+package migrations
+
+import "strings"
+
+// steps все шаги миграции. Новый шаг добавляется в конец со следующим номером версии,
+// уже выпущенные шаги не меняются.
+var steps = []Migration{
+	{
+		Version:     1,
+		Description: "add category to wallet transactions",
+		File:        "wallet_data",
+		Apply:       addTransactionCategories,
+	},
+	{
+		Version:     2,
+		Description: "mark existing products available",
+		File:        "products",
+		Apply:       markProductsAvailable,
+	},
+}
+
+// addTransactionCategories проставляет категории транзакциям, созданным до их появления.
+// Правила зафиксированы на момент миграции и не должны меняться вместе с сервисом кошелька.
+func addTransactionCategories(data any) (any, error) {
+	wallet, ok := data.(map[string]any)
+	if !ok {
+		return data, nil
+	}
+
+	perUser, ok := wallet["transactions"].(map[string]any)
+	if !ok {
+		return data, nil
+	}
+
+	for _, transactions := range perUser {
+		list, ok := transactions.([]any)
+		if !ok {
+			continue
+		}
+
+		for _, item := range list {
+			transaction, ok := item.(map[string]any)
+			if !ok {
+				continue
+			}
+
+			if category, _ := transaction["category"].(string); category != "" {
+				continue
+			}
+
+			title, _ := transaction["title"].(string)
+			transaction["category"] = categoryByTitle(strings.ToLower(title))
+		}
+	}
+
+	return wallet, nil
+}
+
+func categoryByTitle(title string) string {
+	switch {
+	case strings.HasPrefix(title, "пополнение"):
+		return "topup"
+	case strings.HasPrefix(title, "перевод"):
+		return "transfer"
+	case strings.Contains(title, "заказ"),
+		strings.Contains(title, "еды"),
+		strings.Contains(title, "супермаркет"),
+		strings.Contains(title, "кафе"):
+		return "food"
+	default:
+		return "other"
+	}
+}
+
+// markProductsAvailable проставляет наличие товарам из файлов, где поле available еще не сохранялось:
+// раньше оно не читалось из данных, и все товары считались отсутствующими.
+func markProductsAvailable(data any) (any, error) {
+	products, ok := data.([]any)
+	if !ok {
+		return data, nil
+	}
+
+	for _, item := range products {
+		product, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		if _, ok := product["available"]; !ok {
+			product["available"] = true
+		}
+	}
+
+	return products, nil
+}
@@ -1,6 +1,12 @@
 package models
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
 
 var (
 	ErrBadRequest     = errors.New("bad request")
@@ -8,4 +14,304 @@ var (
 	ErrNotFound       = errors.New("not found")
 	ErrUnauthorized   = errors.New("unauthorized")
 	ErrForbidden      = errors.New("forbidden")
+	// ErrPriceChanged цены товаров изменились после предварительного расчета заказа.
+	ErrPriceChanged = errors.New("prices changed")
+	// ErrTooManyRequests операция превысила допустимую частоту.
+	ErrTooManyRequests = errors.New("too many requests")
+	// ErrConflict операция противоречит другой, еще не завершенной операции.
+	ErrConflict = errors.New("conflict")
+	// ErrServiceUnavailable сервер временно перегружен, запрос можно повторить позже.
+	ErrServiceUnavailable = errors.New("service unavailable")
+	// ErrBadGateway внешний сервер, к которому обращается сервер, недоступен или ответил неверно.
+	ErrBadGateway = errors.New("bad gateway")
+	// ErrMethodNotAllowed путь есть, но с другим HTTP-методом.
+	ErrMethodNotAllowed = errors.New("method not allowed")
+)
+
+// MinOrderError стоимость товаров в заказе меньше минимальной суммы заказа.
+type MinOrderError struct {
+	MinOrderAmount Money
+	OrderPrice     Money
+}
+
+func (e *MinOrderError) Error() string {
+	return fmt.Sprintf("%v: order price %s is less than minimum order amount %s", ErrBadRequest, e.OrderPrice, e.MinOrderAmount)
+}
+
+func (e *MinOrderError) Unwrap() error {
+	return ErrBadRequest
+}
+
+// Details дополнительные поля ответа, чтобы клиент мог показать, сколько осталось добрать.
+func (e *MinOrderError) Details() map[string]any {
+	return map[string]any{
+		"code":             "min_order_amount",
+		"minOrderAmount":   e.MinOrderAmount,
+		"amountToMinOrder": e.MinOrderAmount - e.OrderPrice,
+	}
+}
+
+// FraudError операция кошелька нарушила правило антифрода и заблокирована до решения преподавателя.
+type FraudError struct {
+	Rule        FraudRule
+	OperationID string
+	// Через сколько правило перестанет срабатывать, 0 - неизвестно.
+	RetryAfter time.Duration
+}
+
+func (e *FraudError) Error() string {
+	return fmt.Sprintf("%v: operation %s blocked by rule %s", e.Unwrap(), e.OperationID, e.Rule)
+}
+
+// Unwrap превышение частоты операций - 429, остальные правила - 400.
+func (e *FraudError) Unwrap() error {
+	if e.Rule == FraudRuleTransfersPerHour || e.Rule == FraudRuleTopupsPerHour {
+		return ErrTooManyRequests
+	}
+
+	return ErrBadRequest
+}
+
+func (e *FraudError) Details() map[string]any {
+	details := map[string]any{
+		"code":        "fraud_rule",
+		"rule":        e.Rule,
+		"operationId": e.OperationID,
+	}
+
+	if e.RetryAfter > 0 {
+		details["retryAfter"] = int(e.RetryAfter.Seconds())
+	}
+
+	return details
+}
+
+// PageSizeError запрошенный размер страницы больше допустимого.
+type PageSizeError struct {
+	PageSize    int
+	MaxPageSize int
+}
+
+func (e *PageSizeError) Error() string {
+	return fmt.Sprintf("%v: pageSize %d is greater than maximum %d", ErrBadRequest, e.PageSize, e.MaxPageSize)
+}
+
+func (e *PageSizeError) Unwrap() error {
+	return ErrBadRequest
+}
+
+func (e *PageSizeError) Details() map[string]any {
+	return map[string]any{
+		"code":        "page_size_too_large",
+		"maxPageSize": e.MaxPageSize,
+	}
+}
+
+// StoreClosedError заказ оформлен в нерабочее время, а заказы к открытию отключены.
+type StoreClosedError struct {
+	// Нулевое, если магазин больше не откроется по расписанию.
+	NextOpening time.Time
+}
+
+func (e *StoreClosedError) Error() string {
+	return fmt.Sprintf("%v: store is closed", ErrBadRequest)
+}
+
+func (e *StoreClosedError) Unwrap() error {
+	return ErrBadRequest
+}
+
+func (e *StoreClosedError) Details() map[string]any {
+	details := map[string]any{"code": "store_closed"}
+
+	if !e.NextOpening.IsZero() {
+		details["nextOpening"] = e.NextOpening
+	}
+
+	return details
+}
+
+// FieldError нарушение в одном поле запроса.
+type FieldError struct {
+	Field string `json:"field"`
+	// Машиночитаемая причина, например too_long или profanity.
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ValidationError запрос не прошел проверку. Перечислены все нарушения, а не только первое,
+// чтобы клиент мог подсветить сразу все поля.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		messages = append(messages, field.Field+": "+field.Message)
+	}
+
+	return fmt.Sprintf("%v: %s", ErrBadRequest, strings.Join(messages, "; "))
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrBadRequest
+}
+
+func (e *ValidationError) Details() map[string]any {
+	return map[string]any{
+		"code":   "validation_failed",
+		"fields": e.Fields,
+	}
+}
+
+// ReviewRateLimitError пользователь уже оставил максимум отзывов за последние сутки.
+type ReviewRateLimitError struct {
+	Limit      int
+	RetryAfter time.Duration
+}
+
+func (e *ReviewRateLimitError) Error() string {
+	return fmt.Sprintf("%v: no more than %d reviews per day", ErrTooManyRequests, e.Limit)
+}
+
+func (e *ReviewRateLimitError) Unwrap() error {
+	return ErrTooManyRequests
+}
+
+func (e *ReviewRateLimitError) Details() map[string]any {
+	return map[string]any{
+		"code":       "review_rate_limit",
+		"limit":      e.Limit,
+		"retryAfter": int(e.RetryAfter.Seconds()),
+	}
+}
+
+// EmailRateLimitError код подтверждения email запрошен слишком часто для пользователя или адреса.
+type EmailRateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *EmailRateLimitError) Error() string {
+	return fmt.Sprintf("%v: verification code was requested too often", ErrTooManyRequests)
+}
+
+func (e *EmailRateLimitError) Unwrap() error {
+	return ErrTooManyRequests
+}
+
+func (e *EmailRateLimitError) Details() map[string]any {
+	return map[string]any{
+		"code":       "email_rate_limit",
+		"retryAfter": int(math.Ceil(e.RetryAfter.Seconds())),
+	}
+}
+
+// DuplicateAddressError у пользователя уже есть такой адрес: та же строка адреса или точка рядом.
+type DuplicateAddressError struct {
+	AddressID string
+}
+
+func (e *DuplicateAddressError) Error() string {
+	return fmt.Sprintf("%v: address duplicates existing address %s", ErrConflict, e.AddressID)
+}
+
+func (e *DuplicateAddressError) Unwrap() error {
+	return ErrConflict
+}
+
+func (e *DuplicateAddressError) Details() map[string]any {
+	return map[string]any{
+		"code":      "duplicate_address",
+		"addressId": e.AddressID,
+	}
+}
+
+// AddressLimitError у пользователя уже сохранено максимальное число адресов.
+type AddressLimitError struct {
+	MaxAddresses int
+}
+
+func (e *AddressLimitError) Error() string {
+	return fmt.Sprintf("%v: no more than %d addresses allowed", ErrBadRequest, e.MaxAddresses)
+}
+
+func (e *AddressLimitError) Unwrap() error {
+	return ErrBadRequest
+}
+
+func (e *AddressLimitError) Details() map[string]any {
+	return map[string]any{
+		"code":         "address_limit",
+		"maxAddresses": e.MaxAddresses,
+	}
+}
+
+// QuantityLimitError в корзине или заказе больше единиц товара, чем разрешено.
+type QuantityLimitError struct {
+	ProductID string
+	Limit     int
+	Quantity  int
+}
+
+func (e *QuantityLimitError) Error() string {
+	return fmt.Sprintf("%v: no more than %d of product %s allowed, got %d", ErrBadRequest, e.Limit, e.ProductID, e.Quantity)
+}
+
+func (e *QuantityLimitError) Unwrap() error {
+	return ErrBadRequest
+}
+
+func (e *QuantityLimitError) Details() map[string]any {
+	return map[string]any{
+		"code":      "quantity_limit",
+		"productId": e.ProductID,
+		"limit":     e.Limit,
+		"quantity":  e.Quantity,
+	}
+}
+
+type WalletPINErrorReason string
+
+const (
+	// WalletPINRequired операция от порога, а PIN не передан.
+	WalletPINRequired WalletPINErrorReason = "pin_required"
+	// WalletPINInvalid PIN неверный.
+	WalletPINInvalid WalletPINErrorReason = "pin_invalid"
+	// WalletPINLocked слишком много ошибок, подтверждение временно заблокировано.
+	WalletPINLocked WalletPINErrorReason = "pin_locked"
 )
+
+// WalletPINError операция кошелька не подтверждена PIN.
+type WalletPINError struct {
+	Reason WalletPINErrorReason
+	// Сколько еще можно ошибиться до блокировки.
+	AttemptsLeft int
+	// Через сколько блокировка снимется, только у pin_locked.
+	RetryAfter time.Duration
+}
+
+func (e *WalletPINError) Error() string {
+	return fmt.Sprintf("%v: wallet operation not confirmed: %s", e.Unwrap(), e.Reason)
+}
+
+// Unwrap блокировка - 429, остальное - 403.
+func (e *WalletPINError) Unwrap() error {
+	if e.Reason == WalletPINLocked {
+		return ErrTooManyRequests
+	}
+
+	return ErrForbidden
+}
+
+func (e *WalletPINError) Details() map[string]any {
+	details := map[string]any{"code": string(e.Reason)}
+
+	if e.Reason == WalletPINLocked {
+		details["retryAfter"] = int(e.RetryAfter.Seconds())
+	} else {
+		details["attemptsLeft"] = e.AttemptsLeft
+	}
+
+	return details
+}
@@ -2,6 +2,9 @@ package models
 
 import (
 	"context"
+	"encoding/json"
+	"maps"
+	"slices"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -14,22 +17,87 @@ type Product struct {
 	Image       string  `json:"image"`
 	Name        string  `json:"name"`
 	Weight      int     `json:"weight"`
-	Price       int     `json:"price"`
+	Price       Money   `json:"price"`
 	Rating      float32 `json:"rating"`
 	Description string  `json:"description"`
 	// Размер скидки.
-	Discount   int      `json:"discount,omitempty"`
+	Discount int `json:"discount,omitempty"`
+	// Диетические метки: vegan, spicy, gluten-free и т.д.
+	Tags       []string `json:"tags"`
+	Allergens  []string `json:"allergens"`
 	Reviews    []Review `json:"reviews"`
 	IsFavorite bool     `json:"isFavorite"`
-	Available  bool     `json:"-"`
+	// Есть ли товар в наличии. Меняется через PUT /admin/products/{id}/availability.
+	Available bool `json:"available"`
+	// Часы, когда товар продается, например "08:00-11:00" для завтраков. Пусто - все время работы магазина.
+	AvailableHours string `json:"availableHours,omitempty"`
+	// Сколько единиц товара можно заказать за раз, например 2 для акционного товара. 0 - отдельного ограничения нет.
+	MaxQuantity int `json:"maxQuantity,omitempty"`
+	// Почему товар сейчас нельзя заказать по времени: store_closed или outside_hours.
+	UnavailableReason string `json:"unavailableReason,omitempty"`
+	// Переводы названия и описания: код языка -> текст. Name и Description на основном языке каталога.
+	Names        map[string]string `json:"names,omitempty"`
+	Descriptions map[string]string `json:"descriptions,omitempty"`
+	// Когда товар появился в каталоге, по нему определяются новинки. У товаров без поля дата неизвестна.
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+}
+
+// Localize подставляет название и описание на языке lang, если для него есть перевод.
+// Переводы на остальные языки клиенту не нужны и удаляются.
+func (p *Product) Localize(lang string) {
+	p.Name = translate(p.Names, lang, p.Name)
+	p.Description = translate(p.Descriptions, lang, p.Description)
+	p.Names = nil
+	p.Descriptions = nil
+}
+
+// OriginalPrice цена до скидки. Price - уже цена со скидкой, Discount - скидка в процентах от цены до скидки.
+func (p *Product) OriginalPrice() Money {
+	if p.Discount <= 0 || p.Discount >= 100 {
+		return p.Price
+	}
+
+	return p.Price.MulDiv(100, int64(100-p.Discount))
+}
+
+// LocalizedName возвращает название на языке lang или на основном языке, если перевода нет.
+func (p *Product) LocalizedName(lang string) string {
+	return translate(p.Names, lang, p.Name)
+}
+
+// LocalizedDescription возвращает описание на языке lang или на основном языке, если перевода нет.
+func (p *Product) LocalizedDescription(lang string) string {
+	return translate(p.Descriptions, lang, p.Description)
+}
+
+func translate(translations map[string]string, lang, fallback string) string {
+	if translation, ok := translations[lang]; ok && translation != "" {
+		return translation
+	}
+
+	return fallback
 }
 
 type Review struct {
-	Rating    int       `json:"rating"`
-	Author    string    `json:"author"`
+	Rating int `json:"rating"`
+	// Автор отзыва. В каталоге хранится никнейм на момент отзыва, в ответе - имя из текущего профиля.
+	Author string `json:"author"`
+	// Пользователь, оставивший отзыв. Нет у отзывов из файла данных и у авторов удаленных аккаунтов.
+	UserID    string    `json:"userId,omitempty"`
 	CreatedAt time.Time `json:"createdAt"`
 	Content   string    `json:"content"`
 	Images    []string  `json:"images"`
+	// Аватар автора из текущего профиля, заполняется при чтении.
+	AuthorImage string `json:"authorImageUri,omitempty"`
+	// Аккаунт автора удален: имя, аватар и userId скрыты.
+	AuthorDeleted bool `json:"authorDeleted,omitempty"`
+}
+
+// ReviewAuthor текущие данные автора отзывов из профиля
+type ReviewAuthor struct {
+	Name    string
+	Image   string
+	Deleted bool
 }
 
 type PostReviewRequest struct {
@@ -38,17 +106,52 @@ type PostReviewRequest struct {
 	Images  []string `json:"images"`
 }
 
+// ReviewSummary сводка отзывов для карточки товара, чтобы не загружать все отзывы
+type ReviewSummary struct {
+	ProductID string `json:"productId"`
+	// Средняя оценка по отзывам, 0 - отзывов нет.
+	Average float64 `json:"average"`
+	Count   int     `json:"count"`
+	// Число отзывов по оценкам от 5 до 1, оценки без отзывов тоже есть в списке.
+	Distribution []RatingCount `json:"distribution"`
+	// Лучший положительный (4-5) и отрицательный (1-2) отзыв с текстом.
+	TopPositive *ReviewSnippet `json:"topPositive,omitempty"`
+	TopNegative *ReviewSnippet `json:"topNegative,omitempty"`
+}
+
+type RatingCount struct {
+	Rating int `json:"rating"`
+	Count  int `json:"count"`
+}
+
+// ReviewSnippet начало текста отзыва, полный отзыв есть в товаре
+type ReviewSnippet struct {
+	Rating        int       `json:"rating"`
+	Author        string    `json:"author"`
+	UserID        string    `json:"userId,omitempty"`
+	AuthorImage   string    `json:"authorImageUri,omitempty"`
+	AuthorDeleted bool      `json:"authorDeleted,omitempty"`
+	CreatedAt     time.Time `json:"createdAt"`
+	Text          string    `json:"text"`
+	// Текст обрезан.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
 type ProductPreview struct {
 	ID          string  `json:"id"`
 	Image       string  `json:"image"`
 	Name        string  `json:"name"`
 	Weight      int     `json:"weight"`
-	Price       int     `json:"price"`
+	Price       Money   `json:"price"`
 	Rating      float32 `json:"rating"`
 	ReviewCount int     `json:"reviewCount"`
 	IsFavorite  bool    `json:"isFavorite"`
 	// Размер скидки.
-	Discount int `json:"discount,omitempty"`
+	Discount int      `json:"discount,omitempty"`
+	Tags     []string `json:"tags"`
+	// Почему товар сейчас нельзя заказать по времени: store_closed или outside_hours.
+	UnavailableReason string     `json:"unavailableReason,omitempty"`
+	CreatedAt         *time.Time `json:"createdAt,omitempty"`
 }
 
 func (p *Product) ToPreview() ProductPreview {
@@ -60,10 +163,239 @@ func (p *Product) ToPreview() ProductPreview {
 		Rating:      p.Rating,
 		Weight:      p.Weight,
 		Discount:    p.Discount,
+		Tags:        p.Tags,
 		ReviewCount: len(p.Reviews),
+		CreatedAt:   p.CreatedAt,
 	}
 }
 
+// FavouriteCategory устаревшая псевдокатегория: GET /products?category=favourite возвращает избранное.
+// Вместо нее нужно использовать GET /favourites.
+const FavouriteCategory = "favourite"
+
+const (
+	FavouriteActionAdd    = "add"
+	FavouriteActionRemove = "remove"
+	// Сколько операций можно передать в одном POST /favourites/batch.
+	MaxFavouriteOperations = 100
+)
+
+// FavouriteOperation добавление или удаление одного товара в избранном.
+type FavouriteOperation struct {
+	ProductID string `json:"id"`
+	Action    string `json:"action"`
+}
+
+// FavouritesBatchRequest тело POST /favourites/batch. Операции применяются по порядку.
+type FavouritesBatchRequest struct {
+	Operations []FavouriteOperation `json:"operations"`
+}
+
+// FavouritesBatchResponse избранное после применения всех операций.
+type FavouritesBatchResponse struct {
+	Favourites []string `json:"favourites"`
+}
+
+// ProductsFilter параметры фильтрации списка товаров.
+type ProductsFilter struct {
+	Category string
+	// Товар должен иметь все перечисленные метки.
+	Tags []string
+	// Товар не должен содержать ни одного из перечисленных аллергенов.
+	ExcludeAllergens []string
+	// Границы цены и веса включительно, nil - без ограничения.
+	PriceMin  *Money
+	PriceMax  *Money
+	WeightMin *int
+	WeightMax *int
+	// true - только товары со скидкой, false - только без скидки, nil - все.
+	HasDiscount *bool
+	// Порядок товаров, по умолчанию порядок каталога.
+	Sort ProductSort
+	// Ассортимент зоны доставки пользователя, nil - весь каталог.
+	Zone *ZoneFilter
+	// Товары подборки, nil - без подборки.
+	Collection *CollectionFilter
+}
+
+// CollectionFilter товары подборки. Без явного порядка список идет в порядке подборки.
+type CollectionFilter struct {
+	ProductIDs []string
+}
+
+// ZoneFilter ассортимент зоны доставки. Товар, привязанный хотя бы к одной зоне напрямую или через категорию,
+// показывается только в своих зонах, остальные товары - везде.
+type ZoneFilter struct {
+	// Зона адреса пользователя, nil - адрес вне всех зон.
+	Zone *DeliveryZone
+	// Товары и категории, привязанные к каким-либо зонам.
+	Products   map[string]struct{}
+	Categories map[string]struct{}
+}
+
+type ProductSort string
+
+// Порядки товаров в списке. При равных значениях товары идут по идентификатору.
+const (
+	ProductSortPriceAsc  ProductSort = "price_asc"
+	ProductSortPriceDesc ProductSort = "price_desc"
+	ProductSortRating    ProductSort = "rating"
+	// ProductSortPopularity сначала товары, которые чаще смотрят и заказывают.
+	ProductSortPopularity ProductSort = "popularity"
+	// ProductSortNew сначала товары с более поздним createdAt, затем товары без даты в обратном порядке каталога.
+	ProductSortNew ProductSort = "new"
+)
+
+const (
+	DefaultPopularLimit = 10
+	MaxPopularLimit     = 50
+)
+
+// CollectionNew встроенная подборка новинок: товары, появившиеся в каталоге за последний NEW_PRODUCTS_PERIOD.
+const CollectionNew = "new"
+
+// Сколько товаров подборки отдается для ленты на главном экране.
+const (
+	DefaultCollectionRailLimit = 10
+	MaxCollectionRailLimit     = 50
+)
+
+// Collection подборка товаров, которую ведет преподаватель, например "Летнее меню".
+type Collection struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Переводы названия: код языка -> текст.
+	Names map[string]string `json:"names,omitempty"`
+	// Место подборки на главном экране: меньше - выше, при равных - по идентификатору.
+	Position int `json:"position"`
+	// Товары в порядке показа.
+	ProductIDs []string `json:"productIds"`
+	// Сезонная подборка показывается только в этот период, пустая граница - без ограничения.
+	ActiveFrom *time.Time `json:"activeFrom,omitempty"`
+	ActiveTo   *time.Time `json:"activeTo,omitempty"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+}
+
+// LocalizedName возвращает название на языке lang или на основном языке, если перевода нет.
+func (c *Collection) LocalizedName(lang string) string {
+	return translate(c.Names, lang, c.Name)
+}
+
+// Active показывается ли подборка в момент now
+func (c *Collection) Active(now time.Time) bool {
+	return (c.ActiveFrom == nil || !now.Before(*c.ActiveFrom)) && (c.ActiveTo == nil || now.Before(*c.ActiveTo))
+}
+
+// CollectionRequest создание или замена подборки.
+type CollectionRequest struct {
+	Name       string            `json:"name"`
+	Names      map[string]string `json:"names,omitempty"`
+	Position   int               `json:"position"`
+	ProductIDs []string          `json:"productIds"`
+	ActiveFrom *time.Time        `json:"activeFrom,omitempty"`
+	ActiveTo   *time.Time        `json:"activeTo,omitempty"`
+}
+
+// CollectionRail подборка для ленты на главном экране с первыми товарами. Все товары подборки -
+// GET /products?collection={id}.
+type CollectionRail struct {
+	ID       string           `json:"id"`
+	Name     string           `json:"name"`
+	Products []ProductPreview `json:"products"`
+}
+
+// Ограничения числа результатов в каждой группе глобального поиска.
+const (
+	DefaultSearchLimit = 5
+	MaxSearchLimit     = 20
+	// Более короткие запросы совпадают почти со всем каталогом.
+	MinSearchQueryLength = 2
+)
+
+type SearchType string
+
+const (
+	SearchTypeProducts   SearchType = "products"
+	SearchTypeCategories SearchType = "categories"
+	SearchTypeOrders     SearchType = "orders"
+)
+
+// SearchRequest запрос глобального поиска. Пустой Types - все группы.
+type SearchRequest struct {
+	Query string
+	Types []SearchType
+	Limit int
+}
+
+// SearchResults результаты глобального поиска по группам. Группы, не выбранные фильтром type, не возвращаются.
+type SearchResults struct {
+	Query      string               `json:"query"`
+	Products   *ProductSearchGroup  `json:"products,omitempty"`
+	Categories *CategorySearchGroup `json:"categories,omitempty"`
+	Orders     *OrderSearchGroup    `json:"orders,omitempty"`
+}
+
+// ProductSearchGroup первые найденные товары и сколько их всего.
+type ProductSearchGroup struct {
+	Items []ProductPreview `json:"items"`
+	Total int              `json:"total"`
+}
+
+type CategorySearchGroup struct {
+	Items []Category `json:"items"`
+	Total int        `json:"total"`
+}
+
+type OrderSearchGroup struct {
+	Items []OrderSearchResult `json:"items"`
+	Total int                 `json:"total"`
+}
+
+// OrderSearchResult прошлый заказ, в котором есть товары с искомым названием.
+type OrderSearchResult struct {
+	ID           string      `json:"id"`
+	Status       OrderStatus `json:"status"`
+	DeliveryDate string      `json:"deliveryDate"`
+	TotalPrice   Money       `json:"totalPrice"`
+	// Названия товаров заказа, совпавшие с запросом.
+	MatchedItems []string `json:"matchedItems"`
+}
+
+type Tag struct {
+	Name         string `json:"name"`
+	ProductCount int    `json:"productCount"`
+}
+
+// ProductFacets значения фильтров по текущему каталогу, чтобы клиент строил фильтры без зашитых диапазонов.
+type ProductFacets struct {
+	ProductCount int          `json:"productCount"`
+	Price        MoneyRange   `json:"price"`
+	Weight       IntRange     `json:"weight"`
+	WeightRanges []CountRange `json:"weightRanges"`
+	Tags         []Tag        `json:"tags"`
+	Allergens    []string     `json:"allergens"`
+	// Сколько товаров со скидкой и какая скидка самая большая.
+	DiscountedCount int `json:"discountedCount"`
+	MaxDiscount     int `json:"maxDiscount"`
+}
+
+type IntRange struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+type MoneyRange struct {
+	Min Money `json:"min"`
+	Max Money `json:"max"`
+}
+
+// CountRange диапазон [From, To) и число товаров в нем. У последнего диапазона нет верхней границы.
+type CountRange struct {
+	From         int  `json:"from"`
+	To           *int `json:"to,omitempty"`
+	ProductCount int  `json:"productCount"`
+}
+
 type ProductsList struct {
 	CurrentPage int              `json:"currentPage"`
 	TotalPages  int              `json:"totalPages"`
@@ -74,12 +406,64 @@ type Category struct {
 	ID    string `json:"id"`
 	Name  string `json:"name"`
 	Image string `json:"image"`
+	// Переводы названия: код языка -> текст.
+	Names map[string]string `json:"names,omitempty"`
+	// Число товаров в категории, считается по каталогу и в файле данных не хранится.
+	ProductCount int `json:"productCount"`
+}
+
+// Localize подставляет название на языке lang, если для него есть перевод.
+func (c *Category) Localize(lang string) {
+	c.Name = translate(c.Names, lang, c.Name)
+	c.Names = nil
 }
+
 type AuthTokenClaims struct {
 	*jwt.RegisteredClaims
 
 	Nickname  string `json:"nickname"`
 	IsTeacher bool   `json:"isTeacher"`
+	// Роль токена. В токенах, выданных до появления курьеров, ее нет: роль определяется по IsTeacher.
+	Role TokenRole `json:"role,omitempty"`
+}
+
+// GetRole роль токена. У старых токенов без роли она определяется по IsTeacher.
+func (c *AuthTokenClaims) GetRole() TokenRole {
+	switch {
+	case c.Role != "":
+		return c.Role
+	case c.IsTeacher:
+		return TokenRoleTeacher
+	default:
+		return TokenRoleStudent
+	}
+}
+
+// IsCourier токен выдан курьеру.
+func (c *AuthTokenClaims) IsCourier() bool {
+	return c != nil && c.GetRole() == TokenRoleCourier
+}
+
+type TokenRole string
+
+const (
+	TokenRoleStudent TokenRole = "student"
+	TokenRoleTeacher TokenRole = "teacher"
+	TokenRoleCourier TokenRole = "courier"
+)
+
+// IssuedToken запись журнала выданных токенов. Сам токен не хранится.
+type IssuedToken struct {
+	ID       string    `json:"id"`
+	Nickname string    `json:"nickname"`
+	Role     TokenRole `json:"role"`
+	// Никнейм выдавшего и id его токена, bootstrap - вход администратора по паролю
+	IssuedBy        string    `json:"issuedBy"`
+	IssuedByTokenID string    `json:"issuedByTokenId,omitempty"`
+	IssuedAt        time.Time `json:"issuedAt,omitzero"`
+	// Токены выдаются бессрочными, поле пустое, пока у токена нет срока действия
+	ExpiresAt *time.Time `json:"expiresAt"`
+	Revoked   bool       `json:"revoked"`
 }
 
 type ContextClaimsKey struct{}
@@ -90,11 +474,43 @@ func ClaimsFromContext(ctx context.Context) *AuthTokenClaims {
 	return claims
 }
 
+// ContextWithUser возвращает контекст с данными пользователя для фоновых задач,
+// которые выполняют действия от его имени без запроса.
+func ContextWithUser(ctx context.Context, userID string) context.Context {
+	claims := &AuthTokenClaims{RegisteredClaims: &jwt.RegisteredClaims{ID: userID}}
+
+	return context.WithValue(ctx, ContextClaimsKey{}, claims)
+}
+
+type ContextLanguageKey struct{}
+
+// LanguageFromContext возвращает язык ответа, выбранный по Accept-Language.
+func LanguageFromContext(ctx context.Context) string {
+	lang, _ := ctx.Value(ContextLanguageKey{}).(string)
+
+	return lang
+}
+
 type UserProfile struct {
 	Phone    string `json:"phone"`
 	Name     string `json:"name"`
 	Birthday string `json:"birthday"`
 	Image    string `json:"imageUri"`
+	// Подтвержденный email. Пока адрес не подтвержден, он хранится в PendingEmail.
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"emailVerified"`
+	PendingEmail  string `json:"pendingEmail,omitempty"`
+	// Аккаунт удален через DELETE /users/me. Отзывы пользователя показываются без автора, пока он
+	// снова не заполнит профиль.
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+type SetEmailRequest struct {
+	Email string `json:"email"`
+}
+
+type VerifyEmailRequest struct {
+	Code string `json:"code"`
 }
 
 type UpdateUserRequest struct {
@@ -112,13 +528,39 @@ type Address struct {
 	Entrance     string    `json:"entrance"`
 	IntercomCode string    `json:"intercomCode"`
 	Comment      string    `json:"comment"`
+	// Тип адреса для иконки в списке, по умолчанию other.
+	Label AddressLabel `json:"label"`
+	// Название адреса, заданное пользователем, например "Дача".
+	Name string `json:"name,omitempty"`
 }
 
+// AddressPatch частичное изменение адреса: поля, которых нет в запросе, не меняются.
+type AddressPatch struct {
+	Coordinates  []float64     `json:"coordinates,omitempty"`
+	AddressLine  *string       `json:"addressLine,omitempty"`
+	Floor        *string       `json:"floor,omitempty"`
+	Entrance     *string       `json:"entrance,omitempty"`
+	IntercomCode *string       `json:"intercomCode,omitempty"`
+	Comment      *string       `json:"comment,omitempty"`
+	Label        *AddressLabel `json:"label,omitempty"`
+	Name         *string       `json:"name,omitempty"`
+}
+
+type AddressLabel string
+
+const (
+	AddressLabelHome  AddressLabel = "home"
+	AddressLabelWork  AddressLabel = "work"
+	AddressLabelOther AddressLabel = "other"
+)
+
 type OrderStatus string
 
 const (
 	OrderStatusActive    OrderStatus = "active"
 	OrderStatusCompleted OrderStatus = "completed"
+	// Заказ полностью возвращен.
+	OrderStatusRefunded OrderStatus = "refunded"
 )
 
 type Order struct {
@@ -127,14 +569,220 @@ type Order struct {
 	DeliveryDate string      `json:"deliveryDate"`
 	Address      Address     `json:"address"`
 	// Стоимость товаров в заказе.
-	OrderPrice int `json:"orderPrice"`
+	OrderPrice Money `json:"orderPrice"`
 	// Стоимость доставки.
-	DeliveryPrice int `json:"deliveryPrice"`
-	// Общая стоимость.
-	TotalPrice int         `json:"totalPrice"`
+	DeliveryPrice Money `json:"deliveryPrice"`
+	// Чаевые курьеру.
+	Tip Money `json:"tip,omitempty"`
+	// Общая стоимость: товары за вычетом скидок, доставка, опции и чаевые.
+	TotalPrice Money       `json:"totalPrice"`
 	TotalItems int         `json:"totalItems"`
 	Items      []OrderItem `json:"items"`
 	CreatedAt  time.Time   `json:"-"`
+	// Чем и как оплачен заказ. У заказов, созданных до появления поля, его нет.
+	Payment *OrderPayment `json:"payment,omitempty"`
+	// Скидки, примененные к стоимости товаров.
+	Discounts []OrderDiscount `json:"discounts,omitempty"`
+	// Подписка, по которой создан заказ.
+	SubscriptionID string       `json:"subscriptionId,omitempty"`
+	Options        OrderOptions `json:"options"`
+	// Возвраты по заказу, только если они были.
+	Refund *OrderRefund `json:"refund,omitempty"`
+	// Доставка курьером, есть после назначения курьера.
+	Delivery *OrderDelivery `json:"delivery,omitempty"`
+	// Заказ, оформленный в нерабочее время, начинают собирать к открытию.
+	ScheduledFor *time.Time `json:"scheduledFor,omitempty"`
+	// Ожидаемое время доставки, сдвигается задержками. У заказов, созданных до появления поля, его нет.
+	ETA *time.Time `json:"eta,omitempty"`
+	// Задержки доставки, только если они были.
+	Delays []OrderDelay `json:"delays,omitempty"`
+	// Выбранные опции заказа с доплатами на момент оформления.
+	Extras []OrderExtra `json:"extras,omitempty"`
+	// Сумма доплат за опции, входит в TotalPrice.
+	ExtrasPrice Money `json:"extrasPrice,omitempty"`
+	// Смены статуса заказа и доставки по времени, из них строится GET /orders/{id}/timeline.
+	// Задержки и возвраты хранятся в своих полях. У заказов, созданных до появления поля, истории нет.
+	History []OrderEvent `json:"history,omitempty"`
+}
+
+type OrderEventType string
+
+const (
+	OrderEventCreated OrderEventType = "created"
+	// Курьеру предложен заказ, курьер принял его или отказался.
+	OrderEventCourierAssigned OrderEventType = "courier_assigned"
+	OrderEventCourierAccepted OrderEventType = "courier_accepted"
+	OrderEventCourierDeclined OrderEventType = "courier_declined"
+	OrderEventPickedUp        OrderEventType = "picked_up"
+	// Заказ вручен курьером или доставлен по времени, если курьера не было.
+	OrderEventDelivered OrderEventType = "delivered"
+	OrderEventDelayed   OrderEventType = "delayed"
+	OrderEventRefunded  OrderEventType = "refunded"
+)
+
+// OrderEvent смена статуса в истории заказа.
+type OrderEvent struct {
+	Type OrderEventType `json:"type"`
+	At   time.Time      `json:"at"`
+	// Курьер, у событий доставки.
+	Courier string `json:"courier,omitempty"`
+}
+
+// OrderTimeline ход заказа для экрана доставки: события по времени, старые первыми.
+type OrderTimeline struct {
+	OrderID string      `json:"orderId"`
+	Status  OrderStatus `json:"status"`
+	// Ожидаемое время доставки, только у активных заказов.
+	ETA    *time.Time           `json:"eta,omitempty"`
+	Events []OrderTimelineEvent `json:"events"`
+}
+
+// OrderTimelineEvent событие заказа с текстом на языке запроса.
+type OrderTimelineEvent struct {
+	Type        OrderEventType `json:"type"`
+	At          time.Time      `json:"at"`
+	Title       string         `json:"title"`
+	Description string         `json:"description,omitempty"`
+}
+
+// OrderDelay задержка доставки: сколько добавлено к ожидаемому времени и почему.
+type OrderDelay struct {
+	Minutes   int       `json:"minutes"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// OrderDelayRequest тело запроса на задержку заказа.
+type OrderDelayRequest struct {
+	Minutes int    `json:"minutes"`
+	Reason  string `json:"reason"`
+}
+
+// Ограничения задержки заказа.
+const (
+	MaxOrderDelayMinutes = 120
+	MaxDelayReasonLength = 200
+)
+
+// ClockState текущее время сервера. В демо-режиме оно может быть перемотано вперед.
+type ClockState struct {
+	Now           time.Time `json:"now"`
+	OffsetMinutes int       `json:"offsetMinutes"`
+	Simulated     bool      `json:"simulated"`
+}
+
+// AdvanceClockRequest тело запроса на перемотку времени в демо-режиме.
+type AdvanceClockRequest struct {
+	Minutes int `json:"minutes"`
+}
+
+// За один запрос время перематывается не больше чем на неделю.
+const MaxClockAdvanceMinutes = 7 * 24 * 60
+
+type DeliveryStatus string
+
+const (
+	// Курьеру предложен заказ, он еще не принял его
+	DeliveryStatusAssigned  DeliveryStatus = "assigned"
+	DeliveryStatusAccepted  DeliveryStatus = "accepted"
+	DeliveryStatusPickedUp  DeliveryStatus = "picked_up"
+	DeliveryStatusDelivered DeliveryStatus = "delivered"
+)
+
+// OrderDelivery доставка заказа курьером.
+type OrderDelivery struct {
+	CourierID string         `json:"courierId"`
+	Courier   string         `json:"courier"`
+	Status    DeliveryStatus `json:"status"`
+	// Фото доставленного заказа.
+	PhotoURL  string    `json:"photoUrl,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// CourierOrder заказ в приложении курьера: только то, что нужно для доставки.
+type CourierOrder struct {
+	ID            string         `json:"id"`
+	Status        DeliveryStatus `json:"status"`
+	Address       Address        `json:"address"`
+	Items         []OrderItem    `json:"items"`
+	TotalItems    int            `json:"totalItems"`
+	TotalPrice    Money          `json:"totalPrice"`
+	PaymentMethod PaymentMethod  `json:"paymentMethod,omitempty"`
+	Options       OrderOptions   `json:"options"`
+	PhotoURL      string         `json:"photoUrl,omitempty"`
+	UpdatedAt     time.Time      `json:"updatedAt"`
+}
+
+// DeliveryStatusRequest тело запроса на смену статуса доставки.
+type DeliveryStatusRequest struct {
+	Status DeliveryStatus `json:"status"`
+}
+
+// DeliveryPhotoRequest тело запроса с фото доставки: имя файла из /uploads или ссылка на него.
+type DeliveryPhotoRequest struct {
+	Photo string `json:"photo"`
+}
+
+// OrderPayment оплата заказа.
+type OrderPayment struct {
+	Method PaymentMethod `json:"method"`
+	// Сколько заплачено.
+	Amount Money `json:"amount"`
+	// Карта кошелька, с которой списана оплата. Если одной карты не хватило, остаток списан со следующих.
+	AccountID string `json:"accountId,omitempty"`
+	// Операция кошелька по оплате заказа.
+	TransactionID string `json:"transactionId,omitempty"`
+}
+
+type DiscountType string
+
+const (
+	DiscountTypePromoCode DiscountType = "promo_code"
+	DiscountTypeCombo     DiscountType = "combo"
+)
+
+// OrderDiscount скидка на товары заказа.
+type OrderDiscount struct {
+	Type DiscountType `json:"type"`
+	// Промокод, если скидка по промокоду, или набор, если скидка за набор.
+	Code string `json:"code,omitempty"`
+	// Размер скидки.
+	Amount Money `json:"amount"`
+}
+
+type RefundStatus string
+
+const (
+	RefundStatusPartial RefundStatus = "partial"
+	RefundStatusFull    RefundStatus = "full"
+)
+
+// OrderRefund возвраты по заказу.
+type OrderRefund struct {
+	Status RefundStatus `json:"status"`
+	// Сколько уже возвращено.
+	Amount  Money    `json:"amount"`
+	Refunds []Refund `json:"refunds"`
+}
+
+// Refund один возврат денег за заказ.
+type Refund struct {
+	ID        string       `json:"id"`
+	Amount    Money        `json:"amount"`
+	Items     []RefundItem `json:"items"`
+	Reason    string       `json:"reason,omitempty"`
+	CreatedAt time.Time    `json:"createdAt"`
+}
+
+type RefundItem struct {
+	ID       string `json:"id"`
+	Quantity int    `json:"quantity"`
+}
+
+// RefundRequest тело запроса на возврат. Без позиций возвращается все, что еще не возвращено, вместе с доставкой.
+type RefundRequest struct {
+	Items  []RefundItem `json:"items"`
+	Reason string       `json:"reason"`
 }
 
 type OrderItem struct {
@@ -142,21 +790,93 @@ type OrderItem struct {
 	Image    string `json:"image"`
 	Name     string `json:"name"`
 	Weight   int    `json:"weight"`
-	Price    int    `json:"price"`
+	Price    Money  `json:"price"`
 	Quantity int    `json:"quantity"`
+	Comment  string `json:"comment,omitempty"`
+	// Набор, в составе которого заказан товар. Цена товара обычная, скидка набора - в discounts заказа.
+	ComboID string `json:"comboId,omitempty"`
+}
+
+// OrderOptions пожелания к заказу для курьера и кухни.
+type OrderOptions struct {
+	// Сколько положить приборов, 0 - без приборов.
+	Cutlery     int  `json:"cutlery"`
+	LeaveAtDoor bool `json:"leaveAtDoor"`
+	// Дополнительные опции из каталога GET /order-extras.
+	Extras OrderExtras `json:"extras,omitempty"`
+}
+
+// OrderExtras выбранные опции заказа: ID опции из каталога -> выбрана ли она.
+type OrderExtras map[string]bool
+
+// OrderExtra опция заказа из каталога, например многоразовый пакет или доставка без контакта.
+type OrderExtra struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Доплата за опцию, входит в итог заказа.
+	Surcharge Money `json:"surcharge"`
 }
 
+// Ограничения пожеланий к заказу.
+const (
+	MaxCutlery       = 20
+	MaxCommentLength = 200
+	// Максимальные чаевые курьеру.
+	MaxTip = Money(1000 * MinorUnits)
+)
+
 type CartResponse struct {
 	// Сколько минут займет доставка.
 	DeliveryTime int `json:"deliveryTime"`
 	// Стоимость товаров в заказе.
-	OrderPrice int `json:"orderPrice"`
+	OrderPrice Money `json:"orderPrice"`
 	// Стоимость доставки.
-	DeliveryPrice int `json:"deliveryPrice"`
-	// Общая стоимость.
-	TotalPrice int                `json:"totalPrice"`
+	DeliveryPrice Money `json:"deliveryPrice"`
+	// Общая стоимость: товары, доставка и чаевые.
+	TotalPrice Money              `json:"totalPrice"`
 	TotalItems int                `json:"totalItems"`
 	Items      []CartResponseItem `json:"items"`
+	Totals     CartTotals         `json:"totals"`
+	// Сколько не хватает до минимальной суммы заказа и до бесплатной доставки.
+	// 0, если порог достигнут или не задан.
+	AmountToMinOrder     Money `json:"amountToMinOrder"`
+	AmountToFreeDelivery Money `json:"amountToFreeDelivery"`
+	// Адрес, до которого посчитана доставка, и расстояние до него в км. Пустые, если адрес не выбран.
+	AddressID        string  `json:"addressId,omitempty"`
+	DeliveryDistance float64 `json:"deliveryDistance,omitempty"`
+}
+
+// CartTotals разбивка итога корзины, чтобы клиенту не пересчитывать суммы самому. Учитываются только
+// доступные товары.
+type CartTotals struct {
+	// Стоимость товаров по ценам до скидок.
+	Subtotal Money `json:"subtotal"`
+	// Скидки на товары.
+	Discounts Money `json:"discounts"`
+	Delivery  Money `json:"delivery"`
+	Tip       Money `json:"tip"`
+	// Subtotal - Discounts + Delivery + Tip, совпадает с TotalPrice корзины.
+	Total Money `json:"total"`
+}
+
+// CurrentAddressRequest тело запроса на выбор адреса доставки.
+type CurrentAddressRequest struct {
+	AddressID string `json:"addressId"`
+}
+
+// DeliveryInfo условия доставки. Нулевые минимальная сумма и порог бесплатной доставки означают, что их нет.
+type DeliveryInfo struct {
+	MinOrderAmount        Money `json:"minOrderAmount"`
+	FreeDeliveryThreshold Money `json:"freeDeliveryThreshold"`
+	BaseDeliveryPrice     Money `json:"baseDeliveryPrice"`
+	DeliveryPricePerKm    Money `json:"deliveryPricePerKm"`
+	// Время доставки без учета расстояния в минутах.
+	BaseDeliveryTime int `json:"baseDeliveryTime"`
+	// Открыт ли магазин сейчас. Когда закрывается открытый магазин и когда откроется закрытый.
+	IsOpen      bool       `json:"isOpen"`
+	ClosesAt    *time.Time `json:"closesAt,omitempty"`
+	NextOpening *time.Time `json:"nextOpening,omitempty"`
 }
 
 type CartResponseItem struct {
@@ -164,20 +884,157 @@ type CartResponseItem struct {
 	Image     string `json:"image"`
 	Name      string `json:"name"`
 	Weight    int    `json:"weight"`
-	Price     int    `json:"price"`
-	Quantity  int    `json:"quantity"`
+	// Цена за штуку со скидкой и без нее.
+	Price         Money `json:"price"`
+	OriginalPrice Money `json:"originalPrice"`
+	Quantity      int   `json:"quantity"`
+	// Скидка на всю позицию и стоимость позиции: цена со скидкой, умноженная на количество.
+	Discount  Money  `json:"discount"`
+	LineTotal Money  `json:"lineTotal"`
+	Comment   string `json:"comment,omitempty"`
 	Available bool   `json:"available"`
+	// Почему товар нельзя заказать, пусто для доступных товаров.
+	UnavailableReason string `json:"unavailableReason,omitempty"`
+	// Больше скольких единиц товара положить нельзя, 0 - без ограничения.
+	MaxQuantity int `json:"maxQuantity,omitempty"`
+	// Набор, если позиция - набор товаров: тогда id - идентификатор набора, цены - цены набора,
+	// а товары перечислены в components.
+	ComboID    string      `json:"comboId,omitempty"`
+	Components []ComboItem `json:"components,omitempty"`
+}
+
+const (
+	UnavailableRemovedFromCatalog = "removed_from_catalog"
+	UnavailableOutOfStock         = "out_of_stock"
+	// Магазин закрыт или товар продается только в другие часы.
+	UnavailableStoreClosed  = "store_closed"
+	UnavailableOutsideHours = "outside_hours"
+)
+
+// ComboCartPrefix отличает наборы от товаров в корзине: набор лежит в ней одной позицией с ключом combo:<id>.
+const ComboCartPrefix = "combo:"
+
+// ComboCartKey ключ набора в корзине.
+func ComboCartKey(comboID string) string {
+	return ComboCartPrefix + comboID
+}
+
+// Combo набор товаров по цене ниже, чем товары по отдельности.
+type Combo struct {
+	ID          string      `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	Image       string      `json:"image,omitempty"`
+	Items       []ComboItem `json:"items"`
+	// Цена набора.
+	Price Money `json:"price"`
+	// Сколько стоят товары набора по отдельности без скидок, считается по каталогу.
+	OriginalPrice Money `json:"originalPrice"`
+	// Вес всех товаров набора.
+	Weight    int  `json:"weight"`
+	Available bool `json:"available"`
+	// Почему набор нельзя заказать: причина первого недоступного товара.
+	UnavailableReason string `json:"unavailableReason,omitempty"`
+}
+
+// ComboItem товар в наборе. Название, картинка, вес и цена заполняются по каталогу.
+type ComboItem struct {
+	ProductID string `json:"id"`
+	Quantity  int    `json:"quantity"`
+	Name      string `json:"name,omitempty"`
+	Image     string `json:"image,omitempty"`
+	Weight    int    `json:"weight,omitempty"`
+	// Цена за штуку без скидки набора.
+	Price Money `json:"price,omitempty"`
+}
+
+// CartCleanupResult позиции, удаленные из корзины при очистке.
+type CartCleanupResult struct {
+	Removed []CartResponseItem `json:"removed"`
 }
 
 type CartItem struct {
 	ProductID string `json:"id"`
 	Quantity  int    `json:"quantity"`
+	Comment   string `json:"comment,omitempty"`
+}
+
+// CartQuantityRequest тело запроса на изменение количества товара в корзине, 0 убирает товар.
+type CartQuantityRequest struct {
+	Quantity int `json:"quantity"`
+}
+
+// CartCommentRequest тело запроса на изменение комментария к товару в корзине.
+type CartCommentRequest struct {
+	Comment string `json:"comment"`
+}
+
+type PaymentMethod string
+
+const (
+	PaymentMethodCard   PaymentMethod = "card"
+	PaymentMethodCash   PaymentMethod = "cash"
+	PaymentMethodWallet PaymentMethod = "wallet"
+)
+
+type CheckoutPreviewRequest struct {
+	AddressID     string        `json:"addressId"`
+	PaymentMethod PaymentMethod `json:"paymentMethod"`
+	PromoCode     string        `json:"promoCode,omitempty"`
+	// Опции заказа, доплаты за них входят в итог.
+	Options OrderOptions `json:"options"`
+	// Чаевые курьеру, входят в итог, как в заказе.
+	Tip Money `json:"tip,omitempty"`
+}
+
+// CheckoutPreview рассчитанный заказ для экрана подтверждения, сам заказ не создается.
+type CheckoutPreview struct {
+	Address       Address       `json:"address"`
+	PaymentMethod PaymentMethod `json:"paymentMethod"`
+	Items         []OrderItem   `json:"items"`
+	TotalItems    int           `json:"totalItems"`
+	// Стоимость товаров в заказе, товары из наборов - по обычным ценам.
+	OrderPrice Money `json:"orderPrice"`
+	// Скидка за наборы: разница между ценами товаров набора и ценой набора.
+	ComboDiscount Money `json:"comboDiscount,omitempty"`
+	// Расстояние от магазина до адреса в км.
+	DeliveryDistance float64 `json:"deliveryDistance"`
+	// Сколько минут займет доставка.
+	DeliveryTime  int    `json:"deliveryTime"`
+	DeliveryPrice Money  `json:"deliveryPrice"`
+	PromoCode     string `json:"promoCode,omitempty"`
+	// Скидка по промокоду.
+	Discount Money `json:"discount"`
+	// Выбранные опции заказа и сумма доплат за них.
+	Extras      []OrderExtra `json:"extras,omitempty"`
+	ExtrasPrice Money        `json:"extrasPrice,omitempty"`
+	Tip         Money        `json:"tip,omitempty"`
+	TotalPrice  Money        `json:"totalPrice"`
+	// Сколько баллов будет начислено за заказ.
+	LoyaltyPoints int `json:"loyaltyPoints"`
+	// Сумма на рублевых картах кошелька, только ими оплачивается заказ.
+	WalletBalance Money `json:"walletBalance"`
+	// Хватает ли денег в кошельке. Для оплаты картой и наличными всегда true.
+	SufficientFunds bool `json:"sufficientFunds"`
+	// Фиксация цен: если передать ее в заказ, он будет отклонен при изменении цен после расчета.
+	PriceLockID        string    `json:"priceLockId"`
+	PriceLockExpiresAt time.Time `json:"priceLockExpiresAt"`
 }
 
 type OrderRequest struct {
 	PaymentMethod string `json:"paymentMethod"`
 	// Id выбранного адерса.
 	AddressID string `json:"addressid"`
+	// Фиксация цен из предварительного расчета заказа, необязательно.
+	PriceLockID string `json:"priceLockId,omitempty"`
+	// Приборы и доставка до двери, необязательно.
+	Options OrderOptions `json:"options"`
+	// Промокод на скидку, необязательно.
+	PromoCode string `json:"promoCode,omitempty"`
+	// Чаевые курьеру, необязательно.
+	Tip Money `json:"tip,omitempty"`
+	// PIN кошелька при оплате кошельком от порога. Можно передать в заголовке X-Wallet-PIN.
+	PIN string `json:"pin,omitempty"`
 }
 
 // Wallet models
@@ -191,22 +1048,101 @@ const (
 type Account struct {
 	ID      string      `json:"id"`
 	Type    AccountType `json:"type"`
-	Balance int         `json:"balance"` // Баланс в рублях
+	Balance Money       `json:"balance"`
+	// Валюта счета, у счетов из старых данных - рубли.
+	Currency Currency `json:"currency"`
+
+	// Оформление карточки счета, выбранное пользователем. Пустые поля клиент показывает по умолчанию.
+	Name  string      `json:"name,omitempty"`
+	Color string      `json:"color,omitempty"` // #RRGGBB
+	Icon  AccountIcon `json:"icon,omitempty"`
+}
+
+type AccountIcon string
+
+const (
+	AccountIconCard   AccountIcon = "card"
+	AccountIconPiggy  AccountIcon = "piggy-bank"
+	AccountIconWallet AccountIcon = "wallet"
+	AccountIconStar   AccountIcon = "star"
+	AccountIconTravel AccountIcon = "travel"
+	AccountIconFood   AccountIcon = "food"
+	AccountIconGift   AccountIcon = "gift"
+)
+
+// AccountIcons иконки, которые можно выбрать для счета.
+var AccountIcons = []AccountIcon{
+	AccountIconCard, AccountIconPiggy, AccountIconWallet, AccountIconStar, AccountIconTravel, AccountIconFood, AccountIconGift,
+}
+
+const MaxAccountNameLength = 32
+
+// AccountPatch изменение оформления счета: поля, которых нет в запросе, не меняются, пустая строка сбрасывает поле.
+type AccountPatch struct {
+	Name  *string      `json:"name,omitempty"`
+	Color *string      `json:"color,omitempty"`
+	Icon  *AccountIcon `json:"icon,omitempty"`
+}
+
+// OpenAccountRequest открытие счета в одной из разрешенных валют. По умолчанию - рублевая карта.
+type OpenAccountRequest struct {
+	Type     AccountType `json:"type,omitempty"`
+	Currency Currency    `json:"currency,omitempty"`
 }
 
 type Wallet struct {
 	Accounts []Account `json:"accounts"`
 }
 
+type TransactionCategory string
+
+const (
+	TransactionCategoryFood     TransactionCategory = "food"
+	TransactionCategoryTransfer TransactionCategory = "transfer"
+	TransactionCategoryTopup    TransactionCategory = "topup"
+	TransactionCategoryRefund   TransactionCategory = "refund"
+	TransactionCategoryOther    TransactionCategory = "other"
+)
+
 type Transaction struct {
-	Amount int       `json:"amount"` // Сумма в рублях (отрицательная для трат, положительная для доходов)
-	Title  string    `json:"title"`
-	Time   time.Time `json:"time"`
-	Icon   string    `json:"icon"`
+	// Идентификатор операции, есть у оплат заказов.
+	ID       string              `json:"id,omitempty"`
+	Amount   Money               `json:"amount"` // Отрицательная для трат, положительная для доходов
+	Currency Currency            `json:"currency"`
+	Title    string              `json:"title"`
+	Time     time.Time           `json:"time"`
+	Icon     string              `json:"icon"`
+	Category TransactionCategory `json:"category,omitempty"`
+
+	// Ссылки на связанные объекты, чтобы клиент мог перейти от транзакции к заказу или контакту.
+	OrderID            string `json:"orderId,omitempty"`
+	TransferID         string `json:"transferId,omitempty"`
+	CounterpartyUserID string `json:"counterpartyUserId,omitempty"`
+	// Платеж внешнего провайдера, которым пополнен счет.
+	PaymentID string `json:"paymentId,omitempty"`
+	// Курс, по которому выполнен перевод между счетами в разных валютах.
+	Exchange *ExchangeRate `json:"exchange,omitempty"`
 }
 
 type TransactionsByDate map[string][]Transaction
 
+// IconKind вид транзакции, для которого в каталоге задана иконка.
+type IconKind string
+
+const (
+	IconTopup       IconKind = "topup"
+	IconTransferIn  IconKind = "transfer-in"
+	IconTransferOut IconKind = "transfer-out"
+	IconOrder       IconKind = "order"
+	IconRefund      IconKind = "refund"
+	IconOther       IconKind = "other"
+)
+
+// IconRequest задает иконку: имя файла на хосте загрузок или полный URL.
+type IconRequest struct {
+	Icon string `json:"icon"`
+}
+
 type TransactionsResponse struct {
 	CurrentPage int                `json:"currentPage"`
 	TotalPages  int                `json:"totalPages"`
@@ -215,27 +1151,910 @@ type TransactionsResponse struct {
 
 type TopupRequest struct {
 	AccountID string `json:"accountId"`
-	Amount    int    `json:"amount"` // Сумма пополнения в рублях (максимум 1000 рублей в сутки)
+	Amount    Money  `json:"amount"` // Сумма пополнения, максимум 1000 рублей в сутки
 }
 
 type TopupResponse struct {
-	Balance int `json:"balance"` // Новый баланс в рублях
+	Balance Money `json:"balance"` // Новый баланс
+}
+
+type ScheduledTopupInterval string
+
+const (
+	ScheduledTopupDaily  ScheduledTopupInterval = "daily"
+	ScheduledTopupWeekly ScheduledTopupInterval = "weekly"
+)
+
+type ScheduledTopupStatus string
+
+const (
+	ScheduledTopupActive    ScheduledTopupStatus = "active"
+	ScheduledTopupCancelled ScheduledTopupStatus = "cancelled"
+)
+
+// ScheduledTopup регулярное пополнение счета. Выполняется как обычное пополнение,
+// с дневным лимитом и проверкой антифрода.
+type ScheduledTopup struct {
+	ID        string                 `json:"id"`
+	AccountID string                 `json:"accountId"`
+	Amount    Money                  `json:"amount"`
+	Currency  Currency               `json:"currency"` // Валюта счета
+	Interval  ScheduledTopupInterval `json:"interval"`
+	Status    ScheduledTopupStatus   `json:"status"`
+	NextRunAt time.Time              `json:"nextRunAt"`
+	CreatedAt time.Time              `json:"createdAt"`
+
+	LastRunAt time.Time `json:"lastRunAt,omitzero"`
+	// Ошибка последней попытки, пустая после успешного пополнения.
+	LastError string `json:"lastError,omitempty"`
+}
+
+type ScheduledTopupRequest struct {
+	AccountID string                 `json:"accountId"`
+	Amount    Money                  `json:"amount"`
+	Interval  ScheduledTopupInterval `json:"interval"`
+}
+
+type PaymentStatus string
+
+const (
+	PaymentStatusPending   PaymentStatus = "pending"
+	PaymentStatusSucceeded PaymentStatus = "succeeded"
+	PaymentStatusFailed    PaymentStatus = "failed"
+)
+
+// Payment пополнение кошелька через внешнего платежного провайдера. Счет пополняется,
+// когда провайдер сообщит об успешной оплате.
+type Payment struct {
+	ID        string        `json:"id"`
+	AccountID string        `json:"accountId"`
+	Amount    Money         `json:"amount"`
+	Status    PaymentStatus `json:"status"`
+	// Страница оплаты провайдера, клиент открывает ее в браузере.
+	PaymentURL  string    `json:"paymentUrl"`
+	CreatedAt   time.Time `json:"createdAt"`
+	CompletedAt time.Time `json:"completedAt,omitzero"`
+}
+
+// PaymentCallback уведомление провайдера о результате оплаты.
+type PaymentCallback struct {
+	PaymentID string        `json:"paymentId"`
+	Status    PaymentStatus `json:"status"`
 }
 
 type TransferRequest struct {
 	FromAccountID string `json:"fromAccountId"`
 	ToPhoneNumber string `json:"toPhoneNumber"`
-	Amount        int    `json:"amount"` // Сумма перевода в рублях
+	// Получатель из GET /wallet/contacts, если номер телефона не указан.
+	ToContactID string `json:"toContactId,omitempty"`
+	Amount      Money  `json:"amount"` // Сумма перевода
+	// PIN кошелька, если сумма от порога. Можно передать в заголовке X-Wallet-PIN.
+	PIN string `json:"pin,omitempty"`
 }
 
 type TransferResponse struct {
-	Balance int `json:"balance"` // Новый баланс отправителя в рублях
+	Balance    Money    `json:"balance"` // Новый баланс отправителя
+	Currency   Currency `json:"currency"`
+	TransferID string   `json:"transferId"`
+	// Сколько зачислено получателю в валюте его счета.
+	CreditedAmount   Money    `json:"creditedAmount"`
+	CreditedCurrency Currency `json:"creditedCurrency"`
+	// Курс конвертации, только если валюты счетов различаются.
+	Exchange *ExchangeRate `json:"exchange,omitempty"`
+}
+
+// WalletPINHeader заголовок с PIN кошелька для переводов и оплат, если его нет в теле запроса.
+const WalletPINHeader = "X-Wallet-PIN"
+
+// Длина PIN кошелька и кода для его сброса.
+const (
+	WalletPINMinLength    = 4
+	WalletPINMaxLength    = 6
+	WalletPINResetCodeLen = 6
+)
+
+// WalletPINRequest установка PIN кошелька. Чтобы сменить PIN, нужен текущий.
+type WalletPINRequest struct {
+	PIN        string `json:"pin"`
+	CurrentPIN string `json:"currentPin,omitempty"`
+}
+
+// WalletPINStatus установлен ли PIN и можно ли сейчас подтверждать им операции.
+type WalletPINStatus struct {
+	Enabled bool `json:"enabled"`
+	// Переводы и оплаты кошельком от этой суммы в рублях требуют PIN.
+	Threshold    Money `json:"threshold"`
+	AttemptsLeft int   `json:"attemptsLeft"`
+	// До какого времени подтверждение заблокировано после ошибок.
+	LockedUntil *time.Time `json:"lockedUntil,omitempty"`
+}
+
+// WalletPINResetResponse код для сброса PIN отправлен в уведомления.
+type WalletPINResetResponse struct {
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// WalletPINResetConfirmRequest новый PIN по коду из уведомления.
+type WalletPINResetConfirmRequest struct {
+	Code string `json:"code"`
+	PIN  string `json:"pin"`
+}
+
+// WalletPINState PIN кошелька пользователя для хранения. Сам PIN не хранится, только bcrypt-хеш.
+type WalletPINState struct {
+	Hash           string     `json:"hash"`
+	FailedAttempts int        `json:"failedAttempts,omitempty"`
+	LockedUntil    *time.Time `json:"lockedUntil,omitempty"`
+}
+
+type AnalyticsPeriod string
+
+const (
+	AnalyticsPeriodWeek  AnalyticsPeriod = "week"
+	AnalyticsPeriodMonth AnalyticsPeriod = "month"
+)
+
+// WalletAnalytics сводка доходов и трат за текущий календарный период в сравнении с предыдущим.
+type WalletAnalytics struct {
+	Period AnalyticsPeriod `json:"period"`
+	From   string          `json:"from"`
+	To     string          `json:"to"`
+	// Траты считаются положительными числами. Суммы по счетам в других валютах переведены в рубли.
+	Income     Money                    `json:"income"`
+	Expenses   Money                    `json:"expenses"`
+	Categories []CategoryAnalytics      `json:"categories"`
+	Daily      []DailyAnalytics         `json:"daily"`
+	Previous   PreviousPeriodComparison `json:"previous"`
+}
+
+type CategoryAnalytics struct {
+	Category TransactionCategory `json:"category"`
+	Income   Money               `json:"income"`
+	Expenses Money               `json:"expenses"`
+	Count    int                 `json:"count"`
+}
+
+type DailyAnalytics struct {
+	Date     string `json:"date"`
+	Income   Money  `json:"income"`
+	Expenses Money  `json:"expenses"`
+}
+
+type PreviousPeriodComparison struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Income   Money  `json:"income"`
+	Expenses Money  `json:"expenses"`
+	// Изменение трат в процентах, null если в предыдущем периоде трат не было.
+	ExpensesChange *float64 `json:"expensesChange"`
+}
+
+type ChaosFault string
+
+const (
+	ChaosFaultDelay         ChaosFault = "delay"
+	ChaosFaultError         ChaosFault = "error"
+	ChaosFaultMalformedJSON ChaosFault = "malformed_json"
+	ChaosFaultReset         ChaosFault = "reset"
+)
+
+// ChaosRule правило внедрения сбоев для учебных целей.
+type ChaosRule struct {
+	ID string `json:"id"`
+	// Пустое значение - правило действует на всех пользователей.
+	UserID string `json:"userId,omitempty"`
+	// Маршрут в формате ServeMux, например "GET /cart". Пустое значение - все маршруты.
+	Route       string     `json:"route,omitempty"`
+	Fault       ChaosFault `json:"fault"`
+	Probability float64    `json:"probability"`
+	// Задержка для сбоя delay.
+	DelayMs int `json:"delayMs,omitempty"`
+}
+
+type ExportFormat string
+
+const (
+	ExportFormatJSON ExportFormat = "json"
+	ExportFormatCSV  ExportFormat = "csv"
+)
+
+type ExportRequest struct {
+	Entities []string
+	Format   ExportFormat
+}
+
+// OrderExportRequest период и формат выгрузки заказов для бухгалтерии: заказы, созданные в [From, To).
+// Нулевой From - с самого начала.
+type OrderExportRequest struct {
+	From   time.Time
+	To     time.Time
+	Format ExportFormat
+}
+
+// AccountingOrder заказ в выгрузке для бухгалтерии
+type AccountingOrder struct {
+	UserID    string      `json:"userId"`
+	OrderID   string      `json:"orderId"`
+	Status    OrderStatus `json:"status"`
+	CreatedAt time.Time   `json:"createdAt"`
+	// Пусто у заказов, созданных до появления способа оплаты.
+	PaymentMethod PaymentMethod `json:"paymentMethod,omitempty"`
+	OrderPrice    Money         `json:"orderPrice"`
+	// Сумма всех скидок заказа.
+	Discount      Money       `json:"discount"`
+	DeliveryPrice Money       `json:"deliveryPrice"`
+	ExtrasPrice   Money       `json:"extrasPrice"`
+	Tip           Money       `json:"tip"`
+	TotalPrice    Money       `json:"totalPrice"`
+	Refunded      Money       `json:"refunded"`
+	Items         []OrderItem `json:"items"`
 }
 
 // WalletData структура для хранения и загрузки данных кошелька
 type WalletData struct {
 	Accounts     map[string]map[string]*Account `json:"accounts"`
 	Transactions map[string][]Transaction       `json:"transactions"`
-	DailyTopups  map[string]map[string]int      `json:"daily_topups"`
+	DailyTopups  map[string]map[string]Money    `json:"daily_topups"`
 	UserPhones   map[string]string              `json:"user_phones"`
+	// Сохраненные контакты для переводов: userID -> userID получателя -> контакт.
+	Contacts map[string]map[string]SavedWalletContact `json:"contacts,omitempty"`
+}
+
+// Users пользователи, у которых есть хоть какие-то данные кошелька, по порядку
+func (d WalletData) Users() []string {
+	users := slices.Concat(
+		slices.Collect(maps.Keys(d.Accounts)),
+		slices.Collect(maps.Keys(d.Transactions)),
+		slices.Collect(maps.Keys(d.DailyTopups)),
+		slices.Collect(maps.Keys(d.UserPhones)),
+		slices.Collect(maps.Keys(d.Contacts)),
+	)
+
+	slices.Sort(users)
+
+	return slices.Compact(users)
+}
+
+// UserWallet кошелек одного пользователя, в таком виде он хранится в репозитории кошельков
+type UserWallet struct {
+	Accounts     map[string]*Account           `json:"accounts"`
+	Transactions []Transaction                 `json:"transactions"`
+	DailyTopups  map[string]Money              `json:"daily_topups,omitempty"`
+	Phone        string                        `json:"phone,omitempty"`
+	Contacts     map[string]SavedWalletContact `json:"contacts,omitempty"`
+}
+
+// SavedWalletContact получатель, которого пользователь сохранил под своим именем
+type SavedWalletContact struct {
+	Name    string    `json:"name"`
+	SavedAt time.Time `json:"savedAt"`
+}
+
+// WalletContact получатель для экрана перевода: сохраненный пользователем или недавний.
+type WalletContact struct {
+	// Идентификатор для toContactId перевода, совпадает с counterpartyUserId в транзакциях.
+	ID string `json:"id"`
+	// Телефон получателя без средних цифр: 79*****4567.
+	Phone string `json:"phone"`
+	// Имя, под которым пользователь сохранил контакт.
+	Name     string `json:"name,omitempty"`
+	Favorite bool   `json:"favorite"`
+	// Последний перевод этому получателю, нет - переводов еще не было.
+	LastTransferAt *time.Time `json:"lastTransferAt,omitempty"`
+}
+
+type SaveWalletContactRequest struct {
+	Phone string `json:"phone"`
+	Name  string `json:"name"`
+}
+
+// CatalogChange что изменило каталог и создало новую версию.
+type CatalogChange string
+
+const (
+	CatalogChangeInitial      CatalogChange = "initial"
+	CatalogChangeAvailability CatalogChange = "availability"
+	CatalogChangeReview       CatalogChange = "review"
+	CatalogChangeRestore      CatalogChange = "restore"
+	CatalogChangeRollback     CatalogChange = "rollback"
+)
+
+// CatalogVersion версия каталога, на которую можно откатиться.
+type CatalogVersion struct {
+	Version   int           `json:"version"`
+	CreatedAt time.Time     `json:"createdAt"`
+	Change    CatalogChange `json:"change"`
+	// Товар, который изменился, для availability и review
+	ProductID string `json:"productId,omitempty"`
+	// Версия, на которую откатили каталог, для rollback
+	RolledBackTo int  `json:"rolledBackTo,omitempty"`
+	ProductCount int  `json:"productCount"`
+	Current      bool `json:"current"`
+}
+
+// CatalogChanges товары и категории, изменившиеся после since, чтобы клиент обновил локальный кеш каталога
+// вместо повторной загрузки всех страниц.
+type CatalogChanges struct {
+	// Текущая версия каталога
+	Version int `json:"version"`
+	// Время последнего изменения каталога, его передают в since при следующем запросе
+	UpdatedAt time.Time `json:"updatedAt"`
+	// Добавленные и измененные товары
+	Products []string `json:"products"`
+	// Категории, в которых появились или пропали товары
+	Categories []string `json:"categories"`
+	// Товары, которых больше нет в каталоге
+	Deleted []string `json:"deleted"`
+}
+
+// AvailabilityRequest тело запроса на изменение наличия товара.
+type AvailabilityRequest struct {
+	Available bool `json:"available"`
+}
+
+const (
+	NotificationProductAvailable    = "product_available"
+	NotificationSubscriptionCharged = "subscription_charged"
+	NotificationSubscriptionFailed  = "subscription_failed"
+	NotificationOrderRefunded       = "order_refunded"
+	NotificationOrderDelayed        = "order_delayed"
+	NotificationTopupExecuted       = "scheduled_topup_executed"
+	NotificationTopupFailed         = "scheduled_topup_failed"
+	NotificationWalletPINReset      = "wallet_pin_reset"
+)
+
+// Notification уведомление внутри приложения.
+type Notification struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	Text      string    `json:"text"`
+	ProductID string    `json:"productId,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	OrderID        string `json:"orderId,omitempty"`
+	SubscriptionID string `json:"subscriptionId,omitempty"`
+	TopupID        string `json:"topupId,omitempty"`
+}
+
+// NotificationsData структура для хранения и загрузки уведомлений и листа ожидания товаров.
+type NotificationsData struct {
+	// Товар -> пользователи, которые ждут его появления в наличии.
+	Waitlist      map[string][]string       `json:"waitlist"`
+	Notifications map[string][]Notification `json:"notifications"`
+}
+
+type FraudRule string
+
+const (
+	FraudRuleTransfersPerHour        FraudRule = "transfers_per_hour"
+	FraudRuleTopupsPerHour           FraudRule = "topups_per_hour"
+	FraudRuleCounterpartyDailyAmount FraudRule = "counterparty_daily_amount"
+	FraudRuleNewRecipientCooldown    FraudRule = "new_recipient_cooldown"
+)
+
+type WalletOperationType string
+
+const (
+	WalletOperationTopup    WalletOperationType = "topup"
+	WalletOperationTransfer WalletOperationType = "transfer"
+)
+
+// WalletOperation операция кошелька, которую проверяют правила антифрода.
+type WalletOperation struct {
+	Type      WalletOperationType `json:"type"`
+	UserID    string              `json:"userId"`
+	AccountID string              `json:"accountId"`
+	// Сумма в рублях, для счетов в другой валюте - по курсу на момент операции.
+	Amount Money `json:"amount"`
+	// Для переводов: получатель и его номер.
+	CounterpartyUserID string `json:"counterpartyUserId,omitempty"`
+	ToPhone            string `json:"toPhone,omitempty"`
+}
+
+type BlockedOperationStatus string
+
+const (
+	// Операция заблокирована и ждет решения.
+	BlockedOperationPending BlockedOperationStatus = "blocked"
+	// Преподаватель разрешил операцию, повтор пройдет без проверки правил.
+	BlockedOperationApproved BlockedOperationStatus = "approved"
+	// Разрешенная операция повторена и выполнена.
+	BlockedOperationCompleted BlockedOperationStatus = "completed"
+)
+
+// BlockedOperation операция кошелька, остановленная правилом антифрода.
+type BlockedOperation struct {
+	ID        string                 `json:"id"`
+	Operation WalletOperation        `json:"operation"`
+	Rule      FraudRule              `json:"rule"`
+	Status    BlockedOperationStatus `json:"status"`
+	CreatedAt time.Time              `json:"createdAt"`
+}
+
+// ShoppingList именованный список товаров пользователя, например план питания на неделю.
+type ShoppingList struct {
+	ID        string             `json:"id"`
+	Name      string             `json:"name"`
+	Items     []ShoppingListItem `json:"items"`
+	CreatedAt time.Time          `json:"createdAt"`
+	UpdatedAt time.Time          `json:"updatedAt"`
+}
+
+type ShoppingListItem struct {
+	ProductID string `json:"productId"`
+	Quantity  int    `json:"quantity"`
+}
+
+// ShoppingListRequest тело запроса на создание и изменение списка.
+type ShoppingListRequest struct {
+	Name  string             `json:"name"`
+	Items []ShoppingListItem `json:"items"`
+}
+
+const (
+	SkipReasonUnavailable = "unavailable"
+	SkipReasonNotFound    = "not_found"
+)
+
+// SkippedItem позиция списка, которая не попала в корзину.
+type SkippedItem struct {
+	ProductID string `json:"productId"`
+	Quantity  int    `json:"quantity"`
+	// unavailable - товара нет в наличии, not_found - товар удален из каталога.
+	Reason string `json:"reason"`
+}
+
+// ListToCartResult результат переноса списка в корзину.
+type ListToCartResult struct {
+	Added   []ShoppingListItem `json:"added"`
+	Skipped []SkippedItem      `json:"skipped"`
+}
+
+type SubscriptionInterval string
+
+const (
+	SubscriptionWeekly  SubscriptionInterval = "weekly"
+	SubscriptionMonthly SubscriptionInterval = "monthly"
+)
+
+type SubscriptionStatus string
+
+const (
+	SubscriptionActive    SubscriptionStatus = "active"
+	SubscriptionPaused    SubscriptionStatus = "paused"
+	SubscriptionCancelled SubscriptionStatus = "cancelled"
+)
+
+// Subscription повторяющийся заказ, созданный из прошлого заказа пользователя.
+type Subscription struct {
+	ID            string               `json:"id"`
+	SourceOrderID string               `json:"sourceOrderId"`
+	Interval      SubscriptionInterval `json:"interval"`
+	Status        SubscriptionStatus   `json:"status"`
+	Address       Address              `json:"address"`
+	// Товары и количество из исходного заказа. Цены и наличие берутся из каталога в момент заказа.
+	Items     []CartItem `json:"items"`
+	NextRunAt time.Time  `json:"nextRunAt"`
+	CreatedAt time.Time  `json:"createdAt"`
+
+	LastOrderID string `json:"lastOrderId,omitempty"`
+	// Ошибка последней попытки и число неудачных попыток подряд.
+	LastError      string `json:"lastError,omitempty"`
+	FailedAttempts int    `json:"failedAttempts"`
+}
+
+type SubscriptionRequest struct {
+	OrderID  string               `json:"orderId"`
+	Interval SubscriptionInterval `json:"interval"`
+}
+
+// PresignedUpload подписанная ссылка для загрузки файла напрямую, без токена авторизации.
+type PresignedUpload struct {
+	File      string    `json:"file"`
+	UploadURL string    `json:"uploadUrl"`
+	Method    string    `json:"method"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// SignedDownload подписанная ссылка на скачивание закрытого файла.
+type SignedDownload struct {
+	File        string    `json:"file"`
+	DownloadURL string    `json:"downloadUrl"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+}
+
+// UploadedFile файл, загруженный пользователем через /uploads или по подписанной ссылке.
+type UploadedFile struct {
+	Name       string    `json:"name"`
+	URL        string    `json:"url"`
+	UploadedAt time.Time `json:"uploadedAt"`
+}
+
+// WalletExport счета и все транзакции пользователя для выгрузки аккаунта.
+type WalletExport struct {
+	Accounts     []Account       `json:"accounts"`
+	Transactions []Transaction   `json:"transactions"`
+	Contacts     []WalletContact `json:"contacts"`
+}
+
+// AccountExport все данные пользователя: имя раздела -> данные сервиса.
+type AccountExport struct {
+	UserID     string         `json:"userId"`
+	Nickname   string         `json:"nickname"`
+	ExportedAt time.Time      `json:"exportedAt"`
+	Data       map[string]any `json:"data,omitempty"`
+}
+
+// LogLevels текущие уровни логирования. Modules содержит уровень каждого модуля с учетом переопределений.
+type LogLevels struct {
+	Level     string            `json:"level"`
+	Modules   map[string]string `json:"modules"`
+	Overrides map[string]string `json:"overrides"`
+}
+
+// LogLevelRequest меняет общий уровень и переопределения модулей.
+// Пустое значение уровня модуля убирает переопределение, модуль снова следует общему уровню.
+type LogLevelRequest struct {
+	Level   string            `json:"level,omitempty"`
+	Modules map[string]string `json:"modules,omitempty"`
+}
+
+// DefaultStatsDays за сколько дней по умолчанию возвращается статистика по дням.
+const DefaultStatsDays = 7
+
+// Stats сводная статистика для преподавателя. Считается по событиям сервисов с момента запуска,
+// заказы и корзины из файлов данных учитываются при старте.
+type Stats struct {
+	Since        time.Time `json:"since"`
+	Orders       int       `json:"orders"`
+	Revenue      Money     `json:"revenue"`
+	AverageOrder Money     `json:"averageOrder"`
+	// Статистика по дням за последние days дней, включая сегодняшний, по возрастанию даты.
+	Days        []DailyStats   `json:"days"`
+	TopProducts []ProductStats `json:"topProducts"`
+	// Пользователи, которые делали заказы, меняли корзину или пользовались кошельком.
+	ActiveUsers ActiveUsersStats `json:"activeUsers"`
+	// Среднее число единиц товара в непустой корзине.
+	AverageCartSize float64 `json:"averageCartSize"`
+	Carts           int     `json:"carts"`
+	// Оборот кошелька по категориям операций: сумма пополнений, переводов и оплат заказов.
+	WalletVolume map[TransactionCategory]Money `json:"walletVolume"`
+}
+
+type DailyStats struct {
+	Date        string `json:"date"`
+	Orders      int    `json:"orders"`
+	Revenue     Money  `json:"revenue"`
+	ActiveUsers int    `json:"activeUsers"`
+}
+
+type ProductStats struct {
+	ProductID string `json:"productId"`
+	Name      string `json:"name"`
+	Quantity  int    `json:"quantity"`
+	Revenue   Money  `json:"revenue"`
+}
+
+type ActiveUsersStats struct {
+	Day  int `json:"day"`
+	Week int `json:"week"`
+}
+
+// BackupStatus результат последнего бэкапа одного сервиса.
+type BackupStatus struct {
+	Name        string    `json:"name"`
+	LastAttempt time.Time `json:"lastAttempt"`
+	LastSuccess time.Time `json:"lastSuccess,omitzero"`
+	// Файл последнего успешного бэкапа.
+	File  string `json:"file,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// BackupFailure бэкап, который не удалось сделать после всех повторов.
+type BackupFailure struct {
+	FailedAt time.Time `json:"failedAt"`
+	Attempts int       `json:"attempts"`
+	// Объекты, которые не удалось сохранить в последней попытке. Пусто, если не удалось создать сам снимок.
+	Objects []string `json:"objects,omitempty"`
+	Error   string   `json:"error"`
+}
+
+// BackupHealth состояние бэкапов. Degraded, пока после провала всех повторов не пройдет успешный бэкап.
+type BackupHealth struct {
+	Degraded    bool           `json:"degraded"`
+	LastSuccess time.Time      `json:"lastSuccess,omitzero"`
+	LastFailure *BackupFailure `json:"lastFailure,omitempty"`
+}
+
+// BackupSnapshot набор файлов бэкапа, сделанных за один запуск.
+type BackupSnapshot struct {
+	// ID в формате 2006-01-02_15-04-05, передается в /admin/restore.
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	// Суммарный размер файлов в байтах.
+	Size    int64    `json:"size"`
+	Objects []string `json:"objects"`
+}
+
+// RestoreResult какие сервисы загружены из бэкапа, а каких в нем не было.
+type RestoreResult struct {
+	Snapshot string   `json:"snapshot"`
+	Restored []string `json:"restored"`
+	Skipped  []string `json:"skipped"`
+}
+
+// RuntimeDiagnostics состояние процесса: горутины, куча и размеры коллекций в памяти.
+type RuntimeDiagnostics struct {
+	Goroutines  int            `json:"goroutines"`
+	Heap        HeapStats      `json:"heap"`
+	Collections map[string]int `json:"collections"`
+}
+
+// HeapStats выборка из runtime.MemStats, размеры в байтах.
+type HeapStats struct {
+	Alloc        uint64 `json:"alloc"`
+	Sys          uint64 `json:"sys"`
+	Idle         uint64 `json:"idle"`
+	Released     uint64 `json:"released"`
+	Objects      uint64 `json:"objects"`
+	TotalAlloc   uint64 `json:"totalAlloc"`
+	NumGC        uint32 `json:"numGc"`
+	PauseTotalNs uint64 `json:"pauseTotalNs"`
+}
+
+// Webhook адрес интегратора, на который отправляются события. Секрет виден только при создании.
+type Webhook struct {
+	ID     string `json:"id"`
+	URL    string `json:"url"`
+	Secret string `json:"secret,omitempty"`
+	// Имена событий, "*" - все события.
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// WebhookRequest тело запроса на регистрацию вебхука. Без секрета он генерируется.
+type WebhookRequest struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"`
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliveryDelivered WebhookDeliveryStatus = "delivered"
+	WebhookDeliveryFailed    WebhookDeliveryStatus = "failed"
+)
+
+// WebhookDelivery отправка события на вебхук со всеми попытками.
+type WebhookDelivery struct {
+	ID        string                `json:"id"`
+	WebhookID string                `json:"webhookId"`
+	Event     string                `json:"event"`
+	Status    WebhookDeliveryStatus `json:"status"`
+	Attempts  int                   `json:"attempts"`
+	// Код ответа и ошибка последней попытки.
+	ResponseStatus int        `json:"responseStatus,omitempty"`
+	Error          string     `json:"error,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	LastAttemptAt  *time.Time `json:"lastAttemptAt,omitempty"`
+	// Когда будет следующая попытка, только для pending.
+	NextAttemptAt *time.Time      `json:"nextAttemptAt,omitempty"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+// WebhookPayload тело запроса на вебхук.
+type WebhookPayload struct {
+	ID        string    `json:"id"`
+	Event     string    `json:"event"`
+	CreatedAt time.Time `json:"createdAt"`
+	Data      any       `json:"data"`
+}
+
+// RecordedExchange запрос студента и ответ сервера из записи сессии. Секреты в заголовках и телах заменены
+// на "***", длинные тела обрезаны.
+type RecordedExchange struct {
+	RequestID string    `json:"requestId"`
+	At        time.Time `json:"at"`
+	Method    string    `json:"method"`
+	// Маршрут, которым обработан запрос, например "GET /products/{id}".
+	Route          string            `json:"route"`
+	Path           string            `json:"path"`
+	Query          string            `json:"query,omitempty"`
+	RequestHeaders map[string]string `json:"requestHeaders,omitempty"`
+	RequestBody    string            `json:"requestBody,omitempty"`
+	Status         int               `json:"status"`
+	ResponseBody   string            `json:"responseBody,omitempty"`
+	DurationMs     int64             `json:"durationMs"`
+}
+
+// RecordingSession записанные запросы студента, старые первыми.
+type RecordingSession struct {
+	UserID    string             `json:"userId"`
+	Nickname  string             `json:"nickname"`
+	Exchanges []RecordedExchange `json:"exchanges"`
+}
+
+type RecordingSummary struct {
+	UserID   string    `json:"userId"`
+	Nickname string    `json:"nickname"`
+	Count    int       `json:"count"`
+	LastAt   time.Time `json:"lastAt"`
+}
+
+// UserSuspension блокировка пользователя преподавателем. Пока она действует, токены пользователя
+// отклоняются с 403.
+type UserSuspension struct {
+	UserID string `json:"userId"`
+	// Причина показывается пользователю в ответе на любой запрос.
+	Reason    string    `json:"reason"`
+	BlockedBy string    `json:"blockedBy"`
+	BlockedAt time.Time `json:"blockedAt"`
+}
+
+// BlockUserRequest тело запроса на блокировку пользователя.
+type BlockUserRequest struct {
+	Reason string `json:"reason"`
+}
+
+// Experiment A/B эксперимент. Пользователь попадает в вариант по хешу своего ID и ключа эксперимента,
+// поэтому вариант не меняется между запросами, пока не изменится список вариантов.
+type Experiment struct {
+	Key         string              `json:"key"`
+	Description string              `json:"description,omitempty"`
+	Variants    []ExperimentVariant `json:"variants"`
+	// Выключенный эксперимент никому не назначается.
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// ExperimentVariant вариант эксперимента. Weight - доля пользователей относительно других вариантов.
+type ExperimentVariant struct {
+	Name   string `json:"name"`
+	Weight int    `json:"weight"`
+}
+
+// ExperimentRequest тело запроса на создание или замену эксперимента.
+type ExperimentRequest struct {
+	Description string              `json:"description"`
+	Variants    []ExperimentVariant `json:"variants"`
+	Active      bool                `json:"active"`
+}
+
+// ExperimentAssignment вариант активного эксперимента, в который попал пользователь.
+type ExperimentAssignment struct {
+	Experiment string `json:"experiment"`
+	Variant    string `json:"variant"`
+}
+
+const (
+	// ExperimentControl вариант без изменений, с ним сравниваются остальные.
+	ExperimentControl = "control"
+	// ExperimentCatalogSort порядок каталога по умолчанию: имя варианта - значение sort для GET /products.
+	ExperimentCatalogSort = "catalog_sort"
+	// ExperimentsHeader заголовок ответа с вариантами пользователя: "catalog_sort=rating, fee_display=badge".
+	ExperimentsHeader = "X-Experiments"
+)
+
+// Ограничения экспериментов.
+const (
+	MaxExperiments        = 50
+	MaxExperimentVariants = 10
+	MaxExperimentKey      = 64
+)
+
+// DeliveryZone зона доставки с собственным ассортиментом.
+type DeliveryZone struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Многоугольник из точек [долгота, широта], как в адресах пользователей.
+	Polygon [][]float64 `json:"polygon"`
+	// Товары и категории, которые продаются только в этой зоне и других зонах, к которым они привязаны.
+	Products   []string  `json:"products"`
+	Categories []string  `json:"categories"`
+	UpdatedAt  time.Time `json:"updatedAt,omitempty"`
+}
+
+// ZoneAssortmentRequest замена ассортимента зоны.
+type ZoneAssortmentRequest struct {
+	Products   []string `json:"products"`
+	Categories []string `json:"categories"`
+}
+
+// Session токен, с которым пользователь заходил в приложение. Сессии одного пользователя - токены,
+// выданные на его ник одним преподавателем, например для телефона и для браузера.
+type Session struct {
+	// Идентификатор токена (jti), по нему сессию можно завершить.
+	ID string `json:"id"`
+	// Устройство, определенное по User-Agent, например "iOS, Safari".
+	Device      string    `json:"device"`
+	UserAgent   string    `json:"userAgent"`
+	FirstSeenAt time.Time `json:"firstSeenAt"`
+	LastSeenAt  time.Time `json:"lastSeenAt"`
+	// Сессия токена, с которым пришел запрос.
+	Current bool `json:"current"`
+}
+
+// MaxSessionsPerUser сколько сессий хранится для одного пользователя, самые давние вытесняются.
+const MaxSessionsPerUser = 20
+
+// SlowRequest запрос, обработка которого превысила порог SLOW_REQUESTS_THRESHOLD.
+type SlowRequest struct {
+	RequestID  string    `json:"requestId"`
+	Method     string    `json:"method"`
+	Route      string    `json:"route"`
+	Path       string    `json:"path"`
+	UserID     string    `json:"userId,omitempty"`
+	Nickname   string    `json:"nickname,omitempty"`
+	StatusCode int       `json:"statusCode"`
+	DurationMs float64   `json:"durationMs"`
+	At         time.Time `json:"at"`
+	// Время участков сервисов в миллисекундах, например "wallet.lock_wait".
+	Timings map[string]float64 `json:"timings,omitempty"`
+}
+
+// UsageCounters вызовы одного маршрута одним пользователем.
+type UsageCounters struct {
+	Requests int64 `json:"requests"`
+	// Ответы 4xx: ошибка в запросе приложения.
+	ClientErrors int64 `json:"clientErrors"`
+	// Ответы 5xx.
+	ServerErrors  int64     `json:"serverErrors"`
+	FirstCalledAt time.Time `json:"firstCalledAt"`
+	LastCalledAt  time.Time `json:"lastCalledAt"`
+}
+
+// UserUsageState вызовы API пользователя по маршрутам, в таком виде они хранятся в бэкапе.
+type UserUsageState struct {
+	Nickname string `json:"nickname,omitempty"`
+	// Маршрут вида "POST /cart/items" -> счетчики.
+	Endpoints map[string]UsageCounters `json:"endpoints"`
+}
+
+// EndpointUsage вызовы маршрута студентом.
+type EndpointUsage struct {
+	Route string `json:"route"`
+	UsageCounters
+	// Доля ответов без ошибок, от 0 до 1.
+	SuccessRate float64 `json:"successRate"`
+}
+
+// SectionUsage вызовы раздела API: первого сегмента пути, например cart, orders, wallet.
+type SectionUsage struct {
+	Section     string  `json:"section"`
+	Requests    int64   `json:"requests"`
+	Errors      int64   `json:"errors"`
+	SuccessRate float64 `json:"successRate"`
+	// Сколько разных маршрутов раздела вызывалось.
+	Endpoints int `json:"endpoints"`
+	// Сколько из них хотя бы раз ответили без ошибки.
+	SucceededEndpoints int       `json:"succeededEndpoints"`
+	LastCalledAt       time.Time `json:"lastCalledAt"`
+}
+
+// UserUsage использование API студентом, чтобы проверить, какие сценарии его приложение действительно вызывало.
+type UserUsage struct {
+	UserID        string    `json:"userId"`
+	Nickname      string    `json:"nickname,omitempty"`
+	Requests      int64     `json:"requests"`
+	Errors        int64     `json:"errors"`
+	SuccessRate   float64   `json:"successRate"`
+	FirstActivity time.Time `json:"firstActivity"`
+	LastActivity  time.Time `json:"lastActivity"`
+	// Разделы по алфавиту.
+	Sections []SectionUsage `json:"sections"`
+	// Маршруты по алфавиту.
+	Endpoints []EndpointUsage `json:"endpoints"`
+}
+
+// SlowRequestsReport медленные запросы с момента запуска или последней очистки.
+type SlowRequestsReport struct {
+	ThresholdMs float64 `json:"thresholdMs"`
+	Total       int64   `json:"total"`
+	// Число медленных запросов по маршрутам.
+	ByRoute map[string]int64 `json:"byRoute"`
+	// Самые долгие запросы, худшие первыми.
+	Worst []SlowRequest `json:"worst"`
 }
@@ -0,0 +1,173 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Currency код валюты ISO 4217.
+type Currency string
+
+// CurrencyRUB валюта каталога, заказов и кошелька.
+const CurrencyRUB Currency = "RUB"
+
+// Symbol знак валюты для писем и уведомлений, для неизвестных валют - код.
+func (c Currency) Symbol() string {
+	switch c {
+	case CurrencyRUB, "":
+		return "₽"
+	case "USD":
+		return "$"
+	case "EUR":
+		return "€"
+	default:
+		return string(c)
+	}
+}
+
+// ExchangeRate курс обмена: сколько единиц To дают за одну единицу From.
+type ExchangeRate struct {
+	From Currency `json:"from"`
+	To   Currency `json:"to"`
+	Rate float64  `json:"rate"`
+}
+
+// Convert переводит сумму из From в To с округлением до минимальной единицы.
+func (r ExchangeRate) Convert(amount Money) Money {
+	return Money(math.Round(float64(amount) * r.Rate))
+}
+
+// MinorUnits сколько минимальных единиц в одной единице валюты: копеек в рубле.
+const MinorUnits = 100
+
+var ErrMoneyPrecision = errors.New("amount has more than two decimal places")
+
+// Money денежная сумма в копейках. В JSON записывается числом в рублях с не более чем двумя знаками
+// после точки, поэтому старые файлы данных с целыми рублями читаются без изменений.
+type Money int64
+
+// Rubles сумма в целых рублях.
+func Rubles(rubles int) Money {
+	return Money(rubles) * MinorUnits
+}
+
+// Kopecks сумма в копейках.
+func Kopecks(kopecks int64) Money {
+	return Money(kopecks)
+}
+
+// Kopecks сумма в копейках.
+func (m Money) Kopecks() int64 {
+	return int64(m)
+}
+
+// Rubles целая часть суммы в рублях, копейки отбрасываются.
+func (m Money) Rubles() int {
+	return int(m / MinorUnits)
+}
+
+// Mul стоимость quantity единиц по цене m.
+func (m Money) Mul(quantity int) Money {
+	return m * Money(quantity)
+}
+
+// Percent percent процентов от суммы с округлением до копейки.
+func (m Money) Percent(percent int) Money {
+	return m.MulDiv(int64(percent), 100)
+}
+
+// MulDiv m * numerator / denominator с округлением до копейки: половина копейки округляется от нуля.
+func (m Money) MulDiv(numerator, denominator int64) Money {
+	product := int64(m) * numerator
+	half := denominator / 2
+	if (product < 0) != (denominator < 0) {
+		return Money((product - half) / denominator)
+	}
+
+	return Money((product + half) / denominator)
+}
+
+// Abs модуль суммы.
+func (m Money) Abs() Money {
+	if m < 0 {
+		return -m
+	}
+
+	return m
+}
+
+// String сумма в рублях: 100, 99.5, -0.05.
+func (m Money) String() string {
+	sign := ""
+	kopecks := int64(m)
+	if kopecks < 0 {
+		sign = "-"
+		kopecks = -kopecks
+	}
+
+	rubles, fraction := kopecks/MinorUnits, kopecks%MinorUnits
+	switch {
+	case fraction == 0:
+		return sign + strconv.FormatInt(rubles, 10)
+	case fraction%10 == 0:
+		return fmt.Sprintf("%s%d.%d", sign, rubles, fraction/10)
+	default:
+		return fmt.Sprintf("%s%d.%02d", sign, rubles, fraction)
+	}
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+
+	parsed, err := ParseMoney(string(data))
+	if err != nil {
+		return err
+	}
+
+	*m = parsed
+
+	return nil
+}
+
+// UnmarshalText разбирает сумму из переменных окружения.
+func (m *Money) UnmarshalText(text []byte) error {
+	parsed, err := ParseMoney(string(text))
+	if err != nil {
+		return err
+	}
+
+	*m = parsed
+
+	return nil
+}
+
+// ParseMoney разбирает сумму в рублях, например "99.50". Больше двух знаков после точки - ошибка,
+// а не округление, чтобы не терять копейки молча.
+func ParseMoney(value string) (Money, error) {
+	// big.Rat понимает и дроби вида 1/2, а сумма может быть только десятичной
+	rubles, ok := new(big.Rat).SetString(value)
+	if !ok || strings.Contains(value, "/") {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+
+	kopecks := rubles.Mul(rubles, big.NewRat(MinorUnits, 1))
+	if !kopecks.IsInt() {
+		return 0, fmt.Errorf("%w: %s", ErrMoneyPrecision, value)
+	}
+
+	if !kopecks.Num().IsInt64() {
+		return 0, fmt.Errorf("amount %s is out of range", value)
+	}
+
+	return Money(kopecks.Num().Int64()), nil
+}
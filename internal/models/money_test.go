@@ -0,0 +1,43 @@
+package models_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"eats-backend/internal/models"
+)
+
+func TestMoney_JSON(t *testing.T) {
+	raw, err := json.Marshal([]models.Money{models.Rubles(100), models.Kopecks(9950), models.Kopecks(-5), models.Kopecks(1001)})
+	require.NoError(t, err)
+	require.JSONEq(t, `[100, 99.5, -0.05, 10.01]`, string(raw))
+
+	// Целые рубли из старых файлов данных читаются как раньше
+	var account models.Account
+	require.NoError(t, json.Unmarshal([]byte(`{"balance": 3010}`), &account))
+	require.Equal(t, models.Rubles(3010), account.Balance)
+
+	var price models.Money
+	require.NoError(t, json.Unmarshal([]byte(`99.50`), &price))
+	require.Equal(t, models.Kopecks(9950), price)
+
+	require.ErrorIs(t, json.Unmarshal([]byte(`10.005`), &price), models.ErrMoneyPrecision)
+	require.Error(t, json.Unmarshal([]byte(`"10"`), &price))
+}
+
+func TestMoney_Arithmetic(t *testing.T) {
+	price := models.Kopecks(9950)
+
+	require.Equal(t, models.Kopecks(29850), price.Mul(3))
+	require.Equal(t, models.Kopecks(995), price.Percent(10))
+	// Половина копейки округляется от нуля
+	require.Equal(t, models.Kopecks(1), models.Kopecks(10).Percent(5))
+	require.Equal(t, models.Kopecks(-1), models.Kopecks(-10).Percent(5))
+	require.Equal(t, 99, price.Rubles())
+
+	// Цена до скидки 20%: 80 ₽ - это 100 ₽ без скидки
+	product := models.Product{Price: models.Rubles(80), Discount: 20}
+	require.Equal(t, models.Rubles(100), product.OriginalPrice())
+}
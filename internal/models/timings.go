@@ -0,0 +1,48 @@
+package models
+
+import (
+	"context"
+	"maps"
+	"sync"
+	"time"
+)
+
+// RequestTimings время, которое запрос провел в отдельных участках сервисов, например в ожидании блокировки.
+// Собирается для каждого запроса и попадает в лог медленных запросов.
+type RequestTimings struct {
+	durations map[string]time.Duration
+
+	mux sync.Mutex
+}
+
+type requestTimingsKey struct{}
+
+// ContextWithTimings добавляет в контекст сборщик времени участков запроса.
+func ContextWithTimings(ctx context.Context) (context.Context, *RequestTimings) {
+	timings := &RequestTimings{durations: make(map[string]time.Duration)}
+
+	return context.WithValue(ctx, requestTimingsKey{}, timings), timings
+}
+
+// ObserveTiming добавляет к участку name время с start. Без сборщика в контексте ничего не делает.
+func ObserveTiming(ctx context.Context, name string, start time.Time) {
+	timings, ok := ctx.Value(requestTimingsKey{}).(*RequestTimings)
+	if !ok {
+		return
+	}
+
+	elapsed := time.Since(start)
+
+	timings.mux.Lock()
+	defer timings.mux.Unlock()
+
+	timings.durations[name] += elapsed
+}
+
+// Durations возвращает копию собранных времен.
+func (t *RequestTimings) Durations() map[string]time.Duration {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	return maps.Clone(t.durations)
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"eats-backend/internal/models"
+)
+
+// UserDataExporter сервис, который отдает данные пользователя для выгрузки аккаунта
+type UserDataExporter interface {
+	// ExportUserData возвращает данные пользователя из контекста, ничего не создавая и не меняя
+	ExportUserData(ctx context.Context) (any, error)
+	// GetUserExportName возвращает имя раздела в выгрузке
+	GetUserExportName() string
+}
+
+// AccountExportService собирает выгрузку всех данных пользователя по запросу самого пользователя
+type AccountExportService struct {
+	logger    *zap.SugaredLogger
+	exporters []UserDataExporter
+
+	mu sync.RWMutex
+}
+
+func NewAccountExportService(logger *zap.SugaredLogger) *AccountExportService {
+	return &AccountExportService{
+		logger:    logger,
+		exporters: make([]UserDataExporter, 0),
+	}
+}
+
+// RegisterExporter регистрирует сервис, данные которого попадают в выгрузку
+func (s *AccountExportService) RegisterExporter(exporter UserDataExporter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.exporters = append(s.exporters, exporter)
+}
+
+// ExportAccount собирает данные пользователя из всех сервисов. Данные собираются до отправки ответа,
+// поэтому ошибка любого сервиса возвращается обычным ответом, а не обрывает скачивание.
+func (s *AccountExportService) ExportAccount(ctx context.Context) (models.AccountExport, error) {
+	claims := models.ClaimsFromContext(ctx)
+
+	s.mu.RLock()
+	exporters := slices.Clone(s.exporters)
+	s.mu.RUnlock()
+
+	export := models.AccountExport{
+		UserID:     claims.ID,
+		Nickname:   claims.Nickname,
+		ExportedAt: time.Now(),
+		Data:       make(map[string]any, len(exporters)),
+	}
+
+	for _, exporter := range exporters {
+		if err := ctx.Err(); err != nil {
+			return models.AccountExport{}, fmt.Errorf("export account: %w", err)
+		}
+
+		data, err := exporter.ExportUserData(ctx)
+		if err != nil {
+			return models.AccountExport{}, fmt.Errorf("export %s: %w", exporter.GetUserExportName(), err)
+		}
+
+		export.Data[exporter.GetUserExportName()] = data
+	}
+
+	s.logger.Infow("Account exported", "userId", claims.ID, "sections", len(export.Data))
+
+	return export, nil
+}
+
+// WriteArchive пишет выгрузку zip-архивом: account.json с данными о выгрузке и по файлу на каждый раздел
+func (s *AccountExportService) WriteArchive(ctx context.Context, w io.Writer, export models.AccountExport) error {
+	archive := zip.NewWriter(w)
+
+	manifest := export
+	manifest.Data = nil
+
+	files := map[string]any{"account": manifest}
+	for name, data := range export.Data {
+		files[name] = data
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		file, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     name + ".json",
+			Method:   zip.Deflate,
+			Modified: export.ExportedAt,
+		})
+		if err != nil {
+			return fmt.Errorf("can't create %s in archive: %w", name, err)
+		}
+
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+
+		if err := encoder.Encode(files[name]); err != nil {
+			return fmt.Errorf("can't write %s json: %w", name, err)
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("can't finish archive: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package service_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"eats-backend/internal/models"
+	"eats-backend/internal/service"
+)
+
+type testExporter struct {
+	name string
+	err  error
+}
+
+func (e testExporter) ExportUserData(context.Context) (any, error) { return []string{"x"}, e.err }
+func (e testExporter) GetUserExportName() string                   { return e.name }
+
+func TestAccountExportService_ExportAccount(t *testing.T) {
+	exports := service.NewAccountExportService(zap.NewNop().Sugar())
+	exports.RegisterExporter(service.NewFavouritesService(map[string][]string{
+		"user-1": {"apple-001"},
+		"user-2": {"banana-001"},
+	}))
+	exports.RegisterExporter(testExporter{name: "static"})
+
+	ctx := models.ContextWithUser(t.Context(), "user-1")
+
+	export, err := exports.ExportAccount(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "user-1", export.UserID)
+	require.Len(t, export.Data, 2)
+	require.Equal(t, []string{"apple-001"}, export.Data["favourites"])
+
+	var buf bytes.Buffer
+	require.NoError(t, exports.WriteArchive(ctx, &buf, export))
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+
+	names := make([]string, 0, len(archive.File))
+	for _, file := range archive.File {
+		names = append(names, file.Name)
+	}
+
+	require.Equal(t, []string{"account.json", "favourites.json", "static.json"}, names)
+
+	// Ошибка любого раздела отменяет всю выгрузку
+	exports.RegisterExporter(testExporter{name: "broken", err: errors.New("boom")})
+
+	_, err = exports.ExportAccount(ctx)
+	require.ErrorContains(t, err, "export broken: boom")
+}
@@ -3,36 +3,71 @@ package service
 import (
 	"context"
 	"fmt"
+	"strings"
 	"sync"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 
 	"eats-backend/internal/models"
 )
 
+const maxAddressNameLength = 50
+
+// AddressLimits ограничения адресов пользователя. Нулевое значение отключает ограничение.
+type AddressLimits struct {
+	MaxPerUser int
+	// Новый адрес ближе этого расстояния к сохраненному считается его дублем.
+	DuplicateRadiusMeters int
+}
+
 type AddressService struct {
+	limits    AddressLimits
 	addresses map[string][]*models.Address
+	// Выбранный адрес доставки: userID -> addressID
+	current map[string]string
 
 	mux sync.RWMutex
 }
 
-func NewAddressService() *AddressService {
+func NewAddressService(limits AddressLimits) *AddressService {
 	return &AddressService{
+		limits:    limits,
 		addresses: make(map[string][]*models.Address),
+		current:   make(map[string]string),
 	}
 }
 
-func (s *AddressService) GetAddresses(ctx context.Context) []*models.Address {
+// GetAddresses возвращает адреса пользователя. Если label не пустой, только адреса с этим типом.
+func (s *AddressService) GetAddresses(ctx context.Context, label models.AddressLabel) ([]*models.Address, error) {
 	userID := models.ClaimsFromContext(ctx).ID
 
+	if label != "" {
+		if err := validateAddressLabel(label); err != nil {
+			return nil, err
+		}
+	}
+
 	s.mux.RLock()
 	defer s.mux.RUnlock()
 
-	if addresses, ok := s.addresses[userID]; ok {
-		return addresses
+	addresses, ok := s.addresses[userID]
+	if !ok {
+		return []*models.Address{}, nil
+	}
+
+	if label == "" {
+		return addresses, nil
 	}
 
-	return []*models.Address{}
+	result := make([]*models.Address, 0)
+	for _, address := range addresses {
+		if address.Label == label {
+			result = append(result, address)
+		}
+	}
+
+	return result, nil
 }
 
 func (s *AddressService) AddAddress(ctx context.Context, address *models.Address) error {
@@ -45,6 +80,15 @@ func (s *AddressService) AddAddress(ctx context.Context, address *models.Address
 	s.mux.Lock()
 	defer s.mux.Unlock()
 
+	// Дубль проверяется раньше лимита, чтобы скрипт, повторяющий один запрос, получил id уже созданного адреса
+	if existing := s.findDuplicate(userID, "", address); existing != nil {
+		return &models.DuplicateAddressError{AddressID: existing.ID}
+	}
+
+	if s.limits.MaxPerUser > 0 && len(s.addresses[userID]) >= s.limits.MaxPerUser {
+		return &models.AddressLimitError{MaxAddresses: s.limits.MaxPerUser}
+	}
+
 	address.ID = uuid.NewString()
 
 	if _, ok := s.addresses[userID]; !ok {
@@ -56,6 +100,34 @@ func (s *AddressService) AddAddress(ctx context.Context, address *models.Address
 	return nil
 }
 
+// findDuplicate ищет сохраненный адрес с той же строкой адреса или с координатами в радиусе DuplicateRadiusMeters.
+// Адрес с идентификатором skipID не учитывается, чтобы редактируемый адрес не считался дублем самого себя.
+func (s *AddressService) findDuplicate(userID, skipID string, address *models.Address) *models.Address {
+	line := normalizeAddressLine(address.AddressLine)
+
+	for _, existing := range s.addresses[userID] {
+		if existing.ID == skipID {
+			continue
+		}
+
+		if normalizeAddressLine(existing.AddressLine) == line {
+			return existing
+		}
+
+		if s.limits.DuplicateRadiusMeters > 0 && len(existing.Coordinates) == 2 &&
+			haversineKm(existing.Coordinates, address.Coordinates)*1000 <= float64(s.limits.DuplicateRadiusMeters) {
+			return existing
+		}
+	}
+
+	return nil
+}
+
+// normalizeAddressLine приводит строку адреса к виду для сравнения: без регистра и лишних пробелов
+func normalizeAddressLine(line string) string {
+	return strings.Join(strings.Fields(strings.ToLower(line)), " ")
+}
+
 func (s *AddressService) RemoveAddress(ctx context.Context, addressID string) error {
 	userID := models.ClaimsFromContext(ctx).ID
 
@@ -70,6 +142,10 @@ func (s *AddressService) RemoveAddress(ctx context.Context, addressID string) er
 		if address.ID == addressID {
 			s.addresses[userID] = append(s.addresses[userID][:i], s.addresses[userID][i+1:]...)
 
+			if s.current[userID] == addressID {
+				delete(s.current, userID)
+			}
+
 			return nil
 		}
 	}
@@ -93,6 +169,10 @@ func (s *AddressService) UpdateAddress(ctx context.Context, newAddress *models.A
 
 	for i, address := range s.addresses[userID] {
 		if address.ID == newAddress.ID {
+			if existing := s.findDuplicate(userID, address.ID, newAddress); existing != nil {
+				return &models.DuplicateAddressError{AddressID: existing.ID}
+			}
+
 			s.addresses[userID][i] = newAddress
 
 			return nil
@@ -102,6 +182,46 @@ func (s *AddressService) UpdateAddress(ctx context.Context, newAddress *models.A
 	return fmt.Errorf("%w: address not found", models.ErrNotFound)
 }
 
+// PatchAddress меняет только переданные поля адреса. Проверяются тоже только они,
+// поэтому смена кода домофона не требует заново присылать координаты.
+func (s *AddressService) PatchAddress(
+	ctx context.Context,
+	addressID string,
+	patch models.AddressPatch,
+) (models.Address, error) {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	if err := validateAddressPatch(&patch); err != nil {
+		return models.Address{}, err
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	for i, address := range s.addresses[userID] {
+		if address.ID != addressID {
+			continue
+		}
+
+		// Адрес заменяется копией, чтобы не менять объект, который мог быть отдан раньше
+		updated := *address
+		applyAddressPatch(&updated, patch)
+
+		// Дубль возможен, только если меняется строка адреса или координаты
+		if patch.AddressLine != nil || patch.Coordinates != nil {
+			if existing := s.findDuplicate(userID, address.ID, &updated); existing != nil {
+				return models.Address{}, &models.DuplicateAddressError{AddressID: existing.ID}
+			}
+		}
+
+		s.addresses[userID][i] = &updated
+
+		return updated, nil
+	}
+
+	return models.Address{}, fmt.Errorf("%w: address not found", models.ErrNotFound)
+}
+
 func (s *AddressService) GetAddressByID(ctx context.Context, addressID string) (models.Address, error) {
 	userID := models.ClaimsFromContext(ctx).ID
 
@@ -119,6 +239,55 @@ func (s *AddressService) GetAddressByID(ctx context.Context, addressID string) (
 	return models.Address{}, fmt.Errorf("%w: address not found", models.ErrNotFound)
 }
 
+// SetCurrentAddress выбирает адрес, по которому корзина считает доставку
+func (s *AddressService) SetCurrentAddress(ctx context.Context, addressID string) (models.Address, error) {
+	address, err := s.GetAddressByID(ctx, addressID)
+	if err != nil {
+		return models.Address{}, err
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.current[models.ClaimsFromContext(ctx).ID] = addressID
+
+	return address, nil
+}
+
+// ClearCurrentAddress сбрасывает выбранный адрес, корзина возвращается к доставке без учета адреса
+func (s *AddressService) ClearCurrentAddress(ctx context.Context) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	delete(s.current, models.ClaimsFromContext(ctx).ID)
+}
+
+// GetCurrentAddress возвращает выбранный адрес, false - если адрес не выбран
+func (s *AddressService) GetCurrentAddress(ctx context.Context) (models.Address, bool) {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	s.mux.RLock()
+	addressID, ok := s.current[userID]
+	s.mux.RUnlock()
+
+	if !ok {
+		return models.Address{}, false
+	}
+
+	address, err := s.GetAddressByID(ctx, addressID)
+
+	return address, err == nil
+}
+
+// ResetUser удаляет все адреса пользователя
+func (s *AddressService) ResetUser(userID string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	delete(s.addresses, userID)
+	delete(s.current, userID)
+}
+
 func validateCoordinates(coordinates []float64) error {
 	if len(coordinates) != 2 {
 		return fmt.Errorf("%w: invalid coordinates amount, should be two numbers", models.ErrBadRequest)
@@ -137,14 +306,125 @@ func validateCoordinates(coordinates []float64) error {
 	return nil
 }
 
+func validateAddressLabel(label models.AddressLabel) error {
+	switch label {
+	case models.AddressLabelHome, models.AddressLabelWork, models.AddressLabelOther:
+		return nil
+	default:
+		return fmt.Errorf("%w: unknown address label %s, should be one of home, work, other", models.ErrBadRequest, label)
+	}
+}
+
+// validateAddress проверяет адрес и подставляет тип по умолчанию
 func validateAddress(address *models.Address) error {
 	if address.AddressLine == "" {
 		return fmt.Errorf("%w: address line required", models.ErrBadRequest)
 	}
 
+	if address.Label == "" {
+		address.Label = models.AddressLabelOther
+	}
+
+	if err := validateAddressLabel(address.Label); err != nil {
+		return err
+	}
+
+	address.Name = strings.TrimSpace(address.Name)
+	if utf8.RuneCountInString(address.Name) > maxAddressNameLength {
+		return fmt.Errorf("%w: address name is longer than %d characters", models.ErrBadRequest, maxAddressNameLength)
+	}
+
 	if err := validateCoordinates(address.Coordinates); err != nil {
 		return fmt.Errorf("%w: %w", models.ErrBadRequest, err)
 	}
 
 	return nil
 }
+
+func validateAddressPatch(patch *models.AddressPatch) error {
+	if patch.AddressLine != nil && *patch.AddressLine == "" {
+		return fmt.Errorf("%w: address line can't be empty", models.ErrBadRequest)
+	}
+
+	if patch.Label != nil {
+		if err := validateAddressLabel(*patch.Label); err != nil {
+			return err
+		}
+	}
+
+	if patch.Name != nil {
+		name := strings.TrimSpace(*patch.Name)
+		if utf8.RuneCountInString(name) > maxAddressNameLength {
+			return fmt.Errorf("%w: address name is longer than %d characters", models.ErrBadRequest, maxAddressNameLength)
+		}
+
+		patch.Name = &name
+	}
+
+	if patch.Coordinates != nil {
+		if err := validateCoordinates(patch.Coordinates); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func applyAddressPatch(address *models.Address, patch models.AddressPatch) {
+	if patch.Coordinates != nil {
+		address.Coordinates = patch.Coordinates
+	}
+
+	if patch.AddressLine != nil {
+		address.AddressLine = *patch.AddressLine
+	}
+
+	if patch.Floor != nil {
+		address.Floor = *patch.Floor
+	}
+
+	if patch.Entrance != nil {
+		address.Entrance = *patch.Entrance
+	}
+
+	if patch.IntercomCode != nil {
+		address.IntercomCode = *patch.IntercomCode
+	}
+
+	if patch.Comment != nil {
+		address.Comment = *patch.Comment
+	}
+
+	if patch.Label != nil {
+		address.Label = *patch.Label
+	}
+
+	if patch.Name != nil {
+		address.Name = *patch.Name
+	}
+}
+
+// ExportUserData возвращает адреса пользователя и выбранный адрес доставки
+func (s *AddressService) ExportUserData(ctx context.Context) (any, error) {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	addresses := make([]models.Address, 0, len(s.addresses[userID]))
+	for _, address := range s.addresses[userID] {
+		addresses = append(addresses, *address)
+	}
+
+	return struct {
+		Addresses        []models.Address `json:"addresses"`
+		CurrentAddressID string           `json:"currentAddressId,omitempty"`
+	}{
+		Addresses:        addresses,
+		CurrentAddressID: s.current[userID],
+	}, nil
+}
+
+func (s *AddressService) GetUserExportName() string {
+	return "addresses"
+}
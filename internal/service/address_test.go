@@ -0,0 +1,85 @@
+package service_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"eats-backend/internal/models"
+	"eats-backend/internal/service"
+)
+
+func TestAddressService_AddAddressDuplicates(t *testing.T) {
+	addresses := service.NewAddressService(service.AddressLimits{MaxPerUser: 2, DuplicateRadiusMeters: 30})
+	ctx := models.ContextWithUser(t.Context(), "user-1")
+
+	home := &models.Address{AddressLine: "Тверская, 1", Coordinates: []float64{37.6176, 55.7558}}
+	require.NoError(t, addresses.AddAddress(ctx, home))
+
+	// Строка адреса сравнивается без регистра и лишних пробелов
+	err := addresses.AddAddress(ctx, &models.Address{AddressLine: " тверская,  1", Coordinates: []float64{30.31, 59.94}})
+
+	var duplicate *models.DuplicateAddressError
+	require.ErrorAs(t, err, &duplicate)
+	require.ErrorIs(t, err, models.ErrConflict)
+	require.Equal(t, home.ID, duplicate.AddressID)
+
+	// Около 20 метров от сохраненного адреса
+	err = addresses.AddAddress(ctx, &models.Address{AddressLine: "Тверская, 1с2", Coordinates: []float64{37.6179, 55.7558}})
+	require.ErrorAs(t, err, &duplicate)
+
+	require.NoError(t, addresses.AddAddress(ctx, &models.Address{AddressLine: "Невский, 1", Coordinates: []float64{30.31, 59.94}}))
+
+	err = addresses.AddAddress(ctx, &models.Address{AddressLine: "Арбат, 1", Coordinates: []float64{37.59, 55.75}})
+
+	var limit *models.AddressLimitError
+	require.ErrorAs(t, err, &limit)
+	require.Equal(t, 2, limit.MaxAddresses)
+
+	// У другого пользователя свои адреса
+	require.NoError(t, addresses.AddAddress(models.ContextWithUser(t.Context(), "user-2"),
+		&models.Address{AddressLine: "Тверская, 1", Coordinates: []float64{37.6176, 55.7558}}))
+}
+
+func TestAddressService_EditAddressDuplicates(t *testing.T) {
+	addresses := service.NewAddressService(service.AddressLimits{DuplicateRadiusMeters: 30})
+	ctx := models.ContextWithUser(t.Context(), "user-1")
+
+	home := &models.Address{AddressLine: "Тверская, 1", Coordinates: []float64{37.6176, 55.7558}}
+	require.NoError(t, addresses.AddAddress(ctx, home))
+
+	work := &models.Address{AddressLine: "Невский, 1", Coordinates: []float64{30.31, 59.94}}
+	require.NoError(t, addresses.AddAddress(ctx, work))
+
+	// Адрес не считается дублем самого себя
+	require.NoError(t, addresses.UpdateAddress(ctx, &models.Address{
+		ID: work.ID, AddressLine: "Невский, 1", Coordinates: []float64{30.31, 59.94}, Floor: "3",
+	}))
+
+	var duplicate *models.DuplicateAddressError
+
+	err := addresses.UpdateAddress(ctx, &models.Address{ID: work.ID, AddressLine: "ТВЕРСКАЯ, 1", Coordinates: []float64{30.31, 59.94}})
+	require.ErrorAs(t, err, &duplicate)
+	require.ErrorIs(t, err, models.ErrConflict)
+	require.Equal(t, home.ID, duplicate.AddressID)
+
+	// Точка рядом с другим адресом
+	_, err = addresses.PatchAddress(ctx, work.ID, models.AddressPatch{Coordinates: []float64{37.6179, 55.7558}})
+	require.ErrorAs(t, err, &duplicate)
+	require.Equal(t, home.ID, duplicate.AddressID)
+
+	line := "Тверская, 1"
+	_, err = addresses.PatchAddress(ctx, work.ID, models.AddressPatch{AddressLine: &line})
+	require.ErrorAs(t, err, &duplicate)
+
+	// Адрес после отказа не изменился
+	stored, err := addresses.GetAddressByID(ctx, work.ID)
+	require.NoError(t, err)
+	require.Equal(t, "Невский, 1", stored.AddressLine)
+	require.Equal(t, "3", stored.Floor)
+
+	code := "45К1234"
+	patched, err := addresses.PatchAddress(ctx, work.ID, models.AddressPatch{IntercomCode: &code})
+	require.NoError(t, err)
+	require.Equal(t, code, patched.IntercomCode)
+}
@@ -3,13 +3,31 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.uber.org/zap"
+
+	"eats-backend/internal/metrics"
+	"eats-backend/internal/migrations"
+	"eats-backend/internal/models"
+)
+
+const (
+	// Сколько объектов сохраняется одновременно.
+	backupParallelism = 4
+	// Снимок - файлы одного запуска бэкапа, его ID собирается из каталога с датой и метки времени файлов.
+	snapshotIDLayout = "2006-01-02_15-04-05"
+	timestampLayout  = "15-04-05"
 )
 
 // Backupable интерфейс для объектов, которые нужно бэкапить
@@ -18,27 +36,69 @@ type Backupable interface {
 	GetBackupFileName() string
 }
 
+// Restorable объект, состояние которого можно заменить данными из бэкапа
+type Restorable interface {
+	Backupable
+	// RestoreBackupData получает JSON в формате GetBackupData и заменяет им все данные объекта
+	RestoreBackupData(data []byte) error
+}
+
+// BackupAlerter получает сообщения о бэкапах, которые не удались после всех повторов, и о том,
+// что бэкапы снова проходят
+type BackupAlerter interface {
+	BackupFailed(ctx context.Context, failure models.BackupFailure)
+	BackupRecovered(ctx context.Context)
+}
+
 // BackupService сервис для автоматического бэкапа данных
 type BackupService struct {
 	logger      *zap.SugaredLogger
+	clock       Clock
 	backupables []Backupable
+	alerters    []BackupAlerter
 	dataDir     string
 	interval    time.Duration
-	stopChan    chan struct{}
-	mu          sync.RWMutex
+	// Сколько раз пытаться сделать бэкап по таймеру и задержка перед первым повтором, дальше она удваивается
+	retryAttempts int
+	retryBackoff  time.Duration
+	stopChan      chan struct{}
+	// Результат последнего бэкапа по имени файла объекта
+	statuses map[string]models.BackupStatus
+	health   models.BackupHealth
+	mu       sync.RWMutex
+	running  sync.Mutex
 }
 
 // NewBackupService создает новый сервис бэкапа
-func NewBackupService(logger *zap.SugaredLogger, dataDir string, interval time.Duration) *BackupService {
+func NewBackupService(
+	logger *zap.SugaredLogger,
+	clock Clock,
+	dataDir string,
+	interval time.Duration,
+	retryAttempts int,
+	retryBackoff time.Duration,
+) *BackupService {
 	return &BackupService{
-		logger:      logger,
-		backupables: make([]Backupable, 0),
-		dataDir:     dataDir,
-		interval:    interval,
-		stopChan:    make(chan struct{}),
+		logger:        logger,
+		clock:         clockOrSystem(clock),
+		backupables:   make([]Backupable, 0),
+		dataDir:       dataDir,
+		interval:      interval,
+		retryAttempts: max(retryAttempts, 1),
+		retryBackoff:  retryBackoff,
+		stopChan:      make(chan struct{}),
+		statuses:      make(map[string]models.BackupStatus),
 	}
 }
 
+// RegisterAlerter добавляет получателя сообщений о неудачных бэкапах
+func (bs *BackupService) RegisterAlerter(alerter BackupAlerter) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	bs.alerters = append(bs.alerters, alerter)
+}
+
 // RegisterBackupable регистрирует объект для бэкапа
 func (bs *BackupService) RegisterBackupable(backupable Backupable) {
 	bs.mu.Lock()
@@ -52,7 +112,7 @@ func (bs *BackupService) Start(ctx context.Context) {
 	bs.logger.Info("Starting backup service")
 
 	// Выполняем первый бэкап сразу при запуске
-	if err := bs.PerformBackup(); err != nil {
+	if err := bs.BackupWithRetry(ctx); err != nil {
 		bs.logger.Errorf("Initial backup failed: %v", err)
 	}
 
@@ -62,7 +122,7 @@ func (bs *BackupService) Start(ctx context.Context) {
 	for {
 		select {
 		case <-ticker.C:
-			if err := bs.PerformBackup(); err != nil {
+			if err := bs.BackupWithRetry(ctx); err != nil {
 				bs.logger.Errorf("Backup failed: %v", err)
 			}
 		case <-bs.stopChan:
@@ -80,8 +140,114 @@ func (bs *BackupService) Stop() {
 	close(bs.stopChan)
 }
 
-// PerformBackup выполняет бэкап всех зарегистрированных объектов
+// PerformBackup выполняет бэкап всех зарегистрированных объектов. Объекты сохраняются параллельно,
+// ошибка одного не мешает остальным; возвращается ошибка, если не удалось сохранить хотя бы один.
 func (bs *BackupService) PerformBackup() error {
+	_, _, err := bs.performBackup()
+
+	return err
+}
+
+// BackupWithRetry делает бэкап и при ошибке сразу повторяет его с растущей задержкой, не дожидаясь
+// следующего запуска по таймеру. Если все попытки не удались, сервис помечается деградировавшим
+// (это видно в /readyz), а получатели из RegisterAlerter получают сообщение.
+func (bs *BackupService) BackupWithRetry(ctx context.Context) error {
+	var (
+		failed []string
+		err    error
+	)
+
+	attempt := 0
+	for attempt < bs.retryAttempts {
+		if attempt > 0 {
+			delay := bs.retryBackoff << (attempt - 1)
+			bs.logger.Warnf("Backup failed, retrying in %s (attempt %d of %d): %v", delay, attempt+1, bs.retryAttempts, err)
+
+			select {
+			case <-time.After(delay):
+			case <-bs.stopChan:
+				return err
+			case <-ctx.Done():
+				return err
+			}
+		}
+
+		attempt++
+
+		_, failed, err = bs.performBackup()
+		if err == nil {
+			return nil
+		}
+	}
+
+	bs.markFailed(ctx, models.BackupFailure{
+		FailedAt: bs.clock.Now(),
+		Attempts: attempt,
+		Objects:  failed,
+		Error:    err.Error(),
+	})
+
+	return err
+}
+
+// markFailed помечает бэкапы деградировавшими и сообщает о провале
+func (bs *BackupService) markFailed(ctx context.Context, failure models.BackupFailure) {
+	bs.mu.Lock()
+	bs.health.Degraded = true
+	bs.health.LastFailure = &failure
+	alerters := slices.Clone(bs.alerters)
+	bs.mu.Unlock()
+
+	bs.logger.Errorw("Backup failed after all retries",
+		"attempts", failure.Attempts, "objects", failure.Objects, "error", failure.Error)
+
+	for _, alerter := range alerters {
+		alerter.BackupFailed(ctx, failure)
+	}
+}
+
+// markSucceeded снимает пометку о деградации после успешного бэкапа
+func (bs *BackupService) markSucceeded(ctx context.Context) {
+	bs.mu.Lock()
+	recovered := bs.health.Degraded
+	bs.health.Degraded = false
+	bs.health.LastSuccess = bs.clock.Now()
+	alerters := slices.Clone(bs.alerters)
+	bs.mu.Unlock()
+
+	if !recovered {
+		return
+	}
+
+	bs.logger.Info("Backups recovered")
+
+	for _, alerter := range alerters {
+		alerter.BackupRecovered(ctx)
+	}
+}
+
+// Health возвращает состояние бэкапов для проверки готовности
+func (bs *BackupService) Health(_ context.Context) models.BackupHealth {
+	bs.mu.RLock()
+	defer bs.mu.RUnlock()
+
+	health := bs.health
+	if health.LastFailure != nil {
+		failure := *health.LastFailure
+		failure.Objects = slices.Clone(failure.Objects)
+		health.LastFailure = &failure
+	}
+
+	return health
+}
+
+// performBackup возвращает ID созданного снимка, пустой, если сохранять нечего, и объекты,
+// которые не удалось сохранить
+func (bs *BackupService) performBackup() (id string, failed []string, err error) {
+	// Бэкап по таймеру и бэкап при остановке не должны писать одновременно
+	bs.running.Lock()
+	defer bs.running.Unlock()
+
 	bs.mu.RLock()
 	backupables := make([]Backupable, len(bs.backupables))
 	copy(backupables, bs.backupables)
@@ -89,65 +255,395 @@ func (bs *BackupService) PerformBackup() error {
 
 	if len(backupables) == 0 {
 		bs.logger.Debug("No backupables registered, skipping backup")
-		return nil
+		return "", nil, nil
 	}
 
 	bs.logger.Info("Starting backup process")
 
+	var size atomic.Int64
+
+	start := time.Now()
+	defer func() { recordBackupMetrics(time.Since(start), size.Load(), err) }()
+
 	// Создаем директорию для бэкапов если она не существует
 	backupDir := filepath.Join(bs.dataDir, "backups")
 	if err := os.MkdirAll(backupDir, 0755); err != nil {
-		return fmt.Errorf("failed to create backup directory: %w", err)
+		return "", nil, fmt.Errorf("failed to create backup directory: %w", err)
 	}
 
 	// Создаем поддиректорию с текущей датой
-	timestamp := time.Now().Format("2006-01-02")
-	dateDir := filepath.Join(backupDir, timestamp)
+	now := bs.clock.Now()
+	dateDir := filepath.Join(backupDir, now.Format(time.DateOnly))
 	if err := os.MkdirAll(dateDir, 0755); err != nil {
-		return fmt.Errorf("failed to create date directory: %w", err)
+		return "", nil, fmt.Errorf("failed to create date directory: %w", err)
 	}
 
-	successCount := 0
-	for _, backupable := range backupables {
-		if err := bs.backupObject(backupable, dateDir); err != nil {
-			bs.logger.Errorf("Failed to backup %s: %v", backupable.GetBackupFileName(), err)
-		} else {
-			successCount++
+	// Версия формата нужна, чтобы восстановленный из бэкапа каталог можно было мигрировать
+	if err := migrations.WriteVersion(dateDir, migrations.CurrentVersion()); err != nil {
+		return "", nil, fmt.Errorf("failed to write data version: %w", err)
+	}
+
+	// Одна метка времени на все файлы, чтобы было видно, какие из них сделаны вместе
+	timestamp := now.Format(timestampLayout)
+
+	var (
+		wg   sync.WaitGroup
+		errs = make([]error, len(backupables))
+		sem  = make(chan struct{}, backupParallelism)
+	)
+
+	for i, backupable := range backupables {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			file, err := bs.backupObject(backupable, dateDir, timestamp)
+			bs.recordStatus(backupable.GetBackupFileName(), file, err)
+
+			if info, statErr := os.Stat(file); err == nil && statErr == nil {
+				size.Add(info.Size())
+			}
+
+			if err != nil {
+				bs.logger.Errorf("Failed to backup %s: %v", backupable.GetBackupFileName(), err)
+				errs[i] = fmt.Errorf("%s: %w", backupable.GetBackupFileName(), err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	err = errors.Join(errs...)
+	failed = make([]string, 0)
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, backupables[i].GetBackupFileName())
 		}
 	}
 
-	bs.logger.Infof("Backup completed: %d/%d objects backed up successfully", successCount, len(backupables))
-	return nil
+	bs.logger.Infof("Backup completed: %d/%d objects backed up successfully", len(backupables)-len(failed), len(backupables))
+
+	if err != nil {
+		return "", failed, fmt.Errorf("%d of %d objects failed: %w", len(failed), len(backupables), err)
+	}
+
+	bs.markSucceeded(context.Background())
+
+	return now.Format(snapshotIDLayout), nil, nil
 }
 
-// backupObject создает бэкап отдельного объекта
-func (bs *BackupService) backupObject(backupable Backupable, backupDir string) error {
+// recordBackupMetrics обновляет метрики бэкапа: длительность и размер показывают последний запуск,
+// в том числе неудачный
+func recordBackupMetrics(duration time.Duration, size int64, err error) {
+	metrics.BackupDuration.Set(duration.Seconds())
+	metrics.BackupSize.Set(float64(size))
+
+	if err != nil {
+		metrics.Backups.Inc("failure")
+
+		return
+	}
+
+	metrics.Backups.Inc("success")
+	metrics.BackupLastSuccess.Set(float64(time.Now().Unix()))
+}
+
+// backupObject создает бэкап отдельного объекта и возвращает путь к файлу. Файл сначала пишется
+// во временный и переименовывается, поэтому при падении процесса не остается обрезанных бэкапов.
+func (bs *BackupService) backupObject(backupable Backupable, backupDir, timestamp string) (file string, err error) {
+	// Паника в одном сервисе не должна ронять бэкап остальных
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
 	fileName := backupable.GetBackupFileName()
 	if fileName == "" {
-		return fmt.Errorf("empty backup file name")
+		return "", fmt.Errorf("empty backup file name")
 	}
 
 	data := backupable.GetBackupData()
 	if data == nil {
-		return fmt.Errorf("no backup data available")
+		return "", fmt.Errorf("no backup data available")
 	}
 
-	// Добавляем timestamp к имени файла
-	timestamp := time.Now().Format("15-04-05")
-	backupFileName := fmt.Sprintf("%s_backup_%s.json", fileName, timestamp)
-	filePath := filepath.Join(backupDir, backupFileName)
+	filePath := filepath.Join(backupDir, backupFileName(fileName, timestamp))
 
 	// Сериализуем данные в JSON
 	jsonData, err := json.MarshalIndent(data, "", "  ")
 	if err != nil {
-		return fmt.Errorf("failed to marshal backup data: %w", err)
+		return "", fmt.Errorf("failed to marshal backup data: %w", err)
 	}
 
-	// Записываем в файл
-	if err := os.WriteFile(filePath, jsonData, 0644); err != nil {
-		return fmt.Errorf("failed to write backup file: %w", err)
+	if err := writeFileAtomic(filePath, jsonData); err != nil {
+		return "", fmt.Errorf("failed to write backup file: %w", err)
 	}
 
 	bs.logger.Debugf("Successfully backed up %s to %s", fileName, filePath)
-	return nil
+	return filePath, nil
+}
+
+func (bs *BackupService) recordStatus(name, file string, err error) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	status := bs.statuses[name]
+	status.Name = name
+	status.LastAttempt = bs.clock.Now()
+	status.Error = ""
+
+	if err != nil {
+		status.Error = err.Error()
+	} else {
+		status.LastSuccess = status.LastAttempt
+		status.File = file
+	}
+
+	bs.statuses[name] = status
+}
+
+// GetStatus возвращает результат последнего бэкапа каждого объекта
+func (bs *BackupService) GetStatus(_ context.Context) []models.BackupStatus {
+	bs.mu.RLock()
+	defer bs.mu.RUnlock()
+
+	result := slices.Collect(maps.Values(bs.statuses))
+	slices.SortFunc(result, func(a, b models.BackupStatus) int { return strings.Compare(a.Name, b.Name) })
+
+	return result
+}
+
+// BackupNow сразу делает бэкап и возвращает созданный снимок
+func (bs *BackupService) BackupNow(_ context.Context) (models.BackupSnapshot, error) {
+	id, _, err := bs.performBackup()
+	if err != nil {
+		return models.BackupSnapshot{}, fmt.Errorf("%w: %w", models.ErrInternalServer, err)
+	}
+
+	if id == "" {
+		return models.BackupSnapshot{}, fmt.Errorf("%w: nothing to back up", models.ErrBadRequest)
+	}
+
+	snapshots, err := bs.ListSnapshots(context.Background())
+	if err != nil {
+		return models.BackupSnapshot{}, err
+	}
+
+	for _, snapshot := range snapshots {
+		if snapshot.ID == id {
+			return snapshot, nil
+		}
+	}
+
+	return models.BackupSnapshot{}, fmt.Errorf("%w: snapshot %s not found after backup", models.ErrInternalServer, id)
+}
+
+// ListSnapshots возвращает снимки из каталога бэкапов, новые первыми
+func (bs *BackupService) ListSnapshots(_ context.Context) ([]models.BackupSnapshot, error) {
+	backupDir := filepath.Join(bs.dataDir, "backups")
+
+	dates, err := os.ReadDir(backupDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []models.BackupSnapshot{}, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: can't read backup directory: %w", models.ErrInternalServer, err)
+	}
+
+	snapshots := make(map[string]*models.BackupSnapshot)
+
+	for _, date := range dates {
+		if !date.IsDir() {
+			continue
+		}
+
+		files, err := os.ReadDir(filepath.Join(backupDir, date.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("%w: can't read backup directory: %w", models.ErrInternalServer, err)
+		}
+
+		for _, file := range files {
+			name, timestamp, ok := parseBackupFileName(file.Name())
+			if !ok {
+				continue
+			}
+
+			id := date.Name() + "_" + timestamp
+
+			// Посторонние файлы и каталоги (например pre_migration_v0) пропускаются
+			createdAt, err := time.ParseInLocation(snapshotIDLayout, id, time.Local)
+			if err != nil {
+				continue
+			}
+
+			info, err := file.Info()
+			if err != nil {
+				continue
+			}
+
+			snapshot, ok := snapshots[id]
+			if !ok {
+				snapshot = &models.BackupSnapshot{ID: id, CreatedAt: createdAt, Objects: []string{}}
+				snapshots[id] = snapshot
+			}
+
+			snapshot.Size += info.Size()
+			snapshot.Objects = append(snapshot.Objects, name)
+		}
+	}
+
+	result := make([]models.BackupSnapshot, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		slices.Sort(snapshot.Objects)
+		result = append(result, *snapshot)
+	}
+
+	slices.SortFunc(result, func(a, b models.BackupSnapshot) int { return b.CreatedAt.Compare(a.CreatedAt) })
+
+	return result, nil
+}
+
+// Restore загружает снимок в работающие сервисы. Сначала читаются и проверяются все файлы снимка,
+// и только потом данные применяются, чтобы битый файл не оставил сервисы восстановленными наполовину.
+// Сервисы, которых нет в снимке, не меняются.
+func (bs *BackupService) Restore(_ context.Context, id string) (models.RestoreResult, error) {
+	createdAt, err := time.ParseInLocation(snapshotIDLayout, id, time.Local)
+	if err != nil || createdAt.Format(snapshotIDLayout) != id {
+		return models.RestoreResult{}, fmt.Errorf(
+			"%w: snapshot must be in format %s", models.ErrBadRequest, snapshotIDLayout,
+		)
+	}
+
+	dateDir := filepath.Join(bs.dataDir, "backups", createdAt.Format(time.DateOnly))
+	timestamp := createdAt.Format(timestampLayout)
+
+	// Бэкап во время восстановления сохранил бы смесь старых и новых данных
+	bs.running.Lock()
+	defer bs.running.Unlock()
+
+	bs.mu.RLock()
+	backupables := slices.Clone(bs.backupables)
+	bs.mu.RUnlock()
+
+	result := models.RestoreResult{Snapshot: id, Restored: []string{}, Skipped: []string{}}
+	restorables := make([]Restorable, 0, len(backupables))
+	contents := make([][]byte, 0, len(backupables))
+
+	for _, backupable := range backupables {
+		name := backupable.GetBackupFileName()
+
+		restorable, ok := backupable.(Restorable)
+		if !ok {
+			result.Skipped = append(result.Skipped, name)
+
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(dateDir, backupFileName(name, timestamp)))
+		if errors.Is(err, fs.ErrNotExist) {
+			result.Skipped = append(result.Skipped, name)
+
+			continue
+		}
+
+		if err != nil {
+			return models.RestoreResult{}, fmt.Errorf("%w: can't read %s: %w", models.ErrInternalServer, name, err)
+		}
+
+		if !json.Valid(data) {
+			return models.RestoreResult{}, fmt.Errorf("%w: backup of %s is not valid json", models.ErrBadRequest, name)
+		}
+
+		restorables = append(restorables, restorable)
+		contents = append(contents, data)
+	}
+
+	if len(restorables) == 0 {
+		return models.RestoreResult{}, fmt.Errorf("%w: snapshot %s not found", models.ErrNotFound, id)
+	}
+
+	// Старые бэкапы нужно сначала обновить командой migrate
+	if err := migrations.Check(dateDir); err != nil {
+		return models.RestoreResult{}, fmt.Errorf("%w: %w", models.ErrBadRequest, err)
+	}
+
+	var errs []error
+
+	for i, restorable := range restorables {
+		name := restorable.GetBackupFileName()
+
+		if err := restorable.RestoreBackupData(contents[i]); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+
+			continue
+		}
+
+		result.Restored = append(result.Restored, name)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return models.RestoreResult{}, fmt.Errorf(
+			"%w: %d of %d objects failed to restore: %w", models.ErrInternalServer, len(errs), len(restorables), err,
+		)
+	}
+
+	bs.logger.Infof("Restored snapshot %s: %s", id, strings.Join(result.Restored, ", "))
+
+	return result, nil
+}
+
+func backupFileName(name, timestamp string) string {
+	return fmt.Sprintf("%s_backup_%s.json", name, timestamp)
+}
+
+// parseBackupFileName разбирает имя файла вида <объект>_backup_<время>.json
+func parseBackupFileName(fileName string) (name, timestamp string, ok bool) {
+	base, ok := strings.CutSuffix(fileName, ".json")
+	if !ok {
+		return "", "", false
+	}
+
+	i := strings.LastIndex(base, "_backup_")
+	if i <= 0 {
+		return "", "", false
+	}
+
+	return base[:i], base[i+len("_backup_"):], true
+}
+
+// writeFileAtomic пишет файл через временный файл в том же каталоге
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	// После успешного переименования удалять уже нечего
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
 }
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"eats-backend/internal/models"
+)
+
+// BackupAlertMailer пишет на адрес из BACKUP_ALERT_EMAIL, когда бэкап не удался после всех повторов
+// и когда бэкапы снова проходят.
+type BackupAlertMailer struct {
+	mailer Mailer
+	to     string
+	logger *zap.SugaredLogger
+}
+
+func NewBackupAlertMailer(mailer Mailer, to string, logger *zap.SugaredLogger) *BackupAlertMailer {
+	return &BackupAlertMailer{
+		mailer: mailer,
+		to:     to,
+		logger: logger,
+	}
+}
+
+func (m *BackupAlertMailer) BackupFailed(ctx context.Context, failure models.BackupFailure) {
+	var body strings.Builder
+
+	fmt.Fprintf(&body, "Бэкап не удался после %d попыток, последняя в %s.\n",
+		failure.Attempts, failure.FailedAt.Format("2006-01-02 15:04:05"))
+
+	if len(failure.Objects) > 0 {
+		fmt.Fprintf(&body, "Не сохранены: %s.\n", strings.Join(failure.Objects, ", "))
+	}
+
+	fmt.Fprintf(&body, "Ошибка: %s\n", failure.Error)
+
+	m.send(ctx, "Бэкап не удался", body.String())
+}
+
+func (m *BackupAlertMailer) BackupRecovered(ctx context.Context) {
+	m.send(ctx, "Бэкапы восстановлены", "Бэкап снова выполнен успешно.\n")
+}
+
+func (m *BackupAlertMailer) send(ctx context.Context, subject, body string) {
+	if err := m.mailer.Send(ctx, m.to, subject, body); err != nil {
+		m.logger.Errorw("Can't send backup alert", "to", m.to, "error", err)
+	}
+}
@@ -0,0 +1,137 @@
+package service_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"eats-backend/internal/models"
+	"eats-backend/internal/service"
+)
+
+type testBackupable struct {
+	name string
+	data func() interface{}
+}
+
+func (b testBackupable) GetBackupData() interface{} { return b.data() }
+func (b testBackupable) GetBackupFileName() string  { return b.name }
+
+func TestBackupService_PerformBackup_IsolatesFailures(t *testing.T) {
+	dir := t.TempDir()
+	backups := service.NewBackupService(zap.NewNop().Sugar(), nil, dir, 0, 1, 0)
+
+	backups.RegisterBackupable(testBackupable{name: "orders", data: func() interface{} { return map[string]int{"a": 1} }})
+	backups.RegisterBackupable(testBackupable{name: "broken", data: func() interface{} { panic("boom") }})
+	backups.RegisterBackupable(testBackupable{name: "carts", data: func() interface{} { return []string{"x"} }})
+
+	err := backups.PerformBackup()
+	require.ErrorContains(t, err, "1 of 3 objects failed")
+	require.ErrorContains(t, err, "broken: panic: boom")
+
+	statuses := backups.GetStatus(t.Context())
+	require.Len(t, statuses, 3)
+	require.Equal(t, "broken", statuses[0].Name)
+	require.NotEmpty(t, statuses[0].Error)
+	require.True(t, statuses[0].LastSuccess.IsZero())
+
+	for _, status := range statuses[1:] {
+		require.Empty(t, status.Error)
+		require.FileExists(t, status.File)
+	}
+
+	// Временные файлы не остаются рядом с бэкапами
+	entries, err := os.ReadDir(filepath.Dir(statuses[1].File))
+	require.NoError(t, err)
+
+	for _, entry := range entries {
+		require.NotContains(t, entry.Name(), ".tmp-")
+	}
+}
+
+type testBackupAlerter struct {
+	failures  []models.BackupFailure
+	recovered int
+}
+
+func (a *testBackupAlerter) BackupFailed(_ context.Context, failure models.BackupFailure) {
+	a.failures = append(a.failures, failure)
+}
+
+func (a *testBackupAlerter) BackupRecovered(_ context.Context) { a.recovered++ }
+
+func TestBackupService_BackupWithRetry(t *testing.T) {
+	backups := service.NewBackupService(zap.NewNop().Sugar(), nil, t.TempDir(), 0, 3, time.Millisecond)
+
+	alerter := &testBackupAlerter{}
+	backups.RegisterAlerter(alerter)
+
+	// Объект ломается на заданное число попыток
+	failures := 0
+	backups.RegisterBackupable(testBackupable{name: "flaky", data: func() interface{} {
+		if failures > 0 {
+			failures--
+			panic("disk full")
+		}
+
+		return []string{}
+	}})
+
+	// Временная ошибка исправляется повтором, никто не узнает о ней
+	failures = 2
+	require.NoError(t, backups.BackupWithRetry(t.Context()))
+	require.False(t, backups.Health(t.Context()).Degraded)
+	require.Empty(t, alerter.failures)
+
+	failures = 3
+	require.Error(t, backups.BackupWithRetry(t.Context()))
+
+	health := backups.Health(t.Context())
+	require.True(t, health.Degraded)
+	require.Equal(t, 3, health.LastFailure.Attempts)
+	require.Equal(t, []string{"flaky"}, health.LastFailure.Objects)
+	require.Len(t, alerter.failures, 1)
+
+	// Первый успешный бэкап снимает деградацию
+	require.NoError(t, backups.PerformBackup())
+	require.False(t, backups.Health(t.Context()).Degraded)
+	require.Equal(t, 1, alerter.recovered)
+}
+
+func TestBackupService_RestoreSnapshot(t *testing.T) {
+	clock := service.NewFakeClock(time.Date(2025, time.March, 3, 12, 30, 15, 0, time.Local))
+	backups := service.NewBackupService(zap.NewNop().Sugar(), clock, t.TempDir(), 0, 1, 0)
+	favourites := service.NewFavouritesService(map[string][]string{"user-1": {"apple-001"}})
+	backups.RegisterBackupable(favourites)
+	backups.RegisterBackupable(testBackupable{name: "static", data: func() interface{} { return []string{} }})
+
+	snapshot, err := backups.BackupNow(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, "2025-03-03_12-30-15", snapshot.ID)
+	require.Equal(t, []string{"static", "user_favourites"}, snapshot.Objects)
+
+	snapshots, err := backups.ListSnapshots(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, []models.BackupSnapshot{snapshot}, snapshots)
+
+	ctx := models.ContextWithUser(t.Context(), "user-1")
+	favourites.RemoveFavourite(ctx, "apple-001")
+	require.False(t, favourites.IsFavourite(ctx, "apple-001"))
+
+	result, err := backups.Restore(t.Context(), snapshot.ID)
+	require.NoError(t, err)
+	require.Equal(t, []string{"user_favourites"}, result.Restored)
+	require.Equal(t, []string{"static"}, result.Skipped)
+	require.True(t, favourites.IsFavourite(ctx, "apple-001"))
+
+	_, err = backups.Restore(t.Context(), "../../etc_00-00-00")
+	require.ErrorIs(t, err, models.ErrBadRequest)
+
+	_, err = backups.Restore(t.Context(), "2000-01-01_00-00-00")
+	require.ErrorIs(t, err, models.ErrNotFound)
+}
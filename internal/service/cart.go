@@ -2,99 +2,439 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"sync"
+	"time"
+	"unicode/utf8"
 
 	"eats-backend/internal/models"
 
 	"go.uber.org/zap"
 )
 
+// cartReservationTTL через сколько резерв корзины снимается сам, если экземпляр упал посреди оформления
+const cartReservationTTL = 5 * time.Minute
+
 type ProductService interface {
 	GetProductByID(ctx context.Context, id string) (models.Product, error)
 	ProductExists(id string) bool
 }
 
+type CartDelivery interface {
+	BaseDelivery(orderPrice models.Money) (price models.Money, minutes int)
+	Calculate(address models.Address, orderPrice models.Money) (distance float64, price models.Money, minutes int)
+	Info() models.DeliveryInfo
+}
+
+type CartAddresses interface {
+	GetAddressByID(ctx context.Context, addressID string) (models.Address, error)
+	GetCurrentAddress(ctx context.Context) (models.Address, bool)
+}
+
+type CartCombos interface {
+	GetCombo(ctx context.Context, id string) (models.Combo, error)
+}
+
+// CartStats получает изменения корзин для статистики
+type CartStats interface {
+	CartItemChanged(userID, productID string, quantity int)
+	CartReplaced(userID string, items map[string]int)
+}
+
 type Cart struct {
-	items map[string]map[string]*models.CartItem
+	store     CartStore
+	delivery  CartDelivery
+	addresses CartAddresses
+	stats     CartStats
+	combos    CartCombos
+	// Сколько единиц одного товара можно положить в корзину, 0 - без ограничения.
+	// У товара может быть свое ограничение, тогда действует меньшее.
+	maxQuantity int
+	// Исходные корзины из файла данных, к ним возвращает ResetUser.
+	seed map[string]map[string]*models.CartItem
 
 	productService ProductService
 	logger         *zap.SugaredLogger
 
-	mux sync.RWMutex
+	// Проверка резерва и изменение корзины выполняются под одной блокировкой. Сами резервы лежат
+	// в store, поэтому их видят все экземпляры.
+	mux sync.Mutex
 }
 
-func NewCart(productService ProductService, logger *zap.SugaredLogger, items map[string]map[string]*models.CartItem) *Cart {
+func NewCart(
+	productService ProductService,
+	store CartStore,
+	delivery CartDelivery,
+	addresses CartAddresses,
+	stats CartStats,
+	combos CartCombos,
+	maxQuantity int,
+	logger *zap.SugaredLogger,
+	seed map[string]map[string]*models.CartItem,
+) *Cart {
 	return &Cart{
-		items:          items,
+		store:          store,
+		delivery:       delivery,
+		addresses:      addresses,
+		stats:          stats,
+		combos:         combos,
+		maxQuantity:    maxQuantity,
+		seed:           copyCarts(seed),
 		productService: productService,
 		logger:         logger,
 	}
 }
 
+// GetCart возвращает корзину с доставкой на выбранный адрес пользователя
 func (s *Cart) GetCart(ctx context.Context) (models.CartResponse, error) {
+	return s.GetCartForAddress(ctx, "", 0)
+}
+
+// GetCartForAddress возвращает корзину с доставкой на адрес addressID. Без addressID доставка считается
+// до выбранного адреса, а если он не выбран - без учета адреса. Чаевые tip входят в итог, как в заказе.
+func (s *Cart) GetCartForAddress(ctx context.Context, addressID string, tip models.Money) (models.CartResponse, error) {
+	if tip < 0 || tip > models.MaxTip {
+		return models.CartResponse{}, fmt.Errorf("%w: tip must be between 0 and %s", models.ErrBadRequest, models.MaxTip)
+	}
+
+	address, hasAddress := models.Address{}, false
+	if addressID != "" {
+		var err error
+
+		address, err = s.addresses.GetAddressByID(ctx, addressID)
+		if err != nil {
+			return models.CartResponse{}, err
+		}
+
+		hasAddress = true
+	} else {
+		address, hasAddress = s.addresses.GetCurrentAddress(ctx)
+	}
+
 	userID := models.ClaimsFromContext(ctx).ID
 
 	response := models.CartResponse{
-		DeliveryTime:  15,
-		DeliveryPrice: 150,
-		Items:         make([]models.CartResponseItem, 0),
+		Items: make([]models.CartResponseItem, 0),
+	}
+
+	items, err := s.store.GetItems(ctx, userID)
+	if err != nil {
+		return models.CartResponse{}, fmt.Errorf("%w: can't get cart: %w", models.ErrInternalServer, err)
 	}
 
-	s.mux.RLock()
-	defer s.mux.RUnlock()
+	comments, err := s.store.GetComments(ctx, userID)
+	if err != nil {
+		return models.CartResponse{}, fmt.Errorf("%w: can't get cart comments: %w", models.ErrInternalServer, err)
+	}
 
-	if cart, ok := s.items[userID]; ok {
-		if len(cart) > 0 {
-			for _, item := range cart {
-				responseItem, err := s.getCartResponseItem(ctx, item)
-				if err != nil {
-					s.logger.Errorf("failed to get cart response item: %v", err)
+	for productID, quantity := range items {
+		if err := ctx.Err(); err != nil {
+			return models.CartResponse{}, fmt.Errorf("build cart: %w", err)
+		}
 
-					continue
-				}
+		var (
+			responseItem models.CartResponseItem
+			err          error
+		)
+
+		if comboID, ok := strings.CutPrefix(productID, models.ComboCartPrefix); ok {
+			responseItem, err = s.getComboResponseItem(ctx, comboID, quantity)
+		} else {
+			responseItem, err = s.getCartResponseItem(ctx, &models.CartItem{
+				ProductID: productID,
+				Quantity:  quantity,
+				Comment:   comments[productID],
+			})
+		}
 
-				if responseItem.Available {
-					response.OrderPrice += responseItem.Price * responseItem.Quantity
-					response.TotalItems += responseItem.Quantity
-				}
+		if err != nil {
+			return models.CartResponse{}, fmt.Errorf("build cart: %w", err)
+		}
 
-				response.Items = append(response.Items, responseItem)
-			}
+		if responseItem.Available {
+			response.OrderPrice += responseItem.LineTotal
+			response.TotalItems += responseItem.Quantity
+			response.Totals.Subtotal += responseItem.OriginalPrice.Mul(responseItem.Quantity)
+			response.Totals.Discounts += responseItem.Discount
 		}
+
+		response.Items = append(response.Items, responseItem)
+	}
+
+	if hasAddress {
+		response.AddressID = address.ID
+		response.DeliveryDistance, response.DeliveryPrice, response.DeliveryTime = s.delivery.Calculate(
+			address, response.OrderPrice,
+		)
+	} else {
+		response.DeliveryPrice, response.DeliveryTime = s.delivery.BaseDelivery(response.OrderPrice)
 	}
 
-	response.TotalPrice = response.DeliveryPrice + response.OrderPrice
+	response.TotalPrice = response.DeliveryPrice + response.OrderPrice + tip
+	response.Totals.Delivery = response.DeliveryPrice
+	response.Totals.Tip = tip
+	response.Totals.Total = response.TotalPrice
+
+	info := s.delivery.Info()
+	response.AmountToMinOrder = max(0, info.MinOrderAmount-response.OrderPrice)
+	if info.FreeDeliveryThreshold > 0 {
+		response.AmountToFreeDelivery = max(0, info.FreeDeliveryThreshold-response.OrderPrice)
+	}
 
 	return response, nil
 }
 
+// GetDeliveryInfo возвращает условия доставки
+func (s *Cart) GetDeliveryInfo() models.DeliveryInfo {
+	return s.delivery.Info()
+}
+
 func (s *Cart) AddItem(ctx context.Context, productID string) (int, error) {
+	return s.AddQuantity(ctx, productID, 1)
+}
+
+// AddQuantity добавляет в корзину несколько единиц товара и возвращает новое количество
+func (s *Cart) AddQuantity(ctx context.Context, productID string, quantity int) (int, error) {
 	userID := models.ClaimsFromContext(ctx).ID
 
+	if quantity <= 0 {
+		return 0, fmt.Errorf("%w: quantity must be positive", models.ErrBadRequest)
+	}
+
 	if !s.productService.ProductExists(productID) {
 		return 0, fmt.Errorf("%w: product %s does not exist", models.ErrNotFound, productID)
 	}
 
+	// Иначе два параллельных добавления проверят ограничение по одной и той же корзине
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	items, err := s.store.GetItems(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("%w: can't get cart: %w", models.ErrInternalServer, err)
+	}
+
+	quantities, err := s.productQuantities(ctx, items)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := s.checkQuantity(ctx, productID, quantities[productID]+quantity); err != nil {
+		return 0, err
+	}
+
+	total, err := s.store.ChangeQuantity(ctx, userID, productID, quantity)
+	if err != nil {
+		return 0, fmt.Errorf("%w: can't add item: %w", models.ErrInternalServer, err)
+	}
+
+	s.stats.CartItemChanged(userID, productID, total)
+
+	return total, nil
+}
+
+// SetQuantity задает количество товара в корзине и возвращает его. 0 убирает товар из корзины.
+func (s *Cart) SetQuantity(ctx context.Context, productID string, quantity int) (int, error) {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	if quantity < 0 {
+		return 0, fmt.Errorf("%w: quantity can't be negative", models.ErrBadRequest)
+	}
+
 	s.mux.Lock()
 	defer s.mux.Unlock()
 
-	if _, ok := s.items[userID]; !ok {
-		s.items[userID] = make(map[string]*models.CartItem)
+	items, err := s.store.GetItems(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("%w: can't get cart: %w", models.ErrInternalServer, err)
+	}
+
+	current, inCart := items[productID]
+
+	// Убрать из корзины можно и товар, удаленный из каталога
+	if !s.productService.ProductExists(productID) && (quantity > 0 || !inCart) {
+		return 0, fmt.Errorf("%w: product %s does not exist", models.ErrNotFound, productID)
 	}
 
-	if _, ok := s.items[userID][productID]; !ok {
-		s.items[userID][productID] = &models.CartItem{
-			ProductID: productID,
-			Quantity:  1,
+	// Уменьшить количество можно, даже если ограничение стало строже
+	if quantity > current {
+		quantities, err := s.productQuantities(ctx, items)
+		if err != nil {
+			return 0, err
+		}
+
+		if err := s.checkQuantity(ctx, productID, quantities[productID]-current+quantity); err != nil {
+			return 0, err
 		}
+	}
+
+	if quantity == current {
+		return quantity, nil
+	}
 
-		return 1, nil
+	if err := s.checkReserved(ctx, userID, productID, quantity); err != nil {
+		return 0, err
 	}
 
-	s.items[userID][productID].Quantity++
+	total, err := s.store.ChangeQuantity(ctx, userID, productID, quantity-current)
+	if err != nil {
+		return 0, fmt.Errorf("%w: can't set quantity: %w", models.ErrInternalServer, err)
+	}
+
+	s.stats.CartItemChanged(userID, productID, total)
+
+	if total == 0 {
+		s.dropComment(ctx, userID, productID)
+	}
+
+	return total, nil
+}
+
+// CheckQuantityLimits проверяет ограничения количества в заказе. Товары из наборов считаются вместе
+// с товарами по отдельности, поэтому ограничение не обойти через наборы.
+func (s *Cart) CheckQuantityLimits(ctx context.Context, items []models.OrderItem) error {
+	totals := make(map[string]int, len(items))
+	order := make([]string, 0, len(items))
+
+	for _, item := range items {
+		if _, ok := totals[item.ID]; !ok {
+			order = append(order, item.ID)
+		}
+
+		totals[item.ID] += item.Quantity
+	}
+
+	for _, productID := range order {
+		if err := s.checkQuantity(ctx, productID, totals[productID]); err != nil {
+			return err
+		}
+	}
 
-	return s.items[userID][productID].Quantity, nil
+	return nil
+}
+
+// productQuantities сколько единиц каждого товара в корзине вместе с товарами из наборов, как их
+// потом посчитает CheckQuantityLimits
+func (s *Cart) productQuantities(ctx context.Context, items map[string]int) (map[string]int, error) {
+	quantities := make(map[string]int, len(items))
+
+	for key, quantity := range items {
+		comboID, ok := strings.CutPrefix(key, models.ComboCartPrefix)
+		if !ok {
+			quantities[key] += quantity
+
+			continue
+		}
+
+		combo, err := s.combos.GetCombo(ctx, comboID)
+		if errors.Is(err, models.ErrNotFound) {
+			// Удаленный из каталога набор в заказ не попадет
+			continue
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("get combo %s: %w", comboID, err)
+		}
+
+		for _, item := range combo.Items {
+			quantities[item.ProductID] += item.Quantity * quantity
+		}
+	}
+
+	return quantities, nil
+}
+
+// quantityLimit сколько единиц товара можно положить в корзину, 0 - без ограничения
+func (s *Cart) quantityLimit(product models.Product) int {
+	if product.MaxQuantity > 0 && (s.maxQuantity == 0 || product.MaxQuantity < s.maxQuantity) {
+		return product.MaxQuantity
+	}
+
+	return s.maxQuantity
+}
+
+func (s *Cart) checkQuantity(ctx context.Context, productID string, quantity int) error {
+	product, err := s.productService.GetProductByID(ctx, productID)
+	if err != nil {
+		return fmt.Errorf("get product %s: %w", productID, err)
+	}
+
+	if limit := s.quantityLimit(product); limit > 0 && quantity > limit {
+		return &models.QuantityLimitError{ProductID: productID, Limit: limit, Quantity: quantity}
+	}
+
+	return nil
+}
+
+// AddCombo добавляет в корзину набор одной позицией и возвращает количество наборов. Товары набора
+// проверяются по тем же ограничениям количества, что и товары по отдельности.
+func (s *Cart) AddCombo(ctx context.Context, comboID string) (int, error) {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	combo, err := s.combos.GetCombo(ctx, comboID)
+	if err != nil {
+		return 0, err
+	}
+
+	key := models.ComboCartKey(comboID)
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	items, err := s.store.GetItems(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("%w: can't get cart: %w", models.ErrInternalServer, err)
+	}
+
+	quantities, err := s.productQuantities(ctx, items)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, item := range combo.Items {
+		if err := s.checkQuantity(ctx, item.ProductID, quantities[item.ProductID]+item.Quantity); err != nil {
+			return 0, err
+		}
+	}
+
+	total, err := s.store.ChangeQuantity(ctx, userID, key, 1)
+	if err != nil {
+		return 0, fmt.Errorf("%w: can't add combo: %w", models.ErrInternalServer, err)
+	}
+
+	s.stats.CartItemChanged(userID, key, total)
+
+	return total, nil
+}
+
+// RemoveCombo убирает из корзины один набор и возвращает, сколько наборов осталось
+func (s *Cart) RemoveCombo(ctx context.Context, comboID string) (int, error) {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	if _, err := s.combos.GetCombo(ctx, comboID); err != nil {
+		return 0, err
+	}
+
+	key := models.ComboCartKey(comboID)
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if err := s.checkDecrease(ctx, userID, key, 1); err != nil {
+		return 0, err
+	}
+
+	quantity, err := s.store.ChangeQuantity(ctx, userID, key, -1)
+	if err != nil {
+		return 0, fmt.Errorf("%w: can't remove combo: %w", models.ErrInternalServer, err)
+	}
+
+	s.stats.CartItemChanged(userID, key, quantity)
+
+	return quantity, nil
 }
 
 func (s *Cart) RemoveItem(ctx context.Context, productID string) (int, error) {
@@ -107,43 +447,262 @@ func (s *Cart) RemoveItem(ctx context.Context, productID string) (int, error) {
 	s.mux.Lock()
 	defer s.mux.Unlock()
 
-	if _, ok := s.items[userID]; !ok {
-		s.items[userID] = make(map[string]*models.CartItem)
+	if err := s.checkDecrease(ctx, userID, productID, 1); err != nil {
+		return 0, err
+	}
+
+	quantity, err := s.store.ChangeQuantity(ctx, userID, productID, -1)
+	if err != nil {
+		return 0, fmt.Errorf("%w: can't remove item: %w", models.ErrInternalServer, err)
+	}
+
+	s.stats.CartItemChanged(userID, productID, quantity)
+
+	if quantity == 0 {
+		s.dropComment(ctx, userID, productID)
+	}
+
+	return quantity, nil
+}
+
+// SetItemComment меняет комментарий к товару в корзине, пустой комментарий удаляет его
+func (s *Cart) SetItemComment(ctx context.Context, productID, comment string) (models.CartResponseItem, error) {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	comment = strings.TrimSpace(comment)
+	if utf8.RuneCountInString(comment) > models.MaxCommentLength {
+		return models.CartResponseItem{}, fmt.Errorf("%w: comment is longer than %d characters",
+			models.ErrBadRequest, models.MaxCommentLength)
 	}
 
-	if _, ok := s.items[userID][productID]; !ok {
-		return 0, nil
+	items, err := s.store.GetItems(ctx, userID)
+	if err != nil {
+		return models.CartResponseItem{}, fmt.Errorf("%w: can't get cart: %w", models.ErrInternalServer, err)
 	}
 
-	s.items[userID][productID].Quantity--
-	if s.items[userID][productID].Quantity <= 0 {
-		delete(s.items[userID], productID)
+	quantity, ok := items[productID]
+	if !ok {
+		return models.CartResponseItem{}, fmt.Errorf("%w: product %s is not in the cart", models.ErrNotFound, productID)
+	}
 
-		return 0, nil
+	if err := s.store.SetComment(ctx, userID, productID, comment); err != nil {
+		return models.CartResponseItem{}, fmt.Errorf("%w: can't set comment: %w", models.ErrInternalServer, err)
 	}
 
-	return s.items[userID][productID].Quantity, nil
+	return s.getCartResponseItem(ctx, &models.CartItem{ProductID: productID, Quantity: quantity, Comment: comment})
+}
 
+// dropComment удаляет комментарий к товару, которого больше нет в корзине
+func (s *Cart) dropComment(ctx context.Context, userID, productID string) {
+	if err := s.store.SetComment(ctx, userID, productID, ""); err != nil {
+		s.logger.Errorf("failed to remove comment of %s from cart of %s: %v", productID, userID, err)
+	}
 }
 
-func (s *Cart) ClearCart(ctx context.Context) {
+// Cleanup удаляет из корзины товары, которые нельзя заказать: удаленные из каталога и отсутствующие в наличии.
+func (s *Cart) Cleanup(ctx context.Context) (models.CartCleanupResult, error) {
+	cart, err := s.GetCart(ctx)
+	if err != nil {
+		return models.CartCleanupResult{}, err
+	}
+
 	userID := models.ClaimsFromContext(ctx).ID
+	result := models.CartCleanupResult{Removed: make([]models.CartResponseItem, 0)}
 
 	s.mux.Lock()
 	defer s.mux.Unlock()
 
-	delete(s.items, userID)
+	for _, item := range cart.Items {
+		if item.Available {
+			continue
+		}
+
+		key := cartKey(item)
+
+		if err := s.checkReserved(ctx, userID, key, 0); err != nil {
+			return models.CartCleanupResult{}, err
+		}
+
+		quantity, err := s.store.ChangeQuantity(ctx, userID, key, -item.Quantity)
+		if err != nil {
+			return models.CartCleanupResult{}, fmt.Errorf("%w: can't remove item: %w", models.ErrInternalServer, err)
+		}
+
+		s.stats.CartItemChanged(userID, key, quantity)
+		s.dropComment(ctx, userID, key)
+
+		result.Removed = append(result.Removed, item)
+	}
+
+	return result, nil
+}
+
+// ReserveItems резервирует позиции корзины под оформляемый заказ. Пока резерв не снят, второй заказ
+// из той же корзины оформить нельзя, а зарезервированные позиции нельзя уменьшить или убрать.
+// Остатков в каталоге нет, поэтому резерв проверяет, что товары и наборы все еще в наличии.
+func (s *Cart) ReserveItems(ctx context.Context, items []models.OrderItem) error {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	for _, item := range items {
+		if err := s.checkInStock(ctx, item); err != nil {
+			return err
+		}
+	}
+
+	reserved := make(map[string]int, len(items))
+	for _, item := range items {
+		reserved[item.ID] += item.Quantity
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	ok, err := s.store.Reserve(ctx, userID, reserved, cartReservationTTL)
+	if err != nil {
+		return fmt.Errorf("%w: can't reserve cart: %w", models.ErrInternalServer, err)
+	}
+
+	if !ok {
+		return fmt.Errorf("%w: another order is being placed from this cart", models.ErrConflict)
+	}
+
+	return nil
+}
+
+// checkInStock проверяет, что позицию заказа все еще можно купить
+func (s *Cart) checkInStock(ctx context.Context, item models.OrderItem) error {
+	var (
+		line models.CartResponseItem
+		err  error
+	)
+
+	if comboID, ok := strings.CutPrefix(item.ID, models.ComboCartPrefix); ok {
+		line, err = s.getComboResponseItem(ctx, comboID, item.Quantity)
+	} else {
+		line, err = s.getCartResponseItem(ctx, &models.CartItem{ProductID: item.ID, Quantity: item.Quantity})
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if !line.Available {
+		return fmt.Errorf("%w: %s is no longer available", models.ErrConflict, item.ID)
+	}
+
+	return nil
+}
+
+// checkDecrease проверяет, что позицию key можно уменьшить на decrease. Вызывается под s.mux.
+func (s *Cart) checkDecrease(ctx context.Context, userID, key string, decrease int) error {
+	reserved, err := s.reservedQuantity(ctx, userID, key)
+	if err != nil || reserved == 0 {
+		return err
+	}
+
+	items, err := s.store.GetItems(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("%w: can't get cart: %w", models.ErrInternalServer, err)
+	}
+
+	return s.checkReserved(ctx, userID, key, items[key]-decrease)
+}
+
+// checkReserved проверяет, что после изменения в позиции key останется не меньше зарезервированного.
+// Добавленное во время оформления сверх резерва менять можно. Вызывается под s.mux.
+func (s *Cart) checkReserved(ctx context.Context, userID, key string, left int) error {
+	reserved, err := s.reservedQuantity(ctx, userID, key)
+	if err != nil {
+		return err
+	}
+
+	if left < reserved {
+		return fmt.Errorf("%w: %s is reserved by the order being placed", models.ErrConflict, key)
+	}
+
+	return nil
+}
+
+func (s *Cart) reservedQuantity(ctx context.Context, userID, key string) (int, error) {
+	reservation, err := s.store.GetReservation(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("%w: can't get cart reservation: %w", models.ErrInternalServer, err)
+	}
+
+	return reservation[key], nil
+}
+
+// ReleaseItems снимает резерв, не меняя корзину. Используется, когда заказ не удалось оформить.
+func (s *Cart) ReleaseItems(ctx context.Context) {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if err := s.store.ReleaseReservation(ctx, userID); err != nil {
+		s.logger.Errorf("failed to release cart reservation of %s: %v", userID, err)
+	}
+}
+
+// CheckoutItems убирает из корзины зарезервированные позиции оформленного заказа и снимает резерв.
+// Товары, добавленные во время оформления, и недоступные товары остаются в корзине.
+func (s *Cart) CheckoutItems(ctx context.Context) error {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	reserved, err := s.store.GetReservation(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("%w: can't get cart reservation: %w", models.ErrInternalServer, err)
+	}
+
+	if reserved == nil {
+		return fmt.Errorf("%w: cart items are not reserved", models.ErrInternalServer)
+	}
+
+	for productID, quantity := range reserved {
+		left, err := s.store.ChangeQuantity(ctx, userID, productID, -quantity)
+		if err != nil {
+			return fmt.Errorf("%w: can't remove ordered item: %w", models.ErrInternalServer, err)
+		}
+
+		// Позиция уже убрана из резерва, повторная попытка ее не спишет
+		delete(reserved, productID)
+
+		if err := s.store.UpdateReservation(ctx, userID, reserved); err != nil {
+			return fmt.Errorf("%w: can't update cart reservation: %w", models.ErrInternalServer, err)
+		}
+
+		s.stats.CartItemChanged(userID, productID, left)
+
+		if left == 0 {
+			s.dropComment(ctx, userID, productID)
+		}
+	}
+
+	if err := s.store.ReleaseReservation(ctx, userID); err != nil {
+		return fmt.Errorf("%w: can't release cart reservation: %w", models.ErrInternalServer, err)
+	}
 
-	return
+	return nil
 }
 
 func (s *Cart) getCartResponseItem(ctx context.Context, item *models.CartItem) (models.CartResponseItem, error) {
 	result := models.CartResponseItem{
 		ProductID: item.ProductID,
 		Quantity:  item.Quantity,
+		Comment:   item.Comment,
 	}
 
 	product, err := s.productService.GetProductByID(ctx, item.ProductID)
+	if errors.Is(err, models.ErrNotFound) {
+		// Товар остается в корзине, пока пользователь его не уберет, чтобы клиент мог объяснить, куда он делся
+		result.UnavailableReason = models.UnavailableRemovedFromCatalog
+
+		return result, nil
+	}
+
 	if err != nil {
 		return models.CartResponseItem{}, fmt.Errorf("failed to get product by id: %w", err)
 	}
@@ -151,35 +710,207 @@ func (s *Cart) getCartResponseItem(ctx context.Context, item *models.CartItem) (
 	result.Name = product.Name
 	result.Weight = product.Weight
 	result.Price = product.Price
+	result.OriginalPrice = product.OriginalPrice()
+	result.LineTotal = product.Price.Mul(item.Quantity)
+	result.Discount = (result.OriginalPrice - product.Price).Mul(item.Quantity)
 	result.Available = product.Available
 	result.Image = product.Image
+	result.MaxQuantity = s.quantityLimit(product)
+
+	if !product.Available {
+		result.UnavailableReason = models.UnavailableOutOfStock
+	}
+
+	// Закрытый магазин не мешает оформить заказ к открытию, а товар вне своих часов не заказать
+	if product.UnavailableReason == models.UnavailableOutsideHours {
+		result.Available = false
+		result.UnavailableReason = models.UnavailableOutsideHours
+	}
+
+	return result, nil
+}
+
+// getComboResponseItem позиция корзины с набором: цены набора и его товары
+func (s *Cart) getComboResponseItem(ctx context.Context, comboID string, quantity int) (models.CartResponseItem, error) {
+	result := models.CartResponseItem{
+		ProductID: comboID,
+		ComboID:   comboID,
+		Quantity:  quantity,
+	}
+
+	combo, err := s.combos.GetCombo(ctx, comboID)
+	if errors.Is(err, models.ErrNotFound) {
+		result.UnavailableReason = models.UnavailableRemovedFromCatalog
+
+		return result, nil
+	}
+
+	if err != nil {
+		return models.CartResponseItem{}, fmt.Errorf("failed to get combo by id: %w", err)
+	}
+
+	result.Name = combo.Name
+	result.Image = combo.Image
+	result.Weight = combo.Weight
+	result.Price = combo.Price
+	result.OriginalPrice = combo.OriginalPrice
+	result.LineTotal = combo.Price.Mul(quantity)
+	result.Discount = (combo.OriginalPrice - combo.Price).Mul(quantity)
+	result.Available = combo.Available
+	result.UnavailableReason = combo.UnavailableReason
+	result.Components = combo.Items
 
 	return result, nil
 }
 
 // GetBackupData возвращает данные для бэкапа
 func (s *Cart) GetBackupData() interface{} {
-	s.mux.RLock()
-	defer s.mux.RUnlock()
-
-	// Создаем копию данных для бэкапа
-	backupData := make(map[string]map[string]*models.CartItem)
-	for userID, cart := range s.items {
-		backupCart := make(map[string]*models.CartItem)
-		for productID, item := range cart {
-			backupItem := &models.CartItem{
-				ProductID: item.ProductID,
-				Quantity:  item.Quantity,
+	carts, err := s.store.GetAll(context.Background())
+	if err != nil {
+		s.logger.Errorf("failed to get carts for backup: %v", err)
+
+		return nil
+	}
+
+	comments, err := s.store.GetAllComments(context.Background())
+	if err != nil {
+		s.logger.Errorf("failed to get cart comments for backup: %v", err)
+
+		return nil
+	}
+
+	result := make(map[string]map[string]*models.CartItem, len(carts))
+	for userID, items := range carts {
+		result[userID] = make(map[string]*models.CartItem, len(items))
+		for productID, quantity := range items {
+			result[userID][productID] = &models.CartItem{
+				ProductID: productID,
+				Quantity:  quantity,
+				Comment:   comments[userID][productID],
 			}
-			backupCart[productID] = backupItem
 		}
-		backupData[userID] = backupCart
 	}
 
-	return backupData
+	return result
+}
+
+// ResetUser возвращает корзину пользователя к исходному состоянию
+func (s *Cart) ResetUser(userID string) {
+	items := cartQuantities(s.seed)[userID]
+
+	// Резерв старого оформления иначе блокировал бы изменения новой корзины
+	if err := s.store.ReleaseReservation(context.Background(), userID); err != nil {
+		s.logger.Errorf("failed to release cart reservation of %s: %v", userID, err)
+	}
+
+	if err := s.store.SetItems(context.Background(), userID, items); err != nil {
+		s.logger.Errorf("failed to reset cart of %s: %v", userID, err)
+
+		return
+	}
+
+	if err := s.store.SetComments(context.Background(), userID, cartComments(s.seed)[userID]); err != nil {
+		s.logger.Errorf("failed to reset cart comments of %s: %v", userID, err)
+	}
+
+	s.stats.CartReplaced(userID, items)
+}
+
+func copyCarts(carts map[string]map[string]*models.CartItem) map[string]map[string]*models.CartItem {
+	result := make(map[string]map[string]*models.CartItem, len(carts))
+	for userID, cart := range carts {
+		result[userID] = copyCart(cart)
+	}
+
+	return result
+}
+
+func copyCart(cart map[string]*models.CartItem) map[string]*models.CartItem {
+	result := make(map[string]*models.CartItem, len(cart))
+	for productID, item := range cart {
+		result[productID] = &models.CartItem{
+			ProductID: item.ProductID,
+			Quantity:  item.Quantity,
+			Comment:   item.Comment,
+		}
+	}
+
+	return result
 }
 
 // GetBackupFileName возвращает имя файла для бэкапа
 func (s *Cart) GetBackupFileName() string {
 	return "cart_items"
 }
+
+// RestoreBackupData заменяет корзины данными из бэкапа. Корзины пользователей, которых нет в бэкапе, очищаются.
+func (s *Cart) RestoreBackupData(data []byte) error {
+	var backup map[string]map[string]*models.CartItem
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return fmt.Errorf("can't parse carts: %w", err)
+	}
+
+	ctx := context.Background()
+
+	current, err := s.store.GetAll(ctx)
+	if err != nil {
+		return fmt.Errorf("can't get carts: %w", err)
+	}
+
+	carts := cartQuantities(backup)
+	for userID := range current {
+		if _, ok := carts[userID]; !ok {
+			carts[userID] = nil
+		}
+	}
+
+	comments := cartComments(backup)
+
+	// Резервы относятся к заменяемым корзинам
+	if err := s.store.ReleaseAllReservations(ctx); err != nil {
+		return fmt.Errorf("can't release cart reservations: %w", err)
+	}
+
+	for userID, items := range carts {
+		if err := s.store.SetItems(ctx, userID, items); err != nil {
+			return fmt.Errorf("can't restore cart of %s: %w", userID, err)
+		}
+
+		if err := s.store.SetComments(ctx, userID, comments[userID]); err != nil {
+			return fmt.Errorf("can't restore cart comments of %s: %w", userID, err)
+		}
+
+		s.stats.CartReplaced(userID, items)
+	}
+
+	return nil
+}
+
+// CollectionSizes возвращает число корзин и позиций в них. Для Redis это размеры внешнего хранилища.
+func (s *Cart) CollectionSizes(ctx context.Context) map[string]int {
+	carts, err := s.store.GetAll(ctx)
+	if err != nil {
+		s.logger.Errorf("failed to get carts for diagnostics: %v", err)
+
+		return nil
+	}
+
+	items := 0
+	for _, cart := range carts {
+		items += len(cart)
+	}
+
+	return map[string]int{
+		"carts":       len(carts),
+		"carts.items": items,
+	}
+}
+
+// ExportUserData возвращает корзину пользователя
+func (s *Cart) ExportUserData(ctx context.Context) (any, error) {
+	return s.GetCart(ctx)
+}
+
+func (s *Cart) GetUserExportName() string {
+	return "cart"
+}
@@ -0,0 +1,270 @@
+package service
+
+import (
+	"context"
+	"maps"
+	"sync"
+	"time"
+
+	"eats-backend/internal/models"
+)
+
+// CartStore хранилище содержимого корзин. Позволяет держать корзины вне процесса,
+// чтобы запускать несколько экземпляров сервера.
+type CartStore interface {
+	// GetItems возвращает корзину пользователя: productID -> количество.
+	GetItems(ctx context.Context, userID string) (map[string]int, error)
+	// ChangeQuantity меняет количество товара на delta и возвращает новое.
+	// Товар, количество которого стало нулевым, удаляется из корзины.
+	ChangeQuantity(ctx context.Context, userID, productID string, delta int) (int, error)
+	// SetItems заменяет корзину пользователя целиком, пустая корзина удаляется.
+	SetItems(ctx context.Context, userID string, items map[string]int) error
+	// GetAll возвращает корзины всех пользователей для бэкапа.
+	GetAll(ctx context.Context) (map[string]map[string]int, error)
+
+	// GetComments возвращает комментарии к товарам корзины: productID -> комментарий.
+	GetComments(ctx context.Context, userID string) (map[string]string, error)
+	// SetComment меняет комментарий к товару, пустой комментарий удаляется.
+	SetComment(ctx context.Context, userID, productID, comment string) error
+	// SetComments заменяет все комментарии корзины пользователя.
+	SetComments(ctx context.Context, userID string, comments map[string]string) error
+	// GetAllComments возвращает комментарии всех корзин для бэкапа.
+	GetAllComments(ctx context.Context) (map[string]map[string]string, error)
+
+	// Reserve сохраняет резерв позиций корзины на ttl, если корзина еще не зарезервирована.
+	// false - резерв уже есть.
+	Reserve(ctx context.Context, userID string, items map[string]int, ttl time.Duration) (bool, error)
+	// GetReservation возвращает зарезервированные позиции, nil - если резерва нет.
+	GetReservation(ctx context.Context, userID string) (map[string]int, error)
+	// UpdateReservation заменяет позиции существующего резерва, не продлевая его.
+	UpdateReservation(ctx context.Context, userID string, items map[string]int) error
+	// ReleaseReservation снимает резерв корзины.
+	ReleaseReservation(ctx context.Context, userID string) error
+	// ReleaseAllReservations снимает резервы всех корзин.
+	ReleaseAllReservations(ctx context.Context) error
+}
+
+// MemoryCartStore хранит корзины в памяти процесса.
+type MemoryCartStore struct {
+	items        map[string]map[string]int
+	comments     map[string]map[string]string
+	reservations map[string]memoryReservation
+
+	mux sync.RWMutex
+}
+
+type memoryReservation struct {
+	items     map[string]int
+	expiresAt time.Time
+}
+
+func NewMemoryCartStore(carts map[string]map[string]*models.CartItem) *MemoryCartStore {
+	return &MemoryCartStore{
+		items:        cartQuantities(carts),
+		comments:     cartComments(carts),
+		reservations: make(map[string]memoryReservation),
+	}
+}
+
+func (s *MemoryCartStore) GetItems(_ context.Context, userID string) (map[string]int, error) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	return maps.Clone(s.items[userID]), nil
+}
+
+func (s *MemoryCartStore) ChangeQuantity(_ context.Context, userID, productID string, delta int) (int, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	quantity := s.items[userID][productID] + delta
+	if quantity <= 0 {
+		delete(s.items[userID], productID)
+
+		return 0, nil
+	}
+
+	if _, ok := s.items[userID]; !ok {
+		s.items[userID] = make(map[string]int)
+	}
+
+	s.items[userID][productID] = quantity
+
+	return quantity, nil
+}
+
+func (s *MemoryCartStore) SetItems(_ context.Context, userID string, items map[string]int) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if len(items) == 0 {
+		delete(s.items, userID)
+
+		return nil
+	}
+
+	s.items[userID] = maps.Clone(items)
+
+	return nil
+}
+
+func (s *MemoryCartStore) GetAll(_ context.Context) (map[string]map[string]int, error) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	result := make(map[string]map[string]int, len(s.items))
+	for userID, items := range s.items {
+		result[userID] = maps.Clone(items)
+	}
+
+	return result, nil
+}
+
+func (s *MemoryCartStore) GetComments(_ context.Context, userID string) (map[string]string, error) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	return maps.Clone(s.comments[userID]), nil
+}
+
+func (s *MemoryCartStore) SetComment(_ context.Context, userID, productID, comment string) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if comment == "" {
+		delete(s.comments[userID], productID)
+
+		return nil
+	}
+
+	if _, ok := s.comments[userID]; !ok {
+		s.comments[userID] = make(map[string]string)
+	}
+
+	s.comments[userID][productID] = comment
+
+	return nil
+}
+
+func (s *MemoryCartStore) SetComments(_ context.Context, userID string, comments map[string]string) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if len(comments) == 0 {
+		delete(s.comments, userID)
+
+		return nil
+	}
+
+	s.comments[userID] = maps.Clone(comments)
+
+	return nil
+}
+
+func (s *MemoryCartStore) GetAllComments(_ context.Context) (map[string]map[string]string, error) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	result := make(map[string]map[string]string, len(s.comments))
+	for userID, comments := range s.comments {
+		result[userID] = maps.Clone(comments)
+	}
+
+	return result, nil
+}
+
+func (s *MemoryCartStore) Reserve(_ context.Context, userID string, items map[string]int, ttl time.Duration) (bool, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if reservation, ok := s.reservations[userID]; ok && time.Now().Before(reservation.expiresAt) {
+		return false, nil
+	}
+
+	s.reservations[userID] = memoryReservation{items: cloneReservation(items), expiresAt: time.Now().Add(ttl)}
+
+	return true, nil
+}
+
+func (s *MemoryCartStore) GetReservation(_ context.Context, userID string) (map[string]int, error) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	reservation, ok := s.reservations[userID]
+	if !ok || !time.Now().Before(reservation.expiresAt) {
+		return nil, nil
+	}
+
+	return cloneReservation(reservation.items), nil
+}
+
+func (s *MemoryCartStore) UpdateReservation(_ context.Context, userID string, items map[string]int) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if reservation, ok := s.reservations[userID]; ok {
+		reservation.items = cloneReservation(items)
+		s.reservations[userID] = reservation
+	}
+
+	return nil
+}
+
+func (s *MemoryCartStore) ReleaseReservation(_ context.Context, userID string) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	delete(s.reservations, userID)
+
+	return nil
+}
+
+func (s *MemoryCartStore) ReleaseAllReservations(_ context.Context) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	clear(s.reservations)
+
+	return nil
+}
+
+// cloneReservation копирует позиции резерва. Пустой резерв остается не nil, чтобы отличаться от его отсутствия.
+func cloneReservation(items map[string]int) map[string]int {
+	result := make(map[string]int, len(items))
+	maps.Copy(result, items)
+
+	return result
+}
+
+// cartQuantities переводит корзины из формата файла данных в productID -> количество
+func cartQuantities(carts map[string]map[string]*models.CartItem) map[string]map[string]int {
+	result := make(map[string]map[string]int, len(carts))
+	for userID, cart := range carts {
+		result[userID] = make(map[string]int, len(cart))
+		for productID, item := range cart {
+			result[userID][productID] = item.Quantity
+		}
+	}
+
+	return result
+}
+
+// cartComments достает из корзин в формате файла данных комментарии к товарам
+func cartComments(carts map[string]map[string]*models.CartItem) map[string]map[string]string {
+	result := make(map[string]map[string]string)
+	for userID, cart := range carts {
+		for productID, item := range cart {
+			if item.Comment == "" {
+				continue
+			}
+
+			if _, ok := result[userID]; !ok {
+				result[userID] = make(map[string]string)
+			}
+
+			result[userID][productID] = item.Comment
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,379 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"eats-backend/internal/models"
+	"eats-backend/internal/service"
+)
+
+type testCartProducts map[string]models.Product
+
+func (p testCartProducts) GetProductByID(_ context.Context, id string) (models.Product, error) {
+	product, ok := p[id]
+	if !ok {
+		return models.Product{}, fmt.Errorf("%w: product %s", models.ErrNotFound, id)
+	}
+
+	return product, nil
+}
+
+func (p testCartProducts) ProductExists(id string) bool {
+	_, ok := p[id]
+
+	return ok
+}
+
+// testCartDelivery берет фиксированную цену доставки без учета адреса
+type testCartDelivery struct{ price models.Money }
+
+func (d testCartDelivery) BaseDelivery(models.Money) (models.Money, int) { return d.price, 30 }
+
+func (d testCartDelivery) Calculate(models.Address, models.Money) (float64, models.Money, int) {
+	return 1, d.price, 30
+}
+
+func (d testCartDelivery) Info() models.DeliveryInfo { return models.DeliveryInfo{} }
+
+type testCartAddresses struct{}
+
+func (testCartAddresses) GetAddressByID(context.Context, string) (models.Address, error) {
+	return models.Address{}, models.ErrNotFound
+}
+
+func (testCartAddresses) GetCurrentAddress(context.Context) (models.Address, bool) {
+	return models.Address{}, false
+}
+
+type testCartStats struct{}
+
+func (testCartStats) CartItemChanged(string, string, int) {}
+
+func (testCartStats) CartReplaced(string, map[string]int) {}
+
+func TestCart_GetCartForAddressTotals(t *testing.T) {
+	products := testCartProducts{
+		"bread": {ID: "bread", Price: models.Rubles(80), Discount: 20, Available: true},
+		"milk":  {ID: "milk", Price: models.Rubles(90), Available: true},
+		"cake":  {ID: "cake", Price: models.Rubles(500), Discount: 50, Available: false},
+	}
+
+	carts := map[string]map[string]*models.CartItem{
+		"user-1": {
+			"bread": {ProductID: "bread", Quantity: 3},
+			"milk":  {ProductID: "milk", Quantity: 1},
+			"cake":  {ProductID: "cake", Quantity: 1},
+		},
+	}
+
+	cart := service.NewCart(products, service.NewMemoryCartStore(carts), testCartDelivery{price: models.Rubles(150)},
+		testCartAddresses{}, testCartStats{}, nil, 0, zap.NewNop().Sugar(), nil)
+
+	ctx := models.ContextWithUser(t.Context(), "user-1")
+
+	response, err := cart.GetCartForAddress(ctx, "", models.Rubles(40))
+	require.NoError(t, err)
+
+	items := make(map[string]models.CartResponseItem, len(response.Items))
+	for _, item := range response.Items {
+		items[item.ProductID] = item
+	}
+
+	// Цена в каталоге уже со скидкой: 80 ₽ при скидке 20% - это 100 ₽ без нее
+	require.Equal(t, models.Rubles(100), items["bread"].OriginalPrice)
+	require.Equal(t, models.Rubles(60), items["bread"].Discount)
+	require.Equal(t, models.Rubles(240), items["bread"].LineTotal)
+	require.Zero(t, items["milk"].Discount)
+
+	// Недоступный товар в итог не входит
+	require.Equal(t, models.CartTotals{
+		Subtotal:  models.Rubles(390),
+		Discounts: models.Rubles(60),
+		Delivery:  models.Rubles(150),
+		Tip:       models.Rubles(40),
+		Total:     models.Rubles(520),
+	}, response.Totals)
+	require.Equal(t, models.Rubles(330), response.OrderPrice)
+	require.Equal(t, response.Totals.Total, response.TotalPrice)
+
+	_, err = cart.GetCartForAddress(ctx, "", -1)
+	require.ErrorIs(t, err, models.ErrBadRequest)
+}
+
+func TestCart_CheckoutItems(t *testing.T) {
+	products := testCartProducts{
+		"bread": {ID: "bread", Price: models.Rubles(80), Available: true},
+		"milk":  {ID: "milk", Price: models.Rubles(90), Available: true},
+	}
+
+	carts := map[string]map[string]*models.CartItem{
+		"user-1": {"bread": {ProductID: "bread", Quantity: 2}},
+	}
+
+	cart := service.NewCart(products, service.NewMemoryCartStore(carts), testCartDelivery{},
+		testCartAddresses{}, testCartStats{}, nil, 0, zap.NewNop().Sugar(), nil)
+
+	ctx := models.ContextWithUser(t.Context(), "user-1")
+
+	require.NoError(t, cart.ReserveItems(ctx, []models.OrderItem{{ID: "bread", Quantity: 2}}))
+	require.ErrorIs(t, cart.ReserveItems(ctx, nil), models.ErrConflict)
+
+	// Товары, добавленные во время оформления, остаются в корзине
+	_, err := cart.AddItem(ctx, "bread")
+	require.NoError(t, err)
+	_, err = cart.AddItem(ctx, "milk")
+	require.NoError(t, err)
+
+	require.NoError(t, cart.CheckoutItems(ctx))
+
+	response, err := cart.GetCart(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, response.TotalItems)
+	require.Len(t, response.Items, 2)
+
+	require.NoError(t, cart.ReserveItems(ctx, nil))
+}
+
+func TestCart_ReservedItems(t *testing.T) {
+	products := testCartProducts{
+		"bread": {ID: "bread", Price: models.Rubles(80), Available: true},
+		"milk":  {ID: "milk", Price: models.Rubles(90), Available: true},
+		"cake":  {ID: "cake", Price: models.Rubles(500)},
+	}
+
+	carts := map[string]map[string]*models.CartItem{
+		"user-1": {
+			"bread": {ProductID: "bread", Quantity: 2},
+			"milk":  {ProductID: "milk", Quantity: 1},
+		},
+	}
+
+	cart := service.NewCart(products, service.NewMemoryCartStore(carts), testCartDelivery{},
+		testCartAddresses{}, testCartStats{}, nil, 0, zap.NewNop().Sugar(), nil)
+
+	ctx := models.ContextWithUser(t.Context(), "user-1")
+
+	// Товар, который закончился после расчета корзины, не резервируется
+	err := cart.ReserveItems(ctx, []models.OrderItem{{ID: "bread", Quantity: 2}, {ID: "cake", Quantity: 1}})
+	require.ErrorIs(t, err, models.ErrConflict)
+	require.NoError(t, cart.ReserveItems(ctx, []models.OrderItem{{ID: "bread", Quantity: 2}}))
+
+	// Зарезервированное нельзя уменьшить или убрать, пока заказ оформляется
+	_, err = cart.RemoveItem(ctx, "bread")
+	require.ErrorIs(t, err, models.ErrConflict)
+
+	_, err = cart.SetQuantity(ctx, "bread", 0)
+	require.ErrorIs(t, err, models.ErrConflict)
+
+	// Добавленное сверх резерва и позиции вне резерва менять можно
+	_, err = cart.AddItem(ctx, "bread")
+	require.NoError(t, err)
+
+	quantity, err := cart.RemoveItem(ctx, "bread")
+	require.NoError(t, err)
+	require.Equal(t, 2, quantity)
+
+	quantity, err = cart.SetQuantity(ctx, "milk", 0)
+	require.NoError(t, err)
+	require.Zero(t, quantity)
+
+	// Товар пропал из наличия во время оформления: очистка не трогает резерв
+	products["bread"] = models.Product{ID: "bread", Price: models.Rubles(80)}
+
+	_, err = cart.Cleanup(ctx)
+	require.ErrorIs(t, err, models.ErrConflict)
+
+	cart.ReleaseItems(ctx)
+
+	result, err := cart.Cleanup(ctx)
+	require.NoError(t, err)
+	require.Len(t, result.Removed, 1)
+
+	quantity, err = cart.SetQuantity(ctx, "milk", 1)
+	require.NoError(t, err)
+	require.Equal(t, 1, quantity)
+}
+
+func TestCart_ReservationsInStore(t *testing.T) {
+	products := testCartProducts{"bread": {ID: "bread", Price: models.Rubles(80), Available: true}}
+	store := service.NewMemoryCartStore(map[string]map[string]*models.CartItem{
+		"user-1": {"bread": {ProductID: "bread", Quantity: 2}},
+	})
+
+	// Два экземпляра сервера с общим хранилищем корзин
+	first := service.NewCart(products, store, testCartDelivery{}, testCartAddresses{}, testCartStats{}, nil, 0,
+		zap.NewNop().Sugar(), nil)
+	second := service.NewCart(products, store, testCartDelivery{}, testCartAddresses{}, testCartStats{}, nil, 0,
+		zap.NewNop().Sugar(), nil)
+
+	ctx := models.ContextWithUser(t.Context(), "user-1")
+
+	require.NoError(t, first.ReserveItems(ctx, []models.OrderItem{{ID: "bread", Quantity: 2}}))
+	require.ErrorIs(t, second.ReserveItems(ctx, nil), models.ErrConflict)
+
+	_, err := second.RemoveItem(ctx, "bread")
+	require.ErrorIs(t, err, models.ErrConflict)
+
+	// Заказ оформил другой экземпляр
+	require.NoError(t, second.CheckoutItems(ctx))
+
+	response, err := first.GetCart(ctx)
+	require.NoError(t, err)
+	require.Empty(t, response.Items)
+
+	// Сброс пользователя снимает резерв
+	require.NoError(t, first.ReserveItems(ctx, nil))
+	second.ResetUser("user-1")
+	require.NoError(t, first.ReserveItems(ctx, nil))
+
+	// Восстановление из бэкапа тоже
+	require.NoError(t, second.RestoreBackupData([]byte(`{"user-1": {"bread": {"productId": "bread", "quantity": 3}}}`)))
+
+	quantity, err := first.RemoveItem(ctx, "bread")
+	require.NoError(t, err)
+	require.Equal(t, 2, quantity)
+	require.NoError(t, first.ReserveItems(ctx, nil))
+}
+
+func TestCart_QuantityLimits(t *testing.T) {
+	products := testCartProducts{
+		"bread": {ID: "bread", Price: models.Rubles(80), Available: true},
+		"promo": {ID: "promo", Price: models.Rubles(10), Available: true, MaxQuantity: 2},
+	}
+
+	carts := map[string]map[string]*models.CartItem{
+		"user-1": {"bread": {ProductID: "bread", Quantity: 4}},
+	}
+
+	cart := service.NewCart(products, service.NewMemoryCartStore(carts), testCartDelivery{},
+		testCartAddresses{}, testCartStats{}, nil, 5, zap.NewNop().Sugar(), nil)
+
+	ctx := models.ContextWithUser(t.Context(), "user-1")
+
+	total, err := cart.AddItem(ctx, "bread")
+	require.NoError(t, err)
+	require.Equal(t, 5, total)
+
+	// Общее ограничение
+	_, err = cart.AddItem(ctx, "bread")
+
+	var limitErr *models.QuantityLimitError
+	require.ErrorAs(t, err, &limitErr)
+	require.Equal(t, models.QuantityLimitError{ProductID: "bread", Limit: 5, Quantity: 6}, *limitErr)
+	require.ErrorIs(t, err, models.ErrBadRequest)
+
+	// Ограничение товара строже общего
+	_, err = cart.SetQuantity(ctx, "promo", 3)
+	require.ErrorAs(t, err, &limitErr)
+	require.Equal(t, 2, limitErr.Limit)
+
+	total, err = cart.SetQuantity(ctx, "promo", 2)
+	require.NoError(t, err)
+	require.Equal(t, 2, total)
+
+	response, err := cart.GetCart(ctx)
+	require.NoError(t, err)
+
+	for _, item := range response.Items {
+		require.Equal(t, map[string]int{"bread": 5, "promo": 2}[item.ProductID], item.MaxQuantity)
+	}
+
+	total, err = cart.SetQuantity(ctx, "promo", 0)
+	require.NoError(t, err)
+	require.Zero(t, total)
+
+	// При оформлении товар из наборов считается вместе с товаром по отдельности
+	err = cart.CheckQuantityLimits(ctx, []models.OrderItem{
+		{ID: "promo", Quantity: 1},
+		{ID: "promo", Quantity: 2, ComboID: "breakfast"},
+	})
+	require.ErrorAs(t, err, &limitErr)
+	require.Equal(t, 3, limitErr.Quantity)
+
+	require.NoError(t, cart.CheckQuantityLimits(ctx, []models.OrderItem{{ID: "bread", Quantity: 5}}))
+}
+
+func TestCart_ComboQuantityLimits(t *testing.T) {
+	products := testCartProducts{
+		"bread": {ID: "bread", Price: models.Rubles(80), Available: true},
+		"promo": {ID: "promo", Price: models.Rubles(10), Available: true, MaxQuantity: 3},
+	}
+	combos := service.NewComboService(products, []models.Combo{
+		{ID: "breakfast", Items: []models.ComboItem{{ProductID: "promo", Quantity: 2}, {ProductID: "bread", Quantity: 1}}},
+	})
+
+	cart := service.NewCart(products, service.NewMemoryCartStore(nil), testCartDelivery{},
+		testCartAddresses{}, testCartStats{}, combos, 5, zap.NewNop().Sugar(), nil)
+
+	ctx := models.ContextWithUser(t.Context(), "user-1")
+
+	total, err := cart.AddCombo(ctx, "breakfast")
+	require.NoError(t, err)
+	require.Equal(t, 1, total)
+
+	// Второй набор довел бы товар до 4 при ограничении 3
+	_, err = cart.AddCombo(ctx, "breakfast")
+
+	var limitErr *models.QuantityLimitError
+	require.ErrorAs(t, err, &limitErr)
+	require.Equal(t, models.QuantityLimitError{ProductID: "promo", Limit: 3, Quantity: 4}, *limitErr)
+
+	// Товары из набора считаются и при добавлении по отдельности
+	_, err = cart.AddQuantity(ctx, "promo", 2)
+	require.ErrorAs(t, err, &limitErr)
+	require.Equal(t, 4, limitErr.Quantity)
+
+	_, err = cart.SetQuantity(ctx, "promo", 2)
+	require.ErrorAs(t, err, &limitErr)
+
+	total, err = cart.AddItem(ctx, "promo")
+	require.NoError(t, err)
+	require.Equal(t, 1, total)
+}
+
+func TestCart_ConcurrentAddQuantity(t *testing.T) {
+	products := testCartProducts{"bread": {ID: "bread", Price: models.Rubles(80), Available: true}}
+	combos := service.NewComboService(products, []models.Combo{
+		{ID: "bread-pair", Items: []models.ComboItem{{ProductID: "bread", Quantity: 2}}},
+	})
+
+	cart := service.NewCart(products, service.NewMemoryCartStore(nil), testCartDelivery{},
+		testCartAddresses{}, testCartStats{}, combos, 10, zap.NewNop().Sugar(), nil)
+
+	ctx := models.ContextWithUser(t.Context(), "user-1")
+
+	var wg sync.WaitGroup
+
+	for range 20 {
+		wg.Go(func() {
+			_, _ = cart.AddItem(ctx, "bread")
+		})
+		wg.Go(func() {
+			_, _ = cart.AddCombo(ctx, "bread-pair")
+		})
+	}
+
+	wg.Wait()
+
+	response, err := cart.GetCart(ctx)
+	require.NoError(t, err)
+
+	quantity := 0
+	for _, item := range response.Items {
+		if item.ComboID != "" {
+			quantity += 2 * item.Quantity
+		} else {
+			quantity += item.Quantity
+		}
+	}
+
+	// Параллельные добавления не превышают ограничение
+	require.LessOrEqual(t, quantity, 10)
+	require.GreaterOrEqual(t, quantity, 9)
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"eats-backend/internal/models"
+)
+
+const maxChaosDelayMs = 30_000
+
+// ChaosService хранит правила внедрения сбоев, которые преподаватель включает,
+// чтобы студенты учились обрабатывать нестабильное API.
+type ChaosService struct {
+	rules []models.ChaosRule
+
+	mux sync.RWMutex
+}
+
+func NewChaosService() *ChaosService {
+	return &ChaosService{
+		rules: make([]models.ChaosRule, 0),
+	}
+}
+
+func (s *ChaosService) GetRules(_ context.Context) []models.ChaosRule {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	result := make([]models.ChaosRule, len(s.rules))
+	copy(result, s.rules)
+
+	return result
+}
+
+func (s *ChaosService) AddRule(_ context.Context, rule models.ChaosRule) (models.ChaosRule, error) {
+	if err := validateChaosRule(rule); err != nil {
+		return models.ChaosRule{}, err
+	}
+
+	rule.ID = uuid.NewString()
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.rules = append(s.rules, rule)
+
+	return rule, nil
+}
+
+func (s *ChaosService) RemoveRule(_ context.Context, id string) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	for i, rule := range s.rules {
+		if rule.ID == id {
+			s.rules = append(s.rules[:i], s.rules[i+1:]...)
+
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: chaos rule not found", models.ErrNotFound)
+}
+
+func (s *ChaosService) ClearRules(_ context.Context) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.rules = make([]models.ChaosRule, 0)
+}
+
+// PickFault возвращает сработавшее правило для запроса пользователя к маршруту.
+// Правила проверяются по порядку, срабатывает первое, для которого выпала вероятность.
+func (s *ChaosService) PickFault(userID, route string) (models.ChaosRule, bool) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	for _, rule := range s.rules {
+		if rule.UserID != "" && rule.UserID != userID {
+			continue
+		}
+
+		if rule.Route != "" && rule.Route != route {
+			continue
+		}
+
+		if rand.Float64() < rule.Probability {
+			return rule, true
+		}
+	}
+
+	return models.ChaosRule{}, false
+}
+
+func validateChaosRule(rule models.ChaosRule) error {
+	switch rule.Fault {
+	case models.ChaosFaultDelay:
+		if rule.DelayMs <= 0 || rule.DelayMs > maxChaosDelayMs {
+			return fmt.Errorf("%w: delayMs should be between 1 and %d", models.ErrBadRequest, maxChaosDelayMs)
+		}
+	case models.ChaosFaultError, models.ChaosFaultMalformedJSON, models.ChaosFaultReset:
+	default:
+		return fmt.Errorf(
+			"%w: unknown fault %s, should be one of delay, error, malformed_json, reset",
+			models.ErrBadRequest,
+			rule.Fault,
+		)
+	}
+
+	if rule.Probability <= 0 || rule.Probability > 1 {
+		return fmt.Errorf("%w: probability should be in (0, 1]", models.ErrBadRequest)
+	}
+
+	return nil
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"eats-backend/internal/models"
+)
+
+type DeliveryEstimator interface {
+	Calculate(address models.Address, orderPrice models.Money) (distance float64, price models.Money, minutes int)
+}
+
+type WalletProvider interface {
+	GetWallet(ctx context.Context) (*models.Wallet, error)
+}
+
+type PriceLocker interface {
+	Lock(ctx context.Context, items []models.OrderItem) (string, time.Time)
+}
+
+// CheckoutService рассчитывает заказ целиком до его оформления.
+type CheckoutService struct {
+	addressService AddressChecker
+	cartService    CartService
+	walletService  WalletProvider
+	delivery       DeliveryEstimator
+	priceLocks     PriceLocker
+	extras         OrderExtrasResolver
+
+	// Промокод в верхнем регистре -> скидка в процентах на товары.
+	promoCodes     map[string]int
+	loyaltyPercent int
+}
+
+func NewCheckoutService(
+	addressService AddressChecker,
+	cartService CartService,
+	walletService WalletProvider,
+	delivery DeliveryEstimator,
+	priceLocks PriceLocker,
+	extras OrderExtrasResolver,
+	promoCodes map[string]int,
+	loyaltyPercent int,
+) *CheckoutService {
+	normalized := make(map[string]int, len(promoCodes))
+	for code, percent := range promoCodes {
+		normalized[strings.ToUpper(code)] = percent
+	}
+
+	return &CheckoutService{
+		addressService: addressService,
+		cartService:    cartService,
+		walletService:  walletService,
+		delivery:       delivery,
+		priceLocks:     priceLocks,
+		extras:         extras,
+		promoCodes:     normalized,
+		loyaltyPercent: loyaltyPercent,
+	}
+}
+
+func (s *CheckoutService) Preview(ctx context.Context, req models.CheckoutPreviewRequest) (*models.CheckoutPreview, error) {
+	switch req.PaymentMethod {
+	case models.PaymentMethodCard, models.PaymentMethodCash, models.PaymentMethodWallet:
+	default:
+		return nil, fmt.Errorf(
+			"%w: unknown payment method %s, should be one of card, cash, wallet",
+			models.ErrBadRequest,
+			req.PaymentMethod,
+		)
+	}
+
+	if req.Tip < 0 || req.Tip > models.MaxTip {
+		return nil, fmt.Errorf("%w: tip must be between 0 and %s", models.ErrBadRequest, models.MaxTip)
+	}
+
+	address, err := s.addressService.GetAddressByID(ctx, req.AddressID)
+	if err != nil {
+		return nil, fmt.Errorf("get address: %w", err)
+	}
+
+	cart, err := s.cartService.GetCart(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get cart: %w", err)
+	}
+
+	items, comboDiscounts := orderItemsFromCart(cart)
+
+	preview := &models.CheckoutPreview{
+		Address:       address,
+		PaymentMethod: req.PaymentMethod,
+		Items:         items,
+	}
+
+	for _, item := range preview.Items {
+		preview.OrderPrice += item.Price.Mul(item.Quantity)
+		preview.TotalItems += item.Quantity
+	}
+
+	for _, discount := range comboDiscounts {
+		preview.ComboDiscount += discount.Amount
+	}
+
+	if len(preview.Items) == 0 {
+		return nil, fmt.Errorf("%w: cart is empty", models.ErrBadRequest)
+	}
+
+	if err := s.cartService.CheckQuantityLimits(ctx, preview.Items); err != nil {
+		return nil, err
+	}
+
+	// Доставка, промокод и баллы считаются от цены с учетом наборов, как в корзине
+	itemsPrice := preview.OrderPrice - preview.ComboDiscount
+
+	preview.DeliveryDistance, preview.DeliveryPrice, preview.DeliveryTime = s.delivery.Calculate(address, itemsPrice)
+
+	if req.PromoCode != "" {
+		discount, err := s.ApplyPromoCode(req.PromoCode, itemsPrice)
+		if err != nil {
+			return nil, err
+		}
+
+		preview.PromoCode = discount.Code
+		preview.Discount = discount.Amount
+	}
+
+	preview.Extras, preview.ExtrasPrice, err = s.extras.ResolveExtras(req.Options.Extras)
+	if err != nil {
+		return nil, fmt.Errorf("resolve order extras: %w", err)
+	}
+
+	// Итог считается так же, как в MakeNewOrder, чтобы совпасть со списанием
+	preview.Tip = req.Tip
+	preview.TotalPrice = itemsPrice - preview.Discount + preview.DeliveryPrice + preview.ExtrasPrice + preview.Tip
+	// Баллы начисляются за целые рубли
+	preview.LoyaltyPoints = (itemsPrice - preview.Discount).Percent(s.loyaltyPercent).Rubles()
+
+	wallet, err := s.walletService.GetWallet(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get wallet: %w", err)
+	}
+
+	// Заказ оплачивается только с рублевых карт, как в PayForOrder
+	for _, account := range wallet.Accounts {
+		if account.Type == models.AccountTypeCard && account.Currency == models.CurrencyRUB {
+			preview.WalletBalance += account.Balance
+		}
+	}
+
+	preview.SufficientFunds = req.PaymentMethod != models.PaymentMethodWallet ||
+		preview.WalletBalance >= preview.TotalPrice
+
+	preview.PriceLockID, preview.PriceLockExpiresAt = s.priceLocks.Lock(ctx, preview.Items)
+
+	return preview, nil
+}
+
+// ApplyPromoCode считает скидку по промокоду на стоимость товаров, регистр кода не важен
+func (s *CheckoutService) ApplyPromoCode(code string, orderPrice models.Money) (models.OrderDiscount, error) {
+	code = strings.ToUpper(code)
+
+	percent, ok := s.promoCodes[code]
+	if !ok {
+		return models.OrderDiscount{}, fmt.Errorf("%w: unknown promo code %s", models.ErrBadRequest, code)
+	}
+
+	return models.OrderDiscount{
+		Type:   models.DiscountTypePromoCode,
+		Code:   code,
+		Amount: orderPrice.Percent(percent),
+	}, nil
+}
@@ -0,0 +1,78 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"eats-backend/internal/models"
+	"eats-backend/internal/service"
+)
+
+type testCheckoutCart struct{ cart models.CartResponse }
+
+func (c testCheckoutCart) GetCart(context.Context) (models.CartResponse, error) { return c.cart, nil }
+
+func (c testCheckoutCart) CheckQuantityLimits(context.Context, []models.OrderItem) error { return nil }
+
+type testPriceLocks struct{}
+
+func (testPriceLocks) Lock(context.Context, []models.OrderItem) (string, time.Time) {
+	return "lock-1", time.Now().Add(time.Minute)
+}
+
+func TestCheckoutService_PreviewWalletBalance(t *testing.T) {
+	cart := testCheckoutCart{cart: models.CartResponse{
+		Items: []models.CartResponseItem{{ProductID: "cake", Price: models.Rubles(700), Quantity: 1, Available: true}},
+	}}
+
+	checkout := service.NewCheckoutService(testOrderAddresses{}, cart, newPaymentWallet(t),
+		testCartDelivery{price: models.Rubles(100)}, testPriceLocks{}, service.NewOrderExtrasCatalog(nil), nil, 0)
+
+	ctx := walletContext(t, "alice")
+
+	// Долларовая карта и накопительный счет заказ не оплачивают
+	preview, err := checkout.Preview(ctx, models.CheckoutPreviewRequest{
+		AddressID: "address-1", PaymentMethod: models.PaymentMethodWallet,
+	})
+	require.NoError(t, err)
+	require.Equal(t, models.Rubles(800), preview.WalletBalance)
+	require.Equal(t, models.Rubles(800), preview.TotalPrice)
+	require.True(t, preview.SufficientFunds)
+
+	cart.cart.Items[0].Price = models.Rubles(701)
+	checkout = service.NewCheckoutService(testOrderAddresses{}, cart, newPaymentWallet(t),
+		testCartDelivery{price: models.Rubles(100)}, testPriceLocks{}, service.NewOrderExtrasCatalog(nil), nil, 0)
+
+	preview, err = checkout.Preview(ctx, models.CheckoutPreviewRequest{
+		AddressID: "address-1", PaymentMethod: models.PaymentMethodWallet,
+	})
+	require.NoError(t, err)
+	require.False(t, preview.SufficientFunds)
+}
+
+func TestCheckoutService_PreviewTip(t *testing.T) {
+	cart := testCheckoutCart{cart: models.CartResponse{
+		Items: []models.CartResponseItem{{ProductID: "cake", Price: models.Rubles(600), Quantity: 1, Available: true}},
+	}}
+
+	checkout := service.NewCheckoutService(testOrderAddresses{}, cart, newPaymentWallet(t),
+		testCartDelivery{price: models.Rubles(100)}, testPriceLocks{}, service.NewOrderExtrasCatalog(nil), nil, 0)
+
+	ctx := walletContext(t, "alice")
+	request := models.CheckoutPreviewRequest{AddressID: "address-1", PaymentMethod: models.PaymentMethodWallet}
+
+	request.Tip = models.MaxTip + 1
+	_, err := checkout.Preview(ctx, request)
+	require.ErrorIs(t, err, models.ErrBadRequest)
+
+	// Чаевые входят в итог, как в заказе, и учитываются в проверке баланса
+	request.Tip = models.Rubles(101)
+	preview, err := checkout.Preview(ctx, request)
+	require.NoError(t, err)
+	require.Equal(t, models.Rubles(101), preview.Tip)
+	require.Equal(t, models.Rubles(801), preview.TotalPrice)
+	require.False(t, preview.SufficientFunds)
+}
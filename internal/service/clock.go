@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"eats-backend/internal/models"
+)
+
+// Clock источник текущего времени для сервисов, поведение которых зависит от него: завершения заказов
+// по времени, суточных лимитов кошелька, имен снимков бэкапа
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock системное время
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// clockOrSystem системное время, если часы не переданы
+func clockOrSystem(clock Clock) Clock {
+	if clock == nil {
+		return SystemClock{}
+	}
+
+	return clock
+}
+
+// FakeClock часы для тестов: время стоит на месте, пока его не переведут
+type FakeClock struct {
+	now time.Time
+	mux sync.Mutex
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	return c.now
+}
+
+// Set ставит часы на заданное время
+func (c *FakeClock) Set(now time.Time) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.now = now
+}
+
+// Advance переводит часы вперед на d
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+// SimulatedClock системное время со сдвигом. В демо-режиме преподаватель перематывает время вперед,
+// чтобы на занятии не ждать доставки заказов и смены суток у лимитов кошелька. Вне демо-режима
+// сдвиг всегда нулевой.
+type SimulatedClock struct {
+	enabled bool
+	offset  time.Duration
+	mux     sync.RWMutex
+}
+
+func NewSimulatedClock(enabled bool) *SimulatedClock {
+	return &SimulatedClock{enabled: enabled}
+}
+
+func (c *SimulatedClock) Now() time.Time {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+
+	return time.Now().Add(c.offset)
+}
+
+// GetClock возвращает текущее время сервера и насколько оно перемотано
+func (c *SimulatedClock) GetClock(_ context.Context) models.ClockState {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+
+	return c.state()
+}
+
+// AdvanceClock перематывает время вперед. Назад время не переводится: заказы и операции кошелька
+// уже записаны с прошедшим временем.
+func (c *SimulatedClock) AdvanceClock(_ context.Context, req models.AdvanceClockRequest) (models.ClockState, error) {
+	if !c.enabled {
+		return models.ClockState{}, fmt.Errorf("%w: simulated time is available only in demo mode", models.ErrNotFound)
+	}
+
+	if req.Minutes <= 0 || req.Minutes > models.MaxClockAdvanceMinutes {
+		return models.ClockState{}, fmt.Errorf(
+			"%w: minutes must be between 1 and %d", models.ErrBadRequest, models.MaxClockAdvanceMinutes)
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.offset += time.Duration(req.Minutes) * time.Minute
+
+	return c.state(), nil
+}
+
+// state вызывать под c.mux
+func (c *SimulatedClock) state() models.ClockState {
+	return models.ClockState{
+		Now:           time.Now().Add(c.offset),
+		OffsetMinutes: int(c.offset / time.Minute),
+		Simulated:     c.enabled,
+	}
+}
@@ -0,0 +1,111 @@
+package service_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"eats-backend/internal/events"
+	"eats-backend/internal/models"
+	"eats-backend/internal/service"
+)
+
+func TestOrderService_CompletesByClock(t *testing.T) {
+	clock := service.NewFakeClock(time.Date(2025, time.March, 3, 12, 0, 0, 0, time.UTC))
+	createdAt := clock.Now()
+
+	orders := service.NewOrderService(nil, nil, nil, nil, nil, nil, nil, events.NewBus(zap.NewNop().Sugar()), clock,
+		zap.NewNop().Sugar(), map[string][]*models.Order{
+			"user-1": {{ID: "order-1", Status: models.OrderStatusActive, CreatedAt: createdAt}},
+		})
+
+	ctx := walletContext(t, "user-1")
+
+	// Время доставки еще не прошло
+	clock.Advance(service.DeliveryTime - time.Second)
+
+	list, err := orders.GetOrders(ctx)
+	require.NoError(t, err)
+	require.Equal(t, models.OrderStatusActive, list[0].Status)
+	require.Len(t, orders.ActiveOrders(t.Context()), 1)
+
+	clock.Advance(2 * time.Second)
+
+	list, err = orders.GetOrders(ctx)
+	require.NoError(t, err)
+	require.Equal(t, models.OrderStatusCompleted, list[0].Status)
+	require.Equal(t, createdAt.Add(service.DeliveryTime), list[0].History[len(list[0].History)-1].At)
+	require.Empty(t, orders.ActiveOrders(t.Context()))
+}
+
+func TestWalletService_DailyTopupLimitByClock(t *testing.T) {
+	// Перед полуночью, чтобы проверить смену суток
+	clock := service.NewFakeClock(time.Date(2025, time.March, 3, 23, 50, 0, 0, time.Local))
+
+	wallet := service.NewWalletService(
+		testWalletProfiles{},
+		testWalletEvents{},
+		testAllowGuard{},
+		testWalletPINs{},
+		testWalletStats{},
+		testWalletIcons{},
+		service.NewStaticRates(nil),
+		[]models.Currency{models.CurrencyRUB},
+		clock,
+		zap.NewNop().Sugar(),
+		models.WalletData{Accounts: map[string]map[string]*models.Account{
+			"alice": {"alice-card": {ID: "alice-card", Type: models.AccountTypeCard}},
+		}},
+	)
+
+	ctx := walletContext(t, "alice")
+	topup := models.TopupRequest{AccountID: "alice-card", Amount: models.Rubles(1000)}
+
+	_, err := wallet.TopupAccount(ctx, topup)
+	require.NoError(t, err)
+
+	clock.Advance(5 * time.Minute)
+
+	_, err = wallet.TopupAccount(ctx, topup)
+	require.ErrorIs(t, err, models.ErrBadRequest)
+
+	// Наступили следующие сутки, лимит снова свободен
+	clock.Advance(5 * time.Minute)
+
+	balance, err := wallet.TopupAccount(ctx, topup)
+	require.NoError(t, err)
+	require.Equal(t, models.Rubles(2000), balance.Balance)
+
+	history, err := wallet.GetTransactions(ctx, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, history.Data["2025-03-03"], 1)
+	require.Len(t, history.Data["2025-03-04"], 1)
+	require.Equal(t, clock.Now(), history.Data["2025-03-04"][0].Time)
+}
+
+func TestSimulatedClock(t *testing.T) {
+	_, err := service.NewSimulatedClock(false).AdvanceClock(t.Context(), models.AdvanceClockRequest{Minutes: 60})
+	require.ErrorIs(t, err, models.ErrNotFound)
+
+	clock := service.NewSimulatedClock(true)
+
+	_, err = clock.AdvanceClock(t.Context(), models.AdvanceClockRequest{Minutes: 0})
+	require.ErrorIs(t, err, models.ErrBadRequest)
+
+	_, err = clock.AdvanceClock(t.Context(), models.AdvanceClockRequest{Minutes: models.MaxClockAdvanceMinutes + 1})
+	require.ErrorIs(t, err, models.ErrBadRequest)
+
+	before := time.Now()
+
+	_, err = clock.AdvanceClock(t.Context(), models.AdvanceClockRequest{Minutes: 90})
+	require.NoError(t, err)
+
+	state, err := clock.AdvanceClock(t.Context(), models.AdvanceClockRequest{Minutes: 30})
+	require.NoError(t, err)
+	require.True(t, state.Simulated)
+	require.Equal(t, 120, state.OffsetMinutes)
+	require.False(t, clock.Now().Before(before.Add(2*time.Hour)))
+	require.Equal(t, state.OffsetMinutes, clock.GetClock(t.Context()).OffsetMinutes)
+}
@@ -0,0 +1,256 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"eats-backend/internal/models"
+)
+
+// CollectionCatalog проверяет товары подборок, находит новинки и отдает товары для лент
+type CollectionCatalog interface {
+	ProductExists(id string) bool
+	NewProductIDs(since time.Time) []string
+	GetProductsList(ctx context.Context, page, pageSize int, filter models.ProductsFilter) (models.ProductsList, error)
+}
+
+// CollectionService подборки товаров для главного экрана: встроенные новинки и подборки, которые ведет
+// преподаватель. Состав подборок хранится идентификаторами, товары берутся из каталога при каждом запросе.
+type CollectionService struct {
+	catalog CollectionCatalog
+	// Сколько товар считается новинкой
+	newPeriod time.Duration
+
+	collections map[string]*models.Collection
+
+	mux sync.RWMutex
+}
+
+func NewCollectionService(
+	catalog CollectionCatalog,
+	newPeriod time.Duration,
+	collections []*models.Collection,
+) *CollectionService {
+	service := &CollectionService{
+		catalog:   catalog,
+		newPeriod: newPeriod,
+	}
+	service.load(collections)
+
+	return service
+}
+
+// load заменяет подборки копиями, вызывать под s.mux или в конструкторе
+func (s *CollectionService) load(collections []*models.Collection) {
+	s.collections = make(map[string]*models.Collection, len(collections))
+
+	for _, collection := range collections {
+		if collection == nil {
+			continue
+		}
+
+		copied := copyCollection(collection)
+		s.collections[collection.ID] = &copied
+	}
+}
+
+// ListCollections возвращает все подборки преподавателя, в том числе вне сезона, в порядке показа
+func (s *CollectionService) ListCollections(_ context.Context) []models.Collection {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	return s.sorted(func(*models.Collection) bool { return true })
+}
+
+// SetCollection создает подборку или заменяет ее целиком
+func (s *CollectionService) SetCollection(
+	_ context.Context,
+	id string,
+	req models.CollectionRequest,
+) (models.Collection, error) {
+	if id == models.CollectionNew {
+		return models.Collection{}, fmt.Errorf("%w: collection %s is built in", models.ErrBadRequest, id)
+	}
+
+	if strings.TrimSpace(req.Name) == "" {
+		return models.Collection{}, fmt.Errorf("%w: collection name is required", models.ErrBadRequest)
+	}
+
+	if req.ActiveFrom != nil && req.ActiveTo != nil && !req.ActiveFrom.Before(*req.ActiveTo) {
+		return models.Collection{}, fmt.Errorf("%w: activeFrom should be before activeTo", models.ErrBadRequest)
+	}
+
+	for _, productID := range req.ProductIDs {
+		if !s.catalog.ProductExists(productID) {
+			return models.Collection{}, fmt.Errorf("%w: product %s not found", models.ErrBadRequest, productID)
+		}
+	}
+
+	collection := models.Collection{
+		ID:         id,
+		Name:       strings.TrimSpace(req.Name),
+		Names:      maps.Clone(req.Names),
+		Position:   req.Position,
+		ProductIDs: uniqueStrings(req.ProductIDs),
+		ActiveFrom: req.ActiveFrom,
+		ActiveTo:   req.ActiveTo,
+		UpdatedAt:  time.Now(),
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.collections[id] = &collection
+
+	return copyCollection(&collection), nil
+}
+
+// DeleteCollection удаляет подборку преподавателя
+func (s *CollectionService) DeleteCollection(_ context.Context, id string) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if _, ok := s.collections[id]; !ok {
+		return fmt.Errorf("%w: collection %s not found", models.ErrNotFound, id)
+	}
+
+	delete(s.collections, id)
+
+	return nil
+}
+
+// CollectionFilter товары подборки для GET /products?collection={id}. Подборка вне сезона пустая.
+func (s *CollectionService) CollectionFilter(_ context.Context, id string) (*models.CollectionFilter, error) {
+	if id == models.CollectionNew {
+		return &models.CollectionFilter{ProductIDs: s.catalog.NewProductIDs(time.Now().Add(-s.newPeriod))}, nil
+	}
+
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	collection, ok := s.collections[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: collection %s not found", models.ErrNotFound, id)
+	}
+
+	if !collection.Active(time.Now()) {
+		return &models.CollectionFilter{ProductIDs: []string{}}, nil
+	}
+
+	return &models.CollectionFilter{ProductIDs: slices.Clone(collection.ProductIDs)}, nil
+}
+
+// GetRails возвращает ленты для главного экрана: новинки, затем подборки в сезоне по порядку. В ленте
+// первые limit товаров с учетом зоны доставки, подборки без товаров не возвращаются.
+func (s *CollectionService) GetRails(
+	ctx context.Context,
+	limit int,
+	zone *models.ZoneFilter,
+) ([]models.CollectionRail, error) {
+	if limit <= 0 || limit > models.MaxCollectionRailLimit {
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", models.ErrBadRequest, models.MaxCollectionRailLimit)
+	}
+
+	now := time.Now()
+
+	// Каталог запрашивается без блокировки подборок
+	s.mux.RLock()
+	collections := s.sorted(func(collection *models.Collection) bool { return collection.Active(now) })
+	s.mux.RUnlock()
+
+	newest := models.Collection{
+		ID:         models.CollectionNew,
+		Name:       "Новинки",
+		Names:      map[string]string{"en": "New"},
+		ProductIDs: s.catalog.NewProductIDs(now.Add(-s.newPeriod)),
+	}
+	collections = slices.Insert(collections, 0, newest)
+
+	lang := models.LanguageFromContext(ctx)
+	rails := make([]models.CollectionRail, 0, len(collections))
+
+	for _, collection := range collections {
+		list, err := s.catalog.GetProductsList(ctx, 1, limit, models.ProductsFilter{
+			Zone:       zone,
+			Collection: &models.CollectionFilter{ProductIDs: collection.ProductIDs},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("collection %s: %w", collection.ID, err)
+		}
+
+		if len(list.Data) == 0 {
+			continue
+		}
+
+		rails = append(rails, models.CollectionRail{
+			ID:       collection.ID,
+			Name:     collection.LocalizedName(lang),
+			Products: list.Data,
+		})
+	}
+
+	return rails, nil
+}
+
+// sorted копии подборок в порядке показа, вызывать под s.mux
+func (s *CollectionService) sorted(keep func(*models.Collection) bool) []models.Collection {
+	result := make([]models.Collection, 0, len(s.collections))
+
+	for _, collection := range s.collections {
+		if keep(collection) {
+			result = append(result, copyCollection(collection))
+		}
+	}
+
+	slices.SortFunc(result, func(a, b models.Collection) int {
+		return cmp.Or(cmp.Compare(a.Position, b.Position), strings.Compare(a.ID, b.ID))
+	})
+
+	return result
+}
+
+func copyCollection(collection *models.Collection) models.Collection {
+	result := *collection
+	result.Names = maps.Clone(collection.Names)
+	result.ProductIDs = slices.Clone(collection.ProductIDs)
+
+	if result.ProductIDs == nil {
+		result.ProductIDs = []string{}
+	}
+
+	return result
+}
+
+// GetBackupData возвращает данные для бэкапа
+func (s *CollectionService) GetBackupData() interface{} {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	return s.sorted(func(*models.Collection) bool { return true })
+}
+
+func (s *CollectionService) GetBackupFileName() string {
+	return "collections"
+}
+
+// RestoreBackupData заменяет подборки данными из бэкапа
+func (s *CollectionService) RestoreBackupData(data []byte) error {
+	var backup []*models.Collection
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return fmt.Errorf("can't parse collections: %w", err)
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.load(backup)
+
+	return nil
+}
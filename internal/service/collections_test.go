@@ -0,0 +1,125 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"eats-backend/internal/models"
+	"eats-backend/internal/service"
+)
+
+func TestCollectionService(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	favourites := service.NewMockUserService(ctrl)
+	favourites.EXPECT().IsFavourite(gomock.Any(), gomock.Any()).Return(false).AnyTimes()
+
+	now := time.Now()
+	daysAgo := func(days int) *time.Time {
+		created := now.AddDate(0, 0, -days)
+
+		return &created
+	}
+
+	products := service.NewProductsService(favourites, service.NewRecentlyViewed(service.DefaultRecentlyViewedLimit), nil, service.NewProductPopularity(nil, nil), nil, nil, nil, nil, nil, []*models.Product{
+		{ID: "apple", CreatedAt: daysAgo(100)},
+		{ID: "bread"},
+		{ID: "cheese", CreatedAt: daysAgo(3)},
+		{ID: "donut", CreatedAt: daysAgo(10)},
+	}, map[string][]string{}, map[string]models.Category{})
+
+	ids := func(previews []models.ProductPreview) []string {
+		result := make([]string, 0, len(previews))
+		for _, preview := range previews {
+			result = append(result, preview.ID)
+		}
+
+		return result
+	}
+
+	// Без даты товар идет после датированных, в обратном порядке каталога
+	list, err := products.GetProductsList(t.Context(), 1, 20, models.ProductsFilter{Sort: models.ProductSortNew})
+	require.NoError(t, err)
+	require.Equal(t, []string{"cheese", "donut", "apple", "bread"}, ids(list.Data))
+
+	collections := service.NewCollectionService(products, 30*24*time.Hour, []*models.Collection{
+		{ID: "winter", Name: "Зимнее меню", Position: 2, ProductIDs: []string{"bread"}, ActiveTo: daysAgo(1)},
+	})
+
+	filter, err := collections.CollectionFilter(t.Context(), models.CollectionNew)
+	require.NoError(t, err)
+
+	list, err = products.GetProductsList(t.Context(), 1, 20, models.ProductsFilter{Collection: filter})
+	require.NoError(t, err)
+	require.Equal(t, []string{"cheese", "donut"}, ids(list.Data))
+
+	_, err = collections.SetCollection(t.Context(), models.CollectionNew, models.CollectionRequest{Name: "Новинки"})
+	require.ErrorIs(t, err, models.ErrBadRequest)
+
+	_, err = collections.SetCollection(t.Context(), "summer", models.CollectionRequest{Name: "Лето", ProductIDs: []string{"missing"}})
+	require.ErrorIs(t, err, models.ErrBadRequest)
+
+	summer, err := collections.SetCollection(t.Context(), "summer", models.CollectionRequest{
+		Name:       " Летнее меню ",
+		Names:      map[string]string{"en": "Summer menu"},
+		Position:   1,
+		ProductIDs: []string{"donut", "apple", "donut"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "Летнее меню", summer.Name)
+	require.Equal(t, []string{"donut", "apple"}, summer.ProductIDs)
+
+	// Без явного порядка товары идут в порядке подборки, с порядком - по нему
+	filter, err = collections.CollectionFilter(t.Context(), "summer")
+	require.NoError(t, err)
+
+	list, err = products.GetProductsList(t.Context(), 1, 20, models.ProductsFilter{Collection: filter})
+	require.NoError(t, err)
+	require.Equal(t, []string{"donut", "apple"}, ids(list.Data))
+
+	list, err = products.GetProductsList(t.Context(), 1, 20, models.ProductsFilter{Collection: filter, Sort: models.ProductSortNew})
+	require.NoError(t, err)
+	require.Equal(t, []string{"donut", "apple"}, ids(list.Data))
+
+	// Сезон зимней подборки закончился
+	filter, err = collections.CollectionFilter(t.Context(), "winter")
+	require.NoError(t, err)
+	require.Empty(t, filter.ProductIDs)
+
+	_, err = collections.CollectionFilter(t.Context(), "missing")
+	require.ErrorIs(t, err, models.ErrNotFound)
+
+	rails, err := collections.GetRails(t.Context(), 1, nil)
+	require.NoError(t, err)
+	require.Len(t, rails, 2)
+	require.Equal(t, models.CollectionNew, rails[0].ID)
+	require.Equal(t, []string{"cheese"}, ids(rails[0].Products))
+	require.Equal(t, "summer", rails[1].ID)
+	require.Equal(t, []string{"donut"}, ids(rails[1].Products))
+
+	rails, err = collections.GetRails(context.WithValue(t.Context(), models.ContextLanguageKey{}, "en"), 10, nil)
+	require.NoError(t, err)
+	require.Equal(t, "Summer menu", rails[1].Name)
+
+	_, err = collections.GetRails(t.Context(), 0, nil)
+	require.ErrorIs(t, err, models.ErrBadRequest)
+
+	listed := collections.ListCollections(t.Context())
+	require.Len(t, listed, 2)
+	require.Equal(t, "summer", listed[0].ID)
+	require.Equal(t, "winter", listed[1].ID)
+
+	data, err := json.Marshal(collections.GetBackupData())
+	require.NoError(t, err)
+
+	require.NoError(t, collections.DeleteCollection(t.Context(), "summer"))
+	require.ErrorIs(t, collections.DeleteCollection(t.Context(), "summer"), models.ErrNotFound)
+
+	require.NoError(t, collections.RestoreBackupData(data))
+	require.Len(t, collections.ListCollections(t.Context()), 2)
+}
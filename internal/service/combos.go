@@ -0,0 +1,193 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"eats-backend/internal/models"
+)
+
+type ComboProducts interface {
+	GetProductByID(ctx context.Context, id string) (models.Product, error)
+}
+
+// ComboService наборы товаров по цене ниже суммы цен товаров. Состав и цена наборов задаются файлом
+// combos.json, названия, цены и наличие товаров берутся из каталога при каждом запросе.
+type ComboService struct {
+	products ComboProducts
+	// Наборы в порядке из файла
+	combos []models.Combo
+	index  map[string]int
+}
+
+func NewComboService(products ComboProducts, combos []models.Combo) *ComboService {
+	service := &ComboService{
+		products: products,
+		combos:   make([]models.Combo, len(combos)),
+		index:    make(map[string]int, len(combos)),
+	}
+
+	for i, combo := range combos {
+		combo.Items = slices.Clone(combo.Items)
+		service.combos[i] = combo
+		service.index[combo.ID] = i
+	}
+
+	return service
+}
+
+// ListCombos возвращает все наборы, в том числе недоступные, чтобы клиент мог показать их неактивными
+func (s *ComboService) ListCombos(ctx context.Context) ([]models.Combo, error) {
+	result := make([]models.Combo, 0, len(s.combos))
+
+	for _, combo := range s.combos {
+		resolved, err := s.resolve(ctx, combo)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, resolved)
+	}
+
+	return result, nil
+}
+
+// GetCombo возвращает набор с товарами из каталога
+func (s *ComboService) GetCombo(ctx context.Context, id string) (models.Combo, error) {
+	i, ok := s.index[id]
+	if !ok {
+		return models.Combo{}, fmt.Errorf("%w: combo %s not found", models.ErrNotFound, id)
+	}
+
+	return s.resolve(ctx, s.combos[i])
+}
+
+// resolve заполняет товары набора по каталогу. Набор доступен, только если доступны все его товары.
+func (s *ComboService) resolve(ctx context.Context, combo models.Combo) (models.Combo, error) {
+	combo.Items = slices.Clone(combo.Items)
+	combo.Available = true
+
+	// Сколько стоят товары по отдельности с их скидками
+	separately := models.Money(0)
+
+	for i, item := range combo.Items {
+		product, err := s.products.GetProductByID(ctx, item.ProductID)
+		if errors.Is(err, models.ErrNotFound) {
+			markComboUnavailable(&combo, models.UnavailableRemovedFromCatalog)
+
+			continue
+		}
+
+		if err != nil {
+			return models.Combo{}, fmt.Errorf("get combo product %s: %w", item.ProductID, err)
+		}
+
+		combo.Items[i].Name = product.Name
+		combo.Items[i].Image = product.Image
+		combo.Items[i].Weight = product.Weight
+		combo.Items[i].Price = product.Price
+
+		combo.OriginalPrice += product.OriginalPrice().Mul(item.Quantity)
+		combo.Weight += product.Weight * item.Quantity
+		separately += product.Price.Mul(item.Quantity)
+
+		switch {
+		case product.UnavailableReason == models.UnavailableOutsideHours:
+			markComboUnavailable(&combo, models.UnavailableOutsideHours)
+		case !product.Available:
+			markComboUnavailable(&combo, models.UnavailableOutOfStock)
+		}
+	}
+
+	// Набор не может стоить дороже своих товаров, например когда на них скидки
+	if combo.Available && separately < combo.Price {
+		combo.Price = separately
+	}
+
+	return combo, nil
+}
+
+// markComboUnavailable помечает набор недоступным, причиной остается первая найденная
+func markComboUnavailable(combo *models.Combo, reason string) {
+	if combo.Available {
+		combo.Available = false
+		combo.UnavailableReason = reason
+	}
+}
+
+// orderItemsFromCart раскладывает доступные позиции корзины на позиции заказа. Набор превращается в свои
+// товары по обычным ценам, а разница с ценой набора возвращается скидкой за набор.
+func orderItemsFromCart(cart models.CartResponse) ([]models.OrderItem, []models.OrderDiscount) {
+	items := make([]models.OrderItem, 0, len(cart.Items))
+	discounts := make([]models.OrderDiscount, 0)
+
+	for _, item := range cart.Items {
+		if !item.Available {
+			continue
+		}
+
+		if item.ComboID == "" {
+			items = append(items, models.OrderItem{
+				ID:       item.ProductID,
+				Image:    item.Image,
+				Name:     item.Name,
+				Weight:   item.Weight,
+				Price:    item.Price,
+				Quantity: item.Quantity,
+				Comment:  item.Comment,
+			})
+
+			continue
+		}
+
+		separately := models.Money(0)
+		for _, component := range item.Components {
+			items = append(items, models.OrderItem{
+				ID:       component.ProductID,
+				Image:    component.Image,
+				Name:     component.Name,
+				Weight:   component.Weight,
+				Price:    component.Price,
+				Quantity: component.Quantity * item.Quantity,
+				ComboID:  item.ComboID,
+			})
+
+			separately += component.Price.Mul(component.Quantity * item.Quantity)
+		}
+
+		if discount := separately - item.LineTotal; discount > 0 {
+			discounts = append(discounts, models.OrderDiscount{
+				Type:   models.DiscountTypeCombo,
+				Code:   item.ComboID,
+				Amount: discount,
+			})
+		}
+	}
+
+	return items, discounts
+}
+
+// cartLines позиции корзины, которые резервируются под заказ и убираются из нее после оформления.
+// Набор резервируется целиком, а не по товарам.
+func cartLines(cart models.CartResponse) []models.OrderItem {
+	lines := make([]models.OrderItem, 0, len(cart.Items))
+
+	for _, item := range cart.Items {
+		if item.Available {
+			lines = append(lines, models.OrderItem{ID: cartKey(item), Price: item.Price, Quantity: item.Quantity})
+		}
+	}
+
+	return lines
+}
+
+// cartKey ключ позиции в хранилище корзин
+func cartKey(item models.CartResponseItem) string {
+	if item.ComboID != "" {
+		return models.ComboCartKey(item.ComboID)
+	}
+
+	return item.ProductID
+}
@@ -0,0 +1,130 @@
+package service_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"eats-backend/internal/events"
+	"eats-backend/internal/models"
+	"eats-backend/internal/service"
+)
+
+func TestComboService_GetCombo(t *testing.T) {
+	products := testCartProducts{
+		"bread":  {ID: "bread", Name: "Хлеб", Price: models.Rubles(80), Discount: 20, Weight: 500, Available: true},
+		"milk":   {ID: "milk", Name: "Молоко", Price: models.Rubles(90), Weight: 1000, Available: true},
+		"butter": {ID: "butter", Price: models.Rubles(120), Available: false},
+	}
+
+	combos := service.NewComboService(products, []models.Combo{
+		{ID: "breakfast", Items: []models.ComboItem{{ProductID: "bread", Quantity: 2}, {ProductID: "milk", Quantity: 1}}, Price: models.Rubles(200)},
+		{ID: "expensive", Items: []models.ComboItem{{ProductID: "milk", Quantity: 1}}, Price: models.Rubles(150)},
+		{ID: "sold-out", Items: []models.ComboItem{{ProductID: "butter", Quantity: 1}}, Price: models.Rubles(100)},
+		{ID: "removed", Items: []models.ComboItem{{ProductID: "cake", Quantity: 1}}, Price: models.Rubles(100)},
+	})
+
+	breakfast, err := combos.GetCombo(t.Context(), "breakfast")
+	require.NoError(t, err)
+	require.True(t, breakfast.Available)
+	require.Equal(t, models.Rubles(200), breakfast.Price)
+	// Без скидок: хлеб 100 ₽, молоко 90 ₽
+	require.Equal(t, models.Rubles(290), breakfast.OriginalPrice)
+	require.Equal(t, 2000, breakfast.Weight)
+	require.Equal(t, "Хлеб", breakfast.Items[0].Name)
+	require.Equal(t, models.Rubles(80), breakfast.Items[0].Price)
+
+	// Набор не дороже товаров по отдельности
+	expensive, err := combos.GetCombo(t.Context(), "expensive")
+	require.NoError(t, err)
+	require.Equal(t, models.Rubles(90), expensive.Price)
+
+	list, err := combos.ListCombos(t.Context())
+	require.NoError(t, err)
+	require.Len(t, list, 4)
+	require.Equal(t, models.UnavailableOutOfStock, list[2].UnavailableReason)
+	require.Equal(t, models.UnavailableRemovedFromCatalog, list[3].UnavailableReason)
+
+	_, err = combos.GetCombo(t.Context(), "missing")
+	require.ErrorIs(t, err, models.ErrNotFound)
+}
+
+func TestCart_Combos(t *testing.T) {
+	products := testCartProducts{
+		"bread": {ID: "bread", Price: models.Rubles(80), Available: true},
+		"milk":  {ID: "milk", Price: models.Rubles(90), Available: true},
+	}
+
+	combos := service.NewComboService(products, []models.Combo{
+		{ID: "breakfast", Items: []models.ComboItem{{ProductID: "bread", Quantity: 2}, {ProductID: "milk", Quantity: 1}}, Price: models.Rubles(200)},
+	})
+
+	carts := map[string]map[string]*models.CartItem{
+		"user-1": {"bread": {ProductID: "bread", Quantity: 1}},
+	}
+
+	cart := service.NewCart(products, service.NewMemoryCartStore(carts), testCartDelivery{},
+		testCartAddresses{}, testCartStats{}, combos, 0, zap.NewNop().Sugar(), nil)
+
+	ctx := models.ContextWithUser(t.Context(), "user-1")
+
+	_, err := cart.AddCombo(ctx, "missing")
+	require.ErrorIs(t, err, models.ErrNotFound)
+
+	for range 2 {
+		_, err = cart.AddCombo(ctx, "breakfast")
+		require.NoError(t, err)
+	}
+
+	response, err := cart.GetCartForAddress(ctx, "", 0)
+	require.NoError(t, err)
+	require.Len(t, response.Items, 2)
+
+	var combo models.CartResponseItem
+	for _, item := range response.Items {
+		if item.ComboID != "" {
+			combo = item
+		}
+	}
+
+	// Набор одной позицией: два набора по 200 ₽ вместо 250 ₽
+	require.Equal(t, "breakfast", combo.ComboID)
+	require.Equal(t, 2, combo.Quantity)
+	require.Equal(t, models.Rubles(400), combo.LineTotal)
+	require.Equal(t, models.Rubles(100), combo.Discount)
+	require.Len(t, combo.Components, 2)
+	require.Equal(t, models.Rubles(480), response.OrderPrice)
+
+	// В заказе набор раскладывается на товары, а скидка за набор идет в скидки заказа
+	orderCart := &testOrderCart{cart: response}
+	orders := service.NewOrderService(testOrderAddresses{}, orderCart, &testOrderWallet{}, nil, testOrderDelivery{}, nil, service.NewOrderExtrasCatalog(nil),
+		events.NewBus(zap.NewNop().Sugar()), nil, zap.NewNop().Sugar(), map[string][]*models.Order{})
+
+	require.NoError(t, orders.MakeNewOrder(ctx, &models.OrderRequest{
+		PaymentMethod: string(models.PaymentMethodCash), AddressID: "address-1",
+	}))
+
+	placed, err := orders.GetOrders(ctx)
+	require.NoError(t, err)
+	require.Len(t, placed, 1)
+
+	order := placed[0]
+	require.Len(t, order.Items, 3)
+	require.Equal(t, 7, order.TotalItems)
+	require.Equal(t, models.Rubles(580), order.OrderPrice)
+	require.Equal(t, []models.OrderDiscount{{Type: models.DiscountTypeCombo, Code: "breakfast", Amount: models.Rubles(100)}}, order.Discounts)
+	require.Equal(t, response.OrderPrice, order.TotalPrice)
+
+	reserved := make(map[string]int)
+	for _, item := range orderCart.reserved {
+		reserved[item.ID] = item.Quantity
+	}
+
+	require.Equal(t, map[string]int{"bread": 1, models.ComboCartKey("breakfast"): 2}, reserved)
+
+	// Удаление набора уменьшает количество наборов, а не товаров
+	left, err := cart.RemoveCombo(ctx, "breakfast")
+	require.NoError(t, err)
+	require.Equal(t, 1, left)
+}
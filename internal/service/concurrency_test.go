@@ -0,0 +1,156 @@
+package service_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"eats-backend/internal/models"
+	"eats-backend/internal/service"
+)
+
+// Тесты запускают одновременные запросы к общим данным. Гонки они ловят под go test -race.
+
+const concurrencyWorkers = 50
+
+type testAllowGuard struct{}
+
+func (testAllowGuard) Check(models.WalletOperation, []models.Transaction) error { return nil }
+
+func TestUserData_ConcurrentAccess(t *testing.T) {
+	profiles := service.NewUserData(map[string]*models.UserProfile{
+		"alice": {Phone: "79990000001", Name: "Алиса"},
+	}, nil, nil)
+
+	alice := walletContext(t, "alice")
+
+	var wg sync.WaitGroup
+
+	for i := range concurrencyWorkers {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			require.NoError(t, profiles.UpdateProfile(alice, models.UpdateUserRequest{Name: fmt.Sprintf("Алиса %d", i)}))
+
+			profile, err := profiles.GetProfile(alice)
+			require.NoError(t, err)
+			require.Equal(t, "79990000001", profile.Phone)
+
+			_, ok := profiles.GetUserIDByPhone("79990000001")
+			require.True(t, ok)
+
+			_ = profiles.GetBackupData()
+
+			_, err = profiles.ExportUserData(alice)
+			require.NoError(t, err)
+
+			if i%10 == 0 {
+				require.NoError(t, profiles.DeleteProfile(alice))
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	// Профиль отдается копией: изменения копии не попадают в сервис
+	profile, err := profiles.GetProfile(alice)
+	require.NoError(t, err)
+
+	profile.Phone = "70000000000"
+
+	stored, err := profiles.GetProfile(alice)
+	require.NoError(t, err)
+	require.Equal(t, "79990000001", stored.Phone)
+
+	// Изменение профиля, который еще не создан, создает его
+	newcomer := walletContext(t, "newcomer")
+	require.NoError(t, profiles.UpdateProfile(newcomer, models.UpdateUserRequest{Name: "Новичок"}))
+	require.NoError(t, profiles.DeleteProfile(walletContext(t, "another")))
+
+	created, err := profiles.GetProfile(newcomer)
+	require.NoError(t, err)
+	require.Equal(t, "Новичок", created.Name)
+	require.NotEmpty(t, created.Phone)
+}
+
+func TestWalletService_ConcurrentTransfers(t *testing.T) {
+	profiles := service.NewUserData(map[string]*models.UserProfile{
+		"alice": {Phone: "79990000001"},
+		"bob":   {Phone: "79990000002"},
+	}, nil, nil)
+
+	wallet := service.NewWalletService(
+		profiles, testWalletEvents{}, testAllowGuard{}, testWalletPINs{}, testWalletStats{}, testWalletIcons{},
+		service.NewStaticRates(nil), []models.Currency{models.CurrencyRUB}, nil, zap.NewNop().Sugar(),
+		models.WalletData{Accounts: map[string]map[string]*models.Account{
+			"alice": {"alice-card": {ID: "alice-card", Type: models.AccountTypeCard, Balance: models.Rubles(1000)}},
+			"bob":   {"bob-card": {ID: "bob-card", Type: models.AccountTypeCard}},
+		}},
+	)
+
+	alice := walletContext(t, "alice")
+	bob := walletContext(t, "bob")
+
+	var wg sync.WaitGroup
+
+	for range concurrencyWorkers {
+		wg.Add(2)
+
+		go func() {
+			defer wg.Done()
+
+			_, err := wallet.TransferMoney(alice, models.TransferRequest{
+				FromAccountID: "alice-card", ToPhoneNumber: "79990000002", Amount: models.Rubles(10),
+			})
+			require.NoError(t, err)
+		}()
+
+		go func() {
+			defer wg.Done()
+
+			for range 5 {
+				_, err := wallet.GetTransactions(bob, 1, 10)
+				require.NoError(t, err)
+
+				_, err = wallet.GetTransactions(alice, 1, 10)
+				require.NoError(t, err)
+			}
+
+			_, err := wallet.GetWallet(bob)
+			require.NoError(t, err)
+
+			_, err = wallet.GetAnalytics(alice, models.AnalyticsPeriodMonth)
+			require.NoError(t, err)
+
+			_ = wallet.GetBackupData()
+		}()
+	}
+
+	wg.Wait()
+
+	aliceWallet, err := wallet.GetWallet(alice)
+	require.NoError(t, err)
+	require.Equal(t, models.Rubles(500), aliceWallet.Accounts[0].Balance)
+
+	bobWallet, err := wallet.GetWallet(bob)
+	require.NoError(t, err)
+	require.Equal(t, models.Rubles(500), bobWallet.Accounts[0].Balance)
+
+	history, err := wallet.GetTransactions(bob, 1, 2*concurrencyWorkers)
+	require.NoError(t, err)
+
+	received := 0
+	for _, transactions := range history.Data {
+		for _, transaction := range transactions {
+			require.Equal(t, "Перевод от номера 79990000001", transaction.Title)
+			received++
+		}
+	}
+
+	require.Equal(t, concurrencyWorkers, received)
+}
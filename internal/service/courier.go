@@ -0,0 +1,274 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"eats-backend/internal/models"
+)
+
+// Сколько заказов курьер может везти одновременно, включая предложенный
+const maxCourierOrders = 3
+
+type CourierOrders interface {
+	ActiveOrders(ctx context.Context) []models.Order
+	UpdateDelivery(ctx context.Context, orderID string, update func(order *models.Order) error) (models.Order, error)
+}
+
+type DeliveryPhotos interface {
+	ResolveUpload(ref string) (string, error)
+}
+
+// CourierService предлагает заказы курьерам и ведет доставку от назначения до вручения.
+// Сама доставка хранится в заказе, поэтому пользователь видит ее в GET /orders.
+type CourierService struct {
+	orders CourierOrders
+	photos DeliveryPhotos
+	clock  Clock
+	logger *zap.SugaredLogger
+
+	// Заказ -> курьеры, которые от него отказались. Повторно им этот заказ не предлагается.
+	declined map[string]map[string]struct{}
+
+	mux sync.Mutex
+}
+
+func NewCourierService(
+	orders CourierOrders, photos DeliveryPhotos, clock Clock, logger *zap.SugaredLogger,
+) *CourierService {
+	return &CourierService{
+		orders:   orders,
+		photos:   photos,
+		clock:    clockOrSystem(clock),
+		logger:   logger,
+		declined: make(map[string]map[string]struct{}),
+	}
+}
+
+// GetOrders возвращает заказы курьера. Если курьеру ничего не предложено и он везет меньше
+// maxCourierOrders заказов, ему предлагается самый старый заказ без курьера.
+func (s *CourierService) GetOrders(ctx context.Context) ([]models.CourierOrder, error) {
+	claims := models.ClaimsFromContext(ctx)
+
+	// Предложения раздаются по одному, чтобы два курьера не получили один заказ
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	active := s.orders.ActiveOrders(ctx)
+	result := make([]models.CourierOrder, 0)
+	hasOffer := false
+	activeIDs := make(map[string]struct{}, len(active))
+
+	for _, order := range active {
+		activeIDs[order.ID] = struct{}{}
+
+		if order.Delivery != nil && order.Delivery.CourierID == claims.ID {
+			result = append(result, toCourierOrder(order))
+			hasOffer = hasOffer || order.Delivery.Status == models.DeliveryStatusAssigned
+		}
+	}
+
+	// Отказы от завершенных заказов больше не нужны
+	for orderID := range s.declined {
+		if _, ok := activeIDs[orderID]; !ok {
+			delete(s.declined, orderID)
+		}
+	}
+
+	if hasOffer || len(result) >= maxCourierOrders {
+		return result, nil
+	}
+
+	for _, order := range active {
+		if _, declined := s.declined[order.ID][claims.ID]; declined || order.Delivery != nil {
+			continue
+		}
+
+		offered, err := s.orders.UpdateDelivery(ctx, order.ID, func(order *models.Order) error {
+			if order.Delivery != nil || order.Status != models.OrderStatusActive {
+				return fmt.Errorf("%w: order is already taken", models.ErrBadRequest)
+			}
+
+			order.Delivery = &models.OrderDelivery{
+				CourierID: claims.ID,
+				Courier:   claims.Nickname,
+				Status:    models.DeliveryStatusAssigned,
+				UpdatedAt: s.clock.Now(),
+			}
+
+			return nil
+		})
+		if err != nil {
+			continue
+		}
+
+		s.logger.Infow("Order offered to courier", "orderId", order.ID, "courierId", claims.ID)
+
+		result = append(result, toCourierOrder(offered))
+
+		break
+	}
+
+	return result, nil
+}
+
+// AcceptOrder курьер берет предложенный заказ
+func (s *CourierService) AcceptOrder(ctx context.Context, orderID string) (models.CourierOrder, error) {
+	return s.updateOwn(ctx, orderID, func(_ *models.Order, delivery *models.OrderDelivery) error {
+		if delivery.Status != models.DeliveryStatusAssigned {
+			return fmt.Errorf("%w: order is already accepted", models.ErrBadRequest)
+		}
+
+		delivery.Status = models.DeliveryStatusAccepted
+
+		return nil
+	})
+}
+
+// DeclineOrder курьер отказывается от заказа, пока не забрал его. Заказ снова ждет курьера.
+func (s *CourierService) DeclineOrder(ctx context.Context, orderID string) error {
+	claims := models.ClaimsFromContext(ctx)
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	_, err := s.orders.UpdateDelivery(ctx, orderID, func(order *models.Order) error {
+		if order.Delivery == nil || order.Delivery.CourierID != claims.ID {
+			return fmt.Errorf("%w: order is not assigned to you", models.ErrNotFound)
+		}
+
+		switch order.Delivery.Status {
+		case models.DeliveryStatusAssigned, models.DeliveryStatusAccepted:
+		default:
+			return fmt.Errorf("%w: order is already picked up", models.ErrBadRequest)
+		}
+
+		order.Delivery = nil
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, ok := s.declined[orderID]; !ok {
+		s.declined[orderID] = make(map[string]struct{})
+	}
+
+	s.declined[orderID][claims.ID] = struct{}{}
+
+	s.logger.Infow("Order declined by courier", "orderId", orderID, "courierId", claims.ID)
+
+	return nil
+}
+
+// UpdateStatus переводит доставку дальше: принятый заказ забран, забранный вручен.
+// Врученный заказ завершается.
+func (s *CourierService) UpdateStatus(
+	ctx context.Context,
+	orderID string,
+	status models.DeliveryStatus,
+) (models.CourierOrder, error) {
+	var from models.DeliveryStatus
+
+	switch status {
+	case models.DeliveryStatusPickedUp:
+		from = models.DeliveryStatusAccepted
+	case models.DeliveryStatusDelivered:
+		from = models.DeliveryStatusPickedUp
+	default:
+		return models.CourierOrder{}, fmt.Errorf(
+			"%w: unknown status %s, should be picked_up or delivered", models.ErrBadRequest, status)
+	}
+
+	return s.updateOwn(ctx, orderID, func(order *models.Order, delivery *models.OrderDelivery) error {
+		if delivery.Status != from {
+			return fmt.Errorf("%w: can't change status from %s to %s", models.ErrBadRequest, delivery.Status, status)
+		}
+
+		delivery.Status = status
+
+		if status == models.DeliveryStatusDelivered {
+			order.Status = models.OrderStatusCompleted
+			order.DeliveryDate = formatRu(s.clock.Now())
+		}
+
+		return nil
+	})
+}
+
+// SetPhoto прикрепляет фото доставки, загруженное через /uploads
+func (s *CourierService) SetPhoto(ctx context.Context, orderID, photo string) (models.CourierOrder, error) {
+	photoURL, err := s.photos.ResolveUpload(photo)
+	if err != nil {
+		return models.CourierOrder{}, fmt.Errorf("photo: %w", err)
+	}
+
+	return s.updateOwn(ctx, orderID, func(_ *models.Order, delivery *models.OrderDelivery) error {
+		if delivery.Status != models.DeliveryStatusPickedUp && delivery.Status != models.DeliveryStatusDelivered {
+			return fmt.Errorf("%w: photo can be added after pick up", models.ErrBadRequest)
+		}
+
+		delivery.PhotoURL = photoURL
+
+		return nil
+	})
+}
+
+// updateOwn меняет доставку заказа, назначенного курьеру из контекста
+func (s *CourierService) updateOwn(
+	ctx context.Context,
+	orderID string,
+	update func(order *models.Order, delivery *models.OrderDelivery) error,
+) (models.CourierOrder, error) {
+	claims := models.ClaimsFromContext(ctx)
+
+	order, err := s.orders.UpdateDelivery(ctx, orderID, func(order *models.Order) error {
+		// Чужие заказы неотличимы от несуществующих
+		if order.Delivery == nil || order.Delivery.CourierID != claims.ID {
+			return fmt.Errorf("%w: order is not assigned to you", models.ErrNotFound)
+		}
+
+		if err := update(order, order.Delivery); err != nil {
+			return err
+		}
+
+		order.Delivery.UpdatedAt = s.clock.Now()
+
+		return nil
+	})
+	if err != nil {
+		return models.CourierOrder{}, err
+	}
+
+	s.logger.Infow("Delivery updated",
+		"orderId", orderID, "courierId", claims.ID, "status", order.Delivery.Status)
+
+	return toCourierOrder(order), nil
+}
+
+func toCourierOrder(order models.Order) models.CourierOrder {
+	result := models.CourierOrder{
+		ID:         order.ID,
+		Address:    order.Address,
+		Items:      order.Items,
+		TotalItems: order.TotalItems,
+		TotalPrice: order.TotalPrice,
+		Options:    order.Options,
+	}
+
+	if order.Payment != nil {
+		result.PaymentMethod = order.Payment.Method
+	}
+
+	if order.Delivery != nil {
+		result.Status = order.Delivery.Status
+		result.PhotoURL = order.Delivery.PhotoURL
+		result.UpdatedAt = order.Delivery.UpdatedAt
+	}
+
+	return result
+}
@@ -0,0 +1,96 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"eats-backend/internal/events"
+	"eats-backend/internal/models"
+	"eats-backend/internal/service"
+)
+
+func courierContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, models.ContextClaimsKey{}, &models.AuthTokenClaims{
+		RegisteredClaims: &jwt.RegisteredClaims{ID: id},
+		Nickname:         id,
+		Role:             models.TokenRoleCourier,
+	})
+}
+
+func TestCourierService_Delivery(t *testing.T) {
+	bus := events.NewBus(zap.NewNop().Sugar())
+
+	var changed []models.Order
+	events.Subscribe(bus, "test", func(_ context.Context, event events.OrderStatusChanged) {
+		changed = append(changed, event.Order)
+	})
+
+	orders := service.NewOrderService(nil, nil, nil, nil, nil, nil, nil, bus, nil, zap.NewNop().Sugar(), map[string][]*models.Order{
+		"user-1": {
+			{ID: "order-1", Status: models.OrderStatusActive, CreatedAt: time.Now().Add(-time.Minute)},
+			{ID: "order-2", Status: models.OrderStatusActive, CreatedAt: time.Now()},
+		},
+	})
+	couriers := service.NewCourierService(orders, testReviewImages{"photo.jxl": "http://uploads/photo.jxl"}, nil,
+		zap.NewNop().Sugar())
+
+	first := courierContext(t.Context(), "courier-1")
+	second := courierContext(t.Context(), "courier-2")
+
+	// Старый заказ предлагается первым, второму курьеру достается следующий
+	offered, err := couriers.GetOrders(first)
+	require.NoError(t, err)
+	require.Len(t, offered, 1)
+	require.Equal(t, "order-1", offered[0].ID)
+	require.Equal(t, models.DeliveryStatusAssigned, offered[0].Status)
+
+	offered, err = couriers.GetOrders(second)
+	require.NoError(t, err)
+	require.Len(t, offered, 1)
+	require.Equal(t, "order-2", offered[0].ID)
+
+	_, err = couriers.AcceptOrder(second, "order-1")
+	require.ErrorIs(t, err, models.ErrNotFound)
+
+	// После отказа заказ уходит другому курьеру, а отказавшемуся больше не предлагается
+	require.NoError(t, couriers.DeclineOrder(first, "order-1"))
+
+	offered, err = couriers.GetOrders(first)
+	require.NoError(t, err)
+	require.Empty(t, offered)
+
+	_, err = couriers.AcceptOrder(second, "order-2")
+	require.NoError(t, err)
+
+	offered, err = couriers.GetOrders(second)
+	require.NoError(t, err)
+	require.Len(t, offered, 2)
+
+	_, err = couriers.AcceptOrder(second, "order-1")
+	require.NoError(t, err)
+
+	_, err = couriers.UpdateStatus(second, "order-1", models.DeliveryStatusDelivered)
+	require.ErrorIs(t, err, models.ErrBadRequest)
+
+	_, err = couriers.UpdateStatus(second, "order-1", models.DeliveryStatusPickedUp)
+	require.NoError(t, err)
+
+	_, err = couriers.SetPhoto(second, "order-1", "photo.jxl")
+	require.NoError(t, err)
+
+	delivered, err := couriers.UpdateStatus(second, "order-1", models.DeliveryStatusDelivered)
+	require.NoError(t, err)
+	require.Equal(t, models.DeliveryStatusDelivered, delivered.Status)
+	require.Equal(t, "http://uploads/photo.jxl", delivered.PhotoURL)
+
+	order, err := orders.GetOrder(models.ContextWithUser(t.Context(), "user-1"), "order-1")
+	require.NoError(t, err)
+	require.Equal(t, models.OrderStatusCompleted, order.Status)
+	require.Equal(t, "courier-2", order.Delivery.Courier)
+	require.Len(t, changed, 1)
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"math"
+	"time"
+
+	"eats-backend/internal/models"
+)
+
+const (
+	earthRadiusKm = 6371.0
+
+	// Базовое время сборки и доставки заказа в минутах и добавка за каждый километр.
+	baseDeliveryTime  = 15
+	deliveryTimePerKm = 3
+)
+
+// DeliveryCalculator считает стоимость и время доставки по расстоянию от магазина до адреса
+// и проверяет условия доставки: минимальную сумму заказа, порог бесплатной доставки и часы работы.
+type DeliveryCalculator struct {
+	// Массив [долгота, широта], как в адресах пользователей.
+	storeCoordinates []float64
+	basePrice        models.Money
+	pricePerKm       models.Money
+
+	// Нулевые значения отключают ограничение.
+	minOrderAmount        models.Money
+	freeDeliveryThreshold models.Money
+
+	hours *WorkingHours
+	clock Clock
+}
+
+func NewDeliveryCalculator(
+	storeCoordinates []float64,
+	basePrice, pricePerKm models.Money,
+	minOrderAmount, freeDeliveryThreshold models.Money,
+	hours *WorkingHours,
+	clock Clock,
+) *DeliveryCalculator {
+	return &DeliveryCalculator{
+		storeCoordinates:      storeCoordinates,
+		basePrice:             basePrice,
+		pricePerKm:            pricePerKm,
+		minOrderAmount:        minOrderAmount,
+		freeDeliveryThreshold: freeDeliveryThreshold,
+		hours:                 hours,
+		clock:                 clockOrSystem(clock),
+	}
+}
+
+// Calculate возвращает расстояние в км, стоимость и время доставки в минутах.
+// Каждый начатый километр тарифицируется целиком.
+func (c *DeliveryCalculator) Calculate(address models.Address, orderPrice models.Money) (float64, models.Money, int) {
+	if len(address.Coordinates) != 2 || len(c.storeCoordinates) != 2 {
+		return 0, c.priceFor(orderPrice, c.basePrice), baseDeliveryTime
+	}
+
+	distance := haversineKm(c.storeCoordinates, address.Coordinates)
+	km := int(math.Ceil(distance))
+
+	return math.Round(distance*10) / 10, c.priceFor(orderPrice, c.basePrice+c.pricePerKm.Mul(km)), baseDeliveryTime + km*deliveryTimePerKm
+}
+
+// BaseDelivery возвращает стоимость и время доставки без учета адреса, например для корзины
+func (c *DeliveryCalculator) BaseDelivery(orderPrice models.Money) (models.Money, int) {
+	return c.priceFor(orderPrice, c.basePrice), baseDeliveryTime
+}
+
+// CheckMinOrder возвращает ошибку, если стоимость товаров меньше минимальной суммы заказа
+func (c *DeliveryCalculator) CheckMinOrder(orderPrice models.Money) error {
+	if orderPrice < c.minOrderAmount {
+		return &models.MinOrderError{MinOrderAmount: c.minOrderAmount, OrderPrice: orderPrice}
+	}
+
+	return nil
+}
+
+// CheckOrderTime возвращает, с какого момента собирать заказ, оформленный сейчас, или ошибку,
+// если магазин закрыт и заказы к открытию не принимаются
+func (c *DeliveryCalculator) CheckOrderTime(now time.Time) (time.Time, error) {
+	return c.hours.CheckOrderTime(now)
+}
+
+func (c *DeliveryCalculator) Info() models.DeliveryInfo {
+	info := models.DeliveryInfo{
+		MinOrderAmount:        c.minOrderAmount,
+		FreeDeliveryThreshold: c.freeDeliveryThreshold,
+		BaseDeliveryPrice:     c.basePrice,
+		DeliveryPricePerKm:    c.pricePerKm,
+		BaseDeliveryTime:      baseDeliveryTime,
+	}
+
+	open, closesAt, nextOpening := c.hours.Status(c.clock.Now())
+	info.IsOpen = open
+
+	if !closesAt.IsZero() {
+		info.ClosesAt = &closesAt
+	}
+
+	if !nextOpening.IsZero() {
+		info.NextOpening = &nextOpening
+	}
+
+	return info
+}
+
+func (c *DeliveryCalculator) priceFor(orderPrice, price models.Money) models.Money {
+	if c.freeDeliveryThreshold > 0 && orderPrice >= c.freeDeliveryThreshold {
+		return 0
+	}
+
+	return price
+}
+
+// haversineKm расстояние по поверхности Земли между точками [долгота, широта].
+func haversineKm(from, to []float64) float64 {
+	lon1, lat1 := from[0]*math.Pi/180, from[1]*math.Pi/180
+	lon2, lat2 := to[0]*math.Pi/180, to[1]*math.Pi/180
+
+	sinLat := math.Sin((lat2 - lat1) / 2)
+	sinLon := math.Sin((lon2 - lon1) / 2)
+
+	a := sinLat*sinLat + math.Cos(lat1)*math.Cos(lat2)*sinLon*sinLon
+
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
@@ -0,0 +1,244 @@
+package service
+
+import (
+	"cmp"
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"eats-backend/internal/models"
+)
+
+// DemoEpoch момент, от которого отсчитываются даты демо-данных, чтобы на всех скриншотах они совпадали
+var DemoEpoch = time.Date(2025, time.September, 1, 12, 0, 0, 0, time.UTC)
+
+const (
+	demoOrders     = 3
+	demoFavourites = 3
+)
+
+var (
+	demoNames = []string{"Анна", "Иван", "Мария", "Петр", "Елена", "Дмитрий", "Ольга", "Сергей"}
+
+	demoSpendings = []struct {
+		title    string
+		category models.TransactionCategory
+	}{
+		{"Покупка в супермаркете", models.TransactionCategoryFood},
+		{"Кофе в кафе", models.TransactionCategoryFood},
+		{"Заказ доставки еды", models.TransactionCategoryFood},
+		{"Аптека", models.TransactionCategoryOther},
+		{"Транспорт", models.TransactionCategoryOther},
+	}
+)
+
+type DemoCatalog interface {
+	GetAllProducts() []models.Product
+}
+
+type DemoProfiles interface {
+	SeedProfile(userID string, profile models.UserProfile) bool
+}
+
+type DemoWallet interface {
+	SeedWallet(userID string, accounts []models.Account, transactions []models.Transaction) bool
+}
+
+type DemoOrders interface {
+	SeedOrders(userID string, orders []*models.Order) bool
+}
+
+type DemoFavourites interface {
+	SeedFavourites(userID string, productIDs []string) bool
+}
+
+// DemoData данные, которыми демо-режим заполняет нового пользователя.
+type DemoData struct {
+	Profile      models.UserProfile
+	Accounts     []models.Account
+	Transactions []models.Transaction
+	Orders       []*models.Order
+	Favourites   []string
+}
+
+// DemoService в демо-режиме заполняет каждого нового пользователя одинаковыми при каждом запуске данными:
+// профиль с телефоном, кошелек с историей, прошлые заказы и избранное. Данные зависят только от seed
+// и ID пользователя, поэтому скрипты проверки и скриншоты студентов воспроизводимы.
+type DemoService struct {
+	seed       uint64
+	catalog    DemoCatalog
+	profiles   DemoProfiles
+	wallet     DemoWallet
+	orders     DemoOrders
+	favourites DemoFavourites
+
+	// Пользователи, которых уже заполняли с запуска сервера
+	seeded map[string]struct{}
+
+	mux sync.Mutex
+}
+
+func NewDemoService(
+	seed uint64,
+	catalog DemoCatalog,
+	profiles DemoProfiles,
+	wallet DemoWallet,
+	orders DemoOrders,
+	favourites DemoFavourites,
+) *DemoService {
+	return &DemoService{
+		seed:       seed,
+		catalog:    catalog,
+		profiles:   profiles,
+		wallet:     wallet,
+		orders:     orders,
+		favourites: favourites,
+		seeded:     make(map[string]struct{}),
+	}
+}
+
+// EnsureUser заполняет данные пользователя при первом обращении. Сервисы не перезаписывают данные,
+// которые у пользователя уже есть, поэтому повторное заполнение, в том числе после перезапуска, ничего не дублирует.
+func (s *DemoService) EnsureUser(userID string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if _, ok := s.seeded[userID]; ok {
+		return
+	}
+
+	s.seeded[userID] = struct{}{}
+
+	data := s.Generate(userID)
+
+	s.profiles.SeedProfile(userID, data.Profile)
+	s.wallet.SeedWallet(userID, data.Accounts, data.Transactions)
+	s.orders.SeedOrders(userID, data.Orders)
+	s.favourites.SeedFavourites(userID, data.Favourites)
+}
+
+// Generate строит демо-данные пользователя. Для одного seed и пользователя результат всегда одинаковый.
+func (s *DemoService) Generate(userID string) DemoData {
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(userID))
+
+	rng := rand.New(rand.NewPCG(s.seed, hash.Sum64()))
+
+	var phone strings.Builder
+	phone.WriteString("79")
+
+	for range 9 {
+		phone.WriteString(fmt.Sprintf("%d", rng.IntN(10)))
+	}
+
+	data := DemoData{
+		Profile: models.UserProfile{
+			Phone: phone.String(),
+			Name:  demoNames[rng.IntN(len(demoNames))],
+		},
+	}
+
+	data.Accounts, data.Transactions = demoWallet(userID, rng)
+
+	products := s.catalog.GetAllProducts()
+	slices.SortFunc(products, func(a, b models.Product) int { return cmp.Compare(a.ID, b.ID) })
+
+	if len(products) == 0 {
+		return data
+	}
+
+	for i := range demoOrders {
+		data.Orders = append(data.Orders, demoOrder(userID, i, products, rng))
+	}
+
+	for _, index := range rng.Perm(len(products))[:min(demoFavourites, len(products))] {
+		data.Favourites = append(data.Favourites, products[index].ID)
+	}
+
+	slices.Sort(data.Favourites)
+
+	return data
+}
+
+// demoWallet карта с приветственным бонусом и несколькими покупками, баланс сходится с историей
+func demoWallet(userID string, rng *rand.Rand) ([]models.Account, []models.Transaction) {
+	bonus := models.Rubles(5000)
+	transactions := []models.Transaction{{
+		Amount:   bonus,
+		Title:    "Приветственный бонус",
+		Time:     DemoEpoch.Add(-72 * time.Hour),
+		Category: models.TransactionCategoryOther,
+	}}
+
+	balance := bonus
+	for i, spending := range demoSpendings {
+		amount := models.Rubles(100 + rng.IntN(9)*50)
+		balance -= amount
+
+		transactions = append(transactions, models.Transaction{
+			Amount:   -amount,
+			Title:    spending.title,
+			Time:     DemoEpoch.Add(time.Duration(i-len(demoSpendings)) * 12 * time.Hour),
+			Category: spending.category,
+		})
+	}
+
+	accounts := []models.Account{{
+		ID:       uuid.NewSHA1(uuid.NameSpaceOID, []byte(userID+":card")).String(),
+		Type:     models.AccountTypeCard,
+		Balance:  balance,
+		Currency: models.CurrencyRUB,
+	}}
+
+	return accounts, transactions
+}
+
+// demoOrder завершенный заказ из одного-трех товаров каталога. Заказы хранятся от старых к новым,
+// по одному в неделю до DemoEpoch.
+func demoOrder(userID string, index int, products []models.Product, rng *rand.Rand) *models.Order {
+	createdAt := DemoEpoch.AddDate(0, 0, -7*(demoOrders-index))
+
+	order := &models.Order{
+		ID:            uuid.NewSHA1(uuid.NameSpaceOID, []byte(fmt.Sprintf("%s:order:%d", userID, index))).String(),
+		Status:        models.OrderStatusCompleted,
+		DeliveryDate:  formatRu(createdAt.Add(DeliveryTime)),
+		Address:       models.Address{AddressLine: "Москва, ул. Тверская, 1", Label: models.AddressLabelHome},
+		DeliveryPrice: models.Rubles(150),
+		CreatedAt:     createdAt,
+	}
+
+	for _, productIndex := range rng.Perm(len(products))[:min(1+rng.IntN(3), len(products))] {
+		product := products[productIndex]
+		quantity := 1 + rng.IntN(2)
+
+		order.Items = append(order.Items, models.OrderItem{
+			ID:       product.ID,
+			Image:    product.Image,
+			Name:     product.Name,
+			Weight:   product.Weight,
+			Price:    product.Price,
+			Quantity: quantity,
+		})
+
+		order.OrderPrice += product.Price * models.Money(quantity)
+		order.TotalItems += quantity
+	}
+
+	order.TotalPrice = order.OrderPrice + order.DeliveryPrice
+
+	return order
+}
+
+// ResetUser разрешает заполнить пользователя заново после сброса его данных
+func (s *DemoService) ResetUser(userID string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	delete(s.seeded, userID)
+}
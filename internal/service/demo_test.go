@@ -0,0 +1,99 @@
+package service_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"eats-backend/internal/models"
+	"eats-backend/internal/service"
+)
+
+type testDemoCatalog []models.Product
+
+func (c testDemoCatalog) GetAllProducts() []models.Product {
+	return append([]models.Product(nil), c...)
+}
+
+type testDemoOrders map[string][]*models.Order
+
+func (o testDemoOrders) SeedOrders(userID string, orders []*models.Order) bool {
+	if len(o[userID]) > 0 {
+		return false
+	}
+
+	o[userID] = orders
+
+	return true
+}
+
+func TestDemoService_EnsureUser(t *testing.T) {
+	catalog := testDemoCatalog{
+		{ID: "bread", Name: "Хлеб", Price: models.Rubles(50)},
+		{ID: "milk", Name: "Молоко", Price: models.Rubles(90)},
+		{ID: "apple", Name: "Яблоко", Price: models.Rubles(20)},
+		{ID: "cheese", Name: "Сыр", Price: models.Rubles(300)},
+	}
+
+	newDemo := func() (*service.DemoService, *service.UserData, *service.WalletService, testDemoOrders, *service.Favourites) {
+		profiles := service.NewUserData(map[string]*models.UserProfile{
+			"existing": {Phone: "79990000000"},
+		}, nil, nil)
+		wallet := service.NewWalletService(
+			testWalletProfiles{}, testWalletEvents{}, &testWalletGuard{}, testWalletPINs{}, testWalletStats{},
+			testWalletIcons{}, service.NewStaticRates(nil), []models.Currency{models.CurrencyRUB}, nil, zap.NewNop().Sugar(),
+			models.WalletData{},
+		)
+		orders := testDemoOrders{}
+		favourites := service.NewFavouritesService(map[string][]string{})
+
+		return service.NewDemoService(7, catalog, profiles, wallet, orders, favourites), profiles, wallet, orders, favourites
+	}
+
+	demo, profiles, wallet, orders, favourites := newDemo()
+
+	// Данные зависят только от seed и пользователя
+	other, _, _, _, _ := newDemo()
+	require.Equal(t, demo.Generate("student"), other.Generate("student"))
+	require.NotEqual(t, demo.Generate("student").Profile.Phone, demo.Generate("another").Profile.Phone)
+
+	demo.EnsureUser("student")
+	ctx := walletContext(t, "student")
+
+	profile, err := profiles.GetProfile(ctx)
+	require.NoError(t, err)
+	require.Equal(t, demo.Generate("student").Profile, profile)
+
+	// Баланс сходится с историей операций
+	accounts, err := wallet.GetWallet(ctx)
+	require.NoError(t, err)
+	require.Len(t, accounts.Accounts, 1)
+
+	history, err := wallet.GetTransactions(ctx, 1, 100)
+	require.NoError(t, err)
+
+	var sum models.Money
+	for _, transactions := range history.Data {
+		for _, transaction := range transactions {
+			sum += transaction.Amount
+		}
+	}
+	require.Equal(t, accounts.Accounts[0].Balance, sum)
+
+	require.Len(t, orders["student"], 3)
+	require.Len(t, favourites.GetFavourites(ctx), 3)
+
+	// Повторное заполнение после изменений ничего не перезаписывает
+	favourites.RemoveFavourite(ctx, favourites.GetFavourites(ctx)[0])
+	demo.ResetUser("student")
+	demo.EnsureUser("student")
+	require.Len(t, favourites.GetFavourites(ctx), 2)
+	require.Len(t, orders["student"], 3)
+
+	// Существующий профиль не заменяется
+	demo.EnsureUser("existing")
+	profile, err = profiles.GetProfile(walletContext(t, "existing"))
+	require.NoError(t, err)
+	require.Equal(t, "79990000000", profile.Phone)
+}
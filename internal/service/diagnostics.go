@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"maps"
+	"runtime"
+	"sync"
+
+	"eats-backend/internal/models"
+)
+
+// Sizer интерфейс для сервисов, хранящих данные в памяти. Ключи - названия коллекций, значения - число записей.
+type Sizer interface {
+	CollectionSizes(ctx context.Context) map[string]int
+}
+
+// DiagnosticsService собирает состояние процесса для отладки роста памяти на общем сервере
+type DiagnosticsService struct {
+	sizers []Sizer
+
+	mu sync.RWMutex
+}
+
+func NewDiagnosticsService() *DiagnosticsService {
+	return &DiagnosticsService{
+		sizers: make([]Sizer, 0),
+	}
+}
+
+// RegisterSizer регистрирует сервис, размеры коллекций которого попадут в диагностику
+func (s *DiagnosticsService) RegisterSizer(sizer Sizer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sizers = append(s.sizers, sizer)
+}
+
+func (s *DiagnosticsService) GetRuntime(ctx context.Context) models.RuntimeDiagnostics {
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+
+	result := models.RuntimeDiagnostics{
+		Goroutines: runtime.NumGoroutine(),
+		Heap: models.HeapStats{
+			Alloc:        memStats.HeapAlloc,
+			Sys:          memStats.HeapSys,
+			Idle:         memStats.HeapIdle,
+			Released:     memStats.HeapReleased,
+			Objects:      memStats.HeapObjects,
+			TotalAlloc:   memStats.TotalAlloc,
+			NumGC:        memStats.NumGC,
+			PauseTotalNs: memStats.PauseTotalNs,
+		},
+		Collections: make(map[string]int),
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, sizer := range s.sizers {
+		maps.Copy(result.Collections, sizer.CollectionSizes(ctx))
+	}
+
+	return result
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"eats-backend/internal/events"
+	"eats-backend/internal/models"
+)
+
+type VerifiedEmailProvider interface {
+	GetVerifiedEmail(userID string) (string, bool)
+}
+
+type EmailRenderer interface {
+	Render(name string, data any) (string, string, error)
+}
+
+// EmailNotifier отправляет письма о заказах и переводах пользователям с подтвержденным email.
+type EmailNotifier struct {
+	emails   VerifiedEmailProvider
+	mailer   Mailer
+	renderer EmailRenderer
+	logger   *zap.SugaredLogger
+}
+
+func NewEmailNotifier(
+	emails VerifiedEmailProvider,
+	mailer Mailer,
+	renderer EmailRenderer,
+	logger *zap.SugaredLogger,
+) *EmailNotifier {
+	return &EmailNotifier{
+		emails:   emails,
+		mailer:   mailer,
+		renderer: renderer,
+		logger:   logger,
+	}
+}
+
+func (n *EmailNotifier) OrderCreated(ctx context.Context, userID string, order models.Order) {
+	n.send(ctx, userID, "order_created", map[string]any{"Order": order})
+}
+
+func (n *EmailNotifier) OrderStatusChanged(ctx context.Context, userID string, order models.Order) {
+	n.send(ctx, userID, "order_status_changed", map[string]any{"Order": order})
+}
+
+func (n *EmailNotifier) TransferCompleted(ctx context.Context, transfer events.TransferCompleted) {
+	n.send(ctx, transfer.FromUserID, "transfer_sent", map[string]any{
+		"Amount":   transfer.Amount,
+		"Currency": transfer.Currency.Symbol(),
+		"Phone":    transfer.ToPhone,
+		"Balance":  transfer.SenderBalance,
+	})
+
+	n.send(ctx, transfer.ToUserID, "transfer_received", map[string]any{
+		"Amount":   transfer.CreditedAmount,
+		"Currency": transfer.CreditedCurrency.Symbol(),
+		"Phone":    transfer.FromPhone,
+	})
+}
+
+func (n *EmailNotifier) ProductAvailable(ctx context.Context, userID string, product models.Product) {
+	n.send(ctx, userID, "product_available", map[string]any{"Product": product})
+}
+
+func (n *EmailNotifier) SubscriptionFailed(ctx context.Context, userID string, subscription models.Subscription) {
+	n.send(ctx, userID, "subscription_failed", map[string]any{"Subscription": subscription})
+}
+
+// send отправляет письмо в фоне, чтобы не задерживать ответ на запрос.
+func (n *EmailNotifier) send(ctx context.Context, userID, templateName string, data any) {
+	email, ok := n.emails.GetVerifiedEmail(userID)
+	if !ok {
+		return
+	}
+
+	subject, body, err := n.renderer.Render(templateName, data)
+	if err != nil {
+		n.logger.With("module", "email").Errorf("can't render email %s: %v", templateName, err)
+
+		return
+	}
+
+	ctx = context.WithoutCancel(ctx)
+
+	go func() {
+		if err := n.mailer.Send(ctx, email, subject, body); err != nil {
+			n.logger.With("module", "email").Errorf("can't send email %s to user %s: %v", templateName, userID, err)
+		}
+	}()
+}
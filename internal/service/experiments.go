@@ -0,0 +1,257 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"eats-backend/internal/models"
+)
+
+var experimentKeyPattern = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// catalogSortVariants варианты эксперимента с порядком каталога
+var catalogSortVariants = []string{
+	models.ExperimentControl,
+	string(models.ProductSortPriceAsc),
+	string(models.ProductSortPriceDesc),
+	string(models.ProductSortRating),
+	string(models.ProductSortPopularity),
+	string(models.ProductSortNew),
+}
+
+// ExperimentService A/B эксперименты для модуля аналитики. Преподаватель задает эксперименты, а пользователи
+// делятся между вариантами детерминированно по хешу ID пользователя и ключа, без хранения назначений.
+type ExperimentService struct {
+	experiments map[string]*models.Experiment
+
+	mux sync.RWMutex
+}
+
+func NewExperimentService(experiments []*models.Experiment) *ExperimentService {
+	service := &ExperimentService{}
+	service.load(experiments)
+
+	return service
+}
+
+// load заменяет эксперименты копиями, вызывать под s.mux или в конструкторе
+func (s *ExperimentService) load(experiments []*models.Experiment) {
+	s.experiments = make(map[string]*models.Experiment, len(experiments))
+
+	for _, experiment := range experiments {
+		copied := copyExperiment(experiment)
+		s.experiments[experiment.Key] = &copied
+	}
+}
+
+// ListExperiments возвращает все эксперименты по ключу
+func (s *ExperimentService) ListExperiments(_ context.Context) []models.Experiment {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	result := make([]models.Experiment, 0, len(s.experiments))
+	for _, experiment := range s.experiments {
+		result = append(result, copyExperiment(experiment))
+	}
+
+	slices.SortFunc(result, func(a, b models.Experiment) int { return cmp.Compare(a.Key, b.Key) })
+
+	return result
+}
+
+// PutExperiment создает эксперимент или заменяет существующий. При изменении вариантов пользователи
+// распределяются заново.
+func (s *ExperimentService) PutExperiment(
+	_ context.Context,
+	key string,
+	req models.ExperimentRequest,
+) (models.Experiment, error) {
+	if err := validateExperiment(key, req); err != nil {
+		return models.Experiment{}, err
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	now := time.Now()
+
+	experiment, ok := s.experiments[key]
+	if !ok {
+		if len(s.experiments) >= models.MaxExperiments {
+			return models.Experiment{}, fmt.Errorf("%w: too many experiments, max %d", models.ErrBadRequest, models.MaxExperiments)
+		}
+
+		experiment = &models.Experiment{Key: key, CreatedAt: now}
+		s.experiments[key] = experiment
+	}
+
+	experiment.Description = strings.TrimSpace(req.Description)
+	experiment.Variants = slices.Clone(req.Variants)
+	experiment.Active = req.Active
+	experiment.UpdatedAt = now
+
+	return copyExperiment(experiment), nil
+}
+
+// DeleteExperiment удаляет эксперимент
+func (s *ExperimentService) DeleteExperiment(_ context.Context, key string) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if _, ok := s.experiments[key]; !ok {
+		return fmt.Errorf("%w: experiment %s not found", models.ErrNotFound, key)
+	}
+
+	delete(s.experiments, key)
+
+	return nil
+}
+
+// GetAssignments возвращает варианты пользователя во всех активных экспериментах по ключу
+func (s *ExperimentService) GetAssignments(ctx context.Context) []models.ExperimentAssignment {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	result := make([]models.ExperimentAssignment, 0, len(s.experiments))
+	for _, experiment := range s.experiments {
+		if experiment.Active {
+			result = append(result, models.ExperimentAssignment{
+				Experiment: experiment.Key,
+				Variant:    assignVariant(userID, experiment),
+			})
+		}
+	}
+
+	slices.SortFunc(result, func(a, b models.ExperimentAssignment) int {
+		return cmp.Compare(a.Experiment, b.Experiment)
+	})
+
+	return result
+}
+
+// Variant возвращает вариант пользователя в эксперименте, false - если эксперимента нет или он выключен
+func (s *ExperimentService) Variant(ctx context.Context, key string) (string, bool) {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	experiment, ok := s.experiments[key]
+	if !ok || !experiment.Active {
+		return "", false
+	}
+
+	return assignVariant(userID, experiment), true
+}
+
+// assignVariant выбирает вариант по хешу пользователя и ключа с учетом весов
+func assignVariant(userID string, experiment *models.Experiment) string {
+	total := 0
+	for _, variant := range experiment.Variants {
+		total += variant.Weight
+	}
+
+	// Эксперименты из файла данных не проверяются при загрузке
+	if total <= 0 {
+		return models.ExperimentControl
+	}
+
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(experiment.Key + "/" + userID))
+
+	point := int(hash.Sum64() % uint64(total))
+	for _, variant := range experiment.Variants {
+		if point < variant.Weight {
+			return variant.Name
+		}
+
+		point -= variant.Weight
+	}
+
+	return experiment.Variants[len(experiment.Variants)-1].Name
+}
+
+func validateExperiment(key string, req models.ExperimentRequest) error {
+	if len(key) > models.MaxExperimentKey || !experimentKeyPattern.MatchString(key) {
+		return fmt.Errorf(
+			"%w: experiment key must be up to %d lowercase letters, digits, - and _",
+			models.ErrBadRequest, models.MaxExperimentKey,
+		)
+	}
+
+	if len(req.Variants) < 2 || len(req.Variants) > models.MaxExperimentVariants {
+		return fmt.Errorf("%w: experiment must have from 2 to %d variants", models.ErrBadRequest, models.MaxExperimentVariants)
+	}
+
+	seen := make(map[string]struct{}, len(req.Variants))
+
+	for _, variant := range req.Variants {
+		if variant.Name == "" || variant.Weight <= 0 {
+			return fmt.Errorf("%w: variant must have name and positive weight", models.ErrBadRequest)
+		}
+
+		if _, ok := seen[variant.Name]; ok {
+			return fmt.Errorf("%w: duplicate variant %s", models.ErrBadRequest, variant.Name)
+		}
+
+		seen[variant.Name] = struct{}{}
+
+		// Варианты порядка каталога подставляются в GET /products, поэтому должны быть известными порядками
+		if key == models.ExperimentCatalogSort && !slices.Contains(catalogSortVariants, variant.Name) {
+			return fmt.Errorf(
+				"%w: %s variants must be one of %s",
+				models.ErrBadRequest, models.ExperimentCatalogSort, strings.Join(catalogSortVariants, ", "),
+			)
+		}
+	}
+
+	return nil
+}
+
+func copyExperiment(experiment *models.Experiment) models.Experiment {
+	result := *experiment
+	result.Variants = slices.Clone(experiment.Variants)
+
+	return result
+}
+
+// GetBackupData возвращает данные для бэкапа
+func (s *ExperimentService) GetBackupData() interface{} {
+	experiments := s.ListExperiments(context.Background())
+
+	result := make([]*models.Experiment, len(experiments))
+	for i := range experiments {
+		result[i] = &experiments[i]
+	}
+
+	return result
+}
+
+func (s *ExperimentService) GetBackupFileName() string {
+	return "experiments"
+}
+
+// RestoreBackupData заменяет эксперименты данными из бэкапа
+func (s *ExperimentService) RestoreBackupData(data []byte) error {
+	var backup []*models.Experiment
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return fmt.Errorf("can't parse experiments: %w", err)
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.load(backup)
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package service_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"eats-backend/internal/models"
+	"eats-backend/internal/service"
+)
+
+func TestExperimentService_Assignment(t *testing.T) {
+	experiments := service.NewExperimentService(nil)
+
+	_, err := experiments.PutExperiment(t.Context(), models.ExperimentCatalogSort, models.ExperimentRequest{
+		Variants: []models.ExperimentVariant{{Name: models.ExperimentControl, Weight: 1}, {Name: "rating", Weight: 3}},
+		Active:   true,
+	})
+	require.NoError(t, err)
+
+	counts := map[string]int{}
+
+	for i := range 1000 {
+		ctx := models.ContextWithUser(t.Context(), fmt.Sprintf("user-%d", i))
+
+		variant, ok := experiments.Variant(ctx, models.ExperimentCatalogSort)
+		require.True(t, ok)
+
+		// Вариант не меняется между запросами
+		again, _ := experiments.Variant(ctx, models.ExperimentCatalogSort)
+		require.Equal(t, variant, again)
+
+		counts[variant]++
+	}
+
+	// Доли примерно соответствуют весам 1:3
+	require.InDelta(t, 250, counts[models.ExperimentControl], 60)
+	require.InDelta(t, 750, counts["rating"], 60)
+
+	ctx := models.ContextWithUser(t.Context(), "user-1")
+	require.Len(t, experiments.GetAssignments(ctx), 1)
+
+	// Выключенный эксперимент не назначается
+	_, err = experiments.PutExperiment(t.Context(), models.ExperimentCatalogSort, models.ExperimentRequest{
+		Variants: []models.ExperimentVariant{{Name: models.ExperimentControl, Weight: 1}, {Name: "rating", Weight: 1}},
+	})
+	require.NoError(t, err)
+
+	_, ok := experiments.Variant(ctx, models.ExperimentCatalogSort)
+	require.False(t, ok)
+	require.Empty(t, experiments.GetAssignments(ctx))
+
+	// Эксперименты переживают рестарт через бэкап
+	backup, err := json.Marshal(experiments.GetBackupData())
+	require.NoError(t, err)
+
+	restored := service.NewExperimentService(nil)
+	require.NoError(t, restored.RestoreBackupData(backup))
+	restoredList := restored.ListExperiments(t.Context())
+	require.Len(t, restoredList, 1)
+	require.Equal(t, experiments.ListExperiments(t.Context())[0].Variants, restoredList[0].Variants)
+	require.False(t, restoredList[0].Active)
+
+	require.NoError(t, restored.DeleteExperiment(t.Context(), models.ExperimentCatalogSort))
+	require.ErrorIs(t, restored.DeleteExperiment(t.Context(), models.ExperimentCatalogSort), models.ErrNotFound)
+}
+
+func TestExperimentService_Validation(t *testing.T) {
+	experiments := service.NewExperimentService(nil)
+
+	twoVariants := []models.ExperimentVariant{{Name: "control", Weight: 1}, {Name: "badge", Weight: 1}}
+
+	for name, tc := range map[string]struct {
+		key string
+		req models.ExperimentRequest
+	}{
+		"bad key":           {key: "Fee Display", req: models.ExperimentRequest{Variants: twoVariants}},
+		"one variant":       {key: "fee_display", req: models.ExperimentRequest{Variants: twoVariants[:1]}},
+		"zero weight":       {key: "fee_display", req: models.ExperimentRequest{Variants: []models.ExperimentVariant{{Name: "a"}, {Name: "b", Weight: 1}}}},
+		"duplicate variant": {key: "fee_display", req: models.ExperimentRequest{Variants: []models.ExperimentVariant{{Name: "a", Weight: 1}, {Name: "a", Weight: 1}}}},
+		"unknown sort":      {key: models.ExperimentCatalogSort, req: models.ExperimentRequest{Variants: twoVariants}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := experiments.PutExperiment(t.Context(), tc.key, tc.req)
+			require.ErrorIs(t, err, models.ErrBadRequest)
+		})
+	}
+
+	experiment, err := experiments.PutExperiment(t.Context(), "fee_display", models.ExperimentRequest{Variants: twoVariants})
+	require.NoError(t, err)
+	require.Equal(t, "fee_display", experiment.Key)
+}
@@ -0,0 +1,405 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"eats-backend/internal/models"
+)
+
+type CatalogExporter interface {
+	GetAllProducts() []models.Product
+	GetCategories() []models.Category
+}
+
+type OrdersExporter interface {
+	GetAllOrders() map[string][]*models.Order
+	OrdersCreatedBetween(from, to time.Time) map[string][]models.Order
+}
+
+// orderExportFlushEvery через сколько строк выгрузки заказов данные отправляются клиенту.
+const orderExportFlushEvery = 500
+
+// flusher то же, что http.Flusher, чтобы не тянуть net/http в сервис.
+type flusher interface {
+	Flush()
+}
+
+// exportEntity описывает выгружаемый набор данных в обоих форматах.
+type exportEntity struct {
+	data func() any
+	// rows возвращает строки csv, первая строка - заголовок.
+	rows func() [][]string
+}
+
+// ExportService собирает zip-архив с выгрузкой данных для преподавателя.
+type ExportService struct {
+	entities map[string]exportEntity
+	orders   OrdersExporter
+}
+
+func NewExportService(catalog CatalogExporter, orders OrdersExporter) *ExportService {
+	return &ExportService{
+		entities: map[string]exportEntity{
+			"products": {
+				data: func() any { return catalog.GetAllProducts() },
+				rows: func() [][]string { return productRows(catalog.GetAllProducts()) },
+			},
+			"categories": {
+				data: func() any { return catalog.GetCategories() },
+				rows: func() [][]string { return categoryRows(catalog.GetCategories()) },
+			},
+			"orders": {
+				data: func() any { return orders.GetAllOrders() },
+				rows: func() [][]string { return orderRows(orders.GetAllOrders()) },
+			},
+		},
+		orders: orders,
+	}
+}
+
+// ValidateOrders проверяет запрос выгрузки заказов до начала записи ответа.
+func (s *ExportService) ValidateOrders(req *models.OrderExportRequest) error {
+	if req.Format == "" {
+		req.Format = models.ExportFormatCSV
+	}
+
+	if req.Format != models.ExportFormatJSON && req.Format != models.ExportFormatCSV {
+		return fmt.Errorf("%w: unknown format %s, should be csv or json", models.ErrBadRequest, req.Format)
+	}
+
+	if req.To.IsZero() {
+		req.To = time.Now()
+	}
+
+	if !req.From.Before(req.To) {
+		return fmt.Errorf("%w: from should be before to", models.ErrBadRequest)
+	}
+
+	return nil
+}
+
+// WriteOrders пишет заказы за период для бухгалтерии. Заказы копируются под блокировкой сервиса заказов
+// одним снимком, а в ответ пишутся уже без нее. Запрос должен быть проверен через ValidateOrders.
+func (s *ExportService) WriteOrders(ctx context.Context, w io.Writer, req models.OrderExportRequest) error {
+	orders := accountingOrders(s.orders.OrdersCreatedBetween(req.From, req.To))
+
+	if req.Format == models.ExportFormatJSON {
+		return writeAccountingJSON(ctx, w, orders)
+	}
+
+	return writeAccountingCSV(ctx, w, orders)
+}
+
+// Validate проверяет запрос до начала записи архива, чтобы ошибку можно было вернуть обычным ответом.
+func (s *ExportService) Validate(req *models.ExportRequest) error {
+	if req.Format == "" {
+		req.Format = models.ExportFormatJSON
+	}
+
+	if req.Format != models.ExportFormatJSON && req.Format != models.ExportFormatCSV {
+		return fmt.Errorf("%w: unknown format %s, should be json or csv", models.ErrBadRequest, req.Format)
+	}
+
+	if len(req.Entities) == 0 {
+		req.Entities = s.entityNames()
+	}
+
+	for _, entity := range req.Entities {
+		if _, ok := s.entities[entity]; !ok {
+			return fmt.Errorf(
+				"%w: unknown entity %s, available: %s",
+				models.ErrBadRequest,
+				entity,
+				strings.Join(s.entityNames(), ","),
+			)
+		}
+	}
+
+	return nil
+}
+
+// WriteArchive пишет zip-архив с запрошенными наборами данных. Запрос должен быть проверен через Validate.
+func (s *ExportService) WriteArchive(ctx context.Context, w io.Writer, req models.ExportRequest) error {
+	archive := zip.NewWriter(w)
+
+	for _, name := range req.Entities {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		file, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     name + "." + string(req.Format),
+			Method:   zip.Deflate,
+			Modified: time.Now(),
+		})
+		if err != nil {
+			return fmt.Errorf("can't create %s in archive: %w", name, err)
+		}
+
+		entity := s.entities[name]
+
+		switch req.Format {
+		case models.ExportFormatCSV:
+			csvWriter := csv.NewWriter(file)
+			if err := csvWriter.WriteAll(entity.rows()); err != nil {
+				return fmt.Errorf("can't write %s csv: %w", name, err)
+			}
+		default:
+			encoder := json.NewEncoder(file)
+			encoder.SetIndent("", "  ")
+
+			if err := encoder.Encode(entity.data()); err != nil {
+				return fmt.Errorf("can't write %s json: %w", name, err)
+			}
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("can't finish archive: %w", err)
+	}
+
+	return nil
+}
+
+func (s *ExportService) entityNames() []string {
+	names := make([]string, 0, len(s.entities))
+	for name := range s.entities {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	return names
+}
+
+func productRows(products []models.Product) [][]string {
+	rows := [][]string{{"id", "name", "price", "discount", "weight", "rating", "review_count", "tags", "allergens", "available"}}
+
+	for _, product := range products {
+		rows = append(rows, []string{
+			product.ID,
+			product.Name,
+			product.Price.String(),
+			strconv.Itoa(product.Discount),
+			strconv.Itoa(product.Weight),
+			strconv.FormatFloat(float64(product.Rating), 'f', 1, 32),
+			strconv.Itoa(len(product.Reviews)),
+			strings.Join(product.Tags, ";"),
+			strings.Join(product.Allergens, ";"),
+			strconv.FormatBool(product.Available),
+		})
+	}
+
+	return rows
+}
+
+func categoryRows(categories []models.Category) [][]string {
+	rows := [][]string{{"id", "name", "image", "product_count"}}
+
+	for _, category := range categories {
+		rows = append(rows, []string{category.ID, category.Name, category.Image, strconv.Itoa(category.ProductCount)})
+	}
+
+	return rows
+}
+
+// orderRows выгружает по строке на каждую позицию заказа, чтобы таблицу было удобно сводить.
+func orderRows(orders map[string][]*models.Order) [][]string {
+	rows := [][]string{{
+		"user_id", "order_id", "status", "created_at", "address",
+		"order_price", "delivery_price", "total_price",
+		"item_id", "item_name", "item_price", "item_quantity", "item_comment",
+	}}
+
+	userIDs := make([]string, 0, len(orders))
+	for userID := range orders {
+		userIDs = append(userIDs, userID)
+	}
+
+	slices.Sort(userIDs)
+
+	for _, userID := range userIDs {
+		for _, order := range orders[userID] {
+			for _, item := range order.Items {
+				rows = append(rows, []string{
+					userID,
+					order.ID,
+					string(order.Status),
+					order.CreatedAt.Format(time.RFC3339),
+					order.Address.AddressLine,
+					order.OrderPrice.String(),
+					order.DeliveryPrice.String(),
+					order.TotalPrice.String(),
+					item.ID,
+					item.Name,
+					item.Price.String(),
+					strconv.Itoa(item.Quantity),
+					item.Comment,
+				})
+			}
+		}
+	}
+
+	return rows
+}
+
+// accountingOrders собирает заказы для бухгалтерии по времени создания, при равном времени - по номеру.
+func accountingOrders(orders map[string][]models.Order) []models.AccountingOrder {
+	result := make([]models.AccountingOrder, 0)
+
+	for userID, userOrders := range orders {
+		for _, order := range userOrders {
+			accounting := models.AccountingOrder{
+				UserID:        userID,
+				OrderID:       order.ID,
+				Status:        order.Status,
+				CreatedAt:     order.CreatedAt,
+				OrderPrice:    order.OrderPrice,
+				DeliveryPrice: order.DeliveryPrice,
+				ExtrasPrice:   order.ExtrasPrice,
+				Tip:           order.Tip,
+				TotalPrice:    order.TotalPrice,
+				Items:         order.Items,
+			}
+
+			if order.Payment != nil {
+				accounting.PaymentMethod = order.Payment.Method
+			}
+
+			for _, discount := range order.Discounts {
+				accounting.Discount += discount.Amount
+			}
+
+			if order.Refund != nil {
+				accounting.Refunded = order.Refund.Amount
+			}
+
+			result = append(result, accounting)
+		}
+	}
+
+	slices.SortFunc(result, func(a, b models.AccountingOrder) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+
+		return strings.Compare(a.OrderID, b.OrderID)
+	})
+
+	return result
+}
+
+// writeAccountingCSV пишет по строке на каждую позицию заказа, суммы заказа повторяются в каждой его строке.
+func writeAccountingCSV(ctx context.Context, w io.Writer, orders []models.AccountingOrder) error {
+	csvWriter := csv.NewWriter(w)
+
+	header := []string{
+		"created_at", "user_id", "order_id", "status", "payment_method",
+		"order_price", "discount", "delivery_price", "extras_price", "tip", "total_price", "refunded",
+		"item_id", "item_name", "item_price", "item_quantity",
+	}
+	if err := csvWriter.Write(header); err != nil {
+		return fmt.Errorf("can't write orders csv: %w", err)
+	}
+
+	written := 0
+
+	for _, order := range orders {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		for _, item := range order.Items {
+			err := csvWriter.Write([]string{
+				order.CreatedAt.Format(time.RFC3339),
+				order.UserID,
+				order.OrderID,
+				string(order.Status),
+				string(order.PaymentMethod),
+				order.OrderPrice.String(),
+				order.Discount.String(),
+				order.DeliveryPrice.String(),
+				order.ExtrasPrice.String(),
+				order.Tip.String(),
+				order.TotalPrice.String(),
+				order.Refunded.String(),
+				item.ID,
+				item.Name,
+				item.Price.String(),
+				strconv.Itoa(item.Quantity),
+			})
+			if err != nil {
+				return fmt.Errorf("can't write orders csv: %w", err)
+			}
+
+			written++
+			if written%orderExportFlushEvery == 0 {
+				if err := flushExport(csvWriter, w); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return flushExport(csvWriter, w)
+}
+
+// writeAccountingJSON пишет массив заказов по одному, не собирая весь ответ в памяти.
+func writeAccountingJSON(ctx context.Context, w io.Writer, orders []models.AccountingOrder) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return fmt.Errorf("can't write orders json: %w", err)
+	}
+
+	for i, order := range orders {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		buf, err := json.Marshal(order)
+		if err != nil {
+			return fmt.Errorf("can't marshal order %s: %w", order.OrderID, err)
+		}
+
+		if i > 0 {
+			buf = append([]byte{','}, buf...)
+		}
+
+		if _, err := w.Write(buf); err != nil {
+			return fmt.Errorf("can't write orders json: %w", err)
+		}
+
+		if (i+1)%orderExportFlushEvery == 0 {
+			if f, ok := w.(flusher); ok {
+				f.Flush()
+			}
+		}
+	}
+
+	if _, err := io.WriteString(w, "]"); err != nil {
+		return fmt.Errorf("can't write orders json: %w", err)
+	}
+
+	return nil
+}
+
+func flushExport(csvWriter *csv.Writer, w io.Writer) error {
+	csvWriter.Flush()
+	if err := csvWriter.Error(); err != nil {
+		return fmt.Errorf("can't write orders csv: %w", err)
+	}
+
+	if f, ok := w.(flusher); ok {
+		f.Flush()
+	}
+
+	return nil
+}
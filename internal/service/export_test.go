@@ -0,0 +1,100 @@
+package service_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"eats-backend/internal/models"
+	"eats-backend/internal/service"
+)
+
+func TestExportService_WriteOrders(t *testing.T) {
+	day := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+
+	orders := service.NewOrderService(nil, nil, nil, nil, nil, nil, nil, nil, nil, zap.NewNop().Sugar(), map[string][]*models.Order{
+		"user-1": {
+			{ID: "before", Status: models.OrderStatusCompleted, CreatedAt: day.Add(-time.Minute), TotalPrice: models.Rubles(100)},
+			{
+				ID:            "first",
+				Status:        models.OrderStatusCompleted,
+				CreatedAt:     day.Add(time.Hour),
+				OrderPrice:    models.Rubles(500),
+				DeliveryPrice: models.Rubles(99),
+				TotalPrice:    models.Rubles(549),
+				Payment:       &models.OrderPayment{Method: models.PaymentMethodWallet, Amount: models.Rubles(549)},
+				Discounts: []models.OrderDiscount{
+					{Type: models.DiscountTypePromoCode, Amount: models.Rubles(30)},
+					{Type: models.DiscountTypeCombo, Amount: models.Rubles(20)},
+				},
+				Refund: &models.OrderRefund{Status: models.RefundStatusPartial, Amount: models.Rubles(100)},
+				Items: []models.OrderItem{
+					{ID: "p1", Name: "Суп", Price: models.Rubles(200), Quantity: 1},
+					{ID: "p2", Name: "Хлеб, ржаной", Price: models.Rubles(150), Quantity: 2},
+				},
+			},
+		},
+		"user-2": {
+			{
+				ID:         "second",
+				Status:     models.OrderStatusActive,
+				CreatedAt:  day.Add(2 * time.Hour),
+				TotalPrice: models.Rubles(300),
+				Items:      []models.OrderItem{{ID: "p3", Name: "Чай", Price: models.Rubles(300), Quantity: 1}},
+			},
+			{ID: "after", Status: models.OrderStatusActive, CreatedAt: day.AddDate(0, 0, 1), TotalPrice: models.Rubles(100)},
+		},
+	})
+	exports := service.NewExportService(nil, orders)
+
+	// Конец периода не входит в выгрузку
+	req := models.OrderExportRequest{From: day, To: day.AddDate(0, 0, 1)}
+	require.NoError(t, exports.ValidateOrders(&req))
+	require.Equal(t, models.ExportFormatCSV, req.Format)
+
+	var buf bytes.Buffer
+	require.NoError(t, exports.WriteOrders(t.Context(), &buf, req))
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 4)
+	require.Equal(t, "created_at", rows[0][0])
+
+	// По строке на позицию, заказы по времени создания
+	require.Equal(t, []string{
+		day.Add(time.Hour).Format(time.RFC3339), "user-1", "first", "completed", "wallet",
+		"500", "50", "99", "0", "0", "549", "100",
+		"p2", "Хлеб, ржаной", "150", "2",
+	}, rows[2])
+	require.Equal(t, "second", rows[3][2])
+	require.Equal(t, "", rows[3][4])
+
+	req = models.OrderExportRequest{From: day, To: day.AddDate(0, 0, 1), Format: models.ExportFormatJSON}
+	require.NoError(t, exports.ValidateOrders(&req))
+
+	buf.Reset()
+	require.NoError(t, exports.WriteOrders(t.Context(), &buf, req))
+
+	var exported []models.AccountingOrder
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &exported))
+	require.Len(t, exported, 2)
+	require.Equal(t, "first", exported[0].OrderID)
+	require.Equal(t, models.Rubles(50), exported[0].Discount)
+	require.Len(t, exported[0].Items, 2)
+
+	// Пустой период - пустой массив, а не null
+	req = models.OrderExportRequest{From: day.AddDate(1, 0, 0), To: day.AddDate(1, 0, 1), Format: models.ExportFormatJSON}
+	require.NoError(t, exports.ValidateOrders(&req))
+
+	buf.Reset()
+	require.NoError(t, exports.WriteOrders(t.Context(), &buf, req))
+	require.Equal(t, "[]", buf.String())
+
+	require.ErrorIs(t, exports.ValidateOrders(&models.OrderExportRequest{From: day, To: day}), models.ErrBadRequest)
+	require.ErrorIs(t, exports.ValidateOrders(&models.OrderExportRequest{Format: "xml"}), models.ErrBadRequest)
+}
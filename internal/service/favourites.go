@@ -2,6 +2,10 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
 	"sync"
 
 	"eats-backend/internal/models"
@@ -9,12 +13,17 @@ import (
 
 type Favourites struct {
 	favourites map[string]map[string]struct{}
+	// Исходное избранное из файла данных, к нему возвращает ResetUser.
+	seed map[string][]string
 
 	mux sync.Mutex
 }
 
 func NewFavouritesService(favouritesData map[string][]string) *Favourites {
-	result := &Favourites{favourites: make(map[string]map[string]struct{})}
+	result := &Favourites{
+		favourites: make(map[string]map[string]struct{}),
+		seed:       favouritesData,
+	}
 
 	// Преобразуем данные из списка строк в map[string]struct{}
 	for userID, favouriteList := range favouritesData {
@@ -27,6 +36,24 @@ func NewFavouritesService(favouritesData map[string][]string) *Favourites {
 	return result
 }
 
+// SeedFavourites задает избранное пользователю, у которого его еще не было. Возвращает false,
+// если избранное уже есть, даже пустое.
+func (s *Favourites) SeedFavourites(userID string, productIDs []string) bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if _, ok := s.favourites[userID]; ok {
+		return false
+	}
+
+	s.favourites[userID] = make(map[string]struct{}, len(productIDs))
+	for _, productID := range productIDs {
+		s.favourites[userID][productID] = struct{}{}
+	}
+
+	return true
+}
+
 func (s *Favourites) IsFavourite(ctx context.Context, id string) bool {
 	userID := models.ClaimsFromContext(ctx).ID
 
@@ -44,6 +71,16 @@ func (s *Favourites) IsFavourite(ctx context.Context, id string) bool {
 	return has
 }
 
+// GetFavourites возвращает идентификаторы избранных товаров пользователя, отсортированные для стабильных страниц
+func (s *Favourites) GetFavourites(ctx context.Context) []string {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return slices.Sorted(maps.Keys(s.favourites[userID]))
+}
+
 func (s *Favourites) AddFavourite(ctx context.Context, id string) {
 	userID := models.ClaimsFromContext(ctx).ID
 
@@ -75,6 +112,29 @@ func (s *Favourites) RemoveFavourite(ctx context.Context, id string) {
 	delete(s.favourites[userID], id)
 }
 
+// ApplyFavourites применяет операции по порядку под одной блокировкой и возвращает избранное после них
+func (s *Favourites) ApplyFavourites(ctx context.Context, operations []models.FavouriteOperation) []string {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if _, ok := s.favourites[userID]; !ok {
+		s.favourites[userID] = make(map[string]struct{})
+	}
+
+	for _, operation := range operations {
+		switch operation.Action {
+		case models.FavouriteActionAdd:
+			s.favourites[userID][operation.ProductID] = struct{}{}
+		case models.FavouriteActionRemove:
+			delete(s.favourites[userID], operation.ProductID)
+		}
+	}
+
+	return slices.Sorted(maps.Keys(s.favourites[userID]))
+}
+
 // GetBackupData возвращает данные для бэкапа
 func (s *Favourites) GetBackupData() interface{} {
 	s.mux.Lock()
@@ -93,7 +153,55 @@ func (s *Favourites) GetBackupData() interface{} {
 	return backupData
 }
 
+// ResetUser возвращает избранное пользователя к исходному состоянию
+func (s *Favourites) ResetUser(userID string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.favourites[userID] = make(map[string]struct{})
+	for _, productID := range s.seed[userID] {
+		s.favourites[userID][productID] = struct{}{}
+	}
+}
+
 // GetBackupFileName возвращает имя файла для бэкапа
 func (s *Favourites) GetBackupFileName() string {
 	return "user_favourites"
 }
+
+// RestoreBackupData заменяет избранное данными из бэкапа
+func (s *Favourites) RestoreBackupData(data []byte) error {
+	var backup map[string][]string
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return fmt.Errorf("can't parse favourites: %w", err)
+	}
+
+	favourites := make(map[string]map[string]struct{}, len(backup))
+	for userID, favouriteList := range backup {
+		favourites[userID] = make(map[string]struct{}, len(favouriteList))
+		for _, productID := range favouriteList {
+			favourites[userID][productID] = struct{}{}
+		}
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.favourites = favourites
+
+	return nil
+}
+
+// ExportUserData возвращает ID избранных товаров
+func (s *Favourites) ExportUserData(ctx context.Context) (any, error) {
+	favourites := s.GetFavourites(ctx)
+	if favourites == nil {
+		favourites = []string{}
+	}
+
+	return favourites, nil
+}
+
+func (s *Favourites) GetUserExportName() string {
+	return "favourites"
+}
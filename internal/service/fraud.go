@@ -0,0 +1,281 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"eats-backend/internal/models"
+)
+
+// FraudRules ограничения операций кошелька. Нулевое значение отключает правило.
+type FraudRules struct {
+	MaxTransfersPerHour int
+	MaxTopupsPerHour    int
+	// Сколько можно перевести одному получателю за календарный день.
+	MaxAmountPerCounterpartyPerDay models.Money
+	// Перевод новому получателю проходит только через это время после первой попытки.
+	NewRecipientCooldown time.Duration
+}
+
+// maxBlockedOperations сколько заблокированных операций хранится, старые удаляются первыми
+const maxBlockedOperations = 1000
+
+// FraudGuard проверяет операции кошелька по правилам и хранит заблокированные операции для разбора преподавателем.
+type FraudGuard struct {
+	rules  FraudRules
+	clock  Clock
+	logger *zap.SugaredLogger
+
+	blocked []*models.BlockedOperation
+	// Первые попытки перевода новым получателям: userID -> получатель -> время
+	firstAttempts map[string]map[string]time.Time
+
+	mux sync.Mutex
+}
+
+func NewFraudGuard(rules FraudRules, clock Clock, logger *zap.SugaredLogger) *FraudGuard {
+	return &FraudGuard{
+		rules:         rules,
+		clock:         clockOrSystem(clock),
+		logger:        logger,
+		blocked:       make([]*models.BlockedOperation, 0),
+		firstAttempts: make(map[string]map[string]time.Time),
+	}
+}
+
+// Check проверяет операцию по истории транзакций пользователя. Нарушение правила записывается
+// в заблокированные операции и возвращается как *models.FraudError. Разрешенная преподавателем
+// операция проходит без проверки один раз.
+func (g *FraudGuard) Check(op models.WalletOperation, history []models.Transaction) error {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	if approved := g.findApproved(op); approved != nil {
+		approved.Status = models.BlockedOperationCompleted
+
+		return nil
+	}
+
+	rule, retryAfter, violated := g.evaluate(op, history, g.clock.Now())
+	if !violated {
+		return nil
+	}
+
+	// Повтор той же операции не плодит записи, преподаватель разбирает ее один раз
+	if pending := g.findPending(op, rule); pending != nil {
+		return &models.FraudError{Rule: rule, OperationID: pending.ID, RetryAfter: retryAfter}
+	}
+
+	blocked := &models.BlockedOperation{
+		ID:        uuid.NewString(),
+		Operation: op,
+		Rule:      rule,
+		Status:    models.BlockedOperationPending,
+		CreatedAt: g.clock.Now(),
+	}
+	g.blocked = append(g.blocked, blocked)
+
+	if len(g.blocked) > maxBlockedOperations {
+		g.blocked = slices.Delete(g.blocked, 0, len(g.blocked)-maxBlockedOperations)
+	}
+
+	g.logger.Infow("Wallet operation blocked",
+		"operationId", blocked.ID, "rule", rule, "userId", op.UserID, "type", op.Type, "amount", op.Amount)
+
+	return &models.FraudError{Rule: rule, OperationID: blocked.ID, RetryAfter: retryAfter}
+}
+
+func (g *FraudGuard) findApproved(op models.WalletOperation) *models.BlockedOperation {
+	for _, blocked := range g.blocked {
+		if blocked.Status == models.BlockedOperationApproved && blocked.Operation == op {
+			return blocked
+		}
+	}
+
+	return nil
+}
+
+func (g *FraudGuard) findPending(op models.WalletOperation, rule models.FraudRule) *models.BlockedOperation {
+	for _, blocked := range g.blocked {
+		if blocked.Status == models.BlockedOperationPending && blocked.Rule == rule && blocked.Operation == op {
+			return blocked
+		}
+	}
+
+	return nil
+}
+
+// evaluate возвращает первое нарушенное правило и через сколько оно перестанет срабатывать.
+func (g *FraudGuard) evaluate(
+	op models.WalletOperation,
+	history []models.Transaction,
+	now time.Time,
+) (models.FraudRule, time.Duration, bool) {
+	switch op.Type {
+	case models.WalletOperationTopup:
+		if retryAfter, ok := exceedsHourly(history, models.TransactionCategoryTopup, g.rules.MaxTopupsPerHour, now); ok {
+			return models.FraudRuleTopupsPerHour, retryAfter, true
+		}
+	case models.WalletOperationTransfer:
+		if retryAfter, ok := exceedsHourly(history, models.TransactionCategoryTransfer, g.rules.MaxTransfersPerHour, now); ok {
+			return models.FraudRuleTransfersPerHour, retryAfter, true
+		}
+
+		if g.rules.MaxAmountPerCounterpartyPerDay > 0 {
+			today := now.Format("2006-01-02")
+			sent := models.Money(0)
+
+			for _, transaction := range outgoingTransfers(history, op.CounterpartyUserID) {
+				if transaction.Time.Format("2006-01-02") == today {
+					sent -= transaction.Amount
+				}
+			}
+
+			if sent+op.Amount > g.rules.MaxAmountPerCounterpartyPerDay {
+				return models.FraudRuleCounterpartyDailyAmount, 0, true
+			}
+		}
+
+		// Получатель новый, пока ему не было ни одного перевода. Ожидание считается с первой попытки.
+		if g.rules.NewRecipientCooldown > 0 && len(outgoingTransfers(history, op.CounterpartyUserID)) == 0 {
+			first := g.firstAttempt(op.UserID, op.CounterpartyUserID, now)
+
+			if until := first.Add(g.rules.NewRecipientCooldown); now.Before(until) {
+				return models.FraudRuleNewRecipientCooldown, until.Sub(now), true
+			}
+		}
+	}
+
+	return "", 0, false
+}
+
+// firstAttempt возвращает время первой попытки перевода получателю, запоминая now, если попыток не было
+func (g *FraudGuard) firstAttempt(userID, counterpartyUserID string, now time.Time) time.Time {
+	if g.firstAttempts[userID] == nil {
+		g.firstAttempts[userID] = make(map[string]time.Time)
+	}
+
+	first, ok := g.firstAttempts[userID][counterpartyUserID]
+	if !ok {
+		first = now
+		g.firstAttempts[userID][counterpartyUserID] = now
+	}
+
+	return first
+}
+
+// exceedsHourly проверяет, что за последний час уже было limit операций категории.
+// Для переводов считаются только исходящие.
+func exceedsHourly(
+	history []models.Transaction,
+	category models.TransactionCategory,
+	limit int,
+	now time.Time,
+) (time.Duration, bool) {
+	if limit <= 0 {
+		return 0, false
+	}
+
+	since := now.Add(-time.Hour)
+	recent := make([]time.Time, 0)
+
+	for _, transaction := range history {
+		if transaction.Category != category || transaction.Time.Before(since) {
+			continue
+		}
+
+		if category == models.TransactionCategoryTransfer && transaction.Amount >= 0 {
+			continue
+		}
+
+		recent = append(recent, transaction.Time)
+	}
+
+	if len(recent) < limit {
+		return 0, false
+	}
+
+	// Операция станет возможной, когда из окна выйдет самая старая из последних limit операций
+	slices.SortFunc(recent, func(a, b time.Time) int { return a.Compare(b) })
+
+	return recent[len(recent)-limit].Add(time.Hour).Sub(now), true
+}
+
+func outgoingTransfers(history []models.Transaction, counterpartyUserID string) []models.Transaction {
+	result := make([]models.Transaction, 0)
+
+	for _, transaction := range history {
+		if transaction.Category == models.TransactionCategoryTransfer &&
+			transaction.Amount < 0 &&
+			transaction.CounterpartyUserID == counterpartyUserID {
+			result = append(result, transaction)
+		}
+	}
+
+	return result
+}
+
+// GetBlocked возвращает заблокированные операции, новые первыми. Пустой status - все операции.
+func (g *FraudGuard) GetBlocked(_ context.Context, status models.BlockedOperationStatus) []models.BlockedOperation {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	result := make([]models.BlockedOperation, 0, len(g.blocked))
+	for _, blocked := range slices.Backward(g.blocked) {
+		if status == "" || blocked.Status == status {
+			result = append(result, *blocked)
+		}
+	}
+
+	return result
+}
+
+// Approve разрешает заблокированную операцию: когда пользователь повторит ее с теми же параметрами,
+// правила не проверяются.
+func (g *FraudGuard) Approve(_ context.Context, id string) (models.BlockedOperation, error) {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	for _, blocked := range g.blocked {
+		if blocked.ID != id {
+			continue
+		}
+
+		if blocked.Status != models.BlockedOperationPending {
+			return models.BlockedOperation{}, fmt.Errorf("%w: operation is already %s", models.ErrBadRequest, blocked.Status)
+		}
+
+		blocked.Status = models.BlockedOperationApproved
+
+		g.logger.Infow("Blocked wallet operation approved", "operationId", id, "userId", blocked.Operation.UserID)
+
+		return *blocked, nil
+	}
+
+	return models.BlockedOperation{}, fmt.Errorf("%w: blocked operation not found", models.ErrNotFound)
+}
+
+// ResetUser удаляет заблокированные операции пользователя
+func (g *FraudGuard) ResetUser(userID string) {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	g.blocked = slices.DeleteFunc(g.blocked, func(blocked *models.BlockedOperation) bool {
+		return blocked.Operation.UserID == userID
+	})
+
+	delete(g.firstAttempts, userID)
+}
+
+func (g *FraudGuard) CollectionSizes(_ context.Context) map[string]int {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	return map[string]int{"fraud.blocked": len(g.blocked)}
+}
@@ -0,0 +1,102 @@
+package service_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"eats-backend/internal/models"
+	"eats-backend/internal/service"
+)
+
+func TestFraudGuard_CounterpartyDailyAmount(t *testing.T) {
+	guard := service.NewFraudGuard(service.FraudRules{MaxAmountPerCounterpartyPerDay: models.Rubles(1000)}, nil, zap.NewNop().Sugar())
+
+	history := []models.Transaction{
+		{Amount: models.Rubles(-700), Time: time.Now(), Category: models.TransactionCategoryTransfer, CounterpartyUserID: "bob"},
+		{Amount: models.Rubles(500), Time: time.Now(), Category: models.TransactionCategoryTransfer, CounterpartyUserID: "bob"},
+	}
+
+	op := models.WalletOperation{
+		Type: models.WalletOperationTransfer, UserID: "alice", AccountID: "card", Amount: models.Rubles(400), CounterpartyUserID: "bob",
+	}
+
+	err := guard.Check(op, history)
+
+	var fraudErr *models.FraudError
+	require.ErrorAs(t, err, &fraudErr)
+	require.Equal(t, models.FraudRuleCounterpartyDailyAmount, fraudErr.Rule)
+	require.True(t, errors.Is(err, models.ErrBadRequest))
+
+	// Другому получателю лимит не мешает
+	other := op
+	other.CounterpartyUserID = "carol"
+	require.NoError(t, guard.Check(other, history))
+
+	// Разрешенная операция проходит один раз
+	_, err = guard.Approve(t.Context(), fraudErr.OperationID)
+	require.NoError(t, err)
+	require.NoError(t, guard.Check(op, history))
+	require.Error(t, guard.Check(op, history))
+
+	require.Len(t, guard.GetBlocked(t.Context(), models.BlockedOperationPending), 1)
+	require.Len(t, guard.GetBlocked(t.Context(), models.BlockedOperationCompleted), 1)
+}
+
+func TestFraudGuard_NewRecipientCooldown(t *testing.T) {
+	clock := service.NewFakeClock(time.Date(2025, time.March, 3, 12, 0, 0, 0, time.UTC))
+	guard := service.NewFraudGuard(service.FraudRules{NewRecipientCooldown: time.Hour}, clock, zap.NewNop().Sugar())
+
+	op := models.WalletOperation{
+		Type: models.WalletOperationTransfer, UserID: "alice", AccountID: "card", Amount: models.Rubles(100), CounterpartyUserID: "bob",
+	}
+
+	// Первый перевод новому получателю задерживается
+	err := guard.Check(op, nil)
+
+	var fraudErr *models.FraudError
+	require.ErrorAs(t, err, &fraudErr)
+	require.Equal(t, models.FraudRuleNewRecipientCooldown, fraudErr.Rule)
+	require.Equal(t, time.Hour, fraudErr.RetryAfter)
+
+	// Повторы не добавляют записей
+	for range 3 {
+		var retryErr *models.FraudError
+		require.ErrorAs(t, guard.Check(op, nil), &retryErr)
+		require.Equal(t, fraudErr.OperationID, retryErr.OperationID)
+	}
+
+	require.Len(t, guard.GetBlocked(t.Context(), ""), 1)
+
+	// Получателю, которому уже переводили, ожидание не нужно
+	history := []models.Transaction{{
+		Amount: models.Rubles(-50), Time: clock.Now().Add(-time.Minute), Category: models.TransactionCategoryTransfer, CounterpartyUserID: "carol",
+	}}
+
+	toCarol := op
+	toCarol.CounterpartyUserID = "carol"
+	require.NoError(t, guard.Check(toCarol, history))
+
+	// Перевод, разрешенный преподавателем, проходит сразу
+	_, err = guard.Approve(t.Context(), fraudErr.OperationID)
+	require.NoError(t, err)
+	require.NoError(t, guard.Check(op, nil))
+
+	// Ожидание отсчитывается от первой попытки
+	toDave := op
+	toDave.CounterpartyUserID = "dave"
+	require.ErrorAs(t, guard.Check(toDave, nil), &fraudErr)
+
+	clock.Advance(30 * time.Minute)
+	require.ErrorAs(t, guard.Check(toDave, nil), &fraudErr)
+	require.Equal(t, 30*time.Minute, fraudErr.RetryAfter)
+
+	clock.Advance(30 * time.Minute)
+	require.NoError(t, guard.Check(toDave, nil))
+
+	guard.ResetUser("alice")
+	require.Empty(t, guard.GetBlocked(t.Context(), ""))
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/url"
+	"strings"
+	"sync"
+
+	"eats-backend/internal/models"
+)
+
+// Иконки по умолчанию, пути относительно хоста загрузок.
+var defaultIcons = map[models.IconKind]string{
+	models.IconTopup:       "eats-icons/topup.png",
+	models.IconTransferIn:  "eats-icons/transfer-in.png",
+	models.IconTransferOut: "eats-icons/transfer-out.png",
+	models.IconOrder:       "eats-icons/order.png",
+	models.IconRefund:      "eats-icons/refund.png",
+	models.IconOther:       "eats-icons/other.png",
+}
+
+// IconCatalog хранит иконки транзакций по видам. Иконка задается именем файла на хосте загрузок
+// или полным URL, наружу всегда отдается полный URL.
+type IconCatalog struct {
+	host  string
+	icons map[models.IconKind]string
+
+	mux sync.RWMutex
+}
+
+func NewIconCatalog(host string, initialData map[models.IconKind]string) *IconCatalog {
+	icons := maps.Clone(defaultIcons)
+	for kind, icon := range initialData {
+		if _, ok := defaultIcons[kind]; ok && icon != "" {
+			icons[kind] = icon
+		}
+	}
+
+	return &IconCatalog{
+		host:  host,
+		icons: icons,
+	}
+}
+
+// Icon возвращает URL иконки для вида транзакции
+func (c *IconCatalog) Icon(kind models.IconKind) string {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+
+	icon, ok := c.icons[kind]
+	if !ok {
+		icon = c.icons[models.IconOther]
+	}
+
+	return c.resolve(icon)
+}
+
+// GetIcons возвращает URL иконок всех видов транзакций
+func (c *IconCatalog) GetIcons(_ context.Context) map[models.IconKind]string {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+
+	result := make(map[models.IconKind]string, len(c.icons))
+	for kind, icon := range c.icons {
+		result[kind] = c.resolve(icon)
+	}
+
+	return result
+}
+
+// SetIcon меняет иконку вида транзакции. Уже созданные транзакции сохраняют прежнюю иконку.
+func (c *IconCatalog) SetIcon(ctx context.Context, kind models.IconKind, icon string) (map[models.IconKind]string, error) {
+	if _, ok := defaultIcons[kind]; !ok {
+		return nil, fmt.Errorf("%w: unknown icon kind %s", models.ErrBadRequest, kind)
+	}
+
+	icon = strings.TrimSpace(icon)
+	if icon == "" {
+		return nil, fmt.Errorf("%w: icon is required", models.ErrBadRequest)
+	}
+
+	if isAbsoluteURL(icon) {
+		if _, err := url.ParseRequestURI(icon); err != nil {
+			return nil, fmt.Errorf("%w: invalid icon url: %w", models.ErrBadRequest, err)
+		}
+	} else if strings.Contains(icon, "..") {
+		return nil, fmt.Errorf("%w: invalid icon file name", models.ErrBadRequest)
+	}
+
+	c.mux.Lock()
+	c.icons[kind] = icon
+	c.mux.Unlock()
+
+	return c.GetIcons(ctx), nil
+}
+
+func (c *IconCatalog) resolve(icon string) string {
+	if isAbsoluteURL(icon) {
+		return icon
+	}
+
+	return c.host + strings.TrimPrefix(icon, "/")
+}
+
+func isAbsoluteURL(value string) bool {
+	return strings.HasPrefix(value, "http://") || strings.HasPrefix(value, "https://")
+}
+
+// transactionIconKind определяет вид транзакции по категории и знаку суммы
+func transactionIconKind(transaction models.Transaction) models.IconKind {
+	switch {
+	case transaction.Category == models.TransactionCategoryTopup:
+		return models.IconTopup
+	case transaction.Category == models.TransactionCategoryTransfer && transaction.Amount < 0:
+		return models.IconTransferOut
+	case transaction.Category == models.TransactionCategoryTransfer:
+		return models.IconTransferIn
+	case transaction.Category == models.TransactionCategoryRefund,
+		transaction.OrderID != "" && transaction.Amount > 0:
+		return models.IconRefund
+	case transaction.OrderID != "" || transaction.Category == models.TransactionCategoryFood:
+		return models.IconOrder
+	default:
+		return models.IconOther
+	}
+}
+
+// GetBackupData возвращает данные для бэкапа
+func (c *IconCatalog) GetBackupData() interface{} {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+
+	return maps.Clone(c.icons)
+}
+
+// GetBackupFileName возвращает имя файла для бэкапа
+func (c *IconCatalog) GetBackupFileName() string {
+	return "transaction_icons"
+}
+
+// RestoreBackupData заменяет иконки данными из бэкапа, недостающие виды получают иконку по умолчанию
+func (c *IconCatalog) RestoreBackupData(data []byte) error {
+	var backup map[models.IconKind]string
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return fmt.Errorf("can't parse icons: %w", err)
+	}
+
+	restored := NewIconCatalog(c.host, backup)
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.icons = restored.icons
+
+	return nil
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// LeaderLock блокировка, которую одновременно держит не больше одного экземпляра сервера
+type LeaderLock interface {
+	// TryAcquire захватывает блокировку или продлевает уже захваченную на ttl.
+	// false - блокировку держит другой экземпляр.
+	TryAcquire(ctx context.Context, ttl time.Duration) (bool, error)
+	Release(ctx context.Context) error
+}
+
+// LeaderElection выбирает, какой из экземпляров на общем хранилище выполняет фоновые задачи: бэкапы,
+// подписки и пополнения по расписанию. Без блокировки экземпляр считается единственным и всегда лидер.
+type LeaderElection struct {
+	lock   LeaderLock
+	ttl    time.Duration
+	logger *zap.SugaredLogger
+	// Вызывается перед тем, как экземпляр станет лидером.
+	elected func(ctx context.Context) error
+
+	mux    sync.RWMutex
+	leader bool
+}
+
+func NewLeaderElection(lock LeaderLock, ttl time.Duration, logger *zap.SugaredLogger) *LeaderElection {
+	return &LeaderElection{
+		lock:   lock,
+		ttl:    ttl,
+		logger: logger,
+		leader: lock == nil,
+	}
+}
+
+// IsLeader выполняет ли этот экземпляр фоновые задачи
+func (l *LeaderElection) IsLeader() bool {
+	l.mux.RLock()
+	defer l.mux.RUnlock()
+
+	return l.leader
+}
+
+// OnElected задает, что сделать перед тем, как экземпляр станет лидером, например перечитать состояние,
+// которое менял прежний лидер. Если fn вернула ошибку, экземпляр отдает блокировку и остается ведомым
+// до следующей попытки. Вызывать до Run.
+func (l *LeaderElection) OnElected(fn func(ctx context.Context) error) {
+	l.elected = fn
+}
+
+// Run запускает jobs, пока экземпляр лидер, и останавливает их, если лидерство потеряно.
+// Блокировка продлевается каждую треть ttl, чтобы не истечь между попытками. После отмены ctx
+// лидерство сохраняется до Release: так лидер успеет сделать финальный бэкап.
+func (l *LeaderElection) Run(ctx context.Context, jobs ...func(ctx context.Context)) {
+	if l.lock == nil {
+		l.runJobs(ctx, jobs)
+
+		return
+	}
+
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+
+	var (
+		stopJobs context.CancelFunc
+		running  sync.WaitGroup
+	)
+
+	stop := func() {
+		if stopJobs != nil {
+			stopJobs()
+			running.Wait()
+
+			stopJobs = nil
+		}
+	}
+
+	defer stop()
+
+	for {
+		acquired, err := l.lock.TryAcquire(ctx, l.ttl)
+		if err != nil && ctx.Err() == nil {
+			// Без ответа от блокировки нельзя быть уверенным, что другой экземпляр не стал лидером
+			l.logger.Errorf("Can't acquire leader lock: %v", err)
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		if acquired && stopJobs == nil && l.elected != nil {
+			if err := l.elected(ctx); err != nil {
+				l.logger.Errorf("Can't take over leadership: %v", err)
+
+				acquired = false
+				if err := l.lock.Release(ctx); err != nil {
+					l.logger.Errorf("Can't release leader lock: %v", err)
+				}
+			}
+		}
+
+		l.setLeader(acquired)
+
+		if acquired && stopJobs == nil {
+			l.logger.Info("This instance became the leader, starting background jobs")
+
+			var jobsCtx context.Context
+			jobsCtx, stopJobs = context.WithCancel(ctx)
+
+			running.Add(1)
+			go func() {
+				defer running.Done()
+				l.runJobs(jobsCtx, jobs)
+			}()
+		}
+
+		if !acquired && stopJobs != nil {
+			l.logger.Warn("Leadership lost, stopping background jobs")
+			stop()
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Release отдает лидерство, чтобы другой экземпляр подхватил задачи, не дожидаясь истечения блокировки
+func (l *LeaderElection) Release(ctx context.Context) error {
+	if l.lock == nil {
+		return nil
+	}
+
+	l.setLeader(false)
+
+	return l.lock.Release(ctx)
+}
+
+func (l *LeaderElection) setLeader(leader bool) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	l.leader = leader
+}
+
+func (l *LeaderElection) runJobs(ctx context.Context, jobs []func(ctx context.Context)) {
+	var wg sync.WaitGroup
+
+	for _, job := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			job(ctx)
+		}()
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,237 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"eats-backend/internal/service"
+)
+
+// sharedLock блокировка в памяти, общая для нескольких экземпляров
+type sharedLock struct {
+	mux   *sync.Mutex
+	owner *string
+	id    string
+}
+
+func newSharedLocks(ids ...string) []*sharedLock {
+	mux := &sync.Mutex{}
+	owner := new(string)
+
+	locks := make([]*sharedLock, len(ids))
+	for i, id := range ids {
+		locks[i] = &sharedLock{mux: mux, owner: owner, id: id}
+	}
+
+	return locks
+}
+
+func (l *sharedLock) TryAcquire(_ context.Context, _ time.Duration) (bool, error) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	if *l.owner == "" {
+		*l.owner = l.id
+	}
+
+	return *l.owner == l.id, nil
+}
+
+func (l *sharedLock) Release(_ context.Context) error {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	if *l.owner == l.id {
+		*l.owner = ""
+	}
+
+	return nil
+}
+
+func TestLeaderElection_WithoutLockAlwaysLeader(t *testing.T) {
+	election := service.NewLeaderElection(nil, time.Second, zap.NewNop().Sugar())
+	require.True(t, election.IsLeader())
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var runs atomic.Int32
+
+	done := make(chan struct{})
+	go func() {
+		job := func(ctx context.Context) {
+			runs.Add(1)
+			<-ctx.Done()
+		}
+
+		election.Run(ctx, job, job)
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool { return runs.Load() == 2 }, time.Second, time.Millisecond)
+
+	cancel()
+	<-done
+}
+
+func TestLeaderElection_OnlyOneInstanceRunsJobs(t *testing.T) {
+	locks := newSharedLocks("first", "second")
+	ttl := 30 * time.Millisecond
+
+	first := service.NewLeaderElection(locks[0], ttl, zap.NewNop().Sugar())
+	second := service.NewLeaderElection(locks[1], ttl, zap.NewNop().Sugar())
+
+	var running atomic.Int32
+	job := func(ctx context.Context) {
+		running.Add(1)
+		<-ctx.Done()
+		running.Add(-1)
+	}
+
+	firstCtx, stopFirst := context.WithCancel(context.Background())
+	firstDone := make(chan struct{})
+	go func() {
+		first.Run(firstCtx, job)
+		close(firstDone)
+	}()
+
+	require.Eventually(t, first.IsLeader, time.Second, time.Millisecond)
+
+	secondCtx, stopSecond := context.WithCancel(context.Background())
+	defer stopSecond()
+
+	go second.Run(secondCtx, job)
+
+	time.Sleep(3 * ttl)
+	require.False(t, second.IsLeader())
+	require.Equal(t, int32(1), running.Load())
+
+	// Остановленный лидер остается лидером до Release, чтобы успеть сделать финальный бэкап
+	stopFirst()
+	<-firstDone
+	require.True(t, first.IsLeader())
+	require.Zero(t, running.Load())
+
+	require.NoError(t, first.Release(context.Background()))
+	require.False(t, first.IsLeader())
+
+	require.Eventually(t, second.IsLeader, time.Second, time.Millisecond)
+	require.Eventually(t, func() bool { return running.Load() == 1 }, time.Second, time.Millisecond)
+}
+
+// testStateStore хранилище состояния в памяти, общее для экземпляров
+type testStateStore struct {
+	mux  sync.Mutex
+	data map[string][]byte
+}
+
+func (s *testStateStore) Save(_ context.Context, name string, data any) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.data[name] = raw
+
+	return nil
+}
+
+func (s *testStateStore) Load(_ context.Context, name string, dst any) (bool, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	raw, ok := s.data[name]
+	if !ok {
+		return false, nil
+	}
+
+	return true, json.Unmarshal(raw, dst)
+}
+
+// testCounter состояние одного экземпляра
+type testCounter struct{ value atomic.Int64 }
+
+func (c *testCounter) GetBackupData() interface{} { return c.value.Load() }
+
+func (c *testCounter) GetBackupFileName() string { return "counter" }
+
+func (c *testCounter) RestoreBackupData(data []byte) error {
+	var value int64
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	c.value.Store(value)
+
+	return nil
+}
+
+func TestLeaderElection_HandoverReloadsState(t *testing.T) {
+	locks := newSharedLocks("first", "second")
+	ttl := 30 * time.Millisecond
+	store := &testStateStore{data: make(map[string][]byte)}
+
+	first := service.NewLeaderElection(locks[0], ttl, zap.NewNop().Sugar())
+	firstState := &testCounter{}
+	firstPersistence := service.NewPersistenceService(zap.NewNop().Sugar(), store, time.Hour)
+	firstPersistence.RegisterBackupable(firstState)
+
+	second := service.NewLeaderElection(locks[1], ttl, zap.NewNop().Sugar())
+	secondState := &testCounter{}
+	secondPersistence := service.NewPersistenceService(zap.NewNop().Sugar(), store, time.Hour)
+	secondPersistence.RegisterBackupable(secondState)
+
+	var (
+		attempts  atomic.Int32
+		wasLeader atomic.Bool
+	)
+
+	second.OnElected(func(ctx context.Context) error {
+		// Запросы на запись еще не принимаются, пока состояние не перечитано
+		wasLeader.Store(second.IsLeader())
+
+		if attempts.Add(1) == 1 {
+			return errors.New("store is unavailable")
+		}
+
+		return secondPersistence.Reload(ctx)
+	})
+
+	firstCtx, stopFirst := context.WithCancel(context.Background())
+	firstDone := make(chan struct{})
+	go func() {
+		first.Run(firstCtx)
+		close(firstDone)
+	}()
+
+	require.Eventually(t, first.IsLeader, time.Second, time.Millisecond)
+
+	secondCtx, stopSecond := context.WithCancel(context.Background())
+	defer stopSecond()
+
+	go second.Run(secondCtx)
+
+	// Лидер меняет и сохраняет состояние, ведомый о нем не знает
+	firstState.value.Store(42)
+
+	stopFirst()
+	<-firstDone
+	require.NoError(t, firstPersistence.Persist(context.Background()))
+	require.NoError(t, first.Release(context.Background()))
+
+	// Первая попытка не удалась: блокировка отдана, экземпляр остался ведомым и попробовал снова
+	require.Eventually(t, second.IsLeader, time.Second, time.Millisecond)
+	require.Equal(t, int32(2), attempts.Load())
+	require.False(t, wasLeader.Load())
+	require.Equal(t, int64(42), secondState.value.Load())
+}
@@ -0,0 +1,321 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"eats-backend/internal/events"
+	"eats-backend/internal/models"
+)
+
+// NotificationMailer отправляет письма к уведомлениям, для которых они предусмотрены.
+type NotificationMailer interface {
+	ProductAvailable(ctx context.Context, userID string, product models.Product)
+	SubscriptionFailed(ctx context.Context, userID string, subscription models.Subscription)
+}
+
+// NotificationService хранит уведомления внутри приложения и лист ожидания товаров,
+// которых нет в наличии.
+type NotificationService struct {
+	notifier NotificationMailer
+
+	waitlist      map[string]map[string]struct{}
+	notifications map[string][]models.Notification
+
+	mux sync.RWMutex
+}
+
+func NewNotificationService(notifier NotificationMailer, initialData models.NotificationsData) *NotificationService {
+	service := &NotificationService{
+		notifier:      notifier,
+		waitlist:      make(map[string]map[string]struct{}),
+		notifications: make(map[string][]models.Notification),
+	}
+
+	for productID, userIDs := range initialData.Waitlist {
+		service.waitlist[productID] = make(map[string]struct{}, len(userIDs))
+		for _, userID := range userIDs {
+			service.waitlist[productID][userID] = struct{}{}
+		}
+	}
+
+	for userID, notifications := range initialData.Notifications {
+		service.notifications[userID] = slices.Clone(notifications)
+	}
+
+	return service
+}
+
+// Subscribe добавляет пользователя в лист ожидания товара. Повторная подписка ничего не меняет.
+func (s *NotificationService) Subscribe(ctx context.Context, productID string) {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if _, ok := s.waitlist[productID]; !ok {
+		s.waitlist[productID] = make(map[string]struct{})
+	}
+
+	s.waitlist[productID][userID] = struct{}{}
+}
+
+// ProductUpdated уведомляет лист ожидания, когда товар появился в наличии. Откат каталога
+// событие не публикует, поэтому ожидающие о нем не узнают.
+func (s *NotificationService) ProductUpdated(ctx context.Context, event events.ProductUpdated) {
+	if event.Change == models.CatalogChangeAvailability && event.Product.Available && !event.WasAvailable {
+		s.ProductAvailable(ctx, event.Product)
+	}
+}
+
+// ProductAvailable уведомляет всех, кто ждал товар, и очищает его лист ожидания.
+func (s *NotificationService) ProductAvailable(ctx context.Context, product models.Product) {
+	s.mux.Lock()
+
+	subscribers := slices.Sorted(maps.Keys(s.waitlist[product.ID]))
+	delete(s.waitlist, product.ID)
+
+	for _, userID := range subscribers {
+		s.add(userID, models.Notification{
+			Type:      models.NotificationProductAvailable,
+			Text:      fmt.Sprintf("%s снова в наличии", product.Name),
+			ProductID: product.ID,
+		})
+	}
+
+	s.mux.Unlock()
+
+	// Письма отправляются в фоне, но рендер шаблонов не держит блокировку
+	for _, userID := range subscribers {
+		s.notifier.ProductAvailable(ctx, userID, product)
+	}
+}
+
+// SubscriptionCharged сообщает, что по подписке создан и оплачен заказ. Письмо о заказе отправляет сервис заказов.
+func (s *NotificationService) SubscriptionCharged(_ context.Context, userID string, order models.Order) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.add(userID, models.Notification{
+		Type:    models.NotificationSubscriptionCharged,
+		Text:    fmt.Sprintf("Заказ по подписке оформлен и оплачен: %s ₽", order.TotalPrice),
+		OrderID: order.ID,
+	})
+}
+
+// SubscriptionFailed сообщает, что заказ по подписке не удалось создать, например не хватило денег.
+func (s *NotificationService) SubscriptionFailed(ctx context.Context, userID string, subscription models.Subscription) {
+	s.mux.Lock()
+	s.add(userID, models.Notification{
+		Type:           models.NotificationSubscriptionFailed,
+		Text:           fmt.Sprintf("Не удалось оформить заказ по подписке: %s", subscription.LastError),
+		SubscriptionID: subscription.ID,
+	})
+	s.mux.Unlock()
+
+	s.notifier.SubscriptionFailed(ctx, userID, subscription)
+}
+
+// TopupExecuted сообщает о выполненном регулярном пополнении
+func (s *NotificationService) TopupExecuted(_ context.Context, userID string, topup models.ScheduledTopup) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.add(userID, models.Notification{
+		Type:    models.NotificationTopupExecuted,
+		Text:    fmt.Sprintf("Счет пополнен по расписанию: %s %s", topup.Amount, topup.Currency.Symbol()),
+		TopupID: topup.ID,
+	})
+}
+
+// TopupFailed сообщает, что регулярное пополнение не выполнено, например из-за дневного лимита
+func (s *NotificationService) TopupFailed(_ context.Context, userID string, topup models.ScheduledTopup) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.add(userID, models.Notification{
+		Type:    models.NotificationTopupFailed,
+		Text:    fmt.Sprintf("Не удалось пополнить счет по расписанию: %s", topup.LastError),
+		TopupID: topup.ID,
+	})
+}
+
+// OrderRefunded сообщает о возврате денег за заказ
+func (s *NotificationService) OrderRefunded(_ context.Context, userID string, order models.Order, refund models.Refund) {
+	text := fmt.Sprintf("Возврат за заказ: %s ₽ зачислено на карту", refund.Amount)
+	if order.Status == models.OrderStatusRefunded {
+		text = fmt.Sprintf("Заказ возвращен полностью, %s ₽ зачислено на карту", refund.Amount)
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.add(userID, models.Notification{
+		Type:    models.NotificationOrderRefunded,
+		Text:    text,
+		OrderID: order.ID,
+	})
+}
+
+// OrderDelayed сообщает о задержке заказа и новом времени доставки
+func (s *NotificationService) OrderDelayed(_ context.Context, event events.OrderDelayed) {
+	text := fmt.Sprintf("Заказ задерживается на %d мин", event.Delay.Minutes)
+	if event.Delay.Reason != "" {
+		text += ": " + event.Delay.Reason
+	}
+
+	if event.Order.ETA != nil {
+		text += fmt.Sprintf(". Ожидаемое время доставки: %s", formatRu(*event.Order.ETA))
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.add(event.UserID, models.Notification{
+		Type:    models.NotificationOrderDelayed,
+		Text:    text,
+		OrderID: event.Order.ID,
+	})
+}
+
+// WalletPINResetCode присылает код для сброса PIN кошелька
+func (s *NotificationService) WalletPINResetCode(_ context.Context, userID, code string, expiresAt time.Time) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.add(userID, models.Notification{
+		Type: models.NotificationWalletPINReset,
+		Text: fmt.Sprintf("Код для сброса PIN кошелька: %s. Действует до %s, никому его не сообщайте",
+			code, formatRu(expiresAt)),
+	})
+}
+
+// add добавляет уведомление пользователю, вызывается под блокировкой
+func (s *NotificationService) add(userID string, notification models.Notification) {
+	notification.ID = uuid.NewString()
+	notification.CreatedAt = time.Now()
+
+	s.notifications[userID] = append(s.notifications[userID], notification)
+}
+
+// GetNotifications возвращает уведомления пользователя, новые первыми.
+func (s *NotificationService) GetNotifications(ctx context.Context) []models.Notification {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	result := slices.Clone(s.notifications[userID])
+	slices.Reverse(result)
+
+	if result == nil {
+		result = []models.Notification{}
+	}
+
+	return result
+}
+
+// ResetUser удаляет уведомления пользователя и его подписки на товары
+func (s *NotificationService) ResetUser(userID string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	delete(s.notifications, userID)
+
+	for productID, subscribers := range s.waitlist {
+		delete(subscribers, userID)
+
+		if len(subscribers) == 0 {
+			delete(s.waitlist, productID)
+		}
+	}
+}
+
+// GetBackupData возвращает данные для бэкапа
+func (s *NotificationService) GetBackupData() interface{} {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	data := models.NotificationsData{
+		Waitlist:      make(map[string][]string, len(s.waitlist)),
+		Notifications: make(map[string][]models.Notification, len(s.notifications)),
+	}
+
+	for productID, subscribers := range s.waitlist {
+		data.Waitlist[productID] = slices.Sorted(maps.Keys(subscribers))
+	}
+
+	for userID, notifications := range s.notifications {
+		data.Notifications[userID] = slices.Clone(notifications)
+	}
+
+	return data
+}
+
+// GetBackupFileName возвращает имя файла для бэкапа
+func (s *NotificationService) GetBackupFileName() string {
+	return "notifications"
+}
+
+// RestoreBackupData заменяет уведомления и листы ожидания данными из бэкапа
+func (s *NotificationService) RestoreBackupData(data []byte) error {
+	var backup models.NotificationsData
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return fmt.Errorf("can't parse notifications: %w", err)
+	}
+
+	restored := NewNotificationService(s.notifier, backup)
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.waitlist = restored.waitlist
+	s.notifications = restored.notifications
+
+	return nil
+}
+
+func (s *NotificationService) CollectionSizes(_ context.Context) map[string]int {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	return map[string]int{
+		"notifications.waitlist": len(s.waitlist),
+		"notifications.users":    len(s.notifications),
+	}
+}
+
+// ExportUserData возвращает уведомления пользователя и товары, появления которых он ждет
+func (s *NotificationService) ExportUserData(ctx context.Context) (any, error) {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	s.mux.RLock()
+	waitlist := make([]string, 0)
+	for productID, subscribers := range s.waitlist {
+		if _, ok := subscribers[userID]; ok {
+			waitlist = append(waitlist, productID)
+		}
+	}
+	s.mux.RUnlock()
+
+	slices.Sort(waitlist)
+
+	return struct {
+		Notifications []models.Notification `json:"notifications"`
+		Waitlist      []string              `json:"waitlist"`
+	}{
+		Notifications: s.GetNotifications(ctx),
+		Waitlist:      waitlist,
+	}, nil
+}
+
+func (s *NotificationService) GetUserExportName() string {
+	return "notifications"
+}
@@ -0,0 +1,123 @@
+package service_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"eats-backend/internal/events"
+	"eats-backend/internal/models"
+	"eats-backend/internal/service"
+)
+
+type testWaitlistMailer struct {
+	mux       sync.Mutex
+	available map[string][]string
+}
+
+func (m *testWaitlistMailer) ProductAvailable(_ context.Context, userID string, product models.Product) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	m.available[product.ID] = append(m.available[product.ID], userID)
+}
+
+func (m *testWaitlistMailer) SubscriptionFailed(context.Context, string, models.Subscription) {}
+
+func newWaitlistCatalog(t *testing.T) (*service.ProductsService, *service.NotificationService, *testWaitlistMailer) {
+	t.Helper()
+
+	mailer := &testWaitlistMailer{available: make(map[string][]string)}
+	notifications := service.NewNotificationService(mailer, models.NotificationsData{})
+
+	bus := events.NewBus(zap.NewNop().Sugar())
+	events.Subscribe(bus, "waitlist", notifications.ProductUpdated)
+
+	products := service.NewProductsService(nil, service.NewRecentlyViewed(service.DefaultRecentlyViewedLimit), notifications,
+		service.NewProductPopularity(nil, nil), nil, nil, nil, nil, bus, []*models.Product{
+			{ID: "bread", Name: "Хлеб", Available: true},
+			{ID: "cake", Name: "Торт"},
+		}, map[string][]string{}, map[string]models.Category{})
+
+	return products, notifications, mailer
+}
+
+func TestProductsService_NotifyWhenAvailable(t *testing.T) {
+	products, notifications, mailer := newWaitlistCatalog(t)
+
+	alice := walletContext(t, "alice")
+	bob := walletContext(t, "bob")
+
+	require.ErrorIs(t, products.NotifyWhenAvailable(alice, "missing"), models.ErrNotFound)
+	require.ErrorIs(t, products.NotifyWhenAvailable(alice, "bread"), models.ErrBadRequest)
+
+	require.NoError(t, products.NotifyWhenAvailable(alice, "cake"))
+	require.NoError(t, products.NotifyWhenAvailable(alice, "cake"))
+	require.NoError(t, products.NotifyWhenAvailable(bob, "cake"))
+
+	_, err := products.SetAvailability(t.Context(), "cake", true)
+	require.NoError(t, err)
+
+	// Повторная подписка не дублирует уведомление, письмо уходит каждому
+	received := notifications.GetNotifications(alice)
+	require.Len(t, received, 1)
+	require.Equal(t, models.NotificationProductAvailable, received[0].Type)
+	require.Equal(t, "cake", received[0].ProductID)
+	require.Len(t, notifications.GetNotifications(bob), 1)
+	require.Equal(t, []string{"alice", "bob"}, mailer.available["cake"])
+
+	// Лист ожидания очищен: товар снова пропал и появился, а подписок больше нет
+	_, err = products.SetAvailability(t.Context(), "cake", false)
+	require.NoError(t, err)
+	_, err = products.SetAvailability(t.Context(), "cake", true)
+	require.NoError(t, err)
+	require.Len(t, notifications.GetNotifications(alice), 1)
+}
+
+// testRacingWaitlist пока пользователь подписывается, в фоне возвращает товар в наличие
+type testRacingWaitlist struct {
+	*service.NotificationService
+	products *service.ProductsService
+	restored chan struct{}
+}
+
+func (w *testRacingWaitlist) Subscribe(ctx context.Context, productID string) {
+	go func() {
+		defer close(w.restored)
+
+		_, _ = w.products.SetAvailability(context.Background(), productID, true)
+	}()
+
+	// Даем наличию смениться, если подписка не держит блокировку каталога
+	select {
+	case <-w.restored:
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	w.NotificationService.Subscribe(ctx, productID)
+}
+
+func TestProductsService_NotifyWhenAvailableRace(t *testing.T) {
+	notifications := service.NewNotificationService(&testWaitlistMailer{available: make(map[string][]string)}, models.NotificationsData{})
+
+	bus := events.NewBus(zap.NewNop().Sugar())
+	events.Subscribe(bus, "waitlist", notifications.ProductUpdated)
+
+	waitlist := &testRacingWaitlist{NotificationService: notifications, restored: make(chan struct{})}
+	products := service.NewProductsService(nil, service.NewRecentlyViewed(service.DefaultRecentlyViewedLimit), waitlist,
+		service.NewProductPopularity(nil, nil), nil, nil, nil, nil, bus, []*models.Product{{ID: "cake", Name: "Торт"}},
+		map[string][]string{}, map[string]models.Category{})
+	waitlist.products = products
+
+	ctx := walletContext(t, "alice")
+	require.NoError(t, products.NotifyWhenAvailable(ctx, "cake"))
+
+	<-waitlist.restored
+
+	// Товар появился после подписки, поэтому уведомление пришло
+	require.Len(t, notifications.GetNotifications(ctx), 1)
+}
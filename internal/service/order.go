@@ -1,71 +1,253 @@
 package service
 
 import (
+	"cmp"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
 	"slices"
+	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
+	"eats-backend/internal/events"
+	"eats-backend/internal/metrics"
 	"eats-backend/internal/models"
 
 	"github.com/google/uuid"
+	"go.uber.org/zap"
 )
 
 const DeliveryTime = time.Minute * 10
 
+// CourierDelayStep на сколько сдвигается доставка, когда курьер не успел к ожидаемому времени
+const CourierDelayStep = time.Minute * 5
+
+const courierDelayReason = "Курьер задерживается"
+
 type CartService interface {
-	ClearCart(ctx context.Context)
 	GetCart(ctx context.Context) (models.CartResponse, error)
+	CheckQuantityLimits(ctx context.Context, items []models.OrderItem) error
+}
+
+// OrderCart корзина, из которой оформляется заказ. Позиции резервируются на время оформления
+// и убираются из корзины, только когда заказ сохранен.
+type OrderCart interface {
+	GetCartForAddress(ctx context.Context, addressID string, tip models.Money) (models.CartResponse, error)
+	CheckQuantityLimits(ctx context.Context, items []models.OrderItem) error
+	ReserveItems(ctx context.Context, items []models.OrderItem) error
+	ReleaseItems(ctx context.Context)
+	CheckoutItems(ctx context.Context) error
 }
 
 type AddressChecker interface {
 	GetAddressByID(ctx context.Context, addressID string) (models.Address, error)
 }
 
+type OrderPayer interface {
+	ConfirmPayment(ctx context.Context, pin string, amount models.Money) error
+	PayForOrder(ctx context.Context, orderID string, amount models.Money) (models.OrderPayment, error)
+	CreditRefund(userID, orderID string, amount models.Money, refundID string) error
+}
+
+type PromoCodeApplier interface {
+	ApplyPromoCode(code string, orderPrice models.Money) (models.OrderDiscount, error)
+}
+
+// OrderExtrasResolver проверяет выбранные опции заказа и считает доплату за них
+type OrderExtrasResolver interface {
+	ResolveExtras(selected models.OrderExtras) ([]models.OrderExtra, models.Money, error)
+}
+
+// DeliveryChecker проверяет условия доставки: минимальную сумму и часы работы магазина
+type DeliveryChecker interface {
+	CheckMinOrder(orderPrice models.Money) error
+	CheckOrderTime(now time.Time) (time.Time, error)
+}
+
+type PriceLockVerifier interface {
+	Verify(ctx context.Context, lockID string, items []models.OrderItem) error
+	Release(ctx context.Context, lockID string)
+}
+
+// OrderRepository хранит заказы пользователей
+type OrderRepository interface {
+	// SaveOrder добавляет заказ или заменяет сохраненный с тем же идентификатором.
+	SaveOrder(ctx context.Context, userID string, order models.Order) error
+	DeleteOrder(ctx context.Context, orderID string) error
+	// ReplaceOrders в одной транзакции заменяет все заказы перечисленных пользователей.
+	ReplaceOrders(ctx context.Context, orders map[string][]*models.Order) error
+}
+
+// EventPublisher публикует доменные события для подписчиков: уведомлений, статистики, вебхуков
+type EventPublisher interface {
+	Publish(ctx context.Context, event events.Event)
+}
+
 type OrderService struct {
 	orders         map[string][]*models.Order
 	addressService AddressChecker
-	cartService    CartService
+	cartService    OrderCart
+	walletService  OrderPayer
+	priceLocks     PriceLockVerifier
+	delivery       DeliveryChecker
+	promoCodes     PromoCodeApplier
+	extras         OrderExtrasResolver
+	events         EventPublisher
+	checkout       checkoutCoordinator
+	clock          Clock
+	logger         *zap.SugaredLogger
+
+	// Исходные заказы из файла данных, к ним возвращает ResetUser.
+	seed map[string][]*models.Order
+
+	// Хранилище, в которое записывается каждое изменение заказа, nil - только память.
+	repo OrderRepository
 
 	mux sync.RWMutex
 }
 
-func NewOrderService(addressService AddressChecker, cartService CartService, orders map[string][]*models.Order) *OrderService {
+func NewOrderService(
+	addressService AddressChecker,
+	cartService OrderCart,
+	walletService OrderPayer,
+	priceLocks PriceLockVerifier,
+	delivery DeliveryChecker,
+	promoCodes PromoCodeApplier,
+	extras OrderExtrasResolver,
+	events EventPublisher,
+	clock Clock,
+	logger *zap.SugaredLogger,
+	orders map[string][]*models.Order,
+) *OrderService {
 	return &OrderService{
 		orders:         orders,
+		seed:           copyOrdersPerUser(orders),
 		addressService: addressService,
 		cartService:    cartService,
+		walletService:  walletService,
+		priceLocks:     priceLocks,
+		delivery:       delivery,
+		promoCodes:     promoCodes,
+		extras:         extras,
+		events:         events,
+		checkout:       checkoutCoordinator{logger: logger},
+		clock:          clockOrSystem(clock),
+		logger:         logger,
 	}
 }
 
-func (s *OrderService) GetOrders(ctx context.Context) ([]*models.Order, error) {
-	userID := models.ClaimsFromContext(ctx).ID
+// UseRepository включает запись каждого изменения заказа в репозиторий. Заказ считается оформленным
+// или измененным, только когда изменение записано.
+func (s *OrderService) UseRepository(repo OrderRepository) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
 
-	s.mux.RLock()
-	defer s.mux.RUnlock()
+	s.repo = repo
+}
 
-	if _, ok := s.orders[userID]; !ok {
-		return []*models.Order{}, nil
+// persist записывает измененный заказ в репозиторий. Если запись не удалась, заказ возвращается
+// к before и изменение завершается ошибкой. Вызывается под блокировкой до событий об изменении.
+func (s *OrderService) persist(ctx context.Context, userID string, order *models.Order, before models.Order) error {
+	if s.repo == nil {
+		return nil
 	}
 
-	result := make([]*models.Order, 0, len(s.orders[userID]))
+	// Изменение уже сделано в памяти, отмена запроса не должна оставить его незаписанным
+	if err := s.repo.SaveOrder(context.WithoutCancel(ctx), userID, copyOrder(order)); err != nil {
+		*order = before
 
-	for _, order := range s.orders[userID] {
-		if order.Status == models.OrderStatusActive && order.CreatedAt.Add(DeliveryTime).Before(time.Now()) {
-			order.Status = models.OrderStatusCompleted
-			order.DeliveryDate = formatRu(order.CreatedAt.Add(DeliveryTime))
+		s.logger.Errorw("Failed to save order", "orderId", order.ID, "userId", userID, "error", err)
+
+		return fmt.Errorf("%w: can't save order", models.ErrInternalServer)
+	}
+
+	return nil
+}
+
+// replaceOrders записывает все заказы пользователей после сброса, заполнения демо-данными или восстановления
+// из бэкапа. Если запись не удалась, заказы возвращаются к before. Вызывается под блокировкой.
+func (s *OrderService) replaceOrders(before map[string][]*models.Order) error {
+	if s.repo == nil {
+		return nil
+	}
+
+	orders := make(map[string][]*models.Order, len(before))
+	for userID := range before {
+		orders[userID] = copyOrders(s.orders[userID])
+	}
+
+	if err := s.repo.ReplaceOrders(context.Background(), orders); err != nil {
+		for userID, userOrders := range before {
+			delete(s.orders, userID)
+
+			if userOrders != nil {
+				s.orders[userID] = userOrders
+			}
 		}
 
-		result = append(result, order)
+		s.logger.Errorw("Failed to save orders", "users", slices.Collect(maps.Keys(before)), "error", err)
+
+		return fmt.Errorf("%w: can't save orders", models.ErrInternalServer)
+	}
+
+	return nil
+}
+
+// GetOrders возвращает копии заказов пользователя, новые первыми: сами заказы меняются под блокировкой
+// возвратами и задержками, пока ответ сериализуется.
+func (s *OrderService) GetOrders(ctx context.Context) ([]models.Order, error) {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	// Заказы меняются при чтении: завершаются и задерживаются по времени
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	result := make([]models.Order, 0, len(s.orders[userID]))
+
+	for _, order := range s.orders[userID] {
+		s.refreshOrder(ctx, userID, order)
+
+		result = append(result, copyOrder(order))
 	}
 
 	slices.Reverse(result)
+
 	return result, nil
+}
+
+// refreshOrder завершает или задерживает заказ, время доставки которого прошло. Вызывается под блокировкой.
+func (s *OrderService) refreshOrder(ctx context.Context, userID string, order *models.Order) {
+	now := s.clock.Now()
 
+	// Заказ с курьером завершает сам курьер
+	if order.Status == models.OrderStatusActive && order.Delivery == nil && deliveryOverdue(order, now) {
+		before := copyOrder(order)
+		eta := orderETA(order)
+
+		order.Status = models.OrderStatusCompleted
+		order.DeliveryDate = formatRu(eta)
+		order.History = append(order.History, models.OrderEvent{Type: models.OrderEventDelivered, At: eta})
+
+		// Не записанный заказ остается активным и завершится при следующем чтении
+		if s.persist(ctx, userID, order, before) == nil {
+			s.events.Publish(ctx, events.OrderStatusChanged{UserID: userID, Order: copyOrder(order)})
+		}
+	}
+
+	// Курьер не успел к ожидаемому времени, и доставка сдвигается
+	if order.Status == models.OrderStatusActive && order.Delivery != nil && deliveryOverdue(order, now) {
+		// Ошибку уже записал persist, задержка повторится при следующем чтении
+		_ = s.delay(ctx, userID, order, CourierDelayStep, courierDelayReason)
+	}
 }
 
 func (s *OrderService) MakeNewOrder(ctx context.Context, orderRequest *models.OrderRequest) error {
+	defer models.ObserveTiming(ctx, "orders.make_new_order", time.Now())
+
 	userID := models.ClaimsFromContext(ctx).ID
 
 	address, err := s.addressService.GetAddressByID(ctx, orderRequest.AddressID)
@@ -73,58 +255,546 @@ func (s *OrderService) MakeNewOrder(ctx context.Context, orderRequest *models.Or
 		return fmt.Errorf("get address: %w", err)
 	}
 
-	cart, err := s.cartService.GetCart(ctx)
+	// Доставка считается до адреса заказа, как в предпросмотре, а не до выбранного в корзине.
+	// Чаевые заказ добавляет сам.
+	cart, err := s.cartService.GetCartForAddress(ctx, address.ID, 0)
 	if err != nil {
 		return fmt.Errorf("get cart: %w", err)
 	}
 
-	items := make([]models.OrderItem, 0)
+	// Наборы раскладываются на товары, скидка за набор идет в скидки заказа
+	items, discounts := orderItemsFromCart(cart)
 
-	for _, item := range cart.Items {
-		if !item.Available {
-			continue
+	if len(items) == 0 {
+		return fmt.Errorf("%w: cart is empty", models.ErrBadRequest)
+	}
+
+	// Ограничения могли стать строже после того, как товар положили в корзину
+	if err := s.cartService.CheckQuantityLimits(ctx, items); err != nil {
+		return err
+	}
+
+	if orderRequest.Options.Cutlery < 0 || orderRequest.Options.Cutlery > models.MaxCutlery {
+		return fmt.Errorf("%w: cutlery must be between 0 and %d", models.ErrBadRequest, models.MaxCutlery)
+	}
+
+	switch models.PaymentMethod(orderRequest.PaymentMethod) {
+	case models.PaymentMethodCard, models.PaymentMethodCash, models.PaymentMethodWallet:
+	default:
+		return fmt.Errorf(
+			"%w: unknown payment method %s, should be one of card, cash, wallet",
+			models.ErrBadRequest,
+			orderRequest.PaymentMethod,
+		)
+	}
+
+	if orderRequest.Tip < 0 || orderRequest.Tip > models.MaxTip {
+		return fmt.Errorf("%w: tip must be between 0 and %s", models.ErrBadRequest, models.MaxTip)
+	}
+
+	extras, extrasPrice, err := s.extras.ResolveExtras(orderRequest.Options.Extras)
+	if err != nil {
+		return fmt.Errorf("resolve order extras: %w", err)
+	}
+
+	if err := s.delivery.CheckMinOrder(cart.OrderPrice); err != nil {
+		return err
+	}
+
+	now := s.clock.Now()
+
+	startAt, err := s.delivery.CheckOrderTime(now)
+	if err != nil {
+		return err
+	}
+
+	orderPrice := models.Money(0)
+	totalItems := 0
+
+	for _, item := range items {
+		orderPrice += item.Price.Mul(item.Quantity)
+		totalItems += item.Quantity
+	}
+
+	discountsAmount := models.Money(0)
+	for _, discount := range discounts {
+		discountsAmount += discount.Amount
+	}
+
+	if orderRequest.PromoCode != "" {
+		discount, err := s.promoCodes.ApplyPromoCode(orderRequest.PromoCode, cart.OrderPrice)
+		if err != nil {
+			return fmt.Errorf("apply promo code: %w", err)
 		}
 
-		items = append(items, models.OrderItem{
-			ID:       item.ProductID,
-			Image:    item.Image,
-			Name:     item.Name,
-			Weight:   item.Weight,
-			Price:    item.Price,
-			Quantity: item.Quantity,
-		})
+		discounts = append(discounts, discount)
+		discountsAmount += discount.Amount
 	}
 
-	if len(items) == 0 {
-		return fmt.Errorf("%w: cart is empty", models.ErrBadRequest)
+	totalPrice := orderPrice - discountsAmount + cart.DeliveryPrice + extrasPrice + orderRequest.Tip
+
+	if orderRequest.PriceLockID != "" {
+		if err := s.priceLocks.Verify(ctx, orderRequest.PriceLockID, items); err != nil {
+			return fmt.Errorf("verify price lock: %w", err)
+		}
 	}
 
-	s.cartService.ClearCart(ctx)
+	orderID := uuid.NewString()
+
+	payment := models.OrderPayment{Method: models.PaymentMethod(orderRequest.PaymentMethod), Amount: totalPrice}
+
+	// PIN проверяется до резерва товаров: неверный PIN ничего не меняет
+	if payment.Method == models.PaymentMethodWallet {
+		if err := s.walletService.ConfirmPayment(ctx, orderRequest.PIN, totalPrice); err != nil {
+			metrics.PaymentFailures.Inc("order", paymentFailureReason(err))
+
+			return fmt.Errorf("confirm payment: %w", err)
+		}
+	}
 
 	newOrder := &models.Order{
-		ID:            uuid.NewString(),
+		ID:            orderID,
 		Status:        models.OrderStatusActive,
 		Address:       address,
-		OrderPrice:    cart.OrderPrice,
+		OrderPrice:    orderPrice,
 		DeliveryPrice: cart.DeliveryPrice,
-		TotalPrice:    cart.TotalPrice,
-		TotalItems:    cart.TotalItems,
+		Tip:           orderRequest.Tip,
+		TotalPrice:    totalPrice,
+		TotalItems:    totalItems,
 		Items:         items,
-		CreatedAt:     time.Now(),
+		Options:       orderRequest.Options,
+		CreatedAt:     now,
+		Payment:       &payment,
+		Discounts:     discounts,
+		Extras:        extras,
+		ExtrasPrice:   extrasPrice,
+	}
+
+	if startAt.After(now) {
+		newOrder.ScheduledFor = &startAt
+	}
+
+	eta := deliveryStart(newOrder).Add(DeliveryTime)
+	newOrder.ETA = &eta
+
+	// Корзина очищается последней: если оплата или сохранение заказа не удались, все откатывается,
+	// и корзина остается как была
+	err = s.checkout.run(ctx, orderID,
+		placementStep{
+			name: "reserve cart items",
+			run: func(ctx context.Context) error {
+				// Последняя точка, где заказ можно отменить без последствий
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+
+				return s.cartService.ReserveItems(ctx, cartLines(cart))
+			},
+			compensate: func(ctx context.Context) error {
+				s.cartService.ReleaseItems(ctx)
+
+				return nil
+			},
+		},
+		placementStep{
+			name: "pay for order",
+			run: func(ctx context.Context) error {
+				if payment.Method != models.PaymentMethodWallet {
+					return nil
+				}
+
+				paid, err := s.walletService.PayForOrder(ctx, orderID, totalPrice)
+				if err != nil {
+					metrics.PaymentFailures.Inc("order", paymentFailureReason(err))
+
+					return err
+				}
+
+				payment = paid
+
+				return nil
+			},
+			compensate: func(_ context.Context) error {
+				if payment.TransactionID == "" {
+					return nil
+				}
+
+				return s.walletService.CreditRefund(userID, orderID, payment.Amount, payment.TransactionID)
+			},
+		},
+		placementStep{
+			name: "create order",
+			run: func(ctx context.Context) error {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+
+				return s.insertOrder(ctx, userID, newOrder)
+			},
+			compensate: func(ctx context.Context) error {
+				return s.removeOrder(ctx, userID, orderID)
+			},
+		},
+		placementStep{
+			name: "clear cart",
+			run:  s.cartService.CheckoutItems,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("make order: %w", err)
+	}
+
+	s.events.Publish(ctx, events.OrderCreated{UserID: userID, Order: copyOrder(newOrder)})
+	metrics.OrdersCreated.Inc(string(payment.Method), "cart")
+
+	if orderRequest.PriceLockID != "" {
+		s.priceLocks.Release(ctx, orderRequest.PriceLockID)
+	}
+
+	return nil
+}
+
+// ActiveOrders возвращает активные заказы всех пользователей, старые первыми. Заказы без курьера,
+// которые уже должны были завершиться по времени, и заказы к открытию магазина не попадают в список.
+func (s *OrderService) ActiveOrders(_ context.Context) []models.Order {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	result := make([]models.Order, 0)
+	now := s.clock.Now()
+
+	for _, orders := range s.orders {
+		for _, order := range orders {
+			if order.Status != models.OrderStatusActive || (order.Delivery == nil && deliveryOverdue(order, now)) {
+				continue
+			}
+
+			if order.ScheduledFor != nil && order.ScheduledFor.After(now) {
+				continue
+			}
+
+			result = append(result, copyOrder(order))
+		}
+	}
+
+	slices.SortFunc(result, func(a, b models.Order) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), strings.Compare(a.ID, b.ID))
+	})
+
+	return result
+}
+
+// UpdateDelivery меняет заказ любого пользователя под блокировкой. update получает копию заказа,
+// изменения сохраняются, только если он не вернул ошибку.
+func (s *OrderService) UpdateDelivery(
+	ctx context.Context,
+	orderID string,
+	update func(order *models.Order) error,
+) (models.Order, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	for userID, orders := range s.orders {
+		for i, order := range orders {
+			if order.ID != orderID {
+				continue
+			}
+
+			updated := copyOrder(order)
+			if err := update(&updated); err != nil {
+				return models.Order{}, err
+			}
+
+			if event, ok := deliveryEvent(order.Delivery, updated.Delivery, s.clock.Now()); ok {
+				updated.History = append(updated.History, event)
+			}
+
+			if err := s.persist(ctx, userID, &updated, *order); err != nil {
+				return models.Order{}, err
+			}
+
+			orders[i] = &updated
+
+			if updated.Status != order.Status {
+				s.events.Publish(ctx, events.OrderStatusChanged{UserID: userID, Order: copyOrder(&updated)})
+			}
+
+			return copyOrder(&updated), nil
+		}
+	}
+
+	return models.Order{}, fmt.Errorf("%w: order not found", models.ErrNotFound)
+}
+
+// GetOrder возвращает заказ пользователя по идентификатору
+func (s *OrderService) GetOrder(ctx context.Context, id string) (models.Order, error) {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	for _, order := range s.orders[userID] {
+		if order.ID == id {
+			return copyOrder(order), nil
+		}
+	}
+
+	return models.Order{}, fmt.Errorf("%w: order not found", models.ErrNotFound)
+}
+
+// FindOrder ищет заказ среди заказов всех пользователей и возвращает его вместе с владельцем
+func (s *OrderService) FindOrder(_ context.Context, id string) (string, models.Order, error) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	for userID, orders := range s.orders {
+		for _, order := range orders {
+			if order.ID == id {
+				return userID, copyOrder(order), nil
+			}
+		}
+	}
+
+	return "", models.Order{}, fmt.Errorf("%w: order not found", models.ErrNotFound)
+}
+
+// DelayOrder сдвигает ожидаемое время доставки активного заказа любого пользователя. Если заказ уже
+// опаздывает, задержка отсчитывается от текущего момента. Пользователь получает уведомление с новым временем.
+func (s *OrderService) DelayOrder(ctx context.Context, orderID string, req models.OrderDelayRequest) (models.Order, error) {
+	if req.Minutes <= 0 || req.Minutes > models.MaxOrderDelayMinutes {
+		return models.Order{}, fmt.Errorf(
+			"%w: delay must be between 1 and %d minutes", models.ErrBadRequest, models.MaxOrderDelayMinutes)
+	}
+
+	reason := strings.TrimSpace(req.Reason)
+	if utf8.RuneCountInString(reason) > models.MaxDelayReasonLength {
+		return models.Order{}, fmt.Errorf(
+			"%w: reason must be at most %d characters", models.ErrBadRequest, models.MaxDelayReasonLength)
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	for userID, orders := range s.orders {
+		for _, order := range orders {
+			if order.ID != orderID {
+				continue
+			}
+
+			// Заказ без курьера, время которого прошло, уже считается доставленным
+			if order.Status != models.OrderStatusActive || (order.Delivery == nil && deliveryOverdue(order, s.clock.Now())) {
+				return models.Order{}, fmt.Errorf("%w: order is not active", models.ErrBadRequest)
+			}
+
+			if err := s.delay(ctx, userID, order, time.Duration(req.Minutes)*time.Minute, reason); err != nil {
+				return models.Order{}, fmt.Errorf("delay order: %w", err)
+			}
+
+			return copyOrder(order), nil
+		}
 	}
 
+	return models.Order{}, fmt.Errorf("%w: order not found", models.ErrNotFound)
+}
+
+// delay сдвигает время доставки заказа и публикует событие о задержке, вызывается под блокировкой
+func (s *OrderService) delay(ctx context.Context, userID string, order *models.Order, delay time.Duration, reason string) error {
+	now := s.clock.Now()
+	before := copyOrder(order)
+
+	eta := orderETA(order)
+	if eta.Before(now) {
+		eta = now
+	}
+
+	eta = eta.Add(delay)
+	order.ETA = &eta
+
+	orderDelay := models.OrderDelay{
+		Minutes:   int(delay / time.Minute),
+		Reason:    reason,
+		CreatedAt: now,
+	}
+	order.Delays = append(order.Delays, orderDelay)
+
+	if err := s.persist(ctx, userID, order, before); err != nil {
+		return err
+	}
+
+	s.events.Publish(ctx, events.OrderDelayed{UserID: userID, Order: copyOrder(order), Delay: orderDelay})
+
+	return nil
+}
+
+// AddRefund добавляет возврат к заказу. Когда возвращены все позиции, заказ получает статус refunded.
+func (s *OrderService) AddRefund(userID, orderID string, refund models.Refund) (models.Order, error) {
 	s.mux.Lock()
 	defer s.mux.Unlock()
 
-	if _, ok := s.orders[userID]; !ok {
-		s.orders[userID] = make([]*models.Order, 0)
+	for _, order := range s.orders[userID] {
+		if order.ID != orderID {
+			continue
+		}
+
+		before := copyOrder(order)
+
+		if order.Refund == nil {
+			order.Refund = &models.OrderRefund{Status: models.RefundStatusPartial}
+		}
+
+		order.Refund.Amount += refund.Amount
+		order.Refund.Refunds = append(order.Refund.Refunds, refund)
+
+		if fullyRefunded(*order) && order.Status != models.OrderStatusRefunded {
+			order.Refund.Status = models.RefundStatusFull
+			order.Status = models.OrderStatusRefunded
+		}
+
+		if err := s.persist(context.Background(), userID, order, before); err != nil {
+			return models.Order{}, err
+		}
+
+		if order.Status != before.Status {
+			metrics.OrdersCancelled.Inc()
+		}
+
+		return copyOrder(order), nil
 	}
 
-	s.orders[userID] = append(s.orders[userID], newOrder)
+	return models.Order{}, fmt.Errorf("%w: order not found", models.ErrNotFound)
+}
+
+// PlaceWalletOrder создает заказ с уже рассчитанными позициями и стоимостью, оплачивая его из кошелька.
+// Используется для заказов без корзины, например по подписке.
+func (s *OrderService) PlaceWalletOrder(ctx context.Context, order models.Order) (models.Order, error) {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	if len(order.Items) == 0 {
+		return models.Order{}, fmt.Errorf("%w: order is empty", models.ErrBadRequest)
+	}
+
+	if err := s.delivery.CheckMinOrder(order.OrderPrice); err != nil {
+		return models.Order{}, err
+	}
+
+	order.ID = uuid.NewString()
+	order.Status = models.OrderStatusActive
+	order.CreatedAt = s.clock.Now()
+
+	startAt, err := s.delivery.CheckOrderTime(order.CreatedAt)
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	if startAt.After(order.CreatedAt) {
+		order.ScheduledFor = &startAt
+	}
+
+	eta := deliveryStart(&order).Add(DeliveryTime)
+	order.ETA = &eta
+
+	payment, err := s.walletService.PayForOrder(ctx, order.ID, order.TotalPrice)
+	if err != nil {
+		metrics.PaymentFailures.Inc("subscription", paymentFailureReason(err))
+
+		return models.Order{}, fmt.Errorf("pay for order: %w", err)
+	}
+
+	order.Payment = &payment
+
+	saved := copyOrder(&order)
+	if err := s.insertOrder(ctx, userID, &saved); err != nil {
+		// Заказа нет, деньги возвращаются сразу
+		refundErr := s.walletService.CreditRefund(userID, order.ID, payment.Amount, payment.TransactionID)
+
+		return models.Order{}, fmt.Errorf("save order: %w", errors.Join(err, refundErr))
+	}
+
+	s.events.Publish(ctx, events.OrderCreated{UserID: userID, Order: copyOrder(&saved)})
+	metrics.OrdersCreated.Inc(string(payment.Method), "subscription")
+
+	return order, nil
+}
+
+// paymentFailureReason метка причины отказа в оплате для метрик
+func paymentFailureReason(err error) string {
+	var pinErr *models.WalletPINError
+
+	switch {
+	case errors.Is(err, errInsufficientFunds):
+		return metrics.PaymentReasonInsufficientFunds
+	case errors.As(err, &pinErr):
+		return metrics.PaymentReasonPIN
+	case errors.Is(err, models.ErrBadRequest), errors.Is(err, models.ErrForbidden):
+		return metrics.PaymentReasonDeclined
+	default:
+		return metrics.PaymentReasonError
+	}
+}
+
+// insertOrder сохраняет заказ без события о создании
+func (s *OrderService) insertOrder(ctx context.Context, userID string, order *models.Order) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if len(order.History) == 0 {
+		order.History = []models.OrderEvent{{Type: models.OrderEventCreated, At: order.CreatedAt}}
+	}
+
+	if s.repo != nil {
+		if err := s.repo.SaveOrder(context.WithoutCancel(ctx), userID, copyOrder(order)); err != nil {
+			s.logger.Errorw("Failed to save order", "orderId", order.ID, "userId", userID, "error", err)
+
+			return fmt.Errorf("%w: can't save order", models.ErrInternalServer)
+		}
+	}
+
+	s.orders[userID] = append(s.orders[userID], order)
 
 	return nil
 }
 
+// removeOrder удаляет заказ, оформление которого откатилось. Событие о нем еще не публиковалось.
+func (s *OrderService) removeOrder(ctx context.Context, userID, orderID string) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.repo != nil {
+		if err := s.repo.DeleteOrder(ctx, orderID); err != nil {
+			return fmt.Errorf("delete order %s: %w", orderID, err)
+		}
+	}
+
+	s.orders[userID] = slices.DeleteFunc(s.orders[userID], func(order *models.Order) bool {
+		return order.ID == orderID
+	})
+
+	return nil
+}
+
+// deliveryOverdue заказ без курьера считается доставленным к ожидаемому времени
+func deliveryOverdue(order *models.Order, now time.Time) bool {
+	return orderETA(order).Before(now)
+}
+
+// orderETA ожидаемое время доставки: через DeliveryTime после оформления или после открытия магазина,
+// если заказ оформлен в нерабочее время, плюс задержки
+func orderETA(order *models.Order) time.Time {
+	if order.ETA != nil {
+		return *order.ETA
+	}
+
+	return deliveryStart(order).Add(DeliveryTime)
+}
+
+func deliveryStart(order *models.Order) time.Time {
+	if order.ScheduledFor != nil {
+		return *order.ScheduledFor
+	}
+
+	return order.CreatedAt
+}
+
 func formatRu(t time.Time) string {
 	months := map[time.Month]string{
 		time.January:   "января",
@@ -149,51 +819,210 @@ func formatRu(t time.Time) string {
 	)
 }
 
+// ReferencedUploads возвращает ссылки на фото доставки, чтобы хранилище не удалило их
+func (s *OrderService) ReferencedUploads() []string {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	result := make([]string, 0)
+	for _, orders := range s.orders {
+		for _, order := range orders {
+			if order.Delivery != nil && order.Delivery.PhotoURL != "" {
+				result = append(result, order.Delivery.PhotoURL)
+			}
+		}
+	}
+
+	return result
+}
+
 // GetBackupData возвращает данные для бэкапа
 func (s *OrderService) GetBackupData() interface{} {
+	return s.GetAllOrders()
+}
+
+// GetAllOrders возвращает копию заказов всех пользователей
+func (s *OrderService) GetAllOrders() map[string][]*models.Order {
 	s.mux.RLock()
 	defer s.mux.RUnlock()
 
 	// Создаем копию данных для бэкапа
-	backupData := make(map[string][]*models.Order)
+	return copyOrdersPerUser(s.orders)
+}
+
+// OrdersCreatedBetween копирует заказы, созданные в [from, to), по пользователям. Блокировка держится только
+// на время копирования, поэтому долгая выгрузка не мешает оформлять заказы.
+func (s *OrderService) OrdersCreatedBetween(from, to time.Time) map[string][]models.Order {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	result := make(map[string][]models.Order)
+
 	for userID, orders := range s.orders {
-		backupOrders := make([]*models.Order, len(orders))
-		for i, order := range orders {
-			// Создаем копию заказа
-			backupOrder := &models.Order{
-				ID:            order.ID,
-				Status:        order.Status,
-				Address:       order.Address,
-				OrderPrice:    order.OrderPrice,
-				DeliveryPrice: order.DeliveryPrice,
-				TotalPrice:    order.TotalPrice,
-				TotalItems:    order.TotalItems,
-				Items:         make([]models.OrderItem, len(order.Items)),
-				CreatedAt:     order.CreatedAt,
-				DeliveryDate:  order.DeliveryDate,
+		for _, order := range orders {
+			if order.CreatedAt.Before(from) || !order.CreatedAt.Before(to) {
+				continue
 			}
 
-			// Копируем элементы заказа
-			for j, item := range order.Items {
-				backupOrder.Items[j] = models.OrderItem{
-					ID:       item.ID,
-					Image:    item.Image,
-					Name:     item.Name,
-					Weight:   item.Weight,
-					Price:    item.Price,
-					Quantity: item.Quantity,
-				}
-			}
+			result[userID] = append(result[userID], copyOrder(order))
+		}
+	}
+
+	return result
+}
+
+// ResetUser возвращает заказы пользователя к исходному состоянию
+func (s *OrderService) ResetUser(userID string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	before := map[string][]*models.Order{userID: s.orders[userID]}
+	delete(s.orders, userID)
+
+	if orders, ok := s.seed[userID]; ok {
+		s.orders[userID] = copyOrders(orders)
+	}
 
-			backupOrders[i] = backupOrder
+	// Ошибку уже записал replaceOrders, заказы остались прежними
+	_ = s.replaceOrders(before)
+}
+
+// SeedOrders задает историю заказов пользователю, у которого еще нет заказов. Возвращает false,
+// если заказы уже есть.
+func (s *OrderService) SeedOrders(userID string, orders []*models.Order) bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if len(s.orders[userID]) > 0 || len(orders) == 0 {
+		return false
+	}
+
+	before := map[string][]*models.Order{userID: s.orders[userID]}
+	s.orders[userID] = copyOrders(orders)
+
+	return s.replaceOrders(before) == nil
+}
+
+func copyOrdersPerUser(ordersPerUser map[string][]*models.Order) map[string][]*models.Order {
+	result := make(map[string][]*models.Order, len(ordersPerUser))
+	for userID, orders := range ordersPerUser {
+		result[userID] = copyOrders(orders)
+	}
+
+	return result
+}
+
+func copyOrders(orders []*models.Order) []*models.Order {
+	result := make([]*models.Order, len(orders))
+	for i, order := range orders {
+		orderCopy := copyOrder(order)
+		result[i] = &orderCopy
+	}
+
+	return result
+}
+
+func copyOrder(order *models.Order) models.Order {
+	result := *order
+	result.Items = slices.Clone(order.Items)
+	result.Discounts = slices.Clone(order.Discounts)
+	result.Delays = slices.Clone(order.Delays)
+	result.History = slices.Clone(order.History)
+	result.Extras = slices.Clone(order.Extras)
+	result.Options.Extras = maps.Clone(order.Options.Extras)
+
+	if order.Payment != nil {
+		payment := *order.Payment
+		result.Payment = &payment
+	}
+
+	if order.ScheduledFor != nil {
+		scheduledFor := *order.ScheduledFor
+		result.ScheduledFor = &scheduledFor
+	}
+
+	if order.ETA != nil {
+		eta := *order.ETA
+		result.ETA = &eta
+	}
+
+	if order.Delivery != nil {
+		delivery := *order.Delivery
+		result.Delivery = &delivery
+	}
+
+	if order.Refund != nil {
+		refund := *order.Refund
+		refund.Refunds = make([]models.Refund, len(order.Refund.Refunds))
+		for i, r := range order.Refund.Refunds {
+			r.Items = slices.Clone(r.Items)
+			refund.Refunds[i] = r
 		}
-		backupData[userID] = backupOrders
+
+		result.Refund = &refund
 	}
 
-	return backupData
+	return result
 }
 
 // GetBackupFileName возвращает имя файла для бэкапа
 func (s *OrderService) GetBackupFileName() string {
 	return "orders"
 }
+
+// RestoreBackupData заменяет заказы всех пользователей данными из бэкапа
+func (s *OrderService) RestoreBackupData(data []byte) error {
+	orders := make(map[string][]*models.Order)
+	if err := json.Unmarshal(data, &orders); err != nil {
+		return fmt.Errorf("can't parse orders: %w", err)
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	// В репозиторий записываются и заказы из бэкапа, и удаление заказов пользователей, которых в бэкапе нет
+	before := maps.Clone(s.orders)
+	for userID := range orders {
+		if _, ok := before[userID]; !ok {
+			before[userID] = nil
+		}
+	}
+
+	s.orders = orders
+
+	return s.replaceOrders(before)
+}
+
+// ReplaceOrders заменяет заказы всех пользователей перечитанными из репозитория, в репозиторий они
+// не записываются
+func (s *OrderService) ReplaceOrders(orders map[string][]*models.Order) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.orders = orders
+}
+
+// CollectionSizes возвращает число пользователей с заказами и общее число заказов
+func (s *OrderService) CollectionSizes(_ context.Context) map[string]int {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	orders := 0
+	for _, userOrders := range s.orders {
+		orders += len(userOrders)
+	}
+
+	return map[string]int{
+		"orders.users": len(s.orders),
+		"orders":       orders,
+	}
+}
+
+// ExportUserData возвращает заказы пользователя
+func (s *OrderService) ExportUserData(ctx context.Context) (any, error) {
+	return s.GetOrders(ctx)
+}
+
+func (s *OrderService) GetUserExportName() string {
+	return "orders"
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+
+	"eats-backend/internal/models"
+)
+
+// OrderExtrasCatalog опции заказа, которые можно выбрать при оформлении: без пластиковых приборов,
+// многоразовый пакет, доставка без контакта. Каталог задается файлом order_extras.json.
+type OrderExtrasCatalog struct {
+	// Опции в порядке из файла
+	extras []models.OrderExtra
+	index  map[string]int
+}
+
+func NewOrderExtrasCatalog(extras []models.OrderExtra) *OrderExtrasCatalog {
+	catalog := &OrderExtrasCatalog{
+		extras: slices.Clone(extras),
+		index:  make(map[string]int, len(extras)),
+	}
+
+	for i, extra := range catalog.extras {
+		catalog.index[extra.ID] = i
+	}
+
+	return catalog
+}
+
+// ListExtras возвращает все опции каталога
+func (c *OrderExtrasCatalog) ListExtras() []models.OrderExtra {
+	result := slices.Clone(c.extras)
+	if result == nil {
+		result = []models.OrderExtra{}
+	}
+
+	return result
+}
+
+// ResolveExtras проверяет выбранные опции и возвращает их в порядке каталога вместе с суммой доплат.
+// Опции со значением false не выбраны и пропускаются.
+func (c *OrderExtrasCatalog) ResolveExtras(selected models.OrderExtras) ([]models.OrderExtra, models.Money, error) {
+	positions := make([]int, 0, len(selected))
+
+	for id, enabled := range selected {
+		i, ok := c.index[id]
+		if !ok {
+			return nil, 0, fmt.Errorf("%w: unknown order extra %s", models.ErrBadRequest, id)
+		}
+
+		if enabled {
+			positions = append(positions, i)
+		}
+	}
+
+	slices.Sort(positions)
+
+	extras := make([]models.OrderExtra, 0, len(positions))
+	price := models.Money(0)
+
+	for _, i := range positions {
+		extras = append(extras, c.extras[i])
+		price += c.extras[i].Surcharge
+	}
+
+	if len(extras) == 0 {
+		extras = nil
+	}
+
+	return extras, price, nil
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// placementStep шаг оформления заказа. compensate отменяет уже выполненный шаг, если один из следующих
+// не удался, nil - отменять нечего.
+type placementStep struct {
+	name       string
+	run        func(ctx context.Context) error
+	compensate func(ctx context.Context) error
+}
+
+// checkoutCoordinator выполняет шаги оформления заказа по порядку. Если шаг завершился ошибкой,
+// выполненные шаги отменяются в обратном порядке, и пользователь остается с прежними корзиной и балансом.
+type checkoutCoordinator struct {
+	logger *zap.SugaredLogger
+}
+
+func (c checkoutCoordinator) run(ctx context.Context, orderID string, steps ...placementStep) error {
+	for i, step := range steps {
+		err := step.run(ctx)
+		if err == nil {
+			continue
+		}
+
+		// Шаг мог упасть из-за отмены запроса, а откатить уже сделанное нужно в любом случае
+		c.compensate(context.WithoutCancel(ctx), orderID, steps[:i])
+
+		return fmt.Errorf("%s: %w", step.name, err)
+	}
+
+	return nil
+}
+
+func (c checkoutCoordinator) compensate(ctx context.Context, orderID string, done []placementStep) {
+	for i := len(done) - 1; i >= 0; i-- {
+		step := done[i]
+		if step.compensate == nil {
+			continue
+		}
+
+		if err := step.compensate(ctx); err != nil {
+			// Откат дальше продолжается: лучше вернуть деньги без заказа, чем не вернуть ничего
+			c.logger.Errorw("Failed to compensate order placement step", "orderId", orderID, "step", step.name, "error", err)
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"eats-backend/internal/events"
+	"eats-backend/internal/models"
+	"eats-backend/internal/service"
+)
+
+type testOrderAddresses struct{}
+
+func (testOrderAddresses) GetAddressByID(_ context.Context, id string) (models.Address, error) {
+	return models.Address{ID: id}, nil
+}
+
+type testOrderDelivery struct{}
+
+func (testOrderDelivery) CheckMinOrder(models.Money) error { return nil }
+
+func (testOrderDelivery) CheckOrderTime(now time.Time) (time.Time, error) { return now, nil }
+
+type testOrderCart struct {
+	cart        models.CartResponse
+	checkoutErr error
+
+	reserved []models.OrderItem
+	released int
+}
+
+func (c *testOrderCart) GetCartForAddress(context.Context, string, models.Money) (models.CartResponse, error) {
+	return c.cart, nil
+}
+
+func (c *testOrderCart) CheckQuantityLimits(context.Context, []models.OrderItem) error { return nil }
+
+func (c *testOrderCart) ReserveItems(_ context.Context, items []models.OrderItem) error {
+	c.reserved = items
+
+	return nil
+}
+
+func (c *testOrderCart) ReleaseItems(context.Context) { c.released++ }
+
+func (c *testOrderCart) CheckoutItems(context.Context) error { return c.checkoutErr }
+
+type testOrderWallet struct {
+	refunded map[string]models.Money
+}
+
+func (w *testOrderWallet) ConfirmPayment(context.Context, string, models.Money) error { return nil }
+
+func (w *testOrderWallet) PayForOrder(_ context.Context, _ string, amount models.Money) (models.OrderPayment, error) {
+	return models.OrderPayment{Method: models.PaymentMethodWallet, Amount: amount, TransactionID: "tx-1"}, nil
+}
+
+func (w *testOrderWallet) CreditRefund(_, _ string, amount models.Money, refundID string) error {
+	w.refunded[refundID] += amount
+
+	return nil
+}
+
+func TestOrderService_MakeNewOrderCompensation(t *testing.T) {
+	cart := &testOrderCart{
+		cart: models.CartResponse{
+			OrderPrice:    models.Rubles(300),
+			DeliveryPrice: models.Rubles(100),
+			TotalItems:    2,
+			Items: []models.CartResponseItem{
+				{ProductID: "bread", Price: models.Rubles(150), Quantity: 2, Available: true},
+				{ProductID: "cake", Price: models.Rubles(500), Quantity: 1},
+			},
+		},
+		checkoutErr: errors.New("store is down"),
+	}
+	wallet := &testOrderWallet{refunded: make(map[string]models.Money)}
+
+	bus := events.NewBus(zap.NewNop().Sugar())
+
+	created := 0
+	events.Subscribe(bus, "test", func(context.Context, events.OrderCreated) { created++ })
+
+	orders := service.NewOrderService(testOrderAddresses{}, cart, wallet, nil, testOrderDelivery{}, nil, service.NewOrderExtrasCatalog(nil), bus,
+		nil, zap.NewNop().Sugar(), map[string][]*models.Order{})
+
+	ctx := models.ContextWithUser(t.Context(), "user-1")
+	request := &models.OrderRequest{PaymentMethod: string(models.PaymentMethodWallet), AddressID: "address-1", Tip: models.Rubles(50)}
+
+	// Корзина не очистилась: заказ удаляется, деньги возвращаются, резерв снимается
+	require.Error(t, orders.MakeNewOrder(ctx, request))
+
+	require.Equal(t, []models.OrderItem{{ID: "bread", Price: models.Rubles(150), Quantity: 2}}, cart.reserved)
+	require.Equal(t, map[string]models.Money{"tx-1": models.Rubles(450)}, wallet.refunded)
+	require.Equal(t, 1, cart.released)
+	require.Zero(t, created)
+
+	placed, err := orders.GetOrders(ctx)
+	require.NoError(t, err)
+	require.Empty(t, placed)
+
+	cart.checkoutErr = nil
+
+	require.NoError(t, orders.MakeNewOrder(ctx, request))
+	require.Equal(t, 1, created)
+	require.Equal(t, 1, cart.released)
+
+	placed, err = orders.GetOrders(ctx)
+	require.NoError(t, err)
+	require.Len(t, placed, 1)
+	require.Equal(t, "tx-1", placed[0].Payment.TransactionID)
+}
+
+// testOrderRepository репозиторий заказов в памяти, err имитирует сбой записи
+type testOrderRepository struct {
+	orders map[string]models.Order // orderID -> заказ
+	owners map[string]string       // orderID -> userID
+	err    error
+}
+
+func (r *testOrderRepository) SaveOrder(_ context.Context, userID string, order models.Order) error {
+	if r.err != nil {
+		return r.err
+	}
+
+	r.orders[order.ID] = order
+	r.owners[order.ID] = userID
+
+	return nil
+}
+
+func (r *testOrderRepository) DeleteOrder(_ context.Context, orderID string) error {
+	delete(r.orders, orderID)
+	delete(r.owners, orderID)
+
+	return nil
+}
+
+func (r *testOrderRepository) ReplaceOrders(_ context.Context, orders map[string][]*models.Order) error {
+	if r.err != nil {
+		return r.err
+	}
+
+	for orderID, userID := range r.owners {
+		if _, ok := orders[userID]; ok {
+			delete(r.orders, orderID)
+			delete(r.owners, orderID)
+		}
+	}
+
+	for userID, userOrders := range orders {
+		for _, order := range userOrders {
+			r.orders[order.ID] = *order
+			r.owners[order.ID] = userID
+		}
+	}
+
+	return nil
+}
+
+func TestOrderService_Repository(t *testing.T) {
+	wallet := newPaymentWallet(t)
+	cart := &testOrderCart{cart: models.CartResponse{
+		OrderPrice: models.Rubles(200),
+		TotalItems: 1,
+		Items:      []models.CartResponseItem{{ProductID: "cake", Price: models.Rubles(200), Quantity: 1, Available: true}},
+	}}
+
+	repo := &testOrderRepository{orders: make(map[string]models.Order), owners: make(map[string]string), err: errors.New("disk I/O error")}
+
+	orders := service.NewOrderService(testOrderAddresses{}, cart, wallet, nil, testOrderDelivery{}, nil,
+		service.NewOrderExtrasCatalog(nil), events.NewBus(zap.NewNop().Sugar()), nil, zap.NewNop().Sugar(),
+		map[string][]*models.Order{"alice": {{ID: "old", Status: models.OrderStatusCompleted}}})
+	orders.UseRepository(repo)
+
+	ctx := walletContext(t, "alice")
+	request := &models.OrderRequest{PaymentMethod: string(models.PaymentMethodWallet), AddressID: "address-1"}
+
+	// Заказ, который не удалось записать, не создается, и деньги возвращаются
+	require.ErrorIs(t, orders.MakeNewOrder(ctx, request), models.ErrInternalServer)
+	require.Equal(t, 1, cart.released)
+	require.Equal(t, models.Rubles(300), walletBalances(t, wallet, "alice")["card-a"])
+
+	placed, err := orders.GetOrders(ctx)
+	require.NoError(t, err)
+	require.Len(t, placed, 1)
+
+	repo.err = nil
+
+	require.NoError(t, orders.MakeNewOrder(ctx, request))
+
+	placed, err = orders.GetOrders(ctx)
+	require.NoError(t, err)
+	require.Len(t, placed, 2)
+	require.Equal(t, placed[0], repo.orders[placed[0].ID])
+	require.Equal(t, "alice", repo.owners[placed[0].ID])
+
+	// Задержка без записи не применяется
+	repo.err = errors.New("disk I/O error")
+
+	_, err = orders.DelayOrder(ctx, placed[0].ID, models.OrderDelayRequest{Minutes: 10})
+	require.ErrorIs(t, err, models.ErrInternalServer)
+
+	order, err := orders.GetOrder(ctx, placed[0].ID)
+	require.NoError(t, err)
+	require.Empty(t, order.Delays)
+
+	repo.err = nil
+
+	delayed, err := orders.DelayOrder(ctx, placed[0].ID, models.OrderDelayRequest{Minutes: 10})
+	require.NoError(t, err)
+	require.Equal(t, delayed, repo.orders[placed[0].ID])
+
+	// Сброс записывает исходные заказы пользователя
+	orders.ResetUser("alice")
+	require.Equal(t, map[string]string{"old": "alice"}, repo.owners)
+}
+
+func TestOrderService_MakeNewOrderExtras(t *testing.T) {
+	cart := &testOrderCart{
+		cart: models.CartResponse{
+			OrderPrice:    models.Rubles(300),
+			DeliveryPrice: models.Rubles(100),
+			Items: []models.CartResponseItem{
+				{ProductID: "bread", Price: models.Rubles(150), Quantity: 2, Available: true},
+			},
+		},
+	}
+
+	extras := service.NewOrderExtrasCatalog([]models.OrderExtra{
+		{ID: "no_plastic_cutlery", Name: "Без пластика"},
+		{ID: "reusable_bag", Name: "Многоразовая сумка", Surcharge: models.Rubles(30)},
+		{ID: "contactless_delivery", Name: "Без контакта"},
+	})
+
+	orders := service.NewOrderService(testOrderAddresses{}, cart, &testOrderWallet{}, nil, testOrderDelivery{}, nil, extras,
+		events.NewBus(zap.NewNop().Sugar()), nil, zap.NewNop().Sugar(), map[string][]*models.Order{})
+
+	ctx := models.ContextWithUser(t.Context(), "user-1")
+	request := &models.OrderRequest{PaymentMethod: string(models.PaymentMethodCash), AddressID: "address-1"}
+
+	request.Options.Extras = models.OrderExtras{"gift_wrap": true}
+	require.ErrorIs(t, orders.MakeNewOrder(ctx, request), models.ErrBadRequest)
+
+	// Невыбранные опции не попадают в заказ, выбранные идут в порядке каталога
+	request.Options.Extras = models.OrderExtras{"contactless_delivery": true, "reusable_bag": true, "no_plastic_cutlery": false}
+	require.NoError(t, orders.MakeNewOrder(ctx, request))
+
+	placed, err := orders.GetOrders(ctx)
+	require.NoError(t, err)
+	require.Len(t, placed, 1)
+	require.Equal(t, []models.OrderExtra{
+		{ID: "reusable_bag", Name: "Многоразовая сумка", Surcharge: models.Rubles(30)},
+		{ID: "contactless_delivery", Name: "Без контакта"},
+	}, placed[0].Extras)
+	require.Equal(t, models.Rubles(30), placed[0].ExtrasPrice)
+	require.Equal(t, models.Rubles(430), placed[0].TotalPrice)
+}
+
+// testAddressDelivery цена доставки зависит от адреса
+type testAddressDelivery map[string]models.Money
+
+func (d testAddressDelivery) BaseDelivery(models.Money) (models.Money, int) {
+	return models.Rubles(150), 30
+}
+
+func (d testAddressDelivery) Calculate(address models.Address, _ models.Money) (float64, models.Money, int) {
+	return 1, d[address.AddressLine], 30
+}
+
+func (d testAddressDelivery) Info() models.DeliveryInfo { return models.DeliveryInfo{} }
+
+func TestOrderService_MakeNewOrderDeliveryToOrderAddress(t *testing.T) {
+	ctx := models.ContextWithUser(t.Context(), "user-1")
+
+	addresses := service.NewAddressService(service.AddressLimits{})
+
+	home := &models.Address{AddressLine: "Тверская, 1", Coordinates: []float64{37.61, 55.75}}
+	require.NoError(t, addresses.AddAddress(ctx, home))
+
+	dacha := &models.Address{AddressLine: "Дачная, 7", Coordinates: []float64{37.9, 56.1}}
+	require.NoError(t, addresses.AddAddress(ctx, dacha))
+
+	_, err := addresses.SetCurrentAddress(ctx, home.ID)
+	require.NoError(t, err)
+
+	cart := service.NewCart(testCartProducts{"bread": {ID: "bread", Price: models.Rubles(100), Available: true}},
+		service.NewMemoryCartStore(map[string]map[string]*models.CartItem{
+			"user-1": {"bread": {ProductID: "bread", Quantity: 3}},
+		}),
+		testAddressDelivery{"Тверская, 1": models.Rubles(50), "Дачная, 7": models.Rubles(400)},
+		addresses, testCartStats{}, nil, 0, zap.NewNop().Sugar(), nil)
+
+	orders := service.NewOrderService(addresses, cart, &testOrderWallet{}, nil, testOrderDelivery{}, nil,
+		service.NewOrderExtrasCatalog(nil), events.NewBus(zap.NewNop().Sugar()), nil, zap.NewNop().Sugar(),
+		map[string][]*models.Order{})
+
+	// Заказ на адрес, который не выбран в корзине, считает доставку до него
+	require.NoError(t, orders.MakeNewOrder(ctx, &models.OrderRequest{
+		PaymentMethod: string(models.PaymentMethodCash), AddressID: dacha.ID, Tip: models.Rubles(20),
+	}))
+
+	placed, err := orders.GetOrders(ctx)
+	require.NoError(t, err)
+	require.Len(t, placed, 1)
+	require.Equal(t, dacha.ID, placed[0].Address.ID)
+	require.Equal(t, models.Rubles(400), placed[0].DeliveryPrice)
+	require.Equal(t, models.Rubles(720), placed[0].TotalPrice)
+}
@@ -0,0 +1,149 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"eats-backend/internal/events"
+	"eats-backend/internal/models"
+	"eats-backend/internal/service"
+)
+
+func TestOrderService_DelayOrder(t *testing.T) {
+	bus := events.NewBus(zap.NewNop().Sugar())
+	notifications := service.NewNotificationService(nil, models.NotificationsData{})
+	events.Subscribe(bus, "notifications", notifications.OrderDelayed)
+
+	now := time.Now()
+	createdAt := now.Add(-time.Minute)
+
+	orders := service.NewOrderService(nil, nil, nil, nil, nil, nil, nil, bus, nil, zap.NewNop().Sugar(), map[string][]*models.Order{
+		"user-1": {
+			{ID: "active", Status: models.OrderStatusActive, CreatedAt: createdAt},
+			// Время доставки прошло, заказ без курьера уже считается доставленным
+			{ID: "overdue", Status: models.OrderStatusActive, CreatedAt: now.Add(-time.Hour)},
+			{
+				ID: "courier", Status: models.OrderStatusActive, CreatedAt: now.Add(-time.Hour),
+				Delivery: &models.OrderDelivery{CourierID: "courier-1", Status: models.DeliveryStatusPickedUp},
+			},
+		},
+	})
+
+	_, err := orders.DelayOrder(t.Context(), "active", models.OrderDelayRequest{Minutes: 0})
+	require.ErrorIs(t, err, models.ErrBadRequest)
+
+	_, err = orders.DelayOrder(t.Context(), "overdue", models.OrderDelayRequest{Minutes: 15})
+	require.ErrorIs(t, err, models.ErrBadRequest)
+
+	_, err = orders.DelayOrder(t.Context(), "missing", models.OrderDelayRequest{Minutes: 15})
+	require.ErrorIs(t, err, models.ErrNotFound)
+
+	delayed, err := orders.DelayOrder(t.Context(), "active", models.OrderDelayRequest{Minutes: 15, Reason: " Пробки "})
+	require.NoError(t, err)
+	require.Equal(t, createdAt.Add(service.DeliveryTime+15*time.Minute), *delayed.ETA)
+	require.Equal(t, []models.OrderDelay{{Minutes: 15, Reason: "Пробки", CreatedAt: delayed.Delays[0].CreatedAt}}, delayed.Delays)
+
+	// Курьер опаздывает: при чтении заказов доставка сдвигается от текущего момента
+	ctx := walletContext(t, "user-1")
+
+	list, err := orders.GetOrders(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 3)
+
+	courier := list[0]
+	require.Equal(t, "courier", courier.ID)
+	require.Equal(t, models.OrderStatusActive, courier.Status)
+	require.Len(t, courier.Delays, 1)
+	require.Equal(t, 5, courier.Delays[0].Minutes)
+	require.True(t, courier.ETA.After(now.Add(service.CourierDelayStep-time.Second)))
+
+	// Повторное чтение до нового времени доставки больше не задерживает заказ
+	list, err = orders.GetOrders(ctx)
+	require.NoError(t, err)
+	require.Len(t, list[0].Delays, 1)
+
+	received := notifications.GetNotifications(ctx)
+	require.Len(t, received, 2)
+	require.Equal(t, models.NotificationOrderDelayed, received[0].Type)
+	require.Equal(t, "courier", received[0].OrderID)
+	require.Contains(t, received[1].Text, "Пробки")
+}
+
+func TestOrderService_GetOrderTimeline(t *testing.T) {
+	bus := events.NewBus(zap.NewNop().Sugar())
+
+	createdAt := time.Now().Add(-time.Minute)
+
+	orders := service.NewOrderService(nil, nil, nil, nil, nil, nil, nil, bus, nil, zap.NewNop().Sugar(), map[string][]*models.Order{
+		"user-1": {
+			{ID: "active", Status: models.OrderStatusActive, CreatedAt: createdAt},
+			{
+				ID: "refunded", Status: models.OrderStatusCompleted, CreatedAt: createdAt.Add(-time.Hour),
+				Refund: &models.OrderRefund{Refunds: []models.Refund{{Amount: models.Rubles(150), CreatedAt: createdAt}}},
+			},
+		},
+	})
+
+	ctx := walletContext(t, "user-1")
+
+	_, err := orders.GetOrderTimeline(ctx, "missing")
+	require.ErrorIs(t, err, models.ErrNotFound)
+
+	_, err = orders.GetOrderTimeline(walletContext(t, "user-2"), "active")
+	require.ErrorIs(t, err, models.ErrNotFound)
+
+	setDelivery := func(delivery *models.OrderDelivery) {
+		_, err := orders.UpdateDelivery(t.Context(), "active", func(order *models.Order) error {
+			order.Delivery = delivery
+
+			return nil
+		})
+		require.NoError(t, err)
+	}
+
+	setDelivery(&models.OrderDelivery{CourierID: "courier-1", Courier: "Иван", Status: models.DeliveryStatusAssigned})
+	setDelivery(nil)
+	setDelivery(&models.OrderDelivery{CourierID: "courier-2", Courier: "Петр", Status: models.DeliveryStatusAssigned})
+	setDelivery(&models.OrderDelivery{CourierID: "courier-2", Courier: "Петр", Status: models.DeliveryStatusAccepted})
+	// Обновление без смены статуса не добавляет событие
+	setDelivery(&models.OrderDelivery{CourierID: "courier-2", Courier: "Петр", Status: models.DeliveryStatusAccepted})
+
+	_, err = orders.DelayOrder(ctx, "active", models.OrderDelayRequest{Minutes: 15, Reason: "Пробки"})
+	require.NoError(t, err)
+
+	timeline, err := orders.GetOrderTimeline(ctx, "active")
+	require.NoError(t, err)
+	require.Equal(t, "active", timeline.OrderID)
+	require.NotNil(t, timeline.ETA)
+
+	types := make([]models.OrderEventType, 0, len(timeline.Events))
+	for _, event := range timeline.Events {
+		types = append(types, event.Type)
+	}
+
+	require.Equal(t, []models.OrderEventType{
+		models.OrderEventCreated,
+		models.OrderEventCourierAssigned,
+		models.OrderEventCourierDeclined,
+		models.OrderEventCourierAssigned,
+		models.OrderEventCourierAccepted,
+		models.OrderEventDelayed,
+	}, types)
+	require.Equal(t, createdAt, timeline.Events[0].At)
+	require.Equal(t, "Курьер принял заказ", timeline.Events[4].Title)
+	require.Equal(t, "Курьер: Петр", timeline.Events[4].Description)
+	require.Equal(t, "На 15 мин: Пробки", timeline.Events[5].Description)
+
+	// Тексты на языке запроса, у старого заказа без истории оформление берется из времени создания
+	timeline, err = orders.GetOrderTimeline(context.WithValue(ctx, models.ContextLanguageKey{}, "en"), "refunded")
+	require.NoError(t, err)
+	require.Nil(t, timeline.ETA)
+	require.Equal(t, []models.OrderTimelineEvent{
+		{Type: models.OrderEventCreated, At: createdAt.Add(-time.Hour), Title: "Order placed"},
+		{Type: models.OrderEventRefunded, At: createdAt, Title: "Money refunded", Description: "Refunded 150 RUB"},
+	}, timeline.Events)
+}
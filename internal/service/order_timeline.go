@@ -0,0 +1,166 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"eats-backend/internal/models"
+)
+
+// timelineFallbackLanguage язык текстов, если перевода на язык запроса нет
+const timelineFallbackLanguage = "ru"
+
+var orderEventTitles = map[models.OrderEventType]map[string]string{
+	models.OrderEventCreated:         {"ru": "Заказ оформлен", "en": "Order placed"},
+	models.OrderEventCourierAssigned: {"ru": "Заказ предложен курьеру", "en": "Order offered to a courier"},
+	models.OrderEventCourierAccepted: {"ru": "Курьер принял заказ", "en": "Courier accepted the order"},
+	models.OrderEventCourierDeclined: {"ru": "Курьер отказался, ищем другого", "en": "Courier declined, looking for another one"},
+	models.OrderEventPickedUp:        {"ru": "Курьер забрал заказ", "en": "Courier picked up the order"},
+	models.OrderEventDelivered:       {"ru": "Заказ доставлен", "en": "Order delivered"},
+	models.OrderEventDelayed:         {"ru": "Доставка задерживается", "en": "Delivery is delayed"},
+	models.OrderEventRefunded:        {"ru": "Деньги возвращены", "en": "Money refunded"},
+}
+
+// Описания событий: форматные строки с подробностями
+var (
+	timelineCourierText   = map[string]string{"ru": "Курьер: %s", "en": "Courier: %s"}
+	timelineScheduledText = map[string]string{
+		"ru": "Магазин закрыт, заказ начнут собирать к открытию",
+		"en": "The store is closed, the order will be prepared when it opens",
+	}
+	timelineDelayText       = map[string]string{"ru": "На %d мин", "en": "By %d min"}
+	timelineDelayReasonText = map[string]string{"ru": "На %d мин: %s", "en": "By %d min: %s"}
+	timelineRefundText      = map[string]string{"ru": "Возвращено %s ₽", "en": "Refunded %s RUB"}
+)
+
+// deliveryEventTypes события смены статуса доставки
+var deliveryEventTypes = map[models.DeliveryStatus]models.OrderEventType{
+	models.DeliveryStatusAssigned:  models.OrderEventCourierAssigned,
+	models.DeliveryStatusAccepted:  models.OrderEventCourierAccepted,
+	models.DeliveryStatusPickedUp:  models.OrderEventPickedUp,
+	models.DeliveryStatusDelivered: models.OrderEventDelivered,
+}
+
+// GetOrderTimeline возвращает ход заказа пользователя: оформление, доставку, задержки и возвраты
+// с текстами на языке запроса
+func (s *OrderService) GetOrderTimeline(ctx context.Context, orderID string) (models.OrderTimeline, error) {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	// Как и в списке заказов, заказ может завершиться или задержаться при чтении
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	for _, order := range s.orders[userID] {
+		if order.ID != orderID {
+			continue
+		}
+
+		s.refreshOrder(ctx, userID, order)
+
+		return orderTimeline(order, models.LanguageFromContext(ctx)), nil
+	}
+
+	return models.OrderTimeline{}, fmt.Errorf("%w: order not found", models.ErrNotFound)
+}
+
+// deliveryEvent событие истории при смене доставки заказа с before на after
+func deliveryEvent(before, after *models.OrderDelivery, now time.Time) (models.OrderEvent, bool) {
+	if after == nil {
+		if before == nil {
+			return models.OrderEvent{}, false
+		}
+
+		return models.OrderEvent{Type: models.OrderEventCourierDeclined, At: now, Courier: before.Courier}, true
+	}
+
+	if before != nil && before.Status == after.Status && before.CourierID == after.CourierID {
+		return models.OrderEvent{}, false
+	}
+
+	eventType, ok := deliveryEventTypes[after.Status]
+	if !ok {
+		return models.OrderEvent{}, false
+	}
+
+	return models.OrderEvent{Type: eventType, At: now, Courier: after.Courier}, true
+}
+
+func orderTimeline(order *models.Order, lang string) models.OrderTimeline {
+	timeline := models.OrderTimeline{
+		OrderID: order.ID,
+		Status:  order.Status,
+		Events:  make([]models.OrderTimelineEvent, 0, len(order.History)+len(order.Delays)),
+	}
+
+	if order.Status == models.OrderStatusActive {
+		eta := orderETA(order)
+		timeline.ETA = &eta
+	}
+
+	history := order.History
+	// Заказы, оформленные до появления истории, начинают ее без оформления, но время создания известно
+	if (len(history) == 0 || history[0].Type != models.OrderEventCreated) && !order.CreatedAt.IsZero() {
+		created := models.OrderEvent{Type: models.OrderEventCreated, At: order.CreatedAt}
+		history = append([]models.OrderEvent{created}, history...)
+	}
+
+	for _, event := range history {
+		item := timelineEvent(event.Type, event.At, lang)
+
+		switch {
+		case event.Type == models.OrderEventCreated && order.ScheduledFor != nil:
+			item.Description = localizedText(timelineScheduledText, lang)
+		case event.Courier != "" && event.Type != models.OrderEventDelivered:
+			item.Description = fmt.Sprintf(localizedText(timelineCourierText, lang), event.Courier)
+		}
+
+		timeline.Events = append(timeline.Events, item)
+	}
+
+	for _, delay := range order.Delays {
+		item := timelineEvent(models.OrderEventDelayed, delay.CreatedAt, lang)
+
+		if delay.Reason != "" {
+			item.Description = fmt.Sprintf(localizedText(timelineDelayReasonText, lang), delay.Minutes, delay.Reason)
+		} else {
+			item.Description = fmt.Sprintf(localizedText(timelineDelayText, lang), delay.Minutes)
+		}
+
+		timeline.Events = append(timeline.Events, item)
+	}
+
+	if order.Refund != nil {
+		for _, refund := range order.Refund.Refunds {
+			item := timelineEvent(models.OrderEventRefunded, refund.CreatedAt, lang)
+			item.Description = fmt.Sprintf(localizedText(timelineRefundText, lang), refund.Amount)
+
+			timeline.Events = append(timeline.Events, item)
+		}
+	}
+
+	// События одного времени остаются в порядке истории: оформление раньше задержек и возвратов
+	slices.SortStableFunc(timeline.Events, func(a, b models.OrderTimelineEvent) int {
+		return cmp.Compare(a.At.UnixNano(), b.At.UnixNano())
+	})
+
+	return timeline
+}
+
+func timelineEvent(eventType models.OrderEventType, at time.Time, lang string) models.OrderTimelineEvent {
+	return models.OrderTimelineEvent{
+		Type:  eventType,
+		At:    at,
+		Title: localizedText(orderEventTitles[eventType], lang),
+	}
+}
+
+func localizedText(texts map[string]string, lang string) string {
+	if text, ok := texts[lang]; ok {
+		return text
+	}
+
+	return texts[timelineFallbackLanguage]
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"eats-backend/internal/models"
+)
+
+// SandboxPaymentProvider платежный провайдер для разработки. Деньги не списываются: страница оплаты
+// песочницы открывается на этом же сервере, а уведомления подписываются HMAC-SHA256 от тела.
+type SandboxPaymentProvider struct {
+	baseURL string
+	secret  []byte
+}
+
+func NewSandboxPaymentProvider(baseURL string, secret []byte) *SandboxPaymentProvider {
+	return &SandboxPaymentProvider{
+		baseURL: baseURL,
+		secret:  secret,
+	}
+}
+
+// CreatePayment возвращает ссылку на страницу оплаты песочницы
+func (p *SandboxPaymentProvider) CreatePayment(_ context.Context, payment models.Payment) (string, error) {
+	return p.baseURL + "payments/sandbox/" + payment.ID, nil
+}
+
+// ParseCallback проверяет подпись из заголовка X-Payment-Signature и разбирает уведомление
+func (p *SandboxPaymentProvider) ParseCallback(body []byte, signature string) (models.PaymentCallback, error) {
+	if !hmac.Equal([]byte(signature), []byte(p.sign(body))) {
+		return models.PaymentCallback{}, fmt.Errorf("%w: invalid payment signature", models.ErrUnauthorized)
+	}
+
+	var callback models.PaymentCallback
+	if err := json.Unmarshal(body, &callback); err != nil {
+		return models.PaymentCallback{}, fmt.Errorf("%w: can't parse payment callback: %w", models.ErrBadRequest, err)
+	}
+
+	return callback, nil
+}
+
+// SignCallback готовит подписанное уведомление так, как его прислал бы провайдер
+func (p *SandboxPaymentProvider) SignCallback(callback models.PaymentCallback) ([]byte, string, error) {
+	body, err := json.Marshal(callback)
+	if err != nil {
+		return nil, "", fmt.Errorf("can't marshal payment callback: %w", err)
+	}
+
+	return body, p.sign(body), nil
+}
+
+func (p *SandboxPaymentProvider) sign(body []byte) string {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
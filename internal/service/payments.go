@@ -0,0 +1,293 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"eats-backend/internal/metrics"
+	"eats-backend/internal/models"
+)
+
+// PaymentProvider внешний платежный провайдер.
+type PaymentProvider interface {
+	// CreatePayment регистрирует платеж у провайдера и возвращает ссылку на страницу оплаты
+	CreatePayment(ctx context.Context, payment models.Payment) (string, error)
+	// ParseCallback проверяет подпись уведомления провайдера и разбирает его
+	ParseCallback(body []byte, signature string) (models.PaymentCallback, error)
+}
+
+// SandboxCallbackSigner провайдер, который умеет сам подписать уведомление. Есть только у песочницы,
+// через него страница оплаты песочницы завершает платеж.
+type SandboxCallbackSigner interface {
+	SignCallback(callback models.PaymentCallback) (body []byte, signature string, err error)
+}
+
+type PaymentWallet interface {
+	CheckAccount(ctx context.Context, accountID string) error
+	CreditPayment(userID, accountID string, amount models.Money, paymentID string) error
+}
+
+// PaymentService пополняет кошелек через внешнего провайдера: создает платеж и зачисляет деньги
+// по уведомлению провайдера об оплате.
+type PaymentService struct {
+	provider  PaymentProvider
+	wallet    PaymentWallet
+	maxAmount models.Money
+	logger    *zap.SugaredLogger
+
+	payments map[string]*models.Payment
+	owners   map[string]string // paymentID -> userID
+
+	mux sync.RWMutex
+}
+
+func NewPaymentService(
+	provider PaymentProvider,
+	wallet PaymentWallet,
+	maxAmount models.Money,
+	logger *zap.SugaredLogger,
+	initialData map[string][]*models.Payment,
+) *PaymentService {
+	service := &PaymentService{
+		provider:  provider,
+		wallet:    wallet,
+		maxAmount: maxAmount,
+		logger:    logger,
+	}
+
+	service.load(initialData)
+
+	return service
+}
+
+// load заменяет платежи, вызывается в конструкторе или под блокировкой
+func (s *PaymentService) load(data map[string][]*models.Payment) {
+	s.payments = make(map[string]*models.Payment)
+	s.owners = make(map[string]string)
+
+	for userID, payments := range data {
+		for _, payment := range payments {
+			copied := *payment
+			s.payments[payment.ID] = &copied
+			s.owners[payment.ID] = userID
+		}
+	}
+}
+
+// CreateTopup создает платеж на пополнение счета и возвращает его со ссылкой на оплату
+func (s *PaymentService) CreateTopup(ctx context.Context, req models.TopupRequest) (models.Payment, error) {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	if req.Amount > s.maxAmount {
+		metrics.TopupRejections.Inc("max_amount")
+	}
+
+	if req.Amount <= 0 || req.Amount > s.maxAmount {
+		return models.Payment{}, fmt.Errorf("%w: amount must be between 0.01 and %s", models.ErrBadRequest, s.maxAmount)
+	}
+
+	if err := s.wallet.CheckAccount(ctx, req.AccountID); err != nil {
+		return models.Payment{}, err
+	}
+
+	payment := models.Payment{
+		ID:        uuid.NewString(),
+		AccountID: req.AccountID,
+		Amount:    req.Amount,
+		Status:    models.PaymentStatusPending,
+		CreatedAt: time.Now(),
+	}
+
+	paymentURL, err := s.provider.CreatePayment(ctx, payment)
+	if err != nil {
+		return models.Payment{}, fmt.Errorf("%w: can't create payment: %w", models.ErrInternalServer, err)
+	}
+
+	payment.PaymentURL = paymentURL
+
+	s.mux.Lock()
+	s.payments[payment.ID] = &payment
+	s.owners[payment.ID] = userID
+	s.mux.Unlock()
+
+	return payment, nil
+}
+
+// GetPayment возвращает платеж пользователя, чтобы клиент мог дождаться результата оплаты
+func (s *PaymentService) GetPayment(ctx context.Context, id string) (models.Payment, error) {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	payment, ok := s.payments[id]
+	if !ok || s.owners[id] != userID {
+		return models.Payment{}, fmt.Errorf("%w: payment not found", models.ErrNotFound)
+	}
+
+	return *payment, nil
+}
+
+// HandleCallback применяет уведомление провайдера. Повторное уведомление с тем же статусом
+// ничего не меняет, поэтому провайдер может присылать его несколько раз.
+func (s *PaymentService) HandleCallback(_ context.Context, body []byte, signature string) (models.Payment, error) {
+	callback, err := s.provider.ParseCallback(body, signature)
+	if err != nil {
+		return models.Payment{}, err
+	}
+
+	if callback.Status != models.PaymentStatusSucceeded && callback.Status != models.PaymentStatusFailed {
+		return models.Payment{}, fmt.Errorf("%w: unknown payment status %s", models.ErrBadRequest, callback.Status)
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	payment, ok := s.payments[callback.PaymentID]
+	if !ok {
+		return models.Payment{}, fmt.Errorf("%w: payment not found", models.ErrNotFound)
+	}
+
+	if payment.Status != models.PaymentStatusPending {
+		if payment.Status == callback.Status {
+			return *payment, nil
+		}
+
+		return models.Payment{}, fmt.Errorf("%w: payment is already %s", models.ErrBadRequest, payment.Status)
+	}
+
+	userID := s.owners[payment.ID]
+
+	if callback.Status == models.PaymentStatusSucceeded {
+		// Если зачислить не удалось, платеж остается в ожидании и провайдер может повторить уведомление
+		if err := s.wallet.CreditPayment(userID, payment.AccountID, payment.Amount, payment.ID); err != nil {
+			return models.Payment{}, fmt.Errorf("can't credit payment %s: %w", payment.ID, err)
+		}
+	}
+
+	payment.Status = callback.Status
+	payment.CompletedAt = time.Now()
+
+	if payment.Status == models.PaymentStatusFailed {
+		metrics.PaymentFailures.Inc("external_topup", metrics.PaymentReasonDeclined)
+	}
+
+	s.logger.Infow("Payment completed", "paymentId", payment.ID, "userId", userID, "status", payment.Status)
+
+	return *payment, nil
+}
+
+// CompleteSandboxPayment завершает платеж со страницы оплаты песочницы. Уведомление подписывается
+// и проходит ту же проверку, что и уведомления провайдера.
+func (s *PaymentService) CompleteSandboxPayment(
+	ctx context.Context,
+	id string,
+	status models.PaymentStatus,
+) (models.Payment, error) {
+	signer, ok := s.provider.(SandboxCallbackSigner)
+	if !ok {
+		return models.Payment{}, fmt.Errorf("%w: payment sandbox is disabled", models.ErrNotFound)
+	}
+
+	body, signature, err := signer.SignCallback(models.PaymentCallback{PaymentID: id, Status: status})
+	if err != nil {
+		return models.Payment{}, fmt.Errorf("%w: %w", models.ErrInternalServer, err)
+	}
+
+	return s.HandleCallback(ctx, body, signature)
+}
+
+// GetBackupData возвращает данные для бэкапа
+func (s *PaymentService) GetBackupData() interface{} {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	result := make(map[string][]*models.Payment)
+	for id, payment := range s.payments {
+		copied := *payment
+		result[s.owners[id]] = append(result[s.owners[id]], &copied)
+	}
+
+	for _, payments := range result {
+		slices.SortFunc(payments, func(a, b *models.Payment) int {
+			return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), cmp.Compare(a.ID, b.ID))
+		})
+	}
+
+	return result
+}
+
+// GetBackupFileName возвращает имя файла для бэкапа
+func (s *PaymentService) GetBackupFileName() string {
+	return "payments"
+}
+
+// RestoreBackupData заменяет платежи данными из бэкапа
+func (s *PaymentService) RestoreBackupData(data []byte) error {
+	var backup map[string][]*models.Payment
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return fmt.Errorf("can't parse payments: %w", err)
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.load(backup)
+
+	return nil
+}
+
+// ResetUser удаляет платежи пользователя
+func (s *PaymentService) ResetUser(userID string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	for id, owner := range s.owners {
+		if owner == userID {
+			delete(s.payments, id)
+			delete(s.owners, id)
+		}
+	}
+}
+
+func (s *PaymentService) CollectionSizes(_ context.Context) map[string]int {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	return map[string]int{
+		"payments": len(s.payments),
+	}
+}
+
+// ExportUserData возвращает пополнения пользователя через платежного провайдера
+func (s *PaymentService) ExportUserData(ctx context.Context) (any, error) {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	result := make([]models.Payment, 0)
+	for id, payment := range s.payments {
+		if s.owners[id] == userID {
+			result = append(result, *payment)
+		}
+	}
+
+	slices.SortFunc(result, func(a, b models.Payment) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), cmp.Compare(a.ID, b.ID))
+	})
+
+	return result, nil
+}
+
+func (s *PaymentService) GetUserExportName() string {
+	return "payments"
+}
@@ -0,0 +1,70 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"eats-backend/internal/models"
+	"eats-backend/internal/service"
+)
+
+type testPaymentWallet struct {
+	credited map[string]models.Money
+}
+
+func (w *testPaymentWallet) CheckAccount(_ context.Context, accountID string) error {
+	if accountID != "card" {
+		return models.ErrNotFound
+	}
+
+	return nil
+}
+
+func (w *testPaymentWallet) CreditPayment(userID, _ string, amount models.Money, _ string) error {
+	w.credited[userID] += amount
+
+	return nil
+}
+
+func TestPaymentService_Callback(t *testing.T) {
+	wallet := &testPaymentWallet{credited: make(map[string]models.Money)}
+	provider := service.NewSandboxPaymentProvider("http://localhost/", []byte("secret"))
+	payments := service.NewPaymentService(provider, wallet, models.Rubles(5000), zap.NewNop().Sugar(), nil)
+
+	ctx := models.ContextWithUser(t.Context(), "user-1")
+
+	_, err := payments.CreateTopup(ctx, models.TopupRequest{AccountID: "card", Amount: models.Rubles(6000)})
+	require.ErrorIs(t, err, models.ErrBadRequest)
+
+	payment, err := payments.CreateTopup(ctx, models.TopupRequest{AccountID: "card", Amount: models.Rubles(3000)})
+	require.NoError(t, err)
+	require.Equal(t, models.PaymentStatusPending, payment.Status)
+	require.Equal(t, "http://localhost/payments/sandbox/"+payment.ID, payment.PaymentURL)
+
+	body, signature, err := provider.SignCallback(models.PaymentCallback{
+		PaymentID: payment.ID, Status: models.PaymentStatusSucceeded,
+	})
+	require.NoError(t, err)
+
+	_, err = payments.HandleCallback(t.Context(), body, "forged")
+	require.ErrorIs(t, err, models.ErrUnauthorized)
+	require.Empty(t, wallet.credited)
+
+	// Повторное уведомление не зачисляет деньги второй раз
+	for range 2 {
+		payment, err = payments.HandleCallback(t.Context(), body, signature)
+		require.NoError(t, err)
+		require.Equal(t, models.PaymentStatusSucceeded, payment.Status)
+	}
+
+	require.Equal(t, models.Rubles(3000), wallet.credited["user-1"])
+
+	_, err = payments.CompleteSandboxPayment(t.Context(), payment.ID, models.PaymentStatusFailed)
+	require.ErrorIs(t, err, models.ErrBadRequest)
+
+	_, err = payments.GetPayment(models.ContextWithUser(t.Context(), "user-2"), payment.ID)
+	require.ErrorIs(t, err, models.ErrNotFound)
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type StateStore interface {
+	Save(ctx context.Context, name string, data any) error
+	// Load читает сохраненное состояние в dst, false - состояние еще не сохранялось.
+	Load(ctx context.Context, name string, dst any) (bool, error)
+}
+
+// PersistenceService периодически сохраняет состояние сервисов во внешнее хранилище (SQLite).
+// Использует те же данные, что и бэкап, поэтому форматы совпадают с файлами data/.
+type PersistenceService struct {
+	logger      *zap.SugaredLogger
+	store       StateStore
+	backupables []Backupable
+	interval    time.Duration
+
+	mu sync.RWMutex
+}
+
+func NewPersistenceService(logger *zap.SugaredLogger, store StateStore, interval time.Duration) *PersistenceService {
+	return &PersistenceService{
+		logger:      logger,
+		store:       store,
+		backupables: make([]Backupable, 0),
+		interval:    interval,
+	}
+}
+
+// RegisterBackupable регистрирует сервис, состояние которого нужно сохранять
+func (ps *PersistenceService) RegisterBackupable(backupable Backupable) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	ps.backupables = append(ps.backupables, backupable)
+}
+
+// Start сохраняет состояние с заданным интервалом до отмены контекста
+func (ps *PersistenceService) Start(ctx context.Context) {
+	ticker := time.NewTicker(ps.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := ps.Persist(ctx); err != nil {
+				ps.logger.Errorf("Persisting state failed: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Persist сохраняет состояние всех зарегистрированных сервисов
+func (ps *PersistenceService) Persist(ctx context.Context) error {
+	ps.mu.RLock()
+	backupables := make([]Backupable, len(ps.backupables))
+	copy(backupables, ps.backupables)
+	ps.mu.RUnlock()
+
+	var errs []error
+
+	for _, backupable := range backupables {
+		name := backupable.GetBackupFileName()
+
+		data := backupable.GetBackupData()
+		if data == nil {
+			errs = append(errs, fmt.Errorf("%s: no data available", name))
+
+			continue
+		}
+
+		if err := ps.store.Save(ctx, name, data); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Reload заменяет состояние сервисов сохраненным в хранилище. Нужен экземпляру, который становится
+// лидером: пока он был ведомым, состояние менял и сохранял прежний лидер.
+func (ps *PersistenceService) Reload(ctx context.Context) error {
+	ps.mu.RLock()
+	backupables := make([]Backupable, len(ps.backupables))
+	copy(backupables, ps.backupables)
+	ps.mu.RUnlock()
+
+	var errs []error
+
+	for _, backupable := range backupables {
+		restorable, ok := backupable.(Restorable)
+		if !ok {
+			continue
+		}
+
+		name := restorable.GetBackupFileName()
+
+		var data json.RawMessage
+
+		found, err := ps.store.Load(ctx, name, &data)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+
+			continue
+		}
+
+		if !found {
+			continue
+		}
+
+		if err := restorable.RestoreBackupData(data); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"sync/atomic"
+
+	"eats-backend/internal/models"
+)
+
+// Заказ говорит об интересе к товару сильнее, чем просмотр.
+const popularityOrderWeight = 5
+
+type popularityCounters struct {
+	views  atomic.Int64
+	orders atomic.Int64
+}
+
+// ProductPopularity считает просмотры и заказы товаров. Набор товаров фиксируется при создании,
+// после этого map только читается, а счетчики атомарные, поэтому учет просмотра не берет блокировок.
+// Просмотры хранятся только в памяти, заказы при запуске пересчитываются по истории заказов.
+type ProductPopularity struct {
+	counters map[string]*popularityCounters
+}
+
+func NewProductPopularity(products []*models.Product, initialOrders map[string][]*models.Order) *ProductPopularity {
+	popularity := &ProductPopularity{
+		counters: make(map[string]*popularityCounters, len(products)),
+	}
+
+	for _, product := range products {
+		popularity.counters[product.ID] = &popularityCounters{}
+	}
+
+	for _, orders := range initialOrders {
+		for _, order := range orders {
+			popularity.ProductsOrdered(order.Items)
+		}
+	}
+
+	return popularity
+}
+
+// ProductViewed учитывает просмотр детальной страницы товара
+func (p *ProductPopularity) ProductViewed(productID string) {
+	if counters, ok := p.counters[productID]; ok {
+		counters.views.Add(1)
+	}
+}
+
+// ProductsOrdered учитывает заказ: каждый товар заказа считается один раз, независимо от количества
+func (p *ProductPopularity) ProductsOrdered(items []models.OrderItem) {
+	for _, item := range items {
+		if counters, ok := p.counters[item.ID]; ok {
+			counters.orders.Add(1)
+		}
+	}
+}
+
+// Score возвращает популярность товара, неизвестные товары имеют нулевую популярность
+func (p *ProductPopularity) Score(productID string) int64 {
+	counters, ok := p.counters[productID]
+	if !ok {
+		return 0
+	}
+
+	return counters.views.Load() + popularityOrderWeight*counters.orders.Load()
+}
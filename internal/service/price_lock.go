@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"eats-backend/internal/models"
+)
+
+type priceLock struct {
+	id        string
+	expiresAt time.Time
+	items     map[string]models.OrderItem
+}
+
+// PriceLocks фиксирует цены товаров на время оформления заказа: предварительный расчет создает фиксацию,
+// а заказ по ней проверяет, что цены с тех пор не изменились. У пользователя одна фиксация - последняя.
+type PriceLocks struct {
+	locks map[string]*priceLock // userID -> фиксация
+	ttl   time.Duration
+	clock Clock
+
+	mux sync.Mutex
+}
+
+func NewPriceLocks(ttl time.Duration, clock Clock) *PriceLocks {
+	return &PriceLocks{
+		locks: make(map[string]*priceLock),
+		ttl:   ttl,
+		clock: clockOrSystem(clock),
+	}
+}
+
+// Lock фиксирует цены товаров и возвращает идентификатор фиксации и время, до которого она действует
+func (l *PriceLocks) Lock(ctx context.Context, items []models.OrderItem) (string, time.Time) {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	lock := &priceLock{
+		id:        uuid.NewString(),
+		expiresAt: l.clock.Now().Add(l.ttl),
+		items:     make(map[string]models.OrderItem, len(items)),
+	}
+
+	for _, item := range items {
+		lock.items[priceLockKey(item)] = item
+	}
+
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	l.locks[userID] = lock
+
+	return lock.id, lock.expiresAt
+}
+
+// Verify проверяет, что товары заказа совпадают с зафиксированными и их цены не изменились
+func (l *PriceLocks) Verify(ctx context.Context, lockID string, items []models.OrderItem) error {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	l.mux.Lock()
+	lock, ok := l.locks[userID]
+	l.mux.Unlock()
+
+	if !ok || lock.id != lockID {
+		return fmt.Errorf("%w: price lock not found, request checkout preview again", models.ErrBadRequest)
+	}
+
+	if l.clock.Now().After(lock.expiresAt) {
+		return fmt.Errorf("%w: price lock expired, request checkout preview again", models.ErrBadRequest)
+	}
+
+	if len(items) != len(lock.items) {
+		return fmt.Errorf("%w: cart changed after checkout preview", models.ErrBadRequest)
+	}
+
+	for _, item := range items {
+		locked, ok := lock.items[priceLockKey(item)]
+		if !ok || locked.Quantity != item.Quantity {
+			return fmt.Errorf("%w: cart changed after checkout preview", models.ErrBadRequest)
+		}
+
+		if locked.Price != item.Price {
+			return fmt.Errorf("%w: price of %s changed from %s to %s", models.ErrPriceChanged, item.ID, locked.Price, item.Price)
+		}
+	}
+
+	return nil
+}
+
+// Release удаляет фиксацию после оформления заказа, чтобы по ней нельзя было оформить еще один
+func (l *PriceLocks) Release(ctx context.Context, lockID string) {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	if lock, ok := l.locks[userID]; ok && lock.id == lockID {
+		delete(l.locks, userID)
+	}
+}
+
+// priceLockKey отличает товар, заказанный отдельно, от того же товара в наборе
+func priceLockKey(item models.OrderItem) string {
+	if item.ComboID != "" {
+		return models.ComboCartKey(item.ComboID) + "/" + item.ID
+	}
+
+	return item.ID
+}
@@ -0,0 +1,39 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+
+	"eats-backend/internal/models"
+	"eats-backend/internal/service"
+)
+
+func TestPriceLocks_Verify(t *testing.T) {
+	ctx := context.WithValue(t.Context(), models.ContextClaimsKey{}, &models.AuthTokenClaims{
+		RegisteredClaims: &jwt.RegisteredClaims{ID: "user"},
+	})
+
+	clock := service.NewFakeClock(time.Date(2025, time.March, 3, 12, 0, 0, 0, time.UTC))
+	locks := service.NewPriceLocks(time.Minute, clock)
+	items := []models.OrderItem{{ID: "apple", Price: models.Rubles(45), Quantity: 2}, {ID: "bread", Price: models.Rubles(60), Quantity: 1}}
+
+	lockID, _ := locks.Lock(ctx, items)
+	require.NoError(t, locks.Verify(ctx, lockID, items))
+
+	changedPrice := []models.OrderItem{{ID: "apple", Price: models.Rubles(50), Quantity: 2}, {ID: "bread", Price: models.Rubles(60), Quantity: 1}}
+	require.ErrorIs(t, locks.Verify(ctx, lockID, changedPrice), models.ErrPriceChanged)
+
+	changedCart := []models.OrderItem{{ID: "apple", Price: models.Rubles(45), Quantity: 3}, {ID: "bread", Price: models.Rubles(60), Quantity: 1}}
+	require.ErrorIs(t, locks.Verify(ctx, lockID, changedCart), models.ErrBadRequest)
+
+	locks.Release(ctx, lockID)
+	require.ErrorIs(t, locks.Verify(ctx, lockID, items), models.ErrBadRequest)
+
+	lockID, _ = locks.Lock(ctx, items)
+	clock.Advance(time.Minute + time.Second)
+	require.ErrorIs(t, locks.Verify(ctx, lockID, items), models.ErrBadRequest)
+}
@@ -5,99 +5,648 @@ package service
 import (
 	"cmp"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"maps"
 	"math"
-	"net/url"
+	"reflect"
 	"slices"
+	"strings"
 	"sync"
 	"time"
 
+	"eats-backend/internal/events"
 	"eats-backend/internal/models"
 )
 
 type FavouritesService interface {
 	IsFavourite(ctx context.Context, productID string) bool
+	GetFavourites(ctx context.Context) []string
 	AddFavourite(ctx context.Context, id string)
 	RemoveFavourite(ctx context.Context, id string)
+	ApplyFavourites(ctx context.Context, operations []models.FavouriteOperation) []string
 }
 
-const defaultPageSize = 20
+type ViewsRecorder interface {
+	RecordView(userID, productID string)
+	Recent(userID string) []string
+}
+
+type PopularityCounter interface {
+	ProductViewed(productID string)
+	Score(productID string) int64
+}
+
+// ReviewImages проверяет картинки отзывов по каталогу загрузок
+type ReviewImages interface {
+	ResolveUpload(ref string) (string, error)
+}
+
+// ReviewModeration проверяет текст и картинки отзыва и ограничивает частоту отзывов пользователя
+type ReviewModeration interface {
+	ValidateReview(review models.PostReviewRequest) error
+	RecordReview(userID string, now time.Time) error
+}
+
+// ReviewAuthors текущие профили авторов отзывов: имя и аватар в отзыве берутся при каждом чтении
+type ReviewAuthors interface {
+	ReviewAuthor(userID string) (models.ReviewAuthor, bool)
+}
+
+// ProductHours проверяет, можно ли заказать товар сейчас по часам работы магазина и часам продажи товара
+type ProductHours interface {
+	UnavailableReason(availableHours string, now time.Time) string
+}
+
+// AvailabilityWaitlist лист ожидания товаров. Ожидающих уведомляет подписчик события ProductUpdated.
+type AvailabilityWaitlist interface {
+	Subscribe(ctx context.Context, productID string)
+}
+
+const (
+	defaultPageSize = 20
+	// Сколько версий каталога, включая текущую, хранится для отката
+	catalogVersionsLimit = 20
+)
+
+// catalogVersion версия каталога вместе с копией товаров
+type catalogVersion struct {
+	info     models.CatalogVersion
+	products []models.Product
+}
 
 type ProductsService struct {
 	favourites FavouritesService
+	views      ViewsRecorder
+	waitlist   AvailabilityWaitlist
+	popularity PopularityCounter
+	images     ReviewImages
+	moderation ReviewModeration
+	authors    ReviewAuthors
+	hours      ProductHours
+	events     EventPublisher
 
 	products            []*models.Product
 	productsPerCategory map[string][]*models.Product
+	productsPerTag      map[string][]*models.Product
 	productIndex        map[string]*models.Product
+	// Каталог, заранее отсортированный для каждого порядка, кроме популярности: она меняется с каждым просмотром
+	productsSorted map[models.ProductSort][]*models.Product
+
+	// Состав категорий не меняется при откате и восстановлении, по нему заново строится productsPerCategory
+	productIDsPerCategory map[string][]string
+	categories            map[string]models.Category
+
+	// Версии хранятся только в памяти, после перезапуска история начинается с каталога из файла
+	versions    []catalogVersion
+	lastVersion int
 
-	categories map[string]models.Category
+	// Когда в последний раз менялись товары и категории, для ленты изменений. После перезапуска все они
+	// получают время запуска, и клиенты с более ранним since загружают каталог заново.
+	productStamps  map[string]time.Time
+	categoryStamps map[string]time.Time
+	deletedStamps  map[string]time.Time
+
+	// Сводки отзывов по товарам, сбрасываются при новом отзыве и замене каталога
+	reviewSummaries map[string]models.ReviewSummary
+	summaryMux      sync.Mutex
 
 	mux sync.RWMutex
 }
 
 func NewProductsService(
 	favourites FavouritesService,
+	views ViewsRecorder,
+	waitlist AvailabilityWaitlist,
+	popularity PopularityCounter,
+	images ReviewImages,
+	moderation ReviewModeration,
+	authors ReviewAuthors,
+	hours ProductHours,
+	events EventPublisher,
 	products []*models.Product,
 	productIDsPerCategory map[string][]string,
 	categories map[string]models.Category,
 ) *ProductsService {
-	index := make(map[string]*models.Product, len(products))
+	service := &ProductsService{
+		favourites:            favourites,
+		views:                 views,
+		waitlist:              waitlist,
+		popularity:            popularity,
+		images:                images,
+		moderation:            moderation,
+		authors:               authors,
+		hours:                 hours,
+		events:                events,
+		productIDsPerCategory: productIDsPerCategory,
+		categories:            categories,
+		productStamps:         make(map[string]time.Time),
+		categoryStamps:        make(map[string]time.Time),
+		deletedStamps:         make(map[string]time.Time),
+	}
+
+	initial := make([]models.Product, len(products))
+	for i, product := range products {
+		initial[i] = *product
+	}
+
+	service.load(initial)
+	service.commitVersion(models.CatalogVersion{Change: models.CatalogChangeInitial})
+
+	return service
+}
+
+// load заменяет каталог копией products и перестраивает индексы, вызывается в конструкторе или под блокировкой
+func (s *ProductsService) load(products []models.Product) {
+	s.products = make([]*models.Product, len(products))
+	s.productIndex = make(map[string]*models.Product, len(products))
 
 	for i := range products {
-		index[products[i].ID] = products[i]
+		product := cloneProduct(products[i])
+		s.products[i] = &product
+		s.productIndex[product.ID] = &product
+	}
+
+	s.productsPerCategory = make(map[string][]*models.Product)
+	for category, IDs := range s.productIDsPerCategory {
+		s.productsPerCategory[category] = make([]*models.Product, 0, len(IDs))
+		for _, ID := range IDs {
+			// Товара могло не быть в восстановленном каталоге
+			if product, ok := s.productIndex[ID]; ok {
+				s.productsPerCategory[category] = append(s.productsPerCategory[category], product)
+			}
+		}
+	}
+
+	s.productsPerTag = buildTagIndex(s.products)
+	s.productsSorted = buildSortIndex(s.products)
+	s.reviewSummaries = make(map[string]models.ReviewSummary)
+}
+
+// commitVersion запоминает текущий каталог как новую версию, вызывается под блокировкой
+func (s *ProductsService) commitVersion(info models.CatalogVersion) models.CatalogVersion {
+	s.lastVersion++
+
+	info.Version = s.lastVersion
+	info.CreatedAt = time.Now()
+	info.ProductCount = len(s.products)
+
+	products := make([]models.Product, len(s.products))
+	for i, product := range s.products {
+		products[i] = cloneProduct(*product)
 	}
 
-	productsPerCategory := make(map[string][]*models.Product)
-	for category, IDs := range productIDsPerCategory {
-		productsPerCategory[category] = make([]*models.Product, len(IDs))
-		for i, ID := range IDs {
-			productsPerCategory[category][i] = index[ID]
+	var previous []models.Product
+	if len(s.versions) > 0 {
+		previous = s.versions[len(s.versions)-1].products
+	}
+
+	s.stampChanges(previous, products, info.CreatedAt)
+
+	s.versions = append(s.versions, catalogVersion{info: info, products: products})
+	if len(s.versions) > catalogVersionsLimit {
+		s.versions = slices.Delete(s.versions, 0, len(s.versions)-catalogVersionsLimit)
+	}
+
+	return info
+}
+
+// cloneProduct копирует товар вместе с отзывами: в отзывы дописывают новые, и копии не должны делить с
+// оригиналом общий массив
+func cloneProduct(product models.Product) models.Product {
+	product.Reviews = slices.Clone(product.Reviews)
+
+	return product
+}
+
+func buildTagIndex(products []*models.Product) map[string][]*models.Product {
+	productsPerTag := make(map[string][]*models.Product)
+
+	for _, product := range products {
+		for _, tag := range product.Tags {
+			productsPerTag[tag] = append(productsPerTag[tag], product)
 		}
 	}
 
-	return &ProductsService{
-		favourites:          favourites,
-		products:            products,
-		productIndex:        index,
-		categories:          categories,
-		productsPerCategory: productsPerCategory,
+	return productsPerTag
+}
+
+// buildSortIndex сортирует каталог для каждого постоянного порядка. Цена и рейтинг меняются только вместе
+// с каталогом, поэтому индекс пересчитывается в load, а не на каждый запрос.
+func buildSortIndex(products []*models.Product) map[models.ProductSort][]*models.Product {
+	sortBy := func(compare func(a, b *models.Product) int) []*models.Product {
+		sorted := slices.Clone(products)
+		slices.SortFunc(sorted, func(a, b *models.Product) int {
+			return cmp.Or(compare(a, b), cmp.Compare(a.ID, b.ID))
+		})
+
+		return sorted
+	}
+
+	// Новые товары дописываются в конец каталога, поэтому товары без даты появления идут в обратном порядке
+	newest := slices.Clone(products)
+	slices.Reverse(newest)
+	slices.SortStableFunc(newest, func(a, b *models.Product) int {
+		switch {
+		case a.CreatedAt == nil && b.CreatedAt == nil:
+			return 0
+		case a.CreatedAt == nil:
+			return 1
+		case b.CreatedAt == nil:
+			return -1
+		default:
+			return b.CreatedAt.Compare(*a.CreatedAt)
+		}
+	})
+
+	return map[models.ProductSort][]*models.Product{
+		models.ProductSortPriceAsc: sortBy(func(a, b *models.Product) int {
+			return cmp.Compare(a.Price, b.Price)
+		}),
+		models.ProductSortPriceDesc: sortBy(func(a, b *models.Product) int {
+			return cmp.Compare(b.Price, a.Price)
+		}),
+		models.ProductSortRating: sortBy(func(a, b *models.Product) int {
+			return cmp.Compare(b.Rating, a.Rating)
+		}),
+		models.ProductSortNew: newest,
 	}
 }
 
+// GetCategories возвращает категории с числом товаров в каждой
 func (s *ProductsService) GetCategories() []models.Category {
-	categories := slices.SortedFunc(maps.Values(s.categories), func(a models.Category, b models.Category) int {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	categories := make([]models.Category, 0, len(s.categories))
+	for _, category := range s.categories {
+		category.ProductCount = len(s.productsPerCategory[category.ID])
+		categories = append(categories, category)
+	}
+
+	slices.SortFunc(categories, func(a models.Category, b models.Category) int {
 		return cmp.Compare(a.Name, b.Name)
 	})
 
 	return categories
 }
 
-func (s *ProductsService) GetProductsList(ctx context.Context, page, pageSize int, category string) (models.ProductsList, error) {
+// GetLocalizedCategories возвращает категории с названиями на языке пользователя.
+// Без includeEmpty категории без товаров пропускаются.
+func (s *ProductsService) GetLocalizedCategories(ctx context.Context, includeEmpty bool) []models.Category {
+	lang := models.LanguageFromContext(ctx)
+	all := s.GetCategories()
+
+	categories := make([]models.Category, 0, len(all))
+	for _, category := range all {
+		if !includeEmpty && category.ProductCount == 0 {
+			continue
+		}
+
+		category.Localize(lang)
+		categories = append(categories, category)
+	}
+
+	slices.SortFunc(categories, func(a models.Category, b models.Category) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+
+	return categories
+}
+
+// GetAllProducts возвращает копию всего каталога
+func (s *ProductsService) GetAllProducts() []models.Product {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	result := make([]models.Product, 0, len(s.products))
+	for _, product := range s.products {
+		result = append(result, *product)
+	}
+
+	return result
+}
+
+func (s *ProductsService) GetTags() []models.Tag {
 	s.mux.RLock()
 	defer s.mux.RUnlock()
 
+	tags := make([]models.Tag, 0, len(s.productsPerTag))
+	for tag, products := range s.productsPerTag {
+		tags = append(tags, models.Tag{
+			Name:         tag,
+			ProductCount: len(products),
+		})
+	}
+
+	slices.SortFunc(tags, func(a models.Tag, b models.Tag) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+
+	return tags
+}
+
+func (s *ProductsService) GetProductsList(
+	ctx context.Context,
+	page, pageSize int,
+	filter models.ProductsFilter,
+) (models.ProductsList, error) {
+	lockStart := time.Now()
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	models.ObserveTiming(ctx, "products.lock_wait", lockStart)
+
+	// Ожидание блокировки могло съесть время запроса
+	if err := ctx.Err(); err != nil {
+		return models.ProductsList{}, fmt.Errorf("products list: %w", err)
+	}
+
+	if _, ok := s.productsSorted[filter.Sort]; !ok && filter.Sort != "" && filter.Sort != models.ProductSortPopularity {
+		return models.ProductsList{}, fmt.Errorf("%w: unknown sort %s", models.ErrBadRequest, filter.Sort)
+	}
+
+	category := filter.Category
 	products := s.products
 
-	if category != "" && category != "favourite" {
+	switch category {
+	case "":
+	case models.FavouriteCategory:
+		// Устаревший способ получить избранное, оставлен для старых клиентов, замена - GET /favourites
+		products = s.favouriteProducts(ctx)
+	default:
 		if _, categoryExists := s.categories[category]; !categoryExists {
 			return models.ProductsList{}, errors.New("category not found")
 		}
 
 		products = s.productsPerCategory[category]
+	}
 
+	if err := validateRanges(filter); err != nil {
+		return models.ProductsList{}, err
 	}
 
-	if category == "favourite" {
-		products = make([]*models.Product, 0)
-		for _, product := range s.products {
-			if s.favourites.IsFavourite(ctx, product.ID) {
-				products = append(products, product)
-			}
+	products = s.filterByCollection(products, filter.Collection)
+	products = s.filterByZone(products, filter.Zone)
+	products = s.filterByTags(products, filter.Tags)
+	products = filterByAllergens(products, filter.ExcludeAllergens)
+	products = filterByRanges(products, filter)
+
+	switch filter.Sort {
+	case "":
+	case models.ProductSortPopularity:
+		products = s.sortByPopularity(products)
+	default:
+		products = s.sortByIndex(products, filter.Sort)
+	}
+
+	return s.previewPage(ctx, products, page, pageSize), nil
+}
+
+// Границы диапазонов веса в граммах для фасетов
+var weightRangeBounds = []int{100, 250, 500, 1000}
+
+// GetFacets считает значения фильтров по товарам категории, а без категории - по всему каталогу.
+// Пустые диапазоны веса не возвращаются.
+func (s *ProductsService) GetFacets(ctx context.Context, category string) (models.ProductFacets, error) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	products := s.products
+
+	switch category {
+	case "":
+	case models.FavouriteCategory:
+		products = s.favouriteProducts(ctx)
+	default:
+		if _, ok := s.categories[category]; !ok {
+			return models.ProductFacets{}, fmt.Errorf("%w: category not found", models.ErrNotFound)
 		}
+
+		products = s.productsPerCategory[category]
+	}
+
+	facets := models.ProductFacets{
+		ProductCount: len(products),
+		WeightRanges: make([]models.CountRange, 0),
+		Tags:         make([]models.Tag, 0),
+		Allergens:    make([]string, 0),
+	}
+
+	if len(products) == 0 {
+		return facets, nil
 	}
 
+	facets.Price = models.MoneyRange{Min: products[0].Price, Max: products[0].Price}
+	facets.Weight = models.IntRange{Min: products[0].Weight, Max: products[0].Weight}
+
+	weightCounts := make([]int, len(weightRangeBounds)+1)
+	tagCounts := make(map[string]int)
+	allergens := make(map[string]struct{})
+
+	for _, product := range products {
+		facets.Price.Min = min(facets.Price.Min, product.Price)
+		facets.Price.Max = max(facets.Price.Max, product.Price)
+		facets.Weight.Min = min(facets.Weight.Min, product.Weight)
+		facets.Weight.Max = max(facets.Weight.Max, product.Weight)
+
+		bucket, _ := slices.BinarySearch(weightRangeBounds, product.Weight+1)
+		weightCounts[bucket]++
+
+		for _, tag := range product.Tags {
+			tagCounts[tag]++
+		}
+
+		for _, allergen := range product.Allergens {
+			allergens[allergen] = struct{}{}
+		}
+
+		if product.Discount > 0 {
+			facets.DiscountedCount++
+			facets.MaxDiscount = max(facets.MaxDiscount, product.Discount)
+		}
+	}
+
+	for i, count := range weightCounts {
+		if count == 0 {
+			continue
+		}
+
+		weightRange := models.CountRange{ProductCount: count}
+		if i > 0 {
+			weightRange.From = weightRangeBounds[i-1]
+		}
+
+		if i < len(weightRangeBounds) {
+			to := weightRangeBounds[i]
+			weightRange.To = &to
+		}
+
+		facets.WeightRanges = append(facets.WeightRanges, weightRange)
+	}
+
+	for tag, count := range tagCounts {
+		facets.Tags = append(facets.Tags, models.Tag{Name: tag, ProductCount: count})
+	}
+
+	slices.SortFunc(facets.Tags, func(a, b models.Tag) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+
+	for allergen := range allergens {
+		facets.Allergens = append(facets.Allergens, allergen)
+	}
+
+	slices.Sort(facets.Allergens)
+
+	return facets, nil
+}
+
+// GetPopular возвращает самые популярные товары в наличии. Товары без просмотров и заказов не попадают в подборку.
+func (s *ProductsService) GetPopular(ctx context.Context, limit int) ([]models.ProductPreview, error) {
+	if limit <= 0 || limit > models.MaxPopularLimit {
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", models.ErrBadRequest, models.MaxPopularLimit)
+	}
+
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	products := make([]*models.Product, 0, len(s.products))
+	for _, product := range s.products {
+		if product.Available && s.popularity.Score(product.ID) > 0 {
+			products = append(products, product)
+		}
+	}
+
+	products = s.sortByPopularity(products)
+	lang := models.LanguageFromContext(ctx)
+	now := time.Now()
+
+	result := make([]models.ProductPreview, 0, min(limit, len(products)))
+	for _, product := range products[:min(limit, len(products))] {
+		preview := product.ToPreview()
+		preview.Name = product.LocalizedName(lang)
+		preview.IsFavorite = s.favourites.IsFavourite(ctx, product.ID)
+		preview.UnavailableReason = s.unavailableReason(product, now)
+
+		result = append(result, preview)
+	}
+
+	return result, nil
+}
+
+// SearchProducts ищет товары по названию на языке пользователя и на основном языке, а также по описанию.
+// Сначала идут товары, название которых начинается с запроса, последними - найденные только по описанию.
+// Возвращает первые limit товаров и общее число найденных.
+func (s *ProductsService) SearchProducts(ctx context.Context, query string, limit int) ([]models.ProductPreview, int) {
+	query = normalizeSearch(query)
+	lang := models.LanguageFromContext(ctx)
+
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	ranks := make(map[string]int)
+	products := make([]*models.Product, 0)
+
+	for _, product := range s.products {
+		rank, ok := productSearchRank(product, query, lang)
+		if ok {
+			ranks[product.ID] = rank
+			products = append(products, product)
+		}
+	}
+
+	slices.SortStableFunc(products, func(a, b *models.Product) int {
+		return cmp.Compare(ranks[a.ID], ranks[b.ID])
+	})
+
+	return s.previewPage(ctx, products, 1, limit).Data, len(products)
+}
+
+// productSearchRank чем меньше, тем выше товар в результатах поиска.
+func productSearchRank(product *models.Product, query, lang string) (int, bool) {
+	names := []string{normalizeSearch(product.LocalizedName(lang)), normalizeSearch(product.Name)}
+
+	switch {
+	case slices.ContainsFunc(names, func(name string) bool { return strings.HasPrefix(name, query) }):
+		return 0, true
+	case slices.ContainsFunc(names, func(name string) bool { return strings.Contains(name, query) }):
+		return 1, true
+	case strings.Contains(normalizeSearch(product.LocalizedDescription(lang)), query):
+		return 2, true
+	default:
+		return 0, false
+	}
+}
+
+// sortByPopularity возвращает отсортированную копию, при равной популярности сохраняется порядок каталога.
+// Счетчики меняются без блокировки каталога, поэтому сначала снимаются их значения.
+func (s *ProductsService) sortByPopularity(products []*models.Product) []*models.Product {
+	scores := make(map[string]int64, len(products))
+	for _, product := range products {
+		scores[product.ID] = s.popularity.Score(product.ID)
+	}
+
+	result := slices.Clone(products)
+	slices.SortFunc(result, func(a, b *models.Product) int {
+		return cmp.Or(cmp.Compare(scores[b.ID], scores[a.ID]), cmp.Compare(a.ID, b.ID))
+	})
+
+	return result
+}
+
+// sortByIndex упорядочивает отфильтрованные товары по готовому индексу: проходит отсортированный каталог
+// и оставляет те, что прошли фильтры
+func (s *ProductsService) sortByIndex(products []*models.Product, sort models.ProductSort) []*models.Product {
+	sorted := s.productsSorted[sort]
+	if len(products) == len(s.products) {
+		return sorted
+	}
+
+	selected := make(map[string]struct{}, len(products))
+	for _, product := range products {
+		selected[product.ID] = struct{}{}
+	}
+
+	result := make([]*models.Product, 0, len(products))
+	for _, product := range sorted {
+		if _, ok := selected[product.ID]; ok {
+			result = append(result, product)
+		}
+	}
+
+	return result
+}
+
+// GetFavourites возвращает страницу избранных товаров пользователя
+func (s *ProductsService) GetFavourites(ctx context.Context, page, pageSize int) models.ProductsList {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	return s.previewPage(ctx, s.favouriteProducts(ctx), page, pageSize)
+}
+
+// favouriteProducts находит избранные товары по сохраненным идентификаторам. Товары, которых
+// больше нет в каталоге, пропускаются.
+func (s *ProductsService) favouriteProducts(ctx context.Context) []*models.Product {
+	productIDs := s.favourites.GetFavourites(ctx)
+
+	products := make([]*models.Product, 0, len(productIDs))
+	for _, id := range productIDs {
+		if product, ok := s.productIndex[id]; ok {
+			products = append(products, product)
+		}
+	}
+
+	return products
+}
+
+// previewPage возвращает страницу превью товаров на языке пользователя
+func (s *ProductsService) previewPage(
+	ctx context.Context,
+	products []*models.Product,
+	page, pageSize int,
+) models.ProductsList {
 	productsAmount := len(products)
 	totalPages := int(math.Ceil(float64(productsAmount) / float64(pageSize)))
 
@@ -108,7 +657,7 @@ func (s *ProductsService) GetProductsList(ctx context.Context, page, pageSize in
 			CurrentPage: page,
 			TotalPages:  totalPages,
 			Data:        nil,
-		}, nil
+		}
 	}
 
 	paginationEnd := paginationStart + pageSize
@@ -118,11 +667,15 @@ func (s *ProductsService) GetProductsList(ctx context.Context, page, pageSize in
 
 	listLen := paginationEnd - paginationStart
 	result := make([]models.ProductPreview, 0, listLen)
+	lang := models.LanguageFromContext(ctx)
+	now := time.Now()
 
 	for i := paginationStart; i < paginationEnd; i++ {
 		product := products[i]
 		preview := product.ToPreview()
+		preview.Name = product.LocalizedName(lang)
 		preview.IsFavorite = s.favourites.IsFavourite(ctx, product.ID)
+		preview.UnavailableReason = s.unavailableReason(product, now)
 
 		result = append(result, preview)
 	}
@@ -131,7 +684,202 @@ func (s *ProductsService) GetProductsList(ctx context.Context, page, pageSize in
 		CurrentPage: page,
 		TotalPages:  totalPages,
 		Data:        result,
-	}, nil
+	}
+}
+
+// filterByCollection оставляет товары подборки в порядке подборки
+func (s *ProductsService) filterByCollection(
+	products []*models.Product,
+	collection *models.CollectionFilter,
+) []*models.Product {
+	if collection == nil {
+		return products
+	}
+
+	selected := make(map[string]struct{}, len(products))
+	for _, product := range products {
+		selected[product.ID] = struct{}{}
+	}
+
+	result := make([]*models.Product, 0, len(collection.ProductIDs))
+
+	for _, id := range collection.ProductIDs {
+		if _, ok := selected[id]; !ok {
+			continue
+		}
+
+		// Повтор в подборке показывается один раз
+		delete(selected, id)
+		result = append(result, s.productIndex[id])
+	}
+
+	return result
+}
+
+// NewProductIDs товары, появившиеся в каталоге начиная с since, сначала самые новые
+func (s *ProductsService) NewProductIDs(since time.Time) []string {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	result := make([]string, 0)
+
+	for _, product := range s.productsSorted[models.ProductSortNew] {
+		// Индекс отсортирован по дате, товары без даты в конце
+		if product.CreatedAt == nil || product.CreatedAt.Before(since) {
+			break
+		}
+
+		result = append(result, product.ID)
+	}
+
+	return result
+}
+
+// filterByZone убирает товары, привязанные к зонам доставки, кроме привязанных к зоне пользователя
+func (s *ProductsService) filterByZone(products []*models.Product, zone *models.ZoneFilter) []*models.Product {
+	if zone == nil {
+		return products
+	}
+
+	restricted := make(map[string]struct{}, len(zone.Products))
+	maps.Copy(restricted, zone.Products)
+
+	for category := range zone.Categories {
+		for _, id := range s.productIDsPerCategory[category] {
+			restricted[id] = struct{}{}
+		}
+	}
+
+	allowed := make(map[string]struct{})
+	if zone.Zone != nil {
+		for _, id := range zone.Zone.Products {
+			allowed[id] = struct{}{}
+		}
+
+		for _, category := range zone.Zone.Categories {
+			for _, id := range s.productIDsPerCategory[category] {
+				allowed[id] = struct{}{}
+			}
+		}
+	}
+
+	result := make([]*models.Product, 0, len(products))
+	for _, product := range products {
+		_, isRestricted := restricted[product.ID]
+		_, isAllowed := allowed[product.ID]
+
+		if !isRestricted || isAllowed {
+			result = append(result, product)
+		}
+	}
+
+	return result
+}
+
+// filterByTags оставляет только товары, у которых есть все метки из tags.
+// Проверка идет по индексу самой редкой метки, чтобы не перебирать весь список.
+func (s *ProductsService) filterByTags(products []*models.Product, tags []string) []*models.Product {
+	if len(tags) == 0 {
+		return products
+	}
+
+	rarest := tags[0]
+	for _, tag := range tags[1:] {
+		if len(s.productsPerTag[tag]) < len(s.productsPerTag[rarest]) {
+			rarest = tag
+		}
+	}
+
+	matched := make(map[string]struct{}, len(s.productsPerTag[rarest]))
+	for _, product := range s.productsPerTag[rarest] {
+		if hasAllTags(product, tags) {
+			matched[product.ID] = struct{}{}
+		}
+	}
+
+	result := make([]*models.Product, 0, len(matched))
+	for _, product := range products {
+		if _, ok := matched[product.ID]; ok {
+			result = append(result, product)
+		}
+	}
+
+	return result
+}
+
+func hasAllTags(product *models.Product, tags []string) bool {
+	for _, tag := range tags {
+		if !slices.Contains(product.Tags, tag) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func filterByAllergens(products []*models.Product, allergens []string) []*models.Product {
+	if len(allergens) == 0 {
+		return products
+	}
+
+	result := make([]*models.Product, 0, len(products))
+	for _, product := range products {
+		if !slices.ContainsFunc(product.Allergens, func(allergen string) bool {
+			return slices.Contains(allergens, allergen)
+		}) {
+			result = append(result, product)
+		}
+	}
+
+	return result
+}
+
+func validateRanges(filter models.ProductsFilter) error {
+	if err := validateRange("price", filter.PriceMin, filter.PriceMax); err != nil {
+		return err
+	}
+
+	return validateRange("weight", filter.WeightMin, filter.WeightMax)
+}
+
+func validateRange[T int | models.Money](name string, lower, upper *T) error {
+	if (lower != nil && *lower < 0) || (upper != nil && *upper < 0) {
+		return fmt.Errorf("%w: %s bounds must not be negative", models.ErrBadRequest, name)
+	}
+
+	if lower != nil && upper != nil && *lower > *upper {
+		return fmt.Errorf("%w: %sMin %v is greater than %sMax %v", models.ErrBadRequest, name, *lower, name, *upper)
+	}
+
+	return nil
+}
+
+func inRange[T int | models.Money](value T, lower, upper *T) bool {
+	return (lower == nil || value >= *lower) && (upper == nil || value <= *upper)
+}
+
+// filterByRanges оставляет товары в границах цены и веса и с нужным наличием скидки
+func filterByRanges(products []*models.Product, filter models.ProductsFilter) []*models.Product {
+	if filter.PriceMin == nil && filter.PriceMax == nil && filter.WeightMin == nil && filter.WeightMax == nil &&
+		filter.HasDiscount == nil {
+		return products
+	}
+
+	result := make([]*models.Product, 0, len(products))
+	for _, product := range products {
+		if !inRange(product.Price, filter.PriceMin, filter.PriceMax) ||
+			!inRange(product.Weight, filter.WeightMin, filter.WeightMax) {
+			continue
+		}
+
+		if filter.HasDiscount != nil && (product.Discount > 0) != *filter.HasDiscount {
+			continue
+		}
+
+		result = append(result, product)
+	}
+
+	return result
 }
 
 func (s *ProductsService) GetProductByID(ctx context.Context, id string) (models.Product, error) {
@@ -144,14 +892,67 @@ func (s *ProductsService) GetProductByID(ctx context.Context, id string) (models
 	}
 
 	product := *productLink
+	product.Reviews = s.resolveReviewAuthors(product.Reviews)
 	product.IsFavorite = s.favourites.IsFavourite(ctx, product.ID)
+	product.UnavailableReason = s.unavailableReason(productLink, time.Now())
+	product.Localize(models.LanguageFromContext(ctx))
+
+	return product, nil
+}
+
+// ViewProduct возвращает товар для детальной страницы и запоминает просмотр
+func (s *ProductsService) ViewProduct(ctx context.Context, id string) (models.Product, error) {
+	product, err := s.GetProductByID(ctx, id)
+	if err != nil {
+		return models.Product{}, err
+	}
+
+	s.views.RecordView(models.ClaimsFromContext(ctx).ID, id)
+	s.popularity.ProductViewed(id)
 
 	return product, nil
 }
 
+// GetRecentlyViewed возвращает недавно просмотренные товары, начиная с последнего
+func (s *ProductsService) GetRecentlyViewed(ctx context.Context) []models.ProductPreview {
+	productIDs := s.views.Recent(models.ClaimsFromContext(ctx).ID)
+	lang := models.LanguageFromContext(ctx)
+
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	result := make([]models.ProductPreview, 0, len(productIDs))
+	now := time.Now()
+
+	for _, id := range productIDs {
+		product, ok := s.productIndex[id]
+		if !ok {
+			continue
+		}
+
+		preview := product.ToPreview()
+		preview.Name = product.LocalizedName(lang)
+		preview.IsFavorite = s.favourites.IsFavourite(ctx, product.ID)
+		preview.UnavailableReason = s.unavailableReason(product, now)
+
+		result = append(result, preview)
+	}
+
+	return result
+}
+
+// unavailableReason возвращает, почему товар в наличии нельзя заказать сейчас по времени.
+// Товар не в наличии недоступен и так, часы для него не проверяются.
+func (s *ProductsService) unavailableReason(product *models.Product, now time.Time) string {
+	if s.hours == nil || !product.Available {
+		return ""
+	}
+
+	return s.hours.UnavailableReason(product.AvailableHours, now)
+}
+
 func (s *ProductsService) AddFavourite(ctx context.Context, id string) error {
-	_, ok := s.productIndex[id]
-	if !ok {
+	if !s.ProductExists(id) {
 		return fmt.Errorf("%w: no such product", models.ErrNotFound)
 	}
 
@@ -161,8 +962,7 @@ func (s *ProductsService) AddFavourite(ctx context.Context, id string) error {
 }
 
 func (s *ProductsService) RemoveFavourite(ctx context.Context, id string) error {
-	_, ok := s.productIndex[id]
-	if !ok {
+	if !s.ProductExists(id) {
 		return fmt.Errorf("%w: no such product", models.ErrNotFound)
 	}
 
@@ -171,46 +971,374 @@ func (s *ProductsService) RemoveFavourite(ctx context.Context, id string) error
 	return nil
 }
 
+// BatchFavourites добавляет и убирает несколько товаров из избранного за один запрос. Сначала проверяются
+// все операции, и если хоть одна неверна, избранное не меняется. Убрать можно и товар, удаленный из каталога.
+func (s *ProductsService) BatchFavourites(
+	ctx context.Context,
+	req models.FavouritesBatchRequest,
+) (models.FavouritesBatchResponse, error) {
+	if len(req.Operations) == 0 {
+		return models.FavouritesBatchResponse{}, fmt.Errorf("%w: operations are required", models.ErrBadRequest)
+	}
+
+	if len(req.Operations) > models.MaxFavouriteOperations {
+		return models.FavouritesBatchResponse{}, fmt.Errorf(
+			"%w: too many operations, max %d", models.ErrBadRequest, models.MaxFavouriteOperations,
+		)
+	}
+
+	for _, operation := range req.Operations {
+		if operation.ProductID == "" {
+			return models.FavouritesBatchResponse{}, fmt.Errorf("%w: product id is required", models.ErrBadRequest)
+		}
+
+		switch operation.Action {
+		case models.FavouriteActionAdd:
+			if !s.ProductExists(operation.ProductID) {
+				return models.FavouritesBatchResponse{}, fmt.Errorf(
+					"%w: no such product %s", models.ErrNotFound, operation.ProductID,
+				)
+			}
+		case models.FavouriteActionRemove:
+		default:
+			return models.FavouritesBatchResponse{}, fmt.Errorf(
+				"%w: unknown action %s, should be add or remove", models.ErrBadRequest, operation.Action,
+			)
+		}
+	}
+
+	result := models.FavouritesBatchResponse{Favourites: s.favourites.ApplyFavourites(ctx, req.Operations)}
+	if result.Favourites == nil {
+		result.Favourites = []string{}
+	}
+
+	return result, nil
+}
+
 func (s *ProductsService) ProductExists(id string) bool {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
 	_, ok := s.productIndex[id]
 
 	return ok
 }
 
+// NotifyWhenAvailable подписывает пользователя на появление товара в наличии
+func (s *ProductsService) NotifyWhenAvailable(ctx context.Context, id string) error {
+	// Подписка под блокировкой: иначе товар может появиться между проверкой и подпиской,
+	// и пользователь встанет в лист ожидания уже после уведомлений
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	product, ok := s.productIndex[id]
+	if !ok {
+		return fmt.Errorf("%w: no such product", models.ErrNotFound)
+	}
+
+	if product.Available {
+		return fmt.Errorf("%w: product is already available", models.ErrBadRequest)
+	}
+
+	s.waitlist.Subscribe(ctx, id)
+
+	return nil
+}
+
+// SetAvailability меняет наличие товара. Когда товар появляется, ожидавшие его пользователи получают уведомления.
+func (s *ProductsService) SetAvailability(ctx context.Context, id string, available bool) (models.Product, error) {
+	s.mux.Lock()
+
+	productLink, ok := s.productIndex[id]
+	if !ok {
+		s.mux.Unlock()
+
+		return models.Product{}, fmt.Errorf("%w: no such product", models.ErrNotFound)
+	}
+
+	wasAvailable := productLink.Available
+	productLink.Available = available
+	product := cloneProduct(*productLink)
+
+	if wasAvailable != available {
+		s.commitVersion(models.CatalogVersion{Change: models.CatalogChangeAvailability, ProductID: id})
+	}
+
+	s.mux.Unlock()
+
+	if wasAvailable != available {
+		s.events.Publish(ctx, events.ProductUpdated{
+			Product:      product,
+			Change:       models.CatalogChangeAvailability,
+			WasAvailable: wasAvailable,
+		})
+	}
+
+	return product, nil
+}
+
 func (s *ProductsService) AddReview(ctx context.Context, review models.PostReviewRequest, productID string) error {
-	name := models.ClaimsFromContext(ctx).Nickname
+	claims := models.ClaimsFromContext(ctx)
 
 	if review.Rating > 5 || review.Rating < 1 {
 		return fmt.Errorf("%w: rating must be between 1 and 5", models.ErrBadRequest)
 	}
 
+	if s.moderation != nil {
+		if err := s.moderation.ValidateReview(review); err != nil {
+			return err
+		}
+	}
+
+	// В отзыве сохраняются только файлы, загруженные через /uploads, и всегда полной ссылкой
+	images := make([]string, 0, len(review.Images))
 	for _, image := range review.Images {
-		if _, err := url.Parse(image); err != nil {
-			return fmt.Errorf("%w: invalid image: %s must be url", models.ErrBadRequest, image)
+		resolved, err := s.images.ResolveUpload(image)
+		if err != nil {
+			return err
 		}
+
+		images = append(images, resolved)
 	}
 
-	if _, ok := s.productIndex[productID]; !ok {
+	s.mux.Lock()
+
+	product, ok := s.productIndex[productID]
+	if !ok {
+		s.mux.Unlock()
+
 		return fmt.Errorf("%w: no such product", models.ErrNotFound)
 	}
 
-	s.mux.Lock()
-	defer s.mux.Unlock()
+	now := time.Now()
+
+	// Отзыв учитывается в лимите, только когда товар найден и отзыв точно будет сохранен
+	if s.moderation != nil {
+		if err := s.moderation.RecordReview(claims.ID, now); err != nil {
+			s.mux.Unlock()
+
+			return err
+		}
+	}
 
 	newReview := models.Review{
 		Rating:    review.Rating,
-		Author:    name,
-		CreatedAt: time.Now(),
+		Author:    claims.Nickname,
+		UserID:    claims.ID,
+		CreatedAt: now,
 		Content:   review.Content,
-		Images:    review.Images,
+		Images:    images,
 	}
 
-	product := s.productIndex[productID]
 	if product.Reviews == nil {
 		product.Reviews = make([]models.Review, 0)
 	}
 
 	product.Reviews = append(product.Reviews, newReview)
+	delete(s.reviewSummaries, productID)
+
+	s.commitVersion(models.CatalogVersion{Change: models.CatalogChangeReview, ProductID: productID})
+	updated := cloneProduct(*product)
+
+	s.mux.Unlock()
+
+	s.events.Publish(ctx, events.ProductUpdated{
+		Product:      updated,
+		Change:       models.CatalogChangeReview,
+		WasAvailable: updated.Available,
+	})
+
+	return nil
+}
+
+// resolveReviewAuthors возвращает копию отзывов с текущими именем и аватаром авторов. У автора без
+// имени в профиле остается никнейм из отзыва, автор удаленного аккаунта скрывается.
+func (s *ProductsService) resolveReviewAuthors(reviews []models.Review) []models.Review {
+	if s.authors == nil || len(reviews) == 0 {
+		return reviews
+	}
+
+	result := slices.Clone(reviews)
+
+	for i := range result {
+		review := &result[i]
+		review.Author, review.AuthorImage, review.AuthorDeleted = s.reviewAuthor(review.UserID, review.Author)
+
+		if review.AuthorDeleted {
+			review.UserID = ""
+		}
+	}
+
+	return result
+}
+
+// reviewAuthor имя, аватар автора отзыва и удален ли его аккаунт
+func (s *ProductsService) reviewAuthor(userID, nickname string) (string, string, bool) {
+	if s.authors == nil || userID == "" {
+		return nickname, "", false
+	}
+
+	author, ok := s.authors.ReviewAuthor(userID)
+	if !ok {
+		return nickname, "", false
+	}
+
+	if author.Deleted {
+		return "", "", true
+	}
+
+	if author.Name != "" {
+		nickname = author.Name
+	}
+
+	return nickname, author.Image, false
+}
+
+// stampChanges отмечает товары и категории, которые отличаются между двумя версиями каталога,
+// вызывается под блокировкой
+func (s *ProductsService) stampChanges(previous, current []models.Product, stamp time.Time) {
+	before := make(map[string]models.Product, len(previous))
+	for _, product := range previous {
+		before[product.ID] = product
+	}
+
+	// Товары, которые появились или пропали: у их категорий изменился состав
+	moved := make(map[string]bool)
+
+	for _, product := range current {
+		old, existed := before[product.ID]
+		delete(before, product.ID)
+
+		if existed && reflect.DeepEqual(old, product) {
+			continue
+		}
+
+		s.productStamps[product.ID] = stamp
+		delete(s.deletedStamps, product.ID)
+
+		if !existed {
+			moved[product.ID] = true
+		}
+	}
+
+	for productID := range before {
+		delete(s.productStamps, productID)
+		s.deletedStamps[productID] = stamp
+		moved[productID] = true
+	}
+
+	for categoryID, productIDs := range s.productIDsPerCategory {
+		_, stamped := s.categoryStamps[categoryID]
+		if !stamped || slices.ContainsFunc(productIDs, func(id string) bool { return moved[id] }) {
+			s.categoryStamps[categoryID] = stamp
+		}
+	}
+}
+
+// GetCatalogChanges возвращает товары и категории, изменившиеся после since. С нулевым since возвращается весь каталог.
+func (s *ProductsService) GetCatalogChanges(_ context.Context, since time.Time) models.CatalogChanges {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	current := s.versions[len(s.versions)-1].info
+
+	changedAfter := func(stamps map[string]time.Time) []string {
+		result := make([]string, 0)
+		for id, changedAt := range stamps {
+			if changedAt.After(since) {
+				result = append(result, id)
+			}
+		}
+
+		slices.Sort(result)
+
+		return result
+	}
+
+	return models.CatalogChanges{
+		Version:    current.Version,
+		UpdatedAt:  current.CreatedAt,
+		Products:   changedAfter(s.productStamps),
+		Categories: changedAfter(s.categoryStamps),
+		Deleted:    changedAfter(s.deletedStamps),
+	}
+}
+
+// ListCatalogVersions возвращает сохраненные версии каталога, новые первыми
+func (s *ProductsService) ListCatalogVersions(_ context.Context) []models.CatalogVersion {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	result := make([]models.CatalogVersion, 0, len(s.versions))
+	for i := len(s.versions) - 1; i >= 0; i-- {
+		result = append(result, s.versions[i].info)
+	}
+
+	result[0].Current = true
+
+	return result
+}
+
+// RollbackCatalog возвращает каталог к прошлой версии. Откат сохраняется новой версией, поэтому его тоже
+// можно отменить. Ожидающие товар пользователи не уведомляются, даже если товар снова появился в наличии.
+func (s *ProductsService) RollbackCatalog(_ context.Context, version int) (models.CatalogVersion, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if version == s.lastVersion {
+		return models.CatalogVersion{}, fmt.Errorf("%w: version %d is already current", models.ErrBadRequest, version)
+	}
+
+	index := slices.IndexFunc(s.versions, func(v catalogVersion) bool { return v.info.Version == version })
+	if index < 0 {
+		return models.CatalogVersion{}, fmt.Errorf("%w: catalog version %d not found", models.ErrNotFound, version)
+	}
+
+	s.load(s.versions[index].products)
+
+	info := s.commitVersion(models.CatalogVersion{Change: models.CatalogChangeRollback, RolledBackTo: version})
+	info.Current = true
+
+	return info, nil
+}
+
+// ReferencedUploads возвращает ссылки на картинки из отзывов, чтобы хранилище не удалило их
+func (s *ProductsService) ReferencedUploads() []string {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	result := make([]string, 0)
+	for _, product := range s.products {
+		for _, review := range product.Reviews {
+			result = append(result, review.Images...)
+		}
+	}
+
+	return result
+}
+
+// GetBackupData возвращает каталог вместе с оставленными отзывами
+func (s *ProductsService) GetBackupData() interface{} {
+	return s.GetAllProducts()
+}
+
+// GetBackupFileName возвращает имя файла для бэкапа
+func (s *ProductsService) GetBackupFileName() string {
+	return "products"
+}
+
+// RestoreBackupData заменяет каталог данными из бэкапа. Прежний каталог остается в истории версий.
+func (s *ProductsService) RestoreBackupData(data []byte) error {
+	var products []models.Product
+	if err := json.Unmarshal(data, &products); err != nil {
+		return fmt.Errorf("can't parse products: %w", err)
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.load(products)
+	s.commitVersion(models.CatalogVersion{Change: models.CatalogChangeRestore})
 
 	return nil
 }
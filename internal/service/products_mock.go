@@ -13,6 +13,7 @@ import (
 	context "context"
 	reflect "reflect"
 
+	models "eats-backend/internal/models"
 	gomock "go.uber.org/mock/gomock"
 )
 
@@ -52,6 +53,34 @@ func (mr *MockUserServiceMockRecorder) AddFavourite(ctx, id any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddFavourite", reflect.TypeOf((*MockUserService)(nil).AddFavourite), ctx, id)
 }
 
+// ApplyFavourites mocks base method.
+func (m *MockUserService) ApplyFavourites(ctx context.Context, operations []models.FavouriteOperation) []string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ApplyFavourites", ctx, operations)
+	ret0, _ := ret[0].([]string)
+	return ret0
+}
+
+// ApplyFavourites indicates an expected call of ApplyFavourites.
+func (mr *MockUserServiceMockRecorder) ApplyFavourites(ctx, operations any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ApplyFavourites", reflect.TypeOf((*MockUserService)(nil).ApplyFavourites), ctx, operations)
+}
+
+// GetFavourites mocks base method.
+func (m *MockUserService) GetFavourites(ctx context.Context) []string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFavourites", ctx)
+	ret0, _ := ret[0].([]string)
+	return ret0
+}
+
+// GetFavourites indicates an expected call of GetFavourites.
+func (mr *MockUserServiceMockRecorder) GetFavourites(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFavourites", reflect.TypeOf((*MockUserService)(nil).GetFavourites), ctx)
+}
+
 // IsFavourite mocks base method.
 func (m *MockUserService) IsFavourite(ctx context.Context, productID string) bool {
 	m.ctrl.T.Helper()
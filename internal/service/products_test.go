@@ -1,13 +1,19 @@
 package service_test
 
 import (
+	"eats-backend/internal/events"
 	"eats-backend/internal/models"
 	"eats-backend/internal/service"
+	"encoding/json"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
+	"unicode/utf8"
 
+	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
 )
 
 func TestProductsService_GetProductByID(t *testing.T) {
@@ -16,13 +22,13 @@ func TestProductsService_GetProductByID(t *testing.T) {
 	id := "ff25265d-9dfc-49c3-bd01-678c6baa001f"
 
 	userService := service.NewMockUserService(ctrl)
-	service := service.NewProductsService(userService, []*models.Product{
+	service := service.NewProductsService(userService, service.NewRecentlyViewed(service.DefaultRecentlyViewedLimit), nil, service.NewProductPopularity(nil, nil), nil, nil, nil, nil, nil, []*models.Product{
 		{
 			ID:          id,
 			Image:       "https://basket-01.wbbasket.ru/vol100/part10039/10039442/images/big/1.webp",
 			Name:        "Мука",
 			Weight:      123,
-			Price:       1000,
+			Price:       models.Rubles(1000),
 			Rating:      5.6,
 			Description: "Норм",
 			Discount:    0,
@@ -43,3 +49,524 @@ func TestProductsService_GetProductByID(t *testing.T) {
 	fmt.Println(service.GetProductByID(t.Context(), id))
 	fmt.Println(service.GetProductByID(t.Context(), id))
 }
+
+func TestProductsService_GetProductsListFilters(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	favourites := service.NewMockUserService(ctrl)
+	favourites.EXPECT().IsFavourite(gomock.Any(), gomock.Any()).Return(false).AnyTimes()
+
+	products := service.NewProductsService(favourites, service.NewRecentlyViewed(service.DefaultRecentlyViewedLimit), nil, service.NewProductPopularity(nil, nil), nil, nil, nil, nil, nil, []*models.Product{
+		{ID: "apple", Tags: []string{"vegan", "gluten-free"}},
+		{ID: "bread", Tags: []string{"vegan"}, Allergens: []string{"gluten"}},
+		{ID: "cheese", Tags: []string{"vegetarian", "gluten-free"}, Allergens: []string{"lactose"}},
+	}, map[string][]string{}, map[string]models.Category{})
+
+	ids := func(list models.ProductsList) []string {
+		result := make([]string, 0, len(list.Data))
+		for _, preview := range list.Data {
+			result = append(result, preview.ID)
+		}
+
+		return result
+	}
+
+	list, err := products.GetProductsList(t.Context(), 1, 20, models.ProductsFilter{Tags: []string{"vegan"}})
+	require.NoError(t, err)
+	require.Equal(t, []string{"apple", "bread"}, ids(list))
+
+	list, err = products.GetProductsList(t.Context(), 1, 20, models.ProductsFilter{Tags: []string{"vegan", "gluten-free"}})
+	require.NoError(t, err)
+	require.Equal(t, []string{"apple"}, ids(list))
+
+	list, err = products.GetProductsList(t.Context(), 1, 20, models.ProductsFilter{ExcludeAllergens: []string{"gluten", "lactose"}})
+	require.NoError(t, err)
+	require.Equal(t, []string{"apple"}, ids(list))
+
+	require.Equal(t, []models.Tag{
+		{Name: "gluten-free", ProductCount: 2},
+		{Name: "vegan", ProductCount: 2},
+		{Name: "vegetarian", ProductCount: 1},
+	}, products.GetTags())
+}
+
+func TestProductsService_GetFavourites(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	favourites := service.NewMockUserService(ctrl)
+	favourites.EXPECT().GetFavourites(gomock.Any()).Return([]string{"apple", "deleted", "milk"}).AnyTimes()
+	favourites.EXPECT().IsFavourite(gomock.Any(), gomock.Any()).Return(true).AnyTimes()
+
+	products := service.NewProductsService(favourites, service.NewRecentlyViewed(service.DefaultRecentlyViewedLimit), nil, service.NewProductPopularity(nil, nil), nil, nil, nil, nil, nil, []*models.Product{
+		{ID: "apple"},
+		{ID: "bread"},
+		{ID: "milk"},
+	}, map[string][]string{}, map[string]models.Category{})
+
+	list := products.GetFavourites(t.Context(), 1, 20)
+	require.Len(t, list.Data, 2)
+	require.Equal(t, "apple", list.Data[0].ID)
+	require.Equal(t, "milk", list.Data[1].ID)
+	require.Equal(t, 1, list.TotalPages)
+
+	legacy, err := products.GetProductsList(t.Context(), 1, 20, models.ProductsFilter{Category: models.FavouriteCategory})
+	require.NoError(t, err)
+	require.Equal(t, list, legacy)
+}
+
+func TestProductsService_GetLocalizedCategories(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	products := service.NewProductsService(service.NewMockUserService(ctrl), service.NewRecentlyViewed(service.DefaultRecentlyViewedLimit), nil, service.NewProductPopularity(nil, nil), nil, nil, nil, nil, nil, []*models.Product{
+		{ID: "apple"},
+		{ID: "pear"},
+	}, map[string][]string{
+		"fruits": {"apple", "pear"},
+	}, map[string]models.Category{
+		"fruits": {ID: "fruits", Name: "Фрукты"},
+		"drinks": {ID: "drinks", Name: "Напитки"},
+	})
+
+	require.Equal(t, []models.Category{
+		{ID: "drinks", Name: "Напитки", ProductCount: 0},
+		{ID: "fruits", Name: "Фрукты", ProductCount: 2},
+	}, products.GetLocalizedCategories(t.Context(), true))
+
+	require.Equal(t, []models.Category{
+		{ID: "fruits", Name: "Фрукты", ProductCount: 2},
+	}, products.GetLocalizedCategories(t.Context(), false))
+}
+
+func TestProductsService_Popularity(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	favourites := service.NewMockUserService(ctrl)
+	favourites.EXPECT().IsFavourite(gomock.Any(), gomock.Any()).Return(false).AnyTimes()
+
+	catalog := []*models.Product{
+		{ID: "apple", Available: true},
+		{ID: "bread", Available: true},
+		{ID: "milk", Available: false},
+		{ID: "pear", Available: true},
+	}
+	popularity := service.NewProductPopularity(catalog, map[string][]*models.Order{
+		"user-1": {{Items: []models.OrderItem{{ID: "milk", Quantity: 3}, {ID: "bread", Quantity: 1}}}},
+	})
+	products := service.NewProductsService(
+		favourites, service.NewRecentlyViewed(service.DefaultRecentlyViewedLimit), nil, popularity, nil, nil, nil, nil, nil,
+		catalog, map[string][]string{}, map[string]models.Category{},
+	)
+
+	ctx := models.ContextWithUser(t.Context(), "user-1")
+	for range 3 {
+		_, err := products.ViewProduct(ctx, "pear")
+		require.NoError(t, err)
+	}
+
+	list, err := products.GetProductsList(ctx, 1, 20, models.ProductsFilter{Sort: models.ProductSortPopularity})
+	require.NoError(t, err)
+
+	ids := make([]string, 0, len(list.Data))
+	for _, preview := range list.Data {
+		ids = append(ids, preview.ID)
+	}
+
+	// Заказ весит больше трех просмотров, при равной популярности товары идут по идентификатору
+	require.Equal(t, []string{"bread", "milk", "pear", "apple"}, ids)
+
+	popular, err := products.GetPopular(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, popular, 2)
+	require.Equal(t, "bread", popular[0].ID)
+	require.Equal(t, "pear", popular[1].ID)
+
+	_, err = products.GetProductsList(ctx, 1, 20, models.ProductsFilter{Sort: "cheapest"})
+	require.ErrorIs(t, err, models.ErrBadRequest)
+}
+
+func TestProductsService_RollbackCatalog(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	favourites := service.NewMockUserService(ctrl)
+	favourites.EXPECT().IsFavourite(gomock.Any(), gomock.Any()).Return(false).AnyTimes()
+
+	products := service.NewProductsService(favourites, service.NewRecentlyViewed(service.DefaultRecentlyViewedLimit), nil, service.NewProductPopularity(nil, nil), nil, nil, nil, nil, events.NewBus(zap.NewNop().Sugar()), []*models.Product{
+		{ID: "apple", Available: true},
+	}, map[string][]string{"fruits": {"apple"}}, map[string]models.Category{"fruits": {ID: "fruits"}})
+
+	ctx := models.ContextWithUser(t.Context(), "user-1")
+
+	require.NoError(t, products.AddReview(ctx, models.PostReviewRequest{Rating: 5, Content: "first"}, "apple"))
+	require.NoError(t, products.AddReview(ctx, models.PostReviewRequest{Rating: 1, Content: "second"}, "apple"))
+
+	versions := products.ListCatalogVersions(t.Context())
+	require.Len(t, versions, 3)
+	require.Equal(t, 3, versions[0].Version)
+	require.True(t, versions[0].Current)
+	require.Equal(t, models.CatalogChangeReview, versions[0].Change)
+
+	_, err := products.RollbackCatalog(t.Context(), 3)
+	require.ErrorIs(t, err, models.ErrBadRequest)
+
+	rollback, err := products.RollbackCatalog(t.Context(), 2)
+	require.NoError(t, err)
+	require.Equal(t, 4, rollback.Version)
+	require.Equal(t, 2, rollback.RolledBackTo)
+
+	apple, err := products.GetProductByID(t.Context(), "apple")
+	require.NoError(t, err)
+	require.Len(t, apple.Reviews, 1)
+
+	// Новый отзыв после отката не попадает в сохраненные версии
+	require.NoError(t, products.AddReview(ctx, models.PostReviewRequest{Rating: 4, Content: "third"}, "apple"))
+
+	_, err = products.RollbackCatalog(t.Context(), 3)
+	require.NoError(t, err)
+
+	apple, err = products.GetProductByID(t.Context(), "apple")
+	require.NoError(t, err)
+	require.Equal(t, []string{"first", "second"}, []string{apple.Reviews[0].Content, apple.Reviews[1].Content})
+
+	list, err := products.GetProductsList(t.Context(), 1, 10, models.ProductsFilter{Category: "fruits"})
+	require.NoError(t, err)
+	require.Len(t, list.Data, 1)
+}
+
+func TestProductsService_GetCatalogChanges(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	favourites := service.NewMockUserService(ctrl)
+	favourites.EXPECT().IsFavourite(gomock.Any(), gomock.Any()).Return(false).AnyTimes()
+
+	products := service.NewProductsService(favourites, service.NewRecentlyViewed(service.DefaultRecentlyViewedLimit), nil, service.NewProductPopularity(nil, nil), nil, nil, nil, nil, events.NewBus(zap.NewNop().Sugar()), []*models.Product{
+		{ID: "apple", Available: true},
+		{ID: "pear", Available: true},
+		{ID: "milk", Available: true},
+	}, map[string][]string{"fruits": {"apple", "pear"}, "dairy": {"milk"}}, map[string]models.Category{"fruits": {ID: "fruits"}, "dairy": {ID: "dairy"}})
+
+	ctx := models.ContextWithUser(t.Context(), "user-1")
+
+	// Без since возвращается весь каталог
+	initial := products.GetCatalogChanges(t.Context(), time.Time{})
+	require.Equal(t, 1, initial.Version)
+	require.Equal(t, []string{"apple", "milk", "pear"}, initial.Products)
+	require.Equal(t, []string{"dairy", "fruits"}, initial.Categories)
+	require.Empty(t, initial.Deleted)
+
+	require.Empty(t, products.GetCatalogChanges(t.Context(), initial.UpdatedAt).Products)
+
+	require.NoError(t, products.AddReview(ctx, models.PostReviewRequest{Rating: 5, Content: "tasty"}, "apple"))
+
+	changes := products.GetCatalogChanges(t.Context(), initial.UpdatedAt)
+	require.Equal(t, 2, changes.Version)
+	require.Equal(t, []string{"apple"}, changes.Products)
+	require.Empty(t, changes.Categories)
+
+	// Восстановленный каталог без груши: товар удален, у категории изменился состав
+	data, err := json.Marshal([]models.Product{{ID: "apple", Available: true}, {ID: "milk", Available: true}})
+	require.NoError(t, err)
+	require.NoError(t, products.RestoreBackupData(data))
+
+	changes = products.GetCatalogChanges(t.Context(), changes.UpdatedAt)
+	require.Equal(t, []string{"apple"}, changes.Products)
+	require.Equal(t, []string{"fruits"}, changes.Categories)
+	require.Equal(t, []string{"pear"}, changes.Deleted)
+}
+
+type testReviewImages map[string]string
+
+func (i testReviewImages) ResolveUpload(ref string) (string, error) {
+	resolved, ok := i[ref]
+	if !ok {
+		return "", fmt.Errorf("%w: image %s was not uploaded", models.ErrBadRequest, ref)
+	}
+
+	return resolved, nil
+}
+
+func TestProductsService_AddReviewImages(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	favourites := service.NewMockUserService(ctrl)
+	favourites.EXPECT().IsFavourite(gomock.Any(), gomock.Any()).Return(false).AnyTimes()
+
+	images := testReviewImages{"photo.jxl": "http://uploads.test/photo.jxl"}
+	products := service.NewProductsService(favourites, service.NewRecentlyViewed(service.DefaultRecentlyViewedLimit), nil, service.NewProductPopularity(nil, nil), images, nil, nil, nil, events.NewBus(zap.NewNop().Sugar()), []*models.Product{
+		{ID: "apple"},
+	}, map[string][]string{}, map[string]models.Category{})
+
+	ctx := models.ContextWithUser(t.Context(), "user-1")
+
+	err := products.AddReview(ctx, models.PostReviewRequest{
+		Rating: 5, Images: []string{"photo.jxl", "https://example.com/cat.png"},
+	}, "apple")
+	require.ErrorIs(t, err, models.ErrBadRequest)
+
+	require.NoError(t, products.AddReview(ctx, models.PostReviewRequest{Rating: 5, Images: []string{"photo.jxl"}}, "apple"))
+
+	apple, err := products.GetProductByID(t.Context(), "apple")
+	require.NoError(t, err)
+	require.Len(t, apple.Reviews, 1)
+	require.Equal(t, []string{"http://uploads.test/photo.jxl"}, apple.Reviews[0].Images)
+}
+
+func TestProductsService_GetReviewSummary(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	favourites := service.NewMockUserService(ctrl)
+	favourites.EXPECT().IsFavourite(gomock.Any(), gomock.Any()).Return(false).AnyTimes()
+
+	day := time.Date(2025, time.September, 1, 12, 0, 0, 0, time.UTC)
+	long := strings.Repeat("очень вкусно ", 30)
+
+	products := service.NewProductsService(favourites, service.NewRecentlyViewed(service.DefaultRecentlyViewedLimit), nil, service.NewProductPopularity(nil, nil), testReviewImages{}, nil, nil, nil, events.NewBus(zap.NewNop().Sugar()), []*models.Product{
+		{ID: "apple", Reviews: []models.Review{
+			{Rating: 5, Author: "ann", CreatedAt: day, Content: "Хорошие"},
+			{Rating: 5, Author: "bob", CreatedAt: day.Add(time.Hour), Content: long},
+			{Rating: 4, Author: "eve", CreatedAt: day.Add(2 * time.Hour), Content: "Нормально"},
+			{Rating: 1, Author: "max", CreatedAt: day.Add(3 * time.Hour)},
+			{Rating: 2, Author: "kim", CreatedAt: day, Content: "Мятые"},
+		}},
+		{ID: "pear"},
+	}, map[string][]string{}, map[string]models.Category{})
+
+	_, err := products.GetReviewSummary(t.Context(), "plum")
+	require.ErrorIs(t, err, models.ErrNotFound)
+
+	summary, err := products.GetReviewSummary(t.Context(), "apple")
+	require.NoError(t, err)
+	require.Equal(t, 5, summary.Count)
+	require.Equal(t, 3.4, summary.Average)
+	require.Equal(t, []models.RatingCount{
+		{Rating: 5, Count: 2}, {Rating: 4, Count: 1}, {Rating: 3, Count: 0}, {Rating: 2, Count: 1}, {Rating: 1, Count: 1},
+	}, summary.Distribution)
+
+	// Из равных оценок берется новый отзыв, отзыв без текста пропускается
+	require.Equal(t, "bob", summary.TopPositive.Author)
+	require.True(t, summary.TopPositive.Truncated)
+	require.LessOrEqual(t, utf8.RuneCountInString(summary.TopPositive.Text), 201)
+	require.Equal(t, "kim", summary.TopNegative.Author)
+
+	// Новый отзыв сбрасывает сводку
+	ctx := models.ContextWithUser(t.Context(), "user-1")
+	require.NoError(t, products.AddReview(ctx, models.PostReviewRequest{Rating: 1, Content: "Гнилые"}, "apple"))
+
+	summary, err = products.GetReviewSummary(t.Context(), "apple")
+	require.NoError(t, err)
+	require.Equal(t, 6, summary.Count)
+	require.Equal(t, "Гнилые", summary.TopNegative.Text)
+
+	empty, err := products.GetReviewSummary(t.Context(), "pear")
+	require.NoError(t, err)
+	require.Zero(t, empty.Average)
+	require.Len(t, empty.Distribution, 5)
+	require.Nil(t, empty.TopPositive)
+}
+
+func TestProductsService_GetFacets(t *testing.T) {
+	products := service.NewProductsService(nil, service.NewRecentlyViewed(service.DefaultRecentlyViewedLimit), nil, service.NewProductPopularity(nil, nil), nil, nil, nil, nil, nil, []*models.Product{
+		{ID: "apple", Price: models.Rubles(50), Weight: 100, Tags: []string{"vegan"}},
+		{ID: "bread", Price: models.Rubles(80), Weight: 400, Tags: []string{"vegan"}, Allergens: []string{"gluten"}, Discount: 10},
+		{ID: "cheese", Price: models.Rubles(300), Weight: 1200, Allergens: []string{"lactose"}, Discount: 25},
+	}, map[string][]string{"bakery": {"bread"}}, map[string]models.Category{"bakery": {ID: "bakery"}})
+
+	facets, err := products.GetFacets(t.Context(), "")
+	require.NoError(t, err)
+	require.Equal(t, 3, facets.ProductCount)
+	require.Equal(t, models.MoneyRange{Min: models.Rubles(50), Max: models.Rubles(300)}, facets.Price)
+	require.Equal(t, models.IntRange{Min: 100, Max: 1200}, facets.Weight)
+	require.Equal(t, []models.Tag{{Name: "vegan", ProductCount: 2}}, facets.Tags)
+	require.Equal(t, []string{"gluten", "lactose"}, facets.Allergens)
+	require.Equal(t, 2, facets.DiscountedCount)
+	require.Equal(t, 25, facets.MaxDiscount)
+
+	// Вес 100 г попадает в диапазон [100, 250), пустые диапазоны пропускаются
+	to250, to500 := 250, 500
+	require.Equal(t, []models.CountRange{
+		{From: 100, To: &to250, ProductCount: 1},
+		{From: 250, To: &to500, ProductCount: 1},
+		{From: 1000, ProductCount: 1},
+	}, facets.WeightRanges)
+
+	facets, err = products.GetFacets(t.Context(), "bakery")
+	require.NoError(t, err)
+	require.Equal(t, models.MoneyRange{Min: models.Rubles(80), Max: models.Rubles(80)}, facets.Price)
+
+	_, err = products.GetFacets(t.Context(), "unknown")
+	require.ErrorIs(t, err, models.ErrNotFound)
+}
+
+func TestProductsService_GetProductsListRanges(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	favourites := service.NewMockUserService(ctrl)
+	favourites.EXPECT().IsFavourite(gomock.Any(), gomock.Any()).Return(false).AnyTimes()
+
+	products := service.NewProductsService(favourites, service.NewRecentlyViewed(service.DefaultRecentlyViewedLimit), nil, service.NewProductPopularity(nil, nil), nil, nil, nil, nil, nil, []*models.Product{
+		{ID: "apple", Price: models.Rubles(50), Weight: 100, Tags: []string{"vegan"}},
+		{ID: "bread", Price: models.Rubles(80), Weight: 400, Tags: []string{"vegan"}, Discount: 10},
+		{ID: "cheese", Price: models.Rubles(300), Weight: 1200, Discount: 25},
+	}, map[string][]string{}, map[string]models.Category{})
+
+	ids := func(filter models.ProductsFilter) []string {
+		list, err := products.GetProductsList(t.Context(), 1, 20, filter)
+		require.NoError(t, err)
+
+		result := make([]string, 0, len(list.Data))
+		for _, preview := range list.Data {
+			result = append(result, preview.ID)
+		}
+
+		return result
+	}
+
+	value := func(v int) *int { return &v }
+	price := func(rubles int) *models.Money { v := models.Rubles(rubles); return &v }
+	yes, no := true, false
+
+	// Границы включительно
+	require.Equal(t, []string{"apple", "bread"}, ids(models.ProductsFilter{PriceMax: price(80)}))
+	require.Equal(t, []string{"bread", "cheese"}, ids(models.ProductsFilter{WeightMin: value(400)}))
+	require.Equal(t, []string{"bread", "cheese"}, ids(models.ProductsFilter{HasDiscount: &yes}))
+	require.Equal(t, []string{"apple"}, ids(models.ProductsFilter{HasDiscount: &no}))
+	require.Equal(t, []string{"bread"}, ids(models.ProductsFilter{Tags: []string{"vegan"}, HasDiscount: &yes}))
+
+	_, err := products.GetProductsList(t.Context(), 1, 20, models.ProductsFilter{PriceMin: price(100), PriceMax: price(50)})
+	require.ErrorIs(t, err, models.ErrBadRequest)
+}
+
+func TestProductsService_GetProductsListSort(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	favourites := service.NewMockUserService(ctrl)
+	favourites.EXPECT().IsFavourite(gomock.Any(), gomock.Any()).Return(false).AnyTimes()
+
+	products := service.NewProductsService(favourites, service.NewRecentlyViewed(service.DefaultRecentlyViewedLimit), nil, service.NewProductPopularity(nil, nil), nil, nil, nil, nil, nil, []*models.Product{
+		{ID: "pear", Price: models.Rubles(80), Rating: 4.5, Tags: []string{"vegan"}},
+		{ID: "cheese", Price: models.Rubles(300), Rating: 4.9},
+		{ID: "apple", Price: models.Rubles(80), Rating: 4.5, Tags: []string{"vegan"}},
+		{ID: "bread", Price: models.Rubles(50), Rating: 3, Tags: []string{"vegan"}},
+	}, map[string][]string{}, map[string]models.Category{})
+
+	ids := func(filter models.ProductsFilter) []string {
+		list, err := products.GetProductsList(t.Context(), 1, 20, filter)
+		require.NoError(t, err)
+
+		result := make([]string, 0, len(list.Data))
+		for _, preview := range list.Data {
+			result = append(result, preview.ID)
+		}
+
+		return result
+	}
+
+	// При равной цене и рейтинге товары идут по идентификатору
+	require.Equal(t, []string{"bread", "apple", "pear", "cheese"}, ids(models.ProductsFilter{Sort: models.ProductSortPriceAsc}))
+	require.Equal(t, []string{"cheese", "apple", "pear", "bread"}, ids(models.ProductsFilter{Sort: models.ProductSortPriceDesc}))
+	require.Equal(t, []string{"cheese", "apple", "pear", "bread"}, ids(models.ProductsFilter{Sort: models.ProductSortRating}))
+	require.Equal(t, []string{"bread", "apple", "cheese", "pear"}, ids(models.ProductsFilter{Sort: models.ProductSortNew}))
+
+	// Сортировка сочетается с фильтрами
+	require.Equal(t, []string{"apple", "pear", "bread"}, ids(models.ProductsFilter{
+		Tags: []string{"vegan"}, Sort: models.ProductSortPriceDesc,
+	}))
+}
+
+func TestProductsService_BatchFavourites(t *testing.T) {
+	favourites := service.NewFavouritesService(map[string][]string{"user-1": {"apple", "deleted"}})
+
+	products := service.NewProductsService(favourites, service.NewRecentlyViewed(service.DefaultRecentlyViewedLimit), nil, service.NewProductPopularity(nil, nil), nil, nil, nil, nil, nil, []*models.Product{
+		{ID: "apple"},
+		{ID: "bread"},
+		{ID: "milk"},
+	}, map[string][]string{}, map[string]models.Category{})
+
+	ctx := models.ContextWithUser(t.Context(), "user-1")
+
+	// Одна неверная операция отменяет весь запрос
+	_, err := products.BatchFavourites(ctx, models.FavouritesBatchRequest{Operations: []models.FavouriteOperation{
+		{ProductID: "bread", Action: models.FavouriteActionAdd},
+		{ProductID: "missing", Action: models.FavouriteActionAdd},
+	}})
+	require.ErrorIs(t, err, models.ErrNotFound)
+
+	_, err = products.BatchFavourites(ctx, models.FavouritesBatchRequest{Operations: []models.FavouriteOperation{
+		{ProductID: "bread", Action: "toggle"},
+	}})
+	require.ErrorIs(t, err, models.ErrBadRequest)
+
+	_, err = products.BatchFavourites(ctx, models.FavouritesBatchRequest{})
+	require.ErrorIs(t, err, models.ErrBadRequest)
+
+	require.Equal(t, []string{"apple", "deleted"}, favourites.GetFavourites(ctx))
+
+	// Операции применяются по порядку, удаленный из каталога товар можно убрать
+	result, err := products.BatchFavourites(ctx, models.FavouritesBatchRequest{Operations: []models.FavouriteOperation{
+		{ProductID: "bread", Action: models.FavouriteActionAdd},
+		{ProductID: "deleted", Action: models.FavouriteActionRemove},
+		{ProductID: "milk", Action: models.FavouriteActionAdd},
+		{ProductID: "milk", Action: models.FavouriteActionRemove},
+	}})
+	require.NoError(t, err)
+	require.Equal(t, []string{"apple", "bread"}, result.Favourites)
+	require.Equal(t, result.Favourites, favourites.GetFavourites(ctx))
+}
+
+func TestProductsService_ReviewAuthors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	favourites := service.NewMockUserService(ctrl)
+	favourites.EXPECT().IsFavourite(gomock.Any(), gomock.Any()).Return(false).AnyTimes()
+
+	users := service.NewUserData(map[string]*models.UserProfile{
+		"user-1": {Name: "Анна", Image: "https://cdn.example.com/ann.jxl"},
+		"user-2": {},
+		"user-3": {Name: "Борис", Image: "https://cdn.example.com/bob.jxl"},
+	}, nil, nil)
+
+	day := time.Date(2025, time.September, 1, 12, 0, 0, 0, time.UTC)
+
+	products := service.NewProductsService(favourites, service.NewRecentlyViewed(service.DefaultRecentlyViewedLimit), nil, service.NewProductPopularity(nil, nil), testReviewImages{}, nil, users, nil, events.NewBus(zap.NewNop().Sugar()), []*models.Product{
+		{ID: "apple", Reviews: []models.Review{
+			{Rating: 5, Author: "ann", UserID: "user-1", CreatedAt: day, Content: "Хорошие"},
+			{Rating: 4, Author: "guest", CreatedAt: day, Content: "Нормально"},
+			{Rating: 2, Author: "bob", UserID: "user-3", CreatedAt: day, Content: "Мятые"},
+		}},
+	}, map[string][]string{}, map[string]models.Category{})
+
+	// Новый отзыв привязан к пользователю, без имени в профиле остается никнейм
+	require.NoError(t, products.AddReview(models.ContextWithUser(t.Context(), "user-2"), models.PostReviewRequest{Rating: 3}, "apple"))
+	require.NoError(t, users.DeleteProfile(models.ContextWithUser(t.Context(), "user-3")))
+
+	product, err := products.GetProductByID(t.Context(), "apple")
+	require.NoError(t, err)
+	require.Len(t, product.Reviews, 4)
+
+	require.Equal(t, "Анна", product.Reviews[0].Author)
+	require.Equal(t, "user-1", product.Reviews[0].UserID)
+	require.Equal(t, "https://cdn.example.com/ann.jxl", product.Reviews[0].AuthorImage)
+
+	require.Equal(t, "guest", product.Reviews[1].Author)
+	require.Empty(t, product.Reviews[1].AuthorImage)
+
+	require.Equal(t, models.Review{Rating: 2, CreatedAt: day, Content: "Мятые", AuthorDeleted: true}, product.Reviews[2])
+
+	require.Equal(t, "user-2", product.Reviews[3].UserID)
+	require.Empty(t, product.Reviews[3].AuthorImage)
+
+	summary, err := products.GetReviewSummary(t.Context(), "apple")
+	require.NoError(t, err)
+	require.Equal(t, "Анна", summary.TopPositive.Author)
+	require.True(t, summary.TopNegative.AuthorDeleted)
+	require.Empty(t, summary.TopNegative.Author)
+
+	// Имя меняется в отзывах сразу, в каталоге остается никнейм на момент отзыва
+	ctx := models.ContextWithUser(t.Context(), "user-3")
+	require.NoError(t, users.UpdateProfile(ctx, models.UpdateUserRequest{Name: "Борис Петров"}))
+
+	product, err = products.GetProductByID(t.Context(), "apple")
+	require.NoError(t, err)
+	require.Equal(t, "Борис Петров", product.Reviews[2].Author)
+	require.Equal(t, "user-3", product.Reviews[2].UserID)
+	require.Equal(t, "bob", products.GetAllProducts()[0].Reviews[2].Author)
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"fmt"
+	"maps"
+	"math"
+
+	"eats-backend/internal/models"
+)
+
+// RatesProvider выдает курс обмена между валютами.
+type RatesProvider interface {
+	Rate(from, to models.Currency) (models.ExchangeRate, error)
+}
+
+// StaticRates курсы из конфигурации, для разработки и учебного стенда: сколько рублей стоит
+// единица каждой валюты. Кросс-курсы считаются через рубль.
+type StaticRates struct {
+	rubles map[models.Currency]float64
+}
+
+func NewStaticRates(rubles map[models.Currency]float64) *StaticRates {
+	table := maps.Clone(rubles)
+	if table == nil {
+		table = make(map[models.Currency]float64)
+	}
+
+	table[models.CurrencyRUB] = 1
+
+	return &StaticRates{rubles: table}
+}
+
+func (r *StaticRates) Rate(from, to models.Currency) (models.ExchangeRate, error) {
+	fromRubles, ok := r.rubles[from]
+	if !ok || fromRubles <= 0 {
+		return models.ExchangeRate{}, fmt.Errorf("%w: no exchange rate for %s", models.ErrBadRequest, from)
+	}
+
+	toRubles, ok := r.rubles[to]
+	if !ok || toRubles <= 0 {
+		return models.ExchangeRate{}, fmt.Errorf("%w: no exchange rate for %s", models.ErrBadRequest, to)
+	}
+
+	// Клиенту показывается тот же курс, по которому считается сумма, поэтому он округляется заранее
+	rate := math.Round(fromRubles/toRubles*1e6) / 1e6
+
+	return models.ExchangeRate{From: from, To: to, Rate: rate}, nil
+}
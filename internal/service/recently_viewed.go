@@ -0,0 +1,59 @@
+package service
+
+import (
+	"slices"
+	"sync"
+)
+
+const DefaultRecentlyViewedLimit = 20
+
+// RecentlyViewed хранит последние просмотренные пользователем товары.
+// Для каждого пользователя держится не больше limit товаров, повторный просмотр поднимает товар наверх.
+type RecentlyViewed struct {
+	views map[string][]string // userID -> productIDs, от старых к новым
+	limit int
+
+	mux sync.Mutex
+}
+
+func NewRecentlyViewed(limit int) *RecentlyViewed {
+	return &RecentlyViewed{
+		views: make(map[string][]string),
+		limit: limit,
+	}
+}
+
+func (s *RecentlyViewed) RecordView(userID, productID string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	views := slices.DeleteFunc(s.views[userID], func(id string) bool {
+		return id == productID
+	})
+
+	views = append(views, productID)
+	if len(views) > s.limit {
+		views = slices.Delete(views, 0, len(views)-s.limit)
+	}
+
+	s.views[userID] = views
+}
+
+// Recent возвращает просмотренные товары, начиная с самого свежего
+func (s *RecentlyViewed) Recent(userID string) []string {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	result := slices.Clone(s.views[userID])
+	slices.Reverse(result)
+
+	return result
+}
+
+// ResetUser очищает историю просмотров пользователя
+func (s *RecentlyViewed) ResetUser(userID string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	delete(s.views, userID)
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"eats-backend/internal/models"
+)
+
+// recordingBuffer кольцевой буфер запросов одного студента
+type recordingBuffer struct {
+	nickname  string
+	exchanges []models.RecordedExchange
+	// Куда запишется следующий запрос, когда буфер заполнен
+	next int
+}
+
+// ordered возвращает запросы от старых к новым
+func (b *recordingBuffer) ordered() []models.RecordedExchange {
+	result := make([]models.RecordedExchange, 0, len(b.exchanges))
+	result = append(result, b.exchanges[b.next:]...)
+	result = append(result, b.exchanges[:b.next]...)
+
+	return result
+}
+
+// RecordingService хранит последние запросы каждого студента, чтобы преподаватель мог проверить,
+// как студент работает с API. Записи живут только в памяти и пропадают при рестарте.
+type RecordingService struct {
+	maxEntries int
+	users      map[string]*recordingBuffer
+
+	mux sync.RWMutex
+}
+
+func NewRecordingService(maxEntries int) *RecordingService {
+	return &RecordingService{
+		maxEntries: maxEntries,
+		users:      make(map[string]*recordingBuffer),
+	}
+}
+
+// Record добавляет запрос в запись студента, вытесняя самый старый, если буфер заполнен
+func (s *RecordingService) Record(userID, nickname string, exchange models.RecordedExchange) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	buffer, ok := s.users[userID]
+	if !ok {
+		buffer = &recordingBuffer{}
+		s.users[userID] = buffer
+	}
+
+	buffer.nickname = nickname
+
+	if len(buffer.exchanges) < s.maxEntries {
+		buffer.exchanges = append(buffer.exchanges, exchange)
+
+		return
+	}
+
+	buffer.exchanges[buffer.next] = exchange
+	buffer.next = (buffer.next + 1) % s.maxEntries
+}
+
+// ListSessions возвращает студентов с записанными запросами, недавно активные первыми
+func (s *RecordingService) ListSessions(_ context.Context) []models.RecordingSummary {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	result := make([]models.RecordingSummary, 0, len(s.users))
+	for userID, buffer := range s.users {
+		exchanges := buffer.ordered()
+
+		result = append(result, models.RecordingSummary{
+			UserID:   userID,
+			Nickname: buffer.nickname,
+			Count:    len(exchanges),
+			LastAt:   exchanges[len(exchanges)-1].At,
+		})
+	}
+
+	slices.SortFunc(result, func(a, b models.RecordingSummary) int {
+		return cmp.Or(b.LastAt.Compare(a.LastAt), cmp.Compare(a.UserID, b.UserID))
+	})
+
+	return result
+}
+
+func (s *RecordingService) GetSession(_ context.Context, userID string) (models.RecordingSession, error) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	buffer, ok := s.users[userID]
+	if !ok {
+		return models.RecordingSession{}, fmt.Errorf("%w: no recorded requests for user %s", models.ErrNotFound, userID)
+	}
+
+	return models.RecordingSession{
+		UserID:    userID,
+		Nickname:  buffer.nickname,
+		Exchanges: buffer.ordered(),
+	}, nil
+}
+
+// ClearSession удаляет запись студента, например перед началом новой проверки
+func (s *RecordingService) ClearSession(_ context.Context, userID string) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if _, ok := s.users[userID]; !ok {
+		return fmt.Errorf("%w: no recorded requests for user %s", models.ErrNotFound, userID)
+	}
+
+	delete(s.users, userID)
+
+	return nil
+}
+
+func (s *RecordingService) CollectionSizes(_ context.Context) map[string]int {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	exchanges := 0
+	for _, buffer := range s.users {
+		exchanges += len(buffer.exchanges)
+	}
+
+	return map[string]int{
+		"recorded_users":     len(s.users),
+		"recorded_exchanges": exchanges,
+	}
+}
@@ -0,0 +1,43 @@
+package service_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"eats-backend/internal/models"
+	"eats-backend/internal/service"
+)
+
+func TestRecordingService_RingBuffer(t *testing.T) {
+	recordings := service.NewRecordingService(3)
+	start := time.Now()
+
+	for i := range 5 {
+		recordings.Record("user-1", "student", models.RecordedExchange{
+			Path: "/orders/" + string(rune('a'+i)),
+			At:   start.Add(time.Duration(i) * time.Second),
+		})
+	}
+
+	recordings.Record("user-2", "other", models.RecordedExchange{Path: "/cart", At: start})
+
+	// В буфере остаются три последних запроса, от старых к новым
+	session, err := recordings.GetSession(t.Context(), "user-1")
+	require.NoError(t, err)
+	require.Equal(t, "student", session.Nickname)
+	require.Len(t, session.Exchanges, 3)
+	require.Equal(t, "/orders/c", session.Exchanges[0].Path)
+	require.Equal(t, "/orders/e", session.Exchanges[2].Path)
+
+	summaries := recordings.ListSessions(t.Context())
+	require.Len(t, summaries, 2)
+	require.Equal(t, "user-1", summaries[0].UserID)
+	require.Equal(t, start.Add(4*time.Second), summaries[0].LastAt)
+
+	require.NoError(t, recordings.ClearSession(t.Context(), "user-1"))
+
+	_, err = recordings.GetSession(t.Context(), "user-1")
+	require.ErrorIs(t, err, models.ErrNotFound)
+}
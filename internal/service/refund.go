@@ -0,0 +1,245 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"eats-backend/internal/models"
+)
+
+type RefundOrders interface {
+	FindOrder(ctx context.Context, id string) (string, models.Order, error)
+	AddRefund(userID, orderID string, refund models.Refund) (models.Order, error)
+}
+
+type RefundWallet interface {
+	CreditRefund(userID, orderID string, amount models.Money, refundID string) error
+}
+
+type RefundNotifier interface {
+	OrderRefunded(ctx context.Context, userID string, order models.Order, refund models.Refund)
+}
+
+// RefundService возвращает деньги за заказ целиком или за отдельные позиции. Деньги зачисляются
+// в кошелек независимо от способа оплаты заказа.
+type RefundService struct {
+	orders   RefundOrders
+	wallet   RefundWallet
+	notifier RefundNotifier
+	clock    Clock
+	logger   *zap.SugaredLogger
+
+	// Возвраты выполняются по одному, иначе два параллельных возврата могут вернуть одну позицию дважды.
+	mux sync.Mutex
+}
+
+func NewRefundService(
+	orders RefundOrders,
+	wallet RefundWallet,
+	notifier RefundNotifier,
+	clock Clock,
+	logger *zap.SugaredLogger,
+) *RefundService {
+	return &RefundService{
+		orders:   orders,
+		wallet:   wallet,
+		notifier: notifier,
+		clock:    clockOrSystem(clock),
+		logger:   logger,
+	}
+}
+
+// RefundOrder возвращает позиции заказа из запроса или, если позиций нет, все, что еще не возвращено.
+// За позиции возвращается заплаченное с учетом скидок, доставка и все остальное - вместе с последними
+// позициями заказа. Всего возвращается не больше TotalPrice.
+func (s *RefundService) RefundOrder(ctx context.Context, orderID string, req models.RefundRequest) (models.Order, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	userID, order, err := s.orders.FindOrder(ctx, orderID)
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	if fullyRefunded(order) {
+		return models.Order{}, fmt.Errorf("%w: order is already refunded", models.ErrBadRequest)
+	}
+
+	items, err := refundItems(order, req.Items)
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	refund := models.Refund{
+		ID:        uuid.NewString(),
+		Items:     items,
+		Reason:    strings.TrimSpace(req.Reason),
+		CreatedAt: s.clock.Now(),
+	}
+
+	// Больше, чем заплачено за заказ, вернуть нельзя
+	left := order.TotalPrice
+	if order.Refund != nil {
+		left -= order.Refund.Amount
+	}
+
+	withRefund := order
+	withRefund.Refund = &models.OrderRefund{Refunds: []models.Refund{refund}}
+	if order.Refund != nil {
+		withRefund.Refund.Refunds = slices.Concat(order.Refund.Refunds, withRefund.Refund.Refunds)
+	}
+
+	if fullyRefunded(withRefund) {
+		// Последний возврат забирает остаток: доставку, опции, чаевые и копейки округления
+		refund.Amount = left
+	} else {
+		refund.Amount = min(refundAmount(order, items), left)
+	}
+
+	refund.Amount = max(refund.Amount, 0)
+
+	if err := ctx.Err(); err != nil {
+		return models.Order{}, fmt.Errorf("refund: %w", err)
+	}
+
+	if err := s.wallet.CreditRefund(userID, order.ID, refund.Amount, refund.ID); err != nil {
+		return models.Order{}, fmt.Errorf("credit refund: %w", err)
+	}
+
+	updated, err := s.orders.AddRefund(userID, order.ID, refund)
+	if err != nil {
+		// Деньги уже зачислены, заказ мог пропасть при сбросе данных пользователя или не записаться в хранилище
+		s.logger.Errorw("Refund credited but not saved", "orderId", order.ID, "refundId", refund.ID, "error", err)
+
+		return models.Order{}, fmt.Errorf("save refund: %w", err)
+	}
+
+	s.notifier.OrderRefunded(ctx, userID, updated, refund)
+
+	s.logger.Infow("Order refunded", "orderId", order.ID, "userId", userID, "amount", refund.Amount)
+
+	return updated, nil
+}
+
+// refundItems проверяет позиции возврата по заказу. Пустой список означает возврат всех оставшихся позиций.
+func refundItems(order models.Order, requested []models.RefundItem) ([]models.RefundItem, error) {
+	refunded := refundedQuantities(order)
+
+	if len(requested) == 0 {
+		items := make([]models.RefundItem, 0, len(order.Items))
+		for _, item := range order.Items {
+			if left := item.Quantity - refunded[item.ID]; left > 0 {
+				items = append(items, models.RefundItem{ID: item.ID, Quantity: left})
+			}
+		}
+
+		return items, nil
+	}
+
+	quantities := make(map[string]int, len(order.Items))
+	for _, item := range order.Items {
+		quantities[item.ID] += item.Quantity
+	}
+
+	seen := make(map[string]struct{}, len(requested))
+
+	for _, item := range requested {
+		if _, ok := quantities[item.ID]; !ok {
+			return nil, fmt.Errorf("%w: product %s is not in the order", models.ErrBadRequest, item.ID)
+		}
+
+		if _, ok := seen[item.ID]; ok {
+			return nil, fmt.Errorf("%w: product %s is listed twice", models.ErrBadRequest, item.ID)
+		}
+
+		seen[item.ID] = struct{}{}
+
+		if item.Quantity <= 0 {
+			return nil, fmt.Errorf("%w: quantity must be positive", models.ErrBadRequest)
+		}
+
+		if left := quantities[item.ID] - refunded[item.ID]; item.Quantity > left {
+			return nil, fmt.Errorf("%w: only %d of product %s can be refunded", models.ErrBadRequest, left, item.ID)
+		}
+	}
+
+	return requested, nil
+}
+
+// refundAmount сколько заплачено за позиции items. Скидки заказа делятся между строками пропорционально
+// их стоимости. Единицы товара берутся по строкам заказа по порядку, начиная с еще не возвращенных.
+func refundAmount(order models.Order, items []models.RefundItem) models.Money {
+	refunded := refundedQuantities(order)
+
+	requested := make(map[string]int, len(items))
+	for _, item := range items {
+		requested[item.ID] += item.Quantity
+	}
+
+	var goods, gross, discounts models.Money
+
+	for _, line := range order.Items {
+		goods += line.Price.Mul(line.Quantity)
+
+		skip := min(refunded[line.ID], line.Quantity)
+		refunded[line.ID] -= skip
+
+		take := min(requested[line.ID], line.Quantity-skip)
+		requested[line.ID] -= take
+
+		gross += line.Price.Mul(take)
+	}
+
+	for _, discount := range order.Discounts {
+		discounts += discount.Amount
+	}
+
+	if goods <= 0 || discounts <= 0 {
+		return gross
+	}
+
+	paid := max(goods-discounts, 0)
+
+	return gross * paid / goods
+}
+
+// refundedQuantities возвращает, сколько единиц каждого товара заказа уже возвращено
+func refundedQuantities(order models.Order) map[string]int {
+	result := make(map[string]int)
+	if order.Refund == nil {
+		return result
+	}
+
+	for _, refund := range order.Refund.Refunds {
+		for _, item := range refund.Items {
+			result[item.ID] += item.Quantity
+		}
+	}
+
+	return result
+}
+
+func fullyRefunded(order models.Order) bool {
+	if order.Refund == nil {
+		return false
+	}
+
+	refunded := refundedQuantities(order)
+	for _, item := range order.Items {
+		refunded[item.ID] -= item.Quantity
+	}
+
+	for _, left := range refunded {
+		if left < 0 {
+			return false
+		}
+	}
+
+	return true
+}
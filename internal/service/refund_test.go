@@ -0,0 +1,174 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"eats-backend/internal/metrics"
+	"eats-backend/internal/models"
+	"eats-backend/internal/service"
+)
+
+type testRefundWallet struct {
+	credited models.Money
+}
+
+func (w *testRefundWallet) CreditRefund(_, _ string, amount models.Money, _ string) error {
+	w.credited += amount
+
+	return nil
+}
+
+type testRefundNotifier struct {
+	refunds []models.Refund
+}
+
+func (n *testRefundNotifier) OrderRefunded(_ context.Context, _ string, _ models.Order, refund models.Refund) {
+	n.refunds = append(n.refunds, refund)
+}
+
+func TestRefundService_PartialRefunds(t *testing.T) {
+	orders := service.NewOrderService(nil, nil, nil, nil, nil, nil, nil, nil, nil, zap.NewNop().Sugar(), map[string][]*models.Order{
+		"user-1": {{
+			ID:            "order-1",
+			Status:        models.OrderStatusCompleted,
+			OrderPrice:    models.Rubles(500),
+			DeliveryPrice: models.Rubles(100),
+			TotalPrice:    models.Rubles(600),
+			Items: []models.OrderItem{
+				{ID: "milk", Price: models.Rubles(100), Quantity: 3},
+				{ID: "bread", Price: models.Rubles(200), Quantity: 1},
+			},
+		}},
+	})
+	wallet := &testRefundWallet{}
+	notifier := &testRefundNotifier{}
+	refunds := service.NewRefundService(orders, wallet, notifier, nil, zap.NewNop().Sugar())
+	cancelled := metrics.OrdersCancelled.Value()
+
+	_, err := refunds.RefundOrder(t.Context(), "order-1", models.RefundRequest{
+		Items: []models.RefundItem{{ID: "milk", Quantity: 4}},
+	})
+	require.ErrorIs(t, err, models.ErrBadRequest)
+
+	order, err := refunds.RefundOrder(t.Context(), "order-1", models.RefundRequest{
+		Items: []models.RefundItem{{ID: "milk", Quantity: 2}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, models.OrderStatusCompleted, order.Status)
+	require.Equal(t, models.RefundStatusPartial, order.Refund.Status)
+	require.Equal(t, models.Rubles(200), order.Refund.Amount)
+
+	// Остаток возвращается вместе с доставкой
+	order, err = refunds.RefundOrder(t.Context(), "order-1", models.RefundRequest{})
+	require.NoError(t, err)
+	require.Equal(t, models.OrderStatusRefunded, order.Status)
+	require.Equal(t, models.RefundStatusFull, order.Refund.Status)
+	require.Equal(t, models.Rubles(600), order.Refund.Amount)
+	require.Equal(t, []models.RefundItem{{ID: "milk", Quantity: 1}, {ID: "bread", Quantity: 1}}, order.Refund.Refunds[1].Items)
+
+	_, err = refunds.RefundOrder(t.Context(), "order-1", models.RefundRequest{})
+	require.ErrorIs(t, err, models.ErrBadRequest)
+
+	require.Equal(t, models.Rubles(600), wallet.credited)
+	require.Len(t, notifier.refunds, 2)
+	// Частичный возврат заказ не отменяет, полный считается один раз
+	require.Equal(t, cancelled+1, metrics.OrdersCancelled.Value())
+
+	stored, err := orders.GetOrder(models.ContextWithUser(t.Context(), "user-1"), "order-1")
+	require.NoError(t, err)
+	require.Equal(t, order, stored)
+}
+
+func TestRefundService_DiscountedOrder(t *testing.T) {
+	orders := service.NewOrderService(nil, nil, nil, nil, nil, nil, nil, nil, nil, zap.NewNop().Sugar(), map[string][]*models.Order{
+		"user-1": {{
+			ID:            "order-1",
+			Status:        models.OrderStatusCompleted,
+			OrderPrice:    models.Rubles(600),
+			DeliveryPrice: models.Rubles(100),
+			Tip:           models.Rubles(20),
+			TotalPrice:    models.Rubles(600),
+			Items: []models.OrderItem{
+				{ID: "bread", Price: models.Rubles(100), Quantity: 2},
+				{ID: "bread", Price: models.Rubles(100), Quantity: 1, ComboID: "breakfast"},
+				{ID: "cake", Price: models.Rubles(300), Quantity: 1},
+			},
+			Discounts: []models.OrderDiscount{
+				{Type: models.DiscountTypeCombo, Code: "breakfast", Amount: models.Rubles(50)},
+				{Type: models.DiscountTypePromoCode, Code: "SALE", Amount: models.Rubles(70)},
+			},
+		}},
+	})
+	wallet := &testRefundWallet{}
+	refunds := service.NewRefundService(orders, wallet, &testRefundNotifier{}, nil, zap.NewNop().Sugar())
+
+	// Скидки 120 из 600 за товары делятся пропорционально: за 100 возвращается 80
+	order, err := refunds.RefundOrder(t.Context(), "order-1", models.RefundRequest{
+		Items: []models.RefundItem{{ID: "bread", Quantity: 2}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, models.Rubles(160), order.Refund.Amount)
+
+	// Хлеб из набора - отдельная строка заказа
+	order, err = refunds.RefundOrder(t.Context(), "order-1", models.RefundRequest{
+		Items: []models.RefundItem{{ID: "bread", Quantity: 1}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, models.Rubles(80), order.Refund.Refunds[1].Amount)
+
+	// Остаток с доставкой и чаевыми, всего не больше оплаченного
+	order, err = refunds.RefundOrder(t.Context(), "order-1", models.RefundRequest{})
+	require.NoError(t, err)
+	require.Equal(t, models.Rubles(360), order.Refund.Refunds[2].Amount)
+	require.Equal(t, models.Rubles(600), order.Refund.Amount)
+	require.Equal(t, models.Rubles(600), wallet.credited)
+}
+
+func TestOrderService_GetOrdersDuringRefund(t *testing.T) {
+	orders := service.NewOrderService(nil, nil, nil, nil, nil, nil, nil, nil, nil, zap.NewNop().Sugar(), map[string][]*models.Order{
+		"user-1": {{
+			ID:         "order-1",
+			Status:     models.OrderStatusCompleted,
+			OrderPrice: models.Rubles(20000),
+			TotalPrice: models.Rubles(20000),
+			Items:      []models.OrderItem{{ID: "milk", Price: models.Rubles(100), Quantity: 200}},
+		}},
+	})
+	refunds := service.NewRefundService(orders, &testRefundWallet{}, &testRefundNotifier{}, nil, zap.NewNop().Sugar())
+	ctx := models.ContextWithUser(t.Context(), "user-1")
+
+	var wg sync.WaitGroup
+
+	// Под -race чтение заказов не должно пересекаться с изменением заказа возвратом
+	wg.Go(func() {
+		for range 200 {
+			_, err := refunds.RefundOrder(t.Context(), "order-1", models.RefundRequest{
+				Items: []models.RefundItem{{ID: "milk", Quantity: 1}},
+			})
+			require.NoError(t, err)
+		}
+	})
+
+	wg.Go(func() {
+		for range 200 {
+			placed, err := orders.GetOrders(ctx)
+			require.NoError(t, err)
+
+			_, err = json.Marshal(placed)
+			require.NoError(t, err)
+		}
+	})
+
+	wg.Wait()
+
+	placed, err := orders.GetOrders(ctx)
+	require.NoError(t, err)
+	require.Equal(t, models.RefundStatusFull, placed[0].Refund.Status)
+	require.Len(t, placed[0].Refund.Refunds, 200)
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"eats-backend/internal/models"
+)
+
+// Resettable интерфейс для сервисов, хранящих данные пользователя, которые можно сбросить
+type Resettable interface {
+	ResetUser(userID string)
+}
+
+// ResetService сбрасывает данные отдельного студента во всех сервисах без перезапуска сервера
+type ResetService struct {
+	logger      *zap.SugaredLogger
+	resettables []Resettable
+
+	mu sync.RWMutex
+}
+
+func NewResetService(logger *zap.SugaredLogger) *ResetService {
+	return &ResetService{
+		logger:      logger,
+		resettables: make([]Resettable, 0),
+	}
+}
+
+// RegisterResettable регистрирует сервис для сброса
+func (s *ResetService) RegisterResettable(resettable Resettable) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.resettables = append(s.resettables, resettable)
+}
+
+func (s *ResetService) ResetUser(ctx context.Context, userID string) error {
+	userID = strings.TrimSpace(userID)
+	if userID == "" {
+		return fmt.Errorf("%w: empty user id", models.ErrBadRequest)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, resettable := range s.resettables {
+		resettable.ResetUser(userID)
+	}
+
+	s.logger.With("module", "reset").Infof(
+		"User %s reset to seed state by %s",
+		userID,
+		models.ClaimsFromContext(ctx).Nickname,
+	)
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"eats-backend/internal/models"
+)
+
+const reviewRateWindow = 24 * time.Hour
+
+// ReviewRules ограничения отзывов. Нулевое значение отключает ограничение.
+type ReviewRules struct {
+	MaxImages int
+	// Длина текста в символах, без пробелов по краям.
+	MinLength int
+	MaxLength int
+	// Сколько отзывов пользователь может оставить за последние 24 часа.
+	MaxPerDay int
+	// Слова, с которыми отзыв не публикуется. Сравниваются целые слова без учета регистра.
+	BannedWords []string
+}
+
+// ReviewModerator проверяет отзывы перед публикацией и ограничивает их частоту
+type ReviewModerator struct {
+	rules  ReviewRules
+	banned map[string]struct{}
+
+	// userID -> время отзывов за последние сутки, старые первыми
+	posted map[string][]time.Time
+
+	mux sync.Mutex
+}
+
+func NewReviewModerator(rules ReviewRules) *ReviewModerator {
+	banned := make(map[string]struct{}, len(rules.BannedWords))
+	for _, word := range rules.BannedWords {
+		if word = strings.ToLower(strings.TrimSpace(word)); word != "" {
+			banned[word] = struct{}{}
+		}
+	}
+
+	return &ReviewModerator{
+		rules:  rules,
+		banned: banned,
+		posted: make(map[string][]time.Time),
+	}
+}
+
+// ValidateReview проверяет картинки и текст отзыва. Все нарушения возвращаются одной *models.ValidationError.
+func (m *ReviewModerator) ValidateReview(review models.PostReviewRequest) error {
+	var fields []models.FieldError
+
+	if m.rules.MaxImages > 0 && len(review.Images) > m.rules.MaxImages {
+		fields = append(fields, models.FieldError{
+			Field:   "images",
+			Code:    "too_many",
+			Message: fmt.Sprintf("no more than %d images allowed", m.rules.MaxImages),
+		})
+	}
+
+	length := utf8.RuneCountInString(strings.TrimSpace(review.Content))
+
+	switch {
+	case m.rules.MinLength > 0 && length < m.rules.MinLength:
+		fields = append(fields, models.FieldError{
+			Field:   "content",
+			Code:    "too_short",
+			Message: fmt.Sprintf("should be at least %d characters", m.rules.MinLength),
+		})
+	case m.rules.MaxLength > 0 && length > m.rules.MaxLength:
+		fields = append(fields, models.FieldError{
+			Field:   "content",
+			Code:    "too_long",
+			Message: fmt.Sprintf("should be at most %d characters", m.rules.MaxLength),
+		})
+	}
+
+	if m.containsBannedWord(review.Content) {
+		fields = append(fields, models.FieldError{
+			Field:   "content",
+			Code:    "profanity",
+			Message: "contains inappropriate words",
+		})
+	}
+
+	if len(fields) > 0 {
+		return &models.ValidationError{Fields: fields}
+	}
+
+	return nil
+}
+
+func (m *ReviewModerator) containsBannedWord(content string) bool {
+	if len(m.banned) == 0 {
+		return false
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(content), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	for _, word := range words {
+		if _, ok := m.banned[word]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RecordReview учитывает новый отзыв пользователя. Если лимит за сутки исчерпан, отзыв не учитывается
+// и возвращается *models.ReviewRateLimitError со временем до освобождения места.
+func (m *ReviewModerator) RecordReview(userID string, now time.Time) error {
+	if m.rules.MaxPerDay <= 0 {
+		return nil
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	posted := m.posted[userID]
+
+	// Отзывы старше суток больше не учитываются
+	fresh := 0
+	for fresh < len(posted) && !posted[fresh].After(now.Add(-reviewRateWindow)) {
+		fresh++
+	}
+
+	posted = posted[fresh:]
+
+	if len(posted) >= m.rules.MaxPerDay {
+		m.posted[userID] = posted
+
+		return &models.ReviewRateLimitError{
+			Limit:      m.rules.MaxPerDay,
+			RetryAfter: posted[0].Add(reviewRateWindow).Sub(now),
+		}
+	}
+
+	m.posted[userID] = append(posted, now)
+
+	return nil
+}
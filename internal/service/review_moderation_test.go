@@ -0,0 +1,61 @@
+package service_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"eats-backend/internal/models"
+	"eats-backend/internal/service"
+)
+
+func TestReviewModerator_ValidateReview(t *testing.T) {
+	moderator := service.NewReviewModerator(service.ReviewRules{
+		MaxImages:   2,
+		MaxLength:   20,
+		BannedWords: []string{"Редиска"},
+	})
+
+	require.NoError(t, moderator.ValidateReview(models.PostReviewRequest{Content: "Вкусно, беру еще"}))
+
+	// Все нарушения приходят сразу, запрещенное слово ищется без учета регистра
+	err := moderator.ValidateReview(models.PostReviewRequest{
+		Content: "Продавец - редиска! " + strings.Repeat("а", 20),
+		Images:  []string{"1.jxl", "2.jxl", "3.jxl"},
+	})
+
+	var validation *models.ValidationError
+	require.ErrorAs(t, err, &validation)
+	require.ErrorIs(t, err, models.ErrBadRequest)
+
+	codes := make([]string, 0, len(validation.Fields))
+	for _, field := range validation.Fields {
+		codes = append(codes, field.Field+":"+field.Code)
+	}
+
+	require.Equal(t, []string{"images:too_many", "content:too_long", "content:profanity"}, codes)
+
+	// Слово внутри другого слова не считается
+	require.NoError(t, moderator.ValidateReview(models.PostReviewRequest{Content: "редискалюбитель"}))
+}
+
+func TestReviewModerator_RecordReview(t *testing.T) {
+	moderator := service.NewReviewModerator(service.ReviewRules{MaxPerDay: 2})
+	now := time.Now()
+
+	require.NoError(t, moderator.RecordReview("user-1", now))
+	require.NoError(t, moderator.RecordReview("user-1", now.Add(time.Hour)))
+	require.NoError(t, moderator.RecordReview("user-2", now.Add(time.Hour)))
+
+	err := moderator.RecordReview("user-1", now.Add(2*time.Hour))
+
+	var limited *models.ReviewRateLimitError
+	require.ErrorAs(t, err, &limited)
+	require.ErrorIs(t, err, models.ErrTooManyRequests)
+	require.Equal(t, 22*time.Hour, limited.RetryAfter)
+
+	// Через сутки после первого отзыва место освобождается
+	require.NoError(t, moderator.RecordReview("user-1", now.Add(24*time.Hour+time.Second)))
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"eats-backend/internal/models"
+)
+
+// Сколько символов отзыва попадает в сводку
+const reviewSnippetLength = 200
+
+// GetReviewSummary возвращает распределение оценок, среднюю и лучшие положительный и отрицательный
+// отзывы. Сводка считается при первом запросе и хранится до нового отзыва или замены каталога.
+func (s *ProductsService) GetReviewSummary(_ context.Context, productID string) (models.ReviewSummary, error) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	product, ok := s.productIndex[productID]
+	if !ok {
+		return models.ReviewSummary{}, fmt.Errorf("%w: no such product", models.ErrNotFound)
+	}
+
+	// Под RLock сводку могут считать несколько запросов сразу, кеш защищен отдельно
+	s.summaryMux.Lock()
+	defer s.summaryMux.Unlock()
+
+	summary, ok := s.reviewSummaries[productID]
+	if !ok {
+		summary = buildReviewSummary(product)
+		s.reviewSummaries[productID] = summary
+	}
+
+	summary.Distribution = slices.Clone(summary.Distribution)
+	summary.TopPositive = s.resolveSnippetAuthor(summary.TopPositive)
+	summary.TopNegative = s.resolveSnippetAuthor(summary.TopNegative)
+
+	return summary, nil
+}
+
+func buildReviewSummary(product *models.Product) models.ReviewSummary {
+	summary := models.ReviewSummary{ProductID: product.ID, Count: len(product.Reviews)}
+
+	counts := make(map[int]int, 5)
+	total := 0
+
+	var positive, negative *models.Review
+
+	for i := range product.Reviews {
+		review := &product.Reviews[i]
+
+		counts[review.Rating]++
+		total += review.Rating
+
+		if strings.TrimSpace(review.Content) == "" {
+			continue
+		}
+
+		// Лучший - с самой высокой оценкой, худший - с самой низкой, при равенстве новый
+		if review.Rating >= 4 && (positive == nil || review.Rating > positive.Rating ||
+			review.Rating == positive.Rating && review.CreatedAt.After(positive.CreatedAt)) {
+			positive = review
+		}
+
+		if review.Rating <= 2 && (negative == nil || review.Rating < negative.Rating ||
+			review.Rating == negative.Rating && review.CreatedAt.After(negative.CreatedAt)) {
+			negative = review
+		}
+	}
+
+	summary.Distribution = make([]models.RatingCount, 0, 5)
+	for rating := 5; rating >= 1; rating-- {
+		summary.Distribution = append(summary.Distribution, models.RatingCount{Rating: rating, Count: counts[rating]})
+	}
+
+	if summary.Count > 0 {
+		summary.Average = math.Round(float64(total)/float64(summary.Count)*10) / 10
+	}
+
+	summary.TopPositive = reviewSnippet(positive)
+	summary.TopNegative = reviewSnippet(negative)
+
+	return summary
+}
+
+// reviewSnippet обрезает текст отзыва до reviewSnippetLength символов по границе слова
+func reviewSnippet(review *models.Review) *models.ReviewSnippet {
+	if review == nil {
+		return nil
+	}
+
+	snippet := &models.ReviewSnippet{
+		Rating:    review.Rating,
+		Author:    review.Author,
+		UserID:    review.UserID,
+		CreatedAt: review.CreatedAt,
+		Text:      strings.TrimSpace(review.Content),
+	}
+
+	if utf8.RuneCountInString(snippet.Text) <= reviewSnippetLength {
+		return snippet
+	}
+
+	text := string([]rune(snippet.Text)[:reviewSnippetLength])
+	if cut := strings.LastIndexAny(text, " \n\t"); cut > 0 {
+		text = text[:cut]
+	}
+
+	snippet.Text = strings.TrimRight(text, " ,.;:-") + "…"
+	snippet.Truncated = true
+
+	return snippet
+}
+
+// resolveSnippetAuthor возвращает копию отзыва из сводки с текущими данными автора, сводка в кеше не меняется
+func (s *ProductsService) resolveSnippetAuthor(snippet *models.ReviewSnippet) *models.ReviewSnippet {
+	if snippet == nil {
+		return nil
+	}
+
+	resolved := *snippet
+	resolved.Author, resolved.AuthorImage, resolved.AuthorDeleted = s.reviewAuthor(snippet.UserID, snippet.Author)
+
+	if resolved.AuthorDeleted {
+		resolved.UserID = ""
+	}
+
+	return &resolved
+}
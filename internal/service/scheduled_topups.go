@@ -0,0 +1,325 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"eats-backend/internal/models"
+)
+
+type ScheduledTopupWallet interface {
+	GetWallet(ctx context.Context) (*models.Wallet, error)
+	TopupAccount(ctx context.Context, req models.TopupRequest) (*models.TopupResponse, error)
+}
+
+type ScheduledTopupNotifier interface {
+	TopupExecuted(ctx context.Context, userID string, topup models.ScheduledTopup)
+	TopupFailed(ctx context.Context, userID string, topup models.ScheduledTopup)
+}
+
+// ScheduledTopupService хранит регулярные пополнения кошелька и по расписанию выполняет их
+// как обычные пополнения: с дневным лимитом и антифродом.
+type ScheduledTopupService struct {
+	wallet   ScheduledTopupWallet
+	notifier ScheduledTopupNotifier
+	clock    Clock
+	logger   *zap.SugaredLogger
+	interval time.Duration
+
+	topups map[string]map[string]*models.ScheduledTopup // userID -> topupID -> topup
+
+	mux sync.RWMutex
+}
+
+func NewScheduledTopupService(
+	wallet ScheduledTopupWallet,
+	notifier ScheduledTopupNotifier,
+	clock Clock,
+	logger *zap.SugaredLogger,
+	interval time.Duration,
+	initialData map[string][]*models.ScheduledTopup,
+) *ScheduledTopupService {
+	service := &ScheduledTopupService{
+		wallet:   wallet,
+		notifier: notifier,
+		clock:    clockOrSystem(clock),
+		logger:   logger,
+		interval: interval,
+		topups:   make(map[string]map[string]*models.ScheduledTopup),
+	}
+
+	for userID, topups := range initialData {
+		service.topups[userID] = make(map[string]*models.ScheduledTopup, len(topups))
+		for _, topup := range topups {
+			copied := *topup
+			service.topups[userID][topup.ID] = &copied
+		}
+	}
+
+	return service
+}
+
+// GetTopups возвращает регулярные пополнения пользователя в порядке создания, включая отмененные
+func (s *ScheduledTopupService) GetTopups(ctx context.Context) []models.ScheduledTopup {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	result := make([]models.ScheduledTopup, 0, len(s.topups[userID]))
+	for _, topup := range s.topups[userID] {
+		result = append(result, *topup)
+	}
+
+	slices.SortFunc(result, func(a, b models.ScheduledTopup) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), cmp.Compare(a.ID, b.ID))
+	})
+
+	return result
+}
+
+// Create добавляет регулярное пополнение. Первое пополнение будет через один интервал.
+func (s *ScheduledTopupService) Create(ctx context.Context, req models.ScheduledTopupRequest) (models.ScheduledTopup, error) {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	if req.Interval != models.ScheduledTopupDaily && req.Interval != models.ScheduledTopupWeekly {
+		return models.ScheduledTopup{}, fmt.Errorf("%w: interval must be daily or weekly", models.ErrBadRequest)
+	}
+
+	if req.Amount <= 0 {
+		return models.ScheduledTopup{}, fmt.Errorf("%w: amount must be positive", models.ErrBadRequest)
+	}
+
+	wallet, err := s.wallet.GetWallet(ctx)
+	if err != nil {
+		return models.ScheduledTopup{}, fmt.Errorf("get wallet: %w", err)
+	}
+
+	index := slices.IndexFunc(wallet.Accounts, func(account models.Account) bool { return account.ID == req.AccountID })
+	if index < 0 {
+		return models.ScheduledTopup{}, fmt.Errorf("%w: account not found", models.ErrNotFound)
+	}
+
+	now := s.clock.Now()
+	topup := &models.ScheduledTopup{
+		ID:        uuid.NewString(),
+		AccountID: req.AccountID,
+		Amount:    req.Amount,
+		Currency:  wallet.Accounts[index].Currency,
+		Interval:  req.Interval,
+		Status:    models.ScheduledTopupActive,
+		NextRunAt: nextTopupRun(now, req.Interval),
+		CreatedAt: now,
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.topups[userID] == nil {
+		s.topups[userID] = make(map[string]*models.ScheduledTopup)
+	}
+
+	s.topups[userID][topup.ID] = topup
+
+	return *topup, nil
+}
+
+// Cancel отменяет регулярное пополнение, оно остается в списке для истории.
+func (s *ScheduledTopupService) Cancel(ctx context.Context, id string) (models.ScheduledTopup, error) {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	topup, ok := s.topups[userID][id]
+	if !ok {
+		return models.ScheduledTopup{}, fmt.Errorf("%w: scheduled topup not found", models.ErrNotFound)
+	}
+
+	if topup.Status == models.ScheduledTopupCancelled {
+		return models.ScheduledTopup{}, fmt.Errorf("%w: scheduled topup is already cancelled", models.ErrBadRequest)
+	}
+
+	topup.Status = models.ScheduledTopupCancelled
+
+	return *topup, nil
+}
+
+// Start проверяет регулярные пополнения с заданным интервалом до отмены контекста
+func (s *ScheduledTopupService) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.RunDue(ctx, s.clock.Now())
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+type dueTopup struct {
+	userID string
+	topup  models.ScheduledTopup
+}
+
+// RunDue выполняет все активные пополнения, срок которых наступил к now.
+func (s *ScheduledTopupService) RunDue(ctx context.Context, now time.Time) {
+	s.mux.RLock()
+
+	due := make([]dueTopup, 0)
+	for userID, topups := range s.topups {
+		for _, topup := range topups {
+			if topup.Status == models.ScheduledTopupActive && !topup.NextRunAt.After(now) {
+				due = append(due, dueTopup{userID: userID, topup: *topup})
+			}
+		}
+	}
+
+	s.mux.RUnlock()
+
+	for _, item := range due {
+		if ctx.Err() != nil {
+			return
+		}
+
+		s.run(ctx, item.userID, item.topup, now)
+	}
+}
+
+// run выполняет одно пополнение. Пропущенные за время простоя сервера пополнения не наверстываются:
+// следующее назначается на ближайший срок после now. Неудачное пополнение не повторяется до следующего срока.
+func (s *ScheduledTopupService) run(ctx context.Context, userID string, topup models.ScheduledTopup, now time.Time) {
+	userCtx := models.ContextWithUser(context.WithoutCancel(ctx), userID)
+
+	_, err := s.wallet.TopupAccount(userCtx, models.TopupRequest{AccountID: topup.AccountID, Amount: topup.Amount})
+
+	s.mux.Lock()
+
+	current, ok := s.topups[userID][topup.ID]
+	if !ok || current.Status != models.ScheduledTopupActive || !current.NextRunAt.Equal(topup.NextRunAt) {
+		s.mux.Unlock()
+
+		return
+	}
+
+	for !current.NextRunAt.After(now) {
+		current.NextRunAt = nextTopupRun(current.NextRunAt, current.Interval)
+	}
+
+	current.LastRunAt = now
+	current.LastError = ""
+
+	if err != nil {
+		current.LastError = failureReason(err)
+	}
+
+	result := *current
+
+	s.mux.Unlock()
+
+	if err != nil {
+		s.logger.Warnw("Scheduled topup failed", "topupId", topup.ID, "userId", userID, "error", err)
+		s.notifier.TopupFailed(userCtx, userID, result)
+
+		return
+	}
+
+	s.logger.Infow("Scheduled topup executed", "topupId", topup.ID, "userId", userID, "amount", topup.Amount)
+	s.notifier.TopupExecuted(userCtx, userID, result)
+}
+
+func nextTopupRun(from time.Time, interval models.ScheduledTopupInterval) time.Time {
+	if interval == models.ScheduledTopupWeekly {
+		return from.AddDate(0, 0, 7)
+	}
+
+	return from.AddDate(0, 0, 1)
+}
+
+// GetBackupData возвращает данные для бэкапа
+func (s *ScheduledTopupService) GetBackupData() interface{} {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	result := make(map[string][]*models.ScheduledTopup, len(s.topups))
+	for userID, topups := range s.topups {
+		result[userID] = make([]*models.ScheduledTopup, 0, len(topups))
+		for _, topup := range topups {
+			copied := *topup
+			result[userID] = append(result[userID], &copied)
+		}
+	}
+
+	return result
+}
+
+// GetBackupFileName возвращает имя файла для бэкапа
+func (s *ScheduledTopupService) GetBackupFileName() string {
+	return "scheduled_topups"
+}
+
+// RestoreBackupData заменяет регулярные пополнения данными из бэкапа
+func (s *ScheduledTopupService) RestoreBackupData(data []byte) error {
+	var backup map[string][]*models.ScheduledTopup
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return fmt.Errorf("can't parse scheduled topups: %w", err)
+	}
+
+	topups := make(map[string]map[string]*models.ScheduledTopup, len(backup))
+	for userID, userTopups := range backup {
+		topups[userID] = make(map[string]*models.ScheduledTopup, len(userTopups))
+		for _, topup := range userTopups {
+			topups[userID][topup.ID] = topup
+		}
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.topups = topups
+
+	return nil
+}
+
+// ResetUser удаляет регулярные пополнения пользователя
+func (s *ScheduledTopupService) ResetUser(userID string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	delete(s.topups, userID)
+}
+
+func (s *ScheduledTopupService) CollectionSizes(_ context.Context) map[string]int {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	topups := 0
+	for _, userTopups := range s.topups {
+		topups += len(userTopups)
+	}
+
+	return map[string]int{
+		"scheduledTopups.users": len(s.topups),
+		"scheduledTopups":       topups,
+	}
+}
+
+// ExportUserData возвращает регулярные пополнения пользователя
+func (s *ScheduledTopupService) ExportUserData(ctx context.Context) (any, error) {
+	return s.GetTopups(ctx), nil
+}
+
+func (s *ScheduledTopupService) GetUserExportName() string {
+	return "scheduled_topups"
+}
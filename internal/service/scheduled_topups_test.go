@@ -0,0 +1,97 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"eats-backend/internal/models"
+	"eats-backend/internal/service"
+)
+
+type testTopupWallet struct {
+	limit   models.Money
+	credits []models.TopupRequest
+}
+
+func (w *testTopupWallet) GetWallet(context.Context) (*models.Wallet, error) {
+	return &models.Wallet{Accounts: []models.Account{{ID: "card", Currency: models.CurrencyRUB}}}, nil
+}
+
+func (w *testTopupWallet) TopupAccount(_ context.Context, req models.TopupRequest) (*models.TopupResponse, error) {
+	if req.Amount > w.limit {
+		return nil, fmt.Errorf("%w: daily topup limit exceeded (1000 rubles per day)", models.ErrBadRequest)
+	}
+
+	w.credits = append(w.credits, req)
+
+	return &models.TopupResponse{}, nil
+}
+
+type testTopupNotifier struct {
+	executed, failed []models.ScheduledTopup
+}
+
+func (n *testTopupNotifier) TopupExecuted(_ context.Context, _ string, topup models.ScheduledTopup) {
+	n.executed = append(n.executed, topup)
+}
+
+func (n *testTopupNotifier) TopupFailed(_ context.Context, _ string, topup models.ScheduledTopup) {
+	n.failed = append(n.failed, topup)
+}
+
+func TestScheduledTopups_RunDue(t *testing.T) {
+	ctx := walletContext(t, "user")
+	wallet := &testTopupWallet{limit: models.Rubles(1000)}
+	notifier := &testTopupNotifier{}
+	topups := service.NewScheduledTopupService(wallet, notifier, nil, zap.NewNop().Sugar(), time.Minute, nil)
+
+	_, err := topups.Create(ctx, models.ScheduledTopupRequest{AccountID: "card", Amount: models.Rubles(100), Interval: "monthly"})
+	require.ErrorIs(t, err, models.ErrBadRequest)
+
+	_, err = topups.Create(ctx, models.ScheduledTopupRequest{AccountID: "other", Amount: models.Rubles(100), Interval: "daily"})
+	require.ErrorIs(t, err, models.ErrNotFound)
+
+	daily, err := topups.Create(ctx, models.ScheduledTopupRequest{
+		AccountID: "card", Amount: models.Rubles(100), Interval: models.ScheduledTopupDaily,
+	})
+	require.NoError(t, err)
+	require.Equal(t, models.CurrencyRUB, daily.Currency)
+
+	tooBig, err := topups.Create(ctx, models.ScheduledTopupRequest{
+		AccountID: "card", Amount: models.Rubles(2000), Interval: models.ScheduledTopupWeekly,
+	})
+	require.NoError(t, err)
+
+	// Срок еще не наступил
+	topups.RunDue(t.Context(), time.Now())
+	require.Empty(t, wallet.credits)
+
+	// Через неделю наступил срок обоих; пропущенные дни ежедневного пополнения не наверстываются
+	now := time.Now().AddDate(0, 0, 7)
+	topups.RunDue(t.Context(), now)
+
+	require.Equal(t, []models.TopupRequest{{AccountID: "card", Amount: models.Rubles(100)}}, wallet.credits)
+	require.Len(t, notifier.executed, 1)
+	require.Len(t, notifier.failed, 1)
+	require.Equal(t, tooBig.ID, notifier.failed[0].ID)
+	require.Contains(t, notifier.failed[0].LastError, "daily topup limit exceeded")
+
+	list := topups.GetTopups(ctx)
+	require.Len(t, list, 2)
+	require.True(t, list[0].NextRunAt.After(now))
+	require.True(t, list[0].NextRunAt.Before(now.AddDate(0, 0, 1).Add(time.Second)))
+
+	_, err = topups.Cancel(ctx, daily.ID)
+	require.NoError(t, err)
+
+	_, err = topups.Cancel(ctx, daily.ID)
+	require.ErrorIs(t, err, models.ErrBadRequest)
+
+	topups.RunDue(t.Context(), now.AddDate(0, 0, 1))
+	require.Len(t, wallet.credits, 1)
+}
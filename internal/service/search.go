@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"eats-backend/internal/models"
+)
+
+type SearchCatalog interface {
+	SearchProducts(ctx context.Context, query string, limit int) ([]models.ProductPreview, int)
+	GetLocalizedCategories(ctx context.Context, includeEmpty bool) []models.Category
+}
+
+type SearchOrders interface {
+	GetOrders(ctx context.Context) ([]models.Order, error)
+}
+
+// SearchService глобальный поиск для строки поиска клиента: товары, категории и прошлые заказы пользователя.
+type SearchService struct {
+	catalog SearchCatalog
+	orders  SearchOrders
+}
+
+func NewSearchService(catalog SearchCatalog, orders SearchOrders) *SearchService {
+	return &SearchService{
+		catalog: catalog,
+		orders:  orders,
+	}
+}
+
+// Search ищет по всем группам из req.Types и возвращает в каждой не больше req.Limit результатов.
+func (s *SearchService) Search(ctx context.Context, req models.SearchRequest) (models.SearchResults, error) {
+	query := strings.TrimSpace(req.Query)
+	if utf8.RuneCountInString(query) < models.MinSearchQueryLength {
+		return models.SearchResults{}, fmt.Errorf("%w: query must be at least %d characters",
+			models.ErrBadRequest, models.MinSearchQueryLength)
+	}
+
+	if req.Limit == 0 {
+		req.Limit = models.DefaultSearchLimit
+	}
+
+	if req.Limit < 0 || req.Limit > models.MaxSearchLimit {
+		return models.SearchResults{}, fmt.Errorf("%w: limit must be between 1 and %d", models.ErrBadRequest, models.MaxSearchLimit)
+	}
+
+	types := req.Types
+	if len(types) == 0 {
+		types = []models.SearchType{models.SearchTypeProducts, models.SearchTypeCategories, models.SearchTypeOrders}
+	}
+
+	result := models.SearchResults{Query: query}
+
+	for _, searchType := range types {
+		switch searchType {
+		case models.SearchTypeProducts:
+			items, total := s.catalog.SearchProducts(ctx, query, req.Limit)
+			if items == nil {
+				items = []models.ProductPreview{}
+			}
+
+			result.Products = &models.ProductSearchGroup{Items: items, Total: total}
+		case models.SearchTypeCategories:
+			result.Categories = s.searchCategories(ctx, query, req.Limit)
+		case models.SearchTypeOrders:
+			orders, err := s.searchOrders(ctx, query, req.Limit)
+			if err != nil {
+				return models.SearchResults{}, err
+			}
+
+			result.Orders = orders
+		default:
+			return models.SearchResults{}, fmt.Errorf("%w: unknown type %s, should be products, categories or orders",
+				models.ErrBadRequest, searchType)
+		}
+	}
+
+	return result, nil
+}
+
+// searchCategories ищет по названию категории на языке пользователя, категории без товаров не показываются.
+func (s *SearchService) searchCategories(ctx context.Context, query string, limit int) *models.CategorySearchGroup {
+	query = normalizeSearch(query)
+	group := &models.CategorySearchGroup{Items: []models.Category{}}
+
+	for _, category := range s.catalog.GetLocalizedCategories(ctx, false) {
+		if !strings.Contains(normalizeSearch(category.Name), query) {
+			continue
+		}
+
+		group.Total++
+		if len(group.Items) < limit {
+			group.Items = append(group.Items, category)
+		}
+	}
+
+	return group
+}
+
+// searchOrders ищет заказы пользователя, в которых есть товар с искомым названием. Порядок как в истории
+// заказов: новые первыми.
+func (s *SearchService) searchOrders(ctx context.Context, query string, limit int) (*models.OrderSearchGroup, error) {
+	orders, err := s.orders.GetOrders(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get orders: %w", err)
+	}
+
+	query = normalizeSearch(query)
+	group := &models.OrderSearchGroup{Items: []models.OrderSearchResult{}}
+
+	for _, order := range orders {
+		matched := make([]string, 0)
+		for _, item := range order.Items {
+			if strings.Contains(normalizeSearch(item.Name), query) {
+				matched = append(matched, item.Name)
+			}
+		}
+
+		if len(matched) == 0 {
+			continue
+		}
+
+		group.Total++
+		if len(group.Items) < limit {
+			group.Items = append(group.Items, models.OrderSearchResult{
+				ID:           order.ID,
+				Status:       order.Status,
+				DeliveryDate: order.DeliveryDate,
+				TotalPrice:   order.TotalPrice,
+				MatchedItems: matched,
+			})
+		}
+	}
+
+	return group, nil
+}
+
+// normalizeSearch приводит текст к виду для сравнения без учета регистра и разницы между е и ё.
+func normalizeSearch(text string) string {
+	return strings.ReplaceAll(strings.ToLower(text), "ё", "е")
+}
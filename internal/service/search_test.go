@@ -0,0 +1,85 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"eats-backend/internal/models"
+	"eats-backend/internal/service"
+)
+
+type testSearchOrders []models.Order
+
+func (o testSearchOrders) GetOrders(context.Context) ([]models.Order, error) { return o, nil }
+
+func TestSearchService_Search(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	favourites := service.NewMockUserService(ctrl)
+	favourites.EXPECT().IsFavourite(gomock.Any(), gomock.Any()).Return(false).AnyTimes()
+
+	products := service.NewProductsService(favourites, service.NewRecentlyViewed(service.DefaultRecentlyViewedLimit), nil, service.NewProductPopularity(nil, nil), nil, nil, nil, nil, nil, []*models.Product{
+		{ID: "juice", Name: "Сок яблочный", Description: "Сок прямого отжима"},
+		{ID: "pie", Name: "Пирог", Description: "С яблоками"},
+		{ID: "apple", Name: "Яблоко"},
+		{ID: "bread", Name: "Хлеб"},
+	}, map[string][]string{
+		"fruits": {"apple"},
+		"bakery": {"bread", "pie"},
+	}, map[string]models.Category{
+		"fruits": {ID: "fruits", Name: "Фрукты и ягоды"},
+		"bakery": {ID: "bakery", Name: "Выпечка"},
+		"empty":  {ID: "empty", Name: "Ягодные десерты"},
+	})
+
+	search := service.NewSearchService(products, testSearchOrders{
+		{ID: "new", Items: []models.OrderItem{{Name: "Яблоко"}, {Name: "Хлеб"}}},
+		{ID: "old", Items: []models.OrderItem{{Name: "Сок яблочный"}}},
+	})
+
+	ids := func(previews []models.ProductPreview) []string {
+		result := make([]string, 0, len(previews))
+		for _, preview := range previews {
+			result = append(result, preview.ID)
+		}
+
+		return result
+	}
+
+	result, err := search.Search(t.Context(), models.SearchRequest{Query: " ЯБЛ "})
+	require.NoError(t, err)
+	require.Equal(t, "ЯБЛ", result.Query)
+	// Сначала название с начала, потом вхождение в название, последним - описание
+	require.Equal(t, []string{"apple", "juice", "pie"}, ids(result.Products.Items))
+	require.Equal(t, 3, result.Products.Total)
+	require.Empty(t, result.Categories.Items)
+	require.Equal(t, []models.OrderSearchResult{
+		{ID: "new", MatchedItems: []string{"Яблоко"}},
+		{ID: "old", MatchedItems: []string{"Сок яблочный"}},
+	}, result.Orders.Items)
+
+	result, err = search.Search(t.Context(), models.SearchRequest{Query: "ягод", Types: []models.SearchType{models.SearchTypeCategories}, Limit: 1})
+	require.NoError(t, err)
+	require.Nil(t, result.Products)
+	require.Nil(t, result.Orders)
+	// Категория без товаров не показывается
+	require.Equal(t, 1, result.Categories.Total)
+	require.Equal(t, "fruits", result.Categories.Items[0].ID)
+
+	result, err = search.Search(t.Context(), models.SearchRequest{Query: "яблоко", Types: []models.SearchType{models.SearchTypeProducts}, Limit: 1})
+	require.NoError(t, err)
+	require.Equal(t, []string{"apple"}, ids(result.Products.Items))
+	require.Equal(t, 1, result.Products.Total)
+
+	_, err = search.Search(t.Context(), models.SearchRequest{Query: "я"})
+	require.ErrorIs(t, err, models.ErrBadRequest)
+
+	_, err = search.Search(t.Context(), models.SearchRequest{Query: "яблоко", Types: []models.SearchType{"users"}})
+	require.ErrorIs(t, err, models.ErrBadRequest)
+
+	_, err = search.Search(t.Context(), models.SearchRequest{Query: "яблоко", Limit: models.MaxSearchLimit + 1})
+	require.ErrorIs(t, err, models.ErrBadRequest)
+}
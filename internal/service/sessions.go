@@ -0,0 +1,265 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"eats-backend/internal/models"
+)
+
+// TokenRevoker список отозванных токенов, который проверяет авторизация
+type TokenRevoker interface {
+	Add(ctx context.Context, tokenIDs ...string) error
+}
+
+// sessionTouchInterval как часто обновляется время последнего запроса сессии. Чаще каждый запрос
+// брал бы общую блокировку на запись.
+const sessionTouchInterval = time.Minute
+
+// SessionService запоминает токены, с которыми заходил каждый пользователь, и завершает сессии,
+// отзывая их токены. Данные приложения привязаны к токену, но токены для разных устройств выдаются
+// одному пользователю, поэтому сессии группируются по владельцу (см. sessionOwner).
+type SessionService struct {
+	revoker TokenRevoker
+
+	// владелец -> jti -> сессия
+	sessions map[string]map[string]*models.Session
+	// Токены, отозванные через сессии. Сохраняются в бэкапе, чтобы список в памяти пережил перезапуск.
+	revoked []string
+
+	mux sync.RWMutex
+}
+
+func NewSessionService(revoker TokenRevoker) *SessionService {
+	return &SessionService{
+		revoker:  revoker,
+		sessions: make(map[string]map[string]*models.Session),
+	}
+}
+
+// sessionOwner пользователь, которому выдан токен. Ник уникален только у одного преподавателя, поэтому
+// к нему добавляется выдавший токен. Если в токене есть subject, пользователь определяется по нему.
+func sessionOwner(claims *models.AuthTokenClaims) string {
+	return claims.Issuer + "/" + cmp.Or(claims.Subject, claims.Nickname)
+}
+
+// Touch отмечает запрос с токеном. Вызывается после проверки токена на каждом запросе. Время последнего
+// запроса обновляется не чаще раза в sessionTouchInterval, остальные запросы обходятся блокировкой на чтение.
+func (s *SessionService) Touch(claims *models.AuthTokenClaims, userAgent string, now time.Time) {
+	owner := sessionOwner(claims)
+
+	s.mux.RLock()
+	session, ok := s.sessions[owner][claims.ID]
+	fresh := ok && session.UserAgent == userAgent && now.Sub(session.LastSeenAt) < sessionTouchInterval
+	s.mux.RUnlock()
+
+	if fresh {
+		return
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	sessions, ok := s.sessions[owner]
+	if !ok {
+		sessions = make(map[string]*models.Session)
+		s.sessions[owner] = sessions
+	}
+
+	session, ok = sessions[claims.ID]
+	if !ok {
+		if len(sessions) >= models.MaxSessionsPerUser {
+			evictOldestSession(sessions)
+		}
+
+		session = &models.Session{ID: claims.ID, FirstSeenAt: now}
+		sessions[claims.ID] = session
+	}
+
+	if now.After(session.LastSeenAt) {
+		session.LastSeenAt = now
+	}
+
+	if session.UserAgent != userAgent {
+		session.UserAgent = userAgent
+		session.Device = deviceFromUserAgent(userAgent)
+	}
+}
+
+func evictOldestSession(sessions map[string]*models.Session) {
+	var oldest *models.Session
+
+	for _, session := range sessions {
+		if oldest == nil || session.LastSeenAt.Before(oldest.LastSeenAt) {
+			oldest = session
+		}
+	}
+
+	if oldest != nil {
+		delete(sessions, oldest.ID)
+	}
+}
+
+// ListSessions возвращает сессии пользователя, недавние первыми
+func (s *SessionService) ListSessions(ctx context.Context) []models.Session {
+	claims := models.ClaimsFromContext(ctx)
+	owner := sessionOwner(claims)
+
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	result := make([]models.Session, 0, len(s.sessions[owner]))
+	for _, session := range s.sessions[owner] {
+		copied := *session
+		copied.Current = session.ID == claims.ID
+		result = append(result, copied)
+	}
+
+	slices.SortFunc(result, func(a, b models.Session) int {
+		return cmp.Or(b.LastSeenAt.Compare(a.LastSeenAt), cmp.Compare(a.ID, b.ID))
+	})
+
+	return result
+}
+
+// RevokeSession завершает сессию пользователя: ее токен больше не принимается. Можно завершить и текущую.
+func (s *SessionService) RevokeSession(ctx context.Context, tokenID string) error {
+	claims := models.ClaimsFromContext(ctx)
+	owner := sessionOwner(claims)
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if _, ok := s.sessions[owner][tokenID]; !ok {
+		return fmt.Errorf("%w: session not found", models.ErrNotFound)
+	}
+
+	if err := s.revoker.Add(ctx, tokenID); err != nil {
+		return fmt.Errorf("revoke token: %w", err)
+	}
+
+	delete(s.sessions[owner], tokenID)
+	s.revoked = append(s.revoked, tokenID)
+
+	return nil
+}
+
+// userAgentPlatforms и userAgentClients проверяются по порядку: первое совпадение определяет название
+var (
+	userAgentPlatforms = []struct{ marker, name string }{
+		{"iPhone", "iOS"},
+		{"iPad", "iPadOS"},
+		{"CFNetwork", "iOS"},
+		{"Android", "Android"},
+		{"okhttp", "Android"},
+		{"Windows", "Windows"},
+		{"Mac OS", "macOS"},
+		{"Linux", "Linux"},
+	}
+	userAgentClients = []struct{ marker, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"YaBrowser", "Яндекс Браузер"},
+		{"Chrome/", "Chrome"},
+		{"Firefox/", "Firefox"},
+		{"Safari/", "Safari"},
+		{"okhttp", "приложение"},
+		{"CFNetwork", "приложение"},
+		{"PostmanRuntime", "Postman"},
+		{"curl/", "curl"},
+	}
+)
+
+// deviceFromUserAgent описывает устройство по User-Agent, например "Android, Chrome"
+func deviceFromUserAgent(userAgent string) string {
+	var parts []string
+
+	for _, list := range [][]struct{ marker, name string }{userAgentPlatforms, userAgentClients} {
+		for _, known := range list {
+			if strings.Contains(userAgent, known.marker) {
+				parts = append(parts, known.name)
+
+				break
+			}
+		}
+	}
+
+	if len(parts) == 0 {
+		return "Неизвестное устройство"
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+type sessionsBackup struct {
+	Sessions map[string]map[string]*models.Session `json:"sessions"`
+	Revoked  []string                              `json:"revoked"`
+}
+
+// GetBackupData возвращает данные для бэкапа
+func (s *SessionService) GetBackupData() interface{} {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	backup := sessionsBackup{
+		Sessions: make(map[string]map[string]*models.Session, len(s.sessions)),
+		Revoked:  slices.Clone(s.revoked),
+	}
+
+	for owner, sessions := range s.sessions {
+		backup.Sessions[owner] = make(map[string]*models.Session, len(sessions))
+
+		for id, session := range sessions {
+			copied := *session
+			backup.Sessions[owner][id] = &copied
+		}
+	}
+
+	return backup
+}
+
+func (s *SessionService) GetBackupFileName() string {
+	return "sessions"
+}
+
+// RestoreBackupData заменяет сессии данными из бэкапа и снова отзывает завершенные сессии
+func (s *SessionService) RestoreBackupData(data []byte) error {
+	var backup sessionsBackup
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return fmt.Errorf("can't parse sessions: %w", err)
+	}
+
+	if err := s.revoker.Add(context.Background(), backup.Revoked...); err != nil {
+		return fmt.Errorf("revoke tokens: %w", err)
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.sessions = backup.Sessions
+	if s.sessions == nil {
+		s.sessions = make(map[string]map[string]*models.Session)
+	}
+
+	s.revoked = backup.Revoked
+
+	return nil
+}
+
+func (s *SessionService) CollectionSizes(_ context.Context) map[string]int {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	count := 0
+	for _, sessions := range s.sessions {
+		count += len(sessions)
+	}
+
+	return map[string]int{"sessions": count, "revoked_sessions": len(s.revoked)}
+}
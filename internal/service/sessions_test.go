@@ -0,0 +1,115 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+
+	"eats-backend/internal/models"
+	"eats-backend/internal/service"
+)
+
+type testTokenRevoker struct{ ids []string }
+
+func (r *testTokenRevoker) Add(_ context.Context, tokenIDs ...string) error {
+	r.ids = append(r.ids, tokenIDs...)
+	return nil
+}
+
+func TestSessionService(t *testing.T) {
+	revoker := &testTokenRevoker{}
+	sessions := service.NewSessionService(revoker)
+
+	claims := func(id, nickname string) *models.AuthTokenClaims {
+		return &models.AuthTokenClaims{RegisteredClaims: &jwt.RegisteredClaims{ID: id}, Nickname: nickname}
+	}
+
+	phone, browser := claims("phone", "alice"), claims("browser", "alice")
+	now := time.Now()
+
+	sessions.Touch(phone, "okhttp/4.12.0", now)
+	sessions.Touch(browser, "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 Chrome/126.0 Safari/537.36", now.Add(time.Minute))
+	sessions.Touch(claims("other", "bob"), "curl/8.0", now)
+
+	ctx := context.WithValue(t.Context(), models.ContextClaimsKey{}, phone)
+
+	list := sessions.ListSessions(ctx)
+	require.Len(t, list, 2)
+	require.Equal(t, "browser", list[0].ID)
+	require.Equal(t, "Windows, Chrome", list[0].Device)
+	require.False(t, list[0].Current)
+	require.Equal(t, "Android, приложение", list[1].Device)
+	require.True(t, list[1].Current)
+
+	// Чужую сессию завершить нельзя
+	require.ErrorIs(t, sessions.RevokeSession(ctx, "other"), models.ErrNotFound)
+
+	require.NoError(t, sessions.RevokeSession(ctx, "browser"))
+	require.Equal(t, []string{"browser"}, revoker.ids)
+	require.Len(t, sessions.ListSessions(ctx), 1)
+
+	// Отозванные токены снова попадают в список после восстановления из бэкапа
+	backup, err := json.Marshal(sessions.GetBackupData())
+	require.NoError(t, err)
+
+	restoredRevoker := &testTokenRevoker{}
+	restored := service.NewSessionService(restoredRevoker)
+	require.NoError(t, restored.RestoreBackupData(backup))
+	require.Equal(t, []string{"browser"}, restoredRevoker.ids)
+	require.Len(t, restored.ListSessions(ctx), 1)
+}
+
+func TestSessionService_Limit(t *testing.T) {
+	sessions := service.NewSessionService(&testTokenRevoker{})
+	now := time.Now()
+
+	var ctx context.Context
+
+	for i := range models.MaxSessionsPerUser + 1 {
+		claims := &models.AuthTokenClaims{RegisteredClaims: &jwt.RegisteredClaims{ID: string(rune('a' + i))}, Nickname: "alice"}
+		sessions.Touch(claims, "", now.Add(time.Duration(i)*time.Second))
+
+		ctx = context.WithValue(t.Context(), models.ContextClaimsKey{}, claims)
+	}
+
+	list := sessions.ListSessions(ctx)
+	require.Len(t, list, models.MaxSessionsPerUser)
+	// Самая давняя сессия вытеснена
+	require.False(t, slices.ContainsFunc(list, func(session models.Session) bool { return session.ID == "a" }))
+}
+
+func TestSessionService_Owner(t *testing.T) {
+	sessions := service.NewSessionService(&testTokenRevoker{})
+	now := time.Now()
+
+	claims := func(id, issuer string) *models.AuthTokenClaims {
+		return &models.AuthTokenClaims{RegisteredClaims: &jwt.RegisteredClaims{ID: id, Issuer: issuer}, Nickname: "alice"}
+	}
+
+	// Одинаковый ник у учеников разных преподавателей
+	first, second := claims("first", "teacher-1"), claims("second", "teacher-2")
+	sessions.Touch(first, "curl/8.0", now)
+	sessions.Touch(second, "curl/8.0", now)
+
+	firstCtx := context.WithValue(t.Context(), models.ContextClaimsKey{}, first)
+
+	list := sessions.ListSessions(firstCtx)
+	require.Len(t, list, 1)
+	require.Equal(t, "first", list[0].ID)
+	require.ErrorIs(t, sessions.RevokeSession(firstCtx, "second"), models.ErrNotFound)
+
+	// Время последнего запроса обновляется не чаще раза в минуту, смена User-Agent видна сразу
+	sessions.Touch(first, "curl/8.0", now.Add(30*time.Second))
+	require.Equal(t, now, sessions.ListSessions(firstCtx)[0].LastSeenAt)
+
+	sessions.Touch(first, "curl/8.0", now.Add(time.Minute))
+	require.Equal(t, now.Add(time.Minute), sessions.ListSessions(firstCtx)[0].LastSeenAt)
+
+	sessions.Touch(first, "PostmanRuntime/7.39.0", now.Add(70*time.Second))
+	require.Equal(t, "Postman", sessions.ListSessions(firstCtx)[0].Device)
+}
@@ -0,0 +1,361 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"eats-backend/internal/models"
+)
+
+const (
+	maxShoppingListNameLength = 100
+	maxShoppingLists          = 50
+)
+
+type CartFiller interface {
+	AddQuantity(ctx context.Context, productID string, quantity int) (int, error)
+}
+
+// ShoppingListService хранит списки покупок пользователей и переносит их в корзину.
+type ShoppingListService struct {
+	products ProductService
+	cart     CartFiller
+
+	lists map[string]map[string]*models.ShoppingList // userID -> listID -> list
+	// Исходные списки из файла данных, к ним возвращает ResetUser.
+	seed map[string][]*models.ShoppingList
+
+	mux sync.RWMutex
+}
+
+func NewShoppingListService(
+	products ProductService,
+	cart CartFiller,
+	initialData map[string][]*models.ShoppingList,
+) *ShoppingListService {
+	service := &ShoppingListService{
+		products: products,
+		cart:     cart,
+		lists:    make(map[string]map[string]*models.ShoppingList),
+		seed:     copyShoppingLists(initialData),
+	}
+
+	for userID, lists := range copyShoppingLists(initialData) {
+		service.lists[userID] = make(map[string]*models.ShoppingList, len(lists))
+		for _, list := range lists {
+			service.lists[userID][list.ID] = list
+		}
+	}
+
+	return service
+}
+
+// GetLists возвращает списки пользователя в порядке создания
+func (s *ShoppingListService) GetLists(ctx context.Context) []models.ShoppingList {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	result := make([]models.ShoppingList, 0, len(s.lists[userID]))
+	for _, list := range s.lists[userID] {
+		result = append(result, copyShoppingList(list))
+	}
+
+	slices.SortFunc(result, func(a, b models.ShoppingList) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), cmp.Compare(a.ID, b.ID))
+	})
+
+	return result
+}
+
+func (s *ShoppingListService) GetList(ctx context.Context, id string) (models.ShoppingList, error) {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	list, ok := s.lists[userID][id]
+	if !ok {
+		return models.ShoppingList{}, fmt.Errorf("%w: shopping list not found", models.ErrNotFound)
+	}
+
+	return copyShoppingList(list), nil
+}
+
+func (s *ShoppingListService) CreateList(ctx context.Context, req models.ShoppingListRequest) (models.ShoppingList, error) {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	items, err := s.validate(req)
+	if err != nil {
+		return models.ShoppingList{}, err
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if len(s.lists[userID]) >= maxShoppingLists {
+		return models.ShoppingList{}, fmt.Errorf("%w: no more than %d shopping lists", models.ErrBadRequest, maxShoppingLists)
+	}
+
+	now := time.Now()
+	list := &models.ShoppingList{
+		ID:        uuid.NewString(),
+		Name:      strings.TrimSpace(req.Name),
+		Items:     items,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	if s.lists[userID] == nil {
+		s.lists[userID] = make(map[string]*models.ShoppingList)
+	}
+
+	s.lists[userID][list.ID] = list
+
+	return copyShoppingList(list), nil
+}
+
+// UpdateList заменяет название и состав списка
+func (s *ShoppingListService) UpdateList(
+	ctx context.Context,
+	id string,
+	req models.ShoppingListRequest,
+) (models.ShoppingList, error) {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	items, err := s.validate(req)
+	if err != nil {
+		return models.ShoppingList{}, err
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	list, ok := s.lists[userID][id]
+	if !ok {
+		return models.ShoppingList{}, fmt.Errorf("%w: shopping list not found", models.ErrNotFound)
+	}
+
+	list.Name = strings.TrimSpace(req.Name)
+	list.Items = items
+	list.UpdatedAt = time.Now()
+
+	return copyShoppingList(list), nil
+}
+
+func (s *ShoppingListService) DeleteList(ctx context.Context, id string) error {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if _, ok := s.lists[userID][id]; !ok {
+		return fmt.Errorf("%w: shopping list not found", models.ErrNotFound)
+	}
+
+	delete(s.lists[userID], id)
+
+	return nil
+}
+
+// AddToCart добавляет товары списка в корзину. Товары не в наличии и удаленные из каталога
+// пропускаются и возвращаются в Skipped, список при этом не меняется.
+func (s *ShoppingListService) AddToCart(ctx context.Context, id string) (models.ListToCartResult, error) {
+	list, err := s.GetList(ctx, id)
+	if err != nil {
+		return models.ListToCartResult{}, err
+	}
+
+	result := models.ListToCartResult{
+		Added:   make([]models.ShoppingListItem, 0, len(list.Items)),
+		Skipped: make([]models.SkippedItem, 0),
+	}
+
+	for _, item := range list.Items {
+		product, err := s.products.GetProductByID(ctx, item.ProductID)
+		if errors.Is(err, models.ErrNotFound) {
+			result.Skipped = append(result.Skipped, models.SkippedItem{
+				ProductID: item.ProductID, Quantity: item.Quantity, Reason: models.SkipReasonNotFound,
+			})
+
+			continue
+		}
+
+		if err != nil {
+			return models.ListToCartResult{}, fmt.Errorf("can't get product %s: %w", item.ProductID, err)
+		}
+
+		if !product.Available {
+			result.Skipped = append(result.Skipped, models.SkippedItem{
+				ProductID: item.ProductID, Quantity: item.Quantity, Reason: models.SkipReasonUnavailable,
+			})
+
+			continue
+		}
+
+		if _, err := s.cart.AddQuantity(ctx, item.ProductID, item.Quantity); err != nil {
+			return models.ListToCartResult{}, fmt.Errorf("can't add %s to cart: %w", item.ProductID, err)
+		}
+
+		result.Added = append(result.Added, item)
+	}
+
+	return result, nil
+}
+
+// validate проверяет запрос и объединяет повторяющиеся товары в одну позицию
+func (s *ShoppingListService) validate(req models.ShoppingListRequest) ([]models.ShoppingListItem, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, fmt.Errorf("%w: name is required", models.ErrBadRequest)
+	}
+
+	if utf8.RuneCountInString(name) > maxShoppingListNameLength {
+		return nil, fmt.Errorf("%w: name is longer than %d characters", models.ErrBadRequest, maxShoppingListNameLength)
+	}
+
+	items := make([]models.ShoppingListItem, 0, len(req.Items))
+	positions := make(map[string]int, len(req.Items))
+
+	for _, item := range req.Items {
+		if item.Quantity <= 0 {
+			return nil, fmt.Errorf("%w: quantity of %s must be positive", models.ErrBadRequest, item.ProductID)
+		}
+
+		if !s.products.ProductExists(item.ProductID) {
+			return nil, fmt.Errorf("%w: product %s does not exist", models.ErrBadRequest, item.ProductID)
+		}
+
+		if i, ok := positions[item.ProductID]; ok {
+			items[i].Quantity += item.Quantity
+
+			continue
+		}
+
+		positions[item.ProductID] = len(items)
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// GetBackupData возвращает данные для бэкапа
+func (s *ShoppingListService) GetBackupData() interface{} {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	result := make(map[string][]*models.ShoppingList, len(s.lists))
+	for userID, lists := range s.lists {
+		result[userID] = make([]*models.ShoppingList, 0, len(lists))
+		for _, list := range lists {
+			copied := copyShoppingList(list)
+			result[userID] = append(result[userID], &copied)
+		}
+	}
+
+	return result
+}
+
+// GetBackupFileName возвращает имя файла для бэкапа
+func (s *ShoppingListService) GetBackupFileName() string {
+	return "shopping_lists"
+}
+
+// RestoreBackupData заменяет списки покупок данными из бэкапа
+func (s *ShoppingListService) RestoreBackupData(data []byte) error {
+	var backup map[string][]*models.ShoppingList
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return fmt.Errorf("can't parse shopping lists: %w", err)
+	}
+
+	lists := make(map[string]map[string]*models.ShoppingList, len(backup))
+	for userID, userLists := range backup {
+		lists[userID] = make(map[string]*models.ShoppingList, len(userLists))
+		for _, list := range userLists {
+			lists[userID][list.ID] = list
+		}
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.lists = lists
+
+	return nil
+}
+
+// ResetUser возвращает списки пользователя к исходному состоянию
+func (s *ShoppingListService) ResetUser(userID string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	delete(s.lists, userID)
+
+	for _, list := range s.seed[userID] {
+		if s.lists[userID] == nil {
+			s.lists[userID] = make(map[string]*models.ShoppingList)
+		}
+
+		copied := copyShoppingList(list)
+		s.lists[userID][list.ID] = &copied
+	}
+}
+
+func (s *ShoppingListService) CollectionSizes(_ context.Context) map[string]int {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	lists := 0
+	for _, userLists := range s.lists {
+		lists += len(userLists)
+	}
+
+	return map[string]int{
+		"shoppingLists.users": len(s.lists),
+		"shoppingLists":       lists,
+	}
+}
+
+func copyShoppingList(list *models.ShoppingList) models.ShoppingList {
+	result := *list
+	result.Items = slices.Clone(list.Items)
+
+	if result.Items == nil {
+		result.Items = []models.ShoppingListItem{}
+	}
+
+	return result
+}
+
+func copyShoppingLists(lists map[string][]*models.ShoppingList) map[string][]*models.ShoppingList {
+	result := make(map[string][]*models.ShoppingList, len(lists))
+	for userID, userLists := range lists {
+		result[userID] = make([]*models.ShoppingList, 0, len(userLists))
+		for _, list := range userLists {
+			copied := copyShoppingList(list)
+			result[userID] = append(result[userID], &copied)
+		}
+	}
+
+	return result
+}
+
+// ExportUserData возвращает списки покупок пользователя
+func (s *ShoppingListService) ExportUserData(ctx context.Context) (any, error) {
+	return s.GetLists(ctx), nil
+}
+
+func (s *ShoppingListService) GetUserExportName() string {
+	return "shopping_lists"
+}
@@ -0,0 +1,126 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"eats-backend/internal/models"
+	"eats-backend/internal/service"
+)
+
+type testListCart struct {
+	added map[string]int
+	err   error
+}
+
+func (c *testListCart) AddQuantity(_ context.Context, productID string, quantity int) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	c.added[productID] += quantity
+
+	return c.added[productID], nil
+}
+
+func TestShoppingListService(t *testing.T) {
+	products := testCartProducts{
+		"bread": {ID: "bread", Available: true},
+		"milk":  {ID: "milk", Available: true},
+		"cake":  {ID: "cake"},
+	}
+	cart := &testListCart{added: make(map[string]int)}
+
+	lists := service.NewShoppingListService(products, cart, map[string][]*models.ShoppingList{
+		"alice": {{ID: "seed", Name: "Завтрак", Items: []models.ShoppingListItem{{ProductID: "bread", Quantity: 1}}}},
+	})
+
+	alice := walletContext(t, "alice")
+	bob := walletContext(t, "bob")
+
+	for _, req := range []models.ShoppingListRequest{
+		{Name: "  "},
+		{Name: strings.Repeat("я", 101)},
+		{Name: "Ужин", Items: []models.ShoppingListItem{{ProductID: "bread", Quantity: 0}}},
+		{Name: "Ужин", Items: []models.ShoppingListItem{{ProductID: "missing", Quantity: 1}}},
+	} {
+		_, err := lists.CreateList(alice, req)
+		require.ErrorIs(t, err, models.ErrBadRequest)
+	}
+
+	// Повторы товара складываются в одну позицию
+	list, err := lists.CreateList(alice, models.ShoppingListRequest{Name: " Ужин ", Items: []models.ShoppingListItem{
+		{ProductID: "milk", Quantity: 1},
+		{ProductID: "cake", Quantity: 1},
+		{ProductID: "milk", Quantity: 2},
+	}})
+	require.NoError(t, err)
+	require.Equal(t, "Ужин", list.Name)
+	require.Equal(t, []models.ShoppingListItem{{ProductID: "milk", Quantity: 3}, {ProductID: "cake", Quantity: 1}}, list.Items)
+
+	all := lists.GetLists(alice)
+	require.Len(t, all, 2)
+	require.Equal(t, "seed", all[0].ID)
+
+	// Чужой список не виден
+	_, err = lists.GetList(bob, list.ID)
+	require.ErrorIs(t, err, models.ErrNotFound)
+	require.ErrorIs(t, lists.DeleteList(bob, list.ID), models.ErrNotFound)
+
+	updated, err := lists.UpdateList(alice, list.ID, models.ShoppingListRequest{Name: "Ужин на двоих", Items: []models.ShoppingListItem{
+		{ProductID: "milk", Quantity: 2},
+		{ProductID: "cake", Quantity: 1},
+	}})
+	require.NoError(t, err)
+	require.Equal(t, "Ужин на двоих", updated.Name)
+	require.Equal(t, list.CreatedAt, updated.CreatedAt)
+
+	_, err = lists.UpdateList(alice, "missing", models.ShoppingListRequest{Name: "Ужин"})
+	require.ErrorIs(t, err, models.ErrNotFound)
+
+	// Товары не в наличии и удаленные из каталога пропускаются, список не меняется
+	delete(products, "milk")
+
+	result, err := lists.AddToCart(alice, list.ID)
+	require.NoError(t, err)
+	require.Empty(t, result.Added)
+	require.Equal(t, []models.SkippedItem{
+		{ProductID: "milk", Quantity: 2, Reason: models.SkipReasonNotFound},
+		{ProductID: "cake", Quantity: 1, Reason: models.SkipReasonUnavailable},
+	}, result.Skipped)
+
+	products["cake"] = models.Product{ID: "cake", Available: true}
+
+	result, err = lists.AddToCart(alice, list.ID)
+	require.NoError(t, err)
+	require.Equal(t, []models.ShoppingListItem{{ProductID: "cake", Quantity: 1}}, result.Added)
+	require.Equal(t, map[string]int{"cake": 1}, cart.added)
+
+	stored, err := lists.GetList(alice, list.ID)
+	require.NoError(t, err)
+	require.Len(t, stored.Items, 2)
+
+	cart.err = errors.New("cart is down")
+	_, err = lists.AddToCart(alice, list.ID)
+	require.Error(t, err)
+
+	// Бэкап и сброс пользователя
+	data, err := json.Marshal(lists.GetBackupData())
+	require.NoError(t, err)
+
+	require.NoError(t, lists.DeleteList(alice, list.ID))
+	require.Len(t, lists.GetLists(alice), 1)
+
+	require.NoError(t, lists.RestoreBackupData(data))
+	require.Len(t, lists.GetLists(alice), 2)
+
+	lists.ResetUser("alice")
+	all = lists.GetLists(alice)
+	require.Len(t, all, 1)
+	require.Equal(t, "seed", all[0].ID)
+}
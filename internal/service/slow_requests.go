@@ -0,0 +1,90 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"eats-backend/internal/models"
+)
+
+// SlowRequestLog считает медленные запросы по маршрутам и хранит самые долгие из них,
+// чтобы найти конкуренцию за блокировки в сервисах. Данные живут только в памяти.
+type SlowRequestLog struct {
+	threshold time.Duration
+	keep      int
+
+	total   int64
+	byRoute map[string]int64
+	// Самые долгие запросы, худшие первыми
+	worst []models.SlowRequest
+
+	mux sync.Mutex
+}
+
+func NewSlowRequestLog(threshold time.Duration, keep int) *SlowRequestLog {
+	return &SlowRequestLog{
+		threshold: threshold,
+		keep:      keep,
+		byRoute:   make(map[string]int64),
+	}
+}
+
+// Threshold порог медленного запроса, 0 - отслеживание выключено
+func (l *SlowRequestLog) Threshold() time.Duration {
+	return l.threshold
+}
+
+// Record учитывает запрос, превысивший порог
+func (l *SlowRequestLog) Record(request models.SlowRequest) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	l.total++
+	l.byRoute[request.Route]++
+
+	if len(l.worst) == l.keep && request.DurationMs <= l.worst[len(l.worst)-1].DurationMs {
+		return
+	}
+
+	index, _ := slices.BinarySearchFunc(l.worst, request.DurationMs, func(item models.SlowRequest, duration float64) int {
+		return cmp.Compare(duration, item.DurationMs)
+	})
+	l.worst = slices.Insert(l.worst, index, request)
+
+	if len(l.worst) > l.keep {
+		l.worst = l.worst[:l.keep]
+	}
+}
+
+// GetReport возвращает счетчики и самые долгие запросы
+func (l *SlowRequestLog) GetReport(_ context.Context) models.SlowRequestsReport {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	worst := make([]models.SlowRequest, len(l.worst))
+	for i, request := range l.worst {
+		worst[i] = request
+		worst[i].Timings = maps.Clone(request.Timings)
+	}
+
+	return models.SlowRequestsReport{
+		ThresholdMs: float64(l.threshold.Microseconds()) / 1000,
+		Total:       l.total,
+		ByRoute:     maps.Clone(l.byRoute),
+		Worst:       worst,
+	}
+}
+
+// Clear сбрасывает счетчики, например после исправления и перед новым замером
+func (l *SlowRequestLog) Clear(_ context.Context) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	l.total = 0
+	l.byRoute = make(map[string]int64)
+	l.worst = nil
+}
@@ -0,0 +1,48 @@
+package service_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"eats-backend/internal/models"
+	"eats-backend/internal/service"
+)
+
+func TestSlowRequestLog(t *testing.T) {
+	log := service.NewSlowRequestLog(100*time.Millisecond, 2)
+
+	for _, request := range []models.SlowRequest{
+		{RequestID: "1", Route: "GET /wallet", DurationMs: 150},
+		{RequestID: "2", Route: "GET /products", DurationMs: 900},
+		{RequestID: "3", Route: "GET /wallet", DurationMs: 300},
+		{RequestID: "4", Route: "GET /wallet", DurationMs: 120},
+	} {
+		log.Record(request)
+	}
+
+	report := log.GetReport(t.Context())
+	require.Equal(t, 100.0, report.ThresholdMs)
+	require.Equal(t, int64(4), report.Total)
+	require.Equal(t, map[string]int64{"GET /wallet": 3, "GET /products": 1}, report.ByRoute)
+	// Хранятся только самые долгие, худшие первыми
+	require.Len(t, report.Worst, 2)
+	require.Equal(t, "2", report.Worst[0].RequestID)
+	require.Equal(t, "3", report.Worst[1].RequestID)
+
+	log.Clear(t.Context())
+	require.Zero(t, log.GetReport(t.Context()).Total)
+	require.Empty(t, log.GetReport(t.Context()).Worst)
+}
+
+func TestObserveTiming(t *testing.T) {
+	// Без сборщика в контексте время никуда не пишется
+	models.ObserveTiming(t.Context(), "wallet.lock_wait", time.Now())
+
+	ctx, timings := models.ContextWithTimings(t.Context())
+	models.ObserveTiming(ctx, "wallet.lock_wait", time.Now().Add(-time.Second))
+	models.ObserveTiming(ctx, "wallet.lock_wait", time.Now().Add(-time.Second))
+
+	require.GreaterOrEqual(t, timings.Durations()["wallet.lock_wait"], 2*time.Second)
+}
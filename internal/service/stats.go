@@ -0,0 +1,245 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"eats-backend/internal/models"
+)
+
+const (
+	// Сколько дней хранится статистика по дням.
+	maxStatsDays    = 90
+	topProductsSize = 10
+)
+
+type dailyCounters struct {
+	orders  int
+	revenue models.Money
+	users   map[string]struct{}
+}
+
+// StatsService собирает агрегаты по событиям заказов, корзин и кошелька. Запрос статистики
+// не обходит данные других сервисов и не берет их блокировки.
+type StatsService struct {
+	since time.Time
+
+	orders   int
+	revenue  models.Money
+	days     map[string]*dailyCounters // дата -> счетчики
+	products map[string]*models.ProductStats
+	lastSeen map[string]time.Time // userID -> время последней активности
+	carts    map[string]map[string]int
+	wallet   map[models.TransactionCategory]models.Money
+
+	mux sync.RWMutex
+}
+
+func NewStatsService(
+	initialOrders map[string][]*models.Order,
+	initialCarts map[string]map[string]*models.CartItem,
+) *StatsService {
+	service := &StatsService{
+		since:    time.Now(),
+		days:     make(map[string]*dailyCounters),
+		products: make(map[string]*models.ProductStats),
+		lastSeen: make(map[string]time.Time),
+		carts:    cartQuantities(initialCarts),
+		wallet:   make(map[models.TransactionCategory]models.Money),
+	}
+
+	for _, orders := range initialOrders {
+		for _, order := range orders {
+			service.addOrder(*order)
+		}
+	}
+
+	return service
+}
+
+// OrderPlaced учитывает новый заказ
+func (s *StatsService) OrderPlaced(userID string, order models.Order) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.addOrder(order)
+	s.touch(userID, order.CreatedAt)
+}
+
+// CartItemChanged запоминает новое количество товара в корзине, 0 - товар удален
+func (s *StatsService) CartItemChanged(userID, productID string, quantity int) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if quantity <= 0 {
+		delete(s.carts[userID], productID)
+	} else {
+		if s.carts[userID] == nil {
+			s.carts[userID] = make(map[string]int)
+		}
+
+		s.carts[userID][productID] = quantity
+	}
+
+	if len(s.carts[userID]) == 0 {
+		delete(s.carts, userID)
+	}
+
+	s.touch(userID, time.Now())
+}
+
+// CartReplaced запоминает корзину, замененную целиком, например после заказа или сброса
+func (s *StatsService) CartReplaced(userID string, items map[string]int) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if len(items) == 0 {
+		delete(s.carts, userID)
+
+		return
+	}
+
+	s.carts[userID] = maps.Clone(items)
+}
+
+// WalletOperation учитывает операцию кошелька. amount - сумма операции без знака.
+func (s *StatsService) WalletOperation(userID string, category models.TransactionCategory, amount models.Money) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.wallet[category] += amount
+	s.touch(userID, time.Now())
+}
+
+// addOrder вызывается под блокировкой. Заказы без даты (из файла данных) не попадают в статистику по дням.
+func (s *StatsService) addOrder(order models.Order) {
+	s.orders++
+	s.revenue += order.TotalPrice
+
+	if !order.CreatedAt.IsZero() {
+		day := s.day(order.CreatedAt)
+		day.orders++
+		day.revenue += order.TotalPrice
+	}
+
+	for _, item := range order.Items {
+		product, ok := s.products[item.ID]
+		if !ok {
+			product = &models.ProductStats{ProductID: item.ID}
+			s.products[item.ID] = product
+		}
+
+		product.Name = item.Name
+		product.Quantity += item.Quantity
+		product.Revenue += item.Price.Mul(item.Quantity)
+	}
+}
+
+// touch отмечает активность пользователя, вызывается под блокировкой
+func (s *StatsService) touch(userID string, at time.Time) {
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	if at.After(s.lastSeen[userID]) {
+		s.lastSeen[userID] = at
+	}
+
+	day := s.day(at)
+	if day.users == nil {
+		day.users = make(map[string]struct{})
+	}
+
+	day.users[userID] = struct{}{}
+}
+
+// day возвращает счетчики дня и удаляет дни старше maxStatsDays, вызывается под блокировкой
+func (s *StatsService) day(at time.Time) *dailyCounters {
+	date := at.Format(time.DateOnly)
+
+	counters, ok := s.days[date]
+	if !ok {
+		counters = &dailyCounters{}
+		s.days[date] = counters
+
+		oldest := time.Now().AddDate(0, 0, -maxStatsDays).Format(time.DateOnly)
+		maps.DeleteFunc(s.days, func(date string, _ *dailyCounters) bool { return date < oldest })
+	}
+
+	return counters
+}
+
+// GetStats возвращает статистику, по дням - за последние days дней
+func (s *StatsService) GetStats(_ context.Context, days int) (models.Stats, error) {
+	if days <= 0 || days > maxStatsDays {
+		return models.Stats{}, fmt.Errorf("%w: days must be between 1 and %d", models.ErrBadRequest, maxStatsDays)
+	}
+
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	now := time.Now()
+	result := models.Stats{
+		Since:        s.since,
+		Orders:       s.orders,
+		Revenue:      s.revenue,
+		Days:         make([]models.DailyStats, 0, days),
+		WalletVolume: maps.Clone(s.wallet),
+	}
+
+	if s.orders > 0 {
+		result.AverageOrder = s.revenue.MulDiv(1, int64(s.orders))
+	}
+
+	for i := days - 1; i >= 0; i-- {
+		date := now.AddDate(0, 0, -i).Format(time.DateOnly)
+		daily := models.DailyStats{Date: date}
+
+		if counters, ok := s.days[date]; ok {
+			daily.Orders = counters.orders
+			daily.Revenue = counters.revenue
+			daily.ActiveUsers = len(counters.users)
+		}
+
+		result.Days = append(result.Days, daily)
+	}
+
+	products := make([]models.ProductStats, 0, len(s.products))
+	for _, product := range s.products {
+		products = append(products, *product)
+	}
+
+	slices.SortFunc(products, func(a, b models.ProductStats) int {
+		return cmp.Or(cmp.Compare(b.Quantity, a.Quantity), cmp.Compare(a.ProductID, b.ProductID))
+	})
+	result.TopProducts = products[:min(len(products), topProductsSize)]
+
+	for _, seen := range s.lastSeen {
+		if now.Sub(seen) <= 24*time.Hour {
+			result.ActiveUsers.Day++
+		}
+
+		if now.Sub(seen) <= 7*24*time.Hour {
+			result.ActiveUsers.Week++
+		}
+	}
+
+	units := 0
+	for _, cart := range s.carts {
+		for _, quantity := range cart {
+			units += quantity
+		}
+	}
+
+	result.Carts = len(s.carts)
+	if result.Carts > 0 {
+		result.AverageCartSize = float64(units) / float64(result.Carts)
+	}
+
+	return result, nil
+}
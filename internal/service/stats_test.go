@@ -0,0 +1,63 @@
+package service_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"eats-backend/internal/models"
+	"eats-backend/internal/service"
+)
+
+func TestStatsService_GetStats(t *testing.T) {
+	stats := service.NewStatsService(
+		map[string][]*models.Order{
+			"alice": {{TotalPrice: models.Rubles(300), Items: []models.OrderItem{{ID: "milk", Name: "Молоко", Price: models.Rubles(100), Quantity: 3}}}},
+		},
+		map[string]map[string]*models.CartItem{
+			"alice": {"milk": {ProductID: "milk", Quantity: 2}},
+		},
+	)
+
+	stats.OrderPlaced("bob", models.Order{
+		TotalPrice: models.Rubles(500),
+		CreatedAt:  time.Now(),
+		Items: []models.OrderItem{
+			{ID: "bread", Name: "Хлеб", Price: models.Rubles(50), Quantity: 4},
+			{ID: "milk", Name: "Молоко", Price: models.Rubles(100), Quantity: 3},
+		},
+	})
+	stats.CartItemChanged("bob", "bread", 4)
+	stats.CartItemChanged("alice", "milk", 0)
+	stats.WalletOperation("bob", models.TransactionCategoryTopup, models.Rubles(1000))
+	stats.WalletOperation("bob", models.TransactionCategoryFood, models.Rubles(500))
+
+	result, err := stats.GetStats(t.Context(), 3)
+	require.NoError(t, err)
+
+	require.Equal(t, 2, result.Orders)
+	require.Equal(t, models.Rubles(800), result.Revenue)
+	require.Equal(t, models.Rubles(400), result.AverageOrder)
+
+	// Заказ из файла данных без даты не попадает в статистику по дням
+	require.Len(t, result.Days, 3)
+	require.Equal(t, time.Now().Format(time.DateOnly), result.Days[2].Date)
+	require.Equal(t, 1, result.Days[2].Orders)
+	require.Equal(t, 2, result.Days[2].ActiveUsers)
+
+	require.Equal(t, "milk", result.TopProducts[0].ProductID)
+	require.Equal(t, 6, result.TopProducts[0].Quantity)
+	require.Equal(t, models.Rubles(600), result.TopProducts[0].Revenue)
+
+	require.Equal(t, 1, result.Carts)
+	require.InDelta(t, 4.0, result.AverageCartSize, 0.001)
+	require.Equal(t, 2, result.ActiveUsers.Day)
+	require.Equal(t, map[models.TransactionCategory]models.Money{
+		models.TransactionCategoryTopup: models.Rubles(1000),
+		models.TransactionCategoryFood:  models.Rubles(500),
+	}, result.WalletVolume)
+
+	_, err = stats.GetStats(t.Context(), 0)
+	require.ErrorIs(t, err, models.ErrBadRequest)
+}
@@ -0,0 +1,449 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"eats-backend/internal/models"
+)
+
+// После стольких неудачных попыток подряд подписка ставится на паузу.
+const maxSubscriptionFailures = 3
+
+type SubscriptionOrders interface {
+	GetOrder(ctx context.Context, id string) (models.Order, error)
+	PlaceWalletOrder(ctx context.Context, order models.Order) (models.Order, error)
+}
+
+type SubscriptionNotifier interface {
+	SubscriptionCharged(ctx context.Context, userID string, order models.Order)
+	SubscriptionFailed(ctx context.Context, userID string, subscription models.Subscription)
+}
+
+// SubscriptionService хранит подписки на повторяющиеся заказы и по расписанию
+// создает заказы с оплатой из кошелька.
+type SubscriptionService struct {
+	orders   SubscriptionOrders
+	products ProductService
+	delivery CartDelivery
+	notifier SubscriptionNotifier
+	clock    Clock
+	logger   *zap.SugaredLogger
+	interval time.Duration
+
+	subscriptions map[string]map[string]*models.Subscription // userID -> subscriptionID -> subscription
+
+	mux sync.RWMutex
+}
+
+func NewSubscriptionService(
+	orders SubscriptionOrders,
+	products ProductService,
+	delivery CartDelivery,
+	notifier SubscriptionNotifier,
+	clock Clock,
+	logger *zap.SugaredLogger,
+	interval time.Duration,
+	initialData map[string][]*models.Subscription,
+) *SubscriptionService {
+	service := &SubscriptionService{
+		orders:        orders,
+		products:      products,
+		delivery:      delivery,
+		notifier:      notifier,
+		clock:         clockOrSystem(clock),
+		logger:        logger,
+		interval:      interval,
+		subscriptions: make(map[string]map[string]*models.Subscription),
+	}
+
+	for userID, subscriptions := range initialData {
+		service.subscriptions[userID] = make(map[string]*models.Subscription, len(subscriptions))
+		for _, subscription := range subscriptions {
+			copied := copySubscription(subscription)
+			service.subscriptions[userID][subscription.ID] = &copied
+		}
+	}
+
+	return service
+}
+
+// GetSubscriptions возвращает подписки пользователя в порядке создания, включая отмененные
+func (s *SubscriptionService) GetSubscriptions(ctx context.Context) []models.Subscription {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	result := make([]models.Subscription, 0, len(s.subscriptions[userID]))
+	for _, subscription := range s.subscriptions[userID] {
+		result = append(result, copySubscription(subscription))
+	}
+
+	slices.SortFunc(result, func(a, b models.Subscription) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), cmp.Compare(a.ID, b.ID))
+	})
+
+	return result
+}
+
+// Create превращает прошлый заказ в подписку. Первый заказ по ней будет создан через один интервал.
+func (s *SubscriptionService) Create(ctx context.Context, req models.SubscriptionRequest) (models.Subscription, error) {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	if req.Interval != models.SubscriptionWeekly && req.Interval != models.SubscriptionMonthly {
+		return models.Subscription{}, fmt.Errorf("%w: interval must be weekly or monthly", models.ErrBadRequest)
+	}
+
+	if req.OrderID == "" {
+		return models.Subscription{}, fmt.Errorf("%w: orderId is required", models.ErrBadRequest)
+	}
+
+	order, err := s.orders.GetOrder(ctx, req.OrderID)
+	if err != nil {
+		return models.Subscription{}, fmt.Errorf("get order: %w", err)
+	}
+
+	items := make([]models.CartItem, 0, len(order.Items))
+	for _, item := range order.Items {
+		items = append(items, models.CartItem{ProductID: item.ID, Quantity: item.Quantity})
+	}
+
+	now := s.clock.Now()
+	subscription := &models.Subscription{
+		ID:            uuid.NewString(),
+		SourceOrderID: order.ID,
+		Interval:      req.Interval,
+		Status:        models.SubscriptionActive,
+		Address:       order.Address,
+		Items:         items,
+		NextRunAt:     nextRun(now, req.Interval),
+		CreatedAt:     now,
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.subscriptions[userID] == nil {
+		s.subscriptions[userID] = make(map[string]*models.Subscription)
+	}
+
+	s.subscriptions[userID][subscription.ID] = subscription
+
+	return copySubscription(subscription), nil
+}
+
+func (s *SubscriptionService) Pause(ctx context.Context, id string) (models.Subscription, error) {
+	return s.update(ctx, id, func(subscription *models.Subscription) error {
+		if subscription.Status != models.SubscriptionActive {
+			return fmt.Errorf("%w: subscription is %s", models.ErrBadRequest, subscription.Status)
+		}
+
+		subscription.Status = models.SubscriptionPaused
+
+		return nil
+	})
+}
+
+// Resume возобновляет подписку. Если дата следующего заказа прошла за время паузы,
+// она переносится на один интервал от текущего момента.
+func (s *SubscriptionService) Resume(ctx context.Context, id string) (models.Subscription, error) {
+	return s.update(ctx, id, func(subscription *models.Subscription) error {
+		if subscription.Status != models.SubscriptionPaused {
+			return fmt.Errorf("%w: subscription is %s", models.ErrBadRequest, subscription.Status)
+		}
+
+		now := s.clock.Now()
+		if subscription.NextRunAt.Before(now) {
+			subscription.NextRunAt = nextRun(now, subscription.Interval)
+		}
+
+		subscription.Status = models.SubscriptionActive
+		subscription.FailedAttempts = 0
+
+		return nil
+	})
+}
+
+// Cancel отменяет подписку. Отмененную подписку нельзя возобновить, она остается в списке для истории.
+func (s *SubscriptionService) Cancel(ctx context.Context, id string) (models.Subscription, error) {
+	return s.update(ctx, id, func(subscription *models.Subscription) error {
+		if subscription.Status == models.SubscriptionCancelled {
+			return fmt.Errorf("%w: subscription is already cancelled", models.ErrBadRequest)
+		}
+
+		subscription.Status = models.SubscriptionCancelled
+
+		return nil
+	})
+}
+
+func (s *SubscriptionService) update(
+	ctx context.Context,
+	id string,
+	change func(subscription *models.Subscription) error,
+) (models.Subscription, error) {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	subscription, ok := s.subscriptions[userID][id]
+	if !ok {
+		return models.Subscription{}, fmt.Errorf("%w: subscription not found", models.ErrNotFound)
+	}
+
+	if err := change(subscription); err != nil {
+		return models.Subscription{}, err
+	}
+
+	return copySubscription(subscription), nil
+}
+
+// Start проверяет подписки с заданным интервалом до отмены контекста
+func (s *SubscriptionService) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.RunDue(ctx, s.clock.Now())
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+type dueSubscription struct {
+	userID       string
+	subscription models.Subscription
+}
+
+// RunDue создает заказы по всем активным подпискам, срок которых наступил к now.
+func (s *SubscriptionService) RunDue(ctx context.Context, now time.Time) {
+	s.mux.RLock()
+
+	due := make([]dueSubscription, 0)
+	for userID, subscriptions := range s.subscriptions {
+		for _, subscription := range subscriptions {
+			if subscription.Status == models.SubscriptionActive && !subscription.NextRunAt.After(now) {
+				due = append(due, dueSubscription{userID: userID, subscription: copySubscription(subscription)})
+			}
+		}
+	}
+
+	s.mux.RUnlock()
+
+	for _, item := range due {
+		if ctx.Err() != nil {
+			return
+		}
+
+		s.run(ctx, item.userID, item.subscription, now)
+	}
+}
+
+// run создает один заказ по подписке. Оплата и заказ выполняются без блокировки сервиса,
+// результат записывается, только если подписку за это время не поставили на паузу и не отменили.
+func (s *SubscriptionService) run(ctx context.Context, userID string, subscription models.Subscription, now time.Time) {
+	userCtx := models.ContextWithUser(context.WithoutCancel(ctx), userID)
+
+	order, err := s.placeOrder(userCtx, subscription)
+
+	s.mux.Lock()
+
+	current, ok := s.subscriptions[userID][subscription.ID]
+	if !ok || current.Status != models.SubscriptionActive || !current.NextRunAt.Equal(subscription.NextRunAt) {
+		s.mux.Unlock()
+
+		return
+	}
+
+	for !current.NextRunAt.After(now) {
+		current.NextRunAt = nextRun(current.NextRunAt, current.Interval)
+	}
+
+	if err == nil {
+		current.LastOrderID = order.ID
+		current.LastError = ""
+		current.FailedAttempts = 0
+	} else {
+		current.LastError = failureReason(err)
+		current.FailedAttempts++
+
+		if current.FailedAttempts >= maxSubscriptionFailures {
+			current.Status = models.SubscriptionPaused
+		}
+	}
+
+	result := copySubscription(current)
+
+	s.mux.Unlock()
+
+	if err != nil {
+		s.logger.Warnw("Subscription order failed",
+			"subscriptionId", subscription.ID, "userId", userID, "attempts", result.FailedAttempts, "error", err)
+		s.notifier.SubscriptionFailed(userCtx, userID, result)
+
+		return
+	}
+
+	s.logger.Infow("Subscription order placed", "subscriptionId", subscription.ID, "userId", userID, "orderId", order.ID)
+	s.notifier.SubscriptionCharged(userCtx, userID, order)
+}
+
+// placeOrder собирает заказ по текущим ценам каталога. Товары не в наличии и удаленные из каталога пропускаются.
+func (s *SubscriptionService) placeOrder(ctx context.Context, subscription models.Subscription) (models.Order, error) {
+	order := models.Order{
+		Address:        subscription.Address,
+		Items:          make([]models.OrderItem, 0, len(subscription.Items)),
+		SubscriptionID: subscription.ID,
+	}
+
+	for _, item := range subscription.Items {
+		product, err := s.products.GetProductByID(ctx, item.ProductID)
+		if errors.Is(err, models.ErrNotFound) {
+			continue
+		}
+
+		if err != nil {
+			return models.Order{}, fmt.Errorf("get product %s: %w", item.ProductID, err)
+		}
+
+		if !product.Available {
+			continue
+		}
+
+		order.Items = append(order.Items, models.OrderItem{
+			ID:       product.ID,
+			Image:    product.Image,
+			Name:     product.Name,
+			Weight:   product.Weight,
+			Price:    product.Price,
+			Quantity: item.Quantity,
+		})
+		order.OrderPrice += product.Price.Mul(item.Quantity)
+		order.TotalItems += item.Quantity
+	}
+
+	if len(order.Items) == 0 {
+		return models.Order{}, fmt.Errorf("%w: no products from subscription are available", models.ErrBadRequest)
+	}
+
+	order.DeliveryPrice, _ = s.delivery.BaseDelivery(order.OrderPrice)
+	order.TotalPrice = order.OrderPrice + order.DeliveryPrice
+
+	return s.orders.PlaceWalletOrder(ctx, order)
+}
+
+// failureReason возвращает причину неудачи, которую можно показать пользователю.
+// Ошибки клиента (например, insufficient funds или дневной лимит) показываются как есть, внутренние скрываются.
+func failureReason(err error) string {
+	if errors.Is(err, models.ErrBadRequest) || errors.Is(err, models.ErrNotFound) || errors.Is(err, models.ErrTooManyRequests) {
+		return err.Error()
+	}
+
+	return "internal error"
+}
+
+func nextRun(from time.Time, interval models.SubscriptionInterval) time.Time {
+	if interval == models.SubscriptionMonthly {
+		return from.AddDate(0, 1, 0)
+	}
+
+	return from.AddDate(0, 0, 7)
+}
+
+// GetBackupData возвращает данные для бэкапа
+func (s *SubscriptionService) GetBackupData() interface{} {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	result := make(map[string][]*models.Subscription, len(s.subscriptions))
+	for userID, subscriptions := range s.subscriptions {
+		result[userID] = make([]*models.Subscription, 0, len(subscriptions))
+		for _, subscription := range subscriptions {
+			copied := copySubscription(subscription)
+			result[userID] = append(result[userID], &copied)
+		}
+	}
+
+	return result
+}
+
+// GetBackupFileName возвращает имя файла для бэкапа
+func (s *SubscriptionService) GetBackupFileName() string {
+	return "subscriptions"
+}
+
+// RestoreBackupData заменяет подписки данными из бэкапа
+func (s *SubscriptionService) RestoreBackupData(data []byte) error {
+	var backup map[string][]*models.Subscription
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return fmt.Errorf("can't parse subscriptions: %w", err)
+	}
+
+	subscriptions := make(map[string]map[string]*models.Subscription, len(backup))
+	for userID, userSubscriptions := range backup {
+		subscriptions[userID] = make(map[string]*models.Subscription, len(userSubscriptions))
+		for _, subscription := range userSubscriptions {
+			subscriptions[userID][subscription.ID] = subscription
+		}
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.subscriptions = subscriptions
+
+	return nil
+}
+
+// ResetUser удаляет подписки пользователя
+func (s *SubscriptionService) ResetUser(userID string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	delete(s.subscriptions, userID)
+}
+
+func (s *SubscriptionService) CollectionSizes(_ context.Context) map[string]int {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	subscriptions := 0
+	for _, userSubscriptions := range s.subscriptions {
+		subscriptions += len(userSubscriptions)
+	}
+
+	return map[string]int{
+		"subscriptions.users": len(s.subscriptions),
+		"subscriptions":       subscriptions,
+	}
+}
+
+func copySubscription(subscription *models.Subscription) models.Subscription {
+	result := *subscription
+	result.Items = slices.Clone(subscription.Items)
+
+	return result
+}
+
+// ExportUserData возвращает подписки пользователя на повторяющиеся заказы
+func (s *SubscriptionService) ExportUserData(ctx context.Context) (any, error) {
+	return s.GetSubscriptions(ctx), nil
+}
+
+func (s *SubscriptionService) GetUserExportName() string {
+	return "subscriptions"
+}
@@ -0,0 +1,141 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"eats-backend/internal/models"
+	"eats-backend/internal/service"
+)
+
+type testSubscriptionOrders struct {
+	placed []models.Order
+	err    error
+}
+
+func (o *testSubscriptionOrders) GetOrder(context.Context, string) (models.Order, error) {
+	return models.Order{}, models.ErrNotFound
+}
+
+func (o *testSubscriptionOrders) PlaceWalletOrder(_ context.Context, order models.Order) (models.Order, error) {
+	if o.err != nil {
+		return models.Order{}, o.err
+	}
+
+	order.ID = fmt.Sprintf("order-%d", len(o.placed)+1)
+	o.placed = append(o.placed, order)
+
+	return order, nil
+}
+
+type testSubscriptionNotifier struct {
+	charged []string
+	failed  []models.Subscription
+}
+
+func (n *testSubscriptionNotifier) SubscriptionCharged(_ context.Context, _ string, order models.Order) {
+	n.charged = append(n.charged, order.ID)
+}
+
+func (n *testSubscriptionNotifier) SubscriptionFailed(_ context.Context, _ string, subscription models.Subscription) {
+	n.failed = append(n.failed, subscription)
+}
+
+func TestSubscriptionService_RunDue(t *testing.T) {
+	now := time.Date(2025, time.March, 3, 12, 0, 0, 0, time.UTC)
+
+	products := testCartProducts{
+		"bread": {ID: "bread", Price: models.Rubles(100), Available: true},
+		"cake":  {ID: "cake", Price: models.Rubles(500)},
+	}
+	orders := &testSubscriptionOrders{}
+	notifier := &testSubscriptionNotifier{}
+
+	subscription := func(id string, status models.SubscriptionStatus, items ...models.CartItem) *models.Subscription {
+		return &models.Subscription{
+			ID: id, Interval: models.SubscriptionWeekly, Status: status, Items: items, NextRunAt: now.Add(-time.Hour),
+		}
+	}
+
+	clock := service.NewFakeClock(now)
+	subscriptions := service.NewSubscriptionService(orders, products, testCartDelivery{price: models.Rubles(50)}, notifier,
+		clock, zap.NewNop().Sugar(), time.Hour, map[string][]*models.Subscription{
+			"alice": {
+				subscription("weekly", models.SubscriptionActive,
+					models.CartItem{ProductID: "bread", Quantity: 2},
+					models.CartItem{ProductID: "cake", Quantity: 1},
+					models.CartItem{ProductID: "deleted", Quantity: 1},
+				),
+				subscription("paused", models.SubscriptionPaused, models.CartItem{ProductID: "bread", Quantity: 1}),
+				subscription("cancelled", models.SubscriptionCancelled, models.CartItem{ProductID: "bread", Quantity: 1}),
+				subscription("unavailable", models.SubscriptionActive, models.CartItem{ProductID: "cake", Quantity: 1}),
+			},
+		})
+
+	ctx := walletContext(t, "alice")
+	byID := func() map[string]models.Subscription {
+		result := make(map[string]models.Subscription)
+		for _, subscription := range subscriptions.GetSubscriptions(ctx) {
+			result[subscription.ID] = subscription
+		}
+
+		return result
+	}
+
+	subscriptions.RunDue(t.Context(), now)
+
+	// Заказ собран без товаров не в наличии и удаленных из каталога, приостановленные и отмененные пропущены
+	require.Len(t, orders.placed, 1)
+	require.Equal(t, "weekly", orders.placed[0].SubscriptionID)
+	require.Equal(t, []models.OrderItem{{ID: "bread", Price: models.Rubles(100), Quantity: 2}}, orders.placed[0].Items)
+	require.Equal(t, models.Rubles(250), orders.placed[0].TotalPrice)
+	require.Equal(t, []string{"order-1"}, notifier.charged)
+
+	state := byID()
+	require.Equal(t, "order-1", state["weekly"].LastOrderID)
+	require.Equal(t, now.Add(-time.Hour).AddDate(0, 0, 7), state["weekly"].NextRunAt)
+	require.Equal(t, now.Add(-time.Hour), state["paused"].NextRunAt)
+	require.Equal(t, now.Add(-time.Hour), state["cancelled"].NextRunAt)
+
+	// Подписка, из которой ничего нет в наличии, считается неудачной
+	require.Equal(t, 1, state["unavailable"].FailedAttempts)
+	require.Contains(t, state["unavailable"].LastError, "no products from subscription are available")
+	require.Equal(t, models.SubscriptionActive, state["unavailable"].Status)
+	require.Len(t, notifier.failed, 1)
+
+	// Повторный запуск до следующей даты ничего не делает
+	subscriptions.RunDue(t.Context(), now)
+	require.Len(t, orders.placed, 1)
+
+	// После трех неудач подряд подписка ставится на паузу
+	orders.err = fmt.Errorf("%w: insufficient funds", models.ErrBadRequest)
+
+	for week := 1; week <= 3; week++ {
+		subscriptions.RunDue(t.Context(), now.AddDate(0, 0, 7*week))
+	}
+
+	state = byID()
+	require.Equal(t, models.SubscriptionPaused, state["weekly"].Status)
+	require.Equal(t, 3, state["weekly"].FailedAttempts)
+	require.Equal(t, "bad request: insufficient funds", state["weekly"].LastError)
+	require.Equal(t, models.SubscriptionPaused, state["unavailable"].Status)
+
+	// На паузе заказы не создаются
+	orders.err = nil
+	subscriptions.RunDue(t.Context(), now.AddDate(0, 0, 28))
+	require.Len(t, orders.placed, 1)
+
+	// Пропущенная дата переносится на интервал от текущего момента
+	clock.Set(now.AddDate(0, 0, 40))
+
+	resumed, err := subscriptions.Resume(ctx, "weekly")
+	require.NoError(t, err)
+	require.Equal(t, models.SubscriptionActive, resumed.Status)
+	require.Zero(t, resumed.FailedAttempts)
+	require.Equal(t, now.AddDate(0, 0, 47), resumed.NextRunAt)
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"eats-backend/internal/models"
+)
+
+const maxSuspensionReasonLength = 500
+
+// SuspensionService хранит заблокированных преподавателями пользователей
+type SuspensionService struct {
+	// userID -> блокировка
+	suspensions map[string]*models.UserSuspension
+
+	mux sync.RWMutex
+}
+
+func NewSuspensionService(initialSuspensions []*models.UserSuspension) *SuspensionService {
+	s := &SuspensionService{}
+	s.load(initialSuspensions)
+
+	return s
+}
+
+func (s *SuspensionService) load(suspensions []*models.UserSuspension) {
+	s.suspensions = make(map[string]*models.UserSuspension, len(suspensions))
+	for _, suspension := range suspensions {
+		copied := *suspension
+		s.suspensions[suspension.UserID] = &copied
+	}
+}
+
+// BlockUser блокирует пользователя. Повторная блокировка меняет причину и время.
+func (s *SuspensionService) BlockUser(
+	ctx context.Context,
+	userID string,
+	req models.BlockUserRequest,
+) (models.UserSuspension, error) {
+	claims := models.ClaimsFromContext(ctx)
+
+	if userID == claims.ID {
+		return models.UserSuspension{}, fmt.Errorf("%w: you can't block yourself", models.ErrBadRequest)
+	}
+
+	reason := strings.TrimSpace(req.Reason)
+	if utf8.RuneCountInString(reason) > maxSuspensionReasonLength {
+		return models.UserSuspension{}, fmt.Errorf("%w: reason is longer than %d characters",
+			models.ErrBadRequest, maxSuspensionReasonLength)
+	}
+
+	suspension := &models.UserSuspension{
+		UserID:    userID,
+		Reason:    reason,
+		BlockedBy: claims.Nickname,
+		BlockedAt: time.Now(),
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.suspensions[userID] = suspension
+
+	return *suspension, nil
+}
+
+func (s *SuspensionService) UnblockUser(_ context.Context, userID string) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if _, ok := s.suspensions[userID]; !ok {
+		return fmt.Errorf("%w: user %s is not blocked", models.ErrNotFound, userID)
+	}
+
+	delete(s.suspensions, userID)
+
+	return nil
+}
+
+// GetSuspension возвращает блокировку пользователя, если она есть. Вызывается на каждый запрос.
+func (s *SuspensionService) GetSuspension(_ context.Context, userID string) (models.UserSuspension, bool) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	suspension, ok := s.suspensions[userID]
+	if !ok {
+		return models.UserSuspension{}, false
+	}
+
+	return *suspension, true
+}
+
+// ListSuspensions возвращает заблокированных пользователей, недавно заблокированные первыми
+func (s *SuspensionService) ListSuspensions(_ context.Context) []models.UserSuspension {
+	result := s.sorted()
+
+	slices.Reverse(result)
+
+	return result
+}
+
+// sorted возвращает блокировки от старых к новым
+func (s *SuspensionService) sorted() []models.UserSuspension {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	result := make([]models.UserSuspension, 0, len(s.suspensions))
+	for _, suspension := range s.suspensions {
+		result = append(result, *suspension)
+	}
+
+	slices.SortFunc(result, func(a, b models.UserSuspension) int {
+		return cmp.Or(a.BlockedAt.Compare(b.BlockedAt), cmp.Compare(a.UserID, b.UserID))
+	})
+
+	return result
+}
+
+func (s *SuspensionService) CollectionSizes(_ context.Context) map[string]int {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	return map[string]int{"user_suspensions": len(s.suspensions)}
+}
+
+// GetBackupData возвращает блокировки для бэкапа
+func (s *SuspensionService) GetBackupData() interface{} {
+	return s.sorted()
+}
+
+func (s *SuspensionService) GetBackupFileName() string {
+	return "user_suspensions"
+}
+
+// RestoreBackupData заменяет блокировки данными из бэкапа
+func (s *SuspensionService) RestoreBackupData(data []byte) error {
+	var backup []*models.UserSuspension
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return fmt.Errorf("can't parse user suspensions: %w", err)
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.load(backup)
+
+	return nil
+}
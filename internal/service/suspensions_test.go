@@ -0,0 +1,52 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+
+	"eats-backend/internal/models"
+	"eats-backend/internal/service"
+)
+
+func TestSuspensionService_BlockUser(t *testing.T) {
+	suspensions := service.NewSuspensionService(nil)
+
+	teacher := context.WithValue(t.Context(), models.ContextClaimsKey{}, &models.AuthTokenClaims{
+		RegisteredClaims: &jwt.RegisteredClaims{ID: "teacher-1"},
+		Nickname:         "teacher",
+		IsTeacher:        true,
+	})
+
+	_, err := suspensions.BlockUser(teacher, "teacher-1", models.BlockUserRequest{})
+	require.ErrorIs(t, err, models.ErrBadRequest)
+
+	blocked, err := suspensions.BlockUser(teacher, "user-1", models.BlockUserRequest{Reason: "  спам переводами "})
+	require.NoError(t, err)
+	require.Equal(t, "спам переводами", blocked.Reason)
+	require.Equal(t, "teacher", blocked.BlockedBy)
+
+	suspension, ok := suspensions.GetSuspension(t.Context(), "user-1")
+	require.True(t, ok)
+	require.Equal(t, blocked, suspension)
+
+	// Блокировка переживает рестарт через бэкап
+	backup, err := json.Marshal(suspensions.GetBackupData())
+	require.NoError(t, err)
+
+	restored := service.NewSuspensionService(nil)
+	require.NoError(t, restored.RestoreBackupData(backup))
+
+	suspension, ok = restored.GetSuspension(t.Context(), "user-1")
+	require.True(t, ok)
+	require.Equal(t, "спам переводами", suspension.Reason)
+
+	require.NoError(t, restored.UnblockUser(t.Context(), "user-1"))
+	require.ErrorIs(t, restored.UnblockUser(t.Context(), "user-1"), models.ErrNotFound)
+
+	_, ok = restored.GetSuspension(t.Context(), "user-1")
+	require.False(t, ok)
+}
@@ -1,32 +1,52 @@
 package service
 
 import (
+	"bufio"
 	"context"
 	"crypto/rsa"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"go.uber.org/zap"
 
 	"eats-backend/internal/models"
 )
 
+type TokenRevocationList interface {
+	IsRevoked(ctx context.Context, tokenID string) (bool, error)
+}
+
 type TokenService struct {
 	privateKey       *rsa.PrivateKey
 	keysListFilePath string
+	revokedTokens    TokenRevocationList
+	logger           *zap.SugaredLogger
 }
 
-func NewTokenService(privateKey *rsa.PrivateKey, filepath string) *TokenService {
+func NewTokenService(
+	privateKey *rsa.PrivateKey,
+	filepath string,
+	revokedTokens TokenRevocationList,
+	logger *zap.SugaredLogger,
+) *TokenService {
 	return &TokenService{
 		privateKey:       privateKey,
 		keysListFilePath: filepath,
+		revokedTokens:    revokedTokens,
+		logger:           logger,
 	}
 }
 
-func (t *TokenService) GenerateToken(ctx context.Context, username string, isTeacher bool) (string, error) {
+func (t *TokenService) GenerateToken(ctx context.Context, username string, role models.TokenRole) (string, error) {
 	teacherData := models.ClaimsFromContext(ctx)
 
 	if teacherData == nil {
@@ -45,10 +65,12 @@ func (t *TokenService) GenerateToken(ctx context.Context, username string, isTea
 			ID:     uuid.NewString(),
 		},
 		Nickname:  username,
-		IsTeacher: isTeacher,
+		IsTeacher: role == models.TokenRoleTeacher,
+		Role:      role,
 	}
 
-	claims.IssuedAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	now := time.Now()
+	claims.IssuedAt = jwt.NewNumericDate(now.Add(-time.Minute))
 
 	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
 
@@ -57,12 +79,114 @@ func (t *TokenService) GenerateToken(ctx context.Context, username string, isTea
 		return "", fmt.Errorf("failed to sign token: %w", err)
 	}
 
-	creationLog := fmt.Sprintf("%s;%s;%s;%t\n", issuer, username, claims.ID, isTeacher)
-	err = AppendFile(t.keysListFilePath, []byte(creationLog), 0600)
+	t.logger.Infow("Token issued",
+		"issuer", issuer,
+		"issuerTokenId", teacherData.ID,
+		"nickname", username,
+		"tokenId", claims.ID,
+		"role", role,
+	)
+
+	// Журнал выданных токенов: время;выдавший;id его токена;никнейм;id токена;роль
+	creationLog := fmt.Sprintf("%s;%s;%s;%s;%s;%s\n",
+		now.UTC().Format(time.RFC3339), issuer, teacherData.ID, username, claims.ID, role)
+
+	// Токен уже подписан, поэтому ошибка записи журнала не мешает его выдать
+	if err := AppendFile(t.keysListFilePath, []byte(creationLog), 0600); err != nil {
+		t.logger.Errorw("Can't write issued token to log", "tokenId", claims.ID, "error", err)
+	}
 
 	return tokenString, nil
 }
 
+// ListTokens возвращает токены из журнала выданных, новые первыми, с отметкой об отзыве
+func (t *TokenService) ListTokens(ctx context.Context) ([]models.IssuedToken, error) {
+	file, err := os.Open(t.keysListFilePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []models.IssuedToken{}, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: can't open issued tokens: %w", models.ErrInternalServer, err)
+	}
+	defer file.Close()
+
+	tokens := make([]models.IssuedToken, 0)
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		token, ok := parseIssuedToken(scanner.Text())
+		if !ok {
+			continue
+		}
+
+		token.Revoked, err = t.revokedTokens.IsRevoked(ctx, token.ID)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", models.ErrInternalServer, err)
+		}
+
+		tokens = append(tokens, token)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: can't read issued tokens: %w", models.ErrInternalServer, err)
+	}
+
+	// Журнал дописывается по порядку выдачи
+	slices.Reverse(tokens)
+
+	return tokens, nil
+}
+
+// parseIssuedToken разбирает строку журнала. Строки без времени и id токена выдавшего
+// остались от старого формата "выдавший;никнейм;id;преподаватель", до курьеров вместо роли
+// записывался признак преподавателя true/false.
+func parseIssuedToken(line string) (models.IssuedToken, bool) {
+	fields := strings.Split(strings.TrimSpace(line), ";")
+
+	var token models.IssuedToken
+
+	switch len(fields) {
+	case 4:
+		fields = slices.Insert(fields, 0, "")
+		fields = slices.Insert(fields, 2, "")
+	case 6:
+	default:
+		return models.IssuedToken{}, false
+	}
+
+	if fields[0] != "" {
+		issuedAt, err := time.Parse(time.RFC3339, fields[0])
+		if err != nil {
+			return models.IssuedToken{}, false
+		}
+
+		token.IssuedAt = issuedAt
+	}
+
+	switch role := models.TokenRole(fields[5]); role {
+	case models.TokenRoleStudent, models.TokenRoleTeacher, models.TokenRoleCourier:
+		token.Role = role
+	default:
+		isTeacher, err := strconv.ParseBool(fields[5])
+		if err != nil {
+			return models.IssuedToken{}, false
+		}
+
+		token.Role = models.TokenRoleStudent
+		if isTeacher {
+			token.Role = models.TokenRoleTeacher
+		}
+	}
+
+	token.IssuedBy = fields[1]
+	token.IssuedByTokenID = fields[2]
+	token.Nickname = fields[3]
+	token.ID = fields[4]
+
+	return token, true
+}
+
 func AppendFile(filename string, data []byte, perm os.FileMode) error {
 	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, perm)
 	if err != nil {
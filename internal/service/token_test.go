@@ -0,0 +1,69 @@
+package service_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"eats-backend/internal/models"
+	"eats-backend/internal/service"
+)
+
+type testRevocationList map[string]bool
+
+func (l testRevocationList) IsRevoked(_ context.Context, tokenID string) (bool, error) {
+	return l[tokenID], nil
+}
+
+func TestTokenService_ListTokens(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "created_tokens.csv")
+	// Строка старого формата без времени выдачи
+	require.NoError(t, os.WriteFile(path, []byte("teacher;old-student;old-id;false\n"), 0600))
+
+	revoked := testRevocationList{"old-id": true}
+	tokens := service.NewTokenService(key, path, revoked, zap.NewNop().Sugar())
+
+	ctx := context.WithValue(t.Context(), models.ContextClaimsKey{}, &models.AuthTokenClaims{
+		RegisteredClaims: &jwt.RegisteredClaims{ID: "bootstrap"},
+		Nickname:         "admin",
+		IsTeacher:        true,
+	})
+
+	_, err = tokens.GenerateToken(ctx, "new-teacher", models.TokenRoleTeacher)
+	require.NoError(t, err)
+
+	_, err = tokens.GenerateToken(ctx, "courier", models.TokenRoleCourier)
+	require.NoError(t, err)
+
+	list, err := tokens.ListTokens(t.Context())
+	require.NoError(t, err)
+	require.Len(t, list, 3)
+
+	require.Equal(t, models.TokenRoleCourier, list[0].Role)
+	list = list[1:]
+
+	require.Equal(t, "new-teacher", list[0].Nickname)
+	require.Equal(t, models.TokenRoleTeacher, list[0].Role)
+	require.Equal(t, "admin", list[0].IssuedBy)
+	require.Equal(t, "bootstrap", list[0].IssuedByTokenID)
+	require.False(t, list[0].IssuedAt.IsZero())
+	require.False(t, list[0].Revoked)
+
+	require.Equal(t, models.IssuedToken{
+		ID:       "old-id",
+		Nickname: "old-student",
+		Role:     models.TokenRoleStudent,
+		IssuedBy: "teacher",
+		Revoked:  true,
+	}, list[1])
+}
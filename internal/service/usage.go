@@ -0,0 +1,235 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"eats-backend/internal/models"
+)
+
+// UsageService считает вызовы API каждым пользователем по маршрутам, чтобы преподаватель
+// видел, какие сценарии приложение студента действительно вызывало и насколько успешно.
+type UsageService struct {
+	users map[string]*models.UserUsageState
+
+	mux sync.Mutex
+}
+
+func NewUsageService() *UsageService {
+	return &UsageService{
+		users: make(map[string]*models.UserUsageState),
+	}
+}
+
+// RecordRequest учитывает обработанный запрос пользователя. route - шаблон маршрута ServeMux,
+// запросы без пользователя или без найденного маршрута не учитываются.
+func (s *UsageService) RecordRequest(userID, nickname, route string, statusCode int, at time.Time) {
+	if userID == "" || route == "" {
+		return
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		user = &models.UserUsageState{Endpoints: make(map[string]models.UsageCounters)}
+		s.users[userID] = user
+	}
+
+	if nickname != "" {
+		user.Nickname = nickname
+	}
+
+	counters := user.Endpoints[route]
+	if counters.Requests == 0 {
+		counters.FirstCalledAt = at
+	}
+
+	counters.Requests++
+	counters.LastCalledAt = at
+
+	switch {
+	case statusCode >= http.StatusInternalServerError:
+		counters.ServerErrors++
+	case statusCode >= http.StatusBadRequest:
+		counters.ClientErrors++
+	}
+
+	user.Endpoints[route] = counters
+}
+
+// GetUserUsage возвращает вызовы API пользователя по маршрутам и разделам
+func (s *UsageService) GetUserUsage(_ context.Context, userID string) (models.UserUsage, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return models.UserUsage{}, fmt.Errorf("%w: no requests from user %s", models.ErrNotFound, userID)
+	}
+
+	usage := models.UserUsage{
+		UserID:    userID,
+		Nickname:  user.Nickname,
+		Sections:  make([]models.SectionUsage, 0),
+		Endpoints: make([]models.EndpointUsage, 0, len(user.Endpoints)),
+	}
+
+	sections := make(map[string]*models.SectionUsage)
+
+	for route, counters := range user.Endpoints {
+		errors := counters.ClientErrors + counters.ServerErrors
+
+		usage.Endpoints = append(usage.Endpoints, models.EndpointUsage{
+			Route:         route,
+			UsageCounters: counters,
+			SuccessRate:   successRate(counters.Requests, errors),
+		})
+
+		usage.Requests += counters.Requests
+		usage.Errors += errors
+
+		if usage.FirstActivity.IsZero() || counters.FirstCalledAt.Before(usage.FirstActivity) {
+			usage.FirstActivity = counters.FirstCalledAt
+		}
+
+		if counters.LastCalledAt.After(usage.LastActivity) {
+			usage.LastActivity = counters.LastCalledAt
+		}
+
+		name := routeSection(route)
+
+		section, ok := sections[name]
+		if !ok {
+			section = &models.SectionUsage{Section: name}
+			sections[name] = section
+		}
+
+		section.Requests += counters.Requests
+		section.Errors += errors
+		section.Endpoints++
+
+		if errors < counters.Requests {
+			section.SucceededEndpoints++
+		}
+
+		if counters.LastCalledAt.After(section.LastCalledAt) {
+			section.LastCalledAt = counters.LastCalledAt
+		}
+	}
+
+	usage.SuccessRate = successRate(usage.Requests, usage.Errors)
+
+	for _, section := range sections {
+		section.SuccessRate = successRate(section.Requests, section.Errors)
+		usage.Sections = append(usage.Sections, *section)
+	}
+
+	slices.SortFunc(usage.Sections, func(a, b models.SectionUsage) int { return strings.Compare(a.Section, b.Section) })
+	slices.SortFunc(usage.Endpoints, func(a, b models.EndpointUsage) int { return strings.Compare(a.Route, b.Route) })
+
+	return usage, nil
+}
+
+// routeSection первый сегмент пути маршрута: "POST /cart/items" -> "cart"
+func routeSection(route string) string {
+	_, path, found := strings.Cut(route, " ")
+	if !found {
+		path = route
+	}
+
+	section, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	if section == "" || section == "{$}" {
+		return "/"
+	}
+
+	return section
+}
+
+// successRate доля запросов без ошибок, округленная до тысячных
+func successRate(requests, errors int64) float64 {
+	if requests == 0 {
+		return 0
+	}
+
+	return math.Round(float64(requests-errors)/float64(requests)*1000) / 1000
+}
+
+func (s *UsageService) ResetUser(userID string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	delete(s.users, userID)
+}
+
+// GetBackupData возвращает данные для бэкапа
+func (s *UsageService) GetBackupData() interface{} {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	backup := make(map[string]models.UserUsageState, len(s.users))
+	for userID, user := range s.users {
+		endpoints := make(map[string]models.UsageCounters, len(user.Endpoints))
+		for route, counters := range user.Endpoints {
+			endpoints[route] = counters
+		}
+
+		backup[userID] = models.UserUsageState{Nickname: user.Nickname, Endpoints: endpoints}
+	}
+
+	return backup
+}
+
+func (s *UsageService) GetBackupFileName() string {
+	return "api_usage"
+}
+
+// RestoreBackupData заменяет счетчики данными из бэкапа
+func (s *UsageService) RestoreBackupData(data []byte) error {
+	var backup map[string]*models.UserUsageState
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return fmt.Errorf("can't parse api usage: %w", err)
+	}
+
+	for userID, user := range backup {
+		if user == nil {
+			delete(backup, userID)
+
+			continue
+		}
+
+		if user.Endpoints == nil {
+			user.Endpoints = make(map[string]models.UsageCounters)
+		}
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.users = backup
+	if s.users == nil {
+		s.users = make(map[string]*models.UserUsageState)
+	}
+
+	return nil
+}
+
+func (s *UsageService) CollectionSizes(_ context.Context) map[string]int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	endpoints := 0
+	for _, user := range s.users {
+		endpoints += len(user.Endpoints)
+	}
+
+	return map[string]int{"api_usage_users": len(s.users), "api_usage_endpoints": endpoints}
+}
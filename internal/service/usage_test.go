@@ -0,0 +1,78 @@
+package service_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"eats-backend/internal/models"
+	"eats-backend/internal/service"
+)
+
+func TestUsageService_GetUserUsage(t *testing.T) {
+	usage := service.NewUsageService()
+	start := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	usage.RecordRequest("user-1", "student", "POST /cart/items", http.StatusOK, start)
+	usage.RecordRequest("user-1", "student", "POST /cart/items", http.StatusBadRequest, start.Add(time.Minute))
+	usage.RecordRequest("user-1", "", "GET /cart", http.StatusOK, start.Add(2*time.Minute))
+	usage.RecordRequest("user-1", "student", "POST /orders", http.StatusInternalServerError, start.Add(3*time.Minute))
+	usage.RecordRequest("user-2", "other", "GET /wallet", http.StatusOK, start)
+	// Без пользователя или маршрута запрос не учитывается
+	usage.RecordRequest("", "", "GET /products", http.StatusOK, start)
+	usage.RecordRequest("user-1", "student", "", http.StatusNotFound, start)
+
+	report, err := usage.GetUserUsage(t.Context(), "user-1")
+	require.NoError(t, err)
+	require.Equal(t, "student", report.Nickname)
+	require.EqualValues(t, 4, report.Requests)
+	require.EqualValues(t, 2, report.Errors)
+	require.Equal(t, 0.5, report.SuccessRate)
+	require.Equal(t, start, report.FirstActivity)
+	require.Equal(t, start.Add(3*time.Minute), report.LastActivity)
+
+	require.Len(t, report.Endpoints, 3)
+	require.Equal(t, "GET /cart", report.Endpoints[0].Route)
+	require.Equal(t, "POST /cart/items", report.Endpoints[1].Route)
+	require.EqualValues(t, 2, report.Endpoints[1].Requests)
+	require.EqualValues(t, 1, report.Endpoints[1].ClientErrors)
+	require.Equal(t, 0.5, report.Endpoints[1].SuccessRate)
+	require.Equal(t, start.Add(time.Minute), report.Endpoints[1].LastCalledAt)
+	require.EqualValues(t, 1, report.Endpoints[2].ServerErrors)
+
+	require.Equal(t, []models.SectionUsage{
+		{
+			Section: "cart", Requests: 3, Errors: 1, SuccessRate: 0.667,
+			Endpoints: 2, SucceededEndpoints: 2, LastCalledAt: start.Add(2 * time.Minute),
+		},
+		{
+			Section: "orders", Requests: 1, Errors: 1, SuccessRate: 0,
+			Endpoints: 1, SucceededEndpoints: 0, LastCalledAt: start.Add(3 * time.Minute),
+		},
+	}, report.Sections)
+
+	_, err = usage.GetUserUsage(t.Context(), "unknown")
+	require.ErrorIs(t, err, models.ErrNotFound)
+
+	// Счетчики переживают бэкап, сброс студента удаляет только его
+	data, err := json.Marshal(usage.GetBackupData())
+	require.NoError(t, err)
+
+	restored := service.NewUsageService()
+	require.NoError(t, restored.RestoreBackupData(data))
+
+	restoredReport, err := restored.GetUserUsage(t.Context(), "user-1")
+	require.NoError(t, err)
+	require.Equal(t, report, restoredReport)
+
+	restored.ResetUser("user-1")
+
+	_, err = restored.GetUserUsage(t.Context(), "user-1")
+	require.ErrorIs(t, err, models.ErrNotFound)
+
+	_, err = restored.GetUserUsage(t.Context(), "user-2")
+	require.NoError(t, err)
+}
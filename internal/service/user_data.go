@@ -2,10 +2,15 @@ package service
 
 import (
 	"context"
+	crand "crypto/rand"
+	"encoding/json"
 	"fmt"
+	"math/big"
 	"math/rand"
+	"net/mail"
 	"net/url"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -13,15 +18,59 @@ import (
 	"eats-backend/internal/models"
 )
 
+const (
+	emailVerificationTTL         = 15 * time.Minute
+	emailVerificationMaxAttempts = 5
+	// Новый код можно запросить не раньше, чем через минуту после предыдущего.
+	emailResendCooldown = time.Minute
+	// Сколько кодов в час отправляется одному пользователю и на один адрес.
+	emailCodesPerHour = 5
+)
+
+type Mailer interface {
+	Send(ctx context.Context, to, subject, body string) error
+}
+
+type emailVerification struct {
+	email     string
+	code      string
+	expiresAt time.Time
+	attempts  int
+}
+
+// UserData хранит профили пользователей. Наружу отдаются только копии профилей, а менять их можно
+// только методами сервиса под s.mux.
 type UserData struct {
 	profileInfo map[string]*models.UserProfile
+	// Исходные профили из файла данных, к ним возвращает ResetUser.
+	seed map[string]models.UserProfile
+	// Незавершенные подтверждения email: userID -> код.
+	verifications map[string]*emailVerification
+	// Отправленные за последний час коды по пользователям и по адресам. По адресу считается отдельно,
+	// чтобы с разных токенов нельзя было засыпать письмами чужой ящик. Сбросы данных их не трогают.
+	codesByUser    map[string][]time.Time
+	codesByAddress map[string][]time.Time
+
+	mailer Mailer
+	clock  Clock
 
 	mux sync.Mutex
 }
 
-func NewUserData(profiles map[string]*models.UserProfile) *UserData {
+func NewUserData(profiles map[string]*models.UserProfile, mailer Mailer, clock Clock) *UserData {
+	seed := make(map[string]models.UserProfile, len(profiles))
+	for userID, profile := range profiles {
+		seed[userID] = *profile
+	}
+
 	return &UserData{
-		profileInfo: profiles,
+		profileInfo:    profiles,
+		seed:           seed,
+		verifications:  make(map[string]*emailVerification),
+		codesByUser:    make(map[string][]time.Time),
+		codesByAddress: make(map[string][]time.Time),
+		mailer:         mailer,
+		clock:          clockOrSystem(clock),
 	}
 }
 
@@ -38,12 +87,19 @@ func generateRandomPhoneNumber() string {
 	return phoneNumber.String()
 }
 
-func (s *UserData) GetProfile(ctx context.Context) (*models.UserProfile, error) {
+// GetProfile возвращает копию профиля пользователя, создавая его при первом обращении
+func (s *UserData) GetProfile(ctx context.Context) (models.UserProfile, error) {
 	userID := models.ClaimsFromContext(ctx).ID
 
 	s.mux.Lock()
 	defer s.mux.Unlock()
 
+	return *s.getOrCreateProfile(userID), nil
+}
+
+// getOrCreateProfile возвращает профиль пользователя, создавая пустой при первом обращении.
+// Вызывать под s.mux.
+func (s *UserData) getOrCreateProfile(userID string) *models.UserProfile {
 	if _, ok := s.profileInfo[userID]; !ok {
 		s.profileInfo[userID] = &models.UserProfile{
 			Phone:    generateRandomPhoneNumber(),
@@ -53,7 +109,149 @@ func (s *UserData) GetProfile(ctx context.Context) (*models.UserProfile, error)
 		}
 	}
 
-	return s.profileInfo[userID], nil
+	return s.profileInfo[userID]
+}
+
+// SeedProfile задает профиль пользователю, у которого его еще нет. Возвращает false, если профиль уже есть.
+func (s *UserData) SeedProfile(userID string, profile models.UserProfile) bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if _, ok := s.profileInfo[userID]; ok {
+		return false
+	}
+
+	s.profileInfo[userID] = &profile
+
+	return true
+}
+
+// SetEmail сохраняет новый email как неподтвержденный и отправляет на него код подтверждения.
+// Частота кодов ограничена для пользователя и для адреса. Новый код не сбрасывает счетчик неверных попыток.
+func (s *UserData) SetEmail(ctx context.Context, email string) error {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	address, err := mail.ParseAddress(strings.TrimSpace(email))
+	if err != nil {
+		return fmt.Errorf("%w: invalid email: %w", models.ErrBadRequest, err)
+	}
+
+	code, err := generateVerificationCode()
+	if err != nil {
+		return fmt.Errorf("%w: can't generate verification code: %w", models.ErrInternalServer, err)
+	}
+
+	now := s.clock.Now()
+	addressKey := strings.ToLower(address.Address)
+
+	s.mux.Lock()
+	if retryAfter, limited := s.emailCodeLimit(userID, addressKey, now); limited {
+		s.mux.Unlock()
+
+		return &models.EmailRateLimitError{RetryAfter: retryAfter}
+	}
+
+	attempts := 0
+	if previous, ok := s.verifications[userID]; ok && now.Before(previous.expiresAt) {
+		attempts = previous.attempts
+	}
+
+	profile := s.getOrCreateProfile(userID)
+	profile.PendingEmail = address.Address
+	s.verifications[userID] = &emailVerification{
+		email:     address.Address,
+		code:      code,
+		expiresAt: now.Add(emailVerificationTTL),
+		attempts:  attempts,
+	}
+	s.codesByUser[userID] = append(s.codesByUser[userID], now)
+	s.codesByAddress[addressKey] = append(s.codesByAddress[addressKey], now)
+	s.mux.Unlock()
+
+	body := fmt.Sprintf("Ваш код подтверждения: %s. Код действует %d минут.", code, int(emailVerificationTTL.Minutes()))
+	if err := s.mailer.Send(ctx, address.Address, "Подтверждение email", body); err != nil {
+		return fmt.Errorf("%w: can't send verification email: %w", models.ErrInternalServer, err)
+	}
+
+	return nil
+}
+
+// VerifyEmail подтверждает ранее указанный email по коду из письма.
+func (s *UserData) VerifyEmail(ctx context.Context, code string) error {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	verification, ok := s.verifications[userID]
+	if !ok {
+		return fmt.Errorf("%w: no pending email verification", models.ErrNotFound)
+	}
+
+	if s.clock.Now().After(verification.expiresAt) {
+		delete(s.verifications, userID)
+
+		return fmt.Errorf("%w: verification code expired", models.ErrBadRequest)
+	}
+
+	if verification.code != strings.TrimSpace(code) {
+		verification.attempts++
+		if verification.attempts >= emailVerificationMaxAttempts {
+			delete(s.verifications, userID)
+
+			return fmt.Errorf("%w: too many attempts, request a new code", models.ErrBadRequest)
+		}
+
+		return fmt.Errorf("%w: wrong verification code", models.ErrBadRequest)
+	}
+
+	profile := s.getOrCreateProfile(userID)
+	profile.Email = verification.email
+	profile.EmailVerified = true
+	profile.PendingEmail = ""
+
+	delete(s.verifications, userID)
+
+	return nil
+}
+
+// emailCodeLimit проверяет частоту кодов и возвращает, через сколько можно запросить новый.
+// Заодно забывает отправки старше часа. Вызывать под s.mux.
+func (s *UserData) emailCodeLimit(userID, address string, now time.Time) (time.Duration, bool) {
+	for _, sent := range []map[string][]time.Time{s.codesByUser, s.codesByAddress} {
+		for key, times := range sent {
+			times = slices.DeleteFunc(times, func(at time.Time) bool { return !now.Before(at.Add(time.Hour)) })
+			if len(times) == 0 {
+				delete(sent, key)
+			} else {
+				sent[key] = times
+			}
+		}
+	}
+
+	if times := s.codesByUser[userID]; len(times) > 0 {
+		if wait := times[len(times)-1].Add(emailResendCooldown).Sub(now); wait > 0 {
+			return wait, true
+		}
+	}
+
+	for _, times := range [][]time.Time{s.codesByUser[userID], s.codesByAddress[address]} {
+		if len(times) >= emailCodesPerHour {
+			return times[len(times)-emailCodesPerHour].Add(time.Hour).Sub(now), true
+		}
+	}
+
+	return 0, false
+}
+
+// generateVerificationCode генерирует шестизначный код подтверждения
+func generateVerificationCode() (string, error) {
+	n, err := crand.Int(crand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%06d", n.Int64()), nil
 }
 
 func (s *UserData) UpdateProfile(ctx context.Context, data models.UpdateUserRequest) error {
@@ -86,9 +284,11 @@ func (s *UserData) UpdateProfile(ctx context.Context, data models.UpdateUserRequ
 	s.mux.Lock()
 	defer s.mux.Unlock()
 
-	s.profileInfo[userID].Name = name
-	s.profileInfo[userID].Birthday = birthday
-	s.profileInfo[userID].Image = data.Image
+	profile := s.getOrCreateProfile(userID)
+	profile.Name = name
+	profile.Birthday = birthday
+	profile.Image = data.Image
+	profile.Deleted = false
 
 	return nil
 }
@@ -99,13 +299,34 @@ func (s *UserData) DeleteProfile(ctx context.Context) error {
 	s.mux.Lock()
 	defer s.mux.Unlock()
 
-	s.profileInfo[userID].Name = ""
-	s.profileInfo[userID].Birthday = ""
-	s.profileInfo[userID].Image = ""
+	profile := s.getOrCreateProfile(userID)
+	profile.Name = ""
+	profile.Birthday = ""
+	profile.Image = ""
+	profile.Email = ""
+	profile.EmailVerified = false
+	profile.PendingEmail = ""
+	profile.Deleted = true
+
+	delete(s.verifications, userID)
 
 	return nil
 }
 
+// ReviewAuthor возвращает текущие имя и аватар автора отзывов. false - профиля нет, в отзыве остается
+// никнейм на момент отзыва.
+func (s *UserData) ReviewAuthor(userID string) (models.ReviewAuthor, bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	profile, ok := s.profileInfo[userID]
+	if !ok {
+		return models.ReviewAuthor{}, false
+	}
+
+	return models.ReviewAuthor{Name: profile.Name, Image: profile.Image, Deleted: profile.Deleted}, true
+}
+
 func parseBirthday(birthday string) (string, error) {
 	birthday = strings.TrimSpace(birthday)
 
@@ -129,10 +350,13 @@ func (s *UserData) GetBackupData() interface{} {
 	backupData := make(map[string]*models.UserProfile)
 	for id, profile := range s.profileInfo {
 		backupProfile := &models.UserProfile{
-			Phone:    profile.Phone,
-			Name:     profile.Name,
-			Birthday: profile.Birthday,
-			Image:    profile.Image,
+			Phone:         profile.Phone,
+			Name:          profile.Name,
+			Birthday:      profile.Birthday,
+			Image:         profile.Image,
+			Email:         profile.Email,
+			EmailVerified: profile.EmailVerified,
+			Deleted:       profile.Deleted,
 		}
 		backupData[id] = backupProfile
 	}
@@ -153,7 +377,81 @@ func (s *UserData) GetUserIDByPhone(phone string) (string, bool) {
 	return "", false
 }
 
+// GetUserPhone возвращает номер телефона пользователя, если у него есть профиль
+func (s *UserData) GetUserPhone(userID string) (string, bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	profile, ok := s.profileInfo[userID]
+	if !ok {
+		return "", false
+	}
+
+	return profile.Phone, true
+}
+
+// ResetUser возвращает профиль пользователя к исходному состоянию
+func (s *UserData) ResetUser(userID string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	delete(s.profileInfo, userID)
+	delete(s.verifications, userID)
+
+	if profile, ok := s.seed[userID]; ok {
+		s.profileInfo[userID] = &profile
+	}
+}
+
+// GetVerifiedEmail возвращает подтвержденный email пользователя, если он есть
+func (s *UserData) GetVerifiedEmail(userID string) (string, bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	profile, ok := s.profileInfo[userID]
+	if !ok || !profile.EmailVerified || profile.Email == "" {
+		return "", false
+	}
+
+	return profile.Email, true
+}
+
 // GetBackupFileName возвращает имя файла для бэкапа
 func (s *UserData) GetBackupFileName() string {
 	return "user_profiles"
 }
+
+// RestoreBackupData заменяет профили данными из бэкапа, незавершенные подтверждения email сбрасываются
+func (s *UserData) RestoreBackupData(data []byte) error {
+	profiles := make(map[string]*models.UserProfile)
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return fmt.Errorf("can't parse profiles: %w", err)
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.profileInfo = profiles
+	s.verifications = make(map[string]*emailVerification)
+
+	return nil
+}
+
+// ExportUserData возвращает профиль пользователя, nil - если профиль еще не создан
+func (s *UserData) ExportUserData(ctx context.Context) (any, error) {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	profile, ok := s.profileInfo[userID]
+	if !ok {
+		return nil, nil
+	}
+
+	return *profile, nil
+}
+
+func (s *UserData) GetUserExportName() string {
+	return "profile"
+}
@@ -0,0 +1,81 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"eats-backend/internal/models"
+	"eats-backend/internal/service"
+)
+
+type testCodeMailer map[string]string
+
+var testVerificationCode = regexp.MustCompile(`\d{6}`)
+
+func (m testCodeMailer) Send(_ context.Context, to, _, body string) error {
+	m[to] = testVerificationCode.FindString(body)
+	return nil
+}
+
+func requireEmailRateLimit(t *testing.T, err error, retryAfter time.Duration) {
+	t.Helper()
+
+	var limitErr *models.EmailRateLimitError
+	require.True(t, errors.As(err, &limitErr), "expected email rate limit error, got %v", err)
+	require.ErrorIs(t, err, models.ErrTooManyRequests)
+	require.Equal(t, retryAfter, limitErr.RetryAfter)
+}
+
+func TestUserData_SetEmailRateLimit(t *testing.T) {
+	clock := service.NewFakeClock(time.Date(2025, time.March, 3, 12, 0, 0, 0, time.UTC))
+	mailer := testCodeMailer{}
+	users := service.NewUserData(map[string]*models.UserProfile{}, mailer, clock)
+
+	alice := walletContext(t, "alice")
+
+	require.NoError(t, users.SetEmail(alice, "alice@example.com"))
+
+	// Повторный код - не раньше чем через минуту
+	clock.Advance(20 * time.Second)
+	requireEmailRateLimit(t, users.SetEmail(alice, "alice@example.com"), 40*time.Second)
+
+	// Неверные попытки не обнуляются новым кодом
+	for range 4 {
+		require.ErrorIs(t, users.VerifyEmail(alice, "wrong"), models.ErrBadRequest)
+	}
+
+	clock.Advance(40 * time.Second)
+	require.NoError(t, users.SetEmail(alice, "alice@example.com"))
+
+	err := users.VerifyEmail(alice, "wrong")
+	require.ErrorIs(t, err, models.ErrBadRequest)
+	require.Contains(t, err.Error(), "too many attempts")
+	require.ErrorIs(t, users.VerifyEmail(alice, mailer["alice@example.com"]), models.ErrNotFound)
+
+	// Не больше пяти кодов в час одному пользователю
+	for range 3 {
+		clock.Advance(time.Minute)
+		require.NoError(t, users.SetEmail(alice, "alice@example.com"))
+	}
+
+	clock.Advance(time.Minute)
+	requireEmailRateLimit(t, users.SetEmail(alice, "other@example.com"), 55*time.Minute)
+
+	// И на один адрес с разных токенов
+	for _, user := range []string{"bob", "carol", "dave", "erin", "frank"} {
+		require.NoError(t, users.SetEmail(walletContext(t, user), "Victim@example.com"))
+	}
+
+	requireEmailRateLimit(t, users.SetEmail(walletContext(t, "grace"), "victim@example.com"), time.Hour)
+	require.NoError(t, users.SetEmail(walletContext(t, "grace"), "grace@example.com"))
+
+	// Через час лимит снова доступен
+	clock.Advance(time.Hour)
+	require.NoError(t, users.SetEmail(alice, "alice@example.com"))
+	require.NoError(t, users.VerifyEmail(alice, mailer["alice@example.com"]))
+}
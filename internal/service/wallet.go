@@ -1,41 +1,130 @@
 package service
 
 import (
+	"cmp"
 	"context"
+	"encoding/json"
 	"fmt"
+	"maps"
 	"math"
+	"regexp"
+	"slices"
 	"sort"
+	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
+	"go.uber.org/zap"
 
+	"eats-backend/internal/events"
+	"eats-backend/internal/metrics"
 	"eats-backend/internal/models"
 )
 
+// Сколько можно пополнить за сутки без платежного провайдера, в рублях по текущему курсу.
+const dailyTopupLimit = models.Money(1000 * models.MinorUnits)
+
+var accountColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// errInsufficientFunds отдельная ошибка, чтобы метрики отличали нехватку средств от прочих отказов
+var errInsufficientFunds = fmt.Errorf("%w: insufficient funds", models.ErrBadRequest)
+
 type ProfileService interface {
-	GetProfile(ctx context.Context) (*models.UserProfile, error)
+	GetProfile(ctx context.Context) (models.UserProfile, error)
 	GetUserIDByPhone(phone string) (string, bool)
+	GetUserPhone(userID string) (string, bool)
+}
+
+// OperationGuard проверяет пополнения и переводы перед выполнением.
+type OperationGuard interface {
+	Check(op models.WalletOperation, history []models.Transaction) error
+}
+
+// WalletStats получает суммы операций кошелька для статистики
+type WalletStats interface {
+	WalletOperation(userID string, category models.TransactionCategory, amount models.Money)
+}
+
+// OperationConfirmer проверяет PIN кошелька у переводов и оплат
+type OperationConfirmer interface {
+	Confirm(userID, pin string, amount models.Money) error
+}
+
+// TransactionIcons выдает URL иконки для вида транзакции
+type TransactionIcons interface {
+	Icon(kind models.IconKind) string
+}
+
+// WalletRepository хранит кошельки пользователей
+type WalletRepository interface {
+	// SaveWallets записывает кошельки в одной транзакции, nil удаляет кошелек пользователя.
+	SaveWallets(ctx context.Context, wallets map[string]*models.UserWallet) error
 }
 
 type WalletService struct {
 	accounts     map[string]map[string]*models.Account // userID -> accountID -> account
 	transactions map[string][]models.Transaction       // userID -> transactions
-	dailyTopups  map[string]map[string]int             // userID -> date -> total amount
+	dailyTopups  map[string]map[string]models.Money    // userID -> date -> total amount
 	userPhones   map[string]string                     // userID -> phone
 	userData     ProfileService                        // для получения номеров телефонов
+	events       EventPublisher
+	guard        OperationGuard
+	pins         OperationConfirmer
+	stats        WalletStats
+	icons        TransactionIcons
+	rates        RatesProvider
+	currencies   []models.Currency // в каких валютах можно открыть счет
+	clock        Clock             // время операций и смена суток у лимита пополнений
+	logger       *zap.SugaredLogger
+
+	// Сохраненные контакты для переводов: userID -> userID получателя -> контакт
+	savedContacts map[string]map[string]models.SavedWalletContact
+
+	// Исходные данные из файла, к ним возвращает ResetUser.
+	seed models.WalletData
+
+	// Хранилище, в которое записывается каждое изменение кошелька, nil - только память.
+	repo WalletRepository
 
 	mux sync.RWMutex
 }
 
-func NewWalletService(userData ProfileService, initialData models.WalletData) *WalletService {
+func NewWalletService(
+	userData ProfileService,
+	events EventPublisher,
+	guard OperationGuard,
+	pins OperationConfirmer,
+	stats WalletStats,
+	icons TransactionIcons,
+	rates RatesProvider,
+	currencies []models.Currency,
+	clock Clock,
+	logger *zap.SugaredLogger,
+	initialData models.WalletData,
+) *WalletService {
 	ws := &WalletService{
-		userData: userData,
+		userData:   userData,
+		events:     events,
+		guard:      guard,
+		pins:       pins,
+		stats:      stats,
+		icons:      icons,
+		rates:      rates,
+		currencies: currencies,
+		clock:      clockOrSystem(clock),
+		logger:     logger,
 	}
 
 	// Загружаем данные из initialData или инициализируем пустыми структурами
 	if initialData.Accounts != nil {
 		ws.accounts = initialData.Accounts
+		for _, accounts := range ws.accounts {
+			for _, account := range accounts {
+				account.Currency = accountCurrency(*account)
+			}
+		}
 	} else {
 		ws.accounts = make(map[string]map[string]*models.Account)
 	}
@@ -49,7 +138,7 @@ func NewWalletService(userData ProfileService, initialData models.WalletData) *W
 	if initialData.DailyTopups != nil {
 		ws.dailyTopups = initialData.DailyTopups
 	} else {
-		ws.dailyTopups = make(map[string]map[string]int)
+		ws.dailyTopups = make(map[string]map[string]models.Money)
 	}
 
 	if initialData.UserPhones != nil {
@@ -58,10 +147,129 @@ func NewWalletService(userData ProfileService, initialData models.WalletData) *W
 		ws.userPhones = make(map[string]string)
 	}
 
+	if initialData.Contacts != nil {
+		ws.savedContacts = initialData.Contacts
+	} else {
+		ws.savedContacts = make(map[string]map[string]models.SavedWalletContact)
+	}
+
+	ws.seed = copyWalletData(initialData)
+
 	return ws
 }
 
-// getOrCreateUserPhone получает или создает номер телефона для пользователя
+// UseRepository включает запись каждого изменения кошелька в репозиторий. Операция завершается успешно,
+// только когда изменение записано, поэтому подтвержденные списания и зачисления не теряются при падении.
+func (ws *WalletService) UseRepository(repo WalletRepository) {
+	ws.mux.Lock()
+	defer ws.mux.Unlock()
+
+	ws.repo = repo
+}
+
+// userWallet копия кошелька пользователя, nil - кошелька нет. Вызывается под блокировкой.
+func (ws *WalletService) userWallet(userID string) *models.UserWallet {
+	accounts, hasAccounts := ws.accounts[userID]
+	transactions, hasTransactions := ws.transactions[userID]
+	dailyTopups, hasTopups := ws.dailyTopups[userID]
+	phone, hasPhone := ws.userPhones[userID]
+	contacts, hasContacts := ws.savedContacts[userID]
+
+	if !hasAccounts && !hasTransactions && !hasTopups && !hasPhone && !hasContacts {
+		return nil
+	}
+
+	wallet := &models.UserWallet{
+		Transactions: slices.Clone(transactions),
+		DailyTopups:  maps.Clone(dailyTopups),
+		Phone:        phone,
+		Contacts:     maps.Clone(contacts),
+	}
+
+	// Пустая map означала бы открытый кошелек без счетов
+	if hasAccounts {
+		wallet.Accounts = copyAccounts(accounts)
+	}
+
+	return wallet
+}
+
+// setUserWallet заменяет кошелек пользователя, nil удаляет его. Вызывается под блокировкой.
+func (ws *WalletService) setUserWallet(userID string, wallet *models.UserWallet) {
+	delete(ws.accounts, userID)
+	delete(ws.transactions, userID)
+	delete(ws.dailyTopups, userID)
+	delete(ws.userPhones, userID)
+	delete(ws.savedContacts, userID)
+
+	if wallet == nil {
+		return
+	}
+
+	if wallet.Accounts != nil {
+		ws.accounts[userID] = wallet.Accounts
+	}
+
+	if wallet.Transactions != nil {
+		ws.transactions[userID] = wallet.Transactions
+	}
+
+	if wallet.DailyTopups != nil {
+		ws.dailyTopups[userID] = wallet.DailyTopups
+	}
+
+	if wallet.Phone != "" {
+		ws.userPhones[userID] = wallet.Phone
+	}
+
+	if wallet.Contacts != nil {
+		ws.savedContacts[userID] = wallet.Contacts
+	}
+}
+
+// snapshot копии кошельков пользователей до изменения, к ним persist откатывает кошельки, если запись
+// не удалась. Без репозитория возвращает nil. Вызывается под блокировкой.
+func (ws *WalletService) snapshot(userIDs ...string) map[string]*models.UserWallet {
+	if ws.repo == nil {
+		return nil
+	}
+
+	before := make(map[string]*models.UserWallet, len(userIDs))
+	for _, userID := range userIDs {
+		before[userID] = ws.userWallet(userID)
+	}
+
+	return before
+}
+
+// persist записывает кошельки пользователей из снимка в репозиторий. Если запись не удалась, кошельки
+// возвращаются к снимку и операция завершается ошибкой. Вызывается под блокировкой до событий об операции.
+func (ws *WalletService) persist(ctx context.Context, before map[string]*models.UserWallet) error {
+	if ws.repo == nil {
+		return nil
+	}
+
+	wallets := make(map[string]*models.UserWallet, len(before))
+	for userID := range before {
+		wallets[userID] = ws.userWallet(userID)
+	}
+
+	// Изменение уже сделано в памяти, отмена запроса не должна оставить его незаписанным
+	if err := ws.repo.SaveWallets(context.WithoutCancel(ctx), wallets); err != nil {
+		for userID, wallet := range before {
+			ws.setUserWallet(userID, wallet)
+		}
+
+		ws.logger.Errorw("Failed to save wallets", "users", slices.Collect(maps.Keys(before)), "error", err)
+
+		return fmt.Errorf("%w: can't save wallet", models.ErrInternalServer)
+	}
+
+	return nil
+}
+
+// getOrCreateUserPhone получает или создает номер телефона для пользователя. Вызывать под ws.mux на запись.
+// Сервис кошелька обращается к профилям под своей блокировкой, поэтому профили не должны вызывать кошелек.
 func (ws *WalletService) getOrCreateUserPhone(ctx context.Context) (string, error) {
 	userID := models.ClaimsFromContext(ctx).ID
 
@@ -87,73 +295,295 @@ func (ws *WalletService) initializeNewUser(userID string) {
 	cardID := uuid.New().String()
 	ws.accounts[userID] = map[string]*models.Account{
 		cardID: {
-			ID:      cardID,
-			Type:    models.AccountTypeCard,
-			Balance: 3010,
+			ID:       cardID,
+			Type:     models.AccountTypeCard,
+			Balance:  models.Rubles(3010),
+			Currency: models.CurrencyRUB,
 		},
 	}
 
 	// Добавляем фейковые транзакции для имитации истории
-	now := time.Now()
+	now := ws.clock.Now()
 	ws.transactions[userID] = []models.Transaction{
 		{
-			Amount: 5000,
-			Title:  "Приветственный бонус",
-			Time:   now.Add(-72 * time.Hour), // 3 дня назад
+			Amount:   models.Rubles(5000),
+			Title:    "Приветственный бонус",
+			Time:     now.Add(-72 * time.Hour), // 3 дня назад
+			Category: models.TransactionCategoryOther,
 		},
 		{
-			Amount: -450,
-			Title:  "Покупка в супермаркете",
-			Time:   now.Add(-48 * time.Hour), // 2 дня назад
+			Amount:   models.Rubles(-450),
+			Title:    "Покупка в супермаркете",
+			Time:     now.Add(-48 * time.Hour), // 2 дня назад
+			Category: models.TransactionCategoryFood,
 		},
 		{
-			Amount: -150,
-			Title:  "Кофе в кафе",
-			Time:   now.Add(-36 * time.Hour), // 1.5 дня назад
+			Amount:   models.Rubles(-150),
+			Title:    "Кофе в кафе",
+			Time:     now.Add(-36 * time.Hour), // 1.5 дня назад
+			Category: models.TransactionCategoryFood,
 		},
 		{
-			Amount: -890,
-			Title:  "Заказ доставки еды",
-			Time:   now.Add(-24 * time.Hour), // 1 день назад
+			Amount:   models.Rubles(-890),
+			Title:    "Заказ доставки еды",
+			Time:     now.Add(-24 * time.Hour), // 1 день назад
+			Category: models.TransactionCategoryFood,
 		},
 		{
-			Amount: -320,
-			Title:  "Аптека",
-			Time:   now.Add(-12 * time.Hour), // 12 часов назад
+			Amount:   models.Rubles(-320),
+			Title:    "Аптека",
+			Time:     now.Add(-12 * time.Hour), // 12 часов назад
+			Category: models.TransactionCategoryOther,
 		},
 		{
-			Amount: -180,
-			Title:  "Транспорт",
-			Time:   now.Add(-6 * time.Hour), // 6 часов назад
+			Amount:   models.Rubles(-180),
+			Title:    "Транспорт",
+			Time:     now.Add(-6 * time.Hour), // 6 часов назад
+			Category: models.TransactionCategoryOther,
 		},
 	}
+
+	for i, transaction := range ws.transactions[userID] {
+		ws.transactions[userID][i] = ws.withDefaults(transaction)
+	}
+}
+
+// SeedWallet задает счета и историю пользователю, у которого еще нет кошелька. Возвращает false,
+// если кошелек уже есть.
+func (ws *WalletService) SeedWallet(userID string, accounts []models.Account, transactions []models.Transaction) bool {
+	ws.mux.Lock()
+	defer ws.mux.Unlock()
+
+	if _, ok := ws.accounts[userID]; ok {
+		return false
+	}
+
+	before := ws.snapshot(userID)
+
+	ws.accounts[userID] = make(map[string]*models.Account, len(accounts))
+	for _, account := range accounts {
+		ws.accounts[userID][account.ID] = &account
+	}
+
+	ws.transactions[userID] = make([]models.Transaction, 0, len(transactions))
+	for _, transaction := range transactions {
+		ws.transactions[userID] = append(ws.transactions[userID], ws.withDefaults(transaction))
+	}
+
+	return ws.persist(context.Background(), before) == nil
+}
+
+// withDefaults подставляет иконку из каталога, если у транзакции ее нет, и рубли, если не указана
+// валюта. Транзакции из файла данных получают их при выдаче.
+func (ws *WalletService) withDefaults(transaction models.Transaction) models.Transaction {
+	if transaction.Icon == "" {
+		transaction.Icon = ws.icons.Icon(transactionIconKind(transaction))
+	}
+
+	if transaction.Currency == "" {
+		transaction.Currency = models.CurrencyRUB
+	}
+
+	return transaction
+}
+
+// accountCurrency валюта счета, счета из старых данных рублевые.
+func accountCurrency(account models.Account) models.Currency {
+	if account.Currency == "" {
+		return models.CurrencyRUB
+	}
+
+	return account.Currency
+}
+
+// inRubles переводит сумму в рубли по текущему курсу. Лимиты, антифрод и статистика считаются в рублях.
+func (ws *WalletService) inRubles(amount models.Money, currency models.Currency) (models.Money, error) {
+	if currency == "" || currency == models.CurrencyRUB {
+		return amount, nil
+	}
+
+	rate, err := ws.rates.Rate(currency, models.CurrencyRUB)
+	if err != nil {
+		return 0, err
+	}
+
+	return rate.Convert(amount), nil
+}
+
+// historyInRubles транзакции пользователя с суммами в рублях для проверки антифродом. Транзакции
+// в валюте без курса пропускаются. Вызывается под блокировкой.
+func (ws *WalletService) historyInRubles(userID string) []models.Transaction {
+	history := make([]models.Transaction, 0, len(ws.transactions[userID]))
+
+	for _, transaction := range ws.transactions[userID] {
+		amount, err := ws.inRubles(transaction.Amount, transaction.Currency)
+		if err != nil {
+			continue
+		}
+
+		transaction.Amount = amount
+		transaction.Currency = models.CurrencyRUB
+		history = append(history, transaction)
+	}
+
+	return history
+}
+
+// OpenAccount открывает пустой счет в одной из разрешенных валют.
+func (ws *WalletService) OpenAccount(ctx context.Context, req models.OpenAccountRequest) (*models.Account, error) {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	if req.Type == "" {
+		req.Type = models.AccountTypeCard
+	}
+
+	if req.Currency == "" {
+		req.Currency = models.CurrencyRUB
+	}
+
+	if req.Type != models.AccountTypeCard && req.Type != models.AccountTypeSavings {
+		return nil, fmt.Errorf("%w: unknown account type %s", models.ErrBadRequest, req.Type)
+	}
+
+	if !slices.Contains(ws.currencies, req.Currency) {
+		return nil, fmt.Errorf("%w: currency %s is not supported", models.ErrBadRequest, req.Currency)
+	}
+
+	ws.mux.Lock()
+	defer ws.mux.Unlock()
+
+	before := ws.snapshot(userID)
+
+	if _, exists := ws.accounts[userID]; !exists {
+		ws.initializeNewUser(userID)
+	}
+
+	account := &models.Account{
+		ID:       uuid.NewString(),
+		Type:     req.Type,
+		Currency: req.Currency,
+	}
+	ws.accounts[userID][account.ID] = account
+
+	if err := ws.persist(ctx, before); err != nil {
+		return nil, fmt.Errorf("open account: %w", err)
+	}
+
+	ws.logger.Debugw("Account opened", "userId", userID, "accountId", account.ID, "currency", account.Currency)
+
+	result := *account
+
+	return &result, nil
+}
+
+// UpdateAccount меняет название, цвет и иконку карточки счета.
+func (ws *WalletService) UpdateAccount(
+	ctx context.Context,
+	accountID string,
+	patch models.AccountPatch,
+) (*models.Account, error) {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	if err := validateAccountPatch(&patch); err != nil {
+		return nil, err
+	}
+
+	ws.mux.Lock()
+	defer ws.mux.Unlock()
+
+	account, ok := ws.accounts[userID][accountID]
+	if !ok {
+		return nil, fmt.Errorf("%w: account not found", models.ErrNotFound)
+	}
+
+	before := ws.snapshot(userID)
+
+	if patch.Name != nil {
+		account.Name = *patch.Name
+	}
+
+	if patch.Color != nil {
+		account.Color = *patch.Color
+	}
+
+	if patch.Icon != nil {
+		account.Icon = *patch.Icon
+	}
+
+	if err := ws.persist(ctx, before); err != nil {
+		return nil, fmt.Errorf("update account: %w", err)
+	}
+
+	result := *account
+
+	return &result, nil
+}
+
+func validateAccountPatch(patch *models.AccountPatch) error {
+	if patch.Name != nil {
+		name := strings.TrimSpace(*patch.Name)
+		if utf8.RuneCountInString(name) > models.MaxAccountNameLength {
+			return fmt.Errorf("%w: account name is longer than %d characters", models.ErrBadRequest, models.MaxAccountNameLength)
+		}
+
+		patch.Name = &name
+	}
+
+	if patch.Color != nil && *patch.Color != "" {
+		if !accountColorPattern.MatchString(*patch.Color) {
+			return fmt.Errorf("%w: color must be in #RRGGBB format", models.ErrBadRequest)
+		}
+
+		color := strings.ToLower(*patch.Color)
+		patch.Color = &color
+	}
+
+	if patch.Icon != nil && *patch.Icon != "" && !slices.Contains(models.AccountIcons, *patch.Icon) {
+		return fmt.Errorf("%w: unknown account icon %s", models.ErrBadRequest, *patch.Icon)
+	}
+
+	return nil
+}
+
+// GetRate возвращает курс обмена между разрешенными валютами, по нему будет выполнен перевод.
+func (ws *WalletService) GetRate(_ context.Context, from, to models.Currency) (models.ExchangeRate, error) {
+	for _, currency := range []models.Currency{from, to} {
+		if !slices.Contains(ws.currencies, currency) {
+			return models.ExchangeRate{}, fmt.Errorf("%w: currency %s is not supported", models.ErrBadRequest, currency)
+		}
+	}
+
+	rate, err := ws.rates.Rate(from, to)
+	if err != nil {
+		return models.ExchangeRate{}, fmt.Errorf("get rate: %w", err)
+	}
+
+	return rate, nil
 }
 
 func (ws *WalletService) GetWallet(ctx context.Context) (*models.Wallet, error) {
 	userID := models.ClaimsFromContext(ctx).ID
 
-	ws.mux.RLock()
-	userAccounts, exists := ws.accounts[userID]
-	ws.mux.RUnlock()
+	// Кошелек создается при первом обращении, поэтому проверка и создание под одной блокировкой
+	lockStart := time.Now()
+	ws.mux.Lock()
+	defer ws.mux.Unlock()
+	models.ObserveTiming(ctx, "wallet.lock_wait", lockStart)
 
-	// Если у пользователя нет аккаунта, инициализируем его
-	if !exists {
-		ws.mux.Lock()
-		// Двойная проверка после получения блокировки на запись
-		if _, stillNotExists := ws.accounts[userID]; stillNotExists {
-			ws.initializeNewUser(userID)
+	if _, exists := ws.accounts[userID]; !exists {
+		before := ws.snapshot(userID)
+		ws.initializeNewUser(userID)
+
+		if err := ws.persist(ctx, before); err != nil {
+			return nil, fmt.Errorf("create wallet: %w", err)
 		}
-		userAccounts = ws.accounts[userID]
-		ws.mux.Unlock()
 	}
 
-	// Собираем список аккаунтов
-	ws.mux.RLock()
-	accounts := make([]models.Account, 0, len(userAccounts))
-	for _, account := range userAccounts {
+	// Наружу отдаются копии счетов
+	accounts := make([]models.Account, 0, len(ws.accounts[userID]))
+	for _, account := range ws.accounts[userID] {
 		accounts = append(accounts, *account)
 	}
-	ws.mux.RUnlock()
 
 	return &models.Wallet{Accounts: accounts}, nil
 }
@@ -161,8 +591,14 @@ func (ws *WalletService) GetWallet(ctx context.Context) (*models.Wallet, error)
 func (ws *WalletService) GetTransactions(ctx context.Context, page, pageSize int) (*models.TransactionsResponse, error) {
 	userID := models.ClaimsFromContext(ctx).ID
 
+	lockStart := time.Now()
 	ws.mux.RLock()
 	defer ws.mux.RUnlock()
+	models.ObserveTiming(ctx, "wallet.lock_wait", lockStart)
+
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("get transactions: %w", err)
+	}
 
 	userTransactions, exists := ws.transactions[userID]
 	if !exists {
@@ -173,7 +609,8 @@ func (ws *WalletService) GetTransactions(ctx context.Context, page, pageSize int
 		}, nil
 	}
 
-	// Сортируем транзакции по времени (новые сначала)
+	// Сортируем копию транзакций по времени (новые сначала): под блокировкой на чтение хранимые менять нельзя
+	userTransactions = slices.Clone(userTransactions)
 	sort.Slice(userTransactions, func(i, j int) bool {
 		return userTransactions[i].Time.After(userTransactions[j].Time)
 	})
@@ -204,7 +641,7 @@ func (ws *WalletService) GetTransactions(ctx context.Context, page, pageSize int
 	paginatedByDate := make(models.TransactionsByDate)
 	for _, transaction := range paginatedTransactions {
 		date := transaction.Time.Format("2006-01-02")
-		paginatedByDate[date] = append(paginatedByDate[date], transaction)
+		paginatedByDate[date] = append(paginatedByDate[date], ws.withDefaults(transaction))
 	}
 
 	return &models.TransactionsResponse{
@@ -217,19 +654,22 @@ func (ws *WalletService) GetTransactions(ctx context.Context, page, pageSize int
 func (ws *WalletService) TopupAccount(ctx context.Context, req models.TopupRequest) (*models.TopupResponse, error) {
 	userID := models.ClaimsFromContext(ctx).ID
 
+	// Отрицательное пополнение уменьшило бы счетчик дневного лимита
+	if req.Amount <= 0 {
+		return nil, fmt.Errorf("%w: amount must be positive", models.ErrBadRequest)
+	}
+
 	// Проверяем лимит пополнения (1000 рублей в сутки)
-	today := time.Now().Format("2006-01-02")
+	today := ws.clock.Now().Format("2006-01-02")
 
+	lockStart := time.Now()
 	ws.mux.Lock()
 	defer ws.mux.Unlock()
+	models.ObserveTiming(ctx, "wallet.lock_wait", lockStart)
 
-	// Проверяем дневной лимит
-	if ws.dailyTopups[userID] == nil {
-		ws.dailyTopups[userID] = make(map[string]int)
-	}
-
-	if ws.dailyTopups[userID][today]+req.Amount > 1000 {
-		return nil, fmt.Errorf("%w: daily topup limit exceeded (1000 rubles per day)", models.ErrBadRequest)
+	// Ожидание блокировки могло съесть время запроса, а после изменения баланса отменять уже поздно
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("topup: %w", err)
 	}
 
 	// Проверяем существование счета
@@ -243,32 +683,143 @@ func (ws *WalletService) TopupAccount(ctx context.Context, req models.TopupReque
 		return nil, fmt.Errorf("%w: account not found", models.ErrNotFound)
 	}
 
-	// Обновляем баланс
-	account.Balance += req.Amount
+	rubles, err := ws.inRubles(req.Amount, account.Currency)
+	if err != nil {
+		return nil, fmt.Errorf("topup: %w", err)
+	}
+
+	before := ws.snapshot(userID)
+
+	// Проверяем дневной лимит
+	if ws.dailyTopups[userID] == nil {
+		ws.dailyTopups[userID] = make(map[string]models.Money)
+	}
+
+	if ws.dailyTopups[userID][today]+rubles > dailyTopupLimit {
+		metrics.TopupRejections.Inc("daily_limit")
+
+		return nil, fmt.Errorf("%w: daily topup limit exceeded (1000 rubles per day)", models.ErrBadRequest)
+	}
+
+	err = ws.guard.Check(models.WalletOperation{
+		Type:      models.WalletOperationTopup,
+		UserID:    userID,
+		AccountID: req.AccountID,
+		Amount:    rubles,
+	}, ws.historyInRubles(userID))
+	if err != nil {
+		metrics.TopupRejections.Inc("fraud")
+
+		return nil, fmt.Errorf("topup: %w", err)
+	}
 
 	// Обновляем дневной лимит
-	ws.dailyTopups[userID][today] += req.Amount
+	ws.dailyTopups[userID][today] += rubles
 
-	// Добавляем транзакцию
-	transaction := models.Transaction{
-		Amount: req.Amount,
-		Title:  "Пополнение счета",
-		Time:   time.Now(),
+	ws.addTopup(userID, account, models.Transaction{
+		Amount:   req.Amount,
+		Title:    "Пополнение счета",
+		Time:     ws.clock.Now(),
+		Category: models.TransactionCategoryTopup,
+	})
+
+	if err := ws.persist(ctx, before); err != nil {
+		return nil, fmt.Errorf("topup: %w", err)
 	}
 
+	ws.topupStats(userID, req.Amount, account.Currency)
+
+	ws.logger.Debugw("Account topped up", "userId", userID, "accountId", req.AccountID, "amount", req.Amount)
+
+	return &models.TopupResponse{Balance: account.Balance}, nil
+}
+
+// addTopup зачисляет пополнение на счет и добавляет транзакцию, вызывается под блокировкой
+func (ws *WalletService) addTopup(userID string, account *models.Account, transaction models.Transaction) {
+	account.Balance += transaction.Amount
+	transaction.Currency = account.Currency
+
 	if ws.transactions[userID] == nil {
 		ws.transactions[userID] = []models.Transaction{}
 	}
-	ws.transactions[userID] = append(ws.transactions[userID], transaction)
+	ws.transactions[userID] = append(ws.transactions[userID], ws.withDefaults(transaction))
+}
+
+// topupStats передает пополнение в статистику, когда оно уже записано
+func (ws *WalletService) topupStats(userID string, amount models.Money, currency models.Currency) {
+	if rubles, err := ws.inRubles(amount, currency); err == nil {
+		ws.stats.WalletOperation(userID, models.TransactionCategoryTopup, rubles)
+	}
+}
 
-	return &models.TopupResponse{Balance: account.Balance}, nil
+// CheckAccount проверяет, что у пользователя есть счет, который можно пополнить через провайдера.
+// Провайдер принимает оплату только в рублях.
+func (ws *WalletService) CheckAccount(ctx context.Context, accountID string) error {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	ws.mux.RLock()
+	defer ws.mux.RUnlock()
+
+	account, exists := ws.accounts[userID][accountID]
+	if !exists {
+		return fmt.Errorf("%w: account not found", models.ErrNotFound)
+	}
+
+	if account.Currency != models.CurrencyRUB {
+		return fmt.Errorf("%w: only RUB accounts can be topped up by card", models.ErrBadRequest)
+	}
+
+	return nil
+}
+
+// CreditPayment зачисляет пополнение, оплаченное через платежного провайдера. Дневной лимит и антифрод
+// не проверяются: деньги уже списаны провайдером.
+func (ws *WalletService) CreditPayment(userID, accountID string, amount models.Money, paymentID string) error {
+	ws.mux.Lock()
+	defer ws.mux.Unlock()
+
+	account, exists := ws.accounts[userID][accountID]
+	if !exists {
+		return fmt.Errorf("%w: account not found", models.ErrNotFound)
+	}
+
+	before := ws.snapshot(userID)
+
+	ws.addTopup(userID, account, models.Transaction{
+		Amount:    amount,
+		Title:     "Пополнение картой",
+		Time:      ws.clock.Now(),
+		Category:  models.TransactionCategoryTopup,
+		PaymentID: paymentID,
+	})
+
+	if err := ws.persist(context.Background(), before); err != nil {
+		return err
+	}
+
+	ws.topupStats(userID, amount, account.Currency)
+
+	ws.logger.Debugw("Account topped up by payment", "userId", userID, "accountId", accountID, "paymentId", paymentID)
+
+	return nil
 }
 
 func (ws *WalletService) TransferMoney(ctx context.Context, req models.TransferRequest) (*models.TransferResponse, error) {
 	fromUserID := models.ClaimsFromContext(ctx).ID
 
+	// Отрицательный перевод прошел бы проверку баланса и лимитов и забрал бы деньги у получателя
+	if req.Amount <= 0 {
+		return nil, fmt.Errorf("%w: amount must be positive", models.ErrBadRequest)
+	}
+
+	lockStart := time.Now()
 	ws.mux.Lock()
 	defer ws.mux.Unlock()
+	models.ObserveTiming(ctx, "wallet.lock_wait", lockStart)
+
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("transfer: %w", err)
+	}
 
 	// Проверяем существование счета отправителя
 	fromUserAccounts, exists := ws.accounts[fromUserID]
@@ -283,11 +834,21 @@ func (ws *WalletService) TransferMoney(ctx context.Context, req models.TransferR
 
 	// Проверяем достаточность средств
 	if fromAccount.Balance < req.Amount {
-		return nil, fmt.Errorf("%w: insufficient funds", models.ErrBadRequest)
+		return nil, errInsufficientFunds
 	}
 
-	// Находим получателя по номеру телефона
-	toUserID, found := ws.userData.GetUserIDByPhone(req.ToPhoneNumber)
+	// Находим получателя по номеру телефона или среди контактов
+	toPhone := req.ToPhoneNumber
+	if toPhone == "" && req.ToContactID != "" {
+		phone, err := ws.contactPhone(fromUserID, req.ToContactID)
+		if err != nil {
+			return nil, fmt.Errorf("transfer: %w", err)
+		}
+
+		toPhone = phone
+	}
+
+	toUserID, found := ws.userData.GetUserIDByPhone(toPhone)
 	if !found {
 		return nil, fmt.Errorf("%w: recipient not found", models.ErrNotFound)
 	}
@@ -302,53 +863,280 @@ func (ws *WalletService) TransferMoney(ctx context.Context, req models.TransferR
 		return nil, fmt.Errorf("%w: recipient account not found", models.ErrNotFound)
 	}
 
-	// Ищем первый счет получателя (в реальном приложении можно было бы выбрать конкретный счет)
-	var toAccount *models.Account
-	for _, account := range toUserAccounts {
-		toAccount = account
-		break
-	}
-
+	toAccount := recipientAccount(toUserAccounts, fromAccount.Currency)
 	if toAccount == nil {
 		return nil, fmt.Errorf("%w: recipient has no accounts", models.ErrNotFound)
 	}
 
+	// Между счетами в разных валютах сумма конвертируется, курс возвращается в ответе и сохраняется в транзакциях
+	credited := req.Amount
+
+	var exchange *models.ExchangeRate
+
+	if toAccount.Currency != fromAccount.Currency {
+		rate, err := ws.rates.Rate(fromAccount.Currency, toAccount.Currency)
+		if err != nil {
+			return nil, fmt.Errorf("transfer: %w", err)
+		}
+
+		exchange = &rate
+		credited = rate.Convert(req.Amount)
+
+		if credited <= 0 {
+			return nil, fmt.Errorf("%w: amount is too small to convert", models.ErrBadRequest)
+		}
+	}
+
+	rubles, err := ws.inRubles(req.Amount, fromAccount.Currency)
+	if err != nil {
+		return nil, fmt.Errorf("transfer: %w", err)
+	}
+
+	if err := ws.pins.Confirm(fromUserID, req.PIN, rubles); err != nil {
+		return nil, fmt.Errorf("transfer: %w", err)
+	}
+
+	err = ws.guard.Check(models.WalletOperation{
+		Type:               models.WalletOperationTransfer,
+		UserID:             fromUserID,
+		AccountID:          req.FromAccountID,
+		Amount:             rubles,
+		CounterpartyUserID: toUserID,
+		ToPhone:            toPhone,
+	}, ws.historyInRubles(fromUserID))
+	if err != nil {
+		return nil, fmt.Errorf("transfer: %w", err)
+	}
+
+	before := ws.snapshot(fromUserID, toUserID)
+
+	// Телефон отправителя нужен для транзакции получателя, получаем его до изменения балансов
+	fromUserPhone, err := ws.getOrCreateUserPhone(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sender phone: %w", err)
+	}
+
 	// Выполняем перевод
 	fromAccount.Balance -= req.Amount
-	toAccount.Balance += req.Amount
+	toAccount.Balance += credited
 
-	// Добавляем транзакции
-	transferTime := time.Now()
+	// Добавляем транзакции, общий идентификатор связывает обе стороны перевода
+	transferTime := ws.clock.Now()
+	transferID := uuid.NewString()
 
 	// Транзакция отправителя (отрицательная)
 	fromTransaction := models.Transaction{
-		Amount: -req.Amount,
-		Title:  fmt.Sprintf("Перевод на номер %s", req.ToPhoneNumber),
-		Time:   transferTime,
+		Amount:   -req.Amount,
+		Currency: fromAccount.Currency,
+		Title:    fmt.Sprintf("Перевод на номер %s", toPhone),
+		Time:     transferTime,
+		Category: models.TransactionCategoryTransfer,
+
+		TransferID:         transferID,
+		CounterpartyUserID: toUserID,
+		Exchange:           exchange,
 	}
 
 	if ws.transactions[fromUserID] == nil {
 		ws.transactions[fromUserID] = []models.Transaction{}
 	}
-	ws.transactions[fromUserID] = append(ws.transactions[fromUserID], fromTransaction)
+	ws.transactions[fromUserID] = append(ws.transactions[fromUserID], ws.withDefaults(fromTransaction))
 
 	// Транзакция получателя (положительная)
-	fromUserPhone, err := ws.getOrCreateUserPhone(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get sender phone: %w", err)
-	}
 	toTransaction := models.Transaction{
-		Amount: req.Amount,
-		Title:  fmt.Sprintf("Перевод от номера %s", fromUserPhone),
-		Time:   transferTime,
+		Amount:   credited,
+		Currency: toAccount.Currency,
+		Title:    fmt.Sprintf("Перевод от номера %s", fromUserPhone),
+		Time:     transferTime,
+		Category: models.TransactionCategoryTransfer,
+
+		TransferID:         transferID,
+		CounterpartyUserID: fromUserID,
+		Exchange:           exchange,
 	}
 
 	if ws.transactions[toUserID] == nil {
 		ws.transactions[toUserID] = []models.Transaction{}
 	}
-	ws.transactions[toUserID] = append(ws.transactions[toUserID], toTransaction)
+	ws.transactions[toUserID] = append(ws.transactions[toUserID], ws.withDefaults(toTransaction))
+
+	// Обе стороны перевода записываются одной транзакцией хранилища
+	if err := ws.persist(ctx, before); err != nil {
+		return nil, fmt.Errorf("transfer: %w", err)
+	}
 
-	return &models.TransferResponse{Balance: fromAccount.Balance}, nil
+	ws.stats.WalletOperation(fromUserID, models.TransactionCategoryTransfer, rubles)
+
+	ws.logger.Debugw("Transfer completed", "transferId", transferID, "from", fromUserID, "to", toUserID,
+		"amount", req.Amount, "currency", fromAccount.Currency, "credited", credited, "creditedCurrency", toAccount.Currency)
+
+	ws.events.Publish(ctx, events.TransferCompleted{
+		FromUserID:       fromUserID,
+		ToUserID:         toUserID,
+		FromPhone:        fromUserPhone,
+		ToPhone:          toPhone,
+		Amount:           req.Amount,
+		Currency:         fromAccount.Currency,
+		SenderBalance:    fromAccount.Balance,
+		CreditedAmount:   credited,
+		CreditedCurrency: toAccount.Currency,
+	})
+
+	return &models.TransferResponse{
+		Balance:          fromAccount.Balance,
+		Currency:         fromAccount.Currency,
+		TransferID:       transferID,
+		CreditedAmount:   credited,
+		CreditedCurrency: toAccount.Currency,
+		Exchange:         exchange,
+	}, nil
+}
+
+// recipientAccount выбирает счет получателя: в валюте перевода, иначе рублевый, иначе любой.
+// Среди подходящих счетов берется первый по идентификатору, чтобы выбор не зависел от порядка map.
+func recipientAccount(accounts map[string]*models.Account, currency models.Currency) *models.Account {
+	var sameCurrency, rubles, first *models.Account
+
+	for _, account := range accounts {
+		if account.Currency == currency && (sameCurrency == nil || account.ID < sameCurrency.ID) {
+			sameCurrency = account
+		}
+
+		if account.Currency == models.CurrencyRUB && (rubles == nil || account.ID < rubles.ID) {
+			rubles = account
+		}
+
+		if first == nil || account.ID < first.ID {
+			first = account
+		}
+	}
+
+	switch {
+	case sameCurrency != nil:
+		return sameCurrency
+	case rubles != nil:
+		return rubles
+	default:
+		return first
+	}
+}
+
+// ConfirmPayment проверяет PIN перед оплатой заказа кошельком. Заказы по подписке оплачиваются без PIN:
+// пользователь подтвердил их, когда оформлял подписку.
+func (ws *WalletService) ConfirmPayment(ctx context.Context, pin string, amount models.Money) error {
+	return ws.pins.Confirm(models.ClaimsFromContext(ctx).ID, pin, amount)
+}
+
+// PayForOrder списывает стоимость заказа с рублевых карт пользователя. Если на одной карте не хватает денег,
+// остаток списывается со следующих по порядку идентификаторов.
+func (ws *WalletService) PayForOrder(ctx context.Context, orderID string, amount models.Money) (models.OrderPayment, error) {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	lockStart := time.Now()
+	ws.mux.Lock()
+	defer ws.mux.Unlock()
+	models.ObserveTiming(ctx, "wallet.lock_wait", lockStart)
+
+	if err := ctx.Err(); err != nil {
+		return models.OrderPayment{}, fmt.Errorf("pay for order: %w", err)
+	}
+
+	cards := make([]*models.Account, 0)
+	balance := models.Money(0)
+
+	for _, account := range ws.accounts[userID] {
+		if account.Type == models.AccountTypeCard && account.Currency == models.CurrencyRUB {
+			cards = append(cards, account)
+			balance += account.Balance
+		}
+	}
+
+	if balance < amount {
+		return models.OrderPayment{}, errInsufficientFunds
+	}
+
+	slices.SortFunc(cards, func(a, b *models.Account) int { return strings.Compare(a.ID, b.ID) })
+
+	before := ws.snapshot(userID)
+
+	payment := models.OrderPayment{
+		Method:        models.PaymentMethodWallet,
+		Amount:        amount,
+		TransactionID: uuid.NewString(),
+	}
+
+	left := amount
+	for _, card := range cards {
+		charge := min(card.Balance, left)
+		if charge > 0 && payment.AccountID == "" {
+			payment.AccountID = card.ID
+		}
+
+		card.Balance -= charge
+		left -= charge
+	}
+
+	ws.transactions[userID] = append(ws.transactions[userID], ws.withDefaults(models.Transaction{
+		ID:       payment.TransactionID,
+		Amount:   -amount,
+		Currency: models.CurrencyRUB,
+		Title:    "Оплата заказа",
+		Time:     ws.clock.Now(),
+		Category: models.TransactionCategoryFood,
+		OrderID:  orderID,
+	}))
+
+	if err := ws.persist(ctx, before); err != nil {
+		return models.OrderPayment{}, fmt.Errorf("pay for order: %w", err)
+	}
+
+	ws.stats.WalletOperation(userID, models.TransactionCategoryFood, amount)
+
+	ws.logger.Debugw("Order paid from wallet", "userId", userID, "orderId", orderID, "amount", amount)
+
+	return payment, nil
+}
+
+// CreditRefund зачисляет возврат за заказ на первую рублевую карту пользователя, с карт в этом порядке
+// списывается оплата заказа.
+func (ws *WalletService) CreditRefund(userID, orderID string, amount models.Money, refundID string) error {
+	ws.mux.Lock()
+	defer ws.mux.Unlock()
+
+	var card *models.Account
+	for _, account := range ws.accounts[userID] {
+		if account.Type == models.AccountTypeCard && account.Currency == models.CurrencyRUB &&
+			(card == nil || account.ID < card.ID) {
+			card = account
+		}
+	}
+
+	if card == nil {
+		return fmt.Errorf("%w: user has no card to refund to", models.ErrNotFound)
+	}
+
+	before := ws.snapshot(userID)
+
+	card.Balance += amount
+
+	ws.transactions[userID] = append(ws.transactions[userID], ws.withDefaults(models.Transaction{
+		Amount:   amount,
+		Currency: models.CurrencyRUB,
+		Title:    "Возврат за заказ",
+		Time:     ws.clock.Now(),
+		Category: models.TransactionCategoryRefund,
+		OrderID:  orderID,
+	}))
+
+	if err := ws.persist(context.Background(), before); err != nil {
+		return err
+	}
+
+	ws.stats.WalletOperation(userID, models.TransactionCategoryRefund, amount)
+
+	ws.logger.Debugw("Order refund credited", "userId", userID, "orderId", orderID, "refundId", refundID, "amount", amount)
+
+	return nil
 }
 
 // GetBackupData возвращает данные для бэкапа
@@ -356,65 +1144,219 @@ func (ws *WalletService) GetBackupData() interface{} {
 	ws.mux.RLock()
 	defer ws.mux.RUnlock()
 
-	// Создаем структуру для бэкапа
-	backupData := struct {
-		Accounts     map[string]map[string]*models.Account `json:"accounts"`
-		Transactions map[string][]models.Transaction       `json:"transactions"`
-		DailyTopups  map[string]map[string]int             `json:"daily_topups"`
-		UserPhones   map[string]string                     `json:"user_phones"`
-	}{
-		Accounts:     make(map[string]map[string]*models.Account),
-		Transactions: make(map[string][]models.Transaction),
-		DailyTopups:  make(map[string]map[string]int),
-		UserPhones:   make(map[string]string),
+	return copyWalletData(models.WalletData{
+		Accounts:     ws.accounts,
+		Transactions: ws.transactions,
+		DailyTopups:  ws.dailyTopups,
+		UserPhones:   ws.userPhones,
+		Contacts:     ws.savedContacts,
+	})
+}
+
+// ResetUser возвращает кошелек пользователя к исходному состоянию
+func (ws *WalletService) ResetUser(userID string) {
+	ws.mux.Lock()
+	defer ws.mux.Unlock()
+
+	before := ws.snapshot(userID)
+
+	delete(ws.accounts, userID)
+	delete(ws.transactions, userID)
+	delete(ws.dailyTopups, userID)
+	delete(ws.userPhones, userID)
+	delete(ws.savedContacts, userID)
+
+	if accounts, ok := ws.seed.Accounts[userID]; ok {
+		ws.accounts[userID] = copyAccounts(accounts)
+	}
+
+	if transactions, ok := ws.seed.Transactions[userID]; ok {
+		ws.transactions[userID] = slices.Clone(transactions)
+	}
+
+	if dailyTopups, ok := ws.seed.DailyTopups[userID]; ok {
+		ws.dailyTopups[userID] = maps.Clone(dailyTopups)
+	}
+
+	if phone, ok := ws.seed.UserPhones[userID]; ok {
+		ws.userPhones[userID] = phone
+	}
+
+	if contacts, ok := ws.seed.Contacts[userID]; ok {
+		ws.savedContacts[userID] = maps.Clone(contacts)
+	}
+
+	// Ошибку уже записал persist, кошелек остался прежним
+	_ = ws.persist(context.Background(), before)
+}
+
+// users пользователи, у которых есть кошелек. Вызывается под блокировкой.
+func (ws *WalletService) users() []string {
+	return models.WalletData{
+		Accounts:     ws.accounts,
+		Transactions: ws.transactions,
+		DailyTopups:  ws.dailyTopups,
+		UserPhones:   ws.userPhones,
+		Contacts:     ws.savedContacts,
+	}.Users()
+}
+
+// copyWalletData создает глубокую копию данных кошелька
+func copyWalletData(data models.WalletData) models.WalletData {
+	result := models.WalletData{
+		Accounts:     make(map[string]map[string]*models.Account, len(data.Accounts)),
+		Transactions: make(map[string][]models.Transaction, len(data.Transactions)),
+		DailyTopups:  make(map[string]map[string]models.Money, len(data.DailyTopups)),
+		UserPhones:   make(map[string]string, len(data.UserPhones)),
+		Contacts:     make(map[string]map[string]models.SavedWalletContact, len(data.Contacts)),
 	}
 
 	// Копируем аккаунты
-	for userID, accounts := range ws.accounts {
-		backupAccounts := make(map[string]*models.Account)
-		for accountID, account := range accounts {
-			backupAccount := &models.Account{
-				ID:      account.ID,
-				Type:    account.Type,
-				Balance: account.Balance,
-			}
-			backupAccounts[accountID] = backupAccount
-		}
-		backupData.Accounts[userID] = backupAccounts
+	for userID, accounts := range data.Accounts {
+		result.Accounts[userID] = copyAccounts(accounts)
 	}
 
 	// Копируем транзакции
-	for userID, transactions := range ws.transactions {
-		backupTransactions := make([]models.Transaction, len(transactions))
-		for i, transaction := range transactions {
-			backupTransactions[i] = models.Transaction{
-				Amount: transaction.Amount,
-				Title:  transaction.Title,
-				Time:   transaction.Time,
-				Icon:   transaction.Icon,
-			}
-		}
-		backupData.Transactions[userID] = backupTransactions
+	for userID, transactions := range data.Transactions {
+		result.Transactions[userID] = slices.Clone(transactions)
 	}
 
 	// Копируем дневные пополнения
-	for userID, dailyTopups := range ws.dailyTopups {
-		backupDailyTopups := make(map[string]int)
-		for date, amount := range dailyTopups {
-			backupDailyTopups[date] = amount
-		}
-		backupData.DailyTopups[userID] = backupDailyTopups
+	for userID, dailyTopups := range data.DailyTopups {
+		result.DailyTopups[userID] = maps.Clone(dailyTopups)
 	}
 
 	// Копируем номера телефонов
-	for userID, phone := range ws.userPhones {
-		backupData.UserPhones[userID] = phone
+	maps.Copy(result.UserPhones, data.UserPhones)
+
+	for userID, contacts := range data.Contacts {
+		result.Contacts[userID] = maps.Clone(contacts)
+	}
+
+	return result
+}
+
+func copyAccounts(accounts map[string]*models.Account) map[string]*models.Account {
+	result := make(map[string]*models.Account, len(accounts))
+	for accountID, account := range accounts {
+		accountCopy := *account
+		accountCopy.Currency = accountCurrency(accountCopy)
+		result[accountID] = &accountCopy
 	}
 
-	return backupData
+	return result
 }
 
 // GetBackupFileName возвращает имя файла для бэкапа
 func (ws *WalletService) GetBackupFileName() string {
 	return "wallet_data"
 }
+
+// RestoreBackupData заменяет счета, транзакции и лимиты данными из бэкапа
+func (ws *WalletService) RestoreBackupData(data []byte) error {
+	var backup models.WalletData
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return fmt.Errorf("can't parse wallet data: %w", err)
+	}
+
+	ws.mux.Lock()
+	defer ws.mux.Unlock()
+
+	// В репозиторий записываются и кошельки из бэкапа, и удаление тех, которых в бэкапе нет
+	before := ws.snapshot(slices.Concat(ws.users(), backup.Users())...)
+
+	ws.replaceData(backup)
+
+	return ws.persist(context.Background(), before)
+}
+
+// ReplaceData заменяет кошельки всех пользователей перечитанными из репозитория, в репозиторий они
+// не записываются
+func (ws *WalletService) ReplaceData(data models.WalletData) {
+	ws.mux.Lock()
+	defer ws.mux.Unlock()
+
+	ws.replaceData(data)
+}
+
+// replaceData вызывается под блокировкой
+func (ws *WalletService) replaceData(data models.WalletData) {
+	// Копия нужна ради пустых map вместо отсутствующих в файле полей
+	data = copyWalletData(data)
+
+	ws.accounts = data.Accounts
+	ws.transactions = data.Transactions
+	ws.dailyTopups = data.DailyTopups
+	ws.userPhones = data.UserPhones
+	ws.savedContacts = data.Contacts
+}
+
+// CollectionSizes возвращает число счетов, транзакций и записей дневных лимитов
+func (ws *WalletService) CollectionSizes(_ context.Context) map[string]int {
+	ws.mux.RLock()
+	defer ws.mux.RUnlock()
+
+	accounts, transactions, dailyTopups, contacts := 0, 0, 0, 0
+
+	for _, userAccounts := range ws.accounts {
+		accounts += len(userAccounts)
+	}
+
+	for _, userTransactions := range ws.transactions {
+		transactions += len(userTransactions)
+	}
+
+	for _, days := range ws.dailyTopups {
+		dailyTopups += len(days)
+	}
+
+	for _, userContacts := range ws.savedContacts {
+		contacts += len(userContacts)
+	}
+
+	return map[string]int{
+		"wallet.users":        len(ws.accounts),
+		"wallet.accounts":     accounts,
+		"wallet.transactions": transactions,
+		"wallet.dailyTopups":  dailyTopups,
+		"wallet.contacts":     contacts,
+	}
+}
+
+// ExportUserData возвращает счета и все транзакции пользователя, новые первыми. Кошелек не создается,
+// если пользователь его еще не открывал.
+func (ws *WalletService) ExportUserData(ctx context.Context) (any, error) {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	ws.mux.RLock()
+	defer ws.mux.RUnlock()
+
+	export := models.WalletExport{
+		Accounts:     make([]models.Account, 0, len(ws.accounts[userID])),
+		Transactions: make([]models.Transaction, 0, len(ws.transactions[userID])),
+	}
+
+	for _, account := range ws.accounts[userID] {
+		export.Accounts = append(export.Accounts, *account)
+	}
+
+	slices.SortFunc(export.Accounts, func(a, b models.Account) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	for _, transaction := range ws.transactions[userID] {
+		export.Transactions = append(export.Transactions, ws.withDefaults(transaction))
+	}
+
+	slices.SortStableFunc(export.Transactions, func(a, b models.Transaction) int {
+		return b.Time.Compare(a.Time)
+	})
+
+	export.Contacts = ws.contacts(userID)
+
+	return export, nil
+}
+
+func (ws *WalletService) GetUserExportName() string {
+	return "wallet"
+}
@@ -0,0 +1,76 @@
+package service_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"eats-backend/internal/models"
+	"eats-backend/internal/service"
+)
+
+func TestWalletService_UpdateAccount(t *testing.T) {
+	wallet := service.NewWalletService(
+		testWalletProfiles{},
+		testWalletEvents{},
+		&testWalletGuard{},
+		testWalletPINs{},
+		testWalletStats{},
+		testWalletIcons{},
+		service.NewStaticRates(nil),
+		[]models.Currency{models.CurrencyRUB},
+		nil,
+		zap.NewNop().Sugar(),
+		models.WalletData{},
+	)
+
+	alice := walletContext(t, "alice")
+
+	account, err := wallet.OpenAccount(alice, models.OpenAccountRequest{})
+	require.NoError(t, err)
+
+	name, color, icon := "  На отпуск ", "#FFAA00", models.AccountIconTravel
+
+	updated, err := wallet.UpdateAccount(alice, account.ID, models.AccountPatch{Name: &name, Color: &color, Icon: &icon})
+	require.NoError(t, err)
+	require.Equal(t, "На отпуск", updated.Name)
+	require.Equal(t, "#ffaa00", updated.Color)
+	require.Equal(t, models.AccountIconTravel, updated.Icon)
+
+	// Поля, которых нет в запросе, не меняются, пустая строка сбрасывает поле
+	empty := ""
+
+	updated, err = wallet.UpdateAccount(alice, account.ID, models.AccountPatch{Color: &empty})
+	require.NoError(t, err)
+	require.Equal(t, "На отпуск", updated.Name)
+	require.Empty(t, updated.Color)
+
+	badColor, badIcon, longName := "red", models.AccountIcon("rocket"), "Очень длинное название для карточки счета"
+
+	for _, patch := range []models.AccountPatch{
+		{Color: &badColor},
+		{Icon: &badIcon},
+		{Name: &longName},
+	} {
+		_, err = wallet.UpdateAccount(alice, account.ID, patch)
+		require.ErrorIs(t, err, models.ErrBadRequest)
+	}
+
+	_, err = wallet.UpdateAccount(walletContext(t, "bob"), account.ID, models.AccountPatch{Name: &name})
+	require.ErrorIs(t, err, models.ErrNotFound)
+
+	// Оформление возвращается в GET /wallet и сохраняется в бэкапе
+	aliceWallet, err := wallet.GetWallet(alice)
+	require.NoError(t, err)
+	require.Contains(t, aliceWallet.Accounts, *updated)
+
+	backup, err := json.Marshal(wallet.GetBackupData())
+	require.NoError(t, err)
+	require.NoError(t, wallet.RestoreBackupData(backup))
+
+	aliceWallet, err = wallet.GetWallet(alice)
+	require.NoError(t, err)
+	require.Contains(t, aliceWallet.Accounts, *updated)
+}
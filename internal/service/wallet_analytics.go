@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"eats-backend/internal/models"
+)
+
+const dateLayout = "2006-01-02"
+
+var analyticsCategories = []models.TransactionCategory{
+	models.TransactionCategoryFood,
+	models.TransactionCategoryTransfer,
+	models.TransactionCategoryTopup,
+	models.TransactionCategoryRefund,
+	models.TransactionCategoryOther,
+}
+
+// GetAnalytics собирает доходы и траты пользователя по категориям за текущий календарный период
+// (неделя с понедельника или месяц с первого числа) и сравнивает их с предыдущим периодом целиком.
+func (ws *WalletService) GetAnalytics(ctx context.Context, period models.AnalyticsPeriod) (*models.WalletAnalytics, error) {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	if period == "" {
+		period = models.AnalyticsPeriodMonth
+	}
+
+	now := ws.clock.Now()
+
+	var from, previousFrom time.Time
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	switch period {
+	case models.AnalyticsPeriodWeek:
+		daysSinceMonday := (int(today.Weekday()) + 6) % 7
+		from = today.AddDate(0, 0, -daysSinceMonday)
+		previousFrom = from.AddDate(0, 0, -7)
+	case models.AnalyticsPeriodMonth:
+		from = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		previousFrom = from.AddDate(0, -1, 0)
+	default:
+		return nil, fmt.Errorf("%w: unknown period %s, should be week or month", models.ErrBadRequest, period)
+	}
+
+	result := &models.WalletAnalytics{
+		Period:     period,
+		From:       from.Format(dateLayout),
+		To:         today.Format(dateLayout),
+		Categories: make([]models.CategoryAnalytics, len(analyticsCategories)),
+		Previous: models.PreviousPeriodComparison{
+			From: previousFrom.Format(dateLayout),
+			To:   from.AddDate(0, 0, -1).Format(dateLayout),
+		},
+	}
+
+	categoryIndex := make(map[models.TransactionCategory]int, len(analyticsCategories))
+	for i, category := range analyticsCategories {
+		result.Categories[i].Category = category
+		categoryIndex[category] = i
+	}
+
+	dayIndex := make(map[string]int)
+	for day := from; !day.After(today); day = day.AddDate(0, 0, 1) {
+		dayIndex[day.Format(dateLayout)] = len(result.Daily)
+		result.Daily = append(result.Daily, models.DailyAnalytics{Date: day.Format(dateLayout)})
+	}
+
+	ws.mux.RLock()
+	defer ws.mux.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("analytics: %w", err)
+	}
+
+	for _, transaction := range ws.transactions[userID] {
+		transactionTime := transaction.Time.In(now.Location())
+
+		// Счета в разных валютах сводятся в рубли по текущему курсу
+		amount, err := ws.inRubles(transaction.Amount, transaction.Currency)
+		if err != nil {
+			continue
+		}
+
+		income, expenses := max(amount, 0), max(-amount, 0)
+
+		switch {
+		case !transactionTime.Before(from):
+			result.Income += income
+			result.Expenses += expenses
+
+			category := &result.Categories[categoryIndex[categorizeTransaction(transaction)]]
+			category.Income += income
+			category.Expenses += expenses
+			category.Count++
+
+			if i, ok := dayIndex[transactionTime.Format(dateLayout)]; ok {
+				result.Daily[i].Income += income
+				result.Daily[i].Expenses += expenses
+			}
+		case !transactionTime.Before(previousFrom):
+			result.Previous.Income += income
+			result.Previous.Expenses += expenses
+		}
+	}
+
+	if result.Previous.Expenses > 0 {
+		change := float64(result.Expenses-result.Previous.Expenses) / float64(result.Previous.Expenses) * 100
+		change = math.Round(change*10) / 10
+		result.Previous.ExpensesChange = &change
+	}
+
+	return result, nil
+}
+
+// categorizeTransaction возвращает категорию транзакции. У транзакций из старых данных
+// категории нет, для них она определяется по названию.
+func categorizeTransaction(transaction models.Transaction) models.TransactionCategory {
+	if transaction.Category != "" {
+		return transaction.Category
+	}
+
+	title := strings.ToLower(transaction.Title)
+
+	switch {
+	case strings.HasPrefix(title, "пополнение"):
+		return models.TransactionCategoryTopup
+	case strings.HasPrefix(title, "перевод"):
+		return models.TransactionCategoryTransfer
+	case strings.Contains(title, "заказ"),
+		strings.Contains(title, "еды"),
+		strings.Contains(title, "супермаркет"),
+		strings.Contains(title, "кафе"):
+		return models.TransactionCategoryFood
+	default:
+		return models.TransactionCategoryOther
+	}
+}
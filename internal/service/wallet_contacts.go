@@ -0,0 +1,227 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"eats-backend/internal/models"
+)
+
+const (
+	// Сколько недавних получателей без сохраненного имени показывать на экране перевода
+	recentContactsLimit = 20
+	// Длина имени сохраненного контакта в символах
+	maxContactNameLength = 64
+)
+
+// GetContacts возвращает получателей для экрана перевода: сначала сохраненные по имени,
+// затем недавние, начиная с последнего перевода
+func (ws *WalletService) GetContacts(ctx context.Context) []models.WalletContact {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	ws.mux.RLock()
+	defer ws.mux.RUnlock()
+
+	return ws.contacts(userID)
+}
+
+// contacts сохраненные и недавние получатели пользователя. Вызывать под ws.mux.
+func (ws *WalletService) contacts(userID string) []models.WalletContact {
+	lastTransfers := ws.lastTransfers(userID)
+	saved := ws.savedContacts[userID]
+
+	favorites := make([]models.WalletContact, 0, len(saved))
+	for contactID, contact := range saved {
+		phone, ok := ws.userData.GetUserPhone(contactID)
+		if !ok {
+			continue
+		}
+
+		favorites = append(favorites, models.WalletContact{
+			ID:             contactID,
+			Phone:          maskPhone(phone),
+			Name:           contact.Name,
+			Favorite:       true,
+			LastTransferAt: lastTransfers[contactID],
+		})
+	}
+
+	slices.SortFunc(favorites, func(a, b models.WalletContact) int {
+		return cmp.Or(strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)), strings.Compare(a.ID, b.ID))
+	})
+
+	recents := make([]models.WalletContact, 0, len(lastTransfers))
+	for contactID, lastTransferAt := range lastTransfers {
+		if _, ok := saved[contactID]; ok {
+			continue
+		}
+
+		phone, ok := ws.userData.GetUserPhone(contactID)
+		if !ok {
+			continue
+		}
+
+		recents = append(recents, models.WalletContact{
+			ID:             contactID,
+			Phone:          maskPhone(phone),
+			LastTransferAt: lastTransferAt,
+		})
+	}
+
+	slices.SortFunc(recents, func(a, b models.WalletContact) int {
+		return cmp.Or(b.LastTransferAt.Compare(*a.LastTransferAt), strings.Compare(a.ID, b.ID))
+	})
+
+	if len(recents) > recentContactsLimit {
+		recents = recents[:recentContactsLimit]
+	}
+
+	return append(favorites, recents...)
+}
+
+// lastTransfers время последнего перевода каждому получателю. Вызывать под ws.mux.
+func (ws *WalletService) lastTransfers(userID string) map[string]*time.Time {
+	result := make(map[string]*time.Time)
+
+	for _, transaction := range ws.transactions[userID] {
+		if transaction.Category != models.TransactionCategoryTransfer || transaction.Amount >= 0 ||
+			transaction.CounterpartyUserID == "" {
+			continue
+		}
+
+		if last, ok := result[transaction.CounterpartyUserID]; !ok || transaction.Time.After(*last) {
+			transferTime := transaction.Time
+			result[transaction.CounterpartyUserID] = &transferTime
+		}
+	}
+
+	return result
+}
+
+// SaveContact сохраняет получателя под именем пользователя, повторный вызов меняет имя
+func (ws *WalletService) SaveContact(ctx context.Context, req models.SaveWalletContactRequest) (models.WalletContact, error) {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return models.WalletContact{}, fmt.Errorf("%w: contact name is required", models.ErrBadRequest)
+	}
+
+	if utf8.RuneCountInString(name) > maxContactNameLength {
+		return models.WalletContact{}, fmt.Errorf("%w: contact name should be at most %d characters", models.ErrBadRequest, maxContactNameLength)
+	}
+
+	ws.mux.Lock()
+	defer ws.mux.Unlock()
+
+	contactID, found := ws.userData.GetUserIDByPhone(strings.TrimSpace(req.Phone))
+	if !found {
+		return models.WalletContact{}, fmt.Errorf("%w: recipient not found", models.ErrNotFound)
+	}
+
+	if contactID == userID {
+		return models.WalletContact{}, fmt.Errorf("%w: cannot save yourself as a contact", models.ErrBadRequest)
+	}
+
+	before := ws.snapshot(userID)
+
+	if ws.savedContacts[userID] == nil {
+		ws.savedContacts[userID] = make(map[string]models.SavedWalletContact)
+	}
+
+	ws.savedContacts[userID][contactID] = models.SavedWalletContact{Name: name, SavedAt: ws.clock.Now()}
+
+	if err := ws.persist(ctx, before); err != nil {
+		return models.WalletContact{}, fmt.Errorf("save contact: %w", err)
+	}
+
+	phone, _ := ws.userData.GetUserPhone(contactID)
+
+	return models.WalletContact{
+		ID:             contactID,
+		Phone:          maskPhone(phone),
+		Name:           name,
+		Favorite:       true,
+		LastTransferAt: ws.lastTransfers(userID)[contactID],
+	}, nil
+}
+
+// DeleteContact удаляет сохраненный контакт. Недавний получатель остается в списке, пока есть переводы ему.
+func (ws *WalletService) DeleteContact(ctx context.Context, contactID string) error {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	ws.mux.Lock()
+	defer ws.mux.Unlock()
+
+	if _, ok := ws.savedContacts[userID][contactID]; !ok {
+		return fmt.Errorf("%w: contact %s not found", models.ErrNotFound, contactID)
+	}
+
+	before := ws.snapshot(userID)
+
+	delete(ws.savedContacts[userID], contactID)
+
+	if err := ws.persist(ctx, before); err != nil {
+		return fmt.Errorf("delete contact: %w", err)
+	}
+
+	return nil
+}
+
+// contactPhone номер телефона получателя из контактов пользователя для перевода по toContactId.
+// Вызывать под ws.mux.
+func (ws *WalletService) contactPhone(userID, contactID string) (string, error) {
+	_, saved := ws.savedContacts[userID][contactID]
+	if _, recent := ws.lastTransfers(userID)[contactID]; !saved && !recent {
+		return "", fmt.Errorf("%w: contact %s not found", models.ErrNotFound, contactID)
+	}
+
+	phone, ok := ws.userData.GetUserPhone(contactID)
+	if !ok {
+		return "", fmt.Errorf("%w: recipient not found", models.ErrNotFound)
+	}
+
+	return phone, nil
+}
+
+// maskPhone скрывает цифры номера, кроме двух первых и четырех последних: 79123456789 -> 79*****6789
+func maskPhone(phone string) string {
+	digits := 0
+	for _, r := range phone {
+		if unicode.IsDigit(r) {
+			digits++
+		}
+	}
+
+	keepStart, keepEnd := 2, 4
+	if digits <= keepStart+keepEnd {
+		keepStart, keepEnd = 0, min(2, digits)
+	}
+
+	var masked strings.Builder
+
+	position := 0
+	for _, r := range phone {
+		if !unicode.IsDigit(r) {
+			masked.WriteRune(r)
+
+			continue
+		}
+
+		if position < keepStart || position >= digits-keepEnd {
+			masked.WriteRune(r)
+		} else {
+			masked.WriteRune('*')
+		}
+
+		position++
+	}
+
+	return masked.String()
+}
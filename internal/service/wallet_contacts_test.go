@@ -0,0 +1,103 @@
+package service_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"eats-backend/internal/models"
+	"eats-backend/internal/service"
+)
+
+func TestWalletService_Contacts(t *testing.T) {
+	newWallet := func(data models.WalletData) *service.WalletService {
+		return service.NewWalletService(
+			testWalletProfiles{"+70000000000": "alice", "+71111111111": "bob", "+72222222222": "carol"},
+			testWalletEvents{},
+			&testWalletGuard{},
+			testWalletPINs{},
+			testWalletStats{},
+			testWalletIcons{},
+			service.NewStaticRates(nil),
+			[]models.Currency{models.CurrencyRUB},
+			nil,
+			zap.NewNop().Sugar(),
+			data,
+		)
+	}
+
+	wallet := newWallet(models.WalletData{Accounts: map[string]map[string]*models.Account{
+		"alice": {"alice-card": {ID: "alice-card", Type: models.AccountTypeCard, Balance: models.Rubles(1000)}},
+		"bob":   {"bob-card": {ID: "bob-card", Type: models.AccountTypeCard}},
+		"carol": {"carol-card": {ID: "carol-card", Type: models.AccountTypeCard}},
+	}})
+
+	alice := walletContext(t, "alice")
+
+	require.Empty(t, wallet.GetContacts(alice))
+
+	_, err := wallet.TransferMoney(alice, models.TransferRequest{
+		FromAccountID: "alice-card", ToPhoneNumber: "+71111111111", Amount: models.Rubles(10),
+	})
+	require.NoError(t, err)
+
+	contacts := wallet.GetContacts(alice)
+	require.Len(t, contacts, 1)
+	require.Equal(t, "bob", contacts[0].ID)
+	require.Equal(t, "+71*****1111", contacts[0].Phone)
+	require.False(t, contacts[0].Favorite)
+	require.NotNil(t, contacts[0].LastTransferAt)
+
+	// Переводить по toContactId можно только своим контактам
+	_, err = wallet.TransferMoney(alice, models.TransferRequest{FromAccountID: "alice-card", ToContactID: "carol", Amount: models.Rubles(5)})
+	require.ErrorIs(t, err, models.ErrNotFound)
+
+	_, err = wallet.SaveContact(alice, models.SaveWalletContactRequest{Phone: "+72222222222", Name: " "})
+	require.ErrorIs(t, err, models.ErrBadRequest)
+
+	_, err = wallet.SaveContact(alice, models.SaveWalletContactRequest{Phone: "+70000000000", Name: "Я"})
+	require.ErrorIs(t, err, models.ErrBadRequest)
+
+	_, err = wallet.SaveContact(alice, models.SaveWalletContactRequest{Phone: "+79999999999", Name: "Никто"})
+	require.ErrorIs(t, err, models.ErrNotFound)
+
+	carol, err := wallet.SaveContact(alice, models.SaveWalletContactRequest{Phone: "+72222222222", Name: " Кэрол "})
+	require.NoError(t, err)
+	require.Equal(t, models.WalletContact{ID: "carol", Phone: "+72*****2222", Name: "Кэрол", Favorite: true}, carol)
+
+	transfer, err := wallet.TransferMoney(alice, models.TransferRequest{FromAccountID: "alice-card", ToContactID: "carol", Amount: models.Rubles(5)})
+	require.NoError(t, err)
+	require.Equal(t, models.Rubles(985), transfer.Balance)
+
+	// Сохраненные контакты идут первыми
+	contacts = wallet.GetContacts(alice)
+	require.Len(t, contacts, 2)
+	require.Equal(t, "carol", contacts[0].ID)
+	require.True(t, contacts[0].Favorite)
+	require.NotNil(t, contacts[0].LastTransferAt)
+	require.Equal(t, "bob", contacts[1].ID)
+
+	// У получателя отправитель в контакты не попадает, пока он сам ему не переведет
+	require.Empty(t, wallet.GetContacts(walletContext(t, "carol")))
+
+	data, err := json.Marshal(wallet.GetBackupData())
+	require.NoError(t, err)
+
+	require.NoError(t, wallet.DeleteContact(alice, "carol"))
+	require.ErrorIs(t, wallet.DeleteContact(alice, "carol"), models.ErrNotFound)
+
+	// Удаленный контакт остается среди недавних
+	contacts = wallet.GetContacts(alice)
+	require.Len(t, contacts, 2)
+	require.False(t, contacts[0].Favorite)
+	require.False(t, contacts[1].Favorite)
+
+	restored := newWallet(models.WalletData{})
+	require.NoError(t, restored.RestoreBackupData(data))
+	require.True(t, restored.GetContacts(alice)[0].Favorite)
+
+	restored.ResetUser("alice")
+	require.Empty(t, restored.GetContacts(alice))
+}
@@ -0,0 +1,147 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"eats-backend/internal/events"
+	"eats-backend/internal/models"
+	"eats-backend/internal/service"
+)
+
+type testWalletProfiles map[string]string // phone -> userID
+
+func (p testWalletProfiles) GetProfile(context.Context) (models.UserProfile, error) {
+	return models.UserProfile{Phone: "+70000000000"}, nil
+}
+
+func (p testWalletProfiles) GetUserIDByPhone(phone string) (string, bool) {
+	userID, ok := p[phone]
+	return userID, ok
+}
+
+func (p testWalletProfiles) GetUserPhone(userID string) (string, bool) {
+	for phone, id := range p {
+		if id == userID {
+			return phone, true
+		}
+	}
+
+	return "", false
+}
+
+type testWalletEvents struct{}
+
+func (testWalletEvents) Publish(context.Context, events.Event) {}
+
+type testWalletGuard struct{ amounts []models.Money }
+
+func (g *testWalletGuard) Check(op models.WalletOperation, _ []models.Transaction) error {
+	g.amounts = append(g.amounts, op.Amount)
+	return nil
+}
+
+type testWalletPINs struct{}
+
+func (testWalletPINs) Confirm(string, string, models.Money) error { return nil }
+
+type testWalletStats struct{}
+
+func (testWalletStats) WalletOperation(string, models.TransactionCategory, models.Money) {}
+
+type testWalletIcons struct{}
+
+func (testWalletIcons) Icon(models.IconKind) string { return "" }
+
+func walletContext(t *testing.T, userID string) context.Context {
+	return context.WithValue(t.Context(), models.ContextClaimsKey{}, &models.AuthTokenClaims{
+		RegisteredClaims: &jwt.RegisteredClaims{ID: userID},
+	})
+}
+
+func TestStaticRates(t *testing.T) {
+	rates := service.NewStaticRates(map[models.Currency]float64{"USD": 90, "EUR": 100})
+
+	rate, err := rates.Rate("USD", models.CurrencyRUB)
+	require.NoError(t, err)
+	require.Equal(t, models.Rubles(900), rate.Convert(models.Rubles(10)))
+
+	rate, err = rates.Rate(models.CurrencyRUB, "USD")
+	require.NoError(t, err)
+	require.Equal(t, 0.011111, rate.Rate)
+	require.Equal(t, models.Kopecks(111), rate.Convert(models.Rubles(100)))
+
+	rate, err = rates.Rate("EUR", "USD")
+	require.NoError(t, err)
+	require.Equal(t, 1.111111, rate.Rate)
+
+	_, err = rates.Rate("GBP", models.CurrencyRUB)
+	require.ErrorIs(t, err, models.ErrBadRequest)
+}
+
+func TestWalletService_CrossCurrencyTransfer(t *testing.T) {
+	guard := &testWalletGuard{}
+	wallet := service.NewWalletService(
+		testWalletProfiles{"+71111111111": "bob"},
+		testWalletEvents{},
+		guard,
+		testWalletPINs{},
+		testWalletStats{},
+		testWalletIcons{},
+		service.NewStaticRates(map[models.Currency]float64{"USD": 90}),
+		[]models.Currency{models.CurrencyRUB, "USD"},
+		nil,
+		zap.NewNop().Sugar(),
+		models.WalletData{Accounts: map[string]map[string]*models.Account{
+			// Счет из старых данных без валюты считается рублевым
+			"bob": {"bob-rub": {ID: "bob-rub", Type: models.AccountTypeCard, Balance: models.Rubles(100)}},
+		}},
+	)
+
+	alice := walletContext(t, "alice")
+
+	_, err := wallet.OpenAccount(alice, models.OpenAccountRequest{Currency: "GBP"})
+	require.ErrorIs(t, err, models.ErrBadRequest)
+
+	usd, err := wallet.OpenAccount(alice, models.OpenAccountRequest{Currency: "USD"})
+	require.NoError(t, err)
+	require.Equal(t, models.Currency("USD"), usd.Currency)
+	require.Zero(t, usd.Balance)
+
+	_, err = wallet.TopupAccount(alice, models.TopupRequest{AccountID: usd.ID, Amount: models.Rubles(10)})
+	require.NoError(t, err)
+	// Дневной лимит и антифрод считаются в рублях: 10 долларов - это 900 рублей
+	require.Equal(t, []models.Money{models.Rubles(900)}, guard.amounts)
+
+	_, err = wallet.TopupAccount(alice, models.TopupRequest{AccountID: usd.ID, Amount: models.Rubles(2)})
+	require.ErrorIs(t, err, models.ErrBadRequest)
+
+	transfer, err := wallet.TransferMoney(alice, models.TransferRequest{
+		FromAccountID: usd.ID, ToPhoneNumber: "+71111111111", Amount: models.Kopecks(550),
+	})
+	require.NoError(t, err)
+	require.Equal(t, models.Kopecks(450), transfer.Balance)
+	require.Equal(t, models.Currency("USD"), transfer.Currency)
+	require.Equal(t, models.Kopecks(49500), transfer.CreditedAmount)
+	require.Equal(t, models.CurrencyRUB, transfer.CreditedCurrency)
+	require.Equal(t, &models.ExchangeRate{From: "USD", To: models.CurrencyRUB, Rate: 90}, transfer.Exchange)
+
+	bobWallet, err := wallet.GetWallet(walletContext(t, "bob"))
+	require.NoError(t, err)
+	require.Equal(t, []models.Account{{
+		ID: "bob-rub", Type: models.AccountTypeCard, Balance: models.Kopecks(59500), Currency: models.CurrencyRUB,
+	}}, bobWallet.Accounts)
+
+	history, err := wallet.GetTransactions(walletContext(t, "bob"), 1, 10)
+	require.NoError(t, err)
+
+	for _, transactions := range history.Data {
+		require.Len(t, transactions, 1)
+		require.Equal(t, models.CurrencyRUB, transactions[0].Currency)
+		require.Equal(t, transfer.Exchange, transactions[0].Exchange)
+	}
+}
@@ -0,0 +1,237 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"eats-backend/internal/events"
+	"eats-backend/internal/models"
+	"eats-backend/internal/service"
+)
+
+func newPaymentWallet(t *testing.T) *service.WalletService {
+	t.Helper()
+
+	return service.NewWalletService(
+		testWalletProfiles{},
+		testWalletEvents{},
+		&testWalletGuard{},
+		testWalletPINs{},
+		testWalletStats{},
+		testWalletIcons{},
+		service.NewStaticRates(map[models.Currency]float64{"USD": 90}),
+		[]models.Currency{models.CurrencyRUB, "USD"},
+		nil,
+		zap.NewNop().Sugar(),
+		models.WalletData{Accounts: map[string]map[string]*models.Account{
+			"alice": {
+				"card-b":  {ID: "card-b", Type: models.AccountTypeCard, Balance: models.Rubles(500)},
+				"card-a":  {ID: "card-a", Type: models.AccountTypeCard, Balance: models.Rubles(300)},
+				"card-us": {ID: "card-us", Type: models.AccountTypeCard, Balance: models.Money(100000), Currency: "USD"},
+				"savings": {ID: "savings", Type: models.AccountTypeSavings, Balance: models.Rubles(10000)},
+			},
+		}},
+	)
+}
+
+func walletBalances(t *testing.T, wallet *service.WalletService, userID string) map[string]models.Money {
+	t.Helper()
+
+	data, err := wallet.GetWallet(walletContext(t, userID))
+	require.NoError(t, err)
+
+	result := make(map[string]models.Money, len(data.Accounts))
+	for _, account := range data.Accounts {
+		result[account.ID] = account.Balance
+	}
+
+	return result
+}
+
+func TestWalletService_PayForOrder(t *testing.T) {
+	wallet := newPaymentWallet(t)
+	ctx := walletContext(t, "alice")
+
+	// Валютные карты и накопительный счет в оплате не участвуют
+	_, err := wallet.PayForOrder(ctx, "order-1", models.Rubles(801))
+	require.ErrorIs(t, err, models.ErrBadRequest)
+	require.ErrorContains(t, err, "insufficient funds")
+	require.Equal(t, models.Rubles(300), walletBalances(t, wallet, "alice")["card-a"])
+	require.Equal(t, models.Rubles(500), walletBalances(t, wallet, "alice")["card-b"])
+
+	history, err := wallet.GetTransactions(ctx, 1, 10)
+	require.NoError(t, err)
+	require.Empty(t, history.Data)
+
+	// Сумма делится между картами по порядку идентификаторов
+	payment, err := wallet.PayForOrder(ctx, "order-2", models.Rubles(600))
+	require.NoError(t, err)
+	require.Equal(t, models.PaymentMethodWallet, payment.Method)
+	require.Equal(t, models.Rubles(600), payment.Amount)
+	require.Equal(t, "card-a", payment.AccountID)
+
+	balances := walletBalances(t, wallet, "alice")
+	require.Equal(t, models.Money(0), balances["card-a"])
+	require.Equal(t, models.Rubles(200), balances["card-b"])
+	require.Equal(t, models.Money(100000), balances["card-us"])
+	require.Equal(t, models.Rubles(10000), balances["savings"])
+
+	history, err = wallet.GetTransactions(ctx, 1, 10)
+	require.NoError(t, err)
+
+	var transactions []models.Transaction
+	for _, day := range history.Data {
+		transactions = append(transactions, day...)
+	}
+
+	require.Len(t, transactions, 1)
+	require.Equal(t, payment.TransactionID, transactions[0].ID)
+	require.Equal(t, "order-2", transactions[0].OrderID)
+	require.Equal(t, -models.Rubles(600), transactions[0].Amount)
+}
+
+func TestOrderService_MakeNewOrderInsufficientFunds(t *testing.T) {
+	wallet := newPaymentWallet(t)
+	cart := &testOrderCart{cart: models.CartResponse{
+		OrderPrice:    models.Rubles(900),
+		DeliveryPrice: models.Rubles(100),
+		TotalItems:    1,
+		Items:         []models.CartResponseItem{{ProductID: "cake", Price: models.Rubles(900), Quantity: 1, Available: true}},
+	}}
+
+	orders := service.NewOrderService(testOrderAddresses{}, cart, wallet, nil, testOrderDelivery{}, nil,
+		service.NewOrderExtrasCatalog(nil), events.NewBus(zap.NewNop().Sugar()), nil, zap.NewNop().Sugar(),
+		map[string][]*models.Order{})
+
+	ctx := walletContext(t, "alice")
+
+	err := orders.MakeNewOrder(ctx, &models.OrderRequest{PaymentMethod: string(models.PaymentMethodWallet), AddressID: "address-1"})
+	require.ErrorIs(t, err, models.ErrBadRequest)
+	require.ErrorContains(t, err, "insufficient funds")
+
+	// Заказ не создан, резерв корзины снят, деньги не списаны
+	placed, err := orders.GetOrders(ctx)
+	require.NoError(t, err)
+	require.Empty(t, placed)
+	require.Equal(t, 1, cart.released)
+	require.Equal(t, models.Rubles(300), walletBalances(t, wallet, "alice")["card-a"])
+	require.Equal(t, models.Rubles(500), walletBalances(t, wallet, "alice")["card-b"])
+}
+
+// testWalletRepository репозиторий кошельков в памяти, err имитирует сбой записи
+type testWalletRepository struct {
+	wallets map[string]*models.UserWallet
+	err     error
+}
+
+func (r *testWalletRepository) SaveWallets(_ context.Context, wallets map[string]*models.UserWallet) error {
+	if r.err != nil {
+		return r.err
+	}
+
+	for userID, wallet := range wallets {
+		if wallet == nil {
+			delete(r.wallets, userID)
+
+			continue
+		}
+
+		r.wallets[userID] = wallet
+	}
+
+	return nil
+}
+
+func TestWalletService_Repository(t *testing.T) {
+	wallet := newPaymentWallet(t)
+	repo := &testWalletRepository{wallets: make(map[string]*models.UserWallet)}
+	wallet.UseRepository(repo)
+
+	ctx := walletContext(t, "alice")
+
+	_, err := wallet.PayForOrder(ctx, "order-1", models.Rubles(100))
+	require.NoError(t, err)
+	require.Equal(t, models.Rubles(200), repo.wallets["alice"].Accounts["card-a"].Balance)
+	require.Len(t, repo.wallets["alice"].Transactions, 1)
+
+	// Незаписанная операция откатывается целиком, дневной лимит не тратится
+	repo.err = errors.New("disk I/O error")
+
+	_, err = wallet.PayForOrder(ctx, "order-2", models.Rubles(100))
+	require.ErrorIs(t, err, models.ErrInternalServer)
+
+	topup := models.TopupRequest{AccountID: "card-a", Amount: models.Rubles(1000)}
+	_, err = wallet.TopupAccount(ctx, topup)
+	require.ErrorIs(t, err, models.ErrInternalServer)
+
+	require.Equal(t, models.Rubles(200), walletBalances(t, wallet, "alice")["card-a"])
+
+	history, err := wallet.GetTransactions(ctx, 1, 10)
+	require.NoError(t, err)
+	require.Equal(t, 1, history.TotalPages)
+	require.Len(t, slices.Concat(slices.Collect(maps.Values(history.Data))...), 1)
+
+	repo.err = nil
+
+	_, err = wallet.TopupAccount(ctx, topup)
+	require.NoError(t, err)
+	require.Equal(t, models.Rubles(1200), repo.wallets["alice"].Accounts["card-a"].Balance)
+
+	// Новый кошелек записывается при создании, сброс возвращает исходный или удаляет кошелек
+	_, err = wallet.GetWallet(walletContext(t, "bob"))
+	require.NoError(t, err)
+	require.Contains(t, repo.wallets, "bob")
+
+	wallet.ResetUser("alice")
+	wallet.ResetUser("bob")
+	require.Equal(t, models.Rubles(300), repo.wallets["alice"].Accounts["card-a"].Balance)
+	require.Empty(t, repo.wallets["alice"].Transactions)
+	require.NotContains(t, repo.wallets, "bob")
+}
+
+func TestWalletService_NonPositiveAmounts(t *testing.T) {
+	guard := &testWalletGuard{}
+	wallet := service.NewWalletService(
+		testWalletProfiles{"+71111111111": "bob"},
+		testWalletEvents{},
+		guard,
+		testWalletPINs{},
+		testWalletStats{},
+		testWalletIcons{},
+		service.NewStaticRates(nil),
+		[]models.Currency{models.CurrencyRUB},
+		nil,
+		zap.NewNop().Sugar(),
+		models.WalletData{Accounts: map[string]map[string]*models.Account{
+			"alice": {"alice-card": {ID: "alice-card", Type: models.AccountTypeCard, Balance: models.Rubles(100)}},
+			"bob":   {"bob-card": {ID: "bob-card", Type: models.AccountTypeCard, Balance: models.Rubles(100)}},
+		}},
+	)
+
+	alice := walletContext(t, "alice")
+
+	for _, amount := range []models.Money{0, -models.Rubles(50)} {
+		_, err := wallet.TransferMoney(alice, models.TransferRequest{
+			FromAccountID: "alice-card", ToPhoneNumber: "+71111111111", Amount: amount,
+		})
+		require.ErrorIs(t, err, models.ErrBadRequest)
+
+		_, err = wallet.TopupAccount(alice, models.TopupRequest{AccountID: "alice-card", Amount: amount})
+		require.ErrorIs(t, err, models.ErrBadRequest)
+	}
+
+	// Отказ до антифрода и лимитов, балансы не изменились
+	require.Empty(t, guard.amounts)
+	require.Equal(t, models.Rubles(100), walletBalances(t, wallet, "alice")["alice-card"])
+	require.Equal(t, models.Rubles(100), walletBalances(t, wallet, "bob")["bob-card"])
+
+	// Дневной лимит не уменьшился отрицательным пополнением
+	_, err := wallet.TopupAccount(alice, models.TopupRequest{AccountID: "alice-card", Amount: models.Rubles(1001)})
+	require.ErrorIs(t, err, models.ErrBadRequest)
+}
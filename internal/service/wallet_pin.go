@@ -0,0 +1,335 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+
+	"eats-backend/internal/models"
+)
+
+// Сколько раз можно ошибиться в коде сброса PIN, прежде чем придется запросить новый.
+const walletPINResetMaxAttempts = 5
+
+// WalletPINSettings когда нужен PIN и как долго блокируется подтверждение после ошибок.
+type WalletPINSettings struct {
+	// Операции от этой суммы в рублях требуют PIN, 0 - любые.
+	Threshold   models.Money
+	MaxAttempts int
+	Lockout     time.Duration
+	// Сколько действует код для сброса PIN.
+	ResetCodeTTL time.Duration
+}
+
+// PINResetCodeSender доставляет пользователю код для сброса PIN
+type PINResetCodeSender interface {
+	WalletPINResetCode(ctx context.Context, userID, code string, expiresAt time.Time)
+}
+
+type pinReset struct {
+	code      string
+	expiresAt time.Time
+	attempts  int
+}
+
+// WalletPINService хранит необязательные PIN кошелька и проверяет их у крупных переводов и оплат.
+// После MaxAttempts ошибок подряд подтверждение блокируется на Lockout, забытый PIN сбрасывается
+// кодом из уведомления.
+type WalletPINService struct {
+	settings WalletPINSettings
+	codes    PINResetCodeSender
+	clock    Clock
+	logger   *zap.SugaredLogger
+
+	pins map[string]*models.WalletPINState
+	// Коды сброса живут минуты и не сохраняются в бэкапах.
+	resets map[string]*pinReset
+
+	mux sync.Mutex
+}
+
+func NewWalletPINService(
+	settings WalletPINSettings, codes PINResetCodeSender, clock Clock, logger *zap.SugaredLogger,
+) *WalletPINService {
+	return &WalletPINService{
+		settings: settings,
+		codes:    codes,
+		clock:    clockOrSystem(clock),
+		logger:   logger,
+		pins:     make(map[string]*models.WalletPINState),
+		resets:   make(map[string]*pinReset),
+	}
+}
+
+// GetStatus установлен ли PIN у пользователя и не заблокировано ли подтверждение
+func (s *WalletPINService) GetStatus(ctx context.Context) models.WalletPINStatus {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	status := models.WalletPINStatus{Threshold: s.settings.Threshold, AttemptsLeft: s.settings.MaxAttempts}
+
+	state, ok := s.pins[userID]
+	if !ok {
+		return status
+	}
+
+	s.unlockExpired(state, s.clock.Now())
+
+	status.Enabled = true
+	status.AttemptsLeft = s.settings.MaxAttempts - state.FailedAttempts
+	status.LockedUntil = state.LockedUntil
+
+	return status
+}
+
+// SetPIN устанавливает PIN. Установленный PIN меняется только с текущим, проверка считает попытки
+// так же, как подтверждение операций.
+func (s *WalletPINService) SetPIN(ctx context.Context, req models.WalletPINRequest) (models.WalletPINStatus, error) {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	if err := validatePIN(req.PIN); err != nil {
+		return models.WalletPINStatus{}, err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.PIN), bcrypt.DefaultCost)
+	if err != nil {
+		return models.WalletPINStatus{}, fmt.Errorf("%w: can't hash pin: %w", models.ErrInternalServer, err)
+	}
+
+	s.mux.Lock()
+
+	if state, ok := s.pins[userID]; ok {
+		if err := s.verify(state, req.CurrentPIN, s.clock.Now()); err != nil {
+			s.mux.Unlock()
+
+			return models.WalletPINStatus{}, err
+		}
+	}
+
+	s.pins[userID] = &models.WalletPINState{Hash: string(hash)}
+	s.mux.Unlock()
+
+	s.logger.Debugw("Wallet PIN set", "userId", userID)
+
+	return s.GetStatus(ctx), nil
+}
+
+// Confirm проверяет PIN операции на amount рублей. Без установленного PIN и ниже порога PIN не нужен.
+func (s *WalletPINService) Confirm(userID, pin string, amount models.Money) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	state, ok := s.pins[userID]
+	if !ok || amount < s.settings.Threshold {
+		return nil
+	}
+
+	return s.verify(state, pin, s.clock.Now())
+}
+
+// RequestReset отправляет код для сброса забытого PIN. Новый запрос заменяет прежний код.
+func (s *WalletPINService) RequestReset(ctx context.Context) (models.WalletPINResetResponse, error) {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	code, err := generateVerificationCode()
+	if err != nil {
+		return models.WalletPINResetResponse{}, fmt.Errorf("%w: can't generate reset code: %w", models.ErrInternalServer, err)
+	}
+
+	expiresAt := s.clock.Now().Add(s.settings.ResetCodeTTL)
+
+	s.mux.Lock()
+
+	if _, ok := s.pins[userID]; !ok {
+		s.mux.Unlock()
+
+		return models.WalletPINResetResponse{}, fmt.Errorf("%w: wallet pin is not set", models.ErrNotFound)
+	}
+
+	s.resets[userID] = &pinReset{code: code, expiresAt: expiresAt}
+	s.mux.Unlock()
+
+	s.codes.WalletPINResetCode(ctx, userID, code, expiresAt)
+
+	return models.WalletPINResetResponse{ExpiresAt: expiresAt}, nil
+}
+
+// ConfirmReset устанавливает новый PIN по коду сброса и снимает блокировку подтверждения
+func (s *WalletPINService) ConfirmReset(
+	ctx context.Context,
+	req models.WalletPINResetConfirmRequest,
+) (models.WalletPINStatus, error) {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	if err := validatePIN(req.PIN); err != nil {
+		return models.WalletPINStatus{}, err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.PIN), bcrypt.DefaultCost)
+	if err != nil {
+		return models.WalletPINStatus{}, fmt.Errorf("%w: can't hash pin: %w", models.ErrInternalServer, err)
+	}
+
+	s.mux.Lock()
+
+	reset, ok := s.resets[userID]
+	if !ok {
+		s.mux.Unlock()
+
+		return models.WalletPINStatus{}, fmt.Errorf("%w: no pending pin reset", models.ErrNotFound)
+	}
+
+	if s.clock.Now().After(reset.expiresAt) {
+		delete(s.resets, userID)
+		s.mux.Unlock()
+
+		return models.WalletPINStatus{}, fmt.Errorf("%w: reset code expired", models.ErrBadRequest)
+	}
+
+	if reset.code != strings.TrimSpace(req.Code) {
+		reset.attempts++
+		if reset.attempts >= walletPINResetMaxAttempts {
+			delete(s.resets, userID)
+			s.mux.Unlock()
+
+			return models.WalletPINStatus{}, fmt.Errorf("%w: too many attempts, request a new code", models.ErrBadRequest)
+		}
+
+		s.mux.Unlock()
+
+		return models.WalletPINStatus{}, fmt.Errorf("%w: wrong reset code", models.ErrBadRequest)
+	}
+
+	delete(s.resets, userID)
+	s.pins[userID] = &models.WalletPINState{Hash: string(hash)}
+	s.mux.Unlock()
+
+	s.logger.Infow("Wallet PIN reset by code", "userId", userID)
+
+	return s.GetStatus(ctx), nil
+}
+
+// verify сверяет PIN и считает ошибки. Вызывается под блокировкой.
+func (s *WalletPINService) verify(state *models.WalletPINState, pin string, now time.Time) error {
+	s.unlockExpired(state, now)
+
+	if state.LockedUntil != nil {
+		return &models.WalletPINError{Reason: models.WalletPINLocked, RetryAfter: state.LockedUntil.Sub(now)}
+	}
+
+	if pin == "" {
+		return &models.WalletPINError{
+			Reason:       models.WalletPINRequired,
+			AttemptsLeft: s.settings.MaxAttempts - state.FailedAttempts,
+		}
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(state.Hash), []byte(pin))
+	if err == nil {
+		state.FailedAttempts = 0
+
+		return nil
+	}
+
+	if !errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return fmt.Errorf("%w: can't check pin: %w", models.ErrInternalServer, err)
+	}
+
+	state.FailedAttempts++
+	if state.FailedAttempts >= s.settings.MaxAttempts {
+		lockedUntil := now.Add(s.settings.Lockout)
+		state.LockedUntil = &lockedUntil
+
+		return &models.WalletPINError{Reason: models.WalletPINLocked, RetryAfter: s.settings.Lockout}
+	}
+
+	return &models.WalletPINError{
+		Reason:       models.WalletPINInvalid,
+		AttemptsLeft: s.settings.MaxAttempts - state.FailedAttempts,
+	}
+}
+
+// unlockExpired снимает истекшую блокировку и дает попытки заново
+func (s *WalletPINService) unlockExpired(state *models.WalletPINState, now time.Time) {
+	if state.LockedUntil != nil && !now.Before(*state.LockedUntil) {
+		state.LockedUntil = nil
+		state.FailedAttempts = 0
+	}
+}
+
+func validatePIN(pin string) error {
+	if len(pin) < models.WalletPINMinLength || len(pin) > models.WalletPINMaxLength {
+		return fmt.Errorf("%w: pin must be %d to %d digits",
+			models.ErrBadRequest, models.WalletPINMinLength, models.WalletPINMaxLength)
+	}
+
+	for _, r := range pin {
+		if r < '0' || r > '9' {
+			return fmt.Errorf("%w: pin must contain only digits", models.ErrBadRequest)
+		}
+	}
+
+	return nil
+}
+
+// ResetUser удаляет PIN пользователя и незавершенный сброс
+func (s *WalletPINService) ResetUser(userID string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	delete(s.pins, userID)
+	delete(s.resets, userID)
+}
+
+// GetBackupData возвращает данные для бэкапа
+func (s *WalletPINService) GetBackupData() interface{} {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	backup := make(map[string]models.WalletPINState, len(s.pins))
+	for userID, state := range s.pins {
+		backup[userID] = *state
+	}
+
+	return backup
+}
+
+func (s *WalletPINService) GetBackupFileName() string {
+	return "wallet_pins"
+}
+
+// RestoreBackupData заменяет PIN данными из бэкапа, незавершенные сбросы отменяются
+func (s *WalletPINService) RestoreBackupData(data []byte) error {
+	var backup map[string]*models.WalletPINState
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return fmt.Errorf("can't parse wallet pins: %w", err)
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.pins = backup
+	if s.pins == nil {
+		s.pins = make(map[string]*models.WalletPINState)
+	}
+
+	s.resets = make(map[string]*pinReset)
+
+	return nil
+}
+
+func (s *WalletPINService) CollectionSizes(_ context.Context) map[string]int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return map[string]int{"wallet_pins": len(s.pins), "wallet_pin_resets": len(s.resets)}
+}
@@ -0,0 +1,160 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"eats-backend/internal/models"
+	"eats-backend/internal/service"
+)
+
+type testPINCodes map[string]string // userID -> последний код
+
+func (c testPINCodes) WalletPINResetCode(_ context.Context, userID, code string, _ time.Time) {
+	c[userID] = code
+}
+
+func requirePINError(t *testing.T, err error, reason models.WalletPINErrorReason) *models.WalletPINError {
+	t.Helper()
+
+	var pinErr *models.WalletPINError
+	require.True(t, errors.As(err, &pinErr), "expected wallet pin error, got %v", err)
+	require.Equal(t, reason, pinErr.Reason)
+
+	return pinErr
+}
+
+func TestWalletPINService(t *testing.T) {
+	codes := testPINCodes{}
+	pins := service.NewWalletPINService(service.WalletPINSettings{
+		Threshold:    models.Rubles(1000),
+		MaxAttempts:  3,
+		Lockout:      time.Hour,
+		ResetCodeTTL: time.Minute,
+	}, codes, nil, zap.NewNop().Sugar())
+
+	alice := walletContext(t, "alice")
+
+	// Без PIN операции подтверждать не нужно
+	require.NoError(t, pins.Confirm("alice", "", models.Rubles(5000)))
+	require.False(t, pins.GetStatus(alice).Enabled)
+
+	_, err := pins.SetPIN(alice, models.WalletPINRequest{PIN: "12a4"})
+	require.ErrorIs(t, err, models.ErrBadRequest)
+
+	_, err = pins.SetPIN(alice, models.WalletPINRequest{PIN: "1234567"})
+	require.ErrorIs(t, err, models.ErrBadRequest)
+
+	status, err := pins.SetPIN(alice, models.WalletPINRequest{PIN: "1234"})
+	require.NoError(t, err)
+	require.True(t, status.Enabled)
+	require.Equal(t, 3, status.AttemptsLeft)
+
+	require.NoError(t, pins.Confirm("alice", "", models.Rubles(999)))
+
+	err = pins.Confirm("alice", "", models.Rubles(1000))
+	require.ErrorIs(t, err, models.ErrForbidden)
+	requirePINError(t, err, models.WalletPINRequired)
+
+	err = pins.Confirm("alice", "0000", models.Rubles(1000))
+	require.Equal(t, 2, requirePINError(t, err, models.WalletPINInvalid).AttemptsLeft)
+
+	// Верный PIN сбрасывает счетчик ошибок
+	require.NoError(t, pins.Confirm("alice", "1234", models.Rubles(1000)))
+	require.Equal(t, 3, pins.GetStatus(alice).AttemptsLeft)
+
+	// Сменить PIN можно только с текущим
+	_, err = pins.SetPIN(alice, models.WalletPINRequest{PIN: "5678"})
+	requirePINError(t, err, models.WalletPINRequired)
+
+	_, err = pins.SetPIN(alice, models.WalletPINRequest{PIN: "5678", CurrentPIN: "1234"})
+	require.NoError(t, err)
+
+	for range 2 {
+		requirePINError(t, pins.Confirm("alice", "1234", models.Rubles(1000)), models.WalletPINInvalid)
+	}
+
+	err = pins.Confirm("alice", "1234", models.Rubles(1000))
+	require.ErrorIs(t, err, models.ErrTooManyRequests)
+	require.Equal(t, time.Hour, requirePINError(t, err, models.WalletPINLocked).RetryAfter)
+
+	// Во время блокировки не проходит даже верный PIN
+	requirePINError(t, pins.Confirm("alice", "5678", models.Rubles(1000)), models.WalletPINLocked)
+	require.NotNil(t, pins.GetStatus(alice).LockedUntil)
+
+	_, err = pins.RequestReset(walletContext(t, "bob"))
+	require.ErrorIs(t, err, models.ErrNotFound)
+
+	_, err = pins.RequestReset(alice)
+	require.NoError(t, err)
+	require.Len(t, codes["alice"], models.WalletPINResetCodeLen)
+
+	_, err = pins.ConfirmReset(alice, models.WalletPINResetConfirmRequest{Code: "wrong", PIN: "4321"})
+	require.ErrorIs(t, err, models.ErrBadRequest)
+
+	status, err = pins.ConfirmReset(alice, models.WalletPINResetConfirmRequest{Code: codes["alice"], PIN: "4321"})
+	require.NoError(t, err)
+	require.Nil(t, status.LockedUntil)
+	require.Equal(t, 3, status.AttemptsLeft)
+	require.NoError(t, pins.Confirm("alice", "4321", models.Rubles(1000)))
+
+	// Код одноразовый
+	_, err = pins.ConfirmReset(alice, models.WalletPINResetConfirmRequest{Code: codes["alice"], PIN: "1111"})
+	require.ErrorIs(t, err, models.ErrNotFound)
+}
+
+func TestWalletService_TransferRequiresPIN(t *testing.T) {
+	pins := service.NewWalletPINService(service.WalletPINSettings{
+		Threshold:    models.Rubles(100),
+		MaxAttempts:  3,
+		Lockout:      time.Hour,
+		ResetCodeTTL: time.Minute,
+	}, testPINCodes{}, nil, zap.NewNop().Sugar())
+
+	wallet := service.NewWalletService(
+		testWalletProfiles{"+71111111111": "bob"},
+		testWalletEvents{},
+		&testWalletGuard{},
+		pins,
+		testWalletStats{},
+		testWalletIcons{},
+		service.NewStaticRates(nil),
+		[]models.Currency{models.CurrencyRUB},
+		nil,
+		zap.NewNop().Sugar(),
+		models.WalletData{Accounts: map[string]map[string]*models.Account{
+			"alice": {"alice-card": {ID: "alice-card", Type: models.AccountTypeCard, Balance: models.Rubles(1000)}},
+			"bob":   {"bob-card": {ID: "bob-card", Type: models.AccountTypeCard}},
+		}},
+	)
+
+	alice := walletContext(t, "alice")
+
+	_, err := pins.SetPIN(alice, models.WalletPINRequest{PIN: "1234"})
+	require.NoError(t, err)
+
+	transfer := models.TransferRequest{FromAccountID: "alice-card", ToPhoneNumber: "+71111111111", Amount: models.Rubles(500)}
+
+	_, err = wallet.TransferMoney(alice, transfer)
+	requirePINError(t, err, models.WalletPINRequired)
+
+	transfer.PIN = "1234"
+
+	response, err := wallet.TransferMoney(alice, transfer)
+	require.NoError(t, err)
+	require.Equal(t, models.Rubles(500), response.Balance)
+
+	// Ниже порога PIN не нужен, а оплата заказа проверяет его так же, как перевод
+	_, err = wallet.TransferMoney(alice, models.TransferRequest{
+		FromAccountID: "alice-card", ToPhoneNumber: "+71111111111", Amount: models.Rubles(50),
+	})
+	require.NoError(t, err)
+
+	requirePINError(t, wallet.ConfirmPayment(alice, "", models.Rubles(100)), models.WalletPINRequired)
+	require.NoError(t, wallet.ConfirmPayment(alice, "1234", models.Rubles(100)))
+}
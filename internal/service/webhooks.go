@@ -0,0 +1,490 @@
+package service
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"eats-backend/internal/events"
+	"eats-backend/internal/models"
+)
+
+const (
+	// Сколько последних отправок хранится для каждого вебхука
+	webhookDeliveriesLimit = 100
+	// Как часто проверять отправки, которым пора повторить попытку
+	webhookRetryInterval = time.Second
+	// Сколько байт ответа интегратора сохраняется в ошибке
+	webhookErrorBodyLimit = 512
+
+	webhookAllEvents = "*"
+)
+
+// События заказов и кошелька, на которые можно подписать вебхук
+var webhookEvents = []string{
+	events.OrderCreated{}.EventName(),
+	events.OrderStatusChanged{}.EventName(),
+	events.OrderDelayed{}.EventName(),
+	events.TransferCompleted{}.EventName(),
+}
+
+// WebhookService отправляет события интеграторам. Тело подписывается HMAC-SHA256 секретом вебхука,
+// неудачные отправки повторяются с экспоненциальной задержкой. Вебхуки попадают в бэкапы,
+// журнал отправок хранится только в памяти.
+type WebhookService struct {
+	client      *http.Client
+	logger      *zap.SugaredLogger
+	maxAttempts int
+	backoff     time.Duration
+
+	webhooks   map[string]*models.Webhook
+	deliveries map[string][]*models.WebhookDelivery // webhookID -> отправки, старые первыми
+
+	// Будит цикл отправки, когда появилась новая отправка
+	wake chan struct{}
+
+	mux sync.RWMutex
+}
+
+func NewWebhookService(
+	client *http.Client,
+	maxAttempts int,
+	backoff time.Duration,
+	logger *zap.SugaredLogger,
+	initialWebhooks []*models.Webhook,
+) *WebhookService {
+	service := &WebhookService{
+		client:      client,
+		logger:      logger,
+		maxAttempts: maxAttempts,
+		backoff:     backoff,
+		deliveries:  make(map[string][]*models.WebhookDelivery),
+		wake:        make(chan struct{}, 1),
+	}
+
+	service.load(initialWebhooks)
+
+	return service
+}
+
+func (s *WebhookService) load(webhooks []*models.Webhook) {
+	s.webhooks = make(map[string]*models.Webhook, len(webhooks))
+	for _, webhook := range webhooks {
+		copied := *webhook
+		copied.Events = slices.Clone(webhook.Events)
+		s.webhooks[webhook.ID] = &copied
+	}
+
+	for webhookID := range s.deliveries {
+		if _, ok := s.webhooks[webhookID]; !ok {
+			delete(s.deliveries, webhookID)
+		}
+	}
+}
+
+// CreateWebhook регистрирует вебхук. Секрет возвращается только в ответе на этот запрос.
+func (s *WebhookService) CreateWebhook(_ context.Context, request models.WebhookRequest) (models.Webhook, error) {
+	target, err := url.Parse(request.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return models.Webhook{}, fmt.Errorf("%w: url should be absolute http or https url", models.ErrBadRequest)
+	}
+
+	if len(request.Events) == 0 {
+		return models.Webhook{}, fmt.Errorf("%w: events are required", models.ErrBadRequest)
+	}
+
+	for _, event := range request.Events {
+		if event != webhookAllEvents && !slices.Contains(webhookEvents, event) {
+			return models.Webhook{}, fmt.Errorf(
+				"%w: unknown event %s, should be one of %v or *", models.ErrBadRequest, event, webhookEvents)
+		}
+	}
+
+	secret := request.Secret
+	if secret == "" {
+		random := make([]byte, 32)
+		if _, err := rand.Read(random); err != nil {
+			return models.Webhook{}, fmt.Errorf("can't generate webhook secret: %w", err)
+		}
+
+		secret = hex.EncodeToString(random)
+	}
+
+	webhook := models.Webhook{
+		ID:        uuid.NewString(),
+		URL:       target.String(),
+		Secret:    secret,
+		Events:    slices.Clone(request.Events),
+		CreatedAt: time.Now(),
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	stored := webhook
+	s.webhooks[webhook.ID] = &stored
+
+	s.logger.Infow("Webhook created", "webhookId", webhook.ID, "url", webhook.URL, "events", webhook.Events)
+
+	return webhook, nil
+}
+
+// ListWebhooks возвращает вебхуки без секретов, старые первыми
+func (s *WebhookService) ListWebhooks(_ context.Context) []models.Webhook {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	result := make([]models.Webhook, 0, len(s.webhooks))
+	for _, webhook := range s.webhooks {
+		copied := *webhook
+		copied.Secret = ""
+		copied.Events = slices.Clone(webhook.Events)
+		result = append(result, copied)
+	}
+
+	slices.SortFunc(result, func(a, b models.Webhook) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), cmp.Compare(a.ID, b.ID))
+	})
+
+	return result
+}
+
+// DeleteWebhook удаляет вебхук вместе с журналом. Отправки, которые уже выполняются, не прерываются.
+func (s *WebhookService) DeleteWebhook(_ context.Context, id string) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if _, ok := s.webhooks[id]; !ok {
+		return fmt.Errorf("%w: webhook not found", models.ErrNotFound)
+	}
+
+	delete(s.webhooks, id)
+	delete(s.deliveries, id)
+
+	return nil
+}
+
+// GetDeliveries возвращает журнал отправок вебхука, новые первыми
+func (s *WebhookService) GetDeliveries(_ context.Context, webhookID string) ([]models.WebhookDelivery, error) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	if _, ok := s.webhooks[webhookID]; !ok {
+		return nil, fmt.Errorf("%w: webhook not found", models.ErrNotFound)
+	}
+
+	deliveries := s.deliveries[webhookID]
+
+	result := make([]models.WebhookDelivery, 0, len(deliveries))
+	for i := len(deliveries) - 1; i >= 0; i-- {
+		result = append(result, copyDelivery(deliveries[i]))
+	}
+
+	return result, nil
+}
+
+// Enqueue ставит событие в очередь на отправку всем подписанным вебхукам. Сама отправка идет в Start.
+func (s *WebhookService) Enqueue(_ context.Context, event events.Event) {
+	now := time.Now()
+
+	s.mux.Lock()
+
+	queued := 0
+
+	for _, webhook := range s.webhooks {
+		if !slices.Contains(webhook.Events, event.EventName()) && !slices.Contains(webhook.Events, webhookAllEvents) {
+			continue
+		}
+
+		delivery := &models.WebhookDelivery{
+			ID:            uuid.NewString(),
+			WebhookID:     webhook.ID,
+			Event:         event.EventName(),
+			Status:        models.WebhookDeliveryPending,
+			CreatedAt:     now,
+			NextAttemptAt: &now,
+		}
+
+		payload, err := json.Marshal(models.WebhookPayload{
+			ID:        delivery.ID,
+			Event:     event.EventName(),
+			CreatedAt: now,
+			Data:      event,
+		})
+		if err != nil {
+			s.logger.Errorw("Can't marshal webhook payload", "event", event.EventName(), "error", err)
+
+			continue
+		}
+
+		delivery.Payload = payload
+
+		deliveries := append(s.deliveries[webhook.ID], delivery)
+		s.deliveries[webhook.ID] = pruneDeliveries(deliveries)
+		queued++
+	}
+
+	s.mux.Unlock()
+
+	if queued == 0 {
+		return
+	}
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// pruneDeliveries удаляет самые старые завершенные отправки сверх лимита. Ожидающие отправки не удаляются.
+func pruneDeliveries(deliveries []*models.WebhookDelivery) []*models.WebhookDelivery {
+	extra := len(deliveries) - webhookDeliveriesLimit
+
+	return slices.DeleteFunc(deliveries, func(delivery *models.WebhookDelivery) bool {
+		if extra <= 0 || delivery.Status == models.WebhookDeliveryPending {
+			return false
+		}
+
+		extra--
+
+		return true
+	})
+}
+
+// Start отправляет события, пока не завершится ctx
+func (s *WebhookService) Start(ctx context.Context) {
+	ticker := time.NewTicker(webhookRetryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.wake:
+		case <-ctx.Done():
+			return
+		}
+
+		s.RunDue(ctx, time.Now())
+	}
+}
+
+type dueDelivery struct {
+	webhook    models.Webhook
+	deliveryID string
+	payload    []byte
+}
+
+// RunDue выполняет попытки отправки, срок которых наступил к now, и ждет их завершения
+func (s *WebhookService) RunDue(ctx context.Context, now time.Time) {
+	s.mux.RLock()
+
+	due := make([]dueDelivery, 0)
+
+	for webhookID, deliveries := range s.deliveries {
+		for _, delivery := range deliveries {
+			if delivery.Status != models.WebhookDeliveryPending || delivery.NextAttemptAt.After(now) {
+				continue
+			}
+
+			due = append(due, dueDelivery{
+				webhook:    *s.webhooks[webhookID],
+				deliveryID: delivery.ID,
+				payload:    delivery.Payload,
+			})
+		}
+	}
+
+	s.mux.RUnlock()
+
+	var wg sync.WaitGroup
+
+	for _, item := range due {
+		wg.Go(func() {
+			statusCode, err := s.send(ctx, item.webhook, item.deliveryID, item.payload)
+			s.recordAttempt(item.webhook.ID, item.deliveryID, statusCode, err)
+		})
+	}
+
+	wg.Wait()
+}
+
+func (s *WebhookService) send(
+	ctx context.Context,
+	webhook models.Webhook,
+	deliveryID string,
+	payload []byte,
+) (int, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, fmt.Errorf("can't create request: %w", err)
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Webhook-Id", deliveryID)
+	request.Header.Set("X-Webhook-Event", webhookEventName(payload))
+	request.Header.Set("X-Webhook-Timestamp", timestamp)
+	request.Header.Set("X-Webhook-Signature", "sha256="+SignWebhook(webhook.Secret, timestamp, payload))
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+
+	defer response.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(response.Body, webhookErrorBodyLimit))
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return response.StatusCode, fmt.Errorf("unexpected status %d: %s", response.StatusCode, body)
+	}
+
+	return response.StatusCode, nil
+}
+
+func webhookEventName(payload []byte) string {
+	var header struct {
+		Event string `json:"event"`
+	}
+
+	_ = json.Unmarshal(payload, &header)
+
+	return header.Event
+}
+
+// SignWebhook подпись тела вебхука: hex HMAC-SHA256 секретом от "timestamp.body".
+// Метка времени в подписи не дает повторно отправить перехваченный запрос позже.
+func SignWebhook(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// recordAttempt сохраняет результат попытки. После maxAttempts неудач отправка считается проваленной,
+// иначе следующая попытка откладывается на backoff, 2*backoff, 4*backoff и так далее.
+func (s *WebhookService) recordAttempt(webhookID, deliveryID string, statusCode int, sendErr error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	index := slices.IndexFunc(s.deliveries[webhookID], func(delivery *models.WebhookDelivery) bool {
+		return delivery.ID == deliveryID
+	})
+	// Вебхук удалили, пока шла отправка
+	if index < 0 {
+		return
+	}
+
+	delivery := s.deliveries[webhookID][index]
+	now := time.Now()
+
+	delivery.Attempts++
+	delivery.LastAttemptAt = &now
+	delivery.ResponseStatus = statusCode
+	delivery.Error = ""
+	delivery.NextAttemptAt = nil
+
+	switch {
+	case sendErr == nil:
+		delivery.Status = models.WebhookDeliveryDelivered
+	case delivery.Attempts >= s.maxAttempts:
+		delivery.Status = models.WebhookDeliveryFailed
+		delivery.Error = sendErr.Error()
+	default:
+		delivery.Error = sendErr.Error()
+		next := now.Add(s.backoff << (delivery.Attempts - 1))
+		delivery.NextAttemptAt = &next
+	}
+
+	if sendErr != nil {
+		s.logger.Warnw("Webhook delivery failed",
+			"webhookId", webhookID, "deliveryId", deliveryID, "attempt", delivery.Attempts, "error", sendErr)
+	}
+}
+
+func (s *WebhookService) CollectionSizes(_ context.Context) map[string]int {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	deliveries := 0
+	for _, webhookDeliveries := range s.deliveries {
+		deliveries += len(webhookDeliveries)
+	}
+
+	return map[string]int{
+		"webhooks":           len(s.webhooks),
+		"webhook_deliveries": deliveries,
+	}
+}
+
+func copyDelivery(delivery *models.WebhookDelivery) models.WebhookDelivery {
+	result := *delivery
+
+	if delivery.LastAttemptAt != nil {
+		lastAttemptAt := *delivery.LastAttemptAt
+		result.LastAttemptAt = &lastAttemptAt
+	}
+
+	if delivery.NextAttemptAt != nil {
+		nextAttemptAt := *delivery.NextAttemptAt
+		result.NextAttemptAt = &nextAttemptAt
+	}
+
+	return result
+}
+
+// GetBackupData возвращает вебхуки вместе с секретами
+func (s *WebhookService) GetBackupData() interface{} {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	result := make([]*models.Webhook, 0, len(s.webhooks))
+	for _, webhook := range s.webhooks {
+		copied := *webhook
+		copied.Events = slices.Clone(webhook.Events)
+		result = append(result, &copied)
+	}
+
+	slices.SortFunc(result, func(a, b *models.Webhook) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), cmp.Compare(a.ID, b.ID))
+	})
+
+	return result
+}
+
+func (s *WebhookService) GetBackupFileName() string {
+	return "webhooks"
+}
+
+// RestoreBackupData заменяет вебхуки данными из бэкапа, журнал удаленных вебхуков очищается
+func (s *WebhookService) RestoreBackupData(data []byte) error {
+	var backup []*models.Webhook
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return fmt.Errorf("can't parse webhooks: %w", err)
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.load(backup)
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package service_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"eats-backend/internal/events"
+	"eats-backend/internal/models"
+	"eats-backend/internal/service"
+)
+
+func TestWebhookService_Deliver(t *testing.T) {
+	responses := []int{http.StatusInternalServerError, http.StatusOK}
+
+	var signatures []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body, err := io.ReadAll(request.Body)
+		require.NoError(t, err)
+
+		timestamp := request.Header.Get("X-Webhook-Timestamp")
+		expected := "sha256=" + service.SignWebhook("secret", timestamp, body)
+		signatures = append(signatures, strings.TrimPrefix(request.Header.Get("X-Webhook-Signature"), expected))
+
+		writer.WriteHeader(responses[0])
+		responses = responses[1:]
+	}))
+	defer server.Close()
+
+	webhooks := service.NewWebhookService(server.Client(), 2, time.Minute, zap.NewNop().Sugar(), nil)
+
+	webhook, err := webhooks.CreateWebhook(t.Context(), models.WebhookRequest{
+		URL:    server.URL,
+		Secret: "secret",
+		Events: []string{"order.created"},
+	})
+	require.NoError(t, err)
+
+	webhooks.Enqueue(t.Context(), events.TransferCompleted{Amount: models.Rubles(100)})
+	webhooks.Enqueue(t.Context(), events.OrderCreated{UserID: "user-1", Order: models.Order{ID: "order-1"}})
+
+	// Первая попытка неудачна, повтор откладывается на backoff
+	now := time.Now()
+	webhooks.RunDue(t.Context(), now)
+
+	deliveries, err := webhooks.GetDeliveries(t.Context(), webhook.ID)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	require.Equal(t, models.WebhookDeliveryPending, deliveries[0].Status)
+	require.Equal(t, http.StatusInternalServerError, deliveries[0].ResponseStatus)
+	require.WithinDuration(t, now.Add(time.Minute), *deliveries[0].NextAttemptAt, 5*time.Second)
+
+	webhooks.RunDue(t.Context(), now)
+	require.Len(t, signatures, 1)
+
+	webhooks.RunDue(t.Context(), now.Add(2*time.Minute))
+
+	deliveries, err = webhooks.GetDeliveries(t.Context(), webhook.ID)
+	require.NoError(t, err)
+	require.Equal(t, models.WebhookDeliveryDelivered, deliveries[0].Status)
+	require.Equal(t, 2, deliveries[0].Attempts)
+	require.Equal(t, []string{"", ""}, signatures, "signature should match the body")
+
+	var payload models.WebhookPayload
+	require.NoError(t, json.Unmarshal(deliveries[0].Payload, &payload))
+	require.Equal(t, deliveries[0].ID, payload.ID)
+	require.Equal(t, "order.created", payload.Event)
+}
+
+func TestWebhookService_GiveUp(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		writer.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	webhooks := service.NewWebhookService(server.Client(), 2, time.Second, zap.NewNop().Sugar(), nil)
+
+	webhook, err := webhooks.CreateWebhook(t.Context(), models.WebhookRequest{URL: server.URL, Events: []string{"*"}})
+	require.NoError(t, err)
+	require.NotEmpty(t, webhook.Secret)
+	require.Empty(t, webhooks.ListWebhooks(t.Context())[0].Secret)
+
+	webhooks.Enqueue(t.Context(), events.TransferCompleted{Amount: models.Rubles(100)})
+
+	webhooks.RunDue(t.Context(), time.Now())
+	webhooks.RunDue(t.Context(), time.Now().Add(time.Hour))
+
+	deliveries, err := webhooks.GetDeliveries(t.Context(), webhook.ID)
+	require.NoError(t, err)
+	require.Equal(t, models.WebhookDeliveryFailed, deliveries[0].Status)
+	require.Equal(t, 2, deliveries[0].Attempts)
+	require.Nil(t, deliveries[0].NextAttemptAt)
+
+	_, err = webhooks.CreateWebhook(t.Context(), models.WebhookRequest{URL: "ftp://example.com", Events: []string{"*"}})
+	require.ErrorIs(t, err, models.ErrBadRequest)
+
+	_, err = webhooks.CreateWebhook(t.Context(), models.WebhookRequest{URL: server.URL, Events: []string{"product.updated"}})
+	require.ErrorIs(t, err, models.ErrBadRequest)
+}
@@ -0,0 +1,267 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	// Часовые пояса встроены в бинарник: в образе alpine их нет
+	_ "time/tzdata"
+
+	"eats-backend/internal/models"
+)
+
+// На сколько дней вперед искать ближайшее открытие, с запасом на длинные каникулы
+const openingSearchDays = 366
+
+var weekdays = map[string]time.Weekday{
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+	"sun": time.Sunday,
+}
+
+// timeRange часы работы в течение дня. Если to не больше from, интервал заканчивается на следующий день.
+type timeRange struct {
+	from, to time.Duration
+}
+
+// WorkingHours расписание магазина по дням недели и праздники, в которые он закрыт.
+// Пустое расписание - магазин работает круглосуточно.
+type WorkingHours struct {
+	location *time.Location
+	// Дни без часов - выходные.
+	days          map[time.Weekday]timeRange
+	roundTheClock bool
+	holidays      map[string]struct{}
+	// Принимать заказы в нерабочее время к открытию вместо отказа.
+	scheduleOutside bool
+}
+
+// NewWorkingHours разбирает расписание: дни mon..sun или daily с часами "09:00-22:00" или "closed",
+// отдельные дни важнее daily. Праздники - даты "2006-01-02", время в часовом поясе timezone.
+func NewWorkingHours(
+	timezone string,
+	schedule map[string]string,
+	holidays []string,
+	scheduleOutside bool,
+) (*WorkingHours, error) {
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %s: %w", timezone, err)
+	}
+
+	hours := &WorkingHours{
+		location:        location,
+		days:            make(map[time.Weekday]timeRange),
+		roundTheClock:   len(schedule) == 0,
+		holidays:        make(map[string]struct{}, len(holidays)),
+		scheduleOutside: scheduleOutside,
+	}
+
+	if daily, ok := schedule["daily"]; ok {
+		if err := hours.setDays(daily, time.Monday, time.Tuesday, time.Wednesday, time.Thursday,
+			time.Friday, time.Saturday, time.Sunday); err != nil {
+			return nil, fmt.Errorf("daily: %w", err)
+		}
+	}
+
+	for name, value := range schedule {
+		if name == "daily" {
+			continue
+		}
+
+		day, ok := weekdays[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown day %s, should be mon..sun or daily", name)
+		}
+
+		if err := hours.setDays(value, day); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	for _, holiday := range holidays {
+		date, err := time.ParseInLocation(time.DateOnly, strings.TrimSpace(holiday), location)
+		if err != nil {
+			return nil, fmt.Errorf("holiday %s should be YYYY-MM-DD: %w", holiday, err)
+		}
+
+		hours.holidays[date.Format(time.DateOnly)] = struct{}{}
+	}
+
+	return hours, nil
+}
+
+func (w *WorkingHours) setDays(value string, days ...time.Weekday) error {
+	if strings.EqualFold(strings.TrimSpace(value), "closed") {
+		for _, day := range days {
+			delete(w.days, day)
+		}
+
+		return nil
+	}
+
+	hours, err := parseTimeRange(value)
+	if err != nil {
+		return err
+	}
+
+	for _, day := range days {
+		w.days[day] = hours
+	}
+
+	return nil
+}
+
+// Status возвращает, открыт ли магазин в момент now, когда он закроется и когда откроется.
+// Круглосуточный магазин не закрывается, у закрытого навсегда нет ближайшего открытия.
+func (w *WorkingHours) Status(now time.Time) (open bool, closesAt, nextOpening time.Time) {
+	if w.roundTheClock && len(w.holidays) == 0 {
+		return true, time.Time{}, time.Time{}
+	}
+
+	now = now.In(w.location)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, w.location)
+
+	// Вчерашняя смена могла перейти через полночь
+	for _, day := range []time.Time{today.AddDate(0, 0, -1), today} {
+		if from, to, ok := w.shift(day); ok && !now.Before(from) && now.Before(to) {
+			return true, w.closingAfter(day, to), time.Time{}
+		}
+	}
+
+	for i := range openingSearchDays {
+		if from, _, ok := w.shift(today.AddDate(0, 0, i)); ok && from.After(now) {
+			return false, time.Time{}, from
+		}
+	}
+
+	return false, time.Time{}, time.Time{}
+}
+
+// closingAfter продлевает смену дня day, которая заканчивается в to, следующими сменами без перерыва:
+// при работе до 24:00 и с 00:00 магазин закрывается не в полночь.
+func (w *WorkingHours) closingAfter(day, to time.Time) time.Time {
+	for range openingSearchDays {
+		day = day.AddDate(0, 0, 1)
+
+		from, next, ok := w.shift(day)
+		if !ok || !from.Equal(to) {
+			break
+		}
+
+		to = next
+	}
+
+	return to
+}
+
+// shift возвращает смену, которая начинается в день day. Праздник отменяет смену целиком.
+func (w *WorkingHours) shift(day time.Time) (time.Time, time.Time, bool) {
+	if _, holiday := w.holidays[day.Format(time.DateOnly)]; holiday {
+		return time.Time{}, time.Time{}, false
+	}
+
+	hours, ok := w.days[day.Weekday()]
+	if w.roundTheClock {
+		hours, ok = timeRange{to: 24 * time.Hour}, true
+	}
+
+	if !ok {
+		return time.Time{}, time.Time{}, false
+	}
+
+	from := day.Add(hours.from)
+	to := day.Add(hours.to)
+
+	if hours.to <= hours.from {
+		to = day.AddDate(0, 0, 1).Add(hours.to)
+	}
+
+	return from, to, true
+}
+
+// CheckOrderTime возвращает, с какого момента собирать заказ, оформленный в now: сразу, если магазин открыт,
+// или к открытию. Если заказы к открытию отключены, заказ в нерабочее время отклоняется.
+func (w *WorkingHours) CheckOrderTime(now time.Time) (time.Time, error) {
+	open, _, nextOpening := w.Status(now)
+	if open {
+		return now, nil
+	}
+
+	if !w.scheduleOutside || nextOpening.IsZero() {
+		return time.Time{}, &models.StoreClosedError{NextOpening: nextOpening}
+	}
+
+	return nextOpening, nil
+}
+
+// UnavailableReason возвращает, почему товар с часами продажи availableHours нельзя заказать в now,
+// или пустую строку. Неразборчивые часы товара не ограничивают продажу.
+func (w *WorkingHours) UnavailableReason(availableHours string, now time.Time) string {
+	if open, _, _ := w.Status(now); !open {
+		return models.UnavailableStoreClosed
+	}
+
+	if availableHours == "" {
+		return ""
+	}
+
+	hours, err := parseTimeRange(availableHours)
+	if err != nil {
+		return ""
+	}
+
+	now = now.In(w.location)
+	sinceMidnight := time.Duration(now.Hour())*time.Hour + time.Duration(now.Minute())*time.Minute
+
+	inRange := sinceMidnight >= hours.from && sinceMidnight < hours.to
+	if hours.to <= hours.from {
+		inRange = sinceMidnight >= hours.from || sinceMidnight < hours.to
+	}
+
+	if !inRange {
+		return models.UnavailableOutsideHours
+	}
+
+	return ""
+}
+
+// parseTimeRange разбирает часы вида "09:00-22:00", "22:00-02:00" заканчивается на следующий день
+func parseTimeRange(value string) (timeRange, error) {
+	fromValue, toValue, ok := strings.Cut(strings.TrimSpace(value), "-")
+	if !ok {
+		return timeRange{}, fmt.Errorf("hours %s should be HH:MM-HH:MM", value)
+	}
+
+	from, err := parseClock(fromValue)
+	if err != nil {
+		return timeRange{}, err
+	}
+
+	to, err := parseClock(toValue)
+	if err != nil {
+		return timeRange{}, err
+	}
+
+	return timeRange{from: from, to: to}, nil
+}
+
+func parseClock(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+
+	// 24:00 - конец дня, time.Parse такое время не принимает
+	if value == "24:00" {
+		return 24 * time.Hour, nil
+	}
+
+	clock, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("time %s should be HH:MM", value)
+	}
+
+	return time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute, nil
+}
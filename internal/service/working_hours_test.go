@@ -0,0 +1,61 @@
+package service_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"eats-backend/internal/models"
+	"eats-backend/internal/service"
+)
+
+func TestWorkingHours_Status(t *testing.T) {
+	hours, err := service.NewWorkingHours("UTC", map[string]string{
+		"daily": "09:00-22:00",
+		"fri":   "09:00-02:00",
+		"sun":   "closed",
+	}, []string{"2026-10-19"}, false)
+	require.NoError(t, err)
+
+	at := func(value string) time.Time {
+		parsed, err := time.Parse(time.DateTime, value)
+		require.NoError(t, err)
+
+		return parsed
+	}
+
+	// Пятница, 2026-10-16
+	open, closesAt, _ := hours.Status(at("2026-10-16 12:00:00"))
+	require.True(t, open)
+	require.Equal(t, at("2026-10-17 02:00:00"), closesAt)
+
+	// Пятничная смена продолжается после полуночи
+	open, _, _ = hours.Status(at("2026-10-17 01:30:00"))
+	require.True(t, open)
+
+	// Воскресенье выходной, понедельник праздник
+	open, _, nextOpening := hours.Status(at("2026-10-17 23:00:00"))
+	require.False(t, open)
+	require.Equal(t, at("2026-10-20 09:00:00"), nextOpening)
+
+	_, err = hours.CheckOrderTime(at("2026-10-18 12:00:00"))
+
+	var closed *models.StoreClosedError
+	require.ErrorAs(t, err, &closed)
+	require.Equal(t, at("2026-10-20 09:00:00"), closed.NextOpening)
+
+	require.Equal(t, models.UnavailableOutsideHours, hours.UnavailableReason("08:00-11:00", at("2026-10-16 12:00:00")))
+	require.Empty(t, hours.UnavailableReason("08:00-11:00", at("2026-10-16 10:00:00")))
+	require.Equal(t, models.UnavailableStoreClosed, hours.UnavailableReason("", at("2026-10-18 10:00:00")))
+
+	scheduled, err := service.NewWorkingHours("UTC", map[string]string{"daily": "09:00-22:00"}, nil, true)
+	require.NoError(t, err)
+
+	startAt, err := scheduled.CheckOrderTime(at("2026-10-16 23:00:00"))
+	require.NoError(t, err)
+	require.Equal(t, at("2026-10-17 09:00:00"), startAt)
+
+	_, err = service.NewWorkingHours("UTC", map[string]string{"someday": "09:00-22:00"}, nil, false)
+	require.Error(t, err)
+}
@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"eats-backend/internal/models"
+)
+
+// ZoneCatalog проверяет товары и категории, которые привязываются к зонам
+type ZoneCatalog interface {
+	ProductExists(id string) bool
+	GetCategories() []models.Category
+}
+
+// CurrentAddresses выдает адрес, выбранный пользователем для доставки
+type CurrentAddresses interface {
+	GetCurrentAddress(ctx context.Context) (models.Address, bool)
+}
+
+// ZoneService зоны доставки с собственным ассортиментом. Зона пользователя определяется по текущему адресу.
+type ZoneService struct {
+	catalog   ZoneCatalog
+	addresses CurrentAddresses
+
+	// Зоны в порядке из файла: адрес на границе двух зон относится к первой
+	zones []*models.DeliveryZone
+
+	mux sync.RWMutex
+}
+
+func NewZoneService(catalog ZoneCatalog, addresses CurrentAddresses, zones []*models.DeliveryZone) *ZoneService {
+	service := &ZoneService{
+		catalog:   catalog,
+		addresses: addresses,
+	}
+	service.load(zones)
+
+	return service
+}
+
+// load заменяет зоны копиями, вызывать под s.mux или в конструкторе
+func (s *ZoneService) load(zones []*models.DeliveryZone) {
+	s.zones = make([]*models.DeliveryZone, 0, len(zones))
+
+	for _, zone := range zones {
+		copied := copyZone(zone)
+		s.zones = append(s.zones, &copied)
+	}
+}
+
+// ListZones возвращает зоны вместе с ассортиментом
+func (s *ZoneService) ListZones(_ context.Context) []models.DeliveryZone {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	result := make([]models.DeliveryZone, len(s.zones))
+	for i, zone := range s.zones {
+		result[i] = copyZone(zone)
+	}
+
+	return result
+}
+
+// SetAssortment заменяет товары и категории, привязанные к зоне. Пустые списки отвязывают все.
+func (s *ZoneService) SetAssortment(
+	_ context.Context,
+	zoneID string,
+	req models.ZoneAssortmentRequest,
+) (models.DeliveryZone, error) {
+	for _, id := range req.Products {
+		if !s.catalog.ProductExists(id) {
+			return models.DeliveryZone{}, fmt.Errorf("%w: product %s not found", models.ErrBadRequest, id)
+		}
+	}
+
+	categories := s.catalog.GetCategories()
+	for _, id := range req.Categories {
+		if !slices.ContainsFunc(categories, func(category models.Category) bool { return category.ID == id }) {
+			return models.DeliveryZone{}, fmt.Errorf("%w: category %s not found", models.ErrBadRequest, id)
+		}
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	for _, zone := range s.zones {
+		if zone.ID != zoneID {
+			continue
+		}
+
+		zone.Products = uniqueStrings(req.Products)
+		zone.Categories = uniqueStrings(req.Categories)
+		zone.UpdatedAt = time.Now()
+
+		return copyZone(zone), nil
+	}
+
+	return models.DeliveryZone{}, fmt.Errorf("%w: zone %s not found", models.ErrNotFound, zoneID)
+}
+
+// UserZoneFilter возвращает ассортимент зоны текущего адреса пользователя. Пока адрес не выбран или ни один
+// товар не привязан к зонам, показывается весь каталог.
+func (s *ZoneService) UserZoneFilter(ctx context.Context) *models.ZoneFilter {
+	address, ok := s.addresses.GetCurrentAddress(ctx)
+	if !ok {
+		return nil
+	}
+
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	filter := &models.ZoneFilter{
+		Products:   make(map[string]struct{}),
+		Categories: make(map[string]struct{}),
+	}
+
+	for _, zone := range s.zones {
+		for _, id := range zone.Products {
+			filter.Products[id] = struct{}{}
+		}
+
+		for _, id := range zone.Categories {
+			filter.Categories[id] = struct{}{}
+		}
+
+		if filter.Zone == nil && polygonContains(zone.Polygon, address.Coordinates) {
+			copied := copyZone(zone)
+			filter.Zone = &copied
+		}
+	}
+
+	if len(filter.Products) == 0 && len(filter.Categories) == 0 {
+		return nil
+	}
+
+	return filter
+}
+
+// polygonContains проверяет, лежит ли точка внутри многоугольника, методом трассировки луча
+func polygonContains(polygon [][]float64, point []float64) bool {
+	if len(point) != 2 {
+		return false
+	}
+
+	x, y := point[0], point[1]
+	inside := false
+
+	for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+		xi, yi := polygon[i][0], polygon[i][1]
+		xj, yj := polygon[j][0], polygon[j][1]
+
+		if (yi > y) != (yj > y) && x < (xj-xi)*(y-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+
+	return inside
+}
+
+func uniqueStrings(values []string) []string {
+	result := make([]string, 0, len(values))
+
+	for _, value := range values {
+		if !slices.Contains(result, value) {
+			result = append(result, value)
+		}
+	}
+
+	return result
+}
+
+func copyZone(zone *models.DeliveryZone) models.DeliveryZone {
+	result := *zone
+	result.Polygon = make([][]float64, len(zone.Polygon))
+
+	for i, point := range zone.Polygon {
+		result.Polygon[i] = slices.Clone(point)
+	}
+
+	result.Products = slices.Clone(zone.Products)
+	result.Categories = slices.Clone(zone.Categories)
+
+	return result
+}
+
+// GetBackupData возвращает данные для бэкапа
+func (s *ZoneService) GetBackupData() interface{} {
+	zones := s.ListZones(context.Background())
+
+	result := make([]*models.DeliveryZone, len(zones))
+	for i := range zones {
+		result[i] = &zones[i]
+	}
+
+	return result
+}
+
+func (s *ZoneService) GetBackupFileName() string {
+	return "delivery_zones"
+}
+
+// RestoreBackupData заменяет зоны данными из бэкапа
+func (s *ZoneService) RestoreBackupData(data []byte) error {
+	var backup []*models.DeliveryZone
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return fmt.Errorf("can't parse delivery zones: %w", err)
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.load(backup)
+
+	return nil
+}
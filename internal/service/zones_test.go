@@ -0,0 +1,79 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"eats-backend/internal/models"
+	"eats-backend/internal/service"
+)
+
+type testZoneAddresses map[string][]float64 // userID -> координаты текущего адреса
+
+func (a testZoneAddresses) GetCurrentAddress(ctx context.Context) (models.Address, bool) {
+	coordinates, ok := a[models.ClaimsFromContext(ctx).ID]
+	return models.Address{Coordinates: coordinates}, ok
+}
+
+func TestZoneService_UserZoneFilter(t *testing.T) {
+	favourites := service.NewMockUserService(gomock.NewController(t))
+	favourites.EXPECT().IsFavourite(gomock.Any(), gomock.Any()).Return(false).AnyTimes()
+
+	products := service.NewProductsService(favourites, service.NewRecentlyViewed(service.DefaultRecentlyViewedLimit), nil, service.NewProductPopularity(nil, nil), nil, nil, nil, nil, nil, []*models.Product{
+		{ID: "bread"},
+		{ID: "sushi"},
+		{ID: "khinkali"},
+	}, map[string][]string{
+		"georgian": {"khinkali"},
+	}, map[string]models.Category{
+		"georgian": {ID: "georgian"},
+	})
+
+	zones := service.NewZoneService(products, testZoneAddresses{
+		"center":  {37.62, 55.75},
+		"suburbs": {37.9, 55.9},
+		"outside": {30.3, 59.9},
+	}, []*models.DeliveryZone{
+		{ID: "center", Name: "Центр", Polygon: [][]float64{{37.5, 55.7}, {37.7, 55.7}, {37.7, 55.8}, {37.5, 55.8}}},
+		{ID: "suburbs", Name: "Пригород", Polygon: [][]float64{{37.8, 55.8}, {38.0, 55.8}, {38.0, 56.0}, {37.8, 56.0}}},
+	})
+
+	_, err := zones.SetAssortment(t.Context(), "center", models.ZoneAssortmentRequest{Products: []string{"missing"}})
+	require.ErrorIs(t, err, models.ErrBadRequest)
+
+	_, err = zones.SetAssortment(t.Context(), "missing", models.ZoneAssortmentRequest{})
+	require.ErrorIs(t, err, models.ErrNotFound)
+
+	// Пока к зонам ничего не привязано, ассортимент везде одинаковый
+	require.Nil(t, zones.UserZoneFilter(models.ContextWithUser(t.Context(), "center")))
+
+	_, err = zones.SetAssortment(t.Context(), "center", models.ZoneAssortmentRequest{Products: []string{"sushi"}})
+	require.NoError(t, err)
+
+	zone, err := zones.SetAssortment(t.Context(), "suburbs", models.ZoneAssortmentRequest{Categories: []string{"georgian"}})
+	require.NoError(t, err)
+	require.Equal(t, []string{"georgian"}, zone.Categories)
+
+	catalog := func(userID string) []string {
+		ctx := models.ContextWithUser(t.Context(), userID)
+
+		list, err := products.GetProductsList(ctx, 1, 20, models.ProductsFilter{Zone: zones.UserZoneFilter(ctx)})
+		require.NoError(t, err)
+
+		result := make([]string, 0, len(list.Data))
+		for _, preview := range list.Data {
+			result = append(result, preview.ID)
+		}
+
+		return result
+	}
+
+	require.Equal(t, []string{"bread", "sushi"}, catalog("center"))
+	require.Equal(t, []string{"bread", "khinkali"}, catalog("suburbs"))
+	// Вне зон доступны только товары без привязки, без выбранного адреса - весь каталог
+	require.Equal(t, []string{"bread"}, catalog("outside"))
+	require.Equal(t, []string{"bread", "sushi", "khinkali"}, catalog("no-address"))
+}
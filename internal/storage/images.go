@@ -0,0 +1,350 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"eats-backend/internal/models"
+)
+
+const (
+	// Картинка по ссылке не меняется, но сама ссылка в каталоге может смениться, поэтому без immutable
+	imageCacheControl = "public, max-age=86400"
+	imageIndexFile    = "index.json"
+	imageMaxRedirects = 5
+)
+
+var imageHashName = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// imageEntry исходная ссылка картинки и тип, с которым она была скачана
+type imageEntry struct {
+	URL         string `json:"url"`
+	ContentType string `json:"contentType,omitempty"`
+}
+
+type imageFetch struct {
+	done chan struct{}
+	err  error
+}
+
+// ImageProxy отдает картинки каталога с внешних хостов через /img/{hash}: скачивает при первом запросе
+// и дальше отдает из каталога на диске. Ссылки с разрешенных хостов подменяются при загрузке каталога,
+// соответствие хешей исходным ссылкам хранится рядом с картинками, поэтому переживает перезапуск.
+type ImageProxy struct {
+	logger       *zap.SugaredLogger
+	dir          string
+	baseURL      string
+	allowedHosts []string
+	maxSize      int64
+	client       *http.Client
+
+	mux    sync.Mutex
+	images map[string]imageEntry
+	// Скачивания в процессе: одновременные запросы одной картинки ждут одно скачивание
+	fetching map[string]*imageFetch
+}
+
+func NewImageProxy(
+	logger *zap.SugaredLogger,
+	dir, baseURL string,
+	allowedHosts []string,
+	maxSize int64,
+	timeout time.Duration,
+) (*ImageProxy, error) {
+	proxy := &ImageProxy{
+		logger:       logger,
+		dir:          dir,
+		baseURL:      baseURL,
+		allowedHosts: allowedHosts,
+		maxSize:      maxSize,
+		images:       make(map[string]imageEntry),
+		fetching:     make(map[string]*imageFetch),
+	}
+
+	proxy.client = &http.Client{
+		Timeout: timeout,
+		// Перенаправление на другой хост обходило бы список разрешенных
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= imageMaxRedirects {
+				return errors.New("too many redirects")
+			}
+
+			if !proxy.allowed(req.URL) {
+				return fmt.Errorf("redirect to %s is not allowed", req.URL.Host)
+			}
+
+			return nil
+		},
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, imageIndexFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return proxy, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("can't read image index: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &proxy.images); err != nil {
+		return nil, fmt.Errorf("can't parse image index: %w", err)
+	}
+
+	return proxy, nil
+}
+
+// Enabled подменяет ли прокси ссылки: без разрешенных хостов картинки отдаются напрямую
+func (p *ImageProxy) Enabled() bool {
+	return len(p.allowedHosts) > 0
+}
+
+// RewriteProducts заменяет ссылки на картинки товаров с разрешенных хостов ссылками на прокси.
+// Остальные ссылки, в том числе уже подмененные, не меняются.
+func (p *ImageProxy) RewriteProducts(products []*models.Product) error {
+	if !p.Enabled() {
+		return nil
+	}
+
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	added := 0
+
+	for _, product := range products {
+		parsed, err := url.Parse(product.Image)
+		if err != nil || !p.allowed(parsed) {
+			continue
+		}
+
+		hash := imageHash(product.Image)
+		if _, ok := p.images[hash]; !ok {
+			p.images[hash] = imageEntry{URL: product.Image}
+			added++
+		}
+
+		product.Image = p.baseURL + hash
+	}
+
+	if added == 0 {
+		return nil
+	}
+
+	p.logger.Infof("Proxying %d new catalog images", added)
+
+	return p.saveIndex()
+}
+
+// ServeImage отдает картинку по хешу ссылки, при первом запросе скачивая ее с исходного хоста
+func (p *ImageProxy) ServeImage(w http.ResponseWriter, r *http.Request, hash string) error {
+	if !imageHashName.MatchString(hash) {
+		return fmt.Errorf("%w: image %s not found", models.ErrNotFound, hash)
+	}
+
+	p.mux.Lock()
+	entry, ok := p.images[hash]
+	p.mux.Unlock()
+
+	if !ok {
+		return fmt.Errorf("%w: image %s not found", models.ErrNotFound, hash)
+	}
+
+	path := filepath.Join(p.dir, hash)
+
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		// Клиент может уйти, не дождавшись, а картинку ждут и другие запросы
+		if err := p.fetch(context.WithoutCancel(r.Context()), hash, entry.URL); err != nil {
+			return err
+		}
+
+		p.mux.Lock()
+		entry = p.images[hash]
+		p.mux.Unlock()
+
+		file, err = os.Open(path)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w: can't open cached image: %w", models.ErrInternalServer, err)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			p.logger.Warnf("can't close image: %v", err)
+		}
+	}()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("%w: can't stat cached image: %w", models.ErrInternalServer, err)
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", entry.ContentType)
+	header.Set("X-Content-Type-Options", "nosniff")
+	header.Set("Cache-Control", imageCacheControl)
+	header.Set("ETag", `"`+hash+`"`)
+
+	http.ServeContent(w, r, hash, info.ModTime(), file)
+
+	return nil
+}
+
+// fetch скачивает картинку один раз, даже если ее одновременно запросили несколько клиентов
+func (p *ImageProxy) fetch(ctx context.Context, hash, source string) error {
+	p.mux.Lock()
+
+	if inflight, ok := p.fetching[hash]; ok {
+		p.mux.Unlock()
+		<-inflight.done
+
+		return inflight.err
+	}
+
+	inflight := &imageFetch{done: make(chan struct{})}
+	p.fetching[hash] = inflight
+	p.mux.Unlock()
+
+	contentType, err := p.download(ctx, hash, source)
+
+	p.mux.Lock()
+	delete(p.fetching, hash)
+
+	if err == nil {
+		p.images[hash] = imageEntry{URL: source, ContentType: contentType}
+		if err := p.saveIndex(); err != nil {
+			p.logger.Warnf("can't save image index: %v", err)
+		}
+	}
+	p.mux.Unlock()
+
+	if err != nil {
+		p.logger.Warnf("can't fetch image %s: %v", source, err)
+	}
+
+	inflight.err = err
+	close(inflight.done)
+
+	return err
+}
+
+// download проверяет ответ исходного хоста и сохраняет картинку в каталог. Возвращает тип содержимого.
+func (p *ImageProxy) download(ctx context.Context, hash, source string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid image url: %w", models.ErrInternalServer, err)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("%w: can't fetch image: %w", models.ErrBadGateway, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%w: image host returned %d", models.ErrBadGateway, resp.StatusCode)
+	}
+
+	contentType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(contentType, "image/") {
+		return "", fmt.Errorf("%w: image host returned %q instead of an image",
+			models.ErrBadGateway, resp.Header.Get("Content-Type"))
+	}
+
+	if resp.ContentLength > p.maxSize {
+		return "", fmt.Errorf("%w: image is larger than %d bytes", models.ErrBadGateway, p.maxSize)
+	}
+
+	if err := os.MkdirAll(p.dir, os.ModePerm); err != nil {
+		return "", fmt.Errorf("%w: can't create image dir: %w", models.ErrInternalServer, err)
+	}
+
+	tmp, err := os.CreateTemp(p.dir, hash+tempExt+"-*")
+	if err != nil {
+		return "", fmt.Errorf("%w: can't create image file: %w", models.ErrInternalServer, err)
+	}
+
+	// После успешного переименования удалять уже нечего
+	defer os.Remove(tmp.Name())
+
+	// Размер из заголовка может отсутствовать или быть неверным, поэтому ограничиваем и чтение
+	written, err := io.Copy(tmp, io.LimitReader(resp.Body, p.maxSize+1))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("%w: can't download image: %w", models.ErrBadGateway, err)
+	}
+
+	if written > p.maxSize {
+		return "", fmt.Errorf("%w: image is larger than %d bytes", models.ErrBadGateway, p.maxSize)
+	}
+
+	if err := os.Rename(tmp.Name(), filepath.Join(p.dir, hash)); err != nil {
+		return "", fmt.Errorf("%w: can't save image: %w", models.ErrInternalServer, err)
+	}
+
+	return contentType, nil
+}
+
+// allowed разрешен ли хост ссылки: совпадает с хостом из списка или является его поддоменом
+func (p *ImageProxy) allowed(link *url.URL) bool {
+	if link.Scheme != "http" && link.Scheme != "https" {
+		return false
+	}
+
+	host := strings.ToLower(link.Hostname())
+
+	for _, allowed := range p.allowedHosts {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+		if allowed != "" && (host == allowed || strings.HasSuffix(host, "."+allowed)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// saveIndex сохраняет соответствие хешей ссылкам, вызывается под блокировкой
+func (p *ImageProxy) saveIndex() error {
+	data, err := json.Marshal(p.images)
+	if err != nil {
+		return fmt.Errorf("can't marshal image index: %w", err)
+	}
+
+	if err := os.MkdirAll(p.dir, os.ModePerm); err != nil {
+		return fmt.Errorf("can't create image dir: %w", err)
+	}
+
+	path := filepath.Join(p.dir, imageIndexFile)
+	tmp := path + tempExt
+
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("can't write image index: %w", err)
+	}
+
+	return os.Rename(tmp, path)
+}
+
+func imageHash(link string) string {
+	sum := sha256.Sum256([]byte(link))
+
+	return hex.EncodeToString(sum[:])
+}
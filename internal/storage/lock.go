@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// FileLock блокировка лидера на файле через flock. Подходит для экземпляров на одной машине или
+// с общим каталогом данных. Блокировка держится, пока открыт файл, поэтому ttl не нужен:
+// если процесс упал, ее снимает система.
+type FileLock struct {
+	path string
+
+	mux  sync.Mutex
+	file *os.File
+}
+
+func NewFileLock(path string) *FileLock {
+	return &FileLock{path: path}
+}
+
+func (l *FileLock) TryAcquire(_ context.Context, _ time.Duration) (bool, error) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	if l.file != nil {
+		return true, nil
+	}
+
+	file, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return false, fmt.Errorf("can't open lock file %s: %w", l.path, err)
+	}
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		closeErr := file.Close()
+
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return false, closeErr
+		}
+
+		return false, fmt.Errorf("can't lock %s: %w", l.path, errors.Join(err, closeErr))
+	}
+
+	// Для того, кто разбирается, какой экземпляр сейчас лидер
+	if err := file.Truncate(0); err == nil {
+		hostname, _ := os.Hostname()
+		_, _ = fmt.Fprintf(file, "%s %d %s\n", hostname, os.Getpid(), time.Now().Format(time.RFC3339))
+	}
+
+	l.file = file
+
+	return true, nil
+}
+
+func (l *FileLock) Release(_ context.Context) error {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+
+	err := errors.Join(syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN), l.file.Close())
+	l.file = nil
+
+	if err != nil {
+		return fmt.Errorf("can't unlock %s: %w", l.path, err)
+	}
+
+	return nil
+}
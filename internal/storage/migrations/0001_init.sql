@@ -0,0 +1,15 @@
+-- Сохраненные сущности. Запись есть, даже если сущность пустая, чтобы не подставлять
+-- вместо нее исходные данные из файлов.
+CREATE TABLE entities (
+    name       TEXT PRIMARY KEY,
+    updated_at TIMESTAMP NOT NULL
+);
+
+-- Данные сущностей: по строке на ключ верхнего уровня (обычно идентификатор пользователя).
+-- Сущности, которые не являются объектом (например, список товаров), хранятся одной строкой с пустым ключом.
+CREATE TABLE state (
+    entity TEXT NOT NULL,
+    key    TEXT NOT NULL,
+    data   TEXT NOT NULL,
+    PRIMARY KEY (entity, key)
+);
@@ -0,0 +1,24 @@
+-- Кошельки по строке на пользователя: счета, транзакции, дневные пополнения и контакты.
+-- Каждое изменение кошелька записывается сразу, а не снимком состояния.
+CREATE TABLE wallets (
+    user_id    TEXT PRIMARY KEY,
+    data       TEXT NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+-- Заказы по строке на заказ, seq сохраняет порядок оформления.
+CREATE TABLE orders (
+    seq        INTEGER PRIMARY KEY AUTOINCREMENT,
+    id         TEXT NOT NULL UNIQUE,
+    user_id    TEXT NOT NULL,
+    data       TEXT NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX orders_user_id ON orders (user_id);
+
+-- Репозитории, в которые уже записаны исходные данные. Опустевший репозиторий не заполняется заново.
+CREATE TABLE seeded (
+    name       TEXT PRIMARY KEY,
+    seeded_at  TIMESTAMP NOT NULL
+);
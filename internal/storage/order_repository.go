@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"eats-backend/internal/models"
+)
+
+// OrderRepository хранит заказы в SQLite по строке на заказ. Сервис заказов записывает в него
+// каждое изменение заказа до ответа пользователю.
+type OrderRepository struct {
+	store *SQLiteStore
+}
+
+func NewOrderRepository(store *SQLiteStore) *OrderRepository {
+	return &OrderRepository{store: store}
+}
+
+// SaveOrder добавляет заказ или заменяет сохраненный с тем же идентификатором.
+func (r *OrderRepository) SaveOrder(ctx context.Context, userID string, order models.Order) error {
+	return saveOrder(ctx, r.store.db, userID, order)
+}
+
+// DeleteOrder удаляет заказ, оформление которого откатилось
+func (r *OrderRepository) DeleteOrder(ctx context.Context, orderID string) error {
+	if _, err := r.store.db.ExecContext(ctx, `DELETE FROM orders WHERE id = ?`, orderID); err != nil {
+		return fmt.Errorf("can't delete order %s: %w", orderID, err)
+	}
+
+	return nil
+}
+
+// ReplaceOrders в одной транзакции заменяет все заказы перечисленных пользователей.
+func (r *OrderRepository) ReplaceOrders(ctx context.Context, orders map[string][]*models.Order) error {
+	tx, err := r.store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("can't begin transaction: %w", err)
+	}
+	// после Commit возвращает ErrTxDone, ошибку отката проверять не нужно
+	defer func() { _ = tx.Rollback() }()
+
+	if err := replaceOrders(ctx, tx, orders); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("can't commit orders: %w", err)
+	}
+
+	return nil
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func saveOrder(ctx context.Context, db execer, userID string, order models.Order) error {
+	data, err := json.Marshal(order)
+	if err != nil {
+		return fmt.Errorf("can't marshal order %s: %w", order.ID, err)
+	}
+
+	_, err = db.ExecContext(ctx,
+		`INSERT INTO orders (id, user_id, data, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET user_id = excluded.user_id, data = excluded.data, updated_at = excluded.updated_at`,
+		order.ID, userID, string(data), time.Now(),
+	)
+	if err != nil {
+		return fmt.Errorf("can't save order %s: %w", order.ID, err)
+	}
+
+	return nil
+}
+
+func replaceOrders(ctx context.Context, tx *sql.Tx, orders map[string][]*models.Order) error {
+	// Пользователи по порядку, чтобы запись не зависела от порядка map
+	for _, userID := range slices.Sorted(maps.Keys(orders)) {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM orders WHERE user_id = ?`, userID); err != nil {
+			return fmt.Errorf("can't clear orders of %s: %w", userID, err)
+		}
+
+		for _, order := range orders[userID] {
+			if err := saveOrder(ctx, tx, userID, *order); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Load читает заказы всех пользователей в порядке оформления
+func (r *OrderRepository) Load(ctx context.Context) (map[string][]*models.Order, error) {
+	rows, err := r.store.db.QueryContext(ctx, `SELECT user_id, data FROM orders ORDER BY seq`)
+	if err != nil {
+		return nil, fmt.Errorf("can't load orders: %w", err)
+	}
+	defer rows.Close()
+
+	result := make(map[string][]*models.Order)
+
+	for rows.Next() {
+		var userID, data string
+		if err := rows.Scan(&userID, &data); err != nil {
+			return nil, fmt.Errorf("can't scan order: %w", err)
+		}
+
+		var order models.Order
+		if err := json.Unmarshal([]byte(data), &order); err != nil {
+			return nil, fmt.Errorf("can't unmarshal order of %s: %w", userID, err)
+		}
+
+		result[userID] = append(result[userID], &order)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("can't load orders: %w", err)
+	}
+
+	return result, nil
+}
+
+// LoadOrSeed заменяет orders заказами из базы. При первом запуске база заполняется заказами из orders.
+func (r *OrderRepository) LoadOrSeed(ctx context.Context, orders *map[string][]*models.Order) error {
+	seeded, err := r.store.seed(ctx, "orders", func(tx *sql.Tx) error {
+		return replaceOrders(ctx, tx, *orders)
+	})
+	if err != nil || seeded {
+		return err
+	}
+
+	loaded, err := r.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	*orders = loaded
+
+	return nil
+}
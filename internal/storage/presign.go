@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"eats-backend/internal/metrics"
+	"eats-backend/internal/models"
+)
+
+const presignedExt = ".jxl"
+
+// PresignUpload выдает ссылку, по которой клиент может один раз загрузить файл запросом PUT
+// без токена авторизации. Имя файла выбирается заранее и входит в подпись.
+func (s *Storage) PresignUpload(ctx context.Context) (*models.PresignedUpload, error) {
+	name := uuid.NewString() + presignedExt
+	expiresAt := time.Now().Add(s.presignTTL).Truncate(time.Second)
+
+	// Загрузка по ссылке идет без токена, поэтому владелец файла запоминается при выдаче ссылки
+	if claims := models.ClaimsFromContext(ctx); claims != nil {
+		s.mux.Lock()
+		maps.DeleteFunc(s.presigned, func(_ string, owner presignedOwner) bool {
+			return time.Now().After(owner.expiresAt)
+		})
+		s.presigned[name] = presignedOwner{userID: claims.ID, expiresAt: expiresAt}
+		s.mux.Unlock()
+	}
+
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+
+	query := url.Values{}
+	query.Set("expires", expires)
+	query.Set("signature", s.sign(name, expires))
+
+	return &models.PresignedUpload{
+		File:      name,
+		UploadURL: s.baseURL + name + "?" + query.Encode(),
+		Method:    http.MethodPut,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// SaveSignedFile сохраняет тело запроса как файл name, если подпись ссылки верна и срок ее действия не истек.
+func (s *Storage) SaveSignedFile(w http.ResponseWriter, r *http.Request, name, expires, signature string) error {
+	if filepath.Ext(name) != presignedExt || filepath.Base(name) != name {
+		return fmt.Errorf("%w: invalid file name", models.ErrBadRequest)
+	}
+
+	expiresUnix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: invalid expires: %w", models.ErrBadRequest, err)
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(s.sign(name, expires))) {
+		metrics.UploadRejections.Inc("invalid_signature")
+
+		return fmt.Errorf("%w: invalid upload signature", models.ErrForbidden)
+	}
+
+	if time.Now().Unix() > expiresUnix {
+		metrics.UploadRejections.Inc("expired")
+
+		return fmt.Errorf("%w: upload url expired", models.ErrForbidden)
+	}
+
+	release, err := s.acquireSlot()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if err := os.MkdirAll(s.dir, os.ModePerm); err != nil {
+		return fmt.Errorf("%w: can't create upload dir: %w", models.ErrInternalServer, err)
+	}
+
+	// O_EXCL делает ссылку одноразовой: загруженный файл нельзя перезаписать повторным запросом
+	_, err = s.writeJXL(name, http.MaxBytesReader(w, r.Body, maxUploadSize), os.O_CREATE|os.O_EXCL)
+	if errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("%w: file already uploaded", models.ErrForbidden)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	s.mux.Lock()
+	owner := s.presigned[name]
+	delete(s.presigned, name)
+	s.mux.Unlock()
+
+	s.recordUpload(owner.userID, name)
+
+	return nil
+}
+
+func (s *Storage) sign(name, expires string) string {
+	mac := hmac.New(sha256.New, s.signingKey)
+	mac.Write([]byte(name + "\n" + expires))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,402 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"eats-backend/internal/models"
+)
+
+// changeQuantityScript атомарно меняет количество товара и удаляет его при нулевом количестве.
+var changeQuantityScript = redis.NewScript(`
+local quantity = redis.call('HINCRBY', KEYS[1], ARGV[1], ARGV[2])
+if quantity <= 0 then
+	redis.call('HDEL', KEYS[1], ARGV[1])
+	return 0
+end
+return quantity
+`)
+
+// RedisCartStore хранит корзины в Redis: по хешу productID -> количество на пользователя
+// и отдельному хешу productID -> комментарий. Резерв корзины - JSON в ключе со временем жизни,
+// поэтому он общий для всех экземпляров.
+type RedisCartStore struct {
+	client             *redis.Client
+	prefix             string
+	commentsPrefix     string
+	reservationsPrefix string
+}
+
+func NewRedisCartStore(client *redis.Client, keyPrefix string) *RedisCartStore {
+	return &RedisCartStore{
+		client:             client,
+		prefix:             keyPrefix + "cart:",
+		commentsPrefix:     keyPrefix + "cart_comments:",
+		reservationsPrefix: keyPrefix + "cart_reservation:",
+	}
+}
+
+func (s *RedisCartStore) GetItems(ctx context.Context, userID string) (map[string]int, error) {
+	values, err := s.client.HGetAll(ctx, s.prefix+userID).Result()
+	if err != nil {
+		return nil, fmt.Errorf("can't get cart %s: %w", userID, err)
+	}
+
+	return parseQuantities(values)
+}
+
+func (s *RedisCartStore) ChangeQuantity(ctx context.Context, userID, productID string, delta int) (int, error) {
+	quantity, err := changeQuantityScript.Run(ctx, s.client, []string{s.prefix + userID}, productID, delta).Int()
+	if err != nil {
+		return 0, fmt.Errorf("can't change quantity in cart %s: %w", userID, err)
+	}
+
+	return quantity, nil
+}
+
+func (s *RedisCartStore) SetItems(ctx context.Context, userID string, items map[string]int) error {
+	key := s.prefix + userID
+
+	values := make(map[string]any, len(items))
+	for productID, quantity := range items {
+		values[productID] = quantity
+	}
+
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key)
+
+		if len(values) > 0 {
+			pipe.HSet(ctx, key, values)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("can't set cart %s: %w", userID, err)
+	}
+
+	return nil
+}
+
+func (s *RedisCartStore) GetAll(ctx context.Context) (map[string]map[string]int, error) {
+	result := make(map[string]map[string]int)
+
+	iter := s.client.Scan(ctx, 0, s.prefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		userID := strings.TrimPrefix(iter.Val(), s.prefix)
+
+		items, err := s.GetItems(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+
+		result[userID] = items
+	}
+
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("can't scan carts: %w", err)
+	}
+
+	return result, nil
+}
+
+func (s *RedisCartStore) GetComments(ctx context.Context, userID string) (map[string]string, error) {
+	comments, err := s.client.HGetAll(ctx, s.commentsPrefix+userID).Result()
+	if err != nil {
+		return nil, fmt.Errorf("can't get cart comments %s: %w", userID, err)
+	}
+
+	return comments, nil
+}
+
+func (s *RedisCartStore) SetComment(ctx context.Context, userID, productID, comment string) error {
+	key := s.commentsPrefix + userID
+
+	var err error
+	if comment == "" {
+		err = s.client.HDel(ctx, key, productID).Err()
+	} else {
+		err = s.client.HSet(ctx, key, productID, comment).Err()
+	}
+
+	if err != nil {
+		return fmt.Errorf("can't set cart comment %s: %w", userID, err)
+	}
+
+	return nil
+}
+
+func (s *RedisCartStore) SetComments(ctx context.Context, userID string, comments map[string]string) error {
+	key := s.commentsPrefix + userID
+
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key)
+
+		if len(comments) > 0 {
+			pipe.HSet(ctx, key, comments)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("can't set cart comments %s: %w", userID, err)
+	}
+
+	return nil
+}
+
+func (s *RedisCartStore) GetAllComments(ctx context.Context) (map[string]map[string]string, error) {
+	result := make(map[string]map[string]string)
+
+	iter := s.client.Scan(ctx, 0, s.commentsPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		userID := strings.TrimPrefix(iter.Val(), s.commentsPrefix)
+
+		comments, err := s.GetComments(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+
+		result[userID] = comments
+	}
+
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("can't scan cart comments: %w", err)
+	}
+
+	return result, nil
+}
+
+func (s *RedisCartStore) Reserve(ctx context.Context, userID string, items map[string]int, ttl time.Duration) (bool, error) {
+	value, err := json.Marshal(reservationItems(items))
+	if err != nil {
+		return false, fmt.Errorf("can't encode cart reservation %s: %w", userID, err)
+	}
+
+	reserved, err := s.client.SetNX(ctx, s.reservationsPrefix+userID, value, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("can't reserve cart %s: %w", userID, err)
+	}
+
+	return reserved, nil
+}
+
+func (s *RedisCartStore) GetReservation(ctx context.Context, userID string) (map[string]int, error) {
+	value, err := s.client.Get(ctx, s.reservationsPrefix+userID).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("can't get cart reservation %s: %w", userID, err)
+	}
+
+	items := make(map[string]int)
+	if err := json.Unmarshal(value, &items); err != nil {
+		return nil, fmt.Errorf("can't parse cart reservation %s: %w", userID, err)
+	}
+
+	return items, nil
+}
+
+func (s *RedisCartStore) UpdateReservation(ctx context.Context, userID string, items map[string]int) error {
+	value, err := json.Marshal(reservationItems(items))
+	if err != nil {
+		return fmt.Errorf("can't encode cart reservation %s: %w", userID, err)
+	}
+
+	// XX не создает резерв заново, если он уже истек
+	err = s.client.SetArgs(ctx, s.reservationsPrefix+userID, value, redis.SetArgs{Mode: "XX", KeepTTL: true}).Err()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return fmt.Errorf("can't update cart reservation %s: %w", userID, err)
+	}
+
+	return nil
+}
+
+func (s *RedisCartStore) ReleaseReservation(ctx context.Context, userID string) error {
+	if err := s.client.Del(ctx, s.reservationsPrefix+userID).Err(); err != nil {
+		return fmt.Errorf("can't release cart reservation %s: %w", userID, err)
+	}
+
+	return nil
+}
+
+func (s *RedisCartStore) ReleaseAllReservations(ctx context.Context) error {
+	iter := s.client.Scan(ctx, 0, s.reservationsPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		if err := s.client.Del(ctx, iter.Val()).Err(); err != nil {
+			return fmt.Errorf("can't release cart reservation: %w", err)
+		}
+	}
+
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("can't scan cart reservations: %w", err)
+	}
+
+	return nil
+}
+
+// reservationItems не дает пустому резерву сохраниться как null
+func reservationItems(items map[string]int) map[string]int {
+	if items == nil {
+		return map[string]int{}
+	}
+
+	return items
+}
+
+// Seed заполняет корзины из файла данных для пользователей, у которых корзины в Redis еще нет.
+// Так данные не затираются при перезапуске или старте следующего экземпляра.
+func (s *RedisCartStore) Seed(ctx context.Context, carts map[string]map[string]*models.CartItem) error {
+	for userID, cart := range carts {
+		if len(cart) == 0 {
+			continue
+		}
+
+		exists, err := s.client.Exists(ctx, s.prefix+userID).Result()
+		if err != nil {
+			return fmt.Errorf("can't check cart %s: %w", userID, err)
+		}
+
+		if exists > 0 {
+			continue
+		}
+
+		items := make(map[string]int, len(cart))
+		comments := make(map[string]string)
+
+		for productID, item := range cart {
+			items[productID] = item.Quantity
+
+			if item.Comment != "" {
+				comments[productID] = item.Comment
+			}
+		}
+
+		if err := s.SetItems(ctx, userID, items); err != nil {
+			return err
+		}
+
+		if err := s.SetComments(ctx, userID, comments); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func parseQuantities(values map[string]string) (map[string]int, error) {
+	result := make(map[string]int, len(values))
+
+	for productID, value := range values {
+		quantity, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quantity of %s: %w", productID, err)
+		}
+
+		result[productID] = quantity
+	}
+
+	return result, nil
+}
+
+// RedisRevocationList хранит идентификаторы отозванных токенов в множестве Redis,
+// общем для всех экземпляров сервера.
+type RedisRevocationList struct {
+	client *redis.Client
+	key    string
+}
+
+func NewRedisRevocationList(client *redis.Client, keyPrefix string) *RedisRevocationList {
+	return &RedisRevocationList{
+		client: client,
+		key:    keyPrefix + "revoked_tokens",
+	}
+}
+
+func (l *RedisRevocationList) IsRevoked(ctx context.Context, tokenID string) (bool, error) {
+	revoked, err := l.client.SIsMember(ctx, l.key, tokenID).Result()
+	if err != nil {
+		return false, fmt.Errorf("can't check revoked token: %w", err)
+	}
+
+	return revoked, nil
+}
+
+// Add добавляет токены в список отозванных.
+func (l *RedisRevocationList) Add(ctx context.Context, tokenIDs ...string) error {
+	if len(tokenIDs) == 0 {
+		return nil
+	}
+
+	members := make([]any, len(tokenIDs))
+	for i, id := range tokenIDs {
+		members[i] = id
+	}
+
+	if err := l.client.SAdd(ctx, l.key, members...).Err(); err != nil {
+		return fmt.Errorf("can't add revoked tokens: %w", err)
+	}
+
+	return nil
+}
+
+// acquireLockScript захватывает свободную блокировку или продлевает свою.
+var acquireLockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+if redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then
+	return 1
+end
+return 0
+`)
+
+// releaseLockScript снимает блокировку, только если ее держит этот экземпляр.
+var releaseLockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// RedisLock блокировка лидера в Redis: ключ с идентификатором экземпляра и временем жизни.
+// Если лидер упал и не продлил ключ, через ttl его место займет другой экземпляр.
+type RedisLock struct {
+	client *redis.Client
+	key    string
+	owner  string
+}
+
+func NewRedisLock(client *redis.Client, keyPrefix, owner string) *RedisLock {
+	return &RedisLock{
+		client: client,
+		key:    keyPrefix + "leader",
+		owner:  owner,
+	}
+}
+
+func (l *RedisLock) TryAcquire(ctx context.Context, ttl time.Duration) (bool, error) {
+	acquired, err := acquireLockScript.Run(ctx, l.client, []string{l.key}, l.owner, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, fmt.Errorf("can't acquire leader lock: %w", err)
+	}
+
+	return acquired == 1, nil
+}
+
+func (l *RedisLock) Release(ctx context.Context) error {
+	if err := releaseLockScript.Run(ctx, l.client, []string{l.key}, l.owner).Err(); err != nil {
+		return fmt.Errorf("can't release leader lock: %w", err)
+	}
+
+	return nil
+}
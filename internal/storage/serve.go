@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"eats-backend/internal/models"
+)
+
+// contentHashName имя файла по SHA-256 содержимого: такой файл никогда не меняется
+var contentHashName = regexp.MustCompile(`^[0-9a-f]{64}\.[a-z0-9]+$`)
+
+const (
+	immutableCacheControl = "public, max-age=31536000, immutable"
+	// Остальные файлы кешируются, но каждый раз проверяются по ETag
+	revalidateCacheControl = "public, no-cache"
+
+	// Подпись скачивания отличается от подписи загрузки, чтобы ссылку на загрузку нельзя было использовать
+	// для скачивания и наоборот
+	downloadSignPrefix = "download:"
+
+	// Файл, который еще загружается и будет переименован по хешу содержимого
+	tempExt = ".tmp"
+)
+
+// contentTypes типы, которых может не быть в системной базе mime
+var contentTypes = map[string]string{
+	".jxl": "image/jxl",
+}
+
+// SignDownload выдает ссылку на скачивание файла. Нужна, когда файлы закрыты (UPLOADS_PRIVATE):
+// тогда без подписи файл не отдается. Ссылка действует столько же, сколько ссылка на загрузку.
+func (s *Storage) SignDownload(_ context.Context, name string) (*models.SignedDownload, error) {
+	if _, err := s.statUpload(name); err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(s.presignTTL).Truncate(time.Second)
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+
+	query := url.Values{}
+	query.Set("expires", expires)
+	query.Set("signature", s.sign(downloadSignPrefix+name, expires))
+
+	return &models.SignedDownload{
+		File:        name,
+		DownloadURL: s.baseURL + name + "?" + query.Encode(),
+		ExpiresAt:   expiresAt,
+	}, nil
+}
+
+// ServeUpload отдает загруженный файл с типом содержимого, заголовками кеширования, ETag и поддержкой Range.
+func (s *Storage) ServeUpload(w http.ResponseWriter, r *http.Request, name string) error {
+	info, err := s.statUpload(name)
+	if err != nil {
+		return err
+	}
+
+	expiresAt, err := s.checkDownload(r, name)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(filepath.Join(s.dir, name))
+	if err != nil {
+		return fmt.Errorf("%w: file %s not found", models.ErrNotFound, name)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			s.logger.Warnf("can't close file: %v", err)
+		}
+	}()
+
+	header := w.Header()
+
+	ext := filepath.Ext(name)
+	contentType, ok := contentTypes[ext]
+	if !ok {
+		contentType = mime.TypeByExtension(ext)
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	header.Set("Content-Type", contentType)
+	header.Set("X-Content-Type-Options", "nosniff")
+
+	isContentHash := contentHashName.MatchString(name)
+	if isContentHash {
+		header.Set("ETag", `"`+strings.TrimSuffix(name, ext)+`"`)
+	} else {
+		header.Set("ETag", fmt.Sprintf(`W/"%x-%x"`, info.ModTime().UnixNano(), info.Size()))
+	}
+
+	switch {
+	case !expiresAt.IsZero():
+		// Подписанную ссылку нельзя кешировать дольше срока ее действия
+		maxAge := max(int(time.Until(expiresAt).Seconds()), 0)
+		header.Set("Cache-Control", "private, max-age="+strconv.Itoa(maxAge))
+	case isContentHash:
+		header.Set("Cache-Control", immutableCacheControl)
+	default:
+		header.Set("Cache-Control", revalidateCacheControl)
+	}
+
+	http.ServeContent(w, r, name, info.ModTime(), file)
+
+	return nil
+}
+
+// checkDownload проверяет подпись ссылки на скачивание. Для открытых файлов подпись не нужна и не проверяется.
+// Возвращает срок действия ссылки, если файл закрыт.
+func (s *Storage) checkDownload(r *http.Request, name string) (time.Time, error) {
+	if !s.private {
+		return time.Time{}, nil
+	}
+
+	query := r.URL.Query()
+	expires := query.Get("expires")
+
+	expiresUnix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: file is private, signed link required", models.ErrForbidden)
+	}
+
+	if !hmac.Equal([]byte(query.Get("signature")), []byte(s.sign(downloadSignPrefix+name, expires))) {
+		return time.Time{}, fmt.Errorf("%w: invalid download signature", models.ErrForbidden)
+	}
+
+	expiresAt := time.Unix(expiresUnix, 0)
+	if time.Now().After(expiresAt) {
+		return time.Time{}, fmt.Errorf("%w: download url expired", models.ErrForbidden)
+	}
+
+	return expiresAt, nil
+}
+
+// statUpload проверяет, что файл есть в каталоге загрузок и уже загружен до конца. Имя не может выходить
+// за пределы каталога.
+func (s *Storage) statUpload(name string) (os.FileInfo, error) {
+	if name == "" || name == "." || name == ".." || filepath.Base(name) != name {
+		return nil, fmt.Errorf("%w: invalid file name %s", models.ErrBadRequest, name)
+	}
+
+	info, err := os.Stat(filepath.Join(s.dir, name))
+	if err != nil || !info.Mode().IsRegular() || filepath.Ext(name) == tempExt {
+		return nil, fmt.Errorf("%w: file %s not found", models.ErrNotFound, name)
+	}
+
+	return info, nil
+}
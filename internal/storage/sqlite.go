@@ -0,0 +1,255 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// SQLiteStore хранит состояние сервисов в файле SQLite. Используется вместо файлов data/
+// для развертывания на одном сервере, когда данные не должны теряться между бэкапами.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+func NewSQLiteStore(ctx context.Context, dbPath string) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite", dbPath+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)&_time_format=sqlite")
+	if err != nil {
+		return nil, fmt.Errorf("can't open sqlite %s: %w", dbPath, err)
+	}
+
+	// SQLite не поддерживает параллельную запись, одно соединение избавляет от SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+
+	store := &SQLiteStore{db: db}
+
+	if err := store.migrate(ctx); err != nil {
+		return nil, errors.Join(err, db.Close())
+	}
+
+	return store, nil
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// Save заменяет сохраненное состояние сущности name.
+func (s *SQLiteStore) Save(ctx context.Context, name string, data any) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("can't marshal %s: %w", name, err)
+	}
+
+	rows := map[string]json.RawMessage{"": raw}
+	if bytes.HasPrefix(bytes.TrimSpace(raw), []byte("{")) {
+		rows = make(map[string]json.RawMessage)
+		if err := json.Unmarshal(raw, &rows); err != nil {
+			return fmt.Errorf("can't split %s: %w", name, err)
+		}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("can't begin transaction: %w", err)
+	}
+	// после Commit возвращает ErrTxDone, ошибку отката проверять не нужно
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM state WHERE entity = ?`, name); err != nil {
+		return fmt.Errorf("can't clear %s: %w", name, err)
+	}
+
+	for key, value := range rows {
+		_, err := tx.ExecContext(ctx, `INSERT INTO state (entity, key, data) VALUES (?, ?, ?)`, name, key, string(value))
+		if err != nil {
+			return fmt.Errorf("can't save %s/%s: %w", name, key, err)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO entities (name, updated_at) VALUES (?, ?)
+		ON CONFLICT (name) DO UPDATE SET updated_at = excluded.updated_at`,
+		name, time.Now(),
+	)
+	if err != nil {
+		return fmt.Errorf("can't mark %s saved: %w", name, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("can't commit %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// Load читает сохраненное состояние сущности name в dst. Возвращает false, если сущность еще не сохранялась.
+func (s *SQLiteStore) Load(ctx context.Context, name string, dst any) (bool, error) {
+	var saved int
+
+	err := s.db.QueryRowContext(ctx, `SELECT 1 FROM entities WHERE name = ?`, name).Scan(&saved)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("can't check %s: %w", name, err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT key, data FROM state WHERE entity = ?`, name)
+	if err != nil {
+		return false, fmt.Errorf("can't load %s: %w", name, err)
+	}
+	defer rows.Close()
+
+	object := make(map[string]json.RawMessage)
+
+	for rows.Next() {
+		var key, data string
+		if err := rows.Scan(&key, &data); err != nil {
+			return false, fmt.Errorf("can't scan %s: %w", name, err)
+		}
+
+		object[key] = json.RawMessage(data)
+	}
+
+	if err := rows.Err(); err != nil {
+		return false, fmt.Errorf("can't load %s: %w", name, err)
+	}
+
+	raw, ok := object[""]
+	if !ok {
+		raw, err = json.Marshal(object)
+		if err != nil {
+			return false, fmt.Errorf("can't join %s: %w", name, err)
+		}
+	}
+
+	if err := json.Unmarshal(raw, dst); err != nil {
+		return false, fmt.Errorf("can't unmarshal %s: %w", name, err)
+	}
+
+	return true, nil
+}
+
+// seed записывает исходные данные репозитория name, если они еще не записывались. Возвращает false,
+// если репозиторий уже заполнен и данные нужно читать из него.
+func (s *SQLiteStore) seed(ctx context.Context, name string, write func(tx *sql.Tx) error) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("can't begin transaction: %w", err)
+	}
+	// после Commit возвращает ErrTxDone, ошибку отката проверять не нужно
+	defer func() { _ = tx.Rollback() }()
+
+	var seeded int
+
+	err = tx.QueryRowContext(ctx, `SELECT 1 FROM seeded WHERE name = ?`, name).Scan(&seeded)
+	if err == nil {
+		return false, nil
+	}
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		return false, fmt.Errorf("can't check %s: %w", name, err)
+	}
+
+	if err := write(tx); err != nil {
+		return false, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO seeded (name, seeded_at) VALUES (?, ?)`, name, time.Now()); err != nil {
+		return false, fmt.Errorf("can't mark %s seeded: %w", name, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("can't commit %s: %w", name, err)
+	}
+
+	return true, nil
+}
+
+// migrate применяет миграции из migrations/, которые еще не применялись. Версия - числовой префикс имени файла.
+func (s *SQLiteStore) migrate(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TIMESTAMP NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("can't create schema_migrations: %w", err)
+	}
+
+	var current int
+	if err := s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("can't get schema version: %w", err)
+	}
+
+	files, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return fmt.Errorf("can't list migrations: %w", err)
+	}
+
+	slices.Sort(files)
+
+	for _, file := range files {
+		prefix, _, _ := strings.Cut(path.Base(file), "_")
+
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return fmt.Errorf("invalid migration name %s: %w", file, err)
+		}
+
+		if version <= current {
+			continue
+		}
+
+		if err := s.applyMigration(ctx, file, version); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *SQLiteStore) applyMigration(ctx context.Context, file string, version int) error {
+	query, err := migrations.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("can't read migration %s: %w", file, err)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("can't begin transaction: %w", err)
+	}
+	// после Commit возвращает ErrTxDone, ошибку отката проверять не нужно
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, string(query)); err != nil {
+		return fmt.Errorf("can't apply migration %s: %w", file, err)
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`, version, time.Now())
+	if err != nil {
+		return fmt.Errorf("can't record migration %s: %w", file, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("can't commit migration %s: %w", file, err)
+	}
+
+	return nil
+}
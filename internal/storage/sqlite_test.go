@@ -0,0 +1,86 @@
+package storage_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"eats-backend/internal/models"
+	"eats-backend/internal/storage"
+)
+
+func TestSQLite_WalletRepository(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.db")
+
+	store, err := storage.NewSQLiteStore(t.Context(), path)
+	require.NoError(t, err)
+
+	initial := models.WalletData{
+		Accounts: map[string]map[string]*models.Account{
+			"alice": {"card": {ID: "card", Type: models.AccountTypeCard, Balance: models.Rubles(100)}},
+		},
+		UserPhones: map[string]string{"bob": "+71111111111"},
+	}
+
+	// При первом запуске кошельки берутся из исходных данных
+	data := initial
+	wallets := storage.NewWalletRepository(store)
+	require.NoError(t, wallets.LoadOrSeed(t.Context(), &data))
+	require.Equal(t, initial, data)
+
+	require.NoError(t, wallets.SaveWallets(t.Context(), map[string]*models.UserWallet{
+		"alice": {
+			Accounts:     map[string]*models.Account{"card": {ID: "card", Type: models.AccountTypeCard, Balance: models.Rubles(40)}},
+			Transactions: []models.Transaction{{ID: "tx-1", Amount: -models.Rubles(60)}},
+		},
+		"bob": nil,
+	}))
+	require.NoError(t, store.Close())
+
+	// После перезапуска кошельки читаются из базы, исходные данные не подставляются
+	store, err = storage.NewSQLiteStore(t.Context(), path)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, store.Close()) })
+
+	data = initial
+	require.NoError(t, storage.NewWalletRepository(store).LoadOrSeed(t.Context(), &data))
+	require.Equal(t, models.Rubles(40), data.Accounts["alice"]["card"].Balance)
+	require.Equal(t, []models.Transaction{{ID: "tx-1", Amount: -models.Rubles(60)}}, data.Transactions["alice"])
+	require.Empty(t, data.UserPhones)
+}
+
+func TestSQLite_OrderRepository(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.db")
+
+	store, err := storage.NewSQLiteStore(t.Context(), path)
+	require.NoError(t, err)
+
+	initial := map[string][]*models.Order{
+		"alice": {{ID: "a-1", Status: models.OrderStatusActive}, {ID: "a-2", Status: models.OrderStatusActive}},
+	}
+
+	orders := initial
+	repo := storage.NewOrderRepository(store)
+	require.NoError(t, repo.LoadOrSeed(t.Context(), &orders))
+
+	require.NoError(t, repo.SaveOrder(t.Context(), "alice", models.Order{ID: "a-3", Status: models.OrderStatusActive}))
+	require.NoError(t, repo.SaveOrder(t.Context(), "alice", models.Order{ID: "a-1", Status: models.OrderStatusCompleted}))
+	require.NoError(t, repo.DeleteOrder(t.Context(), "a-2"))
+	require.NoError(t, repo.ReplaceOrders(t.Context(), map[string][]*models.Order{
+		"bob": {{ID: "b-1", Status: models.OrderStatusRefunded}},
+	}))
+	require.NoError(t, store.Close())
+
+	store, err = storage.NewSQLiteStore(t.Context(), path)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, store.Close()) })
+
+	// Измененный заказ остается на своем месте в порядке оформления
+	orders = initial
+	require.NoError(t, storage.NewOrderRepository(store).LoadOrSeed(t.Context(), &orders))
+	require.Equal(t, map[string][]*models.Order{
+		"alice": {{ID: "a-1", Status: models.OrderStatusCompleted}, {ID: "a-3", Status: models.OrderStatusActive}},
+		"bob":   {{ID: "b-1", Status: models.OrderStatusRefunded}},
+	}, orders)
+}
@@ -2,17 +2,29 @@ package storage
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"mime/multipart"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 
+	"eats-backend/internal/metrics"
 	"eats-backend/internal/models"
 )
 
@@ -24,18 +36,127 @@ var (
 	jxlContainerSignature = []byte{0x00, 0x00, 0x00, 0x0C, 0x4A, 0x58, 0x4C, 0x20, 0x0D, 0x0A, 0x87, 0x0A}
 )
 
+const maxUploadSize = 5 << 20 // 5MB
+
+// errUploadsBusy возвращается, когда заняты все слоты загрузки
+var errUploadsBusy = fmt.Errorf("%w: too many uploads in progress, retry later", models.ErrServiceUnavailable)
+
 type Storage struct {
 	logger *zap.SugaredLogger
 	dir    string
+
+	// Для подписанных ссылок на загрузку: адрес, по которому доступны файлы, ключ подписи и время жизни ссылки
+	baseURL    string
+	signingKey []byte
+	presignTTL time.Duration
+
+	// Закрытые файлы отдаются только по подписанной ссылке из SignDownload
+	private bool
+
+	// Слоты одновременных загрузок: запрос занимает слот на время чтения и записи файла
+	slots chan struct{}
+
+	// Загруженные файлы по пользователям и выданные, но еще не использованные подписанные ссылки по имени файла
+	uploads   map[string][]models.UploadedFile
+	presigned map[string]presignedOwner
+	// Сколько пользователей загрузили файл: одинаковые картинки хранятся одним файлом
+	refs map[string]int
+	// Сервисы, которые ссылаются на загруженные файлы из отзывов и доставок
+	referrers []UploadReferrer
+	mux       sync.RWMutex
+}
+
+// UploadReferrer сервис, который хранит ссылки на загруженные файлы. Пока файл упоминается, он не удаляется.
+type UploadReferrer interface {
+	ReferencedUploads() []string
+}
+
+type presignedOwner struct {
+	userID    string
+	expiresAt time.Time
+}
+
+func NewStorage(
+	logger *zap.SugaredLogger,
+	dir, baseURL string,
+	signingKey []byte,
+	presignTTL time.Duration,
+	maxConcurrent int,
+	private bool,
+	initialUploads map[string][]models.UploadedFile,
+) *Storage {
+	storage := &Storage{
+		logger:     logger,
+		dir:        dir,
+		baseURL:    baseURL,
+		signingKey: signingKey,
+		presignTTL: presignTTL,
+		private:    private,
+		slots:      make(chan struct{}, maxConcurrent),
+		presigned:  make(map[string]presignedOwner),
+	}
+
+	storage.loadUploads(initialUploads)
+
+	return storage
+}
+
+// loadUploads заменяет список загрузок, вызывается в конструкторе или под блокировкой
+func (s *Storage) loadUploads(data map[string][]models.UploadedFile) {
+	s.uploads = make(map[string][]models.UploadedFile, len(data))
+	s.refs = make(map[string]int)
+
+	for userID, files := range data {
+		s.uploads[userID] = slices.Clone(files)
+		for _, file := range files {
+			s.refs[file.Name]++
+		}
+	}
+}
+
+// RegisterReferrer добавляет сервис, ссылки которого учитываются при удалении файлов
+func (s *Storage) RegisterReferrer(referrer UploadReferrer) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.referrers = append(s.referrers, referrer)
 }
 
-func NewStorage(logger *zap.SugaredLogger, dir string) *Storage {
-	return &Storage{
-		logger: logger,
-		dir:    dir,
+// acquireSlot занимает слот загрузки. Если свободных нет, не ждет, а сразу возвращает ошибку,
+// чтобы тела запросов не копились в памяти.
+func (s *Storage) acquireSlot() (func(), error) {
+	select {
+	case s.slots <- struct{}{}:
+		return func() { <-s.slots }, nil
+	default:
+		metrics.UploadRejections.Inc("busy")
+
+		return nil, errUploadsBusy
 	}
 }
 
+// recordUpload запоминает, какой пользователь загрузил файл. Повторная загрузка того же файла тем же
+// пользователем не добавляет записи.
+func (s *Storage) recordUpload(userID, name string) {
+	if userID == "" {
+		return
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if slices.ContainsFunc(s.uploads[userID], func(file models.UploadedFile) bool { return file.Name == name }) {
+		return
+	}
+
+	s.refs[name]++
+	s.uploads[userID] = append(s.uploads[userID], models.UploadedFile{
+		Name:       name,
+		URL:        s.baseURL + name,
+		UploadedAt: time.Now(),
+	})
+}
+
 // isValidJXL проверяет, является ли содержимое файла действительным JXL файлом
 func isValidJXL(data []byte) bool {
 	// Проверяем минимальный размер
@@ -59,10 +180,18 @@ func isValidJXL(data []byte) bool {
 }
 
 func (s *Storage) SaveFile(w http.ResponseWriter, r *http.Request) (string, error) {
-	r.Body = http.MaxBytesReader(w, r.Body, 5<<20) // 5MB max
+	release, err := s.acquireSlot()
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
 
 	reader, err := r.MultipartReader()
 	if err != nil {
+		metrics.UploadRejections.Inc("invalid_request")
+
 		return "", fmt.Errorf("%w: invalid multipart request: %w", models.ErrBadRequest, err)
 	}
 
@@ -70,7 +199,7 @@ func (s *Storage) SaveFile(w http.ResponseWriter, r *http.Request) (string, erro
 		return "", fmt.Errorf("%w: can't create upload dir: %w", models.ErrInternalServer, err)
 	}
 
-	tempName := uuid.NewString()
+	tempName := uuid.NewString() + tempExt
 	var savedFile string
 
 	for {
@@ -88,11 +217,17 @@ func (s *Storage) SaveFile(w http.ResponseWriter, r *http.Request) (string, erro
 	}
 
 	if savedFile == "" {
+		metrics.UploadRejections.Inc("no_file")
+
 		return "", fmt.Errorf("%w: no file part found: %w", models.ErrBadRequest, err)
 	}
 
 	s.logger.Infof("uploaded file %s to %s successfully", savedFile, s.dir)
 
+	if claims := models.ClaimsFromContext(r.Context()); claims != nil {
+		s.recordUpload(claims.ID, savedFile)
+	}
+
 	return savedFile, nil
 }
 
@@ -111,24 +246,65 @@ func (s *Storage) loadPart(reader *multipart.Reader, tempName string) (string, e
 
 	ext := filepath.Ext(part.FileName())
 	if ext != ".jxl" {
+		metrics.UploadRejections.Inc("wrong_extension")
+
 		return "", fmt.Errorf("wrong extension, should be .jxl: %w", models.ErrBadRequest)
 	}
 
-	// Читаем файл в буфер (максимум 5MB уже ограничен в SaveFile)
-	fileData, err := io.ReadAll(part)
+	hash, err := s.writeJXL(tempName, part, os.O_CREATE|os.O_TRUNC)
 	if err != nil {
-		return "", fmt.Errorf("can't read file data: %w", err)
+		return "", err
+	}
+
+	return s.storeByHash(tempName, hash+ext)
+}
+
+// storeByHash переименовывает временный файл в имя по содержимому. Если такой файл уже есть,
+// временный удаляется и возвращается имя существующего.
+func (s *Storage) storeByHash(tempName, name string) (string, error) {
+	tempPath := filepath.Join(s.dir, tempName)
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if _, err := os.Stat(filepath.Join(s.dir, name)); err == nil {
+		_ = os.Remove(tempPath)
+		s.logger.Infof("file %s is already uploaded, reusing it", name)
+
+		return name, nil
+	}
+
+	if err := os.Rename(tempPath, filepath.Join(s.dir, name)); err != nil {
+		_ = os.Remove(tempPath)
+
+		return "", fmt.Errorf("can't rename file: %w", err)
+	}
+
+	return name, nil
+}
+
+// writeJXL проверяет сигнатуру JXL по первым байтам и потоком копирует файл в каталог загрузок,
+// не читая его в память целиком. Размер ограничивает вызывающий через http.MaxBytesReader.
+// Возвращает SHA-256 содержимого в hex.
+func (s *Storage) writeJXL(name string, src io.Reader, flag int) (string, error) {
+	header := make([]byte, len(jxlContainerSignature))
+
+	n, err := io.ReadFull(src, header)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return "", fmt.Errorf("%w: can't read file data: %w", models.ErrBadRequest, err)
 	}
 
 	// Проверяем, что это действительно JXL файл по содержимому
-	if !isValidJXL(fileData) {
-		s.logger.Warnf("rejected file %s: not a valid JXL file", part.FileName())
+	if !isValidJXL(header[:n]) {
+		s.logger.Warnf("rejected file %s: not a valid JXL file", name)
+		metrics.UploadRejections.Inc("invalid_image")
+
 		return "", fmt.Errorf("%w: file is not a valid JXL image", models.ErrBadRequest)
 	}
 
 	// Создаем файл для сохранения
-	fullPath := filepath.Join(s.dir, tempName+ext)
-	dst, err := os.Create(fullPath)
+	fullPath := filepath.Join(s.dir, name)
+	dst, err := os.OpenFile(fullPath, os.O_WRONLY|flag, 0666)
 	if err != nil {
 		return "", fmt.Errorf("can't create file: %w", err)
 	}
@@ -138,13 +314,140 @@ func (s *Storage) loadPart(reader *multipart.Reader, tempName string) (string, e
 		}
 	}()
 
-	// Записываем проверенные данные
-	if _, err := dst.Write(fileData); err != nil {
-		// Удаляем файл при ошибке записи
+	// Записываем проверенное начало и остаток файла, по пути считая хеш содержимого
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(dst, hash), io.MultiReader(bytes.NewReader(header[:n]), src)); err != nil {
+		// Удаляем недописанный файл
 		_ = os.Remove(fullPath)
+
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			metrics.UploadRejections.Inc("too_large")
+
+			return "", fmt.Errorf("%w: file is larger than %d bytes", models.ErrBadRequest, tooLarge.Limit)
+		}
+
 		return "", fmt.Errorf("can't write file: %w", err)
 	}
 
-	s.logger.Infof("validated and saved JXL file: %s", tempName+ext)
-	return tempName + ext, nil
+	s.logger.Infof("validated and saved JXL file: %s", name)
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// ResolveUpload проверяет, что картинка загружена на сервер, и возвращает ее полный URL. Принимает имя файла
+// из ответа /uploads или ссылку на хост загрузок, схема ссылки не важна.
+func (s *Storage) ResolveUpload(ref string) (string, error) {
+	name := ref
+
+	if strings.Contains(ref, "://") {
+		link, err := url.Parse(ref)
+		base, baseErr := url.Parse(s.baseURL)
+
+		if err != nil || baseErr != nil || !strings.EqualFold(link.Host, base.Host) ||
+			!strings.HasPrefix(link.Path, base.Path) {
+			return "", fmt.Errorf("%w: image %s is not on the upload host %s", models.ErrBadRequest, ref, s.baseURL)
+		}
+
+		name = strings.TrimPrefix(link.Path, base.Path)
+	}
+
+	if name == "" || name == "." || name == ".." || filepath.Base(name) != name {
+		return "", fmt.Errorf("%w: invalid image file name %s", models.ErrBadRequest, ref)
+	}
+
+	info, err := os.Stat(filepath.Join(s.dir, name))
+	if err != nil || !info.Mode().IsRegular() {
+		return "", fmt.Errorf("%w: image %s was not uploaded", models.ErrBadRequest, name)
+	}
+
+	return s.baseURL + name, nil
+}
+
+// ResetUser забывает загрузки пользователя и удаляет с диска файлы, на которые больше никто не ссылается:
+// их не загружали другие пользователи и они не используются в отзывах и фото доставки.
+func (s *Storage) ResetUser(userID string) {
+	s.mux.RLock()
+	referrers := slices.Clone(s.referrers)
+	s.mux.RUnlock()
+
+	referenced := make(map[string]bool)
+	for _, referrer := range referrers {
+		for _, ref := range referrer.ReferencedUploads() {
+			referenced[path.Base(ref)] = true
+		}
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	for _, file := range s.uploads[userID] {
+		s.refs[file.Name]--
+		if s.refs[file.Name] > 0 {
+			continue
+		}
+
+		delete(s.refs, file.Name)
+
+		if referenced[file.Name] {
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(s.dir, file.Name)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			s.logger.Warnf("can't remove upload %s: %v", file.Name, err)
+		}
+	}
+
+	delete(s.uploads, userID)
+}
+
+// ExportUserData возвращает файлы, загруженные пользователем
+func (s *Storage) ExportUserData(ctx context.Context) (any, error) {
+	userID := models.ClaimsFromContext(ctx).ID
+
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	files := slices.Clone(s.uploads[userID])
+	if files == nil {
+		files = []models.UploadedFile{}
+	}
+
+	return files, nil
+}
+
+func (s *Storage) GetUserExportName() string {
+	return "uploads"
+}
+
+// GetBackupData возвращает список загрузок по пользователям, сами файлы в бэкап не входят
+func (s *Storage) GetBackupData() interface{} {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	result := make(map[string][]models.UploadedFile, len(s.uploads))
+	for userID, files := range s.uploads {
+		result[userID] = slices.Clone(files)
+	}
+
+	return result
+}
+
+// GetBackupFileName возвращает имя файла для бэкапа
+func (s *Storage) GetBackupFileName() string {
+	return "uploads"
+}
+
+// RestoreBackupData заменяет список загрузок данными из бэкапа
+func (s *Storage) RestoreBackupData(data []byte) error {
+	var backup map[string][]models.UploadedFile
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return fmt.Errorf("can't parse uploads: %w", err)
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.loadUploads(backup)
+
+	return nil
 }
@@ -0,0 +1,316 @@
+package storage_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"maps"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"eats-backend/internal/models"
+	"eats-backend/internal/storage"
+)
+
+func uploadRequest(t *testing.T, body io.Reader, contentType string) (*httptest.ResponseRecorder, *http.Request) {
+	t.Helper()
+
+	request := httptest.NewRequest(http.MethodPost, "/uploads", body)
+	request.Header.Set("Content-Type", contentType)
+
+	return httptest.NewRecorder(), request
+}
+
+func multipartFile(t *testing.T, name string, data []byte) (*bytes.Buffer, string) {
+	t.Helper()
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	part, err := writer.CreateFormFile("file", name)
+	require.NoError(t, err)
+
+	_, err = part.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	return body, writer.FormDataContentType()
+}
+
+func TestStorage_SaveFile(t *testing.T) {
+	dir := t.TempDir()
+	files := storage.NewStorage(zap.NewNop().Sugar(), dir, "http://localhost/uploads/", []byte("key"), 0, 1, false, nil)
+
+	image := append([]byte{0xFF, 0x0A}, bytes.Repeat([]byte{1}, 64<<10)...)
+	body, contentType := multipartFile(t, "image.jxl", image)
+
+	name, err := files.SaveFile(uploadRequest(t, body, contentType))
+	require.NoError(t, err)
+
+	hash := sha256.Sum256(image)
+	require.Equal(t, hex.EncodeToString(hash[:])+".jxl", name)
+
+	saved, err := os.ReadFile(filepath.Join(dir, name))
+	require.NoError(t, err)
+	require.Equal(t, image, saved)
+
+	// Та же картинка повторно не сохраняется, возвращается имя существующего файла
+	body, contentType = multipartFile(t, "copy.jxl", image)
+	duplicate, err := files.SaveFile(uploadRequest(t, body, contentType))
+	require.NoError(t, err)
+	require.Equal(t, name, duplicate)
+
+	body, contentType = multipartFile(t, "image.jxl", []byte("not an image"))
+	_, err = files.SaveFile(uploadRequest(t, body, contentType))
+	require.ErrorIs(t, err, models.ErrBadRequest)
+
+	// Файл больше лимита отклоняется и не остается на диске
+	body, contentType = multipartFile(t, "big.jxl", append([]byte{0xFF, 0x0A}, make([]byte, 6<<20)...))
+	_, err = files.SaveFile(uploadRequest(t, body, contentType))
+	require.ErrorIs(t, err, models.ErrBadRequest)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+}
+
+func TestStorage_SaveFileBusy(t *testing.T) {
+	files := storage.NewStorage(zap.NewNop().Sugar(), t.TempDir(), "http://localhost/uploads/", []byte("key"), 0, 1, false, nil)
+
+	body, contentType := multipartFile(t, "image.jxl", []byte{0xFF, 0x0A, 1, 2, 3})
+
+	// Первая загрузка занимает единственный слот, пока клиент не досылает тело
+	reader, writer := io.Pipe()
+	done := make(chan error)
+
+	go func() {
+		_, err := files.SaveFile(uploadRequest(t, reader, contentType))
+		done <- err
+	}()
+
+	_, err := writer.Write(body.Bytes()[:10])
+	require.NoError(t, err)
+
+	second, secondType := multipartFile(t, "image.jxl", []byte{0xFF, 0x0A})
+	_, err = files.SaveFile(uploadRequest(t, second, secondType))
+	require.ErrorIs(t, err, models.ErrServiceUnavailable)
+
+	_, err = writer.Write(body.Bytes()[10:])
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	require.NoError(t, <-done)
+
+	// После завершения первой загрузки слот освобождается
+	second, secondType = multipartFile(t, "image.jxl", []byte{0xFF, 0x0A})
+	_, err = files.SaveFile(uploadRequest(t, second, secondType))
+	require.NoError(t, err)
+}
+
+type testReferrer []string
+
+func (r testReferrer) ReferencedUploads() []string { return r }
+
+func TestStorage_ResetUser(t *testing.T) {
+	dir := t.TempDir()
+	files := storage.NewStorage(zap.NewNop().Sugar(), dir, "http://localhost/uploads/", []byte("key"), 0, 1, false, nil)
+
+	upload := func(userID string, data []byte) string {
+		body, contentType := multipartFile(t, "image.jxl", data)
+		writer, request := uploadRequest(t, body, contentType)
+
+		name, err := files.SaveFile(writer, request.WithContext(models.ContextWithUser(t.Context(), userID)))
+		require.NoError(t, err)
+
+		return name
+	}
+
+	shared := upload("alice", []byte{0xFF, 0x0A, 1})
+	require.Equal(t, shared, upload("bob", []byte{0xFF, 0x0A, 1}))
+	inReview := upload("alice", []byte{0xFF, 0x0A, 2})
+	own := upload("alice", []byte{0xFF, 0x0A, 3})
+
+	files.RegisterReferrer(testReferrer{"http://localhost/uploads/" + inReview})
+	files.ResetUser("alice")
+
+	// Остались файл, который загрузил и другой пользователь, и картинка из отзыва
+	require.FileExists(t, filepath.Join(dir, shared))
+	require.FileExists(t, filepath.Join(dir, inReview))
+	require.NoFileExists(t, filepath.Join(dir, own))
+
+	files.ResetUser("bob")
+	require.NoFileExists(t, filepath.Join(dir, shared))
+}
+
+func TestStorage_ServeUpload(t *testing.T) {
+	dir := t.TempDir()
+	files := storage.NewStorage(zap.NewNop().Sugar(), dir, "http://localhost/uploads/", []byte("key"), time.Minute, 1, false, nil)
+
+	body, contentType := multipartFile(t, "image.jxl", []byte{0xFF, 0x0A, 1, 2, 3, 4})
+	name, err := files.SaveFile(uploadRequest(t, body, contentType))
+	require.NoError(t, err)
+
+	serve := func(target string, header http.Header) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, target, nil)
+		maps.Copy(request.Header, header)
+
+		response := httptest.NewRecorder()
+		require.NoError(t, files.ServeUpload(response, request, name))
+
+		return response
+	}
+
+	response := serve("/uploads/"+name, nil)
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Equal(t, "image/jxl", response.Header().Get("Content-Type"))
+	require.Equal(t, "public, max-age=31536000, immutable", response.Header().Get("Cache-Control"))
+	require.Equal(t, `"`+strings.TrimSuffix(name, ".jxl")+`"`, response.Header().Get("ETag"))
+
+	response = serve("/uploads/"+name, http.Header{"If-None-Match": {response.Header().Get("ETag")}})
+	require.Equal(t, http.StatusNotModified, response.Code)
+
+	response = serve("/uploads/"+name, http.Header{"Range": {"bytes=2-3"}})
+	require.Equal(t, http.StatusPartialContent, response.Code)
+	require.Equal(t, []byte{1, 2}, response.Body.Bytes())
+
+	err = files.ServeUpload(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/uploads/x", nil), "../secret")
+	require.ErrorIs(t, err, models.ErrBadRequest)
+}
+
+func TestStorage_ServePrivateUpload(t *testing.T) {
+	dir := t.TempDir()
+	files := storage.NewStorage(zap.NewNop().Sugar(), dir, "http://localhost/uploads/", []byte("key"), time.Minute, 1, true, nil)
+
+	body, contentType := multipartFile(t, "image.jxl", []byte{0xFF, 0x0A, 1})
+	name, err := files.SaveFile(uploadRequest(t, body, contentType))
+	require.NoError(t, err)
+
+	err = files.ServeUpload(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/uploads/"+name, nil), name)
+	require.ErrorIs(t, err, models.ErrForbidden)
+
+	link, err := files.SignDownload(t.Context(), name)
+	require.NoError(t, err)
+
+	response := httptest.NewRecorder()
+	require.NoError(t, files.ServeUpload(response, httptest.NewRequest(http.MethodGet, link.DownloadURL, nil), name))
+	require.Equal(t, http.StatusOK, response.Code)
+	require.True(t, strings.HasPrefix(response.Header().Get("Cache-Control"), "private, max-age="))
+
+	_, err = files.SignDownload(t.Context(), "missing.jxl")
+	require.ErrorIs(t, err, models.ErrNotFound)
+}
+
+func TestFileLock(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "leader.lock")
+
+	first := storage.NewFileLock(path)
+	second := storage.NewFileLock(path)
+
+	acquired, err := first.TryAcquire(ctx, time.Second)
+	require.NoError(t, err)
+	require.True(t, acquired)
+
+	// Повторный захват своей блокировки - продление
+	acquired, err = first.TryAcquire(ctx, time.Second)
+	require.NoError(t, err)
+	require.True(t, acquired)
+
+	acquired, err = second.TryAcquire(ctx, time.Second)
+	require.NoError(t, err)
+	require.False(t, acquired)
+
+	require.NoError(t, first.Release(ctx))
+
+	acquired, err = second.TryAcquire(ctx, time.Second)
+	require.NoError(t, err)
+	require.True(t, acquired)
+	require.NoError(t, second.Release(ctx))
+}
+
+func TestImageProxy(t *testing.T) {
+	var requests atomic.Int32
+
+	image := bytes.Repeat([]byte{7}, 100)
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+
+		switch r.URL.Path {
+		case "/big.webp":
+			w.Header().Set("Content-Type", "image/webp")
+			_, _ = w.Write(bytes.Repeat([]byte{1}, 1000))
+		case "/page.html":
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte("<html></html>"))
+		default:
+			w.Header().Set("Content-Type", "image/webp")
+			_, _ = w.Write(image)
+		}
+	}))
+	defer origin.Close()
+
+	dir := t.TempDir()
+	proxy, err := storage.NewImageProxy(zap.NewNop().Sugar(), dir, "http://localhost/img/", []string{"127.0.0.1"}, 500, time.Second)
+	require.NoError(t, err)
+
+	products := []*models.Product{
+		{ID: "milk", Image: origin.URL + "/milk.webp"},
+		{ID: "big", Image: origin.URL + "/big.webp"},
+		{ID: "page", Image: origin.URL + "/page.html"},
+		{ID: "local", Image: "http://localhost/uploads/bread.jxl"},
+	}
+	require.NoError(t, proxy.RewriteProducts(products))
+	require.True(t, strings.HasPrefix(products[0].Image, "http://localhost/img/"))
+	require.Equal(t, "http://localhost/uploads/bread.jxl", products[3].Image)
+
+	// Повторная подмена не трогает уже подмененные ссылки
+	rewritten := products[0].Image
+	require.NoError(t, proxy.RewriteProducts(products))
+	require.Equal(t, rewritten, products[0].Image)
+
+	serve := func(link string) (*httptest.ResponseRecorder, error) {
+		hash := strings.TrimPrefix(link, "http://localhost/img/")
+		recorder := httptest.NewRecorder()
+
+		return recorder, proxy.ServeImage(recorder, httptest.NewRequest(http.MethodGet, "/img/"+hash, nil), hash)
+	}
+
+	for range 2 {
+		recorder, err := serve(products[0].Image)
+		require.NoError(t, err)
+		require.Equal(t, image, recorder.Body.Bytes())
+		require.Equal(t, "image/webp", recorder.Header().Get("Content-Type"))
+	}
+
+	require.Equal(t, int32(1), requests.Load())
+
+	_, err = serve(products[1].Image)
+	require.ErrorIs(t, err, models.ErrBadGateway)
+
+	_, err = serve(products[2].Image)
+	require.ErrorIs(t, err, models.ErrBadGateway)
+
+	_, err = serve("http://localhost/img/" + strings.Repeat("0", 64))
+	require.ErrorIs(t, err, models.ErrNotFound)
+
+	// Соответствие ссылкам переживает перезапуск
+	restarted, err := storage.NewImageProxy(zap.NewNop().Sugar(), dir, "http://localhost/img/", []string{"127.0.0.1"}, 500, time.Second)
+	require.NoError(t, err)
+
+	hash := strings.TrimPrefix(products[0].Image, "http://localhost/img/")
+	recorder := httptest.NewRecorder()
+	require.NoError(t, restarted.ServeImage(recorder, httptest.NewRequest(http.MethodGet, "/img/"+hash, nil), hash))
+	require.Equal(t, image, recorder.Body.Bytes())
+	require.Equal(t, int32(3), requests.Load())
+}
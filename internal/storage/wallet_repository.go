@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"eats-backend/internal/models"
+)
+
+// WalletRepository хранит кошельки в SQLite по строке на пользователя. Сервис кошелька записывает
+// в него каждое изменение до ответа пользователю.
+type WalletRepository struct {
+	store *SQLiteStore
+}
+
+func NewWalletRepository(store *SQLiteStore) *WalletRepository {
+	return &WalletRepository{store: store}
+}
+
+// SaveWallets записывает кошельки в одной транзакции, nil удаляет кошелек пользователя.
+func (r *WalletRepository) SaveWallets(ctx context.Context, wallets map[string]*models.UserWallet) error {
+	tx, err := r.store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("can't begin transaction: %w", err)
+	}
+	// после Commit возвращает ErrTxDone, ошибку отката проверять не нужно
+	defer func() { _ = tx.Rollback() }()
+
+	// Пользователи по порядку, чтобы запись не зависела от порядка map
+	for _, userID := range slices.Sorted(maps.Keys(wallets)) {
+		if err := saveWallet(ctx, tx, userID, wallets[userID]); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("can't commit wallets: %w", err)
+	}
+
+	return nil
+}
+
+func saveWallet(ctx context.Context, tx *sql.Tx, userID string, wallet *models.UserWallet) error {
+	if wallet == nil {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM wallets WHERE user_id = ?`, userID); err != nil {
+			return fmt.Errorf("can't delete wallet %s: %w", userID, err)
+		}
+
+		return nil
+	}
+
+	data, err := json.Marshal(wallet)
+	if err != nil {
+		return fmt.Errorf("can't marshal wallet %s: %w", userID, err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO wallets (user_id, data, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET data = excluded.data, updated_at = excluded.updated_at`,
+		userID, string(data), time.Now(),
+	)
+	if err != nil {
+		return fmt.Errorf("can't save wallet %s: %w", userID, err)
+	}
+
+	return nil
+}
+
+// Load читает кошельки всех пользователей
+func (r *WalletRepository) Load(ctx context.Context) (models.WalletData, error) {
+	result := models.WalletData{
+		Accounts:     make(map[string]map[string]*models.Account),
+		Transactions: make(map[string][]models.Transaction),
+		DailyTopups:  make(map[string]map[string]models.Money),
+		UserPhones:   make(map[string]string),
+		Contacts:     make(map[string]map[string]models.SavedWalletContact),
+	}
+
+	rows, err := r.store.db.QueryContext(ctx, `SELECT user_id, data FROM wallets`)
+	if err != nil {
+		return models.WalletData{}, fmt.Errorf("can't load wallets: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID, data string
+		if err := rows.Scan(&userID, &data); err != nil {
+			return models.WalletData{}, fmt.Errorf("can't scan wallet: %w", err)
+		}
+
+		var wallet models.UserWallet
+		if err := json.Unmarshal([]byte(data), &wallet); err != nil {
+			return models.WalletData{}, fmt.Errorf("can't unmarshal wallet %s: %w", userID, err)
+		}
+
+		if wallet.Accounts != nil {
+			result.Accounts[userID] = wallet.Accounts
+		}
+
+		if wallet.Transactions != nil {
+			result.Transactions[userID] = wallet.Transactions
+		}
+
+		if wallet.DailyTopups != nil {
+			result.DailyTopups[userID] = wallet.DailyTopups
+		}
+
+		if wallet.Phone != "" {
+			result.UserPhones[userID] = wallet.Phone
+		}
+
+		if wallet.Contacts != nil {
+			result.Contacts[userID] = wallet.Contacts
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return models.WalletData{}, fmt.Errorf("can't load wallets: %w", err)
+	}
+
+	return result, nil
+}
+
+// LoadOrSeed заменяет data кошельками из базы. При первом запуске база заполняется кошельками из data.
+func (r *WalletRepository) LoadOrSeed(ctx context.Context, data *models.WalletData) error {
+	seeded, err := r.store.seed(ctx, "wallets", func(tx *sql.Tx) error {
+		for _, userID := range data.Users() {
+			if err := saveWallet(ctx, tx, userID, userWallet(*data, userID)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil || seeded {
+		return err
+	}
+
+	loaded, err := r.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	*data = loaded
+
+	return nil
+}
+
+func userWallet(data models.WalletData, userID string) *models.UserWallet {
+	return &models.UserWallet{
+		Accounts:     data.Accounts[userID],
+		Transactions: data.Transactions[userID],
+		DailyTopups:  data.DailyTopups[userID],
+		Phone:        data.UserPhones[userID],
+		Contacts:     data.Contacts[userID],
+	}
+}
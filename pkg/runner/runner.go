@@ -2,12 +2,15 @@ package runner
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net"
 	"net/http"
 	"sync"
 	"time"
+
+	"golang.org/x/crypto/acme/autocert"
 )
 
 type Server interface {
@@ -15,27 +18,83 @@ type Server interface {
 	Shutdown(ctx context.Context) error
 }
 
+// Options адрес и TLS для запуска сервера.
+type Options struct {
+	// Адрес вида ":8080", "127.0.0.1:8080" или "[::1]:8080". Без хоста слушаются IPv4 и IPv6.
+	Addr string
+	TLS  TLSOptions
+}
+
+// TLSOptions настройки HTTPS. Сертификат берется из файлов или выпускается через ACME (Let's Encrypt),
+// без них сервер работает по HTTP.
+type TLSOptions struct {
+	CertFile string
+	KeyFile  string
+
+	// Хосты, для которых выпускается сертификат через ACME, и каталог для хранения выпущенных сертификатов.
+	AutocertHosts    []string
+	AutocertCacheDir string
+
+	// Адрес HTTP-сервера, который перенаправляет на HTTPS. Для ACME нужен порт 80, через него
+	// проходит проверка владения хостом. Пустой - без перенаправления.
+	RedirectAddr string
+}
+
 func RunServer(
 	ctx context.Context,
 	server Server,
-	port string,
+	opts Options,
 	errChan chan<- error,
 	wgr *sync.WaitGroup,
 ) error {
-	return runServer(ctx, server, port, errChan, wgr, net.Listen)
+	tlsConfig, redirect, err := opts.TLS.config(opts.Addr)
+	if err != nil {
+		return err
+	}
+
+	listen := net.Listen
+	if tlsConfig != nil {
+		listen = func(network, addr string) (net.Listener, error) {
+			listener, err := net.Listen(network, addr)
+			if err != nil {
+				return nil, err
+			}
+
+			return tls.NewListener(listener, tlsConfig), nil
+		}
+	}
+
+	if err := runServer(ctx, server, opts.Addr, errChan, wgr, listen); err != nil {
+		return err
+	}
+
+	if redirect == nil {
+		return nil
+	}
+
+	redirectServer := &http.Server{
+		Handler:           redirect,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	if err := runServer(ctx, redirectServer, opts.TLS.RedirectAddr, errChan, wgr, net.Listen); err != nil {
+		return fmt.Errorf("can't run https redirect: %w", err)
+	}
+
+	return nil
 }
 
 func runServer(
 	ctx context.Context,
 	server Server,
-	port string,
+	addr string,
 	errChan chan<- error,
 	wgr *sync.WaitGroup,
 	listen func(string, string) (net.Listener, error),
 ) error {
-	listener, err := listen("tcp4", port)
+	listener, err := listen("tcp", addr)
 	if err != nil {
-		return fmt.Errorf("can't listen tcp port %s: %w", port, err)
+		return fmt.Errorf("can't listen tcp address %s: %w", addr, err)
 	}
 
 	wgr.Add(1)
@@ -69,3 +128,77 @@ func runServer(
 
 	return nil
 }
+
+// config возвращает TLS-конфигурацию и обработчик для HTTP-сервера перенаправления.
+// Без TLS оба результата nil.
+func (o TLSOptions) config(addr string) (*tls.Config, http.Handler, error) {
+	useFiles := o.CertFile != "" || o.KeyFile != ""
+	useAutocert := len(o.AutocertHosts) > 0
+
+	switch {
+	case useFiles && useAutocert:
+		return nil, nil, errors.New("tls: set either certificate files or autocert hosts, not both")
+	case useFiles && (o.CertFile == "" || o.KeyFile == ""):
+		return nil, nil, errors.New("tls: both certificate and key files are required")
+	case !useFiles && !useAutocert:
+		if o.RedirectAddr != "" {
+			return nil, nil, errors.New("tls: https redirect requires tls")
+		}
+
+		return nil, nil, nil
+	}
+
+	redirect := httpsRedirect(addr)
+
+	var tlsConfig *tls.Config
+
+	if useFiles {
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("tls: can't load certificate: %w", err)
+		}
+
+		// Listener оборачивается в TLS вручную, поэтому HTTP/2 нужно объявить самим
+		tlsConfig = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+			NextProtos:   []string{"h2", "http/1.1"},
+		}
+	} else {
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(o.AutocertHosts...),
+			Cache:      autocert.DirCache(o.AutocertCacheDir),
+		}
+
+		tlsConfig = manager.TLSConfig()
+		// Ответы на проверки ACME, остальные запросы перенаправляются на HTTPS
+		redirect = manager.HTTPHandler(redirect)
+	}
+
+	if o.RedirectAddr == "" {
+		return tlsConfig, nil, nil
+	}
+
+	return tlsConfig, redirect, nil
+}
+
+// httpsRedirect перенаправляет запрос на тот же хост и путь по HTTPS, на порт основного сервера
+func httpsRedirect(addr string) http.Handler {
+	_, port, _ := net.SplitHostPort(addr)
+
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		host := request.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+
+		target := "https://" + host + request.URL.RequestURI()
+
+		http.Redirect(writer, request, target, http.StatusMovedPermanently)
+	})
+}